@@ -92,6 +92,14 @@ func init() {
 	startCmd.Flags().StringVar(&cfg.PrometheusConfig.BearerTokenFile, "prometheus-bearer-token-file", "", "File containing bearer token to authenticate against Prometheus. Takes precedence over prometheus-bearer-token.")
 	startCmd.Flags().StringVar(&cfg.PrometheusConfig.CAFile, "prometheus-ca-file", "", "The path to the certificate authority to use to connect to Prometheus. If empty, defaults to system CAs")
 
+	startCmd.Flags().StringVar(&cfg.AWSWebIdentityTokenFile, "aws-web-identity-token-file", "", "The path of the web identity token used by ReportDataSources with AWS credentials using a webIdentity. If empty, web identities can't be used.")
+
+	startCmd.Flags().BoolVar(&cfg.KubernetesInventoryAllNamespaces, "kubernetes-inventory-all-namespaces", false, "if true, KubernetesInventory ReportDataSources can snapshot the objects in every namespace and Nodes, otherwise only the objects in their own namespace.")
+
+	startCmd.Flags().StringSliceVar(&cfg.ExternalDatabaseAllowedHosts, "external-database-allowed-hosts", nil, "the hosts ExternalDatabase ReportDataSources can connect to, as hostnames, wildcards of subdomains like *.example.com, IP addresses or CIDRs. If empty, ExternalDatabase ReportDataSources can't connect to any host.")
+	startCmd.Flags().BoolVar(&cfg.ExternalDatabaseCatalogUpdatesEnabled, "external-database-catalog-updates-enabled", false, "if true, the Presto catalogs of ExternalDatabase ReportDataSources are stored in the presto-external-catalogs Secret. Each change of the catalogs restarts Presto, failing the queries running at the time.")
+	startCmd.Flags().DurationVar(&cfg.ExternalDatabaseCatalogUpdateInterval, "external-database-catalog-update-interval", operator.DefaultExternalDatabaseCatalogUpdateInterval, "How often the changes to the Presto catalogs of ExternalDatabase ReportDataSources are stored together, if --external-database-catalog-updates-enabled is set. Each change of the catalogs restarts Presto.")
+
 	startCmd.Flags().StringVar(&cfg.ProxyTrustedCABundle, "proxy-trusted-ca-bundle", "", "The path to the certificate authority bundle used to connect to the cluster-wide https proxy.")
 
 	startCmd.Flags().BoolVar(&cfg.DisablePrometheusMetricsImporter, "disable-prometheus-metrics-importer", false, "disables collecting Prometheus metrics periodically")
@@ -106,6 +114,12 @@ func init() {
 
 	startCmd.Flags().DurationVar(&cfg.PrometheusDataSourceMaxQueryRangeDuration, "prometheus-datasource-max-query-range-duration", operator.DefaultPrometheusDataSourceMaxQueryRangeDuration, "If non-zero specifies the maximum duration of time to query from Prometheus. When backfilling, this value is used for the ChunkSize when querying Prometheus.")
 	startCmd.Flags().DurationVar(&cfg.PrometheusDataSourceMaxBackfillImportDuration, "prometheus-datasource-max-import-backfill-duration", operator.DefaultPrometheusDataSourceMaxBackfillImportDuration, "If non-zero specifies the maximum duration of time before the current to look back for data when backfilling. Has no effect if prometheus-datasource-import-from is set.")
+	startCmd.Flags().DurationVar(&cfg.PrometheusDataSourceRetention, "prometheus-datasource-retention", operator.DefaultPrometheusDataSourceRetention, "How long Prometheus retains data for. Gaps in imported data older than this are not re-imported.")
+	startCmd.Flags().DurationVar(&cfg.PrometheusDataSourceCoverageCheckInterval, "prometheus-datasource-coverage-check-interval", operator.DefaultPrometheusDataSourceCoverageCheckInterval, "How often the data imported by Prometheus ReportDataSources is checked for gaps, which are re-imported. If zero, gaps are not checked for.")
+	startCmd.Flags().BoolVar(&cfg.PrometheusDataSourceWriteFiles, "prometheus-datasource-write-files", false, "If true, new Prometheus ReportDataSource tables are stored as Parquet, and metrics are stored by writing Parquet files to S3 or the local filesystem instead of inserting them using Presto.")
+	startCmd.Flags().IntVar(&cfg.PrometheusDataSourceMaxConcurrentQueries, "prometheus-datasource-max-concurrent-queries", operator.DefaultPrometheusDataSourceMaxConcurrentQueries, "The maximum number of Prometheus queries run at once by the imports of all Prometheus ReportDataSources. Waiting queries are run round-robin between ReportDataSources. If zero, queries are not limited.")
+	startCmd.Flags().IntVar(&cfg.PrometheusDataSourceMaxConcurrentQueriesPerPrometheus, "prometheus-datasource-max-concurrent-queries-per-prometheus", operator.DefaultPrometheusDataSourceMaxConcurrentQueriesPerPrometheus, "The maximum number of Prometheus queries run at once by imports against the same Prometheus. If zero, queries are only limited by --prometheus-datasource-max-concurrent-queries.")
+	startCmd.Flags().IntVar(&cfg.PrometheusDataSourceChunkConcurrency, "prometheus-datasource-chunk-concurrency", operator.DefaultPrometheusDataSourceChunkConcurrency, "The number of chunks of a single Prometheus ReportDataSource import fetched from Prometheus at once. Chunks are always stored in order.")
 	startCmd.Flags().StringVar(&prometheusDataSourceImportFrom, "prometheus-datasource-import-from", "", "If non-empty, expects an RFC3339 timestamp indicating when Prometheus ReportDataSource data should be backfilled from.")
 
 	startCmd.Flags().DurationVar(&cfg.LeaderLeaseDuration, "lease-duration", defaultLeaseDuration, "controls how much time elapses before declaring leader")
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/kube-reporting/metering-operator/cmd/helpers"
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reporting/querytest"
+)
+
+var (
+	testQueriesResourcePaths []string
+	testQueriesTestCasesPath string
+	testQueriesPrestoHost    string
+	testQueriesCfg           querytest.Config
+	testQueriesUpdate        bool
+
+	testQueriesCmd = &cobra.Command{
+		Use:   "test-queries",
+		Short: "renders ReportQueries and compares them with golden SQL files, and with --presto-host, compares their results on fixture data",
+		Long: `test-queries renders ReportQueries the same way the reporting-operator does,
+and compares the rendered queries with golden SQL files. Query results are only
+checked if --presto-host is set, in which case the fixture data of each test case
+is stored in the tables of the ReportDataSources the query depends on, and the
+query results are compared with the expected results as well.`,
+		RunE: runTestQueries,
+	}
+)
+
+func init() {
+	testQueriesCmd.Flags().StringVar(&logLevelStr, "log-level", log.InfoLevel.String(), "log level")
+	testQueriesCmd.Flags().StringSliceVar(&testQueriesResourcePaths, "resources", nil, "files or directories containing the ReportQuery and ReportDataSource YAML to load")
+	testQueriesCmd.Flags().StringVar(&testQueriesTestCasesPath, "test-cases", "", "the file containing the test cases to run")
+	testQueriesCmd.Flags().StringVar(&testQueriesPrestoHost, "presto-host", "", "the hostname:port of the Presto to run queries against, if empty queries are only rendered")
+	testQueriesCmd.Flags().StringVar(&testQueriesCfg.Catalog, "presto-catalog", querytest.DefaultCatalog, "the Presto catalog to create ReportDataSource tables in")
+	testQueriesCmd.Flags().StringVar(&testQueriesCfg.Schema, "presto-schema", querytest.DefaultSchema, "the Presto schema to create ReportDataSource tables in")
+	testQueriesCmd.Flags().StringVar(&testQueriesCfg.Namespace, "namespace", querytest.DefaultNamespace, "the namespace used for rendering queries and naming tables")
+	testQueriesCmd.Flags().BoolVar(&testQueriesUpdate, "update", false, "if true, overwrite the golden files with the output instead of comparing them")
+
+	rootCmd.AddCommand(testQueriesCmd)
+}
+
+func runTestQueries(cmd *cobra.Command, args []string) error {
+	logger := helpers.SetupLogger(logLevelStr, false, log.Fields{
+		"app": "metering",
+	})
+
+	if testQueriesTestCasesPath == "" {
+		return fmt.Errorf("--test-cases must be set")
+	}
+	resources, err := querytest.LoadResources(testQueriesResourcePaths...)
+	if err != nil {
+		return err
+	}
+	testCases, err := querytest.LoadTestCases(testQueriesTestCasesPath)
+	if err != nil {
+		return err
+	}
+
+	var queryer db.Queryer
+	if testQueriesPrestoHost != "" {
+		prestoURL := url.URL{
+			Scheme:   "http",
+			User:     url.User("reporting-operator"),
+			Host:     testQueriesPrestoHost,
+			RawQuery: url.Values{"catalog": {testQueriesCfg.Catalog}, "schema": {testQueriesCfg.Schema}}.Encode(),
+		}
+		prestoDB, err := sql.Open("presto", prestoURL.String())
+		if err != nil {
+			return err
+		}
+		defer prestoDB.Close()
+		queryer = db.NewLoggingQueryer(prestoDB, logger, true)
+	}
+
+	harness := querytest.New(queryer, testQueriesCfg, resources)
+	if !harness.ChecksResults() {
+		logger.Warnf("--presto-host isn't set, only the rendered queries are compared with their golden files, and query results aren't checked")
+	}
+	var failed int
+	for _, testCase := range testCases {
+		if err := harness.Check(testCase, testQueriesUpdate); err != nil {
+			logger.WithError(err).Errorf("test case %s failed", testCase.Name)
+			failed++
+			continue
+		}
+		logger.Infof("test case %s passed", testCase.Name)
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d test cases failed", failed, len(testCases))
+	}
+	return nil
+}
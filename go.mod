@@ -11,6 +11,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/go-chi/chi v3.3.2+incompatible
 	github.com/golang/mock v1.4.3
+	github.com/golang/snappy v0.0.1
 	github.com/huandu/xstrings v1.3.0 // indirect
 	github.com/prestodb/presto-go-client v0.0.0-20180328163046-568bdb2f6dbc
 	github.com/prometheus/client_golang v1.7.1
@@ -22,6 +23,7 @@ require (
 	github.com/stretchr/testify v1.6.1
 	github.com/taozle/go-hive-driver v0.0.0-20181206100408-79951111cb07
 	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	google.golang.org/protobuf v1.25.0
 )
 
 require (
@@ -41,12 +43,14 @@ require (
 	github.com/openshift/client-go v0.0.0-20210112165513-ebc401615f47
 	github.com/operator-framework/api v0.5.3
 	github.com/operator-framework/operator-lifecycle-manager v0.17.0
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	k8s.io/api v0.20.2
 	k8s.io/apiextensions-apiserver v0.20.1
 	k8s.io/apimachinery v0.20.2
 	k8s.io/client-go v0.20.2
 	k8s.io/code-generator v0.20.2
 	k8s.io/klog v1.0.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -0,0 +1,40 @@
+#!/bin/bash
+set -e
+
+# Runs the querytest test cases of the openshift-metering chart's ReportQueries
+# against a local single node Presto, so the results of the queries are
+# compared with the expected results, not only the rendered SQL.
+
+ROOT_DIR=$(dirname "${BASH_SOURCE[0]}")/..
+# shellcheck disable=SC1090
+source "${ROOT_DIR}/hack/common.sh"
+
+: "${CONTAINER_RUNTIME_CMD:=docker}"
+: "${QUERYTEST_PRESTO_IMAGE:=prestosql/presto:340}"
+: "${QUERYTEST_PRESTO_PORT:=18080}"
+: "${QUERYTEST_PRESTO_TIMEOUT:=180}"
+
+CONTAINER_NAME="metering-querytest-presto-$$"
+
+function cleanup() {
+    exit_status=$?
+    "$CONTAINER_RUNTIME_CMD" rm -f "$CONTAINER_NAME" >/dev/null 2>&1 || true
+    exit "$exit_status"
+}
+trap cleanup EXIT
+
+"$CONTAINER_RUNTIME_CMD" run -d --name "$CONTAINER_NAME" -p "127.0.0.1:${QUERYTEST_PRESTO_PORT}:8080" "$QUERYTEST_PRESTO_IMAGE"
+
+echo "Waiting for Presto to become ready"
+deadline=$((SECONDS + QUERYTEST_PRESTO_TIMEOUT))
+until curl -sf "http://127.0.0.1:${QUERYTEST_PRESTO_PORT}/v1/info" | grep -q '"starting":false'; do
+    if (( SECONDS >= deadline )); then
+        echo "Presto didn't become ready within ${QUERYTEST_PRESTO_TIMEOUT} seconds"
+        "$CONTAINER_RUNTIME_CMD" logs "$CONTAINER_NAME" || true
+        exit 1
+    fi
+    sleep 2
+done
+
+METERING_QUERYTEST_PRESTO_HOST="127.0.0.1:${QUERYTEST_PRESTO_PORT}" \
+    go test -v -count=1 -run TestChartReportQueryResults ./pkg/operator/reporting/querytest/...
@@ -282,6 +282,7 @@ type OpenshiftReportingConfig struct {
 type OpenshiftReportingConfigSpec struct {
 	OpenshiftReportingDefaultStorageLocation     *OpenshiftReportingDefaultStorageLocationConfig     `json:"defaultStorageLocation,omitempty"`
 	OpenshiftReportingAWSBillingReportDataSource *OpenshiftReportingAWSBillingReportDataSourceConfig `json:"awsBillingReportDataSource,omitempty"`
+	OpenshiftReportingGCPBillingReportDataSource *OpenshiftReportingGCPBillingReportDataSourceConfig `json:"gcpBillingReportDataSource,omitempty"`
 	OpenshiftReportingDefaultReportDataSources   *OpenshiftReportingDefaultReportDataSourcesConfig   `json:"defaultReportDataSources,omitempty"`
 }
 type OpenshiftReportingDefaultStorageLocationConfig struct {
@@ -302,6 +303,12 @@ type OpenshiftReportingAWSBillingReportDataSourceConfig struct {
 	Prefix  string `json:"prefix,omitempty"`
 	Region  string `json:"region,omitempty"`
 }
+type OpenshiftReportingGCPBillingReportDataSourceConfig struct {
+	Enabled    *bool  `json:"enabled,omitempty"`
+	Bucket     string `json:"bucket,omitempty"`
+	Prefix     string `json:"prefix,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
+}
 type OpenshiftReportingDefaultReportDataSourcesConfig struct {
 	Base            *OpenshiftReportingDefaultReportDataSourcesBaseConfig `json:"base,omitempty"`
 	PostKubeVersion *OpenshiftReportingPostKubeVersionConfig              `json:"postKube_1_14,omitempty"`
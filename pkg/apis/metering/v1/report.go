@@ -63,8 +63,26 @@ type ReportSpec struct {
 
 	// Output is the storage location where results are sent.
 	Output *StorageLocationRef `json:"output,omitempty"`
+
+	// SchemaChangePolicy controls what happens when the columns of the
+	// ReportQuery change in a way that cannot be applied to the existing
+	// Report table. Columns appended to the ReportQuery are always added to
+	// the existing table. Defaults to Fail.
+	SchemaChangePolicy ReportSchemaChangePolicy `json:"schemaChangePolicy,omitempty"`
 }
 
+type ReportSchemaChangePolicy string
+
+const (
+	// ReportSchemaChangePolicyFail stops the Report from running when the
+	// ReportQuery columns are incompatible with the Report table.
+	ReportSchemaChangePolicyFail ReportSchemaChangePolicy = "Fail"
+	// ReportSchemaChangePolicyNewTable creates a new versioned table for the
+	// Report when the ReportQuery columns are incompatible with the Report
+	// table. Results stored in the previous table are kept.
+	ReportSchemaChangePolicyNewTable ReportSchemaChangePolicy = "NewTable"
+)
+
 type ReportPeriod string
 
 const (
@@ -119,6 +137,21 @@ type ReportStatus struct {
 	LastReportTime *meta.Time              `json:"lastReportTime,omitempty"`
 	NextReportTime *meta.Time              `json:"nextReportTime,omitempty"`
 	TableRef       v1.LocalObjectReference `json:"tableRef"`
+	// TableVersion is incremented each time a new table is created for
+	// the Report because of an incompatible ReportQuery change.
+	TableVersion int64 `json:"tableVersion,omitempty"`
+	// QueryGenerations records which generation of the ReportQuery was used
+	// to generate the results for each reporting period.
+	QueryGenerations []ReportQueryGeneration `json:"queryGenerations,omitempty"`
+}
+
+// ReportQueryGeneration is a range of consecutive reporting periods that were
+// generated using the same ReportQuery generation and table.
+type ReportQueryGeneration struct {
+	QueryGeneration int64                   `json:"queryGeneration"`
+	PeriodStart     meta.Time               `json:"periodStart"`
+	PeriodEnd       meta.Time               `json:"periodEnd"`
+	TableRef        v1.LocalObjectReference `json:"tableRef"`
 }
 
 type ReportCondition struct {
@@ -144,4 +177,8 @@ type ReportConditionType string
 
 const (
 	ReportRunning ReportConditionType = "Running"
+	// ReportStale is true when data the Report already generated results
+	// from has changed, and the affected reporting periods should be
+	// generated again.
+	ReportStale ReportConditionType = "Stale"
 )
@@ -3,6 +3,9 @@ package v1
 import (
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
 )
 
 var ReportDataSourceGVK = SchemeGroupVersion.WithKind("ReportDataSource")
@@ -32,6 +35,27 @@ type ReportDataSourceSpec struct {
 	// AWSBilling represents a datasource which points to a pre-existing S3
 	// bucket.
 	AWSBilling *AWSBillingDataSource `json:"awsBilling,omitempty"`
+	// AzureCostExport represents a datasource which points to the runs of
+	// an Azure Cost Management export in a storage container.
+	AzureCostExport *AzureCostExportDataSource `json:"azureCostExport,omitempty"`
+	// GCPBillingExport represents a datasource which points to the files of
+	// a Google Cloud billing export in a Cloud Storage bucket.
+	GCPBillingExport *GCPBillingExportDataSource `json:"gcpBillingExport,omitempty"`
+	// FileDrop represents a datasource which reads the files dropped into a
+	// directory of an S3 bucket or HDFS, such as monthly CSV files of costs.
+	FileDrop *FileDropDataSource `json:"fileDrop,omitempty"`
+	// KubernetesInventory represents a datasource which periodically
+	// snapshots Kubernetes objects, such as Pods and Nodes, from the API
+	// server.
+	KubernetesInventory *KubernetesInventoryDataSource `json:"kubernetesInventory,omitempty"`
+	// PushTable represents a datasource whose rows are pushed to the
+	// reporting-operator API by external systems, such as CI runners or
+	// licensing servers.
+	PushTable *PushTableDataSource `json:"pushTable,omitempty"`
+	// ExternalDatabase represents a datasource which reads a table of a
+	// PostgreSQL or MySQL database through a Presto catalog managed by the
+	// reporting-operator.
+	ExternalDatabase *ExternalDatabaseDataSource `json:"externalDatabase,omitempty"`
 	// PrestoTable represents a datasource which points to an existing
 	// PrestoTable CR.
 	PrestoTable *PrestoTableDataSource `json:"prestoTable,omitempty"`
@@ -42,17 +66,336 @@ type ReportDataSourceSpec struct {
 	// ReportQueryView  represents a datasource which creates a Presto
 	// view from a ReportQuery
 	ReportQueryView *ReportQueryViewDataSource `json:"reportQueryView,omitempty"`
+
+	// Backfill requests importing historical time ranges into a
+	// PrometheusMetricsImporter ReportDataSource. Backfills are processed
+	// one at a time in order.
+	Backfill []ReportDataSourceBackfill `json:"backfill,omitempty"`
+
+	// Retention configures how long the data of a
+	// PrometheusMetricsImporter or KubernetesInventory ReportDataSource is
+	// kept before its partitions are dropped. Data is kept forever if unset.
+	Retention *ReportDataSourceRetention `json:"retention,omitempty"`
+}
+
+type ReportDataSourceRetention struct {
+	// Period is how long data is kept, such as 2160h. A dt partition is
+	// dropped once all of it is older than Period.
+	Period *meta.Duration `json:"period,omitempty"`
+	// Days is the number of whole days kept in addition to the current
+	// day. Only one of Period and Days may be set.
+	Days *int64 `json:"days,omitempty"`
+}
+
+type ReportDataSourceBackfill struct {
+	// Name identifies the backfill in the status of the ReportDataSource.
+	Name string `json:"name"`
+	// Start and End are the time range to import. Changing them restarts
+	// the backfill.
+	Start meta.Time `json:"start"`
+	End   meta.Time `json:"end"`
+	// Overwrite, if true, replaces the dt partitions of the time range
+	// rather than adding to them. Since whole partitions are replaced, the
+	// time range is extended to start and end at midnight UTC.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// QueriesPerMinute limits how many Prometheus queries the backfill
+	// makes per minute, and defaults to 10.
+	QueriesPerMinute int `json:"queriesPerMinute,omitempty"`
 }
 
+type AWSBillingFormat string
+
+const (
+	// AWSBillingFormatCSV is the legacy Cost and Usage Report layout of
+	// gzip compressed CSV files.
+	AWSBillingFormatCSV AWSBillingFormat = "CSV"
+	// AWSBillingFormatParquet is the legacy Cost and Usage Report layout of
+	// Parquet files.
+	AWSBillingFormatParquet AWSBillingFormat = "Parquet"
+	// AWSBillingFormatCUR2 is the CUR 2.0 data export layout of Parquet
+	// files.
+	AWSBillingFormatCUR2 AWSBillingFormat = "CUR2"
+)
+
 type AWSBillingDataSource struct {
 	Source       *S3Bucket `json:"source"`
 	DatabaseName string    `json:"databaseName,omitempty"`
+	// Format is the layout of the reports in Source, and is detected from
+	// the report manifests if unset.
+	Format AWSBillingFormat `json:"format,omitempty"`
+	// Credentials configures how the operator authenticates to read the
+	// reports in Source. If unset, the credentials of the reporting-operator
+	// are used.
+	Credentials *AWSCredentials `json:"credentials,omitempty"`
 }
 
 type S3Bucket struct {
 	Region string `json:"region"`
 	Bucket string `json:"bucket"`
 	Prefix string `json:"prefix"`
+	// Endpoint is the URL of an S3-compatible object store to use instead
+	// of AWS S3, such as MinIO.
+	Endpoint string `json:"endpoint,omitempty"`
+	// ForcePathStyle addresses the bucket in the path of requests instead
+	// of in the host name, which most S3-compatible object stores require.
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+}
+
+// AWSCredentials are the credentials used to access an S3 bucket. Only one
+// of SecretRef and WebIdentity may be set, and AssumeRole assumes a role
+// using the credentials from either of them, or the credentials of the
+// reporting-operator if neither is set.
+type AWSCredentials struct {
+	// SecretRef is a Secret in the namespace of the ReportDataSource with
+	// the aws-access-key-id and aws-secret-access-key keys, and optionally
+	// an aws-session-token key.
+	SecretRef *v1.LocalObjectReference `json:"secretRef,omitempty"`
+	// WebIdentity assumes a role using a web identity token, such as the
+	// service account token projected for IAM Roles for Service Accounts.
+	WebIdentity *AWSWebIdentity `json:"webIdentity,omitempty"`
+	// AssumeRole assumes a role, such as a role of the payer account of an
+	// organization.
+	AssumeRole *AWSAssumeRole `json:"assumeRole,omitempty"`
+}
+
+// AWSWebIdentity assumes a role using the web identity token of the
+// reporting-operator, which is read from the token file configured by
+// reporting-operator.spec.config.aws.webIdentityTokenFile in the
+// MeteringConfig.
+type AWSWebIdentity struct {
+	RoleARN string `json:"roleARN"`
+}
+
+type AWSAssumeRole struct {
+	RoleARN    string `json:"roleARN"`
+	ExternalID string `json:"externalID,omitempty"`
+	// SessionName is the role session name, which defaults to
+	// reporting-operator.
+	SessionName string `json:"sessionName,omitempty"`
+}
+
+type AzureCostExportDataSource struct {
+	Source       *AzureBlobContainer `json:"source"`
+	DatabaseName string              `json:"databaseName,omitempty"`
+}
+
+type AzureBlobContainer struct {
+	StorageAccountName string `json:"storageAccountName"`
+	Container          string `json:"container"`
+	// Prefix is the directory of the export in Container, which is usually
+	// <root-folder>/<export-name>.
+	Prefix string `json:"prefix,omitempty"`
+	// Endpoint is the URL of the Blob service, and defaults to
+	// https://<storageAccountName>.blob.core.windows.net. It can be set to
+	// an emulator such as Azurite, eg: http://azurite:10000/devstoreaccount1.
+	Endpoint string `json:"endpoint,omitempty"`
+	// SecretRef is a Secret in the namespace of the ReportDataSource with
+	// the azure-storage-account-key key. Requests are anonymous if unset.
+	SecretRef *v1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+type GCPBillingExportFormat string
+
+const (
+	// GCPBillingExportFormatJSON is newline delimited JSON files, such as
+	// the files written by a BigQuery extract of the billing export table.
+	GCPBillingExportFormatJSON GCPBillingExportFormat = "JSON"
+	// GCPBillingExportFormatCSV is CSV files with a header, with the nested
+	// fields flattened into columns, such as service.description.
+	GCPBillingExportFormatCSV GCPBillingExportFormat = "CSV"
+	// GCPBillingExportFormatParquet is Parquet files with the nested fields
+	// of the billing export table.
+	GCPBillingExportFormatParquet GCPBillingExportFormat = "Parquet"
+)
+
+type GCPBillingExportDataSource struct {
+	Source       *GCSBucket `json:"source"`
+	DatabaseName string     `json:"databaseName,omitempty"`
+	// Format is the format of the files in Source, and is detected from the
+	// extensions of the files if unset.
+	Format GCPBillingExportFormat `json:"format,omitempty"`
+}
+
+type GCSBucket struct {
+	Bucket string `json:"bucket"`
+	// Prefix is the directory containing a directory for each invoice
+	// month, named either YYYYMM or invoice_month=YYYYMM.
+	Prefix string `json:"prefix,omitempty"`
+	// Endpoint is the URL of the Cloud Storage JSON API, and defaults to
+	// https://storage.googleapis.com. It can be set to a fake GCS server,
+	// eg: http://fake-gcs-server:4443.
+	Endpoint string `json:"endpoint,omitempty"`
+	// SecretRef is a Secret in the namespace of the ReportDataSource with
+	// the gcs-service-account.json key. If unset, the application default
+	// credentials of the reporting-operator are used, or requests are
+	// anonymous if Endpoint is set.
+	SecretRef *v1.LocalObjectReference `json:"secretRef,omitempty"`
+}
+
+type FileDropFormat string
+
+const (
+	// FileDropFormatCSV is delimited text files, read using the options in
+	// spec.fileDrop.csv.
+	FileDropFormatCSV FileDropFormat = "CSV"
+	// FileDropFormatJSON is JSON Lines files, which have a JSON object on
+	// each line.
+	FileDropFormatJSON FileDropFormat = "JSON"
+	// FileDropFormatParquet is Parquet files.
+	FileDropFormatParquet FileDropFormat = "Parquet"
+)
+
+type FileDropDataSource struct {
+	// S3 is the bucket and prefix the files are dropped into. Only one of
+	// S3 and HDFS may be set.
+	S3 *S3Bucket `json:"s3,omitempty"`
+	// Credentials configures how the operator authenticates to list the
+	// files in S3. If unset, the credentials of the reporting-operator are
+	// used.
+	Credentials *AWSCredentials `json:"credentials,omitempty"`
+	// HDFS is the HDFS directory the files are dropped into.
+	HDFS *HDFSDirectory `json:"hdfs,omitempty"`
+
+	DatabaseName string `json:"databaseName,omitempty"`
+	// Format is the format of the files. Files with the extension of a
+	// different format are ignored and reported as failed.
+	Format FileDropFormat `json:"format"`
+	// CSV configures how CSV files are read.
+	CSV *FileDropCSVOptions `json:"csv,omitempty"`
+	// Columns is the schema of the files. The columns of CSV files are read
+	// by position, and the columns of JSON and Parquet files by name.
+	Columns []hive.Column `json:"columns"`
+	// PathTemplate is the path of the directories containing the files,
+	// relative to the S3 prefix or HDFS directory, where each {name}
+	// placeholder is the value of a partition column of the table, eg:
+	// {vendor}/{month} or year={year}/month={month}. A placeholder matches
+	// all or part of a single directory name. If unset, the files must be
+	// directly in the S3 prefix or HDFS directory, and the table isn't
+	// partitioned.
+	PathTemplate string `json:"pathTemplate,omitempty"`
+}
+
+type HDFSDirectory struct {
+	// Path is the hdfs:// URI of the directory, eg:
+	// hdfs://hdfs-namenode-0.hdfs-namenode:9820/filedrop/licenses.
+	Path string `json:"path"`
+	// WebHDFSEndpoint is the URL of the WebHDFS API of the namenode, used to
+	// discover the files, and defaults to port 9870 of the namenode in
+	// Path, eg: http://hdfs-namenode-0.hdfs-namenode:9870.
+	WebHDFSEndpoint string `json:"webHDFSEndpoint,omitempty"`
+	// User is the user files are listed as, and defaults to the user of
+	// the reporting-operator.
+	User string `json:"user,omitempty"`
+}
+
+type FileDropCSVOptions struct {
+	// Header, if true, skips the first line of each file. Defaults to
+	// true.
+	Header *bool `json:"header,omitempty"`
+	// Separator is the character between fields, and defaults to ",".
+	Separator string `json:"separator,omitempty"`
+	// Quote is the character fields containing separators are quoted
+	// with, and defaults to a double quote.
+	Quote string `json:"quote,omitempty"`
+	// Escape is the character escaping quotes in quoted fields, and
+	// defaults to a backslash.
+	Escape string `json:"escape,omitempty"`
+}
+
+type KubernetesInventoryResource string
+
+const (
+	KubernetesInventoryPod                   KubernetesInventoryResource = "Pod"
+	KubernetesInventoryNode                  KubernetesInventoryResource = "Node"
+	KubernetesInventoryPersistentVolumeClaim KubernetesInventoryResource = "PersistentVolumeClaim"
+)
+
+type KubernetesInventoryDataSource struct {
+	// Resource is the kind of the objects snapshotted: Pod, Node or
+	// PersistentVolumeClaim.
+	Resource KubernetesInventoryResource `json:"resource"`
+	// Namespaces limits the snapshots of namespaced resources to the
+	// objects in these namespaces. Objects in every namespace are
+	// snapshotted if unset.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Selector limits the snapshots to the objects with matching labels.
+	Selector *meta.LabelSelector `json:"selector,omitempty"`
+	// SnapshotInterval is how often the objects are snapshotted, and
+	// defaults to 15m.
+	SnapshotInterval *meta.Duration `json:"snapshotInterval,omitempty"`
+	// Columns are the fields and labels of the objects stored in addition
+	// to the timestamp, namespace, name, uid, owner_kind, owner_name and
+	// labels columns. Columns can't be changed once the table is created.
+	Columns []KubernetesInventoryColumn `json:"columns,omitempty"`
+	Storage *StorageLocationRef         `json:"storage,omitempty"`
+}
+
+// KubernetesInventoryColumn is a varchar column of a KubernetesInventory
+// table. Only one of Field and Label may be set, and the column is null for
+// objects without a value.
+type KubernetesInventoryColumn struct {
+	// Name is the name of the column, which must be lowercase.
+	Name string `json:"name"`
+	// Field is a JSONPath template of the value, in the format of kubectl
+	// get -o jsonpath, eg: {.status.qosClass}. The braces may be omitted.
+	Field string `json:"field,omitempty"`
+	// Label is the key of the label whose value is stored.
+	Label string `json:"label,omitempty"`
+}
+
+type PushTableDataSource struct {
+	// Columns is the schema of the pushed rows. The type of each column
+	// must be one of string, boolean, tinyint, smallint, int, bigint,
+	// double, date or timestamp. Columns can't be changed once the table
+	// is created.
+	Columns []hive.Column `json:"columns"`
+	// KeyColumns are the columns whose values identify a row. A row is
+	// ignored if a row with the same values was already pushed, so pushes
+	// can be retried safely. If unset, rows are identified by the
+	// Idempotency-Key header of the request and their position in it.
+	KeyColumns []string `json:"keyColumns,omitempty"`
+	// TokenSecretRef is a Secret in the namespace of the ReportDataSource
+	// whose token key is the token pushes must send in the
+	// X-Metering-Push-Token header.
+	TokenSecretRef v1.LocalObjectReference `json:"tokenSecretRef"`
+
+	DatabaseName string `json:"databaseName,omitempty"`
+}
+
+type ExternalDatabaseType string
+
+const (
+	ExternalDatabasePostgreSQL ExternalDatabaseType = "PostgreSQL"
+	ExternalDatabaseMySQL      ExternalDatabaseType = "MySQL"
+)
+
+type ExternalDatabaseDataSource struct {
+	// Type is the kind of database: PostgreSQL or MySQL.
+	Type ExternalDatabaseType `json:"type"`
+	// Host is the hostname of the database server.
+	Host string `json:"host"`
+	// Port defaults to 5432 for PostgreSQL and 3306 for MySQL.
+	Port int32 `json:"port,omitempty"`
+	// Database is the database the table is in.
+	Database string `json:"database"`
+	// Schema is the PostgreSQL schema of the table, and defaults to public.
+	// It must be unset for MySQL, whose databases are schemas.
+	Schema string `json:"schema,omitempty"`
+	// Table is the name of the table. Presto lowercases the names of
+	// tables, so tables whose names have uppercase letters can't be read.
+	Table string `json:"table"`
+	// CredentialsSecretRef is a Secret in the namespace of the
+	// ReportDataSource whose username and password keys are the
+	// credentials of a database user which can read the table.
+	CredentialsSecretRef v1.LocalObjectReference `json:"credentialsSecretRef"`
+	// ConnectionProperties are added to the JDBC connection URL of the
+	// database, eg: sslmode: require for PostgreSQL. Only the properties
+	// configuring TLS and timeouts are supported.
+	ConnectionProperties map[string]string `json:"connectionProperties,omitempty"`
+	// Columns are the columns the table is expected to have, with their
+	// types as read by Presto. If set, the table isn't usable from Reports
+	// until it has each of them.
+	Columns []presto.Column `json:"columns,omitempty"`
 }
 
 type PrometheusQueryConfig struct {
@@ -63,13 +406,130 @@ type PrometheusQueryConfig struct {
 
 type PrometheusConnectionConfig struct {
 	URL string `json:"url,omitempty"`
+	// Endpoints is a list of Prometheus or Thanos instances to query instead
+	// of URL. Endpoints are queried in order, failing over to the next
+	// endpoint when a query fails, unless ReplicaLabels is set.
+	Endpoints []PrometheusEndpoint `json:"endpoints,omitempty"`
+	// ReplicaLabels are the names of the labels identifying the replicas of
+	// highly available Prometheus instances, such as prometheus_replica. If
+	// set, every endpoint is queried and their results are merged, removing
+	// the ReplicaLabels and deduplicating the samples of series which only
+	// differed by them.
+	ReplicaLabels []string `json:"replicaLabels,omitempty"`
+}
+
+type PrometheusEndpoint struct {
+	// Name identifies the endpoint in the ReportDataSource status.
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// BearerToken selects a key of a Secret in the namespace of the
+	// ReportDataSource containing the token to authenticate with.
+	BearerToken *v1.SecretKeySelector `json:"bearerToken,omitempty"`
+	// CertificateAuthority selects a key of a Secret in the namespace of the
+	// ReportDataSource containing the PEM encoded CA bundle to verify the
+	// endpoint's certificate with.
+	CertificateAuthority *v1.SecretKeySelector `json:"certificateAuthority,omitempty"`
+	// InsecureSkipVerify disables verifying the endpoint's certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
+type PrometheusMetricsImporterMode string
+
+const (
+	// PrometheusMetricsImporterModeRange imports the value of each series
+	// at every stepSize using query_range queries.
+	PrometheusMetricsImporterModeRange PrometheusMetricsImporterMode = "range"
+	// PrometheusMetricsImporterModeSnapshot runs an instant query every
+	// queryInterval and only stores the series which were added, changed
+	// or removed since the previous snapshot.
+	PrometheusMetricsImporterModeSnapshot PrometheusMetricsImporterMode = "snapshot"
+)
+
 type PrometheusMetricsImporterDataSource struct {
-	Query            string                      `json:"query"`
-	QueryConfig      *PrometheusQueryConfig      `json:"queryConfig,omitempty"`
-	Storage          *StorageLocationRef         `json:"storage,omitempty"`
-	PrometheusConfig *PrometheusConnectionConfig `json:"prometheusConfig,omitempty"`
+	// Mode is how Query is imported, and defaults to range. The mode can't
+	// be changed once the table of the ReportDataSource is created.
+	Mode             PrometheusMetricsImporterMode `json:"mode,omitempty"`
+	Query            string                        `json:"query,omitempty"`
+	QueryConfig      *PrometheusQueryConfig        `json:"queryConfig,omitempty"`
+	Storage          *StorageLocationRef           `json:"storage,omitempty"`
+	PrometheusConfig *PrometheusConnectionConfig   `json:"prometheusConfig,omitempty"`
+	// RemoteWrite configures the ReportDataSource to receive metrics pushed
+	// using the Prometheus remote_write protocol instead of importing them
+	// by running Query against Prometheus.
+	RemoteWrite *PrometheusRemoteWriteConfig `json:"remoteWrite,omitempty"`
+	// RemoteRead configures the ReportDataSource to import the raw samples
+	// of the selected series using the Prometheus remote_read API instead
+	// of running Query.
+	RemoteRead *PrometheusRemoteReadConfig `json:"remoteRead,omitempty"`
+	// RelabelConfigs rewrite and filter the labels of each series before
+	// it's stored, the same as the relabel_configs of Prometheus.
+	RelabelConfigs []PrometheusRelabelConfig `json:"relabelConfigs,omitempty"`
+}
+
+type PrometheusRelabelAction string
+
+const (
+	PrometheusRelabelReplace   PrometheusRelabelAction = "replace"
+	PrometheusRelabelKeep      PrometheusRelabelAction = "keep"
+	PrometheusRelabelDrop      PrometheusRelabelAction = "drop"
+	PrometheusRelabelHashMod   PrometheusRelabelAction = "hashmod"
+	PrometheusRelabelLabelDrop PrometheusRelabelAction = "labeldrop"
+	PrometheusRelabelLabelKeep PrometheusRelabelAction = "labelkeep"
+)
+
+type PrometheusRelabelConfig struct {
+	// SourceLabels are the labels whose values are joined by Separator and
+	// matched against Regex.
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	// Separator defaults to ";".
+	Separator *string `json:"separator,omitempty"`
+	// Regex is a fully anchored regular expression, and defaults to "(.*)".
+	Regex *string `json:"regex,omitempty"`
+	// Modulus is the modulus of the hash of the source label values for
+	// the hashmod action.
+	Modulus uint64 `json:"modulus,omitempty"`
+	// TargetLabel is the label set by the replace and hashmod actions.
+	TargetLabel string `json:"targetLabel,omitempty"`
+	// Replacement is the value TargetLabel is set to by the replace action,
+	// and defaults to "$1".
+	Replacement *string `json:"replacement,omitempty"`
+	// Action defaults to replace.
+	Action PrometheusRelabelAction `json:"action,omitempty"`
+}
+
+type PrometheusRemoteReadConfig struct {
+	// Matchers select the series to import, the same as the matchers of a
+	// PromQL selector. At least one matcher is required.
+	Matchers []PrometheusLabelMatcher `json:"matchers"`
+	// Resample, if true, resamples each series to the queryConfig stepSize
+	// like a query_range query of a selector does. Otherwise every raw
+	// sample is imported.
+	Resample bool `json:"resample,omitempty"`
+}
+
+type PrometheusRemoteWriteConfig struct {
+	// Matchers select the series written to this ReportDataSource. A series
+	// must match all Matchers, and an empty list matches every series.
+	Matchers []PrometheusLabelMatcher `json:"matchers,omitempty"`
+	// StepSize is the time precision recorded for each sample, and defaults
+	// to the queryConfig stepSize.
+	StepSize *meta.Duration `json:"stepSize,omitempty"`
+}
+
+type PrometheusLabelMatchType string
+
+const (
+	PrometheusLabelMatchEqual     PrometheusLabelMatchType = "="
+	PrometheusLabelMatchNotEqual  PrometheusLabelMatchType = "!="
+	PrometheusLabelMatchRegexp    PrometheusLabelMatchType = "=~"
+	PrometheusLabelMatchNotRegexp PrometheusLabelMatchType = "!~"
+)
+
+type PrometheusLabelMatcher struct {
+	Name string `json:"name"`
+	// Type is one of "=", "!=", "=~" or "!~", and defaults to "=".
+	Type  PrometheusLabelMatchType `json:"type,omitempty"`
+	Value string                   `json:"value"`
 }
 
 type PrestoTableDataSource struct {
@@ -92,6 +552,235 @@ type ReportQueryViewDataSource struct {
 type ReportDataSourceStatus struct {
 	TableRef                      v1.LocalObjectReference        `json:"tableRef"`
 	PrometheusMetricsImportStatus *PrometheusMetricsImportStatus `json:"prometheusMetricsImportStatus,omitempty"`
+	// PrometheusEndpoints is the health of each of the endpoints of a
+	// PrometheusMetricsImporter ReportDataSource with multiple endpoints.
+	PrometheusEndpoints []PrometheusEndpointStatus `json:"prometheusEndpoints,omitempty"`
+	// Backfills is the progress of each backfill in spec.backfill.
+	Backfills []ReportDataSourceBackfillStatus `json:"backfills,omitempty"`
+	// Retention is the state of the retention policy in spec.retention.
+	Retention *ReportDataSourceRetentionStatus `json:"retention,omitempty"`
+	// AWSBilling is the state of an AWSBilling ReportDataSource.
+	AWSBilling *AWSBillingDataSourceStatus `json:"awsBilling,omitempty"`
+	// AzureCostExport is the state of an AzureCostExport ReportDataSource.
+	AzureCostExport *AzureCostExportDataSourceStatus `json:"azureCostExport,omitempty"`
+	// GCPBillingExport is the state of a GCPBillingExport ReportDataSource.
+	GCPBillingExport *GCPBillingExportDataSourceStatus `json:"gcpBillingExport,omitempty"`
+	// FileDrop is the state of a FileDrop ReportDataSource.
+	FileDrop *FileDropDataSourceStatus `json:"fileDrop,omitempty"`
+	// KubernetesInventory is the state of a KubernetesInventory
+	// ReportDataSource.
+	KubernetesInventory *KubernetesInventoryDataSourceStatus `json:"kubernetesInventory,omitempty"`
+	// PushTable is the state of a PushTable ReportDataSource.
+	PushTable *PushTableDataSourceStatus `json:"pushTable,omitempty"`
+	// ExternalDatabase is the state of an ExternalDatabase
+	// ReportDataSource.
+	ExternalDatabase *ExternalDatabaseDataSourceStatus `json:"externalDatabase,omitempty"`
+	// PrometheusSnapshot is the state of a PrometheusMetricsImporter
+	// ReportDataSource in snapshot mode.
+	PrometheusSnapshot *PrometheusSnapshotDataSourceStatus `json:"prometheusSnapshot,omitempty"`
+	// PrometheusRemoteWrite is the state of a PrometheusMetricsImporter
+	// ReportDataSource receiving metrics by remote_write.
+	PrometheusRemoteWrite *PrometheusRemoteWriteDataSourceStatus `json:"prometheusRemoteWrite,omitempty"`
+}
+
+type PrometheusRemoteWriteDataSourceStatus struct {
+	// LastStoreError is why the metrics of a remote_write request last
+	// couldn't be stored in the table of the ReportDataSource.
+	LastStoreError string `json:"lastStoreError,omitempty"`
+	// LastStoreErrorTime is when storing metrics last failed.
+	LastStoreErrorTime *meta.Time `json:"lastStoreErrorTime,omitempty"`
+}
+
+type PrometheusSnapshotDataSourceStatus struct {
+	// RawTableRef is the HiveTable of the changes to each series. TableRef
+	// is a view of it with the interval each value of a series is valid.
+	RawTableRef v1.LocalObjectReference `json:"rawTableRef"`
+	// LastSnapshotTime is the time of the last snapshot stored.
+	LastSnapshotTime *meta.Time `json:"lastSnapshotTime,omitempty"`
+	// LastSnapshotSeries is the number of series in the last snapshot.
+	LastSnapshotSeries int `json:"lastSnapshotSeries"`
+	// LastSnapshotChanges is the number of rows stored by the last
+	// snapshot, one for each series added, changed or removed.
+	LastSnapshotChanges int `json:"lastSnapshotChanges"`
+}
+
+type ExternalDatabaseDataSourceStatus struct {
+	// Catalog is the Presto catalog of the database.
+	Catalog string `json:"catalog"`
+	// Connected is true if the table could be read through the catalog at
+	// LastCheckTime.
+	Connected bool `json:"connected"`
+	// ConnectionError is why the table couldn't be read.
+	ConnectionError string `json:"connectionError,omitempty"`
+	// SchemaErrors are the columns in spec.externalDatabase.columns the
+	// table doesn't have.
+	SchemaErrors []string `json:"schemaErrors,omitempty"`
+	// Columns are the columns of the table, as read by Presto.
+	Columns []presto.Column `json:"columns,omitempty"`
+	// LastCheckTime is the last time the table was checked.
+	LastCheckTime *meta.Time `json:"lastCheckTime,omitempty"`
+}
+
+type PushTableDataSourceStatus struct {
+	// RawTableRef is the HiveTable the pushed rows are stored in. TableRef
+	// is a view of it with a single row for each idempotency key.
+	RawTableRef v1.LocalObjectReference `json:"rawTableRef"`
+}
+
+type KubernetesInventoryDataSourceStatus struct {
+	// LastSnapshotTime is the time of the last snapshot stored.
+	LastSnapshotTime *meta.Time `json:"lastSnapshotTime,omitempty"`
+	// LastSnapshotObjects is the number of objects in the last snapshot.
+	LastSnapshotObjects int `json:"lastSnapshotObjects"`
+}
+
+type FileDropDataSourceStatus struct {
+	// RawTableRef is the external HiveTable of the files. TableRef is a
+	// view of it with the columns in spec.fileDrop.columns.
+	RawTableRef v1.LocalObjectReference `json:"rawTableRef"`
+	// LastDiscoveryTime is the last time the files were listed.
+	LastDiscoveryTime *meta.Time `json:"lastDiscoveryTime,omitempty"`
+	// DiscoveredFiles are the files in the partitions of the table.
+	DiscoveredFiles []FileDropFileStatus `json:"discoveredFiles,omitempty"`
+	// FailedFiles are the files which can't be read by the table. The
+	// partition of a directory containing a failed file isn't added, since
+	// Hive reads every file in it.
+	FailedFiles []FileDropFailedFileStatus `json:"failedFiles,omitempty"`
+}
+
+type FileDropFileStatus struct {
+	// Path is relative to the S3 prefix or HDFS directory.
+	Path         string    `json:"path"`
+	Size         int64     `json:"size"`
+	LastModified meta.Time `json:"lastModified"`
+}
+
+type FileDropFailedFileStatus struct {
+	// Path is relative to the S3 prefix or HDFS directory.
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type GCPBillingExportDataSourceStatus struct {
+	// Format is the format of the files the tables were created for.
+	Format GCPBillingExportFormat `json:"format,omitempty"`
+	// RawTableRef is the HiveTable of the exported files. TableRef is a view
+	// of it with the nested fields flattened into columns.
+	RawTableRef v1.LocalObjectReference `json:"rawTableRef"`
+	// InvoiceMonths are the invoice months with a partition.
+	InvoiceMonths []GCPBillingExportInvoiceMonthStatus `json:"invoiceMonths,omitempty"`
+}
+
+type GCPBillingExportInvoiceMonthStatus struct {
+	// Month is the invoice month, eg: 202401.
+	Month string `json:"month"`
+	// LastUpdated is the latest time a file of the month was updated.
+	LastUpdated meta.Time `json:"lastUpdated"`
+}
+
+type AzureCostExportDataSourceStatus struct {
+	// RawTableRef is the HiveTable of the exported CSV data, which has a
+	// string column for each column of the exports. TableRef is a view of
+	// it with typed columns.
+	RawTableRef v1.LocalObjectReference `json:"rawTableRef"`
+	// BillingPeriods is the export run the partition of each billing
+	// period currently points at.
+	BillingPeriods []AzureCostExportBillingPeriodStatus `json:"billingPeriods,omitempty"`
+}
+
+type AzureCostExportBillingPeriodStatus struct {
+	Start meta.Time `json:"start"`
+	End   meta.Time `json:"end"`
+	// RunID is the runId of the manifest of the export run the partition
+	// of the billing period points at.
+	RunID string `json:"runId"`
+}
+
+type AWSBillingDataSourceStatus struct {
+	// Format is the layout of the reports the table was created for, which
+	// determines the names and types of its columns.
+	Format AWSBillingFormat `json:"format,omitempty"`
+	// BillingPeriods is the report assembly the partition of each billing
+	// period currently points at.
+	BillingPeriods []AWSBillingPeriodStatus `json:"billingPeriods,omitempty"`
+	// Restatements is the history of billing periods whose reports were
+	// regenerated by AWS, newest last.
+	Restatements []AWSBillingRestatement `json:"restatements,omitempty"`
+}
+
+type AWSBillingPeriodStatus struct {
+	Start meta.Time `json:"start"`
+	End   meta.Time `json:"end"`
+	// AssemblyID is the assemblyId of the report manifest the partition of
+	// the billing period points at.
+	AssemblyID string `json:"assemblyId"`
+}
+
+// AWSBillingRestatement records AWS replacing the report of a billing period
+// with a new assembly.
+type AWSBillingRestatement struct {
+	BillingPeriodStart meta.Time `json:"billingPeriodStart"`
+	BillingPeriodEnd   meta.Time `json:"billingPeriodEnd"`
+	PreviousAssemblyID string    `json:"previousAssemblyId"`
+	AssemblyID         string    `json:"assemblyId"`
+	// DetectedTime is when the partition was switched to the new assembly.
+	DetectedTime meta.Time `json:"detectedTime"`
+}
+
+type ReportDataSourceRetentionStatus struct {
+	// OldestRetainedTime is the start of the oldest partition kept.
+	OldestRetainedTime *meta.Time `json:"oldestRetainedTime,omitempty"`
+	// LastEnforcementTime is when expired partitions were last dropped,
+	// and is used to limit how often the partitions are checked.
+	LastEnforcementTime *meta.Time `json:"lastEnforcementTime,omitempty"`
+	// RetainedForReports are the Reports whose unfinished reporting periods
+	// prevented expired partitions from being dropped.
+	RetainedForReports []string `json:"retainedForReports,omitempty"`
+}
+
+type ReportDataSourceBackfillPhase string
+
+const (
+	ReportDataSourceBackfillPending  ReportDataSourceBackfillPhase = "Pending"
+	ReportDataSourceBackfillRunning  ReportDataSourceBackfillPhase = "Running"
+	ReportDataSourceBackfillComplete ReportDataSourceBackfillPhase = "Complete"
+	ReportDataSourceBackfillFailed   ReportDataSourceBackfillPhase = "Failed"
+)
+
+type ReportDataSourceBackfillStatus struct {
+	Name  string                        `json:"name"`
+	Phase ReportDataSourceBackfillPhase `json:"phase"`
+	// Start and End are the time range being imported, which is extended
+	// to whole days if the backfill overwrites partitions.
+	Start *meta.Time `json:"start,omitempty"`
+	End   *meta.Time `json:"end,omitempty"`
+	// Overwrite is the overwrite setting the backfill was started with.
+	Overwrite bool `json:"overwrite,omitempty"`
+	// ImportedUntil is the time data has been imported up until, and where
+	// the backfill resumes from.
+	ImportedUntil *meta.Time `json:"importedUntil,omitempty"`
+	// MetricsImported is the number of metrics imported by the backfill,
+	// including metrics imported again after the operator restarted.
+	MetricsImported int64 `json:"metricsImported,omitempty"`
+	// Message is the reason a backfill failed, or the last error
+	// encountered by a running backfill.
+	Message        string     `json:"message,omitempty"`
+	StartTime      *meta.Time `json:"startTime,omitempty"`
+	CompletionTime *meta.Time `json:"completionTime,omitempty"`
+	// LastImportTime is when the backfill last imported data, and is used
+	// to limit how often it queries Prometheus.
+	LastImportTime *meta.Time `json:"lastImportTime,omitempty"`
+}
+
+type PrometheusEndpointStatus struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Healthy is false if the last query of the endpoint failed.
+	Healthy bool `json:"healthy"`
+	// LastError is the error of the last failed query of the endpoint.
+	LastError string `json:"lastError,omitempty"`
+	// LastTransitionTime is the last time Healthy changed.
+	LastTransitionTime *meta.Time `json:"lastTransitionTime,omitempty"`
 }
 
 type PrometheusMetricsImportStatus struct {
@@ -109,4 +798,33 @@ type PrometheusMetricsImportStatus struct {
 	// NewestImportedMetricTime is the timestamp for the newest metric
 	// imported for this ReportDataSource.
 	NewestImportedMetricTime *meta.Time `json:"newestImportedMetricTime,omitempty"`
+
+	// ImportedRanges are the time ranges which have been imported, merged
+	// into non-overlapping ranges sorted by time. The end of each range is
+	// exclusive.
+	ImportedRanges []PrometheusImportTimeRange `json:"importedRanges,omitempty"`
+	// Gaps are the time ranges found to be missing data by the last
+	// coverage check. Only the newest gaps older than the retention of
+	// Prometheus are kept.
+	Gaps []PrometheusImportGap `json:"gaps,omitempty"`
+	// LastCoverageCheckTime is the time the imported data was last checked
+	// for gaps.
+	LastCoverageCheckTime *meta.Time `json:"lastCoverageCheckTime,omitempty"`
+}
+
+type PrometheusImportTimeRange struct {
+	Start meta.Time `json:"start"`
+	End   meta.Time `json:"end"`
+}
+
+type PrometheusImportGap struct {
+	Start meta.Time `json:"start"`
+	End   meta.Time `json:"end"`
+	// Repairable is true if the gap will be re-imported, which it isn't once
+	// Prometheus no longer retains its data, or once it has been re-imported
+	// several times without any data being found.
+	Repairable bool `json:"repairable"`
+	// RepairAttempts is the number of times the gap has been re-imported
+	// without the missing data being found.
+	RepairAttempts int `json:"repairAttempts,omitempty"`
 }
@@ -44,6 +44,16 @@ const (
 	// GenerateReportFailedReason is set when a Report is not running because
 	// it previously failed when generating results previously.
 	GenerateReportFailedReason = "GenerateReportFailed"
+
+	// SchemaIncompatibleReason is set when a Report is not running because
+	// it's ReportQuery columns changed in a way that cannot be applied to the
+	// existing Report table, and it's spec.schemaChangePolicy is Fail.
+	SchemaIncompatibleReason = "SchemaIncompatible"
+
+	// DataSourceRestatedReason is set when AWS replaced the report of a
+	// billing period overlapping the generated results of the Report in an
+	// AWSBilling ReportDataSource the Report depends on.
+	DataSourceRestatedReason = "DataSourceRestated"
 )
 
 // NewReportCondition creates a new report condition.
@@ -15,6 +15,22 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSAssumeRole) DeepCopyInto(out *AWSAssumeRole) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSAssumeRole.
+func (in *AWSAssumeRole) DeepCopy() *AWSAssumeRole {
+	if in == nil {
+		return nil
+	}
+	out := new(AWSAssumeRole)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSBillingDataSource) DeepCopyInto(out *AWSBillingDataSource) {
 	*out = *in
@@ -23,6 +39,11 @@ func (in *AWSBillingDataSource) DeepCopyInto(out *AWSBillingDataSource) {
 		*out = new(S3Bucket)
 		**out = **in
 	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(AWSCredentials)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -37,49 +58,74 @@ func (in *AWSBillingDataSource) DeepCopy() *AWSBillingDataSource {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AWSConfig) DeepCopyInto(out *AWSConfig) {
+func (in *AWSBillingDataSourceStatus) DeepCopyInto(out *AWSBillingDataSourceStatus) {
 	*out = *in
-	if in.CreateSecret != nil {
-		in, out := &in.CreateSecret, &out.CreateSecret
-		*out = new(bool)
-		**out = **in
+	if in.BillingPeriods != nil {
+		in, out := &in.BillingPeriods, &out.BillingPeriods
+		*out = make([]AWSBillingPeriodStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Restatements != nil {
+		in, out := &in.Restatements, &out.Restatements
+		*out = make([]AWSBillingRestatement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfig.
-func (in *AWSConfig) DeepCopy() *AWSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSBillingDataSourceStatus.
+func (in *AWSBillingDataSourceStatus) DeepCopy() *AWSBillingDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(AWSConfig)
+	out := new(AWSBillingDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AzureConfig) DeepCopyInto(out *AzureConfig) {
+func (in *AWSBillingPeriodStatus) DeepCopyInto(out *AWSBillingPeriodStatus) {
 	*out = *in
-	if in.CreateSecret != nil {
-		in, out := &in.CreateSecret, &out.CreateSecret
-		*out = new(bool)
-		**out = **in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSBillingPeriodStatus.
+func (in *AWSBillingPeriodStatus) DeepCopy() *AWSBillingPeriodStatus {
+	if in == nil {
+		return nil
 	}
+	out := new(AWSBillingPeriodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AWSBillingRestatement) DeepCopyInto(out *AWSBillingRestatement) {
+	*out = *in
+	in.BillingPeriodStart.DeepCopyInto(&out.BillingPeriodStart)
+	in.BillingPeriodEnd.DeepCopyInto(&out.BillingPeriodEnd)
+	in.DetectedTime.DeepCopyInto(&out.DetectedTime)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureConfig.
-func (in *AzureConfig) DeepCopy() *AzureConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSBillingRestatement.
+func (in *AWSBillingRestatement) DeepCopy() *AWSBillingRestatement {
 	if in == nil {
 		return nil
 	}
-	out := new(AzureConfig)
+	out := new(AWSBillingRestatement)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GCSConfig) DeepCopyInto(out *GCSConfig) {
+func (in *AWSConfig) DeepCopyInto(out *AWSConfig) {
 	*out = *in
 	if in.CreateSecret != nil {
 		in, out := &in.CreateSecret, &out.CreateSecret
@@ -89,635 +135,1094 @@ func (in *GCSConfig) DeepCopyInto(out *GCSConfig) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSConfig.
-func (in *GCSConfig) DeepCopy() *GCSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSConfig.
+func (in *AWSConfig) DeepCopy() *AWSConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(GCSConfig)
+	out := new(AWSConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Ghostunnel) DeepCopyInto(out *Ghostunnel) {
+func (in *AWSCredentials) DeepCopyInto(out *AWSCredentials) {
 	*out = *in
-	if in.Image != nil {
-		in, out := &in.Image, &out.Image
-		*out = new(ImageConfig)
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
+	if in.WebIdentity != nil {
+		in, out := &in.WebIdentity, &out.WebIdentity
+		*out = new(AWSWebIdentity)
+		**out = **in
+	}
+	if in.AssumeRole != nil {
+		in, out := &in.AssumeRole, &out.AssumeRole
+		*out = new(AWSAssumeRole)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ghostunnel.
-func (in *Ghostunnel) DeepCopy() *Ghostunnel {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSCredentials.
+func (in *AWSCredentials) DeepCopy() *AWSCredentials {
 	if in == nil {
 		return nil
 	}
-	out := new(Ghostunnel)
+	out := new(AWSCredentials)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Hadoop) DeepCopyInto(out *Hadoop) {
+func (in *AWSWebIdentity) DeepCopyInto(out *AWSWebIdentity) {
 	*out = *in
-	if in.Spec != nil {
-		in, out := &in.Spec, &out.Spec
-		*out = new(HadoopSpec)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hadoop.
-func (in *Hadoop) DeepCopy() *Hadoop {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AWSWebIdentity.
+func (in *AWSWebIdentity) DeepCopy() *AWSWebIdentity {
 	if in == nil {
 		return nil
 	}
-	out := new(Hadoop)
+	out := new(AWSWebIdentity)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFS) DeepCopyInto(out *HadoopHDFS) {
+func (in *AzureBlobContainer) DeepCopyInto(out *AzureBlobContainer) {
 	*out = *in
-	if in.Enabled != nil {
-		in, out := &in.Enabled, &out.Enabled
-		*out = new(bool)
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
-	if in.SecurityContext != nil {
-		in, out := &in.SecurityContext, &out.SecurityContext
-		*out = new(corev1.SecurityContext)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HadoopHDFSConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Datanode != nil {
-		in, out := &in.Datanode, &out.Datanode
-		*out = new(HadoopHDFSDatanodeSpec)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Namenode != nil {
-		in, out := &in.Namenode, &out.Namenode
-		*out = new(HadoopHDFSNamenodeSpec)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFS.
-func (in *HadoopHDFS) DeepCopy() *HadoopHDFS {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureBlobContainer.
+func (in *AzureBlobContainer) DeepCopy() *AzureBlobContainer {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFS)
+	out := new(AzureBlobContainer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFSConfig) DeepCopyInto(out *HadoopHDFSConfig) {
+func (in *AzureConfig) DeepCopyInto(out *AzureConfig) {
 	*out = *in
-	if in.ReplicationFactor != nil {
-		in, out := &in.ReplicationFactor, &out.ReplicationFactor
-		*out = new(int32)
+	if in.CreateSecret != nil {
+		in, out := &in.CreateSecret, &out.CreateSecret
+		*out = new(bool)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSConfig.
-func (in *HadoopHDFSConfig) DeepCopy() *HadoopHDFSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureConfig.
+func (in *AzureConfig) DeepCopy() *AzureConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFSConfig)
+	out := new(AzureConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFSDatanodeSpec) DeepCopyInto(out *HadoopHDFSDatanodeSpec) {
+func (in *AzureCostExportBillingPeriodStatus) DeepCopyInto(out *AzureCostExportBillingPeriodStatus) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
-	}
-	if in.TerminationGracePeriodSeconds != nil {
-		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
-		*out = new(int64)
-		**out = **in
-	}
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HadoopHDFSNodeConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Storage != nil {
-		in, out := &in.Storage, &out.Storage
-		*out = new(HadoopHDFSStorageConfig)
-		**out = **in
-	}
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSDatanodeSpec.
-func (in *HadoopHDFSDatanodeSpec) DeepCopy() *HadoopHDFSDatanodeSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureCostExportBillingPeriodStatus.
+func (in *AzureCostExportBillingPeriodStatus) DeepCopy() *AzureCostExportBillingPeriodStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFSDatanodeSpec)
+	out := new(AzureCostExportBillingPeriodStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFSNamenodeSpec) DeepCopyInto(out *HadoopHDFSNamenodeSpec) {
+func (in *AzureCostExportDataSource) DeepCopyInto(out *AzureCostExportDataSource) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
-	}
-	if in.TerminationGracePeriodSeconds != nil {
-		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
-		*out = new(int64)
-		**out = **in
-	}
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HadoopHDFSNodeConfig)
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(AzureBlobContainer)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Storage != nil {
-		in, out := &in.Storage, &out.Storage
-		*out = new(HadoopHDFSStorageConfig)
-		**out = **in
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSNamenodeSpec.
-func (in *HadoopHDFSNamenodeSpec) DeepCopy() *HadoopHDFSNamenodeSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureCostExportDataSource.
+func (in *AzureCostExportDataSource) DeepCopy() *AzureCostExportDataSource {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFSNamenodeSpec)
+	out := new(AzureCostExportDataSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFSNodeConfig) DeepCopyInto(out *HadoopHDFSNodeConfig) {
+func (in *AzureCostExportDataSourceStatus) DeepCopyInto(out *AzureCostExportDataSourceStatus) {
 	*out = *in
-	if in.Jvm != nil {
-		in, out := &in.Jvm, &out.Jvm
-		*out = new(JVMConfig)
-		**out = **in
+	out.RawTableRef = in.RawTableRef
+	if in.BillingPeriods != nil {
+		in, out := &in.BillingPeriods, &out.BillingPeriods
+		*out = make([]AzureCostExportBillingPeriodStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSNodeConfig.
-func (in *HadoopHDFSNodeConfig) DeepCopy() *HadoopHDFSNodeConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureCostExportDataSourceStatus.
+func (in *AzureCostExportDataSourceStatus) DeepCopy() *AzureCostExportDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFSNodeConfig)
+	out := new(AzureCostExportDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopHDFSStorageConfig) DeepCopyInto(out *HadoopHDFSStorageConfig) {
+func (in *ExternalDatabaseDataSource) DeepCopyInto(out *ExternalDatabaseDataSource) {
 	*out = *in
-	return
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSStorageConfig.
-func (in *HadoopHDFSStorageConfig) DeepCopy() *HadoopHDFSStorageConfig {
+	out.CredentialsSecretRef = in.CredentialsSecretRef
+	if in.ConnectionProperties != nil {
+		in, out := &in.ConnectionProperties, &out.ConnectionProperties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]presto.Column, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalDatabaseDataSource.
+func (in *ExternalDatabaseDataSource) DeepCopy() *ExternalDatabaseDataSource {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopHDFSStorageConfig)
+	out := new(ExternalDatabaseDataSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopSpec) DeepCopyInto(out *HadoopSpec) {
+func (in *ExternalDatabaseDataSourceStatus) DeepCopyInto(out *ExternalDatabaseDataSourceStatus) {
 	*out = *in
-	if in.Image != nil {
-		in, out := &in.Image, &out.Image
-		*out = new(ImageConfig)
-		**out = **in
+	if in.SchemaErrors != nil {
+		in, out := &in.SchemaErrors, &out.SchemaErrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.HDFS != nil {
-		in, out := &in.HDFS, &out.HDFS
-		*out = new(HadoopHDFS)
-		(*in).DeepCopyInto(*out)
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]presto.Column, len(*in))
+		copy(*out, *in)
 	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HadoopSpecConfig)
-		(*in).DeepCopyInto(*out)
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopSpec.
-func (in *HadoopSpec) DeepCopy() *HadoopSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalDatabaseDataSourceStatus.
+func (in *ExternalDatabaseDataSourceStatus) DeepCopy() *ExternalDatabaseDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopSpec)
+	out := new(ExternalDatabaseDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HadoopSpecConfig) DeepCopyInto(out *HadoopSpecConfig) {
+func (in *FileDropCSVOptions) DeepCopyInto(out *FileDropCSVOptions) {
 	*out = *in
-	if in.AWS != nil {
-		in, out := &in.AWS, &out.AWS
-		*out = new(AWSConfig)
-		(*in).DeepCopyInto(*out)
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(bool)
+		**out = **in
 	}
-	if in.Azure != nil {
-		in, out := &in.Azure, &out.Azure
-		*out = new(AzureConfig)
-		(*in).DeepCopyInto(*out)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileDropCSVOptions.
+func (in *FileDropCSVOptions) DeepCopy() *FileDropCSVOptions {
+	if in == nil {
+		return nil
 	}
-	if in.Gcs != nil {
-		in, out := &in.Gcs, &out.Gcs
-		*out = new(GCSConfig)
+	out := new(FileDropCSVOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileDropDataSource) DeepCopyInto(out *FileDropDataSource) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3Bucket)
+		**out = **in
+	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(AWSCredentials)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.S3Compatible != nil {
-		in, out := &in.S3Compatible, &out.S3Compatible
-		*out = new(S3CompatibleConfig)
+	if in.HDFS != nil {
+		in, out := &in.HDFS, &out.HDFS
+		*out = new(HDFSDirectory)
+		**out = **in
+	}
+	if in.CSV != nil {
+		in, out := &in.CSV, &out.CSV
+		*out = new(FileDropCSVOptions)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]hive.Column, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopSpecConfig.
-func (in *HadoopSpecConfig) DeepCopy() *HadoopSpecConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileDropDataSource.
+func (in *FileDropDataSource) DeepCopy() *FileDropDataSource {
 	if in == nil {
 		return nil
 	}
-	out := new(HadoopSpecConfig)
+	out := new(FileDropDataSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Hive) DeepCopyInto(out *Hive) {
+func (in *FileDropDataSourceStatus) DeepCopyInto(out *FileDropDataSourceStatus) {
 	*out = *in
-	if in.Spec != nil {
-		in, out := &in.Spec, &out.Spec
-		*out = new(HiveSpec)
-		(*in).DeepCopyInto(*out)
+	out.RawTableRef = in.RawTableRef
+	if in.LastDiscoveryTime != nil {
+		in, out := &in.LastDiscoveryTime, &out.LastDiscoveryTime
+		*out = (*in).DeepCopy()
+	}
+	if in.DiscoveredFiles != nil {
+		in, out := &in.DiscoveredFiles, &out.DiscoveredFiles
+		*out = make([]FileDropFileStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FailedFiles != nil {
+		in, out := &in.FailedFiles, &out.FailedFiles
+		*out = make([]FileDropFailedFileStatus, len(*in))
+		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hive.
-func (in *Hive) DeepCopy() *Hive {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileDropDataSourceStatus.
+func (in *FileDropDataSourceStatus) DeepCopy() *FileDropDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Hive)
+	out := new(FileDropDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveColumn) DeepCopyInto(out *HiveColumn) {
+func (in *FileDropFailedFileStatus) DeepCopyInto(out *FileDropFailedFileStatus) {
 	*out = *in
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveColumn.
-func (in *HiveColumn) DeepCopy() *HiveColumn {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileDropFailedFileStatus.
+func (in *FileDropFailedFileStatus) DeepCopy() *FileDropFailedFileStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveColumn)
+	out := new(FileDropFailedFileStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveDBConfig) DeepCopyInto(out *HiveDBConfig) {
+func (in *FileDropFileStatus) DeepCopyInto(out *FileDropFileStatus) {
 	*out = *in
-	if in.AutoCreateMetastoreSchema != nil {
-		in, out := &in.AutoCreateMetastoreSchema, &out.AutoCreateMetastoreSchema
-		*out = new(bool)
-		**out = **in
-	}
-	if in.EnableMetastoreSchemaVerification != nil {
-		in, out := &in.EnableMetastoreSchemaVerification, &out.EnableMetastoreSchemaVerification
-		*out = new(bool)
-		**out = **in
-	}
+	in.LastModified.DeepCopyInto(&out.LastModified)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveDBConfig.
-func (in *HiveDBConfig) DeepCopy() *HiveDBConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileDropFileStatus.
+func (in *FileDropFileStatus) DeepCopy() *FileDropFileStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveDBConfig)
+	out := new(FileDropFileStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveHDFSConfig) DeepCopyInto(out *HiveHDFSConfig) {
+func (in *GCPBillingExportDataSource) DeepCopyInto(out *GCPBillingExportDataSource) {
 	*out = *in
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(GCSBucket)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveHDFSConfig.
-func (in *HiveHDFSConfig) DeepCopy() *HiveHDFSConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPBillingExportDataSource.
+func (in *GCPBillingExportDataSource) DeepCopy() *GCPBillingExportDataSource {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveHDFSConfig)
+	out := new(GCPBillingExportDataSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveMetastoreSpec) DeepCopyInto(out *HiveMetastoreSpec) {
+func (in *GCPBillingExportDataSourceStatus) DeepCopyInto(out *GCPBillingExportDataSourceStatus) {
 	*out = *in
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.LivenessProbe != nil {
-		in, out := &in.LivenessProbe, &out.LivenessProbe
-		*out = new(corev1.Probe)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.ReadinessProbe != nil {
-		in, out := &in.ReadinessProbe, &out.ReadinessProbe
-		*out = new(corev1.Probe)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
+	out.RawTableRef = in.RawTableRef
+	if in.InvoiceMonths != nil {
+		in, out := &in.InvoiceMonths, &out.InvoiceMonths
+		*out = make([]GCPBillingExportInvoiceMonthStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HiveMetastoreSpecConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Storage != nil {
-		in, out := &in.Storage, &out.Storage
-		*out = new(HiveMetastoreStorageConfig)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreSpec.
-func (in *HiveMetastoreSpec) DeepCopy() *HiveMetastoreSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPBillingExportDataSourceStatus.
+func (in *GCPBillingExportDataSourceStatus) DeepCopy() *GCPBillingExportDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveMetastoreSpec)
+	out := new(GCPBillingExportDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveMetastoreSpecConfig) DeepCopyInto(out *HiveMetastoreSpecConfig) {
+func (in *GCPBillingExportInvoiceMonthStatus) DeepCopyInto(out *GCPBillingExportInvoiceMonthStatus) {
 	*out = *in
-	if in.Jvm != nil {
-		in, out := &in.Jvm, &out.Jvm
-		*out = new(JVMConfig)
-		**out = **in
-	}
-	if in.TLS != nil {
-		in, out := &in.TLS, &out.TLS
-		*out = new(TLSConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Auth != nil {
-		in, out := &in.Auth, &out.Auth
-		*out = new(HiveResourceAuthConfig)
-		(*in).DeepCopyInto(*out)
-	}
+	in.LastUpdated.DeepCopyInto(&out.LastUpdated)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreSpecConfig.
-func (in *HiveMetastoreSpecConfig) DeepCopy() *HiveMetastoreSpecConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPBillingExportInvoiceMonthStatus.
+func (in *GCPBillingExportInvoiceMonthStatus) DeepCopy() *GCPBillingExportInvoiceMonthStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveMetastoreSpecConfig)
+	out := new(GCPBillingExportInvoiceMonthStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveMetastoreStorageConfig) DeepCopyInto(out *HiveMetastoreStorageConfig) {
+func (in *GCSBucket) DeepCopyInto(out *GCSBucket) {
 	*out = *in
-	if in.Create != nil {
-		in, out := &in.Create, &out.Create
-		*out = new(bool)
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.LocalObjectReference)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreStorageConfig.
-func (in *HiveMetastoreStorageConfig) DeepCopy() *HiveMetastoreStorageConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSBucket.
+func (in *GCSBucket) DeepCopy() *GCSBucket {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveMetastoreStorageConfig)
+	out := new(GCSBucket)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveResourceAuthConfig) DeepCopyInto(out *HiveResourceAuthConfig) {
+func (in *GCSConfig) DeepCopyInto(out *GCSConfig) {
 	*out = *in
-	if in.Enabled != nil {
-		in, out := &in.Enabled, &out.Enabled
+	if in.CreateSecret != nil {
+		in, out := &in.CreateSecret, &out.CreateSecret
 		*out = new(bool)
 		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveResourceAuthConfig.
-func (in *HiveResourceAuthConfig) DeepCopy() *HiveResourceAuthConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSConfig.
+func (in *GCSConfig) DeepCopy() *GCSConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveResourceAuthConfig)
+	out := new(GCSConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HiveServerSpec) DeepCopyInto(out *HiveServerSpec) {
+func (in *Ghostunnel) DeepCopyInto(out *Ghostunnel) {
 	*out = *in
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.LivenessProbe != nil {
-		in, out := &in.LivenessProbe, &out.LivenessProbe
-		*out = new(corev1.Probe)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.ReadinessProbe != nil {
-		in, out := &in.ReadinessProbe, &out.ReadinessProbe
-		*out = new(corev1.Probe)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(HiveServerSpecConfig)
-		(*in).DeepCopyInto(*out)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageConfig)
+		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveServerSpec.
-func (in *HiveServerSpec) DeepCopy() *HiveServerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ghostunnel.
+func (in *Ghostunnel) DeepCopy() *Ghostunnel {
 	if in == nil {
 		return nil
 	}
-	out := new(HiveServerSpec)
+	out := new(Ghostunnel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HDFSDirectory) DeepCopyInto(out *HDFSDirectory) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HDFSDirectory.
+func (in *HDFSDirectory) DeepCopy() *HDFSDirectory {
+	if in == nil {
+		return nil
+	}
+	out := new(HDFSDirectory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hadoop) DeepCopyInto(out *Hadoop) {
+	*out = *in
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(HadoopSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hadoop.
+func (in *Hadoop) DeepCopy() *Hadoop {
+	if in == nil {
+		return nil
+	}
+	out := new(Hadoop)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFS) DeepCopyInto(out *HadoopHDFS) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HadoopHDFSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Datanode != nil {
+		in, out := &in.Datanode, &out.Datanode
+		*out = new(HadoopHDFSDatanodeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Namenode != nil {
+		in, out := &in.Namenode, &out.Namenode
+		*out = new(HadoopHDFSNamenodeSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFS.
+func (in *HadoopHDFS) DeepCopy() *HadoopHDFS {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFSConfig) DeepCopyInto(out *HadoopHDFSConfig) {
+	*out = *in
+	if in.ReplicationFactor != nil {
+		in, out := &in.ReplicationFactor, &out.ReplicationFactor
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSConfig.
+func (in *HadoopHDFSConfig) DeepCopy() *HadoopHDFSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFSDatanodeSpec) DeepCopyInto(out *HadoopHDFSDatanodeSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HadoopHDFSNodeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(HadoopHDFSStorageConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSDatanodeSpec.
+func (in *HadoopHDFSDatanodeSpec) DeepCopy() *HadoopHDFSDatanodeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFSDatanodeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFSNamenodeSpec) DeepCopyInto(out *HadoopHDFSNamenodeSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HadoopHDFSNodeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(HadoopHDFSStorageConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSNamenodeSpec.
+func (in *HadoopHDFSNamenodeSpec) DeepCopy() *HadoopHDFSNamenodeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFSNamenodeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFSNodeConfig) DeepCopyInto(out *HadoopHDFSNodeConfig) {
+	*out = *in
+	if in.Jvm != nil {
+		in, out := &in.Jvm, &out.Jvm
+		*out = new(JVMConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSNodeConfig.
+func (in *HadoopHDFSNodeConfig) DeepCopy() *HadoopHDFSNodeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFSNodeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopHDFSStorageConfig) DeepCopyInto(out *HadoopHDFSStorageConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopHDFSStorageConfig.
+func (in *HadoopHDFSStorageConfig) DeepCopy() *HadoopHDFSStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopHDFSStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopSpec) DeepCopyInto(out *HadoopSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageConfig)
+		**out = **in
+	}
+	if in.HDFS != nil {
+		in, out := &in.HDFS, &out.HDFS
+		*out = new(HadoopHDFS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HadoopSpecConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopSpec.
+func (in *HadoopSpec) DeepCopy() *HadoopSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HadoopSpecConfig) DeepCopyInto(out *HadoopSpecConfig) {
+	*out = *in
+	if in.AWS != nil {
+		in, out := &in.AWS, &out.AWS
+		*out = new(AWSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Azure != nil {
+		in, out := &in.Azure, &out.Azure
+		*out = new(AzureConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Gcs != nil {
+		in, out := &in.Gcs, &out.Gcs
+		*out = new(GCSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.S3Compatible != nil {
+		in, out := &in.S3Compatible, &out.S3Compatible
+		*out = new(S3CompatibleConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HadoopSpecConfig.
+func (in *HadoopSpecConfig) DeepCopy() *HadoopSpecConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HadoopSpecConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Hive) DeepCopyInto(out *Hive) {
+	*out = *in
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(HiveSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Hive.
+func (in *Hive) DeepCopy() *Hive {
+	if in == nil {
+		return nil
+	}
+	out := new(Hive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveColumn) DeepCopyInto(out *HiveColumn) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveColumn.
+func (in *HiveColumn) DeepCopy() *HiveColumn {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveColumn)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveDBConfig) DeepCopyInto(out *HiveDBConfig) {
+	*out = *in
+	if in.AutoCreateMetastoreSchema != nil {
+		in, out := &in.AutoCreateMetastoreSchema, &out.AutoCreateMetastoreSchema
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableMetastoreSchemaVerification != nil {
+		in, out := &in.EnableMetastoreSchemaVerification, &out.EnableMetastoreSchemaVerification
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveDBConfig.
+func (in *HiveDBConfig) DeepCopy() *HiveDBConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveDBConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveHDFSConfig) DeepCopyInto(out *HiveHDFSConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveHDFSConfig.
+func (in *HiveHDFSConfig) DeepCopy() *HiveHDFSConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveHDFSConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveMetastoreSpec) DeepCopyInto(out *HiveMetastoreSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HiveMetastoreSpecConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(HiveMetastoreStorageConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreSpec.
+func (in *HiveMetastoreSpec) DeepCopy() *HiveMetastoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveMetastoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveMetastoreSpecConfig) DeepCopyInto(out *HiveMetastoreSpecConfig) {
+	*out = *in
+	if in.Jvm != nil {
+		in, out := &in.Jvm, &out.Jvm
+		*out = new(JVMConfig)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(HiveResourceAuthConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreSpecConfig.
+func (in *HiveMetastoreSpecConfig) DeepCopy() *HiveMetastoreSpecConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveMetastoreSpecConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveMetastoreStorageConfig) DeepCopyInto(out *HiveMetastoreStorageConfig) {
+	*out = *in
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveMetastoreStorageConfig.
+func (in *HiveMetastoreStorageConfig) DeepCopy() *HiveMetastoreStorageConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveMetastoreStorageConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveResourceAuthConfig) DeepCopyInto(out *HiveResourceAuthConfig) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveResourceAuthConfig.
+func (in *HiveResourceAuthConfig) DeepCopy() *HiveResourceAuthConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveResourceAuthConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HiveServerSpec) DeepCopyInto(out *HiveServerSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessProbe != nil {
+		in, out := &in.ReadinessProbe, &out.ReadinessProbe
+		*out = new(corev1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(HiveServerSpecConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HiveServerSpec.
+func (in *HiveServerSpec) DeepCopy() *HiveServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HiveServerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1218,6 +1723,83 @@ func (in *JVMConfig) DeepCopy() *JVMConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesInventoryColumn) DeepCopyInto(out *KubernetesInventoryColumn) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesInventoryColumn.
+func (in *KubernetesInventoryColumn) DeepCopy() *KubernetesInventoryColumn {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesInventoryColumn)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesInventoryDataSource) DeepCopyInto(out *KubernetesInventoryDataSource) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotInterval != nil {
+		in, out := &in.SnapshotInterval, &out.SnapshotInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]KubernetesInventoryColumn, len(*in))
+		copy(*out, *in)
+	}
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(StorageLocationRef)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesInventoryDataSource.
+func (in *KubernetesInventoryDataSource) DeepCopy() *KubernetesInventoryDataSource {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesInventoryDataSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesInventoryDataSourceStatus) DeepCopyInto(out *KubernetesInventoryDataSourceStatus) {
+	*out = *in
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesInventoryDataSourceStatus.
+func (in *KubernetesInventoryDataSourceStatus) DeepCopy() *KubernetesInventoryDataSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesInventoryDataSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LinkExistingTableDataSource) DeepCopyInto(out *LinkExistingTableDataSource) {
 	*out = *in
@@ -1620,6 +2202,11 @@ func (in *OpenshiftReportingConfigSpec) DeepCopyInto(out *OpenshiftReportingConf
 		*out = new(OpenshiftReportingAWSBillingReportDataSourceConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OpenshiftReportingGCPBillingReportDataSource != nil {
+		in, out := &in.OpenshiftReportingGCPBillingReportDataSource, &out.OpenshiftReportingGCPBillingReportDataSource
+		*out = new(OpenshiftReportingGCPBillingReportDataSourceConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.OpenshiftReportingDefaultReportDataSources != nil {
 		in, out := &in.OpenshiftReportingDefaultReportDataSources, &out.OpenshiftReportingDefaultReportDataSources
 		*out = new(OpenshiftReportingDefaultReportDataSourcesConfig)
@@ -1723,6 +2310,27 @@ func (in *OpenshiftReportingDefaultStorageLocationConfig) DeepCopy() *OpenshiftR
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenshiftReportingGCPBillingReportDataSourceConfig) DeepCopyInto(out *OpenshiftReportingGCPBillingReportDataSourceConfig) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenshiftReportingGCPBillingReportDataSourceConfig.
+func (in *OpenshiftReportingGCPBillingReportDataSourceConfig) DeepCopy() *OpenshiftReportingGCPBillingReportDataSourceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenshiftReportingGCPBillingReportDataSourceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenshiftReportingHiveStorageLocation) DeepCopyInto(out *OpenshiftReportingHiveStorageLocation) {
 	*out = *in
@@ -2051,14 +2659,276 @@ func (in *PrestoConnectorPrometheusConfigConfig) DeepCopy() *PrestoConnectorProm
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoConnectorPrometheusConfigConfig)
+	out := new(PrestoConnectorPrometheusConfigConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoCoordinatorSpec) DeepCopyInto(out *PrestoCoordinatorSpec) {
+	*out = *in
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(PrestoServerConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoCoordinatorSpec.
+func (in *PrestoCoordinatorSpec) DeepCopy() *PrestoCoordinatorSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoCoordinatorSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoServerConfig) DeepCopyInto(out *PrestoServerConfig) {
+	*out = *in
+	if in.Jvm != nil {
+		in, out := &in.Jvm, &out.Jvm
+		*out = new(JVMConfig)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoServerConfig.
+func (in *PrestoServerConfig) DeepCopy() *PrestoServerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoServerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoSpec) DeepCopyInto(out *PrestoSpec) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageConfig)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(PrestoConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Coordinator != nil {
+		in, out := &in.Coordinator, &out.Coordinator
+		*out = new(PrestoCoordinatorSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Worker != nil {
+		in, out := &in.Worker, &out.Worker
+		*out = new(PrestoWorkerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoSpec.
+func (in *PrestoSpec) DeepCopy() *PrestoSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoTable) DeepCopyInto(out *PrestoTable) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTable.
+func (in *PrestoTable) DeepCopy() *PrestoTable {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoTable)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PrestoTable) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoTableDataSource) DeepCopyInto(out *PrestoTableDataSource) {
+	*out = *in
+	out.TableRef = in.TableRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableDataSource.
+func (in *PrestoTableDataSource) DeepCopy() *PrestoTableDataSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoTableDataSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoTableList) DeepCopyInto(out *PrestoTableList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]*PrestoTable, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(PrestoTable)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableList.
+func (in *PrestoTableList) DeepCopy() *PrestoTableList {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoTableList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PrestoTableList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoTableSpec) DeepCopyInto(out *PrestoTableSpec) {
+	*out = *in
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]presto.Column, len(*in))
+		copy(*out, *in)
+	}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableSpec.
+func (in *PrestoTableSpec) DeepCopy() *PrestoTableSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoTableSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrestoTableStatus) DeepCopyInto(out *PrestoTableStatus) {
+	*out = *in
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]presto.Column, len(*in))
+		copy(*out, *in)
+	}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableStatus.
+func (in *PrestoTableStatus) DeepCopy() *PrestoTableStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PrestoTableStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoCoordinatorSpec) DeepCopyInto(out *PrestoCoordinatorSpec) {
+func (in *PrestoWorkerSpec) DeepCopyInto(out *PrestoWorkerSpec) {
 	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
 	if in.TerminationGracePeriodSeconds != nil {
 		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
 		*out = new(int64)
@@ -2096,388 +2966,439 @@ func (in *PrestoCoordinatorSpec) DeepCopyInto(out *PrestoCoordinatorSpec) {
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoCoordinatorSpec.
-func (in *PrestoCoordinatorSpec) DeepCopy() *PrestoCoordinatorSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoWorkerSpec.
+func (in *PrestoWorkerSpec) DeepCopy() *PrestoWorkerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoCoordinatorSpec)
+	out := new(PrestoWorkerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoServerConfig) DeepCopyInto(out *PrestoServerConfig) {
+func (in *PrometheusConnectionConfig) DeepCopyInto(out *PrometheusConnectionConfig) {
 	*out = *in
-	if in.Jvm != nil {
-		in, out := &in.Jvm, &out.Jvm
-		*out = new(JVMConfig)
-		**out = **in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]PrometheusEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReplicaLabels != nil {
+		in, out := &in.ReplicaLabels, &out.ReplicaLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoServerConfig.
-func (in *PrestoServerConfig) DeepCopy() *PrestoServerConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConnectionConfig.
+func (in *PrometheusConnectionConfig) DeepCopy() *PrometheusConnectionConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoServerConfig)
+	out := new(PrometheusConnectionConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoSpec) DeepCopyInto(out *PrestoSpec) {
+func (in *PrometheusEndpoint) DeepCopyInto(out *PrometheusEndpoint) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.SecurityContext != nil {
-		in, out := &in.SecurityContext, &out.SecurityContext
-		*out = new(corev1.SecurityContext)
+	if in.BearerToken != nil {
+		in, out := &in.BearerToken, &out.BearerToken
+		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Image != nil {
-		in, out := &in.Image, &out.Image
-		*out = new(ImageConfig)
-		**out = **in
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(PrestoConfig)
+	if in.CertificateAuthority != nil {
+		in, out := &in.CertificateAuthority, &out.CertificateAuthority
+		*out = new(corev1.SecretKeySelector)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Coordinator != nil {
-		in, out := &in.Coordinator, &out.Coordinator
-		*out = new(PrestoCoordinatorSpec)
-		(*in).DeepCopyInto(*out)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusEndpoint.
+func (in *PrometheusEndpoint) DeepCopy() *PrometheusEndpoint {
+	if in == nil {
+		return nil
 	}
-	if in.Worker != nil {
-		in, out := &in.Worker, &out.Worker
-		*out = new(PrestoWorkerSpec)
-		(*in).DeepCopyInto(*out)
+	out := new(PrometheusEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusEndpointStatus) DeepCopyInto(out *PrometheusEndpointStatus) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoSpec.
-func (in *PrestoSpec) DeepCopy() *PrestoSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusEndpointStatus.
+func (in *PrometheusEndpointStatus) DeepCopy() *PrometheusEndpointStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoSpec)
+	out := new(PrometheusEndpointStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoTable) DeepCopyInto(out *PrestoTable) {
+func (in *PrometheusImportGap) DeepCopyInto(out *PrometheusImportGap) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTable.
-func (in *PrestoTable) DeepCopy() *PrestoTable {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusImportGap.
+func (in *PrometheusImportGap) DeepCopy() *PrometheusImportGap {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoTable)
+	out := new(PrometheusImportGap)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PrestoTable) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusImportTimeRange) DeepCopyInto(out *PrometheusImportTimeRange) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusImportTimeRange.
+func (in *PrometheusImportTimeRange) DeepCopy() *PrometheusImportTimeRange {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(PrometheusImportTimeRange)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoTableDataSource) DeepCopyInto(out *PrestoTableDataSource) {
+func (in *PrometheusLabelMatcher) DeepCopyInto(out *PrometheusLabelMatcher) {
 	*out = *in
-	out.TableRef = in.TableRef
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableDataSource.
-func (in *PrestoTableDataSource) DeepCopy() *PrestoTableDataSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusLabelMatcher.
+func (in *PrometheusLabelMatcher) DeepCopy() *PrometheusLabelMatcher {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoTableDataSource)
+	out := new(PrometheusLabelMatcher)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoTableList) DeepCopyInto(out *PrestoTableList) {
+func (in *PrometheusMetricsImportStatus) DeepCopyInto(out *PrometheusMetricsImportStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]*PrestoTable, len(*in))
+	if in.LastImportTime != nil {
+		in, out := &in.LastImportTime, &out.LastImportTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ImportDataStartTime != nil {
+		in, out := &in.ImportDataStartTime, &out.ImportDataStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ImportDataEndTime != nil {
+		in, out := &in.ImportDataEndTime, &out.ImportDataEndTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EarliestImportedMetricTime != nil {
+		in, out := &in.EarliestImportedMetricTime, &out.EarliestImportedMetricTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NewestImportedMetricTime != nil {
+		in, out := &in.NewestImportedMetricTime, &out.NewestImportedMetricTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ImportedRanges != nil {
+		in, out := &in.ImportedRanges, &out.ImportedRanges
+		*out = make([]PrometheusImportTimeRange, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(PrestoTable)
-				(*in).DeepCopyInto(*out)
-			}
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Gaps != nil {
+		in, out := &in.Gaps, &out.Gaps
+		*out = make([]PrometheusImportGap, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastCoverageCheckTime != nil {
+		in, out := &in.LastCoverageCheckTime, &out.LastCoverageCheckTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableList.
-func (in *PrestoTableList) DeepCopy() *PrestoTableList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusMetricsImportStatus.
+func (in *PrometheusMetricsImportStatus) DeepCopy() *PrometheusMetricsImportStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoTableList)
+	out := new(PrometheusMetricsImportStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *PrestoTableList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusMetricsImporterDataSource) DeepCopyInto(out *PrometheusMetricsImporterDataSource) {
+	*out = *in
+	if in.QueryConfig != nil {
+		in, out := &in.QueryConfig, &out.QueryConfig
+		*out = new(PrometheusQueryConfig)
+		(*in).DeepCopyInto(*out)
 	}
-	return nil
+	if in.Storage != nil {
+		in, out := &in.Storage, &out.Storage
+		*out = new(StorageLocationRef)
+		**out = **in
+	}
+	if in.PrometheusConfig != nil {
+		in, out := &in.PrometheusConfig, &out.PrometheusConfig
+		*out = new(PrometheusConnectionConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemoteWrite != nil {
+		in, out := &in.RemoteWrite, &out.RemoteWrite
+		*out = new(PrometheusRemoteWriteConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemoteRead != nil {
+		in, out := &in.RemoteRead, &out.RemoteRead
+		*out = new(PrometheusRemoteReadConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RelabelConfigs != nil {
+		in, out := &in.RelabelConfigs, &out.RelabelConfigs
+		*out = make([]PrometheusRelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusMetricsImporterDataSource.
+func (in *PrometheusMetricsImporterDataSource) DeepCopy() *PrometheusMetricsImporterDataSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusMetricsImporterDataSource)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoTableSpec) DeepCopyInto(out *PrestoTableSpec) {
+func (in *PrometheusQueryConfig) DeepCopyInto(out *PrometheusQueryConfig) {
 	*out = *in
-	if in.Columns != nil {
-		in, out := &in.Columns, &out.Columns
-		*out = make([]presto.Column, len(*in))
-		copy(*out, *in)
+	if in.QueryInterval != nil {
+		in, out := &in.QueryInterval, &out.QueryInterval
+		*out = new(metav1.Duration)
+		**out = **in
 	}
-	if in.Properties != nil {
-		in, out := &in.Properties, &out.Properties
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.StepSize != nil {
+		in, out := &in.StepSize, &out.StepSize
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ChunkSize != nil {
+		in, out := &in.ChunkSize, &out.ChunkSize
+		*out = new(metav1.Duration)
+		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableSpec.
-func (in *PrestoTableSpec) DeepCopy() *PrestoTableSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusQueryConfig.
+func (in *PrometheusQueryConfig) DeepCopy() *PrometheusQueryConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoTableSpec)
+	out := new(PrometheusQueryConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoTableStatus) DeepCopyInto(out *PrestoTableStatus) {
+func (in *PrometheusRelabelConfig) DeepCopyInto(out *PrometheusRelabelConfig) {
 	*out = *in
-	if in.Columns != nil {
-		in, out := &in.Columns, &out.Columns
-		*out = make([]presto.Column, len(*in))
+	if in.SourceLabels != nil {
+		in, out := &in.SourceLabels, &out.SourceLabels
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Properties != nil {
-		in, out := &in.Properties, &out.Properties
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Separator != nil {
+		in, out := &in.Separator, &out.Separator
+		*out = new(string)
+		**out = **in
+	}
+	if in.Regex != nil {
+		in, out := &in.Regex, &out.Regex
+		*out = new(string)
+		**out = **in
+	}
+	if in.Replacement != nil {
+		in, out := &in.Replacement, &out.Replacement
+		*out = new(string)
+		**out = **in
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoTableStatus.
-func (in *PrestoTableStatus) DeepCopy() *PrestoTableStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRelabelConfig.
+func (in *PrometheusRelabelConfig) DeepCopy() *PrometheusRelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusRelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusRemoteReadConfig) DeepCopyInto(out *PrometheusRemoteReadConfig) {
+	*out = *in
+	if in.Matchers != nil {
+		in, out := &in.Matchers, &out.Matchers
+		*out = make([]PrometheusLabelMatcher, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRemoteReadConfig.
+func (in *PrometheusRemoteReadConfig) DeepCopy() *PrometheusRemoteReadConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoTableStatus)
+	out := new(PrometheusRemoteReadConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrestoWorkerSpec) DeepCopyInto(out *PrestoWorkerSpec) {
+func (in *PrometheusRemoteWriteConfig) DeepCopyInto(out *PrometheusRemoteWriteConfig) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
+	if in.Matchers != nil {
+		in, out := &in.Matchers, &out.Matchers
+		*out = make([]PrometheusLabelMatcher, len(*in))
+		copy(*out, *in)
 	}
-	if in.TerminationGracePeriodSeconds != nil {
-		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
-		*out = new(int64)
+	if in.StepSize != nil {
+		in, out := &in.StepSize, &out.StepSize
+		*out = new(metav1.Duration)
 		**out = **in
 	}
-	if in.NodeSelector != nil {
-		in, out := &in.NodeSelector, &out.NodeSelector
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	if in.Config != nil {
-		in, out := &in.Config, &out.Config
-		*out = new(PrestoServerConfig)
-		(*in).DeepCopyInto(*out)
-	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrestoWorkerSpec.
-func (in *PrestoWorkerSpec) DeepCopy() *PrestoWorkerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRemoteWriteConfig.
+func (in *PrometheusRemoteWriteConfig) DeepCopy() *PrometheusRemoteWriteConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(PrestoWorkerSpec)
+	out := new(PrometheusRemoteWriteConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusConnectionConfig) DeepCopyInto(out *PrometheusConnectionConfig) {
+func (in *PrometheusRemoteWriteDataSourceStatus) DeepCopyInto(out *PrometheusRemoteWriteDataSourceStatus) {
 	*out = *in
+	if in.LastStoreErrorTime != nil {
+		in, out := &in.LastStoreErrorTime, &out.LastStoreErrorTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusConnectionConfig.
-func (in *PrometheusConnectionConfig) DeepCopy() *PrometheusConnectionConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusRemoteWriteDataSourceStatus.
+func (in *PrometheusRemoteWriteDataSourceStatus) DeepCopy() *PrometheusRemoteWriteDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusConnectionConfig)
+	out := new(PrometheusRemoteWriteDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusMetricsImportStatus) DeepCopyInto(out *PrometheusMetricsImportStatus) {
+func (in *PrometheusSnapshotDataSourceStatus) DeepCopyInto(out *PrometheusSnapshotDataSourceStatus) {
 	*out = *in
-	if in.LastImportTime != nil {
-		in, out := &in.LastImportTime, &out.LastImportTime
-		*out = (*in).DeepCopy()
-	}
-	if in.ImportDataStartTime != nil {
-		in, out := &in.ImportDataStartTime, &out.ImportDataStartTime
-		*out = (*in).DeepCopy()
-	}
-	if in.ImportDataEndTime != nil {
-		in, out := &in.ImportDataEndTime, &out.ImportDataEndTime
-		*out = (*in).DeepCopy()
-	}
-	if in.EarliestImportedMetricTime != nil {
-		in, out := &in.EarliestImportedMetricTime, &out.EarliestImportedMetricTime
-		*out = (*in).DeepCopy()
-	}
-	if in.NewestImportedMetricTime != nil {
-		in, out := &in.NewestImportedMetricTime, &out.NewestImportedMetricTime
+	out.RawTableRef = in.RawTableRef
+	if in.LastSnapshotTime != nil {
+		in, out := &in.LastSnapshotTime, &out.LastSnapshotTime
 		*out = (*in).DeepCopy()
 	}
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusMetricsImportStatus.
-func (in *PrometheusMetricsImportStatus) DeepCopy() *PrometheusMetricsImportStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusSnapshotDataSourceStatus.
+func (in *PrometheusSnapshotDataSourceStatus) DeepCopy() *PrometheusSnapshotDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusMetricsImportStatus)
+	out := new(PrometheusSnapshotDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusMetricsImporterDataSource) DeepCopyInto(out *PrometheusMetricsImporterDataSource) {
+func (in *PushTableDataSource) DeepCopyInto(out *PushTableDataSource) {
 	*out = *in
-	if in.QueryConfig != nil {
-		in, out := &in.QueryConfig, &out.QueryConfig
-		*out = new(PrometheusQueryConfig)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Storage != nil {
-		in, out := &in.Storage, &out.Storage
-		*out = new(StorageLocationRef)
-		**out = **in
+	if in.Columns != nil {
+		in, out := &in.Columns, &out.Columns
+		*out = make([]hive.Column, len(*in))
+		copy(*out, *in)
 	}
-	if in.PrometheusConfig != nil {
-		in, out := &in.PrometheusConfig, &out.PrometheusConfig
-		*out = new(PrometheusConnectionConfig)
-		**out = **in
+	if in.KeyColumns != nil {
+		in, out := &in.KeyColumns, &out.KeyColumns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
+	out.TokenSecretRef = in.TokenSecretRef
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusMetricsImporterDataSource.
-func (in *PrometheusMetricsImporterDataSource) DeepCopy() *PrometheusMetricsImporterDataSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PushTableDataSource.
+func (in *PushTableDataSource) DeepCopy() *PushTableDataSource {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusMetricsImporterDataSource)
+	out := new(PushTableDataSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PrometheusQueryConfig) DeepCopyInto(out *PrometheusQueryConfig) {
+func (in *PushTableDataSourceStatus) DeepCopyInto(out *PushTableDataSourceStatus) {
 	*out = *in
-	if in.QueryInterval != nil {
-		in, out := &in.QueryInterval, &out.QueryInterval
-		*out = new(metav1.Duration)
-		**out = **in
-	}
-	if in.StepSize != nil {
-		in, out := &in.StepSize, &out.StepSize
-		*out = new(metav1.Duration)
-		**out = **in
-	}
-	if in.ChunkSize != nil {
-		in, out := &in.ChunkSize, &out.ChunkSize
-		*out = new(metav1.Duration)
-		**out = **in
-	}
+	out.RawTableRef = in.RawTableRef
 	return
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusQueryConfig.
-func (in *PrometheusQueryConfig) DeepCopy() *PrometheusQueryConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PushTableDataSourceStatus.
+func (in *PushTableDataSourceStatus) DeepCopy() *PushTableDataSourceStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PrometheusQueryConfig)
+	out := new(PushTableDataSourceStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -2556,6 +3477,64 @@ func (in *ReportDataSource) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportDataSourceBackfill) DeepCopyInto(out *ReportDataSourceBackfill) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportDataSourceBackfill.
+func (in *ReportDataSourceBackfill) DeepCopy() *ReportDataSourceBackfill {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportDataSourceBackfill)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportDataSourceBackfillStatus) DeepCopyInto(out *ReportDataSourceBackfillStatus) {
+	*out = *in
+	if in.Start != nil {
+		in, out := &in.Start, &out.Start
+		*out = (*in).DeepCopy()
+	}
+	if in.End != nil {
+		in, out := &in.End, &out.End
+		*out = (*in).DeepCopy()
+	}
+	if in.ImportedUntil != nil {
+		in, out := &in.ImportedUntil, &out.ImportedUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastImportTime != nil {
+		in, out := &in.LastImportTime, &out.LastImportTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportDataSourceBackfillStatus.
+func (in *ReportDataSourceBackfillStatus) DeepCopy() *ReportDataSourceBackfillStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportDataSourceBackfillStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReportDataSourceList) DeepCopyInto(out *ReportDataSourceList) {
 	*out = *in
@@ -2593,6 +3572,61 @@ func (in *ReportDataSourceList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportDataSourceRetention) DeepCopyInto(out *ReportDataSourceRetention) {
+	*out = *in
+	if in.Period != nil {
+		in, out := &in.Period, &out.Period
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = new(int64)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportDataSourceRetention.
+func (in *ReportDataSourceRetention) DeepCopy() *ReportDataSourceRetention {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportDataSourceRetention)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportDataSourceRetentionStatus) DeepCopyInto(out *ReportDataSourceRetentionStatus) {
+	*out = *in
+	if in.OldestRetainedTime != nil {
+		in, out := &in.OldestRetainedTime, &out.OldestRetainedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastEnforcementTime != nil {
+		in, out := &in.LastEnforcementTime, &out.LastEnforcementTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RetainedForReports != nil {
+		in, out := &in.RetainedForReports, &out.RetainedForReports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportDataSourceRetentionStatus.
+func (in *ReportDataSourceRetentionStatus) DeepCopy() *ReportDataSourceRetentionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportDataSourceRetentionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReportDataSourceSpec) DeepCopyInto(out *ReportDataSourceSpec) {
 	*out = *in
@@ -2606,6 +3640,36 @@ func (in *ReportDataSourceSpec) DeepCopyInto(out *ReportDataSourceSpec) {
 		*out = new(AWSBillingDataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AzureCostExport != nil {
+		in, out := &in.AzureCostExport, &out.AzureCostExport
+		*out = new(AzureCostExportDataSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GCPBillingExport != nil {
+		in, out := &in.GCPBillingExport, &out.GCPBillingExport
+		*out = new(GCPBillingExportDataSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FileDrop != nil {
+		in, out := &in.FileDrop, &out.FileDrop
+		*out = new(FileDropDataSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubernetesInventory != nil {
+		in, out := &in.KubernetesInventory, &out.KubernetesInventory
+		*out = new(KubernetesInventoryDataSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PushTable != nil {
+		in, out := &in.PushTable, &out.PushTable
+		*out = new(PushTableDataSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalDatabase != nil {
+		in, out := &in.ExternalDatabase, &out.ExternalDatabase
+		*out = new(ExternalDatabaseDataSource)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.PrestoTable != nil {
 		in, out := &in.PrestoTable, &out.PrestoTable
 		*out = new(PrestoTableDataSource)
@@ -2621,6 +3685,18 @@ func (in *ReportDataSourceSpec) DeepCopyInto(out *ReportDataSourceSpec) {
 		*out = new(ReportQueryViewDataSource)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Backfill != nil {
+		in, out := &in.Backfill, &out.Backfill
+		*out = make([]ReportDataSourceBackfill, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(ReportDataSourceRetention)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -2643,6 +3719,70 @@ func (in *ReportDataSourceStatus) DeepCopyInto(out *ReportDataSourceStatus) {
 		*out = new(PrometheusMetricsImportStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PrometheusEndpoints != nil {
+		in, out := &in.PrometheusEndpoints, &out.PrometheusEndpoints
+		*out = make([]PrometheusEndpointStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Backfills != nil {
+		in, out := &in.Backfills, &out.Backfills
+		*out = make([]ReportDataSourceBackfillStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Retention != nil {
+		in, out := &in.Retention, &out.Retention
+		*out = new(ReportDataSourceRetentionStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AWSBilling != nil {
+		in, out := &in.AWSBilling, &out.AWSBilling
+		*out = new(AWSBillingDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AzureCostExport != nil {
+		in, out := &in.AzureCostExport, &out.AzureCostExport
+		*out = new(AzureCostExportDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GCPBillingExport != nil {
+		in, out := &in.GCPBillingExport, &out.GCPBillingExport
+		*out = new(GCPBillingExportDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FileDrop != nil {
+		in, out := &in.FileDrop, &out.FileDrop
+		*out = new(FileDropDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubernetesInventory != nil {
+		in, out := &in.KubernetesInventory, &out.KubernetesInventory
+		*out = new(KubernetesInventoryDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PushTable != nil {
+		in, out := &in.PushTable, &out.PushTable
+		*out = new(PushTableDataSourceStatus)
+		**out = **in
+	}
+	if in.ExternalDatabase != nil {
+		in, out := &in.ExternalDatabase, &out.ExternalDatabase
+		*out = new(ExternalDatabaseDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrometheusSnapshot != nil {
+		in, out := &in.PrometheusSnapshot, &out.PrometheusSnapshot
+		*out = new(PrometheusSnapshotDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrometheusRemoteWrite != nil {
+		in, out := &in.PrometheusRemoteWrite, &out.PrometheusRemoteWrite
+		*out = new(PrometheusRemoteWriteDataSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -2737,6 +3877,25 @@ func (in *ReportQueryColumn) DeepCopy() *ReportQueryColumn {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportQueryGeneration) DeepCopyInto(out *ReportQueryGeneration) {
+	*out = *in
+	in.PeriodStart.DeepCopyInto(&out.PeriodStart)
+	in.PeriodEnd.DeepCopyInto(&out.PeriodEnd)
+	out.TableRef = in.TableRef
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportQueryGeneration.
+func (in *ReportQueryGeneration) DeepCopy() *ReportQueryGeneration {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportQueryGeneration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReportQueryInputDefinition) DeepCopyInto(out *ReportQueryInputDefinition) {
 	*out = *in
@@ -3114,6 +4273,13 @@ func (in *ReportStatus) DeepCopyInto(out *ReportStatus) {
 		*out = (*in).DeepCopy()
 	}
 	out.TableRef = in.TableRef
+	if in.QueryGenerations != nil {
+		in, out := &in.QueryGenerations, &out.QueryGenerations
+		*out = make([]ReportQueryGeneration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -5,11 +5,13 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strings"
 	"time"
 
@@ -18,6 +20,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
 )
 
 const (
@@ -33,10 +37,19 @@ const (
 	// maxS3Keys is the maximum amount of keys to be returned by a single S3
 	// list objects API response
 	maxS3Keys = 200
+
+	// maxParquetFooterSize is the maximum size of the footer of a Parquet
+	// report data file which will be read to get its schema.
+	maxParquetFooterSize = 16 * 1024 * 1024
 )
 
+// cur2ManifestDirRegexp matches the directory of the manifest of a billing
+// period of a CUR 2.0 data export, relative to the export.
+var cur2ManifestDirRegexp = regexp.MustCompile(`^metadata/BILLING_PERIOD=\d{4}-\d{2}$`)
+
 type ManifestRetriever interface {
 	RetrieveManifests() ([]*Manifest, error)
+	RetrieveParquetSchema(key string) ([]parquet.SchemaElement, error)
 }
 
 type manifestRetriever struct {
@@ -46,7 +59,22 @@ type manifestRetriever struct {
 	prefix string
 }
 
-func NewManifestRetriever(logger log.FieldLogger, region, bucket, prefix, caBundlePath string) (ManifestRetriever, error) {
+func NewManifestRetriever(logger log.FieldLogger, region, bucket, prefix, caBundlePath string, opts S3Options) (ManifestRetriever, error) {
+	client, err := newS3Client(logger, region, caBundlePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestRetriever{
+		logger: logger,
+		s3API:  client,
+		bucket: bucket,
+		prefix: prefix,
+	}, nil
+}
+
+// newS3Client returns an S3 client for region, which uses the proxy of the
+// reporting-operator if one is configured.
+func newS3Client(logger log.FieldLogger, region, caBundlePath string, opts S3Options) (s3iface.S3API, error) {
 	var (
 		proxy                 string
 		useProxyConfiguration bool
@@ -120,14 +148,11 @@ func NewManifestRetriever(logger log.FieldLogger, region, bucket, prefix, caBund
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a new aws session: %v", err)
 	}
-	client := s3.New(session)
-
-	return &manifestRetriever{
-		logger: logger,
-		s3API:  client,
-		bucket: bucket,
-		prefix: prefix,
-	}, nil
+	s3Config, err := opts.s3Config(session)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 credentials: %v", err)
+	}
+	return s3.New(session, s3Config), nil
 }
 
 // RetrieveManifests downloads the billing manifest for the given bucket and
@@ -214,6 +239,14 @@ func (r *manifestRetriever) filterObjects(prefix string, objects []*s3.Object) [
 		// manifestDir will be <YYYYMMDD-YYYYMMDD>/<assemblyId> or <YYYYMMDD-YYYYMMDD>
 		// The latter is what we're looking for (without the assemblyId subdir)
 		manifestDir := path.Dir(trimmedPath)
+		// CUR 2.0 data exports instead have a manifest for each billing
+		// period in the following format, which is overwritten each time
+		// the export runs:
+		// <export-prefix>/<export-name>/metadata/BILLING_PERIOD=YYYY-MM/<export-name>-Manifest.json
+		if cur2ManifestDirRegexp.MatchString(manifestDir) {
+			keys = append(keys, key)
+			continue
+		}
 		// assemblyDir will be empty if manifestDir is without an assemblyId
 		// subdirectory: <YYYYMMDD-YYYYMMDD>
 		assemblyDir, _ := path.Split(manifestDir)
@@ -228,6 +261,52 @@ func (r *manifestRetriever) filterObjects(prefix string, objects []*s3.Object) [
 	return keys
 }
 
+// RetrieveParquetSchema returns the schema of the Parquet report data file
+// key, which is read from its footer without downloading the rest of it.
+func (r *manifestRetriever) RetrieveParquetSchema(key string) ([]parquet.SchemaElement, error) {
+	tail, err := r.retrieveObjectTail(key, parquet.FooterTailSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the end of %s from the bucket '%s': %v", key, r.bucket, err)
+	}
+	footerLength, err := parquet.FooterLength(tail)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file %s: %v", key, err)
+	}
+	if footerLength > maxParquetFooterSize {
+		return nil, fmt.Errorf("the footer of Parquet file %s is larger than %d bytes", key, maxParquetFooterSize)
+	}
+	data, err := r.retrieveObjectTail(key, footerLength+parquet.FooterTailSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the footer of %s from the bucket '%s': %v", key, r.bucket, err)
+	}
+	schema, err := parquet.ReadSchema(data[:footerLength])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Parquet file %s: %v", key, err)
+	}
+	return schema, nil
+}
+
+// retrieveObjectTail returns the last n bytes of the object key.
+func (r *manifestRetriever) retrieveObjectTail(key string, n int) ([]byte, error) {
+	obj, err := r.s3API.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=-%d", n)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(obj.Body, int64(n)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != n {
+		return nil, fmt.Errorf("expected %d bytes, got %d", n, len(data))
+	}
+	return data, nil
+}
+
 // retrieveManifest retrieves a manifest from the given bucket and key.
 func retrieveManifest(client s3iface.S3API, bucket, key string) (*Manifest, error) {
 	obj, err := client.GetObject(&s3.GetObjectInput{
@@ -246,5 +325,8 @@ func retrieveManifest(client s3iface.S3API, bucket, key string) (*Manifest, erro
 	if err != nil {
 		return nil, err
 	}
-	return &manifest, err
+	if err := manifest.setBillingPeriodFromKey(key); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
 }
@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
+)
+
+func TestFilterObjects(t *testing.T) {
+	keys := []string{
+		"reports/cur/20170701-20170801/cur-Manifest.json",
+		"reports/cur/20170701-20170801/ea74f90b-e82f-9c72-fab6-abc716793752/cur-Manifest.json",
+		"reports/cur/20170701-20170801/ea74f90b-e82f-9c72-fab6-abc716793752/cur-1.csv.gz",
+		"reports/cur/metadata/BILLING_PERIOD=2023-11/cur-Manifest.json",
+		"reports/cur/metadata/BILLING_PERIOD=2023-11/7d1c4f0c/cur-Manifest.json",
+		"reports/cur/data/BILLING_PERIOD=2023-11/cur-00001.snappy.parquet",
+	}
+	var objects []*s3.Object
+	for _, key := range keys {
+		objects = append(objects, &s3.Object{Key: aws.String(key)})
+	}
+
+	r := &manifestRetriever{logger: logrus.New()}
+	expected := []string{
+		"reports/cur/20170701-20170801/cur-Manifest.json",
+		"reports/cur/metadata/BILLING_PERIOD=2023-11/cur-Manifest.json",
+	}
+	if filtered := r.filterObjects("reports/cur/", objects); !reflect.DeepEqual(filtered, expected) {
+		t.Errorf("unexpected manifests: got %v, want %v", filtered, expected)
+	}
+}
+
+func TestRetrieveParquetSchema(t *testing.T) {
+	var file bytes.Buffer
+	err := parquet.Write(&file, []parquet.Column{
+		{Name: "line_item_unblended_cost", Type: parquet.Double, Doubles: []float64{1.5}},
+		{Name: "line_item_usage_start_date", Type: parquet.Timestamp, Timestamps: []time.Time{time.Unix(0, 0)}},
+	})
+	require.NoError(t, err)
+
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		if r.URL.Path != "/billing/reports/cur-00001.snappy.parquet" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(file.Bytes()))
+	}))
+	defer server.Close()
+
+	retriever, err := NewManifestRetriever(logrus.New(), "us-east-1", "billing", "reports", "", S3Options{
+		Endpoint:       server.URL,
+		ForcePathStyle: true,
+		Credentials:    Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"},
+	})
+	require.NoError(t, err)
+	schema, err := retriever.RetrieveParquetSchema("reports/cur-00001.snappy.parquet")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"line_item_unblended_cost":   "double",
+		"line_item_usage_start_date": "timestamp",
+	}, parquet.HiveColumnTypes(schema))
+	require.Len(t, ranges, 2)
+	assert.Equal(t, "bytes=-8", ranges[0], "only the end of the file should be read")
+
+	_, err = retriever.RetrieveParquetSchema("reports/missing.parquet")
+	assert.Error(t, err)
+}
@@ -0,0 +1,108 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// defaultRoleSessionName is the role session name used when assuming roles
+// if one isn't configured.
+const defaultRoleSessionName = "reporting-operator"
+
+// S3Options configures how an S3 bucket is accessed.
+type S3Options struct {
+	// Endpoint is the URL of an S3-compatible object store to use instead of
+	// AWS S3.
+	Endpoint string
+	// ForcePathStyle addresses buckets in the path of requests instead of
+	// in the host name.
+	ForcePathStyle bool
+	Credentials    Credentials
+}
+
+// Credentials configures the credentials used to access S3. Static
+// credentials and a web identity can't both be set. A role to assume is
+// assumed using the static credentials, the web identity, or the default
+// credentials of the session if neither are set.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	WebIdentityRoleARN string
+	// WebIdentityTokenFile is the path of the web identity token, which
+	// must only come from the configuration of the reporting-operator.
+	WebIdentityTokenFile string
+
+	AssumeRoleARN         string
+	AssumeRoleExternalID  string
+	AssumeRoleSessionName string
+}
+
+// newCredentials returns the credentials configured by c, or nil if the
+// default credentials of sess should be used.
+func (c Credentials) newCredentials(sess *session.Session) (*credentials.Credentials, error) {
+	static := c.AccessKeyID != "" || c.SecretAccessKey != ""
+	webIdentity := c.WebIdentityRoleARN != "" || c.WebIdentityTokenFile != ""
+	sessionName := c.AssumeRoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	var creds *credentials.Credentials
+	switch {
+	case static && webIdentity:
+		return nil, fmt.Errorf("only one of static credentials and a web identity can be used")
+	case static:
+		if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+			return nil, fmt.Errorf("both an access key ID and a secret access key are required")
+		}
+		creds = credentials.NewStaticCredentials(c.AccessKeyID, c.SecretAccessKey, c.SessionToken)
+	case webIdentity:
+		if c.WebIdentityRoleARN == "" || c.WebIdentityTokenFile == "" {
+			return nil, fmt.Errorf("both a role ARN and a token file are required to use a web identity")
+		}
+		creds = stscreds.NewWebIdentityCredentials(sess, c.WebIdentityRoleARN, sessionName, c.WebIdentityTokenFile)
+	}
+
+	if c.AssumeRoleARN == "" {
+		if c.AssumeRoleExternalID != "" {
+			return nil, fmt.Errorf("an external ID requires a role to assume")
+		}
+		return creds, nil
+	}
+	source := sess
+	if creds != nil {
+		source = sess.Copy(&aws.Config{Credentials: creds})
+	}
+	return stscreds.NewCredentials(source, c.AssumeRoleARN, func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = sessionName
+		if c.AssumeRoleExternalID != "" {
+			p.ExternalID = aws.String(c.AssumeRoleExternalID)
+		}
+	}), nil
+}
+
+// s3Config returns the configuration of S3 clients for opts, which isn't
+// used for the session so the endpoint of S3 doesn't apply to STS.
+func (opts S3Options) s3Config(sess *session.Session) (*aws.Config, error) {
+	creds, err := opts.Credentials.newCredentials(sess)
+	if err != nil {
+		return nil, err
+	}
+	config := &aws.Config{}
+	if creds != nil {
+		config.Credentials = creds
+	}
+	if opts.Endpoint != "" {
+		config.Endpoint = aws.String(opts.Endpoint)
+	}
+	if opts.ForcePathStyle {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	return config, nil
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentials(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+	require.NoError(t, err)
+
+	creds, err := Credentials{}.newCredentials(sess)
+	require.NoError(t, err)
+	assert.Nil(t, creds, "the default credentials of the session should be used")
+
+	creds, err = Credentials{AccessKeyID: "id", SecretAccessKey: "secret", SessionToken: "token"}.newCredentials(sess)
+	require.NoError(t, err)
+	value, err := creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "id", value.AccessKeyID)
+	assert.Equal(t, "secret", value.SecretAccessKey)
+	assert.Equal(t, "token", value.SessionToken)
+
+	for _, valid := range []Credentials{
+		{WebIdentityRoleARN: "arn:aws:iam::123456789012:role/metering", WebIdentityTokenFile: "/var/run/secrets/token"},
+		{AssumeRoleARN: "arn:aws:iam::210987654321:role/payer", AssumeRoleExternalID: "external"},
+		{AccessKeyID: "id", SecretAccessKey: "secret", AssumeRoleARN: "arn:aws:iam::210987654321:role/payer"},
+		{WebIdentityRoleARN: "arn:aws:iam::123456789012:role/metering", WebIdentityTokenFile: "/var/run/secrets/token", AssumeRoleARN: "arn:aws:iam::210987654321:role/payer"},
+	} {
+		creds, err := valid.newCredentials(sess)
+		assert.NoError(t, err)
+		assert.NotNil(t, creds)
+	}
+
+	for _, invalid := range []Credentials{
+		{AccessKeyID: "id"},
+		{WebIdentityRoleARN: "arn:aws:iam::123456789012:role/metering"},
+		{AccessKeyID: "id", SecretAccessKey: "secret", WebIdentityRoleARN: "arn:aws:iam::123456789012:role/metering", WebIdentityTokenFile: "/var/run/secrets/token"},
+		{AssumeRoleExternalID: "external"},
+	} {
+		_, err := invalid.newCredentials(sess)
+		assert.Error(t, err, "%#v should be invalid", invalid)
+	}
+}
+
+func TestManifestRetrieverS3CompatibleEndpoint(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>billing</Name><Prefix>reports/</Prefix><KeyCount>0</KeyCount><MaxKeys>200</MaxKeys><IsTruncated>false</IsTruncated></ListBucketResult>`))
+	}))
+	defer server.Close()
+
+	retriever, err := NewManifestRetriever(logrus.New(), "us-east-1", "billing", "reports", "", S3Options{
+		Endpoint:       server.URL,
+		ForcePathStyle: true,
+		Credentials:    Credentials{AccessKeyID: "minio", SecretAccessKey: "minio123"},
+	})
+	require.NoError(t, err)
+	manifests, err := retriever.RetrieveManifests()
+	require.NoError(t, err)
+	assert.Empty(t, manifests)
+
+	require.Len(t, requests, 1)
+	// path-style requests have the bucket in the path rather than the host
+	assert.Equal(t, "/billing", requests[0].URL.Path)
+	assert.Equal(t, "reports/", requests[0].URL.Query().Get("prefix"))
+	assert.True(t, strings.Contains(requests[0].Header.Get("Authorization"), "Credential=minio/"), "requests should be signed with the configured credentials")
+}
@@ -1,12 +1,33 @@
 package aws
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ManifestFormat is the layout of the report data a Manifest describes.
+type ManifestFormat string
+
+const (
+	// ManifestFormatCSV is the legacy Cost and Usage Report layout of gzip
+	// compressed CSV files, with columns named category/name.
+	ManifestFormatCSV ManifestFormat = "CSV"
+	// ManifestFormatParquet is the legacy Cost and Usage Report layout of
+	// Parquet files, with the category/name of each column converted to
+	// snake case, such as line_item_usage_start_date.
+	ManifestFormatParquet ManifestFormat = "Parquet"
+	// ManifestFormatCUR2 is the CUR 2.0 data export layout of Parquet
+	// files, whose columns are listed with their names and types.
+	ManifestFormatCUR2 ManifestFormat = "CUR2"
+)
+
 // Manifest is a representation of the file AWS provides with metadata for current usage information.
 type Manifest struct {
 	AssemblyID             string               `json:"assemblyId"`
@@ -21,6 +42,13 @@ type Manifest struct {
 	Bucket                 string               `json:"bucket"`
 	ReportKeys             []string             `json:"reportKeys"`
 	AdditionalArtifactKeys []AdditionalArtifact `json:"additionalArtifactKeys"`
+
+	// ExportName, ExecutionID and DataFiles are only set in the manifests
+	// of CUR 2.0 data exports. DataFiles are the s3:// URIs of the data
+	// files, rather than keys.
+	ExportName  string   `json:"exportName,omitempty"`
+	ExecutionID string   `json:"executionId,omitempty"`
+	DataFiles   []string `json:"dataFiles,omitempty"`
 }
 
 type BillingPeriod struct {
@@ -28,10 +56,31 @@ type BillingPeriod struct {
 	End   Time `json:"end"`
 }
 
+// UnmarshalJSON accepts a billing period as either an object with a start
+// and end, or a month such as "2023-11", which is how CUR 2.0 data exports
+// identify billing periods.
+func (p *BillingPeriod) UnmarshalJSON(b []byte) error {
+	var month string
+	if err := json.Unmarshal(b, &month); err == nil {
+		start, err := time.Parse(billingPeriodMonthLayout, month)
+		if err != nil {
+			return fmt.Errorf("invalid billing period %q: %v", month, err)
+		}
+		*p = BillingPeriod{Start: Time{start}, End: Time{start.AddDate(0, 1, 0)}}
+		return nil
+	}
+	type billingPeriod BillingPeriod
+	return json.Unmarshal(b, (*billingPeriod)(p))
+}
+
 // Column is a description of a field from a AWS usage report manifest file.
 type Column struct {
 	Category string `json:"category"`
 	Name     string `json:"name"`
+	// Type is the type of the column, such as DateTime or BigDecimal in
+	// legacy manifests, or a Hive type such as map<string,string> in CUR 2.0
+	// manifests. It's empty in older manifests.
+	Type string `json:"type,omitempty"`
 }
 
 type AdditionalArtifact struct {
@@ -39,6 +88,62 @@ type AdditionalArtifact struct {
 	Name         string `json:"name"`
 }
 
+// billingPeriodMonthLayout is the layout of the months CUR 2.0 data exports
+// are partitioned by, such as BILLING_PERIOD=2023-11.
+const billingPeriodMonthLayout = "2006-01"
+
+var cur2BillingPeriodRegexp = regexp.MustCompile(`(?:^|/)BILLING_PERIOD=(\d{4}-\d{2})(?:/|$)`)
+
+// Format returns the layout of the report data described by the manifest,
+// which is detected from its fields.
+func (m Manifest) Format() ManifestFormat {
+	if len(m.DataFiles) != 0 || m.ExportName != "" {
+		return ManifestFormatCUR2
+	}
+	if strings.EqualFold(m.ContentType, "parquet") || strings.EqualFold(m.Compression, "parquet") {
+		return ManifestFormatParquet
+	}
+	return ManifestFormatCSV
+}
+
+// setBillingPeriodFromKey sets the billing period of a CUR 2.0 manifest
+// without one from the BILLING_PERIOD directory it was found in.
+func (m *Manifest) setBillingPeriodFromKey(key string) error {
+	if !m.BillingPeriod.Start.IsZero() || m.Format() != ManifestFormatCUR2 {
+		return nil
+	}
+	match := cur2BillingPeriodRegexp.FindStringSubmatch(key)
+	if match == nil {
+		return fmt.Errorf("manifest %s has no billing period", key)
+	}
+	start, err := time.Parse(billingPeriodMonthLayout, match[1])
+	if err != nil {
+		return err
+	}
+	m.BillingPeriod = BillingPeriod{Start: Time{start}, End: Time{start.AddDate(0, 1, 0)}}
+	return nil
+}
+
+// DataKeys returns the keys of the report data files, which are the
+// reportKeys of legacy manifests, and the keys of the dataFiles of CUR 2.0
+// manifests.
+func (m Manifest) DataKeys() []string {
+	if len(m.DataFiles) == 0 {
+		return m.ReportKeys
+	}
+	keys := make([]string, 0, len(m.DataFiles))
+	for _, file := range m.DataFiles {
+		u, err := url.Parse(file)
+		if err != nil || u.Scheme == "" {
+			// already a key
+			keys = append(keys, file)
+			continue
+		}
+		keys = append(keys, strings.TrimPrefix(u.Path, "/"))
+	}
+	return keys
+}
+
 // Paths returns the directories containing usage data. The result will be free of duplicates.
 func (m Manifest) DataDirectory() string {
 	paths := m.paths()
@@ -48,7 +153,7 @@ func (m Manifest) DataDirectory() string {
 		path = paths[0]
 	}
 	if pathsLen != 1 {
-		logrus.Errorf("aws manifest %s does not have exactly 1 data directory containing report data, reportKeys: %v", m.AssemblyID, m.ReportKeys)
+		logrus.Errorf("aws manifest %s does not have exactly 1 data directory containing report data, reportKeys: %v", m.AssemblyID, m.DataKeys())
 	}
 
 	return path
@@ -57,7 +162,7 @@ func (m Manifest) DataDirectory() string {
 func (m Manifest) paths() []string {
 	seen := make(map[string]struct{})
 	var paths []string
-	for _, key := range m.ReportKeys {
+	for _, key := range m.DataKeys() {
 		dirPath := filepath.Dir(key)
 		if _, exists := seen[dirPath]; exists {
 			continue
@@ -74,13 +179,23 @@ type Time struct {
 
 const manifestTime = "20060102T000000.000Z"
 
+// UnmarshalJSON parses the timestamps of legacy manifests, such as
+// 20170701T000000.000Z, and the RFC 3339 timestamps of CUR 2.0 manifests.
 func (t *Time) UnmarshalJSON(b []byte) error {
-	// b contains quotes around the timestamp
-	tt, err := time.Parse(manifestTime, string(b[1:len(b)-1]))
-	if err == nil {
-		*t = Time{tt}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	tt, err := time.Parse(manifestTime, s)
+	if err != nil {
+		var rfc3339Err error
+		tt, rfc3339Err = time.Parse(time.RFC3339Nano, s)
+		if rfc3339Err != nil {
+			return err
+		}
 	}
-	return err
+	*t = Time{tt.UTC()}
+	return nil
 }
 
 func (t *Time) String() string {
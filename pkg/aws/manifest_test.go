@@ -60,3 +60,74 @@ func TestManifest_Paths(t *testing.T) {
 		t.Error("manifests without report keys should not produce paths")
 	}
 }
+
+func TestManifest_Format(t *testing.T) {
+	var csv Manifest
+	if err := json.Unmarshal([]byte(manifestText), &csv); err != nil {
+		t.Fatal("failed to unmarshal manifest: ", err)
+	}
+	if format := csv.Format(); format != ManifestFormatCSV {
+		t.Errorf("unexpected format: got %s, want %s", format, ManifestFormatCSV)
+	}
+
+	parquet := csv
+	parquet.ContentType = "Parquet"
+	parquet.Compression = "Parquet"
+	if format := parquet.Format(); format != ManifestFormatParquet {
+		t.Errorf("unexpected format: got %s, want %s", format, ManifestFormatParquet)
+	}
+}
+
+func TestManifest_CUR2(t *testing.T) {
+	const cur2ManifestText = `{
+  "exportName":"cur2-export",
+  "executionId":"7d1c4f0c-0d6c-4a2b-8f1e-0c0b7c6f6a5e",
+  "billingPeriod":"2023-11",
+  "dataFiles":["s3://billing-bucket/exports/cur2-export/data/BILLING_PERIOD=2023-11/cur2-export-00001.snappy.parquet","s3://billing-bucket/exports/cur2-export/data/BILLING_PERIOD=2023-11/cur2-export-00002.snappy.parquet"],
+  "columns":[{"name":"line_item_usage_start_date","type":"timestamp"},{"name":"resource_tags","type":"map<string,string>"}]
+}`
+	var manifest Manifest
+	if err := json.Unmarshal([]byte(cur2ManifestText), &manifest); err != nil {
+		t.Fatal("failed to unmarshal manifest: ", err)
+	}
+	if format := manifest.Format(); format != ManifestFormatCUR2 {
+		t.Errorf("unexpected format: got %s, want %s", format, ManifestFormatCUR2)
+	}
+	if start := manifest.BillingPeriod.Start.Format(BillingDateFormat); start != "20231101" {
+		t.Errorf("unexpected billing period start: got %s, want 20231101", start)
+	}
+	if end := manifest.BillingPeriod.End.Format(BillingDateFormat); end != "20231201" {
+		t.Errorf("unexpected billing period end: got %s, want 20231201", end)
+	}
+	expectedPath := "exports/cur2-export/data/BILLING_PERIOD=2023-11"
+	if path := manifest.DataDirectory(); path != expectedPath {
+		t.Errorf("unexpected path: got %s, want %s", path, expectedPath)
+	}
+	if manifest.Columns[1].Type != "map<string,string>" {
+		t.Errorf("unexpected column type: got %s, want map<string,string>", manifest.Columns[1].Type)
+	}
+
+	// billing periods can also be timestamps, or missing, in which case
+	// the billing period is taken from the key of the manifest
+	var withTimestamps Manifest
+	if err := json.Unmarshal([]byte(`{"exportName":"cur2-export","billingPeriod":{"start":"2023-11-01T00:00:00.000Z","end":"2023-12-01T00:00:00.000Z"}}`), &withTimestamps); err != nil {
+		t.Fatal("failed to unmarshal manifest: ", err)
+	}
+	if !withTimestamps.BillingPeriod.End.Equal(manifest.BillingPeriod.End.Time) {
+		t.Errorf("unexpected billing period end: got %s, want %s", withTimestamps.BillingPeriod.End.Time, manifest.BillingPeriod.End.Time)
+	}
+
+	var withoutPeriod Manifest
+	if err := json.Unmarshal([]byte(`{"exportName":"cur2-export"}`), &withoutPeriod); err != nil {
+		t.Fatal("failed to unmarshal manifest: ", err)
+	}
+	if err := withoutPeriod.setBillingPeriodFromKey("exports/cur2-export/metadata/BILLING_PERIOD=2023-11/cur2-export-Manifest.json"); err != nil {
+		t.Fatal("failed to set billing period: ", err)
+	}
+	if !withoutPeriod.BillingPeriod.Start.Equal(manifest.BillingPeriod.Start.Time) {
+		t.Errorf("unexpected billing period start: got %s, want %s", withoutPeriod.BillingPeriod.Start.Time, manifest.BillingPeriod.Start.Time)
+	}
+	if err := withoutPeriod.setBillingPeriodFromKey("exports/cur2-export/metadata/cur2-export-Manifest.json"); err != nil {
+		t.Error("billing periods which are already set must not be changed: ", err)
+	}
+}
@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	log "github.com/sirupsen/logrus"
+)
+
+// Object is an object in an S3 bucket.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ObjectLister lists the objects in an S3 bucket.
+type ObjectLister struct {
+	s3API  s3iface.S3API
+	bucket string
+}
+
+func NewObjectLister(logger log.FieldLogger, region, bucket, caBundlePath string, opts S3Options) (*ObjectLister, error) {
+	client, err := newS3Client(logger, region, caBundlePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectLister{s3API: client, bucket: bucket}, nil
+}
+
+// ListObjects returns every object with a key starting with prefix.
+func (l *ObjectLister) ListObjects(prefix string) ([]Object, error) {
+	var objects []Object
+	err := l.s3API.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(l.bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(maxS3Keys),
+	}, func(out *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range out.Contents {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(obj.Key),
+				Size:         aws.Int64Value(obj.Size),
+				LastModified: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the objects in bucket %s with prefix %s: %v", l.bucket, prefix, err)
+	}
+	return objects, nil
+}
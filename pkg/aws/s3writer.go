@@ -0,0 +1,110 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3ObjectWriter writes objects to S3 buckets, using the credentials from the
+// environment. The region of each bucket is looked up the first time it's
+// written to.
+type S3ObjectWriter struct {
+	session *session.Session
+
+	clientsMu sync.Mutex
+	clients   map[string]s3iface.S3API
+}
+
+func NewS3ObjectWriter() (*S3ObjectWriter, error) {
+	session, err := session.NewSession(&aws.Config{Region: aws.String(defaultS3Region)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new aws session: %v", err)
+	}
+	return &S3ObjectWriter{
+		session: session,
+		clients: make(map[string]s3iface.S3API),
+	}, nil
+}
+
+func (w *S3ObjectWriter) client(ctx context.Context, bucket string) (s3iface.S3API, error) {
+	w.clientsMu.Lock()
+	defer w.clientsMu.Unlock()
+	if client, ok := w.clients[bucket]; ok {
+		return client, nil
+	}
+	region, err := s3manager.GetBucketRegion(ctx, w.session, bucket, defaultS3Region)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the region of bucket %s: %v", bucket, err)
+	}
+	client := s3.New(w.session, &aws.Config{Region: aws.String(region)})
+	w.clients[bucket] = client
+	return client, nil
+}
+
+// PutObject writes data to the object key in bucket.
+func (w *S3ObjectWriter) PutObject(ctx context.Context, bucket, key string, data []byte) error {
+	client, err := w.client(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write object %s to bucket %s: %v", key, bucket, err)
+	}
+	return nil
+}
+
+// DeletePrefix deletes every object in bucket whose key starts with prefix.
+func (w *S3ObjectWriter) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	client, err := w.client(ctx, bucket)
+	if err != nil {
+		return err
+	}
+
+	var deleteErr error
+	err = client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		// pages have at most 1000 objects, the most DeleteObjects accepts
+		if len(page.Contents) == 0 {
+			return true
+		}
+		objects := make([]*s3.ObjectIdentifier, len(page.Contents))
+		for i, object := range page.Contents {
+			objects[i] = &s3.ObjectIdentifier{Key: object.Key}
+		}
+		out, err := client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			deleteErr = err
+			return false
+		}
+		if len(out.Errors) != 0 {
+			deleteErr = fmt.Errorf("%d objects were not deleted, including %s: %s", len(out.Errors), aws.StringValue(out.Errors[0].Key), aws.StringValue(out.Errors[0].Message))
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = deleteErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to delete objects with prefix %s from bucket %s: %v", prefix, bucket, err)
+	}
+	return nil
+}
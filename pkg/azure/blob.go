@@ -0,0 +1,286 @@
+package azure
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// blobServiceVersion is the version of the Blob service REST API used.
+	blobServiceVersion = "2019-12-12"
+
+	// maxListResults is the maximum amount of blobs to be returned by a
+	// single List Blobs API response.
+	maxListResults = 1000
+)
+
+// BlobClient reads the blobs of an Azure storage container using the Blob
+// service REST API.
+type BlobClient struct {
+	httpClient *http.Client
+	// containerURL is the URL of the container, such as
+	// https://account.blob.core.windows.net/container.
+	containerURL *url.URL
+	account      string
+	container    string
+	// key is the decoded access key of the storage account, requests are
+	// anonymous if it's empty.
+	key []byte
+}
+
+// Blob is a blob returned when listing a container.
+type Blob struct {
+	Name          string
+	LastModified  time.Time
+	ContentLength int64
+}
+
+// NewBlobClient returns a client for the container of the storage account.
+// The endpoint of the Blob service defaults to
+// https://<account>.blob.core.windows.net, but can be set to the URL of an
+// emulator such as Azurite, which includes the account in the path, eg:
+// http://127.0.0.1:10000/devstoreaccount1. If caBundlePath is set, the CA
+// bundle is trusted in addition to the system roots.
+func NewBlobClient(account, container, endpoint, accessKey, caBundlePath string) (*BlobClient, error) {
+	if account == "" {
+		return nil, fmt.Errorf("a storage account name is required")
+	}
+	if container == "" {
+		return nil, fmt.Errorf("a container is required")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Blob service endpoint %q: %v", endpoint, err)
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Blob service endpoint %q: scheme must be http or https", endpoint)
+	}
+	var key []byte
+	if accessKey != "" {
+		key, err = base64.StdEncoding.DecodeString(accessKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid storage account access key: %v", err)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the trusted CA bundle: %v", err)
+		}
+		caRoot, err := x509.SystemCertPool()
+		if err != nil {
+			caRoot = x509.NewCertPool()
+		}
+		caRoot.AppendCertsFromPEM(caBundle)
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: caRoot,
+		}
+	}
+
+	containerURL := *endpointURL
+	containerURL.Path = strings.TrimSuffix(containerURL.Path, "/") + "/" + container
+	return &BlobClient{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   time.Second * 60,
+		},
+		containerURL: &containerURL,
+		account:      account,
+		container:    container,
+		key:          key,
+	}, nil
+}
+
+type listBlobsResult struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+type blobError struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// ListBlobs returns every blob in the container with a name beginning with
+// prefix.
+func (c *BlobClient) ListBlobs(prefix string) ([]Blob, error) {
+	var (
+		blobs  []Blob
+		marker string
+	)
+	for {
+		query := url.Values{
+			"restype":    {"container"},
+			"comp":       {"list"},
+			"maxresults": {strconv.Itoa(maxListResults)},
+		}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs in container %s with prefix %q: %v", c.container, prefix, err)
+		}
+		var result listBlobsResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the blobs in container %s: %v", c.container, err)
+		}
+		for _, b := range result.Blobs {
+			lastModified, err := time.Parse(http.TimeFormat, b.Properties.LastModified)
+			if err != nil {
+				return nil, fmt.Errorf("invalid last modified time %q of blob %s: %v", b.Properties.LastModified, b.Name, err)
+			}
+			blobs = append(blobs, Blob{
+				Name:          b.Name,
+				LastModified:  lastModified,
+				ContentLength: b.Properties.ContentLength,
+			})
+		}
+		if result.NextMarker == "" {
+			return blobs, nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// GetBlob returns the content of a blob. If length is greater than zero,
+// only the first length bytes of the blob are returned. The caller must
+// close the returned reader.
+func (c *BlobClient) GetBlob(name string, length int64) (io.ReadCloser, error) {
+	headers := http.Header{}
+	if length > 0 {
+		headers.Set("x-ms-range", fmt.Sprintf("bytes=0-%d", length-1))
+	}
+	resp, err := c.do(http.MethodGet, name, nil, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s from container %s: %v", name, c.container, err)
+	}
+	return resp.Body, nil
+}
+
+// do sends a request for the blob name, or the container if name is empty,
+// and returns an error if the response isn't successful.
+func (c *BlobClient) do(method, name string, query url.Values, headers http.Header) (*http.Response, error) {
+	u := *c.containerURL
+	if name != "" {
+		u.Path += "/" + name
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", blobServiceVersion)
+	if len(c.key) != 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", c.account, c.signature(req)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var blobErr blobError
+		if err := xml.NewDecoder(resp.Body).Decode(&blobErr); err != nil || blobErr.Code == "" {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s: %s", resp.Status, blobErr.Code, strings.TrimSpace(blobErr.Message))
+	}
+	return resp, nil
+}
+
+// signature returns the Shared Key signature of req, as described in
+// https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key.
+func (c *BlobClient) signature(req *http.Request) string {
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+	stringToSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		// the Date header is empty because x-ms-date is set
+		"",
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalizedHeaders(req.Header) + canonicalizedResource(c.account, req.URL),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func canonicalizedHeaders(headers http.Header) string {
+	var names []string
+	for name := range headers {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s:%s\n", name, strings.TrimSpace(headers.Get(name)))
+	}
+	return b.String()
+}
+
+func canonicalizedResource(account string, u *url.URL) string {
+	var b strings.Builder
+	b.WriteString("/" + account + u.EscapedPath())
+	query := u.Query()
+	var names []string
+	for name := range query {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		fmt.Fprintf(&b, "\n%s:%s", name, strings.Join(values, ","))
+	}
+	return b.String()
+}
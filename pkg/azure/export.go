@@ -0,0 +1,286 @@
+package azure
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ExportManifestName is the name of the manifest written in the
+	// directory of each run of a Cost Management export.
+	ExportManifestName = "manifest.json"
+
+	// ExportFileFormatCSV is the only file format of exports that's
+	// supported.
+	ExportFileFormatCSV = "Csv"
+
+	// billingPeriodDirLayout is the layout of each date of the name of the
+	// billing period directory of an export.
+	billingPeriodDirLayout = "20060102"
+
+	// exportDateLayout is the layout of the dates of the runInfo of a
+	// manifest, which don't include a time zone.
+	exportDateLayout = "2006-01-02T15:04:05"
+
+	// maxHeaderBytes is the amount of the first data blob of a run read to
+	// find its columns.
+	maxHeaderBytes = 64 * 1024
+)
+
+// billingPeriodDirRegexp matches the name of the billing period directory
+// of an export, eg: 20240101-20240131.
+var billingPeriodDirRegexp = regexp.MustCompile(`^(\d{8})-(\d{8})$`)
+
+// ExportManifest is the manifest of a run of an Azure Cost Management
+// export.
+type ExportManifest struct {
+	ManifestVersion string `json:"manifestVersion"`
+	BlobCount       int    `json:"blobCount"`
+	DataRowCount    int64  `json:"dataRowCount"`
+
+	ExportConfig struct {
+		ExportName  string `json:"exportName"`
+		ResourceID  string `json:"resourceId"`
+		DataVersion string `json:"dataVersion"`
+		Type        string `json:"type"`
+		TimeFrame   string `json:"timeFrame"`
+		Granularity string `json:"granularity"`
+	} `json:"exportConfig"`
+
+	DeliveryConfig struct {
+		PartitionData         bool   `json:"partitionData"`
+		DataOverwriteBehavior string `json:"dataOverwriteBehavior"`
+		FileFormat            string `json:"fileFormat"`
+		CompressionMode       string `json:"compressionMode"`
+	} `json:"deliveryConfig"`
+
+	RunInfo struct {
+		ExecutionType string     `json:"executionType"`
+		SubmittedTime time.Time  `json:"submittedTime"`
+		RunID         string     `json:"runId"`
+		StartDate     ExportDate `json:"startDate"`
+		EndDate       ExportDate `json:"endDate"`
+	} `json:"runInfo"`
+
+	Blobs []ExportBlob `json:"blobs"`
+}
+
+// ExportBlob is a data blob of a run of an export.
+type ExportBlob struct {
+	BlobName     string `json:"blobName"`
+	ByteCount    int64  `json:"byteCount"`
+	DataRowCount int64  `json:"dataRowCount"`
+}
+
+// ExportDate is a date of the runInfo of a manifest, which is in UTC
+// without a time zone.
+type ExportDate struct {
+	time.Time
+}
+
+func (d *ExportDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+	t, err := time.Parse(exportDateLayout, s)
+	if err != nil {
+		// newer manifests may include the time zone
+		t, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("invalid export date %q: %v", s, err)
+		}
+	}
+	d.Time = t.UTC()
+	return nil
+}
+
+// ExportRun is the latest run of an export for a billing period.
+type ExportRun struct {
+	Manifest *ExportManifest
+	// Directory is the directory containing the manifest and the data
+	// blobs of the run.
+	Directory string
+	// BillingPeriodStart is inclusive and BillingPeriodEnd is exclusive.
+	BillingPeriodStart time.Time
+	BillingPeriodEnd   time.Time
+	// Columns are the column names in the header of the data blobs.
+	Columns []string
+}
+
+type ExportRetriever interface {
+	RetrieveRuns() ([]*ExportRun, error)
+}
+
+type exportRetriever struct {
+	logger log.FieldLogger
+	client *BlobClient
+	prefix string
+}
+
+// NewExportRetriever returns an ExportRetriever for the runs of the exports
+// with manifests under prefix, which is usually the directory of an export:
+// <root-folder>/<export-name>.
+func NewExportRetriever(logger log.FieldLogger, client *BlobClient, prefix string) ExportRetriever {
+	return &exportRetriever{
+		logger: logger,
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// RetrieveRuns returns the latest run of each billing period, sorted by the
+// start of the billing period. Exports write each run to its own directory:
+// <prefix>/<YYYYMMDD-YYYYMMDD>/<run-id>/manifest.json
+func (r *exportRetriever) RetrieveRuns() ([]*ExportRun, error) {
+	prefix := r.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	logger := r.logger.WithField("prefix", prefix)
+
+	blobs, err := r.client.ListBlobs(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[time.Time]*ExportRun)
+	for _, blob := range blobs {
+		if path.Base(blob.Name) != ExportManifestName {
+			continue
+		}
+		runDir := path.Dir(blob.Name)
+		start, end, ok := parseBillingPeriodDir(path.Base(path.Dir(runDir)))
+		if !ok {
+			logger.Debugf("ignoring manifest %s outside of a billing period directory", blob.Name)
+			continue
+		}
+
+		logger.WithField("blob", blob.Name).Debugf("retrieving manifest")
+		manifest, err := r.retrieveManifest(blob.Name)
+		if err != nil {
+			return nil, err
+		}
+		if manifest.DeliveryConfig.FileFormat != "" && !strings.EqualFold(manifest.DeliveryConfig.FileFormat, ExportFileFormatCSV) {
+			return nil, fmt.Errorf("export run %s has unsupported file format %s, only %s exports are supported", runDir, manifest.DeliveryConfig.FileFormat, ExportFileFormatCSV)
+		}
+		if len(manifest.Blobs) == 0 {
+			logger.Debugf("ignoring manifest %s without data blobs", blob.Name)
+			continue
+		}
+
+		run := &ExportRun{
+			Manifest:           manifest,
+			Directory:          runDir,
+			BillingPeriodStart: start,
+			BillingPeriodEnd:   end,
+		}
+		if previous, exists := latest[start]; !exists || previous.Manifest.RunInfo.SubmittedTime.Before(manifest.RunInfo.SubmittedTime) {
+			latest[start] = run
+		}
+	}
+
+	runs := make([]*ExportRun, 0, len(latest))
+	for _, run := range latest {
+		run.Columns, err = r.retrieveColumns(run)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].BillingPeriodStart.Before(runs[j].BillingPeriodStart)
+	})
+	return runs, nil
+}
+
+func (r *exportRetriever) retrieveManifest(name string) (*ExportManifest, error) {
+	body, err := r.client.GetBlob(name, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest ExportManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %s: %v", name, err)
+	}
+	return &manifest, nil
+}
+
+// retrieveColumns reads the header of the first data blob of run.
+func (r *exportRetriever) retrieveColumns(run *ExportRun) ([]string, error) {
+	name := run.Manifest.Blobs[0].BlobName
+	body, err := r.client.GetBlob(name, maxHeaderBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var reader io.Reader = body
+	if strings.HasSuffix(name, ".gz") || strings.EqualFold(run.Manifest.DeliveryConfig.CompressionMode, "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress data blob %s: %v", name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	columns, err := ReadHeader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of data blob %s: %v", name, err)
+	}
+	return columns, nil
+}
+
+// ReadHeader returns the column names in the first line of CSV data.
+func ReadHeader(r io.Reader) ([]string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, err
+	}
+	// exports begin with a byte order mark
+	line = strings.TrimPrefix(line, "\ufeff")
+	columns, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		columns[i] = strings.TrimSpace(col)
+	}
+	return columns, nil
+}
+
+// parseBillingPeriodDir returns the billing period of a directory named
+// YYYYMMDD-YYYYMMDD, which includes the end date. The returned end is the
+// day after it.
+func parseBillingPeriodDir(dir string) (time.Time, time.Time, bool) {
+	match := billingPeriodDirRegexp.FindStringSubmatch(dir)
+	if match == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err := time.Parse(billingPeriodDirLayout, match[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse(billingPeriodDirLayout, match[2])
+	if err != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end.AddDate(0, 0, 1), true
+}
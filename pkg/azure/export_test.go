@@ -0,0 +1,151 @@
+package azure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// azuriteAccountKey is the well known access key of the devstoreaccount1
+// account of the Azurite emulator.
+const azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func testManifest(runID, submitted, blobName string) string {
+	return fmt.Sprintf(`{
+  "manifestVersion": "2024-04-01",
+  "exportConfig": {"exportName": "costs", "type": "ActualCost"},
+  "deliveryConfig": {"fileFormat": "Csv", "compressionMode": "gzip"},
+  "runInfo": {"runId": %q, "submittedTime": %q, "startDate": "2024-01-01T00:00:00", "endDate": "2024-01-31T00:00:00"},
+  "blobs": [{"blobName": %q, "byteCount": 100, "dataRowCount": 1}]
+}`, runID, submitted, blobName)
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExportRetrieverRetrieveRuns(t *testing.T) {
+	blobs := map[string][]byte{
+		"exports/costs/20240101-20240131/run-1/manifest.json":       []byte(testManifest("run-1", "2024-02-01T05:00:00.1234567Z", "exports/costs/20240101-20240131/run-1/part_0_0001.csv.gz")),
+		"exports/costs/20240101-20240131/run-1/part_0_0001.csv.gz":  gzipped(t, "\ufeffDate,CostInBillingCurrency\n01/01/2024,1.5\n"),
+		"exports/costs/20240101-20240131/run-2/manifest.json":       []byte(testManifest("run-2", "2024-02-03T05:00:00Z", "exports/costs/20240101-20240131/run-2/part_0_0001.csv.gz")),
+		"exports/costs/20240101-20240131/run-2/part_0_0001.csv.gz":  gzipped(t, "Date,CostInBillingCurrency,Tags\n01/01/2024,1.5,\"{\"\"a\"\": \"\"b\"\"}\"\n"),
+		"exports/costs/20240201-20240229/run-3/manifest.json":       []byte(testManifest("run-3", "2024-02-10T05:00:00Z", "exports/costs/20240201-20240229/run-3/part_0_0001.csv.gz")),
+		"exports/costs/20240201-20240229/run-3/part_0_0001.csv.gz":  gzipped(t, "Date,CostInBillingCurrency,Tags\n"),
+		"exports/costs/manifest.json":                               []byte(`{}`),
+		"exports/costs/20240201-20240229/run-3/unrelated/other.txt": []byte(`other`),
+	}
+
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		const containerPath = "/devstoreaccount1/billing"
+		if r.URL.Path == containerPath {
+			assert.Equal(t, "list", r.URL.Query().Get("comp"))
+			assert.Equal(t, "exports/costs/", r.URL.Query().Get("prefix"))
+			// return the blobs in two pages
+			names := []string{}
+			for name := range blobs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			var page []string
+			nextMarker := ""
+			if r.URL.Query().Get("marker") == "" {
+				page, nextMarker = names[:4], "page-2"
+			} else {
+				page = names[4:]
+			}
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults ContainerName="billing"><Blobs>`)
+			for _, name := range page {
+				fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Last-Modified>Sat, 03 Feb 2024 05:00:00 GMT</Last-Modified><Content-Length>%d</Content-Length></Properties></Blob>", name, len(blobs[name]))
+			}
+			fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", nextMarker)
+			return
+		}
+		content, ok := blobs[strings.TrimPrefix(r.URL.Path, containerPath+"/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><Error><Code>BlobNotFound</Code><Message>The specified blob does not exist.</Message></Error>`)
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	client, err := NewBlobClient("devstoreaccount1", "billing", server.URL+"/devstoreaccount1", azuriteAccountKey, "")
+	require.NoError(t, err)
+	runs, err := NewExportRetriever(logrus.New(), client, "exports/costs").RetrieveRuns()
+	require.NoError(t, err)
+
+	require.Len(t, runs, 2)
+	// the latest run of January replaces the previous run
+	assert.Equal(t, "run-2", runs[0].Manifest.RunInfo.RunID)
+	assert.Equal(t, "exports/costs/20240101-20240131/run-2", runs[0].Directory)
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), runs[0].BillingPeriodStart)
+	assert.Equal(t, time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), runs[0].BillingPeriodEnd)
+	assert.Equal(t, time.Date(2024, time.January, 31, 0, 0, 0, 0, time.UTC), runs[0].Manifest.RunInfo.EndDate.Time)
+	assert.Equal(t, []string{"Date", "CostInBillingCurrency", "Tags"}, runs[0].Columns)
+	assert.Equal(t, "run-3", runs[1].Manifest.RunInfo.RunID)
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), runs[1].BillingPeriodEnd)
+
+	for _, authorization := range authorizations {
+		assert.True(t, strings.HasPrefix(authorization, "SharedKey devstoreaccount1:"), "requests should be signed with the account key: %q", authorization)
+	}
+
+	_, err = client.GetBlob("missing.csv", 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "BlobNotFound: The specified blob does not exist.")
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	columns, err := ReadHeader(strings.NewReader("\ufeff\"InvoiceId\", Date ,\"Tags\"\r\n1,2,3\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"InvoiceId", "Date", "Tags"}, columns)
+
+	columns, err = ReadHeader(strings.NewReader("Date,Cost"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Date", "Cost"}, columns)
+
+	_, err = ReadHeader(strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func TestSignature(t *testing.T) {
+	client, err := NewBlobClient("account", "container", "", azuriteAccountKey, "")
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, "https://account.blob.core.windows.net/container?restype=container&comp=list&prefix=exports%2F", nil)
+	require.NoError(t, err)
+	req.Header.Set("x-ms-date", "Sat, 03 Feb 2024 05:00:00 GMT")
+	req.Header.Set("x-ms-version", blobServiceVersion)
+	req.Header.Set("x-ms-range", "bytes=0-99")
+
+	stringToSign := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Sat, 03 Feb 2024 05:00:00 GMT\nx-ms-range:bytes=0-99\nx-ms-version:" + blobServiceVersion + "\n" +
+		"/account/container\ncomp:list\nprefix:exports/\nrestype:container"
+	key, err := base64.StdEncoding.DecodeString(azuriteAccountKey)
+	require.NoError(t, err)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	assert.Equal(t, base64.StdEncoding.EncodeToString(mac.Sum(nil)), client.signature(req))
+}
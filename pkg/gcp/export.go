@@ -0,0 +1,213 @@
+package gcp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ExportFormat is the file format of a billing export.
+type ExportFormat string
+
+const (
+	// ExportFormatJSON is newline delimited JSON, with a JSON object per
+	// row.
+	ExportFormatJSON ExportFormat = "JSON"
+	// ExportFormatCSV is CSV with a header and the nested fields of the
+	// export flattened into columns, such as service.description.
+	ExportFormatCSV ExportFormat = "CSV"
+	// ExportFormatParquet is Parquet with the nested fields of the export.
+	ExportFormatParquet ExportFormat = "Parquet"
+
+	// InvoiceMonthLayout is the layout of an invoice month, eg: 202401.
+	InvoiceMonthLayout = "200601"
+
+	// maxHeaderBytes is the amount of the first CSV file of an invoice
+	// month read to find its columns.
+	maxHeaderBytes = 64 * 1024
+)
+
+// invoiceMonthDirRegexp matches the name of the directory of an invoice
+// month, either 202401 or invoice_month=202401.
+var invoiceMonthDirRegexp = regexp.MustCompile(`^(?:invoice_month=)?(\d{6})$`)
+
+// InvoiceMonth is the directory containing the billing export files of an
+// invoice month.
+type InvoiceMonth struct {
+	// Month is the invoice month, eg: 202401.
+	Month string
+	// Directory is the directory containing the files of the month.
+	Directory string
+	Format    ExportFormat
+	// LastUpdated is the latest time a file of the month was updated.
+	LastUpdated time.Time
+	// Columns are the column names in the header of the CSV files, it's
+	// empty for the other formats.
+	Columns []string
+}
+
+type ExportRetriever interface {
+	RetrieveInvoiceMonths() ([]*InvoiceMonth, error)
+}
+
+type exportRetriever struct {
+	logger log.FieldLogger
+	client *StorageClient
+	prefix string
+}
+
+// NewExportRetriever returns an ExportRetriever for the billing export files
+// written under prefix.
+func NewExportRetriever(logger log.FieldLogger, client *StorageClient, prefix string) ExportRetriever {
+	return &exportRetriever{
+		logger: logger,
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// RetrieveInvoiceMonths returns the invoice months with files under the
+// prefix, sorted by month. The files of each month are expected in their own
+// directory, named after the month with or without a Hive style partition
+// key: <prefix>/<YYYYMM>/ or <prefix>/invoice_month=<YYYYMM>/. Files are
+// either JSON, CSV or Parquet, optionally gzip compressed, and the files of
+// a month must all have the same format.
+func (r *exportRetriever) RetrieveInvoiceMonths() ([]*InvoiceMonth, error) {
+	prefix := r.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	logger := r.logger.WithField("prefix", prefix)
+
+	objects, err := r.client.ListObjects(prefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Name < objects[j].Name
+	})
+
+	months := make(map[string]*InvoiceMonth)
+	firstObjects := make(map[string]string)
+	for _, object := range objects {
+		rel := strings.TrimPrefix(object.Name, prefix)
+		i := strings.Index(rel, "/")
+		if i < 0 {
+			logger.Debugf("ignoring object %s outside of an invoice month directory", object.Name)
+			continue
+		}
+		dir := rel[:i]
+		match := invoiceMonthDirRegexp.FindStringSubmatch(dir)
+		if match == nil {
+			logger.Debugf("ignoring object %s outside of an invoice month directory", object.Name)
+			continue
+		}
+		if _, err := time.Parse(InvoiceMonthLayout, match[1]); err != nil {
+			logger.Debugf("ignoring object %s in directory with invalid invoice month %s", object.Name, dir)
+			continue
+		}
+		format, ok := DetectFormat(object.Name)
+		if !ok {
+			logger.Debugf("ignoring object %s with unknown file format", object.Name)
+			continue
+		}
+
+		month, exists := months[match[1]]
+		if !exists {
+			month = &InvoiceMonth{
+				Month:     match[1],
+				Directory: prefix + dir,
+				Format:    format,
+			}
+			months[match[1]] = month
+			firstObjects[match[1]] = object.Name
+		} else if month.Directory != prefix+dir {
+			return nil, fmt.Errorf("invoice month %s has files in multiple directories: %s and %s", month.Month, month.Directory, prefix+dir)
+		} else if month.Format != format {
+			return nil, fmt.Errorf("invoice month %s has files of multiple formats: %s and %s", month.Month, month.Format, format)
+		}
+		if object.Updated.After(month.LastUpdated) {
+			month.LastUpdated = object.Updated
+		}
+	}
+
+	result := make([]*InvoiceMonth, 0, len(months))
+	for _, month := range months {
+		if month.Format == ExportFormatCSV {
+			month.Columns, err = r.retrieveColumns(firstObjects[month.Month])
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, month)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Month < result[j].Month
+	})
+	return result, nil
+}
+
+// retrieveColumns reads the header of a CSV file.
+func (r *exportRetriever) retrieveColumns(name string) ([]string, error) {
+	body, err := r.client.GetObject(name, maxHeaderBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var reader io.Reader = body
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress file %s: %v", name, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	columns, err := ReadHeader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the header of file %s: %v", name, err)
+	}
+	return columns, nil
+}
+
+// DetectFormat returns the format of a billing export file from the
+// extension of its name, ignoring a .gz extension.
+func DetectFormat(name string) (ExportFormat, bool) {
+	switch strings.ToLower(path.Ext(strings.TrimSuffix(name, ".gz"))) {
+	case ".json", ".jsonl", ".ndjson":
+		return ExportFormatJSON, true
+	case ".csv":
+		return ExportFormatCSV, true
+	case ".parquet":
+		return ExportFormatParquet, true
+	default:
+		return "", false
+	}
+}
+
+// ReadHeader returns the column names in the first line of CSV data.
+func ReadHeader(r io.Reader) ([]string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, err
+	}
+	line = strings.TrimPrefix(line, "\ufeff")
+	columns, err := csv.NewReader(strings.NewReader(line)).Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, col := range columns {
+		columns[i] = strings.TrimSpace(col)
+	}
+	return columns, nil
+}
@@ -0,0 +1,137 @@
+package gcp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+type fakeObject struct {
+	content []byte
+	updated time.Time
+}
+
+// newFakeGCSServer returns a server implementing the parts of the Cloud
+// Storage JSON API used by StorageClient, like fake-gcs-server.
+func newFakeGCSServer(t *testing.T, bucket string, objects map[string]fakeObject) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listPath := "/storage/v1/b/" + bucket + "/o"
+		if r.URL.Path == listPath {
+			prefix := r.URL.Query().Get("prefix")
+			var names []string
+			for name := range objects {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			// return the objects in two pages
+			nextPageToken := ""
+			if r.URL.Query().Get("pageToken") == "" {
+				names, nextPageToken = names[:len(names)/2], "page-2"
+			} else {
+				names = names[len(names)/2:]
+			}
+			type item struct {
+				Name    string    `json:"name"`
+				Size    string    `json:"size"`
+				Updated time.Time `json:"updated"`
+			}
+			result := struct {
+				Items         []item `json:"items"`
+				NextPageToken string `json:"nextPageToken,omitempty"`
+			}{NextPageToken: nextPageToken}
+			for _, name := range names {
+				result.Items = append(result.Items, item{Name: name, Size: fmt.Sprint(len(objects[name].content)), Updated: objects[name].updated})
+			}
+			require.NoError(t, json.NewEncoder(w).Encode(result))
+			return
+		}
+		assert.Equal(t, "media", r.URL.Query().Get("alt"))
+		object, ok := objects[strings.TrimPrefix(r.URL.Path, listPath+"/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "No such object"}}`)
+			return
+		}
+		w.Write(object.content)
+	}))
+}
+
+func TestExportRetrieverRetrieveInvoiceMonths(t *testing.T) {
+	jan := time.Date(2024, time.February, 2, 5, 0, 0, 0, time.UTC)
+	objects := map[string]fakeObject{
+		"billing/202401/export-000.json.gz":                  {gzipped(t, `{"cost": 1.5}`), jan.Add(-time.Hour)},
+		"billing/202401/export-001.json.gz":                  {gzipped(t, `{"cost": 2.5}`), jan},
+		"billing/invoice_month=202402/export-000.csv.gz":     {gzipped(t, "billing_account_id,service.description,cost\n012345,Compute Engine,1.5\n"), jan},
+		"billing/invoice_month=202403/export-000.parquet":    {[]byte("PAR1"), jan},
+		"billing/invoice_month=202403/_SUCCESS":              {nil, jan},
+		"billing/README.md":                                  {[]byte("readme"), jan},
+		"billing/other/export-000.json":                      {[]byte(`{}`), jan},
+		"unrelated/202401/export-000.json":                   {[]byte(`{}`), jan},
+		"billing/invoice_month=202313/export-000.parquet":    {[]byte("PAR1"), jan},
+		"billing/invoice_month=202404/export 000 (1).csv.gz": {gzipped(t, "\ufeffcost\n1.5"), jan},
+	}
+	server := newFakeGCSServer(t, "exports", objects)
+	defer server.Close()
+
+	client, err := NewStorageClient(context.Background(), "exports", server.URL, nil, "")
+	require.NoError(t, err)
+	months, err := NewExportRetriever(logrus.New(), client, "billing").RetrieveInvoiceMonths()
+	require.NoError(t, err)
+
+	assert.Equal(t, []*InvoiceMonth{
+		{Month: "202401", Directory: "billing/202401", Format: ExportFormatJSON, LastUpdated: jan},
+		{Month: "202402", Directory: "billing/invoice_month=202402", Format: ExportFormatCSV, LastUpdated: jan, Columns: []string{"billing_account_id", "service.description", "cost"}},
+		{Month: "202403", Directory: "billing/invoice_month=202403", Format: ExportFormatParquet, LastUpdated: jan},
+		{Month: "202404", Directory: "billing/invoice_month=202404", Format: ExportFormatCSV, LastUpdated: jan, Columns: []string{"cost"}},
+	}, months)
+
+	objects["billing/202401/export-002.csv"] = fakeObject{[]byte("cost\n"), jan}
+	_, err = NewExportRetriever(logrus.New(), client, "billing/").RetrieveInvoiceMonths()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invoice month 202401 has files of multiple formats")
+	}
+
+	_, err = client.GetObject("missing.json", 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "404 Not Found: No such object")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for name, expected := range map[string]ExportFormat{
+		"export-000.json":       ExportFormatJSON,
+		"export-000.jsonl.gz":   ExportFormatJSON,
+		"export-000.CSV":        ExportFormatCSV,
+		"export-000.csv.gz":     ExportFormatCSV,
+		"part-0.snappy.parquet": ExportFormatParquet,
+	} {
+		format, ok := DetectFormat(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, format, name)
+	}
+	_, ok := DetectFormat("_SUCCESS")
+	assert.False(t, ok)
+}
@@ -0,0 +1,208 @@
+package gcp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// defaultStorageEndpoint is the URL of the Cloud Storage JSON API.
+	defaultStorageEndpoint = "https://storage.googleapis.com"
+
+	// storageReadOnlyScope is the OAuth scope used to read objects.
+	storageReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+)
+
+// StorageClient reads the objects of a Cloud Storage bucket using the JSON
+// API.
+type StorageClient struct {
+	httpClient *http.Client
+	endpoint   string
+	bucket     string
+}
+
+// Object is an object returned when listing a bucket.
+type Object struct {
+	Name    string
+	Size    int64
+	Updated time.Time
+}
+
+// NewStorageClient returns a client for bucket. Requests are authenticated
+// using the service account key in serviceAccountKeyJSON if it's set, and
+// otherwise using the application default credentials, such as those of
+// Workload Identity. If endpoint is set, such as to the URL of a fake GCS
+// server, requests are anonymous unless a service account key is set. If
+// caBundlePath is set, the CA bundle is trusted in addition to the system
+// roots.
+func NewStorageClient(ctx context.Context, bucket, endpoint string, serviceAccountKeyJSON []byte, caBundlePath string) (*StorageClient, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("a bucket is required")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the trusted CA bundle: %v", err)
+		}
+		caRoot, err := x509.SystemCertPool()
+		if err != nil {
+			caRoot = x509.NewCertPool()
+		}
+		caRoot.AppendCertsFromPEM(caBundle)
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: caRoot,
+		}
+	}
+	baseClient := &http.Client{
+		Transport: transport,
+		Timeout:   time.Second * 60,
+	}
+
+	httpClient := baseClient
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, baseClient)
+	switch {
+	case len(serviceAccountKeyJSON) != 0:
+		creds, err := google.CredentialsFromJSON(ctx, serviceAccountKeyJSON, storageReadOnlyScope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service account key: %v", err)
+		}
+		httpClient = oauth2.NewClient(ctx, creds.TokenSource)
+	case endpoint == "":
+		tokenSource, err := google.DefaultTokenSource(ctx, storageReadOnlyScope)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find default credentials: %v", err)
+		}
+		httpClient = oauth2.NewClient(ctx, tokenSource)
+	}
+	httpClient.Timeout = baseClient.Timeout
+
+	if endpoint == "" {
+		endpoint = defaultStorageEndpoint
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Cloud Storage endpoint %q: %v", endpoint, err)
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid Cloud Storage endpoint %q: scheme must be http or https", endpoint)
+	}
+	return &StorageClient{
+		httpClient: httpClient,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		bucket:     bucket,
+	}, nil
+}
+
+type listObjectsResponse struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"`
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+type storageError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ListObjects returns every object in the bucket with a name beginning with
+// prefix.
+func (c *StorageClient) ListObjects(prefix string) ([]Object, error) {
+	var (
+		objects   []Object
+		pageToken string
+	)
+	for {
+		query := url.Values{}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		resp, err := c.do(fmt.Sprintf("%s/storage/v1/b/%s/o?%s", c.endpoint, url.PathEscape(c.bucket), query.Encode()), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects in bucket %s with prefix %q: %v", c.bucket, prefix, err)
+		}
+		var result listObjectsResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the objects in bucket %s: %v", c.bucket, err)
+		}
+		for _, item := range result.Items {
+			// sizes are returned as strings since they're 64 bit integers
+			size, err := strconv.ParseInt(item.Size, 10, 64)
+			if err != nil && item.Size != "" {
+				return nil, fmt.Errorf("invalid size %q of object %s: %v", item.Size, item.Name, err)
+			}
+			objects = append(objects, Object{
+				Name:    item.Name,
+				Size:    size,
+				Updated: item.Updated,
+			})
+		}
+		if result.NextPageToken == "" {
+			return objects, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
+
+// GetObject returns the content of an object. If length is greater than
+// zero, only the first length bytes of the object are returned. The caller
+// must close the returned reader.
+func (c *StorageClient) GetObject(name string, length int64) (io.ReadCloser, error) {
+	headers := http.Header{}
+	if length > 0 {
+		headers.Set("Range", fmt.Sprintf("bytes=0-%d", length-1))
+	}
+	resp, err := c.do(fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", c.endpoint, url.PathEscape(c.bucket), url.PathEscape(name)), headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s from bucket %s: %v", name, c.bucket, err)
+	}
+	return resp.Body, nil
+}
+
+// do sends a GET request and returns an error if the response isn't
+// successful.
+func (c *StorageClient) do(rawURL string, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var storageErr storageError
+		if err := json.NewDecoder(resp.Body).Decode(&storageErr); err != nil || storageErr.Error.Message == "" {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, storageErr.Error.Message)
+	}
+	return resp, nil
+}
@@ -0,0 +1,191 @@
+package hdfs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultWebHDFSPort is the port of the HTTP server of the namenode,
+	// which serves the WebHDFS API.
+	DefaultWebHDFSPort = "9870"
+
+	fileTypeFile      = "FILE"
+	fileTypeDirectory = "DIRECTORY"
+)
+
+// Client lists the files of HDFS using the WebHDFS REST API of the
+// namenode.
+type Client struct {
+	httpClient *http.Client
+	endpoint   string
+	user       string
+}
+
+// File is a file returned when listing a directory.
+type File struct {
+	// Path is the path of the file relative to the listed directory.
+	Path             string
+	Length           int64
+	ModificationTime time.Time
+}
+
+// NewClient returns a client for the WebHDFS API at endpoint, such as
+// http://hdfs-namenode-0.hdfs-namenode:9870. If user is set, requests are
+// made as user using pseudo authentication. If caBundlePath is set, the CA
+// bundle is trusted in addition to the system roots.
+func NewClient(endpoint, user, caBundlePath string) (*Client, error) {
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebHDFS endpoint %q: %v", endpoint, err)
+	}
+	if endpointURL.Scheme != "http" && endpointURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid WebHDFS endpoint %q: scheme must be http or https", endpoint)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caBundlePath != "" {
+		caBundle, err := ioutil.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the trusted CA bundle: %v", err)
+		}
+		caRoot, err := x509.SystemCertPool()
+		if err != nil {
+			caRoot = x509.NewCertPool()
+		}
+		caRoot.AppendCertsFromPEM(caBundle)
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs: caRoot,
+		}
+	}
+	return &Client{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   time.Second * 60,
+		},
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		user:     user,
+	}, nil
+}
+
+type listStatusResponse struct {
+	FileStatuses struct {
+		FileStatus []struct {
+			PathSuffix string `json:"pathSuffix"`
+			Type       string `json:"type"`
+			Length     int64  `json:"length"`
+			// ModificationTime is in milliseconds since the epoch.
+			ModificationTime int64 `json:"modificationTime"`
+		} `json:"FileStatus"`
+	} `json:"FileStatuses"`
+}
+
+type remoteExceptionResponse struct {
+	RemoteException struct {
+		Exception string `json:"exception"`
+		Message   string `json:"message"`
+	} `json:"RemoteException"`
+}
+
+// notFoundError is returned when a path doesn't exist.
+type notFoundError struct {
+	message string
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+// ListFiles returns every file in dir and its subdirectories. No files are
+// returned if dir doesn't exist, since nothing may have been written to it
+// yet.
+func (c *Client) ListFiles(dir string) ([]File, error) {
+	dir = "/" + strings.Trim(dir, "/")
+	files, err := c.listFiles(dir, "")
+	if _, ok := err.(*notFoundError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the files in %s: %v", dir, err)
+	}
+	return files, nil
+}
+
+func (c *Client) listFiles(dir, relativeDir string) ([]File, error) {
+	statuses, err := c.listStatus(path.Join(dir, relativeDir))
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, status := range statuses.FileStatuses.FileStatus {
+		relativePath := path.Join(relativeDir, status.PathSuffix)
+		switch status.Type {
+		case fileTypeFile:
+			files = append(files, File{
+				Path:             relativePath,
+				Length:           status.Length,
+				ModificationTime: time.Unix(0, status.ModificationTime*int64(time.Millisecond)).UTC(),
+			})
+		case fileTypeDirectory:
+			dirFiles, err := c.listFiles(dir, relativePath)
+			// the directory was deleted after listing its parent
+			if _, ok := err.(*notFoundError); ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+		}
+	}
+	return files, nil
+}
+
+func (c *Client) listStatus(dir string) (*listStatusResponse, error) {
+	query := url.Values{}
+	query.Set("op", "LISTSTATUS")
+	if c.user != "" {
+		query.Set("user.name", c.user)
+	}
+	rawURL := fmt.Sprintf("%s/webhdfs/v1%s?%s", c.endpoint, (&url.URL{Path: dir}).EscapedPath(), query.Encode())
+	resp, err := c.httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var remoteErr remoteExceptionResponse
+		if err := json.NewDecoder(resp.Body).Decode(&remoteErr); err != nil || remoteErr.RemoteException.Message == "" {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		if remoteErr.RemoteException.Exception == "FileNotFoundException" {
+			return nil, &notFoundError{message: remoteErr.RemoteException.Message}
+		}
+		return nil, fmt.Errorf("unexpected status %s: %s: %s", resp.Status, remoteErr.RemoteException.Exception, remoteErr.RemoteException.Message)
+	}
+	var result listStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode the status of the files in %s: %v", dir, err)
+	}
+	return &result, nil
+}
+
+// WebHDFSEndpoint returns the default URL of the WebHDFS API of the namenode
+// of the hdfs:// URI location.
+func WebHDFSEndpoint(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "hdfs" || u.Hostname() == "" {
+		return "", fmt.Errorf("%q is not an hdfs:// URI with a namenode", location)
+	}
+	return fmt.Sprintf("http://%s:%s", u.Hostname(), DefaultWebHDFSPort), nil
+}
@@ -0,0 +1,101 @@
+package hdfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeNamenode returns a server implementing the LISTSTATUS operation of
+// the WebHDFS API for files, which maps the paths of files to their length.
+func newFakeNamenode(t *testing.T, files map[string]int64, modified time.Time) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "LISTSTATUS", r.URL.Query().Get("op"))
+		assert.Equal(t, "metering", r.URL.Query().Get("user.name"))
+		dir := strings.TrimPrefix(r.URL.Path, "/webhdfs/v1")
+
+		type status struct {
+			PathSuffix       string `json:"pathSuffix"`
+			Type             string `json:"type"`
+			Length           int64  `json:"length"`
+			ModificationTime int64  `json:"modificationTime"`
+		}
+		var statuses []status
+		seen := make(map[string]bool)
+		for name, length := range files {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(name, dir+"/"), "/", 2)
+			if seen[parts[0]] {
+				continue
+			}
+			seen[parts[0]] = true
+			if len(parts) == 2 {
+				statuses = append(statuses, status{PathSuffix: parts[0], Type: "DIRECTORY"})
+			} else {
+				statuses = append(statuses, status{PathSuffix: parts[0], Type: "FILE", Length: length, ModificationTime: modified.UnixNano() / int64(time.Millisecond)})
+			}
+		}
+		if len(statuses) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"RemoteException": {"exception": "FileNotFoundException", "javaClassName": "java.io.FileNotFoundException", "message": "File %s does not exist."}}`, dir)
+			return
+		}
+		var result struct {
+			FileStatuses struct {
+				FileStatus []status `json:"FileStatus"`
+			} `json:"FileStatuses"`
+		}
+		result.FileStatuses.FileStatus = statuses
+		require.NoError(t, json.NewEncoder(w).Encode(result))
+	}))
+}
+
+func TestClientListFiles(t *testing.T) {
+	modified := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+	server := newFakeNamenode(t, map[string]int64{
+		"/filedrop/licenses/2024/01/licenses.csv": 10,
+		"/filedrop/licenses/2024/02/licenses.csv": 20,
+		"/filedrop/licenses/README":               5,
+		"/filedrop/other/costs.csv":               30,
+	}, modified)
+	defer server.Close()
+
+	client, err := NewClient(server.URL, "metering", "")
+	require.NoError(t, err)
+
+	files, err := client.ListFiles("/filedrop/licenses/")
+	require.NoError(t, err)
+	byPath := make(map[string]File)
+	for _, f := range files {
+		byPath[f.Path] = f
+	}
+	assert.Equal(t, map[string]File{
+		"2024/01/licenses.csv": {Path: "2024/01/licenses.csv", Length: 10, ModificationTime: modified},
+		"2024/02/licenses.csv": {Path: "2024/02/licenses.csv", Length: 20, ModificationTime: modified},
+		"README":               {Path: "README", Length: 5, ModificationTime: modified},
+	}, byPath)
+
+	files, err = client.ListFiles("/filedrop/missing")
+	require.NoError(t, err, "a missing directory has no files")
+	assert.Empty(t, files)
+}
+
+func TestWebHDFSEndpoint(t *testing.T) {
+	endpoint, err := WebHDFSEndpoint("hdfs://hdfs-namenode-0.hdfs-namenode:9820/filedrop/licenses")
+	require.NoError(t, err)
+	assert.Equal(t, "http://hdfs-namenode-0.hdfs-namenode:9870", endpoint)
+
+	_, err = WebHDFSEndpoint("s3a://bucket/filedrop")
+	assert.Error(t, err)
+	_, err = WebHDFSEndpoint("/filedrop")
+	assert.Error(t, err)
+}
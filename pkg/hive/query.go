@@ -2,6 +2,7 @@ package hive
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -69,11 +70,11 @@ func generateCreateTableSQL(params TableParameters, ignoreExists bool) string {
 	}
 	location := ""
 	if params.Location != "" {
-		location = fmt.Sprintf(`LOCATION "%s"`, params.Location)
+		location = fmt.Sprintf("LOCATION %s", quoteString(params.Location))
 	}
 	tblProps := ""
-	if params.TableProperties != nil {
-		tblProps = fmt.Sprintf("TBLPROPERTIES(%s)", params.TableProperties)
+	if len(params.TableProperties) != 0 {
+		tblProps = fmt.Sprintf("TBLPROPERTIES (%s)", generateTablePropertiesSQL(params.TableProperties))
 	}
 	return fmt.Sprintf(
 		`CREATE %s TABLE %s
@@ -89,6 +90,13 @@ func generateCreateTableSQL(params TableParameters, ignoreExists bool) string {
 	)
 }
 
+func generateAddColumnsSQL(database, tableName string, columns []Column) string {
+	if database != "" {
+		tableName = fmt.Sprintf("%s.%s", database, tableName)
+	}
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMNS (%s)", tableName, generateColumnListSQL(columns))
+}
+
 func generateCreateDatabaseSQL(params DatabaseParameters, ignoreExists bool) string {
 	ignoreExistsStr := ""
 	if params.Location != "" {
@@ -96,7 +104,7 @@ func generateCreateDatabaseSQL(params DatabaseParameters, ignoreExists bool) str
 	}
 	locStr := ""
 	if params.Location != "" {
-		locStr = fmt.Sprintf("LOCATION '%s'", params.Location)
+		locStr = fmt.Sprintf("LOCATION %s", quoteString(params.Location))
 	}
 	return fmt.Sprintf(
 		`CREATE DATABASE
@@ -131,6 +139,32 @@ func generateColumnListSQL(columns []Column) string {
 	return strings.Join(c, ",")
 }
 
+// generateTablePropertiesSQL returns the key/value pairs of a Hive
+// TBLPROPERTIES clause sorted by key. For example, "'key'='value'".
+func generateTablePropertiesSQL(properties map[string]string) string {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	props := make([]string, len(keys))
+	for i, key := range keys {
+		props[i] = fmt.Sprintf("%s=%s", quoteString(key), quoteString(properties[key]))
+	}
+	return strings.Join(props, ",")
+}
+
+// QuoteString returns s as a Hive string literal, for values such as
+// partition values and locations which aren't known to be safe.
+func QuoteString(s string) string {
+	return quoteString(s)
+}
+
+// quoteString returns s as a Hive string literal.
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
 func generateColumnNoTypesListSQL(columns []string) string {
 	c := make([]string, len(columns))
 	for i, col := range columns {
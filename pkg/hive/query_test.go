@@ -0,0 +1,27 @@
+package hive
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateCreateTableSQLTableProperties(t *testing.T) {
+	query := generateCreateTableSQL(TableParameters{
+		Database: "metering",
+		Name:     "costs",
+		Columns:  []Column{{Name: "cost", Type: "string"}},
+		TableProperties: map[string]string{
+			"skip.header.line.count": "1",
+			"comment":                "it's a \\ table",
+		},
+	}, true)
+	assert.True(t, strings.HasSuffix(query, `TBLPROPERTIES ('comment'='it\'s a \\ table','skip.header.line.count'='1')`), "unexpected query: %s", query)
+
+	query = generateCreateTableSQL(TableParameters{
+		Name:    "costs",
+		Columns: []Column{{Name: "cost", Type: "string"}},
+	}, false)
+	assert.NotContains(t, query, "TBLPROPERTIES")
+}
@@ -52,6 +52,15 @@ func ExecuteDropTable(execer db.Execer, dbName, tableName string, ignoreNotExist
 	return err
 }
 
+// ExecuteAddColumns appends the given columns to an existing table. Hive
+// adds the new columns after the existing columns, but before any partition
+// columns.
+func ExecuteAddColumns(execer db.Execer, dbName, tableName string, columns []Column) error {
+	query := generateAddColumnsSQL(dbName, tableName, columns)
+	_, err := execer.Exec(query)
+	return err
+}
+
 // s3Location returns the HDFS path based on an S3 bucket and prefix.
 func S3Location(bucket, prefix string) (string, error) {
 	bucket = path.Join(bucket, prefix)
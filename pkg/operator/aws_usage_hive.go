@@ -3,17 +3,24 @@ package operator
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
 
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	meteringUtil "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1/util"
 	"github.com/kube-reporting/metering-operator/pkg/aws"
 	"github.com/kube-reporting/metering-operator/pkg/hive"
 	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 const (
@@ -34,19 +41,138 @@ WITH SERDEPROPERTIES (
 )
 
 var (
+	// awsHiveColumnInvalidCharsRegexp matches the characters of the names of
+	// the columns of AWS reports which aren't allowed in Hive identifiers.
+	awsHiveColumnInvalidCharsRegexp = regexp.MustCompile(`[^a-z0-9_]`)
+	// hiveDecimalTypeRegexp matches Hive decimal types with a precision and
+	// scale.
+	hiveDecimalTypeRegexp = regexp.MustCompile(`^decimal\(\d{1,2},\d{1,2}\)$`)
+
 	AWSUsageHivePartitions = []hive.Column{
 		{Name: billingPeriodStartPartitionColumnName, Type: "string"},
 		{Name: billingPeriodEndPartitionColumnName, Type: "string"},
 	}
 )
 
-// CreateAWSUsageTable instantiates a new external HiveTable CR for AWS Billing/Usage reports stored in S3.
-func (op *defaultReportingOperator) createAWSUsageHiveTableCR(logger logrus.FieldLogger, dataSource *metering.ReportDataSource, tableName, bucket, prefix string, manifests []*aws.Manifest) (*metering.HiveTable, error) {
-	location, err := hive.S3Location(bucket, prefix)
-	if err != nil {
-		return nil, err
+// getAWSBillingFormat returns the format of the reports of dataSource. The
+// format of an existing table can't change, since its columns depend on it,
+// and tables created before formats were supported are CSV tables. New
+// tables use spec.awsBilling.format, or the format of the newest manifest if
+// it's unset.
+func getAWSBillingFormat(dataSource *metering.ReportDataSource, manifests []*aws.Manifest) (aws.ManifestFormat, error) {
+	specFormat := aws.ManifestFormat(dataSource.Spec.AWSBilling.Format)
+	switch specFormat {
+	case "", aws.ManifestFormatCSV, aws.ManifestFormatParquet, aws.ManifestFormatCUR2:
+	default:
+		return "", fmt.Errorf("invalid spec.awsBilling.format %q, must be one of %s, %s or %s", specFormat, aws.ManifestFormatCSV, aws.ManifestFormatParquet, aws.ManifestFormatCUR2)
+	}
+
+	var tableFormat aws.ManifestFormat
+	if status := dataSource.Status.AWSBilling; status != nil && status.Format != "" {
+		tableFormat = aws.ManifestFormat(status.Format)
+	} else if dataSource.Status.TableRef.Name != "" {
+		tableFormat = aws.ManifestFormatCSV
+	}
+	if tableFormat != "" {
+		if specFormat != "" && specFormat != tableFormat {
+			return "", fmt.Errorf("the table of ReportDataSource %s was created for %s reports, and must be deleted to use %s reports", dataSource.Name, tableFormat, specFormat)
+		}
+		return tableFormat, nil
+	}
+	if specFormat != "" {
+		return specFormat, nil
+	}
+
+	var newest *aws.Manifest
+	for _, manifest := range manifests {
+		if newest == nil || manifest.BillingPeriod.Start.After(newest.BillingPeriod.Start.Time) {
+			newest = manifest
+		}
+	}
+	if newest == nil {
+		return aws.ManifestFormatCSV, nil
+	}
+	return newest.Format(), nil
+}
+
+const (
+	awsAccessKeyIDSecretKey     = "aws-access-key-id"
+	awsSecretAccessKeySecretKey = "aws-secret-access-key"
+	awsSessionTokenSecretKey    = "aws-session-token"
+)
+
+// getAWSBillingS3Options returns the options used to access the bucket of
+// dataSource, reading the credentials in spec.awsBilling.credentials.secretRef
+// if it's set.
+func (op *defaultReportingOperator) getAWSBillingS3Options(dataSource *metering.ReportDataSource) (aws.S3Options, error) {
+	return op.getS3Options(dataSource.Namespace, dataSource.Spec.AWSBilling.Source, dataSource.Spec.AWSBilling.Credentials, "spec.awsBilling.credentials")
+}
+
+// getS3Options returns the options used to access source with creds, which
+// are configured by the credentials field at fieldPath of a resource in
+// namespace.
+func (op *defaultReportingOperator) getS3Options(namespace string, source *metering.S3Bucket, creds *metering.AWSCredentials, fieldPath string) (aws.S3Options, error) {
+	opts := aws.S3Options{
+		Endpoint:       source.Endpoint,
+		ForcePathStyle: source.ForcePathStyle,
+	}
+	if creds == nil {
+		return opts, nil
+	}
+	if creds.SecretRef != nil && creds.WebIdentity != nil {
+		return opts, fmt.Errorf("only one of %[1]s.secretRef and %[1]s.webIdentity may be set", fieldPath)
+	}
+	if creds.SecretRef != nil {
+		secret, err := op.kubeClient.Secrets(namespace).Get(context.TODO(), creds.SecretRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return opts, fmt.Errorf("unable to get Secret %s: %v", creds.SecretRef.Name, err)
+		}
+		for _, key := range []string{awsAccessKeyIDSecretKey, awsSecretAccessKeySecretKey} {
+			if len(secret.Data[key]) == 0 {
+				return opts, fmt.Errorf("Secret %s has no key %s", creds.SecretRef.Name, key)
+			}
+		}
+		opts.Credentials.AccessKeyID = strings.TrimSpace(string(secret.Data[awsAccessKeyIDSecretKey]))
+		opts.Credentials.SecretAccessKey = strings.TrimSpace(string(secret.Data[awsSecretAccessKeySecretKey]))
+		opts.Credentials.SessionToken = strings.TrimSpace(string(secret.Data[awsSessionTokenSecretKey]))
+	}
+	if creds.WebIdentity != nil {
+		// the token is never read from a path in the resource, since that
+		// would let anyone able to create ReportDataSources read any file
+		// of the reporting-operator
+		if op.cfg.AWSWebIdentityTokenFile == "" {
+			return opts, fmt.Errorf("%s.webIdentity requires the reporting-operator to be configured with a web identity token file", fieldPath)
+		}
+		opts.Credentials.WebIdentityRoleARN = creds.WebIdentity.RoleARN
+		opts.Credentials.WebIdentityTokenFile = op.cfg.AWSWebIdentityTokenFile
+	}
+	if creds.AssumeRole != nil {
+		opts.Credentials.AssumeRoleARN = creds.AssumeRole.RoleARN
+		opts.Credentials.AssumeRoleExternalID = creds.AssumeRole.ExternalID
+		opts.Credentials.AssumeRoleSessionName = creds.AssumeRole.SessionName
+	}
+	return opts, nil
+}
+
+// filterAWSManifestsByFormat returns the manifests of reports in format,
+// since the reports of other formats can't be read by the same table.
+func filterAWSManifestsByFormat(logger log.FieldLogger, manifests []*aws.Manifest, format aws.ManifestFormat) []*aws.Manifest {
+	var filtered []*aws.Manifest
+	for _, manifest := range manifests {
+		if manifest.Format() != format {
+			logger.Warnf("ignoring %s report manifest for billing period %s, the table of the ReportDataSource is for %s reports", manifest.Format(), manifest.BillingPeriod.Start.String(), format)
+			continue
+		}
+		filtered = append(filtered, manifest)
 	}
+	return filtered
+}
 
+// AWSManifestHiveColumns returns the columns of a table for the reports of
+// manifests, which are in format. schemaTypes are the Hive types of the
+// columns of the Parquet report data files by column name, which are used for
+// the columns of Parquet and CUR 2.0 reports without a type in the manifests.
+func AWSManifestHiveColumns(format aws.ManifestFormat, manifests []*aws.Manifest, schemaTypes map[string]string) []hive.Column {
 	// Since the billing data likely exists already, we need to enumerate all
 	// columns for all manifests to get the entire set of columns used
 	// historically.
@@ -56,18 +182,86 @@ func (op *defaultReportingOperator) createAWSUsageHiveTableCR(logger logrus.Fiel
 	seen := make(map[string]struct{})
 	for _, manifest := range manifests {
 		for _, c := range manifest.Columns {
-			name := SanetizeAWSColumnForHive(c)
-			colType := AWSColumnToHiveColumnType(c)
-
-			if _, exists := seen[name]; !exists {
-				seen[name] = struct{}{}
-				columns = append(columns, hive.Column{
-					Name: name,
-					Type: colType,
-				})
+			var col hive.Column
+			switch format {
+			case aws.ManifestFormatParquet:
+				col = hive.Column{Name: AWSParquetColumnName(c), Type: AWSParquetColumnToHiveColumnType(c, schemaTypes)}
+			case aws.ManifestFormatCUR2:
+				col = hive.Column{Name: AWSCUR2ColumnName(c), Type: AWSCUR2ColumnToHiveColumnType(c, schemaTypes)}
+			default:
+				col = hive.Column{Name: SanetizeAWSColumnForHive(c), Type: AWSColumnToHiveColumnType(c)}
+			}
+			if col.Name == "" {
+				continue
+			}
+
+			if _, exists := seen[col.Name]; !exists {
+				seen[col.Name] = struct{}{}
+				columns = append(columns, col)
 			}
 		}
 	}
+	return columns
+}
+
+// awsManifestsMissingColumnTypes returns true if any of the columns of
+// manifests have no type.
+func awsManifestsMissingColumnTypes(manifests []*aws.Manifest) bool {
+	for _, manifest := range manifests {
+		for _, c := range manifest.Columns {
+			if strings.TrimSpace(c.Type) == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getAWSParquetSchemaTypes returns the Hive types of the columns of the
+// Parquet report data files of manifests, which are read from the schema of
+// the first data file of the newest report, by sanitized column name. It
+// returns nil if the reports aren't Parquet files, or every column has a type
+// in the manifests, and types which can't be read are guessed from the names
+// of the columns instead.
+func getAWSParquetSchemaTypes(logger log.FieldLogger, retriever aws.ManifestRetriever, format aws.ManifestFormat, manifests []*aws.Manifest) map[string]string {
+	if format != aws.ManifestFormatParquet && format != aws.ManifestFormatCUR2 {
+		return nil
+	}
+	if !awsManifestsMissingColumnTypes(manifests) {
+		return nil
+	}
+	var newest *aws.Manifest
+	for _, manifest := range manifests {
+		if len(manifest.DataKeys()) == 0 {
+			continue
+		}
+		if newest == nil || manifest.BillingPeriod.Start.After(newest.BillingPeriod.Start.Time) {
+			newest = manifest
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+	key := newest.DataKeys()[0]
+	schema, err := retriever.RetrieveParquetSchema(key)
+	if err != nil {
+		logger.WithError(err).Warnf("unable to read the schema of report data file %s, the types of columns without a type in the report manifests will be guessed from their names", key)
+		return nil
+	}
+	types := make(map[string]string)
+	for name, hiveType := range parquet.HiveColumnTypes(schema) {
+		types[sanitizeAWSHiveColumnName(name)] = hiveType
+	}
+	return types
+}
+
+// CreateAWSUsageTable instantiates a new external HiveTable CR for AWS Billing/Usage reports stored in S3.
+func (op *defaultReportingOperator) createAWSUsageHiveTableCR(logger logrus.FieldLogger, dataSource *metering.ReportDataSource, tableName, bucket, prefix string, format aws.ManifestFormat, manifests []*aws.Manifest, schemaTypes map[string]string) (*metering.HiveTable, error) {
+	location, err := hive.S3Location(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	columns := AWSManifestHiveColumns(format, manifests, schemaTypes)
 
 	var dbName string
 	if dataSource.Spec.AWSBilling.DatabaseName == "" {
@@ -98,6 +292,10 @@ func (op *defaultReportingOperator) createAWSUsageHiveTableCR(logger logrus.Fiel
 		RowFormat:     AWSUsageHiveRowFormat,
 		External:      true,
 	}
+	if format == aws.ManifestFormatParquet || format == aws.ManifestFormatCUR2 {
+		params.FileFormat = "parquet"
+		params.RowFormat = ""
+	}
 
 	logger.Infof("creating Hive table %s", tableName)
 	hiveTable, err := op.createHiveTableCR(dataSource, metering.ReportDataSourceGVK, params, true, nil)
@@ -165,14 +363,182 @@ func getDesiredPartitions(bucket string, manifests []*aws.Manifest) ([]metering.
 	return desiredPartitions, nil
 }
 
+// maxAWSBillingRestatements is the number of restatements kept in the
+// status of an AWSBilling ReportDataSource.
+const maxAWSBillingRestatements = 50
+
+// awsManifestAssemblyID identifies the version of the report of a billing
+// period a manifest describes. CUR 2.0 manifests have an executionId
+// instead of an assemblyId.
+func awsManifestAssemblyID(manifest *aws.Manifest) string {
+	if manifest.AssemblyID != "" {
+		return manifest.AssemblyID
+	}
+	return manifest.ExecutionID
+}
+
+// getAWSBillingPeriodStatuses returns the assembly of each billing period in
+// manifests, ordered by the start of the billing period.
+func getAWSBillingPeriodStatuses(manifests []*aws.Manifest) []metering.AWSBillingPeriodStatus {
+	periods := make([]metering.AWSBillingPeriodStatus, 0, len(manifests))
+	for _, manifest := range manifests {
+		periods = append(periods, metering.AWSBillingPeriodStatus{
+			Start:      metav1.NewTime(manifest.BillingPeriod.Start.Time.UTC()),
+			End:        metav1.NewTime(manifest.BillingPeriod.End.Time.UTC()),
+			AssemblyID: awsManifestAssemblyID(manifest),
+		})
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Time.Before(periods[j].Start.Time)
+	})
+	return periods
+}
+
+// awsBillingPeriodsEqual returns true if a and b record the same assemblies
+// for the same billing periods.
+func awsBillingPeriodsEqual(a, b []metering.AWSBillingPeriodStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(&b[i].Start) || !a[i].End.Equal(&b[i].End) || a[i].AssemblyID != b[i].AssemblyID {
+			return false
+		}
+	}
+	return true
+}
+
+// detectAWSBillingRestatements compares the assembly of each billing period
+// in periods with the assembly previously recorded for it, returning a
+// restatement for each billing period AWS regenerated the report of.
+func detectAWSBillingRestatements(previous, periods []metering.AWSBillingPeriodStatus, now time.Time) []metering.AWSBillingRestatement {
+	previousAssemblies := make(map[time.Time]string, len(previous))
+	for _, p := range previous {
+		previousAssemblies[p.Start.Time.UTC()] = p.AssemblyID
+	}
+	var restatements []metering.AWSBillingRestatement
+	for _, p := range periods {
+		previousAssemblyID, ok := previousAssemblies[p.Start.Time.UTC()]
+		if !ok || previousAssemblyID == "" || p.AssemblyID == "" || previousAssemblyID == p.AssemblyID {
+			continue
+		}
+		restatements = append(restatements, metering.AWSBillingRestatement{
+			BillingPeriodStart: p.Start,
+			BillingPeriodEnd:   p.End,
+			PreviousAssemblyID: previousAssemblyID,
+			AssemblyID:         p.AssemblyID,
+			DetectedTime:       metav1.NewTime(now),
+		})
+	}
+	return restatements
+}
+
+// updateAWSBillingStatus records the assembly of each billing period of
+// dataSource, and appends restatements to its restatement history.
+func (op *defaultReportingOperator) updateAWSBillingStatus(dataSource *metering.ReportDataSource, periods []metering.AWSBillingPeriodStatus, restatements []metering.AWSBillingRestatement) (*metering.ReportDataSource, error) {
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	return updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		if newDS.Status.AWSBilling == nil {
+			newDS.Status.AWSBilling = &metering.AWSBillingDataSourceStatus{}
+		}
+		status := newDS.Status.AWSBilling
+		status.BillingPeriods = periods
+		status.Restatements = append(status.Restatements, restatements...)
+		if len(status.Restatements) > maxAWSBillingRestatements {
+			status.Restatements = status.Restatements[len(status.Restatements)-maxAWSBillingRestatements:]
+		}
+	})
+}
+
+// reportOverlapsBillingPeriod returns true if report has generated results
+// for any time in the billing period from start to end.
+func reportOverlapsBillingPeriod(report *metering.Report, start, end time.Time) bool {
+	if report.Status.LastReportTime == nil || !report.Status.LastReportTime.Time.After(start) {
+		return false
+	}
+	var resultsStart *metav1.Time
+	if len(report.Status.QueryGenerations) != 0 {
+		resultsStart = &report.Status.QueryGenerations[0].PeriodStart
+	} else if report.Spec.ReportingStart != nil {
+		resultsStart = report.Spec.ReportingStart
+	}
+	return resultsStart == nil || resultsStart.Time.Before(end)
+}
+
+// flagReportsForAWSBillingRestatements sets the Stale condition on the
+// Reports depending on dataSource that generated results for a restated
+// billing period, so they can be generated again. Reports whose dependencies
+// can't be resolved are logged and skipped, and an error is only returned if
+// a Report couldn't be flagged.
+func (op *defaultReportingOperator) flagReportsForAWSBillingRestatements(logger log.FieldLogger, dataSource *metering.ReportDataSource, restatements []metering.AWSBillingRestatement) error {
+	if len(restatements) == 0 {
+		return nil
+	}
+	reports, err := op.reportLister.Reports(dataSource.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		deps, err := op.getReportDependencies(report)
+		if err != nil {
+			logger.WithField("report", report.Name).WithError(err).Warnf("unable to get the dependencies of Report %s, skipping checking if it's stale", report.Name)
+			continue
+		}
+		dependent := false
+		for _, depDataSource := range deps.ReportDataSources {
+			if depDataSource.Name == dataSource.Name {
+				dependent = true
+				break
+			}
+		}
+		if !dependent {
+			continue
+		}
+
+		var restatedPeriods []string
+		for _, r := range restatements {
+			if reportOverlapsBillingPeriod(report, r.BillingPeriodStart.Time, r.BillingPeriodEnd.Time) {
+				restatedPeriods = append(restatedPeriods, fmt.Sprintf("%s-%s", reportingutil.AWSBillingPeriodTimestamp(r.BillingPeriodStart.Time), reportingutil.AWSBillingPeriodTimestamp(r.BillingPeriodEnd.Time)))
+			}
+		}
+		if len(restatedPeriods) == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("AWS restated the billing periods %s of ReportDataSource %s, results generated for them are out of date", strings.Join(restatedPeriods, ", "), dataSource.Name)
+		cond := meteringUtil.NewReportCondition(metering.ReportStale, v1.ConditionTrue, meteringUtil.DataSourceRestatedReason, msg)
+		report = report.DeepCopy()
+		if staleCond := meteringUtil.GetReportCondition(report.Status, metering.ReportStale); staleCond != nil && staleCond.Status == v1.ConditionTrue {
+			if staleCond.Message == msg {
+				continue
+			}
+			// SetReportCondition keeps the message of a condition with
+			// the same status and reason, so replace it to list the
+			// newly restated billing periods
+			cond.LastTransitionTime = staleCond.LastTransitionTime
+			if err := meteringUtil.RemoveReportCondition(&report.Status, metering.ReportStale); err != nil {
+				return err
+			}
+		}
+		logger.Infof("flagging Report %s for regeneration: %s", report.Name, msg)
+		if _, err := op.updateReportStatus(report, cond); err != nil {
+			return fmt.Errorf("unable to flag Report %s for regeneration: %v", report.Name, err)
+		}
+	}
+	return nil
+}
+
 // SanetizeAWSColumnForHive removes and replaces invalid characters in AWS
 // billing columns with characters allowed in hive SQL
 func SanetizeAWSColumnForHive(col aws.Column) string {
-	name := fmt.Sprintf("%s_%s", strings.TrimSpace(col.Category), strings.TrimSpace(col.Name))
-	// hive does not allow ':' or '.' in identifiers
-	name = strings.Replace(name, ":", "_", -1)
-	name = strings.Replace(name, ".", "_", -1)
-	return strings.ToLower(name)
+	return sanitizeAWSHiveColumnName(fmt.Sprintf("%s_%s", strings.TrimSpace(col.Category), strings.TrimSpace(col.Name)))
+}
+
+// sanitizeAWSHiveColumnName lower cases name and replaces the characters
+// which aren't allowed in Hive identifiers, such as the ':' and '.' of
+// resource tags, with underscores.
+func sanitizeAWSHiveColumnName(name string) string {
+	return awsHiveColumnInvalidCharsRegexp.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "_")
 }
 
 // AWSColumnToHiveColumnType is the data type a column is created as in Hive.
@@ -186,3 +552,112 @@ func AWSColumnToHiveColumnType(c aws.Column) string {
 		return "string"
 	}
 }
+
+// AWSParquetColumnName is the name of a column in the Parquet files of a
+// legacy Cost and Usage Report, which is its category and name converted to
+// snake case, such as line_item_usage_start_date for lineItem/UsageStartDate.
+func AWSParquetColumnName(col aws.Column) string {
+	name := toSnakeCase(strings.TrimSpace(col.Category)) + "_" + toSnakeCase(strings.TrimSpace(col.Name))
+	// collapse the underscores of replaced characters, such as the ':' of
+	// resourceTags/user:Name
+	for strings.Contains(name, "__") {
+		name = strings.Replace(name, "__", "_", -1)
+	}
+	return strings.Trim(name, "_")
+}
+
+// toSnakeCase converts a camel case name into lower snake case, replacing
+// any characters other than letters and digits with underscores.
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			// start a new word at an upper case letter following a lower
+			// case letter or digit, or at the last upper case letter of an
+			// acronym followed by a lower case letter, such as the T of
+			// ECUType
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLower(r), unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// AWSParquetColumnToHiveColumnType is the data type a column of a legacy
+// Parquet Cost and Usage Report is created as in Hive. Columns without a type
+// in the manifest use their type in schemaTypes, the types of the columns of
+// the Parquet files, and are otherwise guessed from their names.
+func AWSParquetColumnToHiveColumnType(c aws.Column, schemaTypes map[string]string) string {
+	switch strings.ToLower(strings.TrimSpace(c.Type)) {
+	case "datetime":
+		return "timestamp"
+	case "bigdecimal", "optionalbigdecimal":
+		return "double"
+	case "":
+		// older manifests don't have column types
+		if schemaType, ok := schemaTypes[AWSParquetColumnName(c)]; ok {
+			return schemaType
+		}
+		switch AWSParquetColumnName(c) {
+		case "line_item_usage_start_date", "line_item_usage_end_date":
+			return "timestamp"
+		case "line_item_blended_cost":
+			return "double"
+		}
+	}
+	return "string"
+}
+
+// AWSCUR2ColumnName is the name of a column of a CUR 2.0 data export in Hive.
+func AWSCUR2ColumnName(c aws.Column) string {
+	return sanitizeAWSHiveColumnName(c.Name)
+}
+
+// AWSCUR2ColumnToHiveColumnType is the data type a column of a CUR 2.0 data
+// export is created as in Hive. CUR 2.0 manifests list the Hive type of each
+// column, of which only the known primitive types and maps are used, and any
+// other types are created as strings. Columns without a type use their type
+// in schemaTypes, the types of the columns of the Parquet files, and are
+// otherwise guessed from their names, where the nested columns holding
+// key/value pairs, such as resource_tags, are maps.
+func AWSCUR2ColumnToHiveColumnType(c aws.Column, schemaTypes map[string]string) string {
+	if colType := strings.ToLower(strings.Replace(strings.TrimSpace(c.Type), " ", "", -1)); colType != "" {
+		switch colType {
+		case "string", "varchar", "char":
+			return "string"
+		case "integer", "int":
+			return "int"
+		case "bigint", "smallint", "tinyint", "double", "float", "boolean", "timestamp", "date",
+			"map<string,string>", "map<string,double>":
+			return colType
+		}
+		if hiveDecimalTypeRegexp.MatchString(colType) {
+			return colType
+		}
+		return "string"
+	}
+	name := AWSCUR2ColumnName(c)
+	if schemaType, ok := schemaTypes[name]; ok {
+		return schemaType
+	}
+	switch {
+	case name == "discount":
+		return "map<string,double>"
+	case name == "product", name == "resource_tags", name == "cost_category":
+		return "map<string,string>"
+	case strings.HasSuffix(name, "_date"):
+		return "timestamp"
+	case strings.HasSuffix(name, "_cost"), strings.HasSuffix(name, "_amount"):
+		return "double"
+	}
+	return "string"
+}
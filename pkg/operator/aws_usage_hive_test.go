@@ -0,0 +1,414 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	meteringUtil "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1/util"
+	"github.com/kube-reporting/metering-operator/pkg/aws"
+	"github.com/kube-reporting/metering-operator/pkg/generated/clientset/versioned/fake"
+	listers "github.com/kube-reporting/metering-operator/pkg/generated/listers/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reporting"
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+func TestAWSParquetColumnName(t *testing.T) {
+	tests := map[aws.Column]string{
+		{Category: "lineItem", Name: "UsageStartDate"}:    "line_item_usage_start_date",
+		{Category: "identity", Name: "LineItemId"}:        "identity_line_item_id",
+		{Category: "product", Name: "ECU"}:                "product_ecu",
+		{Category: "product", Name: "ECUType"}:            "product_ecu_type",
+		{Category: "product", Name: "vcpu"}:               "product_vcpu",
+		{Category: "resourceTags", Name: "user:Name"}:     "resource_tags_user_name",
+		{Category: "resourceTags", Name: "aws:k8s.Id"}:    "resource_tags_aws_k8s_id",
+		{Category: "savingsPlan", Name: "SavingsPlanARN"}: "savings_plan_savings_plan_arn",
+	}
+	for col, expected := range tests {
+		assert.Equal(t, expected, AWSParquetColumnName(col), "column %s/%s", col.Category, col.Name)
+	}
+}
+
+func TestAWSManifestHiveColumns(t *testing.T) {
+	columns := []aws.Column{
+		{Category: "lineItem", Name: "UsageStartDate", Type: "DateTime"},
+		{Category: "lineItem", Name: "BlendedCost", Type: "BigDecimal"},
+		{Category: "lineItem", Name: "ResourceId", Type: "OptionalString"},
+	}
+	manifests := []*aws.Manifest{{Columns: columns}, {Columns: columns[:1]}}
+
+	assert.Equal(t, []hive.Column{
+		{Name: "lineitem_usagestartdate", Type: "timestamp"},
+		{Name: "lineitem_blendedcost", Type: "double"},
+		{Name: "lineitem_resourceid", Type: "string"},
+	}, AWSManifestHiveColumns(aws.ManifestFormatCSV, manifests, nil))
+
+	assert.Equal(t, []hive.Column{
+		{Name: "line_item_usage_start_date", Type: "timestamp"},
+		{Name: "line_item_blended_cost", Type: "double"},
+		{Name: "line_item_resource_id", Type: "string"},
+	}, AWSManifestHiveColumns(aws.ManifestFormatParquet, manifests, nil))
+
+	// older manifests don't list column types, which are read from the
+	// schema of the Parquet files
+	untyped := []*aws.Manifest{{Columns: []aws.Column{
+		{Category: "lineItem", Name: "UsageStartDate"},
+		{Category: "lineItem", Name: "UnblendedCost"},
+		{Category: "lineItem", Name: "UsageAmount"},
+		{Category: "lineItem", Name: "BlendedCost"},
+		{Category: "lineItem", Name: "ResourceId"},
+	}}}
+	schemaTypes := map[string]string{
+		"line_item_usage_start_date": "timestamp",
+		"line_item_unblended_cost":   "double",
+		"line_item_usage_amount":     "decimal(18,4)",
+		"line_item_resource_id":      "string",
+	}
+	assert.Equal(t, []hive.Column{
+		{Name: "line_item_usage_start_date", Type: "timestamp"},
+		{Name: "line_item_unblended_cost", Type: "double"},
+		{Name: "line_item_usage_amount", Type: "decimal(18,4)"},
+		{Name: "line_item_blended_cost", Type: "double"},
+		{Name: "line_item_resource_id", Type: "string"},
+	}, AWSManifestHiveColumns(aws.ManifestFormatParquet, untyped, schemaTypes))
+
+	cur2 := []*aws.Manifest{{Columns: []aws.Column{
+		{Name: "line_item_usage_start_date", Type: "timestamp"},
+		{Name: "line_item_unblended_cost", Type: "decimal(38,9)"},
+		{Name: "product", Type: "MAP<STRING,STRING>"},
+		{Name: "bill_payer_account_name", Type: "varchar"},
+		// older data exports don't list column types
+		{Name: "resource_tags"},
+		{Name: "discount"},
+		{Name: "line_item_usage_end_date"},
+		{Name: "line_item_usage_amount"},
+		{Name: "line_item_resource_id"},
+		{Name: "line_item_net_unblended_cost"},
+		// unknown types are strings, and invalid characters are replaced
+		{Name: "savings_plan", Type: "struct<arn:string>"},
+		{Name: "line_item_tax_type", Type: "string) STORED AS textfile --"},
+		{Name: "Reservation.ARN`; DROP TABLE x", Type: "string"},
+		{Name: " "},
+	}}}
+	assert.Equal(t, []hive.Column{
+		{Name: "line_item_usage_start_date", Type: "timestamp"},
+		{Name: "line_item_unblended_cost", Type: "decimal(38,9)"},
+		{Name: "product", Type: "map<string,string>"},
+		{Name: "bill_payer_account_name", Type: "string"},
+		{Name: "resource_tags", Type: "map<string,string>"},
+		{Name: "discount", Type: "map<string,double>"},
+		{Name: "line_item_usage_end_date", Type: "timestamp"},
+		{Name: "line_item_usage_amount", Type: "double"},
+		{Name: "line_item_resource_id", Type: "string"},
+		{Name: "line_item_net_unblended_cost", Type: "decimal(38,9)"},
+		{Name: "savings_plan", Type: "string"},
+		{Name: "line_item_tax_type", Type: "string"},
+		{Name: "reservation_arn___drop_table_x", Type: "string"},
+	}, AWSManifestHiveColumns(aws.ManifestFormatCUR2, cur2, map[string]string{
+		"line_item_net_unblended_cost": "decimal(38,9)",
+	}))
+}
+
+type fakeManifestRetriever struct {
+	schemas map[string][]parquet.SchemaElement
+	keys    []string
+}
+
+func (r *fakeManifestRetriever) RetrieveManifests() ([]*aws.Manifest, error) {
+	return nil, nil
+}
+
+func (r *fakeManifestRetriever) RetrieveParquetSchema(key string) ([]parquet.SchemaElement, error) {
+	r.keys = append(r.keys, key)
+	schema, ok := r.schemas[key]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", key)
+	}
+	return schema, nil
+}
+
+func TestGetAWSParquetSchemaTypes(t *testing.T) {
+	double := int32(5)
+	retriever := &fakeManifestRetriever{schemas: map[string][]parquet.SchemaElement{
+		"cur/data/BILLING_PERIOD=2023-12/cur-00001.snappy.parquet": {
+			{Name: "schema", NumChildren: 1},
+			{Name: "Line_Item_Unblended_Cost", Type: &double},
+		},
+	}}
+	november := &aws.Manifest{
+		BillingPeriod: aws.BillingPeriod{Start: aws.Time{Time: time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)}},
+		DataFiles:     []string{"s3://billing/cur/data/BILLING_PERIOD=2023-11/cur-00001.snappy.parquet"},
+		Columns:       []aws.Column{{Name: "line_item_unblended_cost"}},
+	}
+	december := &aws.Manifest{
+		BillingPeriod: aws.BillingPeriod{Start: aws.Time{Time: time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)}},
+		DataFiles:     []string{"s3://billing/cur/data/BILLING_PERIOD=2023-12/cur-00001.snappy.parquet"},
+		Columns:       []aws.Column{{Name: "line_item_unblended_cost", Type: "double"}},
+	}
+	logger := logrus.New()
+
+	types := getAWSParquetSchemaTypes(logger, retriever, aws.ManifestFormatCUR2, []*aws.Manifest{november, december})
+	assert.Equal(t, map[string]string{"line_item_unblended_cost": "double"}, types)
+	assert.Equal(t, []string{"cur/data/BILLING_PERIOD=2023-12/cur-00001.snappy.parquet"}, retriever.keys, "only the newest report should be read")
+
+	retriever.keys = nil
+	assert.Nil(t, getAWSParquetSchemaTypes(logger, retriever, aws.ManifestFormatCUR2, []*aws.Manifest{december}), "every column has a type")
+	assert.Nil(t, getAWSParquetSchemaTypes(logger, retriever, aws.ManifestFormatCSV, []*aws.Manifest{november}), "CSV reports have no schema")
+	assert.Empty(t, retriever.keys)
+
+	december.DataFiles = []string{"s3://billing/cur/data/BILLING_PERIOD=2023-12/missing.snappy.parquet"}
+	assert.Nil(t, getAWSParquetSchemaTypes(logger, retriever, aws.ManifestFormatCUR2, []*aws.Manifest{november, december}), "unreadable schemas are ignored")
+}
+
+func TestGetAWSBillingFormat(t *testing.T) {
+	november := aws.Time{Time: time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)}
+	december := aws.Time{Time: time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)}
+	csvManifest := &aws.Manifest{ContentType: "text/csv", BillingPeriod: aws.BillingPeriod{Start: november}}
+	cur2Manifest := &aws.Manifest{ExportName: "cur2", BillingPeriod: aws.BillingPeriod{Start: december}}
+	manifests := []*aws.Manifest{cur2Manifest, csvManifest}
+
+	tests := map[string]struct {
+		spec      metering.AWSBillingFormat
+		status    metering.ReportDataSourceStatus
+		expected  aws.ManifestFormat
+		expectErr bool
+	}{
+		"detected from the newest manifest": {
+			expected: aws.ManifestFormatCUR2,
+		},
+		"configured": {
+			spec:     metering.AWSBillingFormatParquet,
+			expected: aws.ManifestFormatParquet,
+		},
+		"invalid": {
+			spec:      "JSON",
+			expectErr: true,
+		},
+		"recorded in the status": {
+			status:   metering.ReportDataSourceStatus{TableRef: v1.LocalObjectReference{Name: "table"}, AWSBilling: &metering.AWSBillingDataSourceStatus{Format: metering.AWSBillingFormatParquet}},
+			expected: aws.ManifestFormatParquet,
+		},
+		"tables created before formats were supported": {
+			status:   metering.ReportDataSourceStatus{TableRef: v1.LocalObjectReference{Name: "table"}},
+			expected: aws.ManifestFormatCSV,
+		},
+		"configured format differs from the table": {
+			spec:      metering.AWSBillingFormatCUR2,
+			status:    metering.ReportDataSourceStatus{TableRef: v1.LocalObjectReference{Name: "table"}},
+			expectErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dataSource := &metering.ReportDataSource{
+				Spec:   metering.ReportDataSourceSpec{AWSBilling: &metering.AWSBillingDataSource{Format: test.spec}},
+				Status: test.status,
+			}
+			format, err := getAWSBillingFormat(dataSource, manifests)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+
+	filtered := filterAWSManifestsByFormat(logrus.New(), manifests, aws.ManifestFormatCSV)
+	assert.Equal(t, []*aws.Manifest{csvManifest}, filtered)
+}
+
+func TestGetDesiredPartitionsCUR2(t *testing.T) {
+	manifests := []*aws.Manifest{{
+		ExportName: "cur2-export",
+		BillingPeriod: aws.BillingPeriod{
+			Start: aws.Time{Time: time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)},
+			End:   aws.Time{Time: time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		DataFiles: []string{"s3://billing-bucket/exports/cur2-export/data/BILLING_PERIOD=2023-11/cur2-export-00001.snappy.parquet"},
+	}}
+	partitions, err := getDesiredPartitions("billing-bucket", manifests)
+	require.NoError(t, err)
+	assert.Equal(t, []metering.HiveTablePartition{{
+		Location: "s3a://billing-bucket/exports/cur2-export/data/BILLING_PERIOD=2023-11/",
+		PartitionSpec: hive.PartitionSpec{
+			billingPeriodStartPartitionColumnName: "20231101",
+			billingPeriodEndPartitionColumnName:   "20231201",
+		},
+	}}, partitions)
+}
+
+func TestDetectAWSBillingRestatements(t *testing.T) {
+	nov := time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)
+	jan := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2023, time.December, 5, 0, 0, 0, 0, time.UTC)
+
+	manifests := []*aws.Manifest{
+		{AssemblyID: "dec-2", BillingPeriod: aws.BillingPeriod{Start: aws.Time{Time: dec}, End: aws.Time{Time: jan}}},
+		{AssemblyID: "nov-2", BillingPeriod: aws.BillingPeriod{Start: aws.Time{Time: nov}, End: aws.Time{Time: dec}}},
+	}
+	periods := getAWSBillingPeriodStatuses(manifests)
+	assert.Equal(t, []metering.AWSBillingPeriodStatus{
+		{Start: metav1.NewTime(nov), End: metav1.NewTime(dec), AssemblyID: "nov-2"},
+		{Start: metav1.NewTime(dec), End: metav1.NewTime(jan), AssemblyID: "dec-2"},
+	}, periods)
+
+	// December is new, so only November was restated
+	previous := []metering.AWSBillingPeriodStatus{
+		{Start: metav1.NewTime(nov.Local()), End: metav1.NewTime(dec.Local()), AssemblyID: "nov-1"},
+	}
+	assert.Equal(t, []metering.AWSBillingRestatement{{
+		BillingPeriodStart: metav1.NewTime(nov),
+		BillingPeriodEnd:   metav1.NewTime(dec),
+		PreviousAssemblyID: "nov-1",
+		AssemblyID:         "nov-2",
+		DetectedTime:       metav1.NewTime(now),
+	}}, detectAWSBillingRestatements(previous, periods, now))
+	assert.False(t, awsBillingPeriodsEqual(previous, periods))
+
+	// times read back from the API aren't in UTC
+	unchanged := []metering.AWSBillingPeriodStatus{
+		{Start: metav1.NewTime(nov.Local()), End: metav1.NewTime(dec.Local()), AssemblyID: "nov-2"},
+		{Start: metav1.NewTime(dec.Local()), End: metav1.NewTime(jan.Local()), AssemblyID: "dec-2"},
+	}
+	assert.Empty(t, detectAWSBillingRestatements(unchanged, periods, now))
+	assert.True(t, awsBillingPeriodsEqual(unchanged, periods))
+
+	// CUR 2.0 manifests identify the report by executionId
+	assert.Equal(t, "execution", awsManifestAssemblyID(&aws.Manifest{ExecutionID: "execution"}))
+}
+
+func TestFlagReportsForAWSBillingRestatements(t *testing.T) {
+	const namespace = "default"
+	nov := time.Date(2023, time.November, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2023, time.December, 1, 0, 0, 0, 0, time.UTC)
+	oct := nov.AddDate(0, -1, 0)
+	octTwentieth := oct.AddDate(0, 0, 19)
+	novTwentieth := nov.AddDate(0, 0, 19)
+
+	dataSource := &metering.ReportDataSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-billing", Namespace: namespace},
+		Spec:       metering.ReportDataSourceSpec{AWSBilling: &metering.AWSBillingDataSource{}},
+	}
+	dsInput, err := json.Marshal(dataSource.Name)
+	require.NoError(t, err)
+	query := &metering.ReportQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-query", Namespace: namespace},
+		Spec: metering.ReportQuerySpec{
+			Inputs: []metering.ReportQueryInputDefinition{{Name: "ds", Type: "ReportDataSource"}},
+		},
+	}
+	inputs := metering.ReportQueryInputValues{{Name: "ds", Value: (*json.RawMessage)(&dsInput)}}
+	lastReportTime := func(t time.Time) metering.ReportStatus {
+		return metering.ReportStatus{LastReportTime: &metav1.Time{Time: t}}
+	}
+	alreadyStale := lastReportTime(novTwentieth)
+	alreadyStale.Conditions = []metering.ReportCondition{*meteringUtil.NewReportCondition(metering.ReportStale, v1.ConditionTrue, meteringUtil.DataSourceRestatedReason, "AWS restated the billing periods 20231001-20231101 of ReportDataSource aws-billing, results generated for them are out of date")}
+	reports := []*metering.Report{
+		// generated results for the first half of November
+		testhelpers.NewReport("overlapping", namespace, query.Name, inputs, &oct, nil, lastReportTime(novTwentieth), nil, false, nil),
+		// hasn't generated results for November yet
+		testhelpers.NewReport("before", namespace, query.Name, inputs, &oct, nil, lastReportTime(octTwentieth), nil, false, nil),
+		// only generated results after November
+		testhelpers.NewReport("after", namespace, query.Name, inputs, &dec, nil, lastReportTime(dec.AddDate(0, 0, 5)), nil, false, nil),
+		// doesn't depend on the ReportDataSource
+		testhelpers.NewReport("unrelated", namespace, "other-query", nil, &oct, nil, lastReportTime(novTwentieth), nil, false, nil),
+		// its ReportQuery doesn't exist, and it's skipped
+		testhelpers.NewReport("broken", namespace, "missing-query", nil, &oct, nil, lastReportTime(novTwentieth), nil, false, nil),
+		// already stale for an earlier restatement
+		testhelpers.NewReport("already-stale", namespace, query.Name, inputs, &oct, nil, alreadyStale, nil, false, nil),
+	}
+
+	reportIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	var objs []runtime.Object
+	for _, report := range reports {
+		require.NoError(t, reportIndexer.Add(report))
+		objs = append(objs, report)
+	}
+	otherQuery := &metering.ReportQuery{ObjectMeta: metav1.ObjectMeta{Name: "other-query", Namespace: namespace}}
+	reportQueryIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, reportQueryIndexer.Add(query))
+	require.NoError(t, reportQueryIndexer.Add(otherQuery))
+	client := fake.NewSimpleClientset(objs...)
+	op := &defaultReportingOperator{
+		meteringClient:    client,
+		reportLister:      listers.NewReportLister(reportIndexer),
+		reportQueryLister: listers.NewReportQueryLister(reportQueryIndexer),
+		dependencyResolver: reporting.NewDependencyResolver(
+			testhelpers.NewReportQueryStore([]*metering.ReportQuery{query, otherQuery}),
+			testhelpers.NewReportDataSourceStore([]*metering.ReportDataSource{dataSource}),
+			testhelpers.NewReportStore(reports),
+		),
+	}
+
+	restatements := []metering.AWSBillingRestatement{{
+		BillingPeriodStart: metav1.NewTime(nov),
+		BillingPeriodEnd:   metav1.NewTime(dec),
+		PreviousAssemblyID: "nov-1",
+		AssemblyID:         "nov-2",
+	}}
+	require.NoError(t, op.flagReportsForAWSBillingRestatements(logrus.New(), dataSource, restatements))
+
+	for name, stale := range map[string]bool{"overlapping": true, "before": false, "after": false, "unrelated": false, "broken": false, "already-stale": true} {
+		report, err := client.MeteringV1().Reports(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		require.NoError(t, err)
+		cond := meteringUtil.GetReportCondition(report.Status, metering.ReportStale)
+		if !stale {
+			assert.Nil(t, cond, "Report %s shouldn't be stale", name)
+			continue
+		}
+		require.NotNil(t, cond, "Report %s should be stale", name)
+		assert.Equal(t, v1.ConditionTrue, cond.Status)
+		assert.Equal(t, meteringUtil.DataSourceRestatedReason, cond.Reason)
+		assert.Contains(t, cond.Message, "20231101-20231201", "the condition of Report %s should list the newly restated billing period", name)
+	}
+}
+
+func TestGetAWSBillingS3Options(t *testing.T) {
+	op := &defaultReportingOperator{cfg: Config{AWSWebIdentityTokenFile: "/var/run/secrets/token"}}
+	dataSource := &metering.ReportDataSource{
+		Spec: metering.ReportDataSourceSpec{AWSBilling: &metering.AWSBillingDataSource{
+			Source: &metering.S3Bucket{Bucket: "billing", Region: "us-east-1", Endpoint: "https://minio.example.com", ForcePathStyle: true},
+			Credentials: &metering.AWSCredentials{
+				WebIdentity: &metering.AWSWebIdentity{RoleARN: "arn:aws:iam::123456789012:role/metering"},
+				AssumeRole:  &metering.AWSAssumeRole{RoleARN: "arn:aws:iam::210987654321:role/payer", ExternalID: "external"},
+			},
+		}},
+	}
+	opts, err := op.getAWSBillingS3Options(dataSource)
+	require.NoError(t, err)
+	assert.Equal(t, aws.S3Options{
+		Endpoint:       "https://minio.example.com",
+		ForcePathStyle: true,
+		Credentials: aws.Credentials{
+			WebIdentityRoleARN:   "arn:aws:iam::123456789012:role/metering",
+			WebIdentityTokenFile: "/var/run/secrets/token",
+			AssumeRoleARN:        "arn:aws:iam::210987654321:role/payer",
+			AssumeRoleExternalID: "external",
+		},
+	}, opts)
+
+	dataSource.Spec.AWSBilling.Credentials.SecretRef = &v1.LocalObjectReference{Name: "aws-credentials"}
+	_, err = op.getAWSBillingS3Options(dataSource)
+	assert.Error(t, err)
+
+	// web identities can't be used unless the token file is configured
+	dataSource.Spec.AWSBilling.Credentials.SecretRef = nil
+	op.cfg.AWSWebIdentityTokenFile = ""
+	_, err = op.getAWSBillingS3Options(dataSource)
+	assert.Error(t, err)
+}
@@ -0,0 +1,226 @@
+package operator
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/azure"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const azureStorageAccountKeySecretKey = "azure-storage-account-key"
+
+// AzureCostExportHivePartitions are the partition columns of the table of
+// an AzureCostExport ReportDataSource, which are the same as the table of an
+// AWSBilling ReportDataSource.
+var AzureCostExportHivePartitions = AWSUsageHivePartitions
+
+// azureCostExportColumnTypes are the Presto types of the known numeric and
+// date columns of the exports of Enterprise Agreement, Microsoft Customer
+// Agreement and pay-as-you-go subscriptions. Other columns are varchar.
+var azureCostExportColumnTypes = map[string]string{
+	"costinbillingcurrency":        "double",
+	"costinpricingcurrency":        "double",
+	"costinusd":                    "double",
+	"paygcostinbillingcurrency":    "double",
+	"paygcostinusd":                "double",
+	"cost":                         "double",
+	"pretaxcost":                   "double",
+	"effectiveprice":               "double",
+	"unitprice":                    "double",
+	"paygprice":                    "double",
+	"quantity":                     "double",
+	"usagequantity":                "double",
+	"resourcerate":                 "double",
+	"exchangeratepricingtobilling": "double",
+	"date":                         "timestamp",
+	"usagedatetime":                "timestamp",
+	"billingperiodstartdate":       "timestamp",
+	"billingperiodenddate":         "timestamp",
+	"serviceperiodstartdate":       "timestamp",
+	"serviceperiodenddate":         "timestamp",
+	"exchangeratedate":             "timestamp",
+}
+
+// azureCostExportHiveColumns returns the columns of the raw table of the
+// exported CSV data, which are all strings as the OpenCSVSerde only supports
+// string columns.
+func azureCostExportHiveColumns(columns []string) []hive.Column {
+	hiveColumns := make([]hive.Column, len(columns))
+	for i, col := range columns {
+		hiveColumns[i] = hive.Column{Name: csvHeaderColumnName(col), Type: "string"}
+	}
+	return hiveColumns
+}
+
+// azureCostExportViewColumn returns the Presto type of a column of the raw
+// table, and the expression converting the raw string to it.
+func azureCostExportViewColumn(name string) (string, string) {
+	col := presto.QuoteIdentifier(name)
+	switch azureCostExportColumnTypes[name] {
+	case "double":
+		return "double", fmt.Sprintf("try_cast(nullif(trim(%s), '') AS double)", col)
+	case "timestamp":
+		// dates are MM/DD/YYYY in older exports and YYYY-MM-DD in newer
+		// ones
+		return "timestamp", fmt.Sprintf("coalesce(try(date_parse(%[1]s, '%%m/%%d/%%Y')), try(date_parse(%[1]s, '%%Y-%%m-%%d')), try(CAST(from_iso8601_timestamp(%[1]s) AS timestamp)))", col)
+	default:
+		return "varchar", col
+	}
+}
+
+// azureCostExportView returns the query of a view of rawTableName with typed
+// columns, and the columns of the view.
+func azureCostExportView(rawTableName string, hiveColumns []hive.Column) (string, []presto.Column) {
+	var (
+		selects []string
+		columns []presto.Column
+	)
+	for _, hiveCol := range hiveColumns {
+		colType, expr := azureCostExportViewColumn(hiveCol.Name)
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, presto.QuoteIdentifier(hiveCol.Name)))
+		columns = append(columns, presto.Column{Name: hiveCol.Name, Type: colType})
+	}
+	for _, partitionCol := range AzureCostExportHivePartitions {
+		selects = append(selects, presto.QuoteIdentifier(partitionCol.Name))
+		columns = append(columns, presto.Column{Name: partitionCol.Name, Type: "varchar"})
+	}
+	// Hive reads every file in the directory of a run, including its
+	// manifest.json, so only the rows of the other files are selected using
+	// the hidden $path column of the Hive connector.
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s NOT LIKE %s", strings.Join(selects, ", "), rawTableName, presto.QuoteIdentifier("$path"), presto.QuoteString("%/"+azure.ExportManifestName))
+	return query, columns
+}
+
+// azureBlobLocation returns the wasb(s) URI of dir in the container of
+// source, which is how Hive and Presto address Azure blob storage. Emulators
+// are addressed using the same URI, and require Hive to be configured to use
+// the emulator for the storage account.
+func azureBlobLocation(source *metering.AzureBlobContainer, dir string) (string, error) {
+	scheme := "wasbs"
+	if source.Endpoint != "" {
+		endpoint, err := url.Parse(source.Endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid Blob service endpoint %q: %v", source.Endpoint, err)
+		}
+		if endpoint.Scheme == "http" {
+			scheme = "wasb"
+		}
+	}
+	location := fmt.Sprintf("%s://%s@%s.blob.core.windows.net/", scheme, source.Container, source.StorageAccountName)
+	dir = strings.Trim(dir, "/")
+	if dir != "" {
+		location += dir + "/"
+	}
+	return location, nil
+}
+
+// getAzureBlobClient returns a client for the container of dataSource, using
+// the access key in spec.azureCostExport.source.secretRef if it's set.
+func (op *defaultReportingOperator) getAzureBlobClient(dataSource *metering.ReportDataSource) (*azure.BlobClient, error) {
+	source := dataSource.Spec.AzureCostExport.Source
+	var accessKey string
+	if source.SecretRef != nil {
+		key, err := op.getSecretKey(dataSource.Namespace, &v1.SecretKeySelector{
+			LocalObjectReference: *source.SecretRef,
+			Key:                  azureStorageAccountKeySecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		accessKey = strings.TrimSpace(string(key))
+	}
+	return azure.NewBlobClient(source.StorageAccountName, source.Container, source.Endpoint, accessKey, op.cfg.ProxyTrustedCABundle)
+}
+
+// filterAzureExportRunsByColumns returns the runs with the same columns as
+// the table, since the columns of the CSV data are read by position.
+func filterAzureExportRunsByColumns(logger log.FieldLogger, runs []*azure.ExportRun, hiveColumns []hive.Column) []*azure.ExportRun {
+	var filtered []*azure.ExportRun
+	for _, run := range runs {
+		if reflect.DeepEqual(azureCostExportHiveColumns(run.Columns), hiveColumns) {
+			filtered = append(filtered, run)
+			continue
+		}
+		logger.Warnf("ignoring export run %s with columns which differ from the table, recreate the ReportDataSource to use the columns of the latest exports", run.Directory)
+	}
+	return filtered
+}
+
+func getAzureCostExportPartitions(source *metering.AzureBlobContainer, runs []*azure.ExportRun) ([]metering.HiveTablePartition, error) {
+	partitions := make([]metering.HiveTablePartition, 0, len(runs))
+	for _, run := range runs {
+		location, err := azureBlobLocation(source, run.Directory)
+		if err != nil {
+			return nil, err
+		}
+		partitions = append(partitions, metering.HiveTablePartition{
+			Location: location,
+			PartitionSpec: hive.PartitionSpec{
+				billingPeriodStartPartitionColumnName: reportingutil.AWSBillingPeriodTimestamp(run.BillingPeriodStart),
+				billingPeriodEndPartitionColumnName:   reportingutil.AWSBillingPeriodTimestamp(run.BillingPeriodEnd),
+			},
+		})
+	}
+	return partitions, nil
+}
+
+func getAzureCostExportBillingPeriodStatuses(runs []*azure.ExportRun) []metering.AzureCostExportBillingPeriodStatus {
+	periods := make([]metering.AzureCostExportBillingPeriodStatus, 0, len(runs))
+	for _, run := range runs {
+		periods = append(periods, metering.AzureCostExportBillingPeriodStatus{
+			Start: metav1.NewTime(run.BillingPeriodStart.UTC()),
+			End:   metav1.NewTime(run.BillingPeriodEnd.UTC()),
+			RunID: run.Manifest.RunInfo.RunID,
+		})
+	}
+	return periods
+}
+
+func azureCostExportBillingPeriodsEqual(a, b []metering.AzureCostExportBillingPeriodStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(&b[i].Start) || !a[i].End.Equal(&b[i].End) || a[i].RunID != b[i].RunID {
+			return false
+		}
+	}
+	return true
+}
+
+// createAzureCostExportTables creates the HiveTable of the exported CSV data
+// with the columns of run, and a PrestoTable view of it with typed columns.
+func (op *defaultReportingOperator) createAzureCostExportTables(logger log.FieldLogger, dataSource *metering.ReportDataSource, run *azure.ExportRun) (*metering.HiveTable, *metering.PrestoTable, error) {
+	dbName, err := op.getDataSourceDatabaseName(dataSource, dataSource.Spec.AzureCostExport.DatabaseName)
+	if err != nil {
+		return nil, nil, err
+	}
+	location, err := azureBlobLocation(dataSource.Spec.AzureCostExport.Source, dataSource.Spec.AzureCostExport.Source.Prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := hive.TableParameters{
+		Database:      dbName,
+		Name:          reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name) + dataSourceRawTableSuffix,
+		Columns:       azureCostExportHiveColumns(run.Columns),
+		PartitionedBy: AzureCostExportHivePartitions,
+		Location:      location,
+		FileFormat:    "textfile",
+		RowFormat:     csvHiveRowFormat,
+		TableProperties: map[string]string{
+			"skip.header.line.count": "1",
+		},
+		External: true,
+	}
+	return op.createDataSourceRawTableAndView(logger, dataSource, params, azureCostExportView)
+}
@@ -0,0 +1,76 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/azure"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+func TestAzureCostExportView(t *testing.T) {
+	hiveColumns := azureCostExportHiveColumns([]string{"Date", "CostInBillingCurrency", "Resource Id"})
+	assert.Equal(t, []hive.Column{
+		{Name: "date", Type: "string"},
+		{Name: "costinbillingcurrency", Type: "string"},
+		{Name: "resource_id", Type: "string"},
+	}, hiveColumns)
+
+	query, columns := azureCostExportView("hive.metering.datasource_metering_azure_raw", hiveColumns)
+	assert.Equal(t, []presto.Column{
+		{Name: "date", Type: "timestamp"},
+		{Name: "costinbillingcurrency", Type: "double"},
+		{Name: "resource_id", Type: "varchar"},
+		{Name: "billing_period_start", Type: "varchar"},
+		{Name: "billing_period_end", Type: "varchar"},
+	}, columns)
+	assert.Equal(t, `SELECT coalesce(try(date_parse("date", '%m/%d/%Y')), try(date_parse("date", '%Y-%m-%d')), try(CAST(from_iso8601_timestamp("date") AS timestamp))) AS "date", `+
+		`try_cast(nullif(trim("costinbillingcurrency"), '') AS double) AS "costinbillingcurrency", `+
+		`"resource_id" AS "resource_id", "billing_period_start", "billing_period_end" `+
+		`FROM hive.metering.datasource_metering_azure_raw WHERE "$path" NOT LIKE '%/manifest.json'`, query)
+}
+
+func TestGetAzureCostExportPartitions(t *testing.T) {
+	newRun := func(dir string, start time.Time, columns ...string) *azure.ExportRun {
+		return &azure.ExportRun{
+			Manifest:           &azure.ExportManifest{},
+			Directory:          dir,
+			BillingPeriodStart: start,
+			BillingPeriodEnd:   start.AddDate(0, 1, 0),
+			Columns:            columns,
+		}
+	}
+	january := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	runs := []*azure.ExportRun{
+		newRun("exports/costs/20240101-20240131/run-1", january, "Date", "Cost"),
+		newRun("exports/costs/20240201-20240229/run-2", january.AddDate(0, 1, 0), "Date", "Cost", "Tags"),
+	}
+	runs = filterAzureExportRunsByColumns(logrus.New(), runs, azureCostExportHiveColumns([]string{"Date", "Cost"}))
+	require.Len(t, runs, 1)
+
+	partitions, err := getAzureCostExportPartitions(&metering.AzureBlobContainer{
+		StorageAccountName: "devstoreaccount1",
+		Container:          "billing",
+		Endpoint:           "http://azurite:10000/devstoreaccount1",
+	}, runs)
+	require.NoError(t, err)
+	assert.Equal(t, []metering.HiveTablePartition{
+		{
+			Location: "wasb://billing@devstoreaccount1.blob.core.windows.net/exports/costs/20240101-20240131/run-1/",
+			PartitionSpec: hive.PartitionSpec{
+				"billing_period_start": "20240101",
+				"billing_period_end":   "20240201",
+			},
+		},
+	}, partitions)
+
+	location, err := azureBlobLocation(&metering.AzureBlobContainer{StorageAccountName: "account", Container: "billing"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "wasbs://billing@account.blob.core.windows.net/", location)
+}
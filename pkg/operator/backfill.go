@@ -0,0 +1,249 @@
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+const (
+	// defaultBackfillQueriesPerMinute is how many Prometheus queries a
+	// backfill makes per minute when queriesPerMinute isn't set.
+	defaultBackfillQueriesPerMinute = 10
+	// backfillInterval is how often a running backfill imports data.
+	backfillInterval = time.Minute
+
+	// backfillPartitionDuration is the duration of the data in a dt partition.
+	backfillPartitionDuration = 24 * time.Hour
+)
+
+// backfillTimeRange returns the time range imported by backfill, which is
+// extended to whole days if the backfill overwrites dt partitions.
+func backfillTimeRange(backfill metering.ReportDataSourceBackfill) (time.Time, time.Time) {
+	start, end := backfill.Start.UTC(), backfill.End.UTC()
+	if backfill.Overwrite {
+		start = start.Truncate(backfillPartitionDuration)
+		if truncated := end.Truncate(backfillPartitionDuration); truncated.Before(end) {
+			end = truncated.Add(backfillPartitionDuration)
+		}
+	}
+	return start, end
+}
+
+// newPrometheusBackfillStatuses returns a status for each backfill of
+// dataSource, keeping the existing status of backfills whose time range
+// hasn't changed.
+func newPrometheusBackfillStatuses(dataSource *metering.ReportDataSource) []metering.ReportDataSourceBackfillStatus {
+	existing := make(map[string]metering.ReportDataSourceBackfillStatus)
+	for _, status := range dataSource.Status.Backfills {
+		existing[status.Name] = status
+	}
+	statuses := make([]metering.ReportDataSourceBackfillStatus, len(dataSource.Spec.Backfill))
+	for i, backfill := range dataSource.Spec.Backfill {
+		start, end := backfillTimeRange(backfill)
+		status, ok := existing[backfill.Name]
+		if !ok || status.Overwrite != backfill.Overwrite || status.Start == nil || !status.Start.Time.Equal(start) || status.End == nil || !status.End.Time.Equal(end) {
+			status = metering.ReportDataSourceBackfillStatus{
+				Name:      backfill.Name,
+				Phase:     metering.ReportDataSourceBackfillPending,
+				Start:     &metav1.Time{Time: start},
+				End:       &metav1.Time{Time: end},
+				Overwrite: backfill.Overwrite,
+			}
+		}
+		statuses[i] = *status.DeepCopy()
+	}
+	return statuses
+}
+
+func backfillFinished(status metering.ReportDataSourceBackfillStatus) bool {
+	return status.Phase == metering.ReportDataSourceBackfillComplete || status.Phase == metering.ReportDataSourceBackfillFailed
+}
+
+// runPrometheusBackfills runs the first unfinished backfill of dataSource,
+// importing data until it has made queriesPerMinute queries, and records its
+// progress in the status of dataSource. Backfills run at most once every
+// backfillInterval. It returns the updated dataSource, and whether any
+// backfills are still unfinished.
+func (op *defaultReportingOperator) runPrometheusBackfills(logger log.FieldLogger, dataSource *metering.ReportDataSource, promConn prom.API, importerCfg prestostore.Config, metricsCollectors prestostore.ImporterMetricsCollectors) (*metering.ReportDataSource, bool, error) {
+	statuses := newPrometheusBackfillStatuses(dataSource)
+
+	var backfilled []prestostore.TimeInterval
+	var backfillErr error
+	for i := range statuses {
+		status := &statuses[i]
+		if backfillFinished(*status) {
+			continue
+		}
+		if status.LastImportTime == nil || op.clock.Since(status.LastImportTime.Time) >= backfillInterval {
+			backfilled, backfillErr = op.runPrometheusBackfill(logger, dataSource, dataSource.Spec.Backfill[i], status, promConn, importerCfg, metricsCollectors)
+		}
+		break
+	}
+
+	pending := false
+	for _, status := range statuses {
+		if !backfillFinished(status) {
+			pending = true
+		}
+	}
+
+	if len(backfilled) == 0 && reflect.DeepEqual(statuses, dataSource.Status.Backfills) {
+		return dataSource, pending, backfillErr
+	}
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	updated, err := updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		newDS.Status.Backfills = statuses
+		if len(backfilled) == 0 {
+			return
+		}
+		// record the backfilled data as imported so it isn't considered a
+		// gap, and isn't imported again by other backfills
+		importStatus := newDS.Status.PrometheusMetricsImportStatus
+		if importStatus == nil {
+			importStatus = &metering.PrometheusMetricsImportStatus{}
+			newDS.Status.PrometheusMetricsImportStatus = importStatus
+		}
+		intervals := importedRangesToIntervals(importStatus, importerCfg.StepSize)
+		for _, interval := range backfilled {
+			intervals = prestostore.AddTimeInterval(intervals, interval)
+		}
+		importStatus.ImportedRanges = intervalsToImportedRanges(intervals)
+	})
+	if err != nil {
+		return dataSource, pending, fmt.Errorf("unable to update ReportDataSource %s backfill status: %v", dataSource.Name, err)
+	}
+	return updated, pending, backfillErr
+}
+
+// runPrometheusBackfill imports the data of backfill starting from where it
+// last imported until, updating status with its progress. It returns the
+// intervals of data imported.
+//
+// Backfills which overwrite data delete each dt partition before importing
+// the data of that day. When the operator restarts part way through a day,
+// the day is imported again from the start, since the data imported after
+// the progress recorded in status is unknown. Backfills which don't
+// overwrite data skip the data already imported by the ReportDataSource.
+func (op *defaultReportingOperator) runPrometheusBackfill(logger log.FieldLogger, dataSource *metering.ReportDataSource, backfill metering.ReportDataSourceBackfill, status *metering.ReportDataSourceBackfillStatus, promConn prom.API, importerCfg prestostore.Config, metricsCollectors prestostore.ImporterMetricsCollectors) ([]prestostore.TimeInterval, error) {
+	now := op.clock.Now().UTC()
+	start, end := status.Start.UTC(), status.End.UTC()
+	logger = logger.WithField("backfill", backfill.Name)
+
+	if status.Phase == metering.ReportDataSourceBackfillPending {
+		var reason string
+		switch {
+		case !start.Before(end):
+			reason = "start must be before end"
+		case backfill.Overwrite && end.After(now.Truncate(backfillPartitionDuration)):
+			reason = "backfills which overwrite data must end before the current day"
+		case end.After(now):
+			reason = "end must not be in the future"
+		}
+		if reason != "" {
+			logger.Errorf("backfill %s of ReportDataSource %s failed: %s", backfill.Name, dataSource.Name, reason)
+			status.Phase = metering.ReportDataSourceBackfillFailed
+			status.Message = reason
+			status.CompletionTime = &metav1.Time{Time: now}
+			return nil, nil
+		}
+		logger.Infof("starting backfill %s of ReportDataSource %s from %s to %s", backfill.Name, dataSource.Name, start, end)
+		status.Phase = metering.ReportDataSourceBackfillRunning
+		status.StartTime = &metav1.Time{Time: now}
+	}
+	if status.ImportedUntil == nil {
+		status.ImportedUntil = &metav1.Time{Time: start}
+	}
+
+	pos := status.ImportedUntil.UTC()
+	resumedKey := fmt.Sprintf("%s/%s/%s", dataSource.Namespace, dataSource.Name, backfill.Name)
+	op.resumedBackfillsMu.Lock()
+	resumed := op.resumedBackfills[resumedKey]
+	op.resumedBackfills[resumedKey] = true
+	op.resumedBackfillsMu.Unlock()
+	if backfill.Overwrite && !resumed {
+		pos = pos.Truncate(backfillPartitionDuration)
+	}
+
+	var coverage []prestostore.TimeInterval
+	if !backfill.Overwrite && dataSource.Status.PrometheusMetricsImportStatus != nil {
+		coverage = importedRangesToIntervals(dataSource.Status.PrometheusMetricsImportStatus, importerCfg.StepSize)
+	}
+
+	queries := backfill.QueriesPerMinute
+	if queries <= 0 {
+		queries = defaultBackfillQueriesPerMinute
+	}
+
+	var backfilled []prestostore.TimeInterval
+	var err error
+	for queries > 0 && pos.Before(end) {
+		interval := prestostore.TimeInterval{Start: pos, End: pos.Add(importerCfg.ChunkSize)}
+		if interval.End.After(end) || importerCfg.ChunkSize <= 0 {
+			interval.End = end
+		}
+
+		if backfill.Overwrite {
+			if nextDay := pos.Truncate(backfillPartitionDuration).Add(backfillPartitionDuration); interval.End.After(nextDay) {
+				interval.End = nextDay
+			}
+			if pos.Equal(pos.Truncate(backfillPartitionDuration)) {
+				dt := prestostore.PrometheusMetricTimestampPartition(pos)
+				logger.Infof("deleting partition dt=%s of table %s for backfill %s", dt, importerCfg.PrestoTableName, backfill.Name)
+				if err = op.prometheusMetricsRepo.DeletePrometheusMetricsPartition(importerCfg.PrestoTableName, dt); err != nil {
+					break
+				}
+			}
+		} else {
+			skipped := false
+			for _, covered := range coverage {
+				if !pos.Before(covered.Start) && pos.Before(covered.End) {
+					pos = covered.End
+					skipped = true
+					break
+				}
+				if covered.Start.After(pos) && covered.Start.Before(interval.End) {
+					interval.End = covered.Start
+				}
+			}
+			if skipped {
+				continue
+			}
+		}
+
+		var imported int
+		imported, err = op.importPrometheusInterval(logger, promConn, importerCfg, metricsCollectors, interval)
+		if err != nil {
+			break
+		}
+		status.MetricsImported += int64(imported)
+		backfilled = prestostore.AddTimeInterval(backfilled, interval)
+		pos = interval.End
+		queries--
+	}
+	if pos.After(end) {
+		pos = end
+	}
+
+	status.ImportedUntil = &metav1.Time{Time: pos}
+	status.LastImportTime = &metav1.Time{Time: now}
+	if err != nil {
+		// the backfill resumes from where it got to the next time it runs
+		status.Message = err.Error()
+		return backfilled, fmt.Errorf("backfill %s of ReportDataSource %s errored: %v", backfill.Name, dataSource.Name, err)
+	}
+	status.Message = ""
+	if !pos.Before(end) {
+		logger.Infof("backfill %s of ReportDataSource %s completed with %d metrics imported", backfill.Name, dataSource.Name, status.MetricsImported)
+		status.Phase = metering.ReportDataSourceBackfillComplete
+		status.CompletionTime = &metav1.Time{Time: now}
+	}
+	return backfilled, nil
+}
@@ -0,0 +1,159 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/generated/clientset/versioned/fake"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+func TestRunPrometheusBackfills(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	janThree := janOne.Add(2 * 24 * time.Hour)
+	now := janOne.Add(10 * 24 * time.Hour)
+	tableName := "hive.metering.datasource_test"
+
+	// the first 12 hours of January 1st have already been imported
+	repo := &fakePrometheusMetricsRepo{metrics: map[string][]*prestostore.PrometheusMetric{}}
+	for ts := janOne; ts.Before(janOne.Add(12 * time.Hour)); ts = ts.Add(time.Minute) {
+		repo.metrics[tableName] = append(repo.metrics[tableName], &prestostore.PrometheusMetric{Timestamp: ts})
+	}
+	promConn := &fakeRangePrometheusAPI{hasData: func(time.Time) bool { return true }}
+
+	dataSource := &metering.ReportDataSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Spec: metering.ReportDataSourceSpec{
+			Backfill: []metering.ReportDataSourceBackfill{
+				{Name: "overwrite", Start: metav1.NewTime(janOne.Add(2 * time.Hour)), End: metav1.NewTime(janOne.Add(5 * time.Hour)), Overwrite: true},
+				{Name: "skip-imported", Start: metav1.NewTime(janThree), End: metav1.NewTime(janThree.Add(6 * time.Hour)), QueriesPerMinute: 100},
+				{Name: "future", Start: metav1.NewTime(now), End: metav1.NewTime(now.Add(time.Hour))},
+			},
+		},
+		Status: metering.ReportDataSourceStatus{
+			PrometheusMetricsImportStatus: &metering.PrometheusMetricsImportStatus{
+				ImportedRanges: []metering.PrometheusImportTimeRange{
+					{Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(12 * time.Hour))},
+					{Start: metav1.NewTime(janThree.Add(2 * time.Hour)), End: metav1.NewTime(janThree.Add(4 * time.Hour))},
+				},
+			},
+		},
+	}
+	fakeClock := clock.NewFakeClock(now)
+	meteringClient := fake.NewSimpleClientset(dataSource)
+	op := &defaultReportingOperator{
+		meteringClient:        meteringClient,
+		clock:                 fakeClock,
+		prometheusMetricsRepo: repo,
+		resumedBackfills:      make(map[string]bool),
+	}
+	importerCfg := prestostore.Config{
+		PrometheusQuery: "up",
+		PrestoTableName: tableName,
+		ChunkSize:       time.Hour,
+		StepSize:        time.Minute,
+	}
+	metricsCollectors := op.newPromImporterMetricsCollectors(dataSource, &metering.PrestoTable{}, importerCfg)
+	countMetrics := func(start, end time.Time) int {
+		count := 0
+		for _, metric := range repo.metrics[tableName] {
+			if !metric.Timestamp.Before(start) && metric.Timestamp.Before(end) {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the overwrite backfill is extended to the whole of January 1st, which
+	// is deleted and then imported 10 queries at a time
+	dataSource, pending, err := op.runPrometheusBackfills(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	status := dataSource.Status.Backfills[0]
+	assert.Equal(t, metering.ReportDataSourceBackfillRunning, status.Phase)
+	assert.Equal(t, janOne, status.Start.UTC())
+	assert.Equal(t, janOne.Add(24*time.Hour), status.End.UTC())
+	assert.Equal(t, janOne.Add(10*time.Hour), status.ImportedUntil.UTC())
+	assert.Equal(t, 10, promConn.calls)
+	assert.Equal(t, 10*60, countMetrics(janOne, janOne.Add(24*time.Hour)))
+
+	// backfills are rate limited
+	dataSource, _, err = op.runPrometheusBackfills(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors)
+	require.NoError(t, err)
+	assert.Equal(t, 10, promConn.calls)
+
+	// after a restart, the day being imported is imported again from the start
+	op.resumedBackfills = make(map[string]bool)
+	fakeClock.Step(backfillInterval)
+	dataSource, _, err = op.runPrometheusBackfills(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors)
+	require.NoError(t, err)
+	assert.Equal(t, janOne.Add(10*time.Hour), dataSource.Status.Backfills[0].ImportedUntil.UTC())
+	assert.Equal(t, 10*60, countMetrics(janOne, janOne.Add(24*time.Hour)))
+
+	for i := 0; pending && i < 10; i++ {
+		fakeClock.Step(backfillInterval)
+		dataSource, pending, err = op.runPrometheusBackfills(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors)
+		require.NoError(t, err)
+	}
+	require.False(t, pending)
+
+	statuses := dataSource.Status.Backfills
+	require.Len(t, statuses, 3)
+	assert.Equal(t, metering.ReportDataSourceBackfillComplete, statuses[0].Phase)
+	// the first 10 hours were imported twice
+	assert.Equal(t, int64(34*60), statuses[0].MetricsImported)
+	assert.Equal(t, 24*60, countMetrics(janOne, janOne.Add(24*time.Hour)))
+
+	// only the parts of the time range which weren't imported are backfilled
+	assert.Equal(t, metering.ReportDataSourceBackfillComplete, statuses[1].Phase)
+	assert.Equal(t, int64(4*60), statuses[1].MetricsImported)
+	assert.Equal(t, 4*60, countMetrics(janThree, janThree.Add(24*time.Hour)))
+
+	assert.Equal(t, metering.ReportDataSourceBackfillFailed, statuses[2].Phase)
+	assert.Equal(t, "end must not be in the future", statuses[2].Message)
+
+	assert.Equal(t, []metering.PrometheusImportTimeRange{
+		{Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(24 * time.Hour))},
+		{Start: metav1.NewTime(janThree), End: metav1.NewTime(janThree.Add(6 * time.Hour))},
+	}, dataSource.Status.PrometheusMetricsImportStatus.ImportedRanges)
+
+	// finished backfills don't update the ReportDataSource
+	meteringClient.ClearActions()
+	fakeClock.Step(backfillInterval)
+	_, pending, err = op.runPrometheusBackfills(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors)
+	require.NoError(t, err)
+	assert.False(t, pending)
+	assert.Empty(t, meteringClient.Actions())
+}
+
+func TestNewPrometheusBackfillStatuses(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	dataSource := &metering.ReportDataSource{
+		Spec: metering.ReportDataSourceSpec{
+			Backfill: []metering.ReportDataSourceBackfill{
+				{Name: "unchanged", Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(time.Hour))},
+				{Name: "changed", Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(2 * time.Hour))},
+			},
+		},
+		Status: metering.ReportDataSourceStatus{
+			Backfills: []metering.ReportDataSourceBackfillStatus{
+				{Name: "changed", Phase: metering.ReportDataSourceBackfillComplete, Start: &metav1.Time{Time: janOne}, End: &metav1.Time{Time: janOne.Add(time.Hour)}},
+				{Name: "unchanged", Phase: metering.ReportDataSourceBackfillComplete, Start: &metav1.Time{Time: janOne}, End: &metav1.Time{Time: janOne.Add(time.Hour)}},
+				{Name: "removed", Phase: metering.ReportDataSourceBackfillRunning},
+			},
+		},
+	}
+	statuses := newPrometheusBackfillStatuses(dataSource)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "unchanged", statuses[0].Name)
+	assert.Equal(t, metering.ReportDataSourceBackfillComplete, statuses[0].Phase)
+	assert.Equal(t, "changed", statuses[1].Name)
+	assert.Equal(t, metering.ReportDataSourceBackfillPending, statuses[1].Phase)
+}
@@ -0,0 +1,231 @@
+package operator
+
+import (
+	"context"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+// maxGapRepairAttempts is the number of times a gap is re-imported before
+// it's assumed Prometheus has no data for it, such as when the query of the
+// ReportDataSource has no results for that time.
+const maxGapRepairAttempts = 3
+
+// maxExpiredGaps is the number of gaps older than the retention of
+// Prometheus kept in the status of a ReportDataSource. They can't be repaired,
+// so only the newest are kept to bound the size of the status.
+const maxExpiredGaps = 20
+
+var (
+	prometheusReportDatasourceGapsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_gaps",
+			Help:      "Number of gaps in the imported data of a Prometheus ReportDataSource found by the last coverage check.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	prometheusReportDatasourceGapSecondsGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_gap_seconds",
+			Help:      "Total duration of the gaps in the imported data of a Prometheus ReportDataSource found by the last coverage check.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	prometheusReportDatasourceGapRepairsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_gap_repairs_total",
+			Help:      "Number of gaps in the imported data of a Prometheus ReportDataSource re-imported.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(prometheusReportDatasourceGapsGauge)
+	prometheus.MustRegister(prometheusReportDatasourceGapSecondsGauge)
+	prometheus.MustRegister(prometheusReportDatasourceGapRepairsCounter)
+}
+
+// importedRangesToIntervals returns the ImportedRanges of status as
+// intervals. ReportDataSources imported before ImportedRanges was tracked
+// are assumed to have imported everything between ImportDataStartTime and
+// ImportDataEndTime.
+func importedRangesToIntervals(status *metering.PrometheusMetricsImportStatus, stepSize time.Duration) []prestostore.TimeInterval {
+	if len(status.ImportedRanges) == 0 {
+		if status.ImportDataStartTime == nil || status.ImportDataEndTime == nil {
+			return nil
+		}
+		return []prestostore.TimeInterval{{
+			Start: status.ImportDataStartTime.UTC(),
+			End:   status.ImportDataEndTime.Add(stepSize).UTC(),
+		}}
+	}
+	intervals := make([]prestostore.TimeInterval, len(status.ImportedRanges))
+	for i, r := range status.ImportedRanges {
+		intervals[i] = prestostore.TimeInterval{Start: r.Start.UTC(), End: r.End.UTC()}
+	}
+	return intervals
+}
+
+func intervalsToImportedRanges(intervals []prestostore.TimeInterval) []metering.PrometheusImportTimeRange {
+	ranges := make([]metering.PrometheusImportTimeRange, len(intervals))
+	for i, interval := range intervals {
+		ranges[i] = metering.PrometheusImportTimeRange{
+			Start: metav1.NewTime(interval.Start),
+			End:   metav1.NewTime(interval.End),
+		}
+	}
+	return ranges
+}
+
+// recordImportedTimeRanges adds the time ranges processed by an import to
+// the ImportedRanges of status.
+func recordImportedTimeRanges(status *metering.PrometheusMetricsImportStatus, timeRanges []prom.Range, stepSize time.Duration) {
+	intervals := importedRangesToIntervals(status, stepSize)
+	for _, timeRange := range timeRanges {
+		intervals = prestostore.AddTimeInterval(intervals, prestostore.ProcessedTimeRangeInterval(timeRange))
+	}
+	status.ImportedRanges = intervalsToImportedRanges(intervals)
+}
+
+// coverageCheckDue returns true if the imported data of status should be
+// checked for gaps.
+func (op *defaultReportingOperator) coverageCheckDue(status *metering.PrometheusMetricsImportStatus) bool {
+	interval := op.cfg.PrometheusDataSourceCoverageCheckInterval
+	if interval <= 0 {
+		return false
+	}
+	return status.LastCoverageCheckTime == nil || op.clock.Since(status.LastCoverageCheckTime.Time) >= interval
+}
+
+// checkPrometheusImportCoverage counts the metrics of the table of
+// dataSource to find gaps in the data it has imported within the retention
+// of Prometheus, and re-imports each gap which hasn't already been
+// re-imported maxGapRepairAttempts times. The gaps remaining are recorded in
+// status along with the newest maxExpiredGaps gaps found by previous checks
+// which are older than the retention of Prometheus, and can no longer be
+// repaired.
+func (op *defaultReportingOperator) checkPrometheusImportCoverage(logger log.FieldLogger, dataSource *metering.ReportDataSource, promConn prom.API, importerCfg prestostore.Config, metricsCollectors prestostore.ImporterMetricsCollectors, status *metering.PrometheusMetricsImportStatus) error {
+	now := op.clock.Now().UTC()
+	window := prestostore.TimeInterval{Start: now.Add(-op.cfg.PrometheusDataSourceRetention), End: now}
+	coverage := importedRangesToIntervals(status, importerCfg.StepSize)
+	if len(coverage) != 0 && coverage[0].Start.After(window.Start) {
+		window.Start = coverage[0].Start
+	}
+
+	counts, err := op.prometheusMetricsRepo.GetMetricCountsByBucket(importerCfg.PrestoTableName, window)
+	if err != nil {
+		return err
+	}
+	found := prestostore.FindGaps(coverage, counts, window)
+
+	previousAttempts := make(map[prestostore.TimeInterval]int)
+	var gaps []metering.PrometheusImportGap
+	for _, gap := range status.Gaps {
+		if gap.End.Time.After(window.Start) {
+			previousAttempts[prestostore.TimeInterval{Start: gap.Start.UTC(), End: gap.End.UTC()}] = gap.RepairAttempts
+			continue
+		}
+		// the data of this gap is no longer retained by Prometheus
+		gap.Repairable = false
+		gaps = append(gaps, gap)
+	}
+	// status.Gaps is sorted by time, so the oldest gaps are first
+	if len(gaps) > maxExpiredGaps {
+		logger.Infof("forgetting %d gaps older than the retention of Prometheus in ReportDataSource %s", len(gaps)-maxExpiredGaps, dataSource.Name)
+		gaps = gaps[len(gaps)-maxExpiredGaps:]
+	}
+
+	var repairErr error
+	for _, gap := range found {
+		attempts := previousAttempts[gap]
+		if repairErr == nil && attempts < maxGapRepairAttempts {
+			var imported int
+			imported, repairErr = op.repairPrometheusImportGap(logger, promConn, importerCfg, metricsCollectors, gap)
+			if repairErr == nil {
+				prometheusReportDatasourceGapRepairsCounter.WithLabelValues(dataSource.Name, dataSource.Namespace, importerCfg.PrestoTableName).Inc()
+				coverage = prestostore.AddTimeInterval(coverage, gap)
+				if imported != 0 {
+					logger.Infof("repaired gap from %s to %s in ReportDataSource %s with %d metrics", gap.Start, gap.End, dataSource.Name, imported)
+					continue
+				}
+				attempts++
+			}
+		}
+		gaps = append(gaps, metering.PrometheusImportGap{
+			Start:          metav1.NewTime(gap.Start),
+			End:            metav1.NewTime(gap.End),
+			Repairable:     attempts < maxGapRepairAttempts,
+			RepairAttempts: attempts,
+		})
+	}
+
+	var gapDuration time.Duration
+	for _, gap := range gaps {
+		gapDuration += gap.End.Sub(gap.Start.Time)
+	}
+	promLabels := prometheus.Labels{
+		"reportdatasource": dataSource.Name,
+		"namespace":        dataSource.Namespace,
+		"table_name":       importerCfg.PrestoTableName,
+	}
+	prometheusReportDatasourceGapsGauge.With(promLabels).Set(float64(len(gaps)))
+	prometheusReportDatasourceGapSecondsGauge.With(promLabels).Set(gapDuration.Seconds())
+
+	status.Gaps = gaps
+	status.ImportedRanges = intervalsToImportedRanges(coverage)
+	status.LastCoverageCheckTime = &metav1.Time{Time: now}
+	return repairErr
+}
+
+// repairPrometheusImportGap re-imports the data of gap, returning the number
+// of metrics imported. gap is imported in time ranges of at most the
+// importer's ChunkSize.
+func (op *defaultReportingOperator) repairPrometheusImportGap(logger log.FieldLogger, promConn prom.API, importerCfg prestostore.Config, metricsCollectors prestostore.ImporterMetricsCollectors, gap prestostore.TimeInterval) (int, error) {
+	logger.Infof("re-importing gap from %s to %s in table %s", gap.Start, gap.End, importerCfg.PrestoTableName)
+	imported := 0
+	for start := gap.Start; start.Before(gap.End); {
+		end := start.Add(importerCfg.ChunkSize)
+		if end.After(gap.End) || importerCfg.ChunkSize <= 0 {
+			end = gap.End
+		}
+		n, err := op.importPrometheusInterval(logger, promConn, importerCfg, metricsCollectors, prestostore.TimeInterval{Start: start, End: end})
+		imported += n
+		if err != nil {
+			return imported, err
+		}
+		start = end
+	}
+	return imported, nil
+}
+
+// importPrometheusInterval imports the data of interval with a single query,
+// returning the number of metrics imported. The query ends one step before
+// the end of interval so no sample is imported twice when the next interval
+// begins at its end.
+func (op *defaultReportingOperator) importPrometheusInterval(logger log.FieldLogger, promConn prom.API, importerCfg prestostore.Config, metricsCollectors prestostore.ImporterMetricsCollectors, interval prestostore.TimeInterval) (int, error) {
+	rangeEnd := interval.End.Add(-importerCfg.StepSize)
+	if rangeEnd.Before(interval.Start) {
+		rangeEnd = interval.Start
+	}
+	cfg := importerCfg
+	cfg.ChunkSize = rangeEnd.Sub(interval.Start)
+	cfg.ImportFromTime = nil
+	results, err := prestostore.ImportFromTimeRange(logger, op.clock, promConn, op.prometheusMetricsRepo, metricsCollectors, context.Background(), interval.Start, rangeEnd, cfg)
+	if err != nil {
+		return 0, err
+	}
+	return len(results.Metrics), nil
+}
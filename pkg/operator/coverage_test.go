@@ -0,0 +1,153 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+// fakeRangePrometheusAPI returns a sample of a single series at every step
+// of a query_range query for which hasData returns true.
+type fakeRangePrometheusAPI struct {
+	fakePrometheusEndpointAPI
+	hasData func(t time.Time) bool
+}
+
+func (api *fakeRangePrometheusAPI) QueryRange(ctx context.Context, query string, r prom.Range) (model.Value, error) {
+	api.calls++
+	stream := &model.SampleStream{Metric: model.Metric{"pod": "foo"}}
+	for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+		if api.hasData(t) {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(t.UnixNano()), Value: 1})
+		}
+	}
+	if len(stream.Values) == 0 {
+		return model.Matrix{}, nil
+	}
+	return model.Matrix{stream}, nil
+}
+
+func TestCheckPrometheusImportCoverage(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := janOne.Add(12 * time.Hour)
+	tableName := "hive.metering.datasource_test"
+	isHour := func(ts time.Time, hours ...int) bool {
+		for _, hour := range hours {
+			if ts.Hour() == hour {
+				return true
+			}
+		}
+		return false
+	}
+
+	// hours 3 and 5 are missing from the table, but Prometheus only has data
+	// for hour 3
+	repo := &fakePrometheusMetricsRepo{metrics: map[string][]*prestostore.PrometheusMetric{}}
+	for ts := janOne; ts.Before(now); ts = ts.Add(time.Minute) {
+		if !isHour(ts, 3, 5) {
+			repo.metrics[tableName] = append(repo.metrics[tableName], &prestostore.PrometheusMetric{Timestamp: ts})
+		}
+	}
+	promConn := &fakeRangePrometheusAPI{hasData: func(ts time.Time) bool { return !isHour(ts, 5) }}
+
+	op := &defaultReportingOperator{
+		cfg: Config{
+			PrometheusDataSourceRetention:             24 * time.Hour,
+			PrometheusDataSourceCoverageCheckInterval: time.Hour,
+		},
+		clock:                 clock.NewFakeClock(now),
+		prometheusMetricsRepo: repo,
+	}
+	dataSource := &metering.ReportDataSource{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	importerCfg := prestostore.Config{
+		PrometheusQuery: "up",
+		PrestoTableName: tableName,
+		ChunkSize:       20 * time.Minute,
+		StepSize:        time.Minute,
+	}
+	metricsCollectors := op.newPromImporterMetricsCollectors(dataSource, &metering.PrestoTable{}, importerCfg)
+	status := &metering.PrometheusMetricsImportStatus{
+		ImportDataStartTime: &metav1.Time{Time: janOne},
+		ImportDataEndTime:   &metav1.Time{Time: now.Add(-time.Minute)},
+	}
+	require.True(t, op.coverageCheckDue(status))
+
+	err := op.checkPrometheusImportCoverage(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors, status)
+	require.NoError(t, err)
+
+	// hour 3 is re-imported without duplicating the samples at its bounds
+	var hourThree int
+	for _, metric := range repo.metrics[tableName] {
+		if isHour(metric.Timestamp, 3) {
+			hourThree++
+		}
+	}
+	assert.Equal(t, 60, hourThree)
+	assert.Len(t, repo.metrics[tableName], 11*60)
+
+	assert.Equal(t, []metering.PrometheusImportGap{{
+		Start:          metav1.NewTime(janOne.Add(5 * time.Hour)),
+		End:            metav1.NewTime(janOne.Add(6 * time.Hour)),
+		Repairable:     true,
+		RepairAttempts: 1,
+	}}, status.Gaps)
+	assert.Equal(t, []metering.PrometheusImportTimeRange{{Start: metav1.NewTime(janOne), End: metav1.NewTime(now)}}, status.ImportedRanges)
+	assert.False(t, op.coverageCheckDue(status))
+
+	// the gap is no longer re-imported after maxGapRepairAttempts
+	for i := 1; i < maxGapRepairAttempts+2; i++ {
+		require.NoError(t, op.checkPrometheusImportCoverage(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors, status))
+	}
+	calls := promConn.calls
+	require.NoError(t, op.checkPrometheusImportCoverage(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors, status))
+	assert.Equal(t, calls, promConn.calls)
+	require.Len(t, status.Gaps, 1)
+	assert.False(t, status.Gaps[0].Repairable)
+	assert.Equal(t, maxGapRepairAttempts, status.Gaps[0].RepairAttempts)
+
+	// only the newest gaps older than the retention of Prometheus are kept
+	var expiredGaps []metering.PrometheusImportGap
+	for i := 0; i < maxExpiredGaps+5; i++ {
+		start := janOne.Add(-48*time.Hour + time.Duration(i)*time.Hour)
+		expiredGaps = append(expiredGaps, metering.PrometheusImportGap{
+			Start:      metav1.NewTime(start),
+			End:        metav1.NewTime(start.Add(time.Minute)),
+			Repairable: true,
+		})
+	}
+	status.Gaps = append(expiredGaps, status.Gaps...)
+	require.NoError(t, op.checkPrometheusImportCoverage(logrus.New(), dataSource, promConn, importerCfg, metricsCollectors, status))
+	require.Len(t, status.Gaps, maxExpiredGaps+1)
+	assert.Equal(t, expiredGaps[5].Start, status.Gaps[0].Start)
+	for _, gap := range status.Gaps {
+		assert.False(t, gap.Repairable)
+	}
+}
+
+func TestRecordImportedTimeRanges(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	status := &metering.PrometheusMetricsImportStatus{}
+	recordImportedTimeRanges(status, []prom.Range{
+		{Start: janOne, End: janOne.Add(4 * time.Minute), Step: time.Minute},
+		{Start: janOne.Add(5 * time.Minute), End: janOne.Add(9 * time.Minute), Step: time.Minute},
+	}, time.Minute)
+	// a failed import leaves a hole
+	recordImportedTimeRanges(status, []prom.Range{
+		{Start: janOne.Add(15 * time.Minute), End: janOne.Add(19 * time.Minute), Step: time.Minute},
+	}, time.Minute)
+	assert.Equal(t, []metering.PrometheusImportTimeRange{
+		{Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(10 * time.Minute))},
+		{Start: metav1.NewTime(janOne.Add(15 * time.Minute)), End: metav1.NewTime(janOne.Add(20 * time.Minute))},
+	}, status.ImportedRanges)
+}
@@ -0,0 +1,33 @@
+package operator
+
+import (
+	"strings"
+	"unicode"
+)
+
+// csvHiveRowFormat is the Hadoop serialization/deserialization implementation
+// used with CSV files which quote fields containing commas, such as the tags
+// and labels of resources in cloud billing exports. The OpenCSVSerde only
+// supports string columns.
+const csvHiveRowFormat = `
+SERDE 'org.apache.hadoop.hive.serde2.OpenCSVSerde'
+WITH SERDEPROPERTIES (
+    "separatorChar" = ",",
+    "quoteChar"     = "\""
+)
+`
+
+// csvHeaderColumnName returns the name of the column for a column in the
+// header of a CSV file, which is lowercased with any characters other than
+// letters, digits and underscores replaced with underscores, eg:
+// CostInBillingCurrency becomes costinbillingcurrency, and
+// service.description becomes service_description.
+func csvHeaderColumnName(col string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(col))
+}
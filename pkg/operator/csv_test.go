@@ -0,0 +1,20 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVHeaderColumnName(t *testing.T) {
+	tests := map[string]string{
+		"CostInBillingCurrency": "costinbillingcurrency",
+		"service.description":   "service_description",
+		" Tags ":                "tags",
+		"cost-center/team":      "cost_center_team",
+		"usage_amount":          "usage_amount",
+	}
+	for col, expected := range tests {
+		assert.Equal(t, expected, csvHeaderColumnName(col), "column %q", col)
+	}
+}
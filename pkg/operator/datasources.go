@@ -3,6 +3,7 @@ package operator
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -17,11 +18,14 @@ import (
 
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
 	"github.com/kube-reporting/metering-operator/pkg/aws"
+	"github.com/kube-reporting/metering-operator/pkg/azure"
+	"github.com/kube-reporting/metering-operator/pkg/gcp"
 	clientset "github.com/kube-reporting/metering-operator/pkg/generated/clientset/versioned/typed/metering/v1"
 	"github.com/kube-reporting/metering-operator/pkg/hive"
 	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
 	"github.com/kube-reporting/metering-operator/pkg/operator/reporting"
 	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
 	"github.com/kube-reporting/metering-operator/pkg/util/slice"
 )
 
@@ -32,6 +36,11 @@ const (
 	// expect that splitting this overall string by the `.` delimiter
 	// will yield an array of three string elements
 	expectedArrSplitElementsFQTN = 3
+
+	// dataSourceRawTableSuffix is appended to the names of the table and
+	// HiveTable holding the raw data of ReportDataSources whose table is a
+	// view of it, such as the exported CSV data of an AzureCostExport.
+	dataSourceRawTableSuffix = "_raw"
 )
 
 func (op *defaultReportingOperator) runReportDataSourceWorker() {
@@ -54,13 +63,22 @@ func (op *defaultReportingOperator) syncReportDataSource(logger log.FieldLogger,
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			logger.Infof("ReportDataSource %s does not exist anymore", key)
-			return nil
+			op.remoteWriteTracker.forget(namespace, name)
+			op.forgetPrometheusEndpointSet(namespace, name)
+			// the type of a deleted ReportDataSource isn't known, so
+			// remove its catalog in case it was an ExternalDatabase
+			return op.removePrestoExternalCatalog(logger, namespace, name)
 		}
 		return err
 	}
 
 	if reportDataSource.DeletionTimestamp != nil {
 		logger.Infof("ReportDataSource is marked for deletion, performing cleanup")
+		if reportDataSource.Spec.ExternalDatabase != nil {
+			if err := op.removePrestoExternalCatalog(logger, namespace, name); err != nil {
+				return err
+			}
+		}
 		_, err = op.removeReportDataSourceFinalizer(reportDataSource)
 		return err
 	}
@@ -85,6 +103,18 @@ func (op *defaultReportingOperator) handleReportDataSource(logger log.FieldLogge
 		err = op.handlePrometheusMetricsDataSource(logger, dataSource)
 	case dataSource.Spec.AWSBilling != nil:
 		err = op.handleAWSBillingDataSource(logger, dataSource)
+	case dataSource.Spec.AzureCostExport != nil:
+		err = op.handleAzureCostExportDataSource(logger, dataSource)
+	case dataSource.Spec.GCPBillingExport != nil:
+		err = op.handleGCPBillingExportDataSource(logger, dataSource)
+	case dataSource.Spec.FileDrop != nil:
+		err = op.handleFileDropDataSource(logger, dataSource)
+	case dataSource.Spec.KubernetesInventory != nil:
+		err = op.handleKubernetesInventoryDataSource(logger, dataSource)
+	case dataSource.Spec.PushTable != nil:
+		err = op.handlePushTableDataSource(logger, dataSource)
+	case dataSource.Spec.ExternalDatabase != nil:
+		err = op.handleExternalDatabaseDataSource(logger, dataSource)
 	case dataSource.Spec.PrestoTable != nil:
 		err = op.handlePrestoTableDataSource(logger, dataSource)
 	case dataSource.Spec.LinkExistingTable != nil:
@@ -92,7 +122,7 @@ func (op *defaultReportingOperator) handleReportDataSource(logger log.FieldLogge
 	case dataSource.Spec.ReportQueryView != nil:
 		err = op.handleReportQueryViewDataSource(logger, dataSource)
 	default:
-		err = fmt.Errorf("ReportDataSource %s: improperly configured missing prometheusMetricsImporter, awsBilling, reportQueryView or prestoTable configuration", dataSource.Name)
+		err = fmt.Errorf("ReportDataSource %s: improperly configured missing prometheusMetricsImporter, awsBilling, azureCostExport, gcpBillingExport, fileDrop, kubernetesInventory, pushTable, externalDatabase, reportQueryView or prestoTable configuration", dataSource.Name)
 	}
 	return err
 
@@ -102,6 +132,16 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 	if dataSource.Spec.PrometheusMetricsImporter == nil {
 		return fmt.Errorf("%s is not a PrometheusMetricsImporter ReportDataSource", dataSource.Name)
 	}
+	switch mode := dataSource.Spec.PrometheusMetricsImporter.Mode; mode {
+	case "", metering.PrometheusMetricsImporterModeRange:
+	case metering.PrometheusMetricsImporterModeSnapshot:
+		return op.handlePrometheusSnapshotDataSource(logger, dataSource)
+	default:
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, spec.prometheusMetricsImporter.mode must be range or snapshot, got %q", dataSource.Name, mode)
+	}
+	if dataSource.Status.PrometheusSnapshot != nil {
+		return fmt.Errorf("ReportDataSource %s was created in snapshot mode, the ReportDataSource must be recreated to change its mode", dataSource.Name)
+	}
 
 	var prestoTable *metering.PrestoTable
 	if dataSource.Status.TableRef.Name != "" {
@@ -136,6 +176,13 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 			params.RowFormat = hiveStorage.Spec.Hive.DefaultTableProperties.RowFormat
 			params.FileFormat = hiveStorage.Spec.Hive.DefaultTableProperties.FileFormat
 		}
+		// the table's location must be known to write files into it, and
+		// the files are written as Parquet
+		if op.cfg.PrometheusDataSourceWriteFiles && op.metricsFileWriter.Supports(hiveStorage.Status.Hive.Location) {
+			params.Location = fmt.Sprintf("%s/%s", strings.TrimSuffix(hiveStorage.Status.Hive.Location, "/"), tableName)
+			params.RowFormat = ""
+			params.FileFormat = prometheusMetricsFileFormat
+		}
 
 		logger.Infof("creating Hive table %s in database %s", tableName, hiveStorage.Status.Hive.DatabaseName)
 		hiveTable, err := op.createHiveTableCR(dataSource, metering.ReportDataSourceGVK, params, false, nil)
@@ -176,6 +223,18 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 		return nil
 	}
 
+	if updatedDS, err := op.enforceReportDataSourceRetention(logger, dataSource, prestoTable); err != nil {
+		logger.WithError(err).Errorf("error dropping expired partitions of ReportDataSource %s", dataSource.Name)
+	} else {
+		dataSource = updatedDS
+	}
+
+	// metrics are pushed to remote_write ReportDataSources, so there is
+	// nothing to import
+	if dataSource.Spec.PrometheusMetricsImporter.RemoteWrite != nil {
+		return op.handlePrometheusRemoteWriteDataSource(logger, dataSource)
+	}
+
 	if op.cfg.DisablePrometheusMetricsImporter {
 		logger.Infof("Periodic Prometheus ReportDataSource importing disabled")
 		return nil
@@ -196,28 +255,27 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 	if err != nil {
 		return err
 	}
+	promConn, err := op.getPrometheusConnForReportDataSource(dataSource)
+	if err != nil {
+		return err
+	}
 
 	// wrap in a closure to handle lock and unlock of the mutex
-	importer, err := func() (*prestostore.PrometheusImporter, error) {
+	importer := func() *prestostore.PrometheusImporter {
 		op.importersMu.Lock()
 		defer op.importersMu.Unlock()
 		importer, exists := op.importers[dataSource.Name]
 		if exists {
 			dataSourceLogger.Debugf("ReportDataSource %s already has an importer, updating configuration", dataSource.Name)
 			importer.UpdateConfig(importerCfg)
-			return importer, nil
+			importer.UpdatePrometheusConn(promConn)
+			return importer
 		}
 		// don't already have an importer, so create a new one
-		importer, err := op.newPromImporter(dataSourceLogger, dataSource, prestoTable, importerCfg)
-		if err != nil {
-			return nil, err
-		}
+		importer = op.newPromImporter(dataSourceLogger, dataSource, prestoTable, promConn, importerCfg)
 		op.importers[dataSource.Name] = importer
-		return importer, nil
+		return importer
 	}()
-	if err != nil {
-		return err
-	}
 
 	importStatus := dataSource.Status.PrometheusMetricsImportStatus
 	if importStatus == nil {
@@ -229,6 +287,14 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 
 	// run the import
 	results, err := importer.ImportFromLastTimestamp(context.Background())
+	if endpointSet, ok := promConn.(*prometheusEndpointSet); ok {
+		updatedDS, updateErr := op.updatePrometheusEndpointStatuses(dataSource, endpointSet)
+		if updateErr != nil {
+			logger.WithError(updateErr).Errorf("unable to update ReportDataSource %s Prometheus endpoint statuses", dataSource.Name)
+		} else {
+			dataSource = updatedDS
+		}
+	}
 	if err != nil {
 		op.eventRecorder.Event(dataSource, v1.EventTypeWarning, "FailedPrometheusQuery", "Unable to import metrics after Prometheus query failure. Check the reporting-operator container logs for more information.")
 		return fmt.Errorf("ImportFromLastTimestamp errored: %v", err)
@@ -292,6 +358,18 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 			}
 
 		}
+
+		recordImportedTimeRanges(importStatus, results.ProcessedTimeRanges, importerCfg.StepSize)
+		if op.coverageCheckDue(importStatus) {
+			metricsCollectors := op.newPromImporterMetricsCollectors(dataSource, prestoTable, importerCfg)
+			err := op.checkPrometheusImportCoverage(dataSourceLogger, dataSource, promConn, importerCfg, metricsCollectors, importStatus)
+			if err != nil {
+				// the gaps found are still recorded, and are repaired by
+				// the next check
+				dataSourceLogger.WithError(err).Errorf("error checking ReportDataSource %s for gaps in imported data", dataSource.Name)
+			}
+		}
+
 		// Update the status to indicate where we are in the metric import process
 		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
 		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
@@ -312,6 +390,19 @@ func (op *defaultReportingOperator) handlePrometheusMetricsDataSource(logger log
 
 	}
 
+	if len(dataSource.Spec.Backfill) != 0 || len(dataSource.Status.Backfills) != 0 {
+		metricsCollectors := op.newPromImporterMetricsCollectors(dataSource, prestoTable, importerCfg)
+		var backfillPending bool
+		dataSource, backfillPending, err = op.runPrometheusBackfills(dataSourceLogger, dataSource, promConn, importerCfg, metricsCollectors)
+		if err != nil {
+			// backfills resume from their recorded progress when next run
+			dataSourceLogger.WithError(err).Errorf("error backfilling ReportDataSource %s", dataSource.Name)
+		}
+		if backfillPending && importDelay > backfillInterval {
+			importDelay = backfillInterval
+		}
+	}
+
 	nextImport := op.clock.Now().Add(importDelay).UTC()
 	logger.Infof("queuing Prometheus ReportDataSource %s to import data again in %s at %s", dataSource.Name, importDelay, nextImport)
 	op.enqueueReportDataSourceAfter(dataSource, importDelay)
@@ -325,7 +416,11 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 	}
 
 	logger.Debugf("querying bucket %#v for AWS Billing manifests for ReportDataSource %s", source, dataSource.Name)
-	manifestRetriever, err := aws.NewManifestRetriever(logger, source.Region, source.Bucket, source.Prefix, op.cfg.ProxyTrustedCABundle)
+	s3Options, err := op.getAWSBillingS3Options(dataSource)
+	if err != nil {
+		return err
+	}
+	manifestRetriever, err := aws.NewManifestRetriever(logger, source.Region, source.Bucket, source.Prefix, op.cfg.ProxyTrustedCABundle, s3Options)
 	if err != nil {
 		return err
 	}
@@ -334,6 +429,12 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 		return err
 	}
 
+	format, err := getAWSBillingFormat(dataSource, manifests)
+	if err != nil {
+		return err
+	}
+	manifests = filterAWSManifestsByFormat(logger, manifests, format)
+
 	if len(manifests) == 0 {
 		logger.Warnf("ReportDataSource %q has no report manifests in it's bucket, the first report has likely not been generated yet", dataSource.Name)
 		return nil
@@ -343,8 +444,9 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 	if dataSource.Status.TableRef.Name == "" {
 		logger.Infof("new AWSBilling ReportDataSource discovered")
 		tableName := reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name)
-		logger.Debugf("creating AWS Billing DataSource table %s pointing to s3 bucket %s at prefix %s", tableName, source.Bucket, source.Prefix)
-		hiveTable, err = op.createAWSUsageHiveTableCR(logger, dataSource, tableName, source.Bucket, source.Prefix, manifests)
+		logger.Debugf("creating AWS Billing DataSource table %s for %s reports pointing to s3 bucket %s at prefix %s", tableName, format, source.Bucket, source.Prefix)
+		schemaTypes := getAWSParquetSchemaTypes(logger, manifestRetriever, format, manifests)
+		hiveTable, err = op.createAWSUsageHiveTableCR(logger, dataSource, tableName, source.Bucket, source.Prefix, format, manifests, schemaTypes)
 		if err != nil {
 			return err
 		}
@@ -362,6 +464,7 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
 		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
 			newDS.Status.TableRef = v1.LocalObjectReference{Name: hiveTable.Name}
+			newDS.Status.AWSBilling = &metering.AWSBillingDataSourceStatus{Format: metering.AWSBillingFormat(format)}
 		})
 		if err != nil {
 			return err
@@ -389,6 +492,17 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 			return err
 		}
 		logger.Infof("existing AWSBilling ReportDataSource discovered, tableName: %s", tableName)
+
+		// record the format of tables created before formats were supported
+		if dataSource.Status.AWSBilling == nil {
+			dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+			dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+				newDS.Status.AWSBilling = &metering.AWSBillingDataSourceStatus{Format: metering.AWSBillingFormat(format)}
+			})
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	err = op.updateAWSBillingPartitions(logger, dataSource, source, hiveTable, manifests)
@@ -396,6 +510,30 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 		return fmt.Errorf("error updating AWS billing partitions for ReportDataSource %s: %v", dataSource.Name, err)
 	}
 
+	// a billing period with a new assembly has been restated by AWS, and
+	// its partition now points at the new report
+	var previousPeriods []metering.AWSBillingPeriodStatus
+	if dataSource.Status.AWSBilling != nil {
+		previousPeriods = dataSource.Status.AWSBilling.BillingPeriods
+	}
+	periods := getAWSBillingPeriodStatuses(manifests)
+	if !awsBillingPeriodsEqual(previousPeriods, periods) {
+		restatements := detectAWSBillingRestatements(previousPeriods, periods, op.clock.Now().UTC())
+		for _, r := range restatements {
+			logger.Infof("AWS restated billing period %s-%s, assembly %s replaced by %s", reportingutil.AWSBillingPeriodTimestamp(r.BillingPeriodStart.Time), reportingutil.AWSBillingPeriodTimestamp(r.BillingPeriodEnd.Time), r.PreviousAssemblyID, r.AssemblyID)
+		}
+		// the billing periods are only recorded once the dependent Reports
+		// are flagged, so the restatements are detected again and the
+		// Reports flagged on the next sync if flagging them fails
+		if err := op.flagReportsForAWSBillingRestatements(logger, dataSource, restatements); err != nil {
+			return fmt.Errorf("error flagging Reports depending on ReportDataSource %s for regeneration: %v", dataSource.Name, err)
+		}
+		dataSource, err = op.updateAWSBillingStatus(dataSource, periods, restatements)
+		if err != nil {
+			return err
+		}
+	}
+
 	nextUpdate := op.clock.Now().Add(partitionUpdateInterval).UTC()
 
 	logger.Infof("queuing AWSBilling ReportDataSource %s to update partitions again in %s at %s", dataSource.Name, partitionUpdateInterval, nextUpdate)
@@ -410,6 +548,495 @@ func (op *defaultReportingOperator) handleAWSBillingDataSource(logger log.FieldL
 	return nil
 }
 
+func (op *defaultReportingOperator) handleAzureCostExportDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	source := dataSource.Spec.AzureCostExport.Source
+	if source == nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, source is empty", dataSource.Name)
+	}
+
+	logger.Debugf("querying container %s of storage account %s for Azure cost export runs for ReportDataSource %s", source.Container, source.StorageAccountName, dataSource.Name)
+	client, err := op.getAzureBlobClient(dataSource)
+	if err != nil {
+		return err
+	}
+	runs, err := azure.NewExportRetriever(logger, client, source.Prefix).RetrieveRuns()
+	if err != nil {
+		return err
+	}
+
+	if len(runs) == 0 {
+		logger.Warnf("ReportDataSource %q has no export runs in its container, the first export has likely not run yet", dataSource.Name)
+		op.enqueueReportDataSourceAfter(dataSource, partitionUpdateInterval)
+		return nil
+	}
+
+	var hiveTable *metering.HiveTable
+	if dataSource.Status.TableRef.Name == "" || dataSource.Status.AzureCostExport == nil {
+		logger.Infof("new AzureCostExport ReportDataSource discovered")
+		// the latest run has the columns of the current version of the
+		// exports
+		var prestoTable *metering.PrestoTable
+		hiveTable, prestoTable, err = op.createAzureCostExportTables(logger, dataSource, runs[len(runs)-1])
+		if err != nil {
+			return err
+		}
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+			newDS.Status.AzureCostExport = &metering.AzureCostExportDataSourceStatus{
+				RawTableRef: v1.LocalObjectReference{Name: hiveTable.Name},
+			}
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		hiveTable, err = op.getDataSourceRawHiveTable(dataSource.Namespace, dataSource.Status.AzureCostExport.RawTableRef.Name)
+		if err != nil {
+			return err
+		}
+		logger.Infof("existing AzureCostExport ReportDataSource discovered, tableName: %s", hiveTable.Spec.TableName)
+	}
+
+	runs = filterAzureExportRunsByColumns(logger, runs, hiveTable.Spec.Columns)
+	logger.Infof("updating partitions for Hive table %s", hiveTable.Name)
+	hiveTable.Spec.Partitions, err = getAzureCostExportPartitions(source, runs)
+	if err != nil {
+		return err
+	}
+	_, err = op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating Azure cost export partitions for ReportDataSource %s: %v", dataSource.Name, err)
+	}
+
+	periods := getAzureCostExportBillingPeriodStatuses(runs)
+	if !azureCostExportBillingPeriodsEqual(dataSource.Status.AzureCostExport.BillingPeriods, periods) {
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			if newDS.Status.AzureCostExport == nil {
+				newDS.Status.AzureCostExport = &metering.AzureCostExportDataSourceStatus{}
+			}
+			newDS.Status.AzureCostExport.BillingPeriods = periods
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	nextUpdate := op.clock.Now().Add(partitionUpdateInterval).UTC()
+
+	logger.Infof("queuing AzureCostExport ReportDataSource %s to update partitions again in %s at %s", dataSource.Name, partitionUpdateInterval, nextUpdate)
+	op.enqueueReportDataSourceAfter(dataSource, partitionUpdateInterval)
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	return nil
+}
+
+func (op *defaultReportingOperator) handleGCPBillingExportDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	source := dataSource.Spec.GCPBillingExport.Source
+	if source == nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, source is empty", dataSource.Name)
+	}
+
+	logger.Debugf("querying bucket %s for GCP billing export files for ReportDataSource %s", source.Bucket, dataSource.Name)
+	client, err := op.getGCSStorageClient(dataSource)
+	if err != nil {
+		return err
+	}
+	months, err := gcp.NewExportRetriever(logger, client, source.Prefix).RetrieveInvoiceMonths()
+	if err != nil {
+		return err
+	}
+
+	if len(months) == 0 {
+		logger.Warnf("ReportDataSource %q has no invoice months in its bucket, the billing export has likely not been written yet", dataSource.Name)
+		op.enqueueReportDataSourceAfter(dataSource, partitionUpdateInterval)
+		return nil
+	}
+
+	format, err := getGCPBillingExportFormat(dataSource, months)
+	if err != nil {
+		return err
+	}
+
+	var hiveTable *metering.HiveTable
+	if dataSource.Status.TableRef.Name == "" || dataSource.Status.GCPBillingExport == nil {
+		logger.Infof("new GCPBillingExport ReportDataSource discovered")
+		// the latest invoice month has the columns of the current version
+		// of CSV exports
+		var prestoTable *metering.PrestoTable
+		hiveTable, prestoTable, err = op.createGCPBillingExportTables(logger, dataSource, format, months[len(months)-1])
+		if err != nil {
+			return err
+		}
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+			newDS.Status.GCPBillingExport = &metering.GCPBillingExportDataSourceStatus{
+				Format:      format,
+				RawTableRef: v1.LocalObjectReference{Name: hiveTable.Name},
+			}
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		hiveTable, err = op.getDataSourceRawHiveTable(dataSource.Namespace, dataSource.Status.GCPBillingExport.RawTableRef.Name)
+		if err != nil {
+			return err
+		}
+		logger.Infof("existing GCPBillingExport ReportDataSource discovered, tableName: %s", hiveTable.Spec.TableName)
+	}
+
+	months = filterGCPInvoiceMonths(logger, months, format, hiveTable.Spec.Columns)
+	logger.Infof("updating partitions for Hive table %s", hiveTable.Name)
+	hiveTable.Spec.Partitions = getGCPBillingExportPartitions(source.Bucket, months)
+	_, err = op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error updating GCP billing export partitions for ReportDataSource %s: %v", dataSource.Name, err)
+	}
+
+	invoiceMonths := getGCPBillingExportInvoiceMonthStatuses(months)
+	if !gcpBillingExportInvoiceMonthsEqual(dataSource.Status.GCPBillingExport.InvoiceMonths, invoiceMonths) {
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			if newDS.Status.GCPBillingExport == nil {
+				newDS.Status.GCPBillingExport = &metering.GCPBillingExportDataSourceStatus{}
+			}
+			newDS.Status.GCPBillingExport.InvoiceMonths = invoiceMonths
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	nextUpdate := op.clock.Now().Add(partitionUpdateInterval).UTC()
+
+	logger.Infof("queuing GCPBillingExport ReportDataSource %s to update partitions again in %s at %s", dataSource.Name, partitionUpdateInterval, nextUpdate)
+	op.enqueueReportDataSourceAfter(dataSource, partitionUpdateInterval)
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	return nil
+}
+
+func (op *defaultReportingOperator) handleFileDropDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	template, err := validateFileDropDataSource(dataSource.Spec.FileDrop)
+	if err != nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, %v", dataSource.Name, err)
+	}
+
+	var hiveTable *metering.HiveTable
+	if dataSource.Status.TableRef.Name == "" || dataSource.Status.FileDrop == nil {
+		logger.Infof("new FileDrop ReportDataSource discovered")
+		var prestoTable *metering.PrestoTable
+		hiveTable, prestoTable, err = op.createFileDropTables(logger, dataSource, template)
+		if err != nil {
+			return err
+		}
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+			newDS.Status.FileDrop = &metering.FileDropDataSourceStatus{
+				RawTableRef: v1.LocalObjectReference{Name: hiveTable.Name},
+			}
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		hiveTable, err = op.getDataSourceRawHiveTable(dataSource.Namespace, dataSource.Status.FileDrop.RawTableRef.Name)
+		if err != nil {
+			return err
+		}
+		logger.Infof("existing FileDrop ReportDataSource discovered, tableName: %s", hiveTable.Spec.TableName)
+	}
+
+	// the partitions can't be added if the pathTemplate no longer has the
+	// partition columns of the table
+	if !reflect.DeepEqual(hiveTable.Spec.PartitionedBy, template.partitionColumns()) {
+		return fmt.Errorf("the partition columns of the pathTemplate of ReportDataSource %s differ from the table, the ReportDataSource must be recreated to change them", dataSource.Name)
+	}
+	location, err := fileDropLocation(dataSource.Spec.FileDrop)
+	if err != nil {
+		return err
+	}
+	if params := fileDropHiveTableParameters(dataSource.Spec.FileDrop, template, location); !reflect.DeepEqual(hiveTable.Spec.Columns, params.Columns) {
+		logger.Warnf("the columns of ReportDataSource %s differ from the table, the ReportDataSource must be recreated to change them", dataSource.Name)
+	}
+
+	logger.Debugf("discovering files in %s for ReportDataSource %s", location, dataSource.Name)
+	files, err := op.listFileDropFiles(logger, dataSource)
+	if err != nil {
+		return err
+	}
+	discovery := discoverFileDropFiles(files, template, dataSource.Spec.FileDrop.Format, location)
+	for _, failed := range discovery.FailedFiles {
+		logger.Warnf("ignoring file %s: %s", failed.Path, failed.Reason)
+	}
+
+	if template != nil && !reflect.DeepEqual(hiveTable.Spec.Partitions, discovery.Partitions) {
+		logger.Infof("updating partitions for Hive table %s", hiveTable.Name)
+		hiveTable.Spec.Partitions = discovery.Partitions
+		_, err = op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
+		if err != nil {
+			return fmt.Errorf("error updating file drop partitions for ReportDataSource %s: %v", dataSource.Name, err)
+		}
+	}
+
+	now := metav1.NewTime(op.clock.Now().UTC())
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		if newDS.Status.FileDrop == nil {
+			newDS.Status.FileDrop = &metering.FileDropDataSourceStatus{}
+		}
+		newDS.Status.FileDrop.LastDiscoveryTime = &now
+		newDS.Status.FileDrop.DiscoveredFiles = discovery.DiscoveredFiles
+		newDS.Status.FileDrop.FailedFiles = discovery.FailedFiles
+	})
+	if err != nil {
+		return err
+	}
+
+	nextUpdate := op.clock.Now().Add(partitionUpdateInterval).UTC()
+
+	logger.Infof("queuing FileDrop ReportDataSource %s to discover files again in %s at %s", dataSource.Name, partitionUpdateInterval, nextUpdate)
+	op.enqueueReportDataSourceAfter(dataSource, partitionUpdateInterval)
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	return nil
+}
+
+func (op *defaultReportingOperator) handleKubernetesInventoryDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	cfg, err := newKubernetesInventoryConfig(dataSource.Spec.KubernetesInventory, dataSource.Namespace, op.cfg.KubernetesInventoryAllNamespaces)
+	if err != nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, %v", dataSource.Name, err)
+	}
+
+	if dataSource.Status.TableRef.Name == "" {
+		logger.Infof("new KubernetesInventory ReportDataSource %s discovered", dataSource.Name)
+		hiveTable, err := op.createKubernetesInventoryTable(dataSource, cfg)
+		if err != nil {
+			return err
+		}
+		logger.Infof("created Hive table %s in database %s", hiveTable.Spec.TableName, hiveTable.Spec.DatabaseName)
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: hiveTable.Name}
+		})
+		if err != nil {
+			logger.WithError(err).Errorf("failed to update ReportDataSource tableRef to %s", hiveTable.Name)
+			return err
+		}
+		// snapshot once the tables of other new ReportDataSources are
+		// created, the same as Prometheus ReportDataSources
+		op.enqueueReportDataSourceAfter(dataSource, wait.Jitter(2*time.Second, 2.5))
+		return nil
+	}
+
+	// PrestoTables of HiveTables have the same name as the HiveTable
+	hiveTable, err := op.hiveTableLister.HiveTables(dataSource.Namespace).Get(dataSource.Status.TableRef.Name)
+	if err != nil {
+		return fmt.Errorf("unable to get HiveTable %s for ReportDataSource %s, %s", dataSource.Status.TableRef.Name, dataSource.Name, err)
+	}
+	if !reflect.DeepEqual(hiveTable.Spec.Columns, prestostore.KubernetesInventoryHiveColumns(cfg.columnNames())) {
+		return fmt.Errorf("the columns of ReportDataSource %s differ from the table, the ReportDataSource must be recreated to change them", dataSource.Name)
+	}
+	prestoTable, err := op.prestoTableLister.PrestoTables(dataSource.Namespace).Get(dataSource.Status.TableRef.Name)
+	if err != nil {
+		return fmt.Errorf("unable to get PrestoTable %s for ReportDataSource %s, %s", dataSource.Status.TableRef.Name, dataSource.Name, err)
+	}
+	tableName, err := reportingutil.FullyQualifiedTableName(prestoTable)
+	if err != nil {
+		return err
+	}
+
+	if updatedDS, err := op.enforceReportDataSourceRetention(logger, dataSource, prestoTable); err != nil {
+		logger.WithError(err).Errorf("error dropping expired partitions of ReportDataSource %s", dataSource.Name)
+	} else {
+		dataSource = updatedDS
+	}
+
+	now := op.clock.Now().UTC()
+	if status := dataSource.Status.KubernetesInventory; status != nil && status.LastSnapshotTime != nil {
+		if nextSnapshot := status.LastSnapshotTime.Add(cfg.interval); now.Before(nextSnapshot) {
+			op.enqueueReportDataSourceAfter(dataSource, nextSnapshot.Sub(now))
+			return nil
+		}
+	}
+
+	informer := op.getKubernetesInventoryInformer(cfg)
+	if !informer.HasSynced() {
+		logger.Infof("waiting for the cache of %s to sync before snapshotting ReportDataSource %s", cfg.resource.resource, dataSource.Name)
+		op.enqueueReportDataSourceAfter(dataSource, kubernetesInventorySyncRetryInterval)
+		return nil
+	}
+
+	logger.Infof("snapshotting %s into table %s", cfg.resource.resource, tableName)
+	stored, err := op.storeKubernetesInventorySnapshot(cfg, informer, tableName, now)
+	if err != nil {
+		return err
+	}
+
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		newDS.Status.KubernetesInventory = &metering.KubernetesInventoryDataSourceStatus{
+			LastSnapshotTime:    &metav1.Time{Time: now},
+			LastSnapshotObjects: stored,
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update ReportDataSource %s KubernetesInventory status: %v", dataSource.Name, err)
+	}
+
+	nextSnapshot := now.Add(cfg.interval)
+	logger.Infof("stored %d %s, queuing KubernetesInventory ReportDataSource %s to snapshot again in %s at %s", stored, cfg.resource.resource, dataSource.Name, cfg.interval, nextSnapshot)
+	op.enqueueReportDataSourceAfter(dataSource, cfg.interval)
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	return nil
+}
+
+func (op *defaultReportingOperator) handlePushTableDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	schema, err := validatePushTableDataSource(dataSource.Spec.PushTable)
+	if err != nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, %v", dataSource.Name, err)
+	}
+
+	if dataSource.Status.TableRef.Name == "" || dataSource.Status.PushTable == nil {
+		logger.Infof("new PushTable ReportDataSource discovered")
+		hiveTable, prestoTable, err := op.createPushTableTables(logger, dataSource, schema)
+		if err != nil {
+			return err
+		}
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+			newDS.Status.PushTable = &metering.PushTableDataSourceStatus{
+				RawTableRef: v1.LocalObjectReference{Name: hiveTable.Name},
+			}
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		hiveTable, err := op.getDataSourceRawHiveTable(dataSource.Namespace, dataSource.Status.PushTable.RawTableRef.Name)
+		if err != nil {
+			return err
+		}
+		logger.Infof("existing PushTable ReportDataSource discovered, tableName: %s", hiveTable.Spec.TableName)
+		// rows are inserted by position, so they can't be stored if the
+		// columns changed
+		if !reflect.DeepEqual(hiveTable.Spec.Columns, prestostore.PushTableHiveColumns(schema.hiveColumns(dataSource.Spec.PushTable))) {
+			return fmt.Errorf("the columns of ReportDataSource %s differ from the table, the ReportDataSource must be recreated to change them", dataSource.Name)
+		}
+	}
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	return nil
+}
+
+// getDataSourceDatabaseName returns databaseName if it's set, and otherwise
+// the Hive database of the default StorageLocation.
+func (op *defaultReportingOperator) getDataSourceDatabaseName(dataSource *metering.ReportDataSource, databaseName string) (string, error) {
+	if databaseName != "" {
+		return databaseName, nil
+	}
+	hiveStorage, err := op.getHiveStorage(nil, dataSource.Namespace)
+	if err != nil {
+		return "", fmt.Errorf("storage incorrectly configured for ReportDataSource %s, err: %s", dataSource.Name, err)
+	}
+	if hiveStorage.Status.Hive.DatabaseName == "" {
+		op.enqueueStorageLocation(hiveStorage)
+		return "", fmt.Errorf("StorageLocation %s Hive database %s does not exist yet", hiveStorage.Name, hiveStorage.Spec.Hive.DatabaseName)
+	}
+	return hiveStorage.Status.Hive.DatabaseName, nil
+}
+
+// createDataSourceRawTableAndView creates a HiveTable of the raw data of a
+// ReportDataSource from params, and a PrestoTable view of it from the query
+// returned by view, which is given the fully qualified name and the columns
+// of the raw table. The view is named after the ReportDataSource and becomes
+// its table.
+func (op *defaultReportingOperator) createDataSourceRawTableAndView(logger log.FieldLogger, dataSource *metering.ReportDataSource, params hive.TableParameters, view func(rawTableName string, hiveColumns []hive.Column) (string, []presto.Column)) (*metering.HiveTable, *metering.PrestoTable, error) {
+	logger.Infof("creating Hive table %s", params.Name)
+	resourceName := reportingutil.TableResourceNameFromKind(metering.ReportDataSourceGVK.Kind, dataSource.Namespace, dataSource.Name) + strings.Replace(dataSourceRawTableSuffix, "_", "-", -1)
+	hiveTable, err := op.createNamedHiveTableCR(resourceName, dataSource, metering.ReportDataSourceGVK, params, true, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Hive table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	hiveTable, err = op.waitForHiveTable(hiveTable.Namespace, hiveTable.Name, time.Second, 30*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Hive table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	rawPrestoTable, err := op.waitForPrestoTable(hiveTable.Namespace, hiveTable.Name, time.Second, 30*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	fullyQualifiedRawTableName, err := reportingutil.FullyQualifiedTableName(rawPrestoTable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tableName := reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name)
+	query, columns := view(fullyQualifiedRawTableName, hiveTable.Spec.Columns)
+	logger.Infof("creating view %s", tableName)
+	prestoTable, err := op.createPrestoTableCR(dataSource, metering.ReportDataSourceGVK, "hive", params.Database, tableName, columns, false, true, query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating view %s for ReportDataSource %s: %v", tableName, dataSource.Name, err)
+	}
+	prestoTable, err = op.waitForPrestoTable(prestoTable.Namespace, prestoTable.Name, time.Second, 30*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating view for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+
+	logger.Infof("created Hive table %s and view %s", params.Name, tableName)
+	return hiveTable, prestoTable, nil
+}
+
+func (op *defaultReportingOperator) getDataSourceRawHiveTable(namespace, name string) (*metering.HiveTable, error) {
+	hiveTable, err := op.hiveTableLister.HiveTables(namespace).Get(name)
+	// if not found, try for the uncached copy
+	if apierrors.IsNotFound(err) {
+		return op.meteringClient.MeteringV1().HiveTables(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the partitions of the table are updated by the caller, so don't
+	// mutate the cache
+	return hiveTable.DeepCopy(), nil
+}
+
 func (op *defaultReportingOperator) handlePrestoTableDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
 	if dataSource.Spec.PrestoTable == nil {
 		return fmt.Errorf("%s is not a PrestoTable ReportDataSource", dataSource.Name)
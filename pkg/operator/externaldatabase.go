@@ -0,0 +1,608 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+	"github.com/kube-reporting/metering-operator/pkg/util/slice"
+)
+
+const (
+	// PrestoExternalCatalogsSecretName is the Secret in the namespace of
+	// the reporting-operator holding the Presto catalogs of ExternalDatabase
+	// ReportDataSources. Presto only loads catalogs when it starts, so the
+	// metering-operator restarts Presto when the Secret changes, and
+	// changes are batched by storePrestoExternalCatalogs. The Secret is
+	// only changed if ExternalDatabaseCatalogUpdatesEnabled is set.
+	PrestoExternalCatalogsSecretName = "presto-external-catalogs"
+
+	// prestoExternalCatalogOwnerPrefix starts the comment naming the
+	// ReportDataSource which owns a catalog in the first line of its
+	// properties.
+	prestoExternalCatalogOwnerPrefix = "# ReportDataSource "
+
+	externalDatabaseUsernameSecretKey = "username"
+	externalDatabasePasswordSecretKey = "password"
+
+	// externalDatabaseCheckInterval is how often the tables of
+	// ExternalDatabase ReportDataSources are checked.
+	externalDatabaseCheckInterval = 5 * time.Minute
+	// externalDatabaseCatalogLoadInterval is how often ExternalDatabase
+	// ReportDataSources whose catalog isn't loaded by Presto yet are
+	// checked.
+	externalDatabaseCatalogLoadInterval = time.Minute
+)
+
+// externalDatabaseConnectionProperties are the JDBC connection properties
+// ExternalDatabase ReportDataSources can set, by connector. Others are
+// rejected, since properties like the socketFactory and loggerFile of
+// PostgreSQL or the queryInterceptors and allowLoadLocalInfile of MySQL
+// load classes or read and write files in Presto.
+var externalDatabaseConnectionProperties = map[string]map[string]bool{
+	"postgresql": {
+		"ApplicationName": true,
+		"connectTimeout":  true,
+		"loginTimeout":    true,
+		"readOnly":        true,
+		"socketTimeout":   true,
+		"ssl":             true,
+		"sslmode":         true,
+		"tcpKeepAlive":    true,
+	},
+	"mysql": {
+		"characterEncoding":       true,
+		"connectTimeout":          true,
+		"requireSSL":              true,
+		"serverTimezone":          true,
+		"socketTimeout":           true,
+		"sslMode":                 true,
+		"tcpKeepAlive":            true,
+		"useSSL":                  true,
+		"useUnicode":              true,
+		"verifyServerCertificate": true,
+	},
+}
+
+// externalDatabaseConfig is the validated spec of an ExternalDatabase
+// ReportDataSource.
+type externalDatabaseConfig struct {
+	// connector is the name of the Presto connector of the database.
+	connector string
+	host      string
+	port      int32
+	database  string
+	// schema is the schema of the table in the Presto catalog, which is
+	// the database for MySQL.
+	schema               string
+	table                string
+	connectionProperties map[string]string
+	columns              []presto.Column
+}
+
+// newExternalDatabaseConfig validates the spec of an ExternalDatabase
+// ReportDataSource, which can only connect to the allowedHosts.
+func newExternalDatabaseConfig(spec *metering.ExternalDatabaseDataSource, allowedHosts []string) (*externalDatabaseConfig, error) {
+	cfg := &externalDatabaseConfig{
+		host:                 spec.Host,
+		port:                 spec.Port,
+		database:             spec.Database,
+		schema:               spec.Schema,
+		table:                strings.ToLower(spec.Table),
+		connectionProperties: spec.ConnectionProperties,
+		columns:              spec.Columns,
+	}
+	switch spec.Type {
+	case metering.ExternalDatabasePostgreSQL:
+		cfg.connector = "postgresql"
+		if cfg.port == 0 {
+			cfg.port = 5432
+		}
+		if cfg.schema == "" {
+			cfg.schema = "public"
+		}
+	case metering.ExternalDatabaseMySQL:
+		cfg.connector = "mysql"
+		if cfg.port == 0 {
+			cfg.port = 3306
+		}
+		if cfg.schema != "" {
+			return nil, fmt.Errorf("spec.externalDatabase.schema must be unset for MySQL, whose databases are schemas")
+		}
+		cfg.schema = cfg.database
+	default:
+		return nil, fmt.Errorf("spec.externalDatabase.type must be %s or %s, got %q", metering.ExternalDatabasePostgreSQL, metering.ExternalDatabaseMySQL, spec.Type)
+	}
+	cfg.schema = strings.ToLower(cfg.schema)
+
+	if spec.Host == "" {
+		return nil, fmt.Errorf("spec.externalDatabase.host must be set")
+	}
+	if strings.ContainsAny(spec.Host, "/?#@ \t\r\n") {
+		return nil, fmt.Errorf("spec.externalDatabase.host %q must be a hostname or IP address", spec.Host)
+	}
+	if !externalDatabaseHostAllowed(spec.Host, allowedHosts) {
+		return nil, fmt.Errorf("spec.externalDatabase.host %q isn't one of the hosts the reporting-operator allows ExternalDatabase ReportDataSources to connect to", spec.Host)
+	}
+	if cfg.port < 1 || cfg.port > 65535 {
+		return nil, fmt.Errorf("spec.externalDatabase.port must be between 1 and 65535, got %d", cfg.port)
+	}
+	if spec.Database == "" {
+		return nil, fmt.Errorf("spec.externalDatabase.database must be set")
+	}
+	if strings.ContainsAny(spec.Database, "/?#&;= \t\r\n") {
+		return nil, fmt.Errorf("spec.externalDatabase.database %q is not a valid database name", spec.Database)
+	}
+	if spec.Table == "" {
+		return nil, fmt.Errorf("spec.externalDatabase.table must be set")
+	}
+	if spec.CredentialsSecretRef.Name == "" {
+		return nil, fmt.Errorf("spec.externalDatabase.credentialsSecretRef.name must be set")
+	}
+	keys := make([]string, 0, len(spec.ConnectionProperties))
+	for key := range spec.ConnectionProperties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	supported := externalDatabaseConnectionProperties[cfg.connector]
+	for _, key := range keys {
+		if !supported[key] {
+			supportedKeys := make([]string, 0, len(supported))
+			for supportedKey := range supported {
+				supportedKeys = append(supportedKeys, supportedKey)
+			}
+			sort.Strings(supportedKeys)
+			return nil, fmt.Errorf("spec.externalDatabase.connectionProperties has the unsupported property %q, the supported properties of %s are %s", key, spec.Type, strings.Join(supportedKeys, ", "))
+		}
+	}
+	for i, col := range spec.Columns {
+		if col.Name == "" || col.Type == "" {
+			return nil, fmt.Errorf("spec.externalDatabase.columns[%d] must have a name and a type", i)
+		}
+	}
+	return cfg, nil
+}
+
+// externalDatabaseHostAllowed returns true if host matches one of the
+// allowedHosts, which are hostnames, wildcards of subdomains like
+// *.example.com, IP addresses or CIDRs. Hostnames are compared as written,
+// so wildcards don't match IP addresses and CIDRs only match IP addresses.
+func externalDatabaseHostAllowed(host string, allowedHosts []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	ip := net.ParseIP(host)
+	for _, allowed := range allowedHosts {
+		allowed = strings.TrimSuffix(strings.ToLower(allowed), ".")
+		switch {
+		case strings.Contains(allowed, "/"):
+			_, cidr, err := net.ParseCIDR(allowed)
+			if err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(allowed, "*."):
+			if ip == nil && len(host) > len(allowed)-1 && strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		case ip != nil:
+			if allowedIP := net.ParseIP(allowed); allowedIP != nil && allowedIP.Equal(ip) {
+				return true
+			}
+		case host == allowed:
+			return true
+		}
+	}
+	return false
+}
+
+// connectionURL returns the JDBC URL of the database. The databases of
+// MySQL are the schemas of its catalog, so the URL of a MySQL database
+// doesn't include the database.
+func (cfg *externalDatabaseConfig) connectionURL() string {
+	hostPort := cfg.host
+	if strings.Contains(hostPort, ":") && !strings.HasPrefix(hostPort, "[") {
+		hostPort = "[" + hostPort + "]"
+	}
+	hostPort += ":" + strconv.Itoa(int(cfg.port))
+
+	connectionURL := fmt.Sprintf("jdbc:%s://%s", cfg.connector, hostPort)
+	if cfg.connector == "postgresql" {
+		connectionURL += "/" + cfg.database
+	}
+	if len(cfg.connectionProperties) != 0 {
+		keys := make([]string, 0, len(cfg.connectionProperties))
+		for key := range cfg.connectionProperties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		params := make([]string, len(keys))
+		for i, key := range keys {
+			params[i] = key + "=" + url.QueryEscape(cfg.connectionProperties[key])
+		}
+		connectionURL += "?" + strings.Join(params, "&")
+	}
+	return connectionURL
+}
+
+// catalogProperties returns the properties file of the Presto catalog of
+// the database, starting with a comment naming the ReportDataSource which
+// owns the catalog.
+func (cfg *externalDatabaseConfig) catalogProperties(owner, username, password string) string {
+	var b strings.Builder
+	b.WriteString(prestoExternalCatalogOwnerPrefix)
+	b.WriteString(owner)
+	b.WriteString("\n")
+	for _, prop := range [][2]string{
+		{"connector.name", cfg.connector},
+		{"connection-url", cfg.connectionURL()},
+		{"connection-user", username},
+		{"connection-password", password},
+	} {
+		b.WriteString(prop[0])
+		b.WriteString("=")
+		b.WriteString(escapePropertiesValue(prop[1]))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// escapePropertiesValue escapes s to be read as the value of a property by
+// Java's Properties.load, which reads files as ISO 8859-1 and strips
+// leading whitespace from values.
+func escapePropertiesValue(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == ' ' && i == 0:
+			b.WriteString(`\ `)
+		case r < 0x20 || r > 0x7e:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(&b, `\u%04x`, u)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// validateExternalDatabaseColumns returns an error for each of the expected
+// columns the table doesn't have. Column names are compared ignoring case,
+// since Presto lowercases them, and an expected varchar column matches
+// varchar columns of any length.
+func validateExternalDatabaseColumns(expected, actual []presto.Column) []string {
+	actualTypes := make(map[string]string, len(actual))
+	for _, col := range actual {
+		actualTypes[strings.ToLower(col.Name)] = strings.ToLower(col.Type)
+	}
+	var errs []string
+	for _, col := range expected {
+		expectedType := strings.ToLower(col.Type)
+		actualType, ok := actualTypes[strings.ToLower(col.Name)]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Sprintf("column %s is missing", col.Name))
+		case actualType == expectedType:
+		case expectedType == "varchar" && strings.HasPrefix(actualType, "varchar("):
+		default:
+			errs = append(errs, fmt.Sprintf("column %s has type %s, expected %s", col.Name, actualType, expectedType))
+		}
+	}
+	return errs
+}
+
+func (op *defaultReportingOperator) handleExternalDatabaseDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	cfg, err := newExternalDatabaseConfig(dataSource.Spec.ExternalDatabase, op.cfg.ExternalDatabaseAllowedHosts)
+	if err != nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, %v", dataSource.Name, err)
+	}
+
+	catalog := reportingutil.ExternalDatabaseCatalogName(dataSource.Namespace, dataSource.Name)
+	if op.cfg.ExternalDatabaseCatalogUpdatesEnabled {
+		properties, err := op.getExternalDatabaseCatalogProperties(dataSource, cfg)
+		if err != nil {
+			return err
+		}
+		queued, err := op.queuePrestoExternalCatalogChange(catalog, &prestoExternalCatalogChange{
+			owner:      dataSource.Namespace + "/" + dataSource.Name,
+			properties: []byte(properties),
+		})
+		if err != nil {
+			return fmt.Errorf("unable to store the Presto catalog %s of ReportDataSource %s in Secret %s: %v", catalog, dataSource.Name, PrestoExternalCatalogsSecretName, err)
+		}
+		if queued {
+			logger.Infof("the Presto catalog %s changed, it's stored in Secret %s within %s and loaded once the metering-operator restarts Presto", catalog, PrestoExternalCatalogsSecretName, op.cfg.ExternalDatabaseCatalogUpdateInterval)
+		}
+	}
+
+	status := &metering.ExternalDatabaseDataSourceStatus{
+		Catalog:       catalog,
+		LastCheckTime: &metav1.Time{Time: op.clock.Now().UTC()},
+	}
+	nextCheck := externalDatabaseCheckInterval
+	catalogs, err := op.prestoTableManager.ListCatalogs()
+	if err != nil {
+		return err
+	}
+	if !slice.ContainsString(catalogs, catalog, nil) {
+		if op.cfg.ExternalDatabaseCatalogUpdatesEnabled {
+			status.ConnectionError = fmt.Sprintf("Presto hasn't loaded the catalog %s yet, Presto loads catalogs when it's restarted by the metering-operator", catalog)
+			nextCheck = externalDatabaseCatalogLoadInterval
+		} else {
+			status.ConnectionError = fmt.Sprintf("Presto doesn't have the catalog %s, and the reporting-operator doesn't store the catalogs of ExternalDatabase ReportDataSources since storing them restarts Presto, unless externalDatabase.catalogUpdatesEnabled is set", catalog)
+		}
+	} else if columns, err := op.prestoTableManager.QueryMetadata(catalog, cfg.schema, cfg.table); err != nil {
+		status.ConnectionError = err.Error()
+	} else {
+		status.Connected = true
+		status.Columns = columns
+		status.SchemaErrors = validateExternalDatabaseColumns(cfg.columns, columns)
+	}
+
+	usable := status.Connected && len(status.SchemaErrors) == 0
+	var prestoTable *metering.PrestoTable
+	if usable && dataSource.Status.TableRef.Name == "" {
+		logger.Infof("new ExternalDatabase ReportDataSource discovered, creating PrestoTable for %s", presto.FullyQualifiedTableName(catalog, cfg.schema, cfg.table))
+		// the table belongs to the database, so the PrestoTable is
+		// unmanaged, the same as the tables of LinkExistingTable
+		// ReportDataSources
+		prestoTable, err = op.createPrestoTableCR(dataSource, metering.ReportDataSourceGVK, catalog, cfg.schema, cfg.table, status.Columns, true, false, "")
+		if err != nil {
+			return fmt.Errorf("failed to create the PrestoTable for the %s ReportDataSource: %v", dataSource.Name, err)
+		}
+		prestoTable, err = op.waitForPrestoTable(prestoTable.Namespace, prestoTable.Name, time.Second, 10*time.Second)
+		if err != nil {
+			return fmt.Errorf("error waiting for the %s PrestoTable to be created for ReportDataSource %s: %v", prestoTable.Name, dataSource.Name, err)
+		}
+	}
+
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		if prestoTable != nil {
+			newDS.Status.TableRef.Name = prestoTable.Name
+		}
+		newDS.Status.ExternalDatabase = status
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update ReportDataSource %s ExternalDatabase status: %v", dataSource.Name, err)
+	}
+
+	if !status.Connected {
+		logger.Warnf("unable to read the table of ExternalDatabase ReportDataSource %s: %s", dataSource.Name, status.ConnectionError)
+	} else if len(status.SchemaErrors) != 0 {
+		logger.Warnf("the table of ExternalDatabase ReportDataSource %s doesn't have the expected columns: %s", dataSource.Name, strings.Join(status.SchemaErrors, ", "))
+	}
+	op.enqueueReportDataSourceAfter(dataSource, nextCheck)
+
+	if usable {
+		if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+			logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+		}
+		if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+			logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+		}
+	}
+	return nil
+}
+
+// getExternalDatabaseCatalogProperties returns the catalog properties of an
+// ExternalDatabase ReportDataSource, with the credentials in its Secret.
+func (op *defaultReportingOperator) getExternalDatabaseCatalogProperties(dataSource *metering.ReportDataSource, cfg *externalDatabaseConfig) (string, error) {
+	secretName := dataSource.Spec.ExternalDatabase.CredentialsSecretRef.Name
+	secret, err := op.kubeClient.Secrets(dataSource.Namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("unable to get the credentials Secret %s of ReportDataSource %s: %v", secretName, dataSource.Name, err)
+	}
+	username, ok := secret.Data[externalDatabaseUsernameSecretKey]
+	if !ok || len(username) == 0 {
+		return "", fmt.Errorf("the credentials Secret %s of ReportDataSource %s has no %s key", secretName, dataSource.Name, externalDatabaseUsernameSecretKey)
+	}
+	password := secret.Data[externalDatabasePasswordSecretKey]
+	return cfg.catalogProperties(dataSource.Namespace+"/"+dataSource.Name, string(username), string(password)), nil
+}
+
+// prestoExternalCatalogChange is a change of a catalog in the
+// PrestoExternalCatalogsSecretName Secret waiting to be stored.
+type prestoExternalCatalogChange struct {
+	// owner is the namespace/name of the ReportDataSource of the catalog.
+	owner string
+	// properties are the new properties of the catalog, or nil if the
+	// catalog is removed.
+	properties []byte
+}
+
+// prestoExternalCatalogOwner returns the namespace/name of the
+// ReportDataSource which owns the catalog with the given properties, or
+// an empty string if the properties don't name one.
+func prestoExternalCatalogOwner(properties []byte) string {
+	line := string(properties)
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if !strings.HasPrefix(line, prestoExternalCatalogOwnerPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(line, prestoExternalCatalogOwnerPrefix)
+}
+
+// checkPrestoExternalCatalogOwner returns an error if the stored or the
+// pending properties of a catalog belong to another ReportDataSource than
+// owner.
+func checkPrestoExternalCatalogOwner(catalog, owner string, stored []byte, pending *prestoExternalCatalogChange) error {
+	if stored != nil {
+		storedOwner := prestoExternalCatalogOwner(stored)
+		if storedOwner == "" {
+			return fmt.Errorf("the Presto catalog %s wasn't stored by a ReportDataSource", catalog)
+		}
+		if storedOwner != owner {
+			return fmt.Errorf("the Presto catalog %s belongs to ReportDataSource %s", catalog, storedOwner)
+		}
+	}
+	if pending != nil && pending.owner != owner {
+		return fmt.Errorf("the Presto catalog %s belongs to ReportDataSource %s", catalog, pending.owner)
+	}
+	return nil
+}
+
+// queuePrestoExternalCatalogChange queues a change of a catalog to be stored
+// by storePrestoExternalCatalogs, and returns true if it changes the catalog
+// stored in the PrestoExternalCatalogsSecretName Secret. It returns an error
+// if the catalog belongs to another ReportDataSource, whose catalog is left
+// alone when the change removes it.
+func (op *defaultReportingOperator) queuePrestoExternalCatalogChange(catalog string, change *prestoExternalCatalogChange) (bool, error) {
+	var stored []byte
+	secret, err := op.kubeClient.Secrets(op.cfg.OwnNamespace).Get(context.TODO(), PrestoExternalCatalogsSecretName, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		stored = secret.Data[catalog+".properties"]
+	case !apierrors.IsNotFound(err):
+		return false, err
+	}
+
+	op.prestoExternalCatalogsMu.Lock()
+	defer op.prestoExternalCatalogsMu.Unlock()
+	pending := op.pendingPrestoExternalCatalogs[catalog]
+	if err := checkPrestoExternalCatalogOwner(catalog, change.owner, stored, pending); err != nil {
+		if change.properties == nil {
+			return false, nil
+		}
+		return false, err
+	}
+	if bytes.Equal(stored, change.properties) {
+		delete(op.pendingPrestoExternalCatalogs, catalog)
+		return false, nil
+	}
+	op.pendingPrestoExternalCatalogs[catalog] = change
+	return true, nil
+}
+
+// applyPrestoExternalCatalogChanges applies the changes of the catalogs to
+// the data of the PrestoExternalCatalogsSecretName Secret, skipping the
+// catalogs which belong to other ReportDataSources, and returns the stored
+// and removed catalogs.
+func applyPrestoExternalCatalogChanges(logger log.FieldLogger, data map[string][]byte, changes map[string]*prestoExternalCatalogChange) (stored, removed []string) {
+	for catalog, change := range changes {
+		key := catalog + ".properties"
+		current, ok := data[key]
+		if ok {
+			if err := checkPrestoExternalCatalogOwner(catalog, change.owner, current, nil); err != nil {
+				logger.Warnf("not storing the change of ReportDataSource %s: %v", change.owner, err)
+				continue
+			}
+		}
+		switch {
+		case change.properties == nil && ok:
+			delete(data, key)
+			removed = append(removed, catalog)
+		case change.properties != nil && !bytes.Equal(current, change.properties):
+			data[key] = change.properties
+			stored = append(stored, catalog)
+		}
+	}
+	sort.Strings(stored)
+	sort.Strings(removed)
+	return stored, removed
+}
+
+// storePrestoExternalCatalogs stores the queued changes of the catalogs
+// together in the PrestoExternalCatalogsSecretName Secret, creating it if it
+// doesn't exist. Each change of the Secret restarts Presto, so the changes
+// are stored every ExternalDatabaseCatalogUpdateInterval rather than when
+// ReportDataSources change. The changes stay queued if they can't be stored.
+func (op *defaultReportingOperator) storePrestoExternalCatalogs() {
+	op.prestoExternalCatalogsMu.Lock()
+	defer op.prestoExternalCatalogsMu.Unlock()
+	if len(op.pendingPrestoExternalCatalogs) == 0 {
+		return
+	}
+
+	var stored, removed []string
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		secrets := op.kubeClient.Secrets(op.cfg.OwnNamespace)
+		secret, err := secrets.Get(context.TODO(), PrestoExternalCatalogsSecretName, metav1.GetOptions{})
+		create := apierrors.IsNotFound(err)
+		if create {
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      PrestoExternalCatalogsSecretName,
+					Namespace: op.cfg.OwnNamespace,
+					Labels: map[string]string{
+						"app": "presto",
+					},
+				},
+				Type: corev1.SecretTypeOpaque,
+			}
+		} else if err != nil {
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+
+		stored, removed = applyPrestoExternalCatalogChanges(op.logger, secret.Data, op.pendingPrestoExternalCatalogs)
+		if len(stored) == 0 && len(removed) == 0 {
+			return nil
+		}
+		if create {
+			_, err = secrets.Create(context.TODO(), secret, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// retry as an update
+				return apierrors.NewConflict(corev1.Resource("secrets"), PrestoExternalCatalogsSecretName, err)
+			}
+			return err
+		}
+		_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		op.logger.WithError(err).Errorf("unable to store the changes of %d Presto catalogs in Secret %s, retrying in %s", len(op.pendingPrestoExternalCatalogs), PrestoExternalCatalogsSecretName, op.cfg.ExternalDatabaseCatalogUpdateInterval)
+		return
+	}
+	op.pendingPrestoExternalCatalogs = make(map[string]*prestoExternalCatalogChange)
+	if len(stored) != 0 || len(removed) != 0 {
+		op.logger.Infof("stored the Presto catalogs %v and removed the Presto catalogs %v in Secret %s, they're loaded once the metering-operator restarts Presto", stored, removed, PrestoExternalCatalogsSecretName)
+	}
+}
+
+// removePrestoExternalCatalog queues the removal of the catalog of a deleted
+// ExternalDatabase ReportDataSource from the
+// PrestoExternalCatalogsSecretName Secret. Removing a catalog restarts
+// Presto too, so nothing is removed unless
+// ExternalDatabaseCatalogUpdatesEnabled is set.
+func (op *defaultReportingOperator) removePrestoExternalCatalog(logger log.FieldLogger, namespace, name string) error {
+	if !op.cfg.ExternalDatabaseCatalogUpdatesEnabled {
+		return nil
+	}
+	catalog := reportingutil.ExternalDatabaseCatalogName(namespace, name)
+	queued, err := op.queuePrestoExternalCatalogChange(catalog, &prestoExternalCatalogChange{owner: namespace + "/" + name})
+	if err != nil {
+		return err
+	}
+	if queued {
+		logger.Infof("the Presto catalog %s is removed from Secret %s within %s", catalog, PrestoExternalCatalogsSecretName, op.cfg.ExternalDatabaseCatalogUpdateInterval)
+	}
+	return nil
+}
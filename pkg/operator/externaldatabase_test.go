@@ -0,0 +1,298 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+var testExternalDatabaseAllowedHosts = []string{"cmdb.example.com", "fd00::/64"}
+
+func newTestExternalDatabase() *metering.ExternalDatabaseDataSource {
+	return &metering.ExternalDatabaseDataSource{
+		Type:                 metering.ExternalDatabasePostgreSQL,
+		Host:                 "cmdb.example.com",
+		Database:             "cmdb",
+		Table:                "Cost_Centers",
+		CredentialsSecretRef: v1.LocalObjectReference{Name: "cmdb-credentials"},
+	}
+}
+
+func TestNewExternalDatabaseConfig(t *testing.T) {
+	tests := map[string]struct {
+		modify      func(*metering.ExternalDatabaseDataSource)
+		expected    *externalDatabaseConfig
+		expectedErr string
+	}{
+		"PostgreSQL defaults": {
+			modify: func(*metering.ExternalDatabaseDataSource) {},
+			expected: &externalDatabaseConfig{
+				connector: "postgresql",
+				host:      "cmdb.example.com",
+				port:      5432,
+				database:  "cmdb",
+				schema:    "public",
+				table:     "cost_centers",
+			},
+		},
+		"MySQL databases are schemas": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Type = metering.ExternalDatabaseMySQL
+				ds.Database = "HR"
+				ds.Port = 3307
+			},
+			expected: &externalDatabaseConfig{
+				connector: "mysql",
+				host:      "cmdb.example.com",
+				port:      3307,
+				database:  "HR",
+				schema:    "hr",
+				table:     "cost_centers",
+			},
+		},
+		"MySQL with a schema": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Type = metering.ExternalDatabaseMySQL
+				ds.Schema = "hr"
+			},
+			expectedErr: "spec.externalDatabase.schema must be unset for MySQL, whose databases are schemas",
+		},
+		"unknown type": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Type = "Oracle"
+			},
+			expectedErr: `spec.externalDatabase.type must be PostgreSQL or MySQL, got "Oracle"`,
+		},
+		"host with a path": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Host = "cmdb.example.com/other"
+			},
+			expectedErr: `spec.externalDatabase.host "cmdb.example.com/other" must be a hostname or IP address`,
+		},
+		"invalid port": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Port = 70000
+			},
+			expectedErr: "spec.externalDatabase.port must be between 1 and 65535, got 70000",
+		},
+		"database with parameters": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Database = "cmdb?ssl=false"
+			},
+			expectedErr: `spec.externalDatabase.database "cmdb?ssl=false" is not a valid database name`,
+		},
+		"missing table": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Table = ""
+			},
+			expectedErr: "spec.externalDatabase.table must be set",
+		},
+		"missing credentials": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.CredentialsSecretRef.Name = ""
+			},
+			expectedErr: "spec.externalDatabase.credentialsSecretRef.name must be set",
+		},
+		"host not allowed": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Host = "169.254.169.254"
+			},
+			expectedErr: `spec.externalDatabase.host "169.254.169.254" isn't one of the hosts the reporting-operator allows ExternalDatabase ReportDataSources to connect to`,
+		},
+		"supported connection properties": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.ConnectionProperties = map[string]string{"sslmode": "verify-full", "connectTimeout": "10"}
+			},
+			expected: &externalDatabaseConfig{
+				connector:            "postgresql",
+				host:                 "cmdb.example.com",
+				port:                 5432,
+				database:             "cmdb",
+				schema:               "public",
+				table:                "cost_centers",
+				connectionProperties: map[string]string{"sslmode": "verify-full", "connectTimeout": "10"},
+			},
+		},
+		"invalid connection property": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.ConnectionProperties = map[string]string{"ssl&user": "true"}
+			},
+			expectedErr: `spec.externalDatabase.connectionProperties has the unsupported property "ssl&user", the supported properties of PostgreSQL are ApplicationName, connectTimeout, loginTimeout, readOnly, socketTimeout, ssl, sslmode, tcpKeepAlive`,
+		},
+		"PostgreSQL socketFactory": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.ConnectionProperties = map[string]string{"sslmode": "require", "socketFactory": "org.springframework.context.support.ClassPathXmlApplicationContext"}
+			},
+			expectedErr: `spec.externalDatabase.connectionProperties has the unsupported property "socketFactory", the supported properties of PostgreSQL are ApplicationName, connectTimeout, loginTimeout, readOnly, socketTimeout, ssl, sslmode, tcpKeepAlive`,
+		},
+		"MySQL autoDeserialize": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Type = metering.ExternalDatabaseMySQL
+				ds.ConnectionProperties = map[string]string{"autoDeserialize": "true"}
+			},
+			expectedErr: `spec.externalDatabase.connectionProperties has the unsupported property "autoDeserialize", the supported properties of MySQL are characterEncoding, connectTimeout, requireSSL, serverTimezone, socketTimeout, sslMode, tcpKeepAlive, useSSL, useUnicode, verifyServerCertificate`,
+		},
+		"PostgreSQL property of MySQL": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Type = metering.ExternalDatabaseMySQL
+				ds.ConnectionProperties = map[string]string{"sslmode": "require"}
+			},
+			expectedErr: `spec.externalDatabase.connectionProperties has the unsupported property "sslmode", the supported properties of MySQL are characterEncoding, connectTimeout, requireSSL, serverTimezone, socketTimeout, sslMode, tcpKeepAlive, useSSL, useUnicode, verifyServerCertificate`,
+		},
+		"column without a type": {
+			modify: func(ds *metering.ExternalDatabaseDataSource) {
+				ds.Columns = []presto.Column{{Name: "id"}}
+			},
+			expectedErr: "spec.externalDatabase.columns[0] must have a name and a type",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ds := newTestExternalDatabase()
+			test.modify(ds)
+			cfg, err := newExternalDatabaseConfig(ds, testExternalDatabaseAllowedHosts)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, cfg)
+		})
+	}
+}
+
+func TestExternalDatabaseCatalogProperties(t *testing.T) {
+	ds := newTestExternalDatabase()
+	ds.Port = 5433
+	ds.ConnectionProperties = map[string]string{
+		"sslmode":         "verify-full",
+		"ApplicationName": "metering reports",
+	}
+	cfg, err := newExternalDatabaseConfig(ds, testExternalDatabaseAllowedHosts)
+	require.NoError(t, err)
+	properties := cfg.catalogProperties("metering/cmdb", "metering", " p\\ss\nwörd😀")
+	assert.Equal(t,
+		"# ReportDataSource metering/cmdb\n"+
+			"connector.name=postgresql\n"+
+			"connection-url=jdbc:postgresql://cmdb.example.com:5433/cmdb?ApplicationName=metering+reports&sslmode=verify-full\n"+
+			"connection-user=metering\n"+
+			`connection-password=\ p\\ss\nw\u00f6rd\ud83d\ude00`+"\n",
+		properties,
+	)
+	assert.Equal(t, "metering/cmdb", prestoExternalCatalogOwner([]byte(properties)))
+
+	ds = newTestExternalDatabase()
+	ds.Type = metering.ExternalDatabaseMySQL
+	ds.Host = "fd00::12"
+	cfg, err = newExternalDatabaseConfig(ds, testExternalDatabaseAllowedHosts)
+	require.NoError(t, err)
+	assert.Equal(t, "jdbc:mysql://[fd00::12]:3306", cfg.connectionURL())
+}
+
+func TestValidateExternalDatabaseColumns(t *testing.T) {
+	actual := []presto.Column{
+		{Name: "cost_center", Type: "varchar(32)"},
+		{Name: "owner", Type: "varchar"},
+		{Name: "budget", Type: "decimal(12,2)"},
+	}
+	assert.Empty(t, validateExternalDatabaseColumns(nil, actual))
+	assert.Empty(t, validateExternalDatabaseColumns([]presto.Column{
+		{Name: "Cost_Center", Type: "varchar"},
+		{Name: "owner", Type: "VARCHAR"},
+		{Name: "budget", Type: "decimal(12,2)"},
+	}, actual))
+	assert.Equal(t, []string{
+		"column budget has type decimal(12,2), expected double",
+		"column team is missing",
+	}, validateExternalDatabaseColumns([]presto.Column{
+		{Name: "cost_center", Type: "varchar(32)"},
+		{Name: "budget", Type: "double"},
+		{Name: "team", Type: "varchar"},
+	}, actual))
+}
+
+func TestExternalDatabaseHostAllowed(t *testing.T) {
+	allowedHosts := []string{"cmdb.example.com", "*.db.example.com", "10.1.0.0/16", "fd00::12"}
+	tests := map[string]bool{
+		"cmdb.example.com":      true,
+		"CMDB.example.com.":     true,
+		"hr.db.example.com":     true,
+		"a.hr.db.example.com":   true,
+		"db.example.com":        false,
+		"evil-db.example.com":   false,
+		"cmdb.example.com.evil": false,
+		"10.1.2.3":              true,
+		"10.2.0.1":              false,
+		"fd00::12":              true,
+		"[fd00::12]":            true,
+		"fd00:0::12":            true,
+		"fd00::13":              false,
+		"169.254.169.254":       false,
+		"localhost":             false,
+	}
+	for host, expected := range tests {
+		assert.Equal(t, expected, externalDatabaseHostAllowed(host, allowedHosts), host)
+	}
+	assert.False(t, externalDatabaseHostAllowed("cmdb.example.com", nil))
+	// wildcards only match hostnames
+	assert.False(t, externalDatabaseHostAllowed("10.0.0.1", []string{"*.0.0.1"}))
+}
+
+func TestApplyPrestoExternalCatalogChanges(t *testing.T) {
+	owned := func(owner string) []byte {
+		return []byte(prestoExternalCatalogOwnerPrefix + owner + "\nconnector.name=postgresql\n")
+	}
+	data := map[string][]byte{
+		"a.properties":      owned("metering/a"),
+		"b.properties":      owned("metering/b"),
+		"c.properties":      owned("metering/c"),
+		"other.properties":  owned("metering/other"),
+		"legacy.properties": []byte("connector.name=postgresql\n"),
+	}
+	stored, removed := applyPrestoExternalCatalogChanges(logrus.New(), data, map[string]*prestoExternalCatalogChange{
+		// unchanged
+		"a": {owner: "metering/a", properties: owned("metering/a")},
+		// changed
+		"b": {owner: "metering/b", properties: append(owned("metering/b"), "connection-user=metering\n"...)},
+		// removed
+		"c": {owner: "metering/c"},
+		// added
+		"d": {owner: "metering/d", properties: owned("metering/d")},
+		// owned by other ReportDataSources
+		"other":  {owner: "metering/d"},
+		"legacy": {owner: "metering/legacy", properties: owned("metering/legacy")},
+	})
+	assert.Equal(t, []string{"b", "d"}, stored)
+	assert.Equal(t, []string{"c"}, removed)
+	assert.Equal(t, map[string][]byte{
+		"a.properties":      owned("metering/a"),
+		"b.properties":      append(owned("metering/b"), "connection-user=metering\n"...),
+		"d.properties":      owned("metering/d"),
+		"other.properties":  owned("metering/other"),
+		"legacy.properties": []byte("connector.name=postgresql\n"),
+	}, data)
+}
+
+func TestCheckPrestoExternalCatalogOwner(t *testing.T) {
+	stored := []byte(prestoExternalCatalogOwnerPrefix + "metering/a-b\nconnector.name=postgresql\n")
+	assert.NoError(t, checkPrestoExternalCatalogOwner("c", "metering/a-b", nil, nil))
+	assert.NoError(t, checkPrestoExternalCatalogOwner("c", "metering/a-b", stored, &prestoExternalCatalogChange{owner: "metering/a-b"}))
+	assert.EqualError(t, checkPrestoExternalCatalogOwner("c", "metering/a.b", stored, nil), `the Presto catalog c belongs to ReportDataSource metering/a-b`)
+	assert.EqualError(t, checkPrestoExternalCatalogOwner("c", "metering/a.b", nil, &prestoExternalCatalogChange{owner: "metering/a-b"}), `the Presto catalog c belongs to ReportDataSource metering/a-b`)
+	assert.EqualError(t, checkPrestoExternalCatalogOwner("c", "metering/a.b", []byte("connector.name=mysql\n"), nil), "the Presto catalog c wasn't stored by a ReportDataSource")
+}
+
+func TestRemovePrestoExternalCatalogDisabled(t *testing.T) {
+	// the Secret isn't read, since the kubeClient is nil
+	op := &defaultReportingOperator{
+		pendingPrestoExternalCatalogs: make(map[string]*prestoExternalCatalogChange),
+	}
+	require.NoError(t, op.removePrestoExternalCatalog(logrus.New(), "metering", "cmdb"))
+	assert.Empty(t, op.pendingPrestoExternalCatalogs)
+}
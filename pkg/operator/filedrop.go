@@ -0,0 +1,563 @@
+package operator
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/aws"
+	"github.com/kube-reporting/metering-operator/pkg/hdfs"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const (
+	// FileDropJSONHiveRowFormat is the Hadoop serialization/deserialization
+	// implementation used with JSON Lines files.
+	FileDropJSONHiveRowFormat = GCPBillingExportJSONHiveRowFormat
+
+	defaultFileDropCSVSeparator = ","
+	defaultFileDropCSVQuote     = `"`
+	defaultFileDropCSVEscape    = `\`
+
+	// maxFileDropFileStatuses is the maximum number of discovered and failed
+	// files recorded in the status of a FileDrop ReportDataSource, which
+	// keeps the most recently modified files.
+	maxFileDropFileStatuses = 1000
+)
+
+var (
+	// fileDropPlaceholderRegexp matches the {name} placeholders of a
+	// pathTemplate.
+	fileDropPlaceholderRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+	// fileDropColumnNameRegexp matches the names of columns, which must be
+	// lowercase since Hive and Presto lowercase them.
+	fileDropColumnNameRegexp = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+	// fileDropFormatExtensions are the file extensions of each format. The
+	// files of text formats may also be compressed, which Hive detects from
+	// a .gz or .bz2 extension.
+	fileDropFormatExtensions = map[metering.FileDropFormat][]string{
+		metering.FileDropFormatCSV:     {".csv", ".tsv", ".txt"},
+		metering.FileDropFormatJSON:    {".json", ".jsonl", ".ndjson"},
+		metering.FileDropFormatParquet: {".parquet"},
+	}
+)
+
+// fileDropPathTemplate matches the directories of a pathTemplate, and
+// extracts the values of its partition columns.
+type fileDropPathTemplate struct {
+	template string
+	regexp   *regexp.Regexp
+	columns  []string
+}
+
+// parseFileDropPathTemplate parses a pathTemplate, returning nil if it's
+// empty.
+func parseFileDropPathTemplate(template string) (*fileDropPathTemplate, error) {
+	template = strings.Trim(template, "/")
+	if template == "" {
+		return nil, nil
+	}
+	var (
+		segments []string
+		columns  []string
+		seen     = make(map[string]bool)
+	)
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" {
+			return nil, fmt.Errorf("pathTemplate %q has an empty directory name", template)
+		}
+		var (
+			expr   strings.Builder
+			offset int
+		)
+		for _, match := range fileDropPlaceholderRegexp.FindAllStringSubmatchIndex(segment, -1) {
+			literal := segment[offset:match[0]]
+			if strings.ContainsAny(literal, "{}") {
+				return nil, fmt.Errorf("pathTemplate %q has an unmatched brace", template)
+			}
+			// the values of adjacent placeholders can't be told apart
+			if literal == "" && offset != 0 {
+				return nil, fmt.Errorf("pathTemplate %q has placeholders which aren't separated", template)
+			}
+			name := segment[match[2]:match[3]]
+			if !fileDropColumnNameRegexp.MatchString(name) {
+				return nil, fmt.Errorf("pathTemplate %q has an invalid placeholder {%s}, names must be lowercase letters, digits and underscores", template, name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("pathTemplate %q has the placeholder {%s} more than once", template, name)
+			}
+			seen[name] = true
+			columns = append(columns, name)
+			expr.WriteString(regexp.QuoteMeta(literal))
+			expr.WriteString(`([^/]+?)`)
+			offset = match[1]
+		}
+		literal := segment[offset:]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, fmt.Errorf("pathTemplate %q has an unmatched brace", template)
+		}
+		expr.WriteString(regexp.QuoteMeta(literal))
+		segments = append(segments, expr.String())
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("pathTemplate %q has no {name} placeholders", template)
+	}
+	return &fileDropPathTemplate{
+		template: template,
+		regexp:   regexp.MustCompile("^" + strings.Join(segments, "/") + "$"),
+		columns:  columns,
+	}, nil
+}
+
+// partitionSpec returns the values of the partition columns of dir, or false
+// if dir doesn't match the template.
+func (t *fileDropPathTemplate) partitionSpec(dir string) (hive.PartitionSpec, bool) {
+	match := t.regexp.FindStringSubmatch(dir)
+	if match == nil {
+		return nil, false
+	}
+	spec := make(hive.PartitionSpec, len(t.columns))
+	for i, col := range t.columns {
+		spec[col] = match[i+1]
+	}
+	return spec, true
+}
+
+func (t *fileDropPathTemplate) partitionColumns() []hive.Column {
+	if t == nil {
+		return nil
+	}
+	columns := make([]hive.Column, len(t.columns))
+	for i, col := range t.columns {
+		columns[i] = hive.Column{Name: col, Type: "string"}
+	}
+	return columns
+}
+
+// validateFileDropDataSource validates spec.fileDrop, and returns its parsed
+// pathTemplate.
+func validateFileDropDataSource(fileDrop *metering.FileDropDataSource) (*fileDropPathTemplate, error) {
+	switch {
+	case fileDrop.S3 == nil && fileDrop.HDFS == nil:
+		return nil, fmt.Errorf("one of spec.fileDrop.s3 and spec.fileDrop.hdfs must be set")
+	case fileDrop.S3 != nil && fileDrop.HDFS != nil:
+		return nil, fmt.Errorf("only one of spec.fileDrop.s3 and spec.fileDrop.hdfs may be set")
+	case fileDrop.S3 != nil && fileDrop.S3.Bucket == "":
+		return nil, fmt.Errorf("spec.fileDrop.s3.bucket must be set")
+	case fileDrop.HDFS != nil && fileDrop.Credentials != nil:
+		return nil, fmt.Errorf("spec.fileDrop.credentials can only be set with spec.fileDrop.s3")
+	}
+	if fileDrop.HDFS != nil {
+		if _, err := hdfs.WebHDFSEndpoint(fileDrop.HDFS.Path); err != nil {
+			return nil, fmt.Errorf("invalid spec.fileDrop.hdfs.path: %v", err)
+		}
+	}
+	if _, ok := fileDropFormatExtensions[fileDrop.Format]; !ok {
+		return nil, fmt.Errorf("invalid spec.fileDrop.format %q, must be one of %s, %s or %s", fileDrop.Format, metering.FileDropFormatCSV, metering.FileDropFormatJSON, metering.FileDropFormatParquet)
+	}
+	if fileDrop.CSV != nil {
+		if fileDrop.Format != metering.FileDropFormatCSV {
+			return nil, fmt.Errorf("spec.fileDrop.csv can only be set with the %s format", metering.FileDropFormatCSV)
+		}
+		for field, value := range map[string]string{"separator": fileDrop.CSV.Separator, "quote": fileDrop.CSV.Quote, "escape": fileDrop.CSV.Escape} {
+			if value != "" && len([]rune(value)) != 1 {
+				return nil, fmt.Errorf("spec.fileDrop.csv.%s must be a single character", field)
+			}
+		}
+	}
+
+	if len(fileDrop.Columns) == 0 {
+		return nil, fmt.Errorf("spec.fileDrop.columns must have at least one column")
+	}
+	columnNames := make(map[string]bool)
+	for _, col := range fileDrop.Columns {
+		if !fileDropColumnNameRegexp.MatchString(col.Name) {
+			return nil, fmt.Errorf("invalid column name %q, names must be lowercase letters, digits and underscores", col.Name)
+		}
+		if columnNames[col.Name] {
+			return nil, fmt.Errorf("column %s is declared more than once", col.Name)
+		}
+		columnNames[col.Name] = true
+		if _, err := reportingutil.HiveColumnToPrestoColumn(col); err != nil {
+			return nil, fmt.Errorf("invalid type %q of column %s: %v", col.Type, col.Name, err)
+		}
+		// the fields of CSV files are strings converted by the view
+		if fileDrop.Format == metering.FileDropFormatCSV && reportingutil.SimpleHiveColumnTypeToPrestoColumnType(col.Type) == "" {
+			return nil, fmt.Errorf("invalid type %q of column %s, the columns of CSV files must have a primitive type", col.Type, col.Name)
+		}
+	}
+
+	template, err := parseFileDropPathTemplate(fileDrop.PathTemplate)
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range template.partitionColumns() {
+		if columnNames[col.Name] {
+			return nil, fmt.Errorf("pathTemplate placeholder {%s} has the same name as a column", col.Name)
+		}
+	}
+	return template, nil
+}
+
+// fileDropCSVRowFormat returns the Hive row format of CSV files read using
+// opts.
+func fileDropCSVRowFormat(opts *metering.FileDropCSVOptions) string {
+	separator, quote, escape := defaultFileDropCSVSeparator, defaultFileDropCSVQuote, defaultFileDropCSVEscape
+	if opts != nil {
+		if opts.Separator != "" {
+			separator = opts.Separator
+		}
+		if opts.Quote != "" {
+			quote = opts.Quote
+		}
+		if opts.Escape != "" {
+			escape = opts.Escape
+		}
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return fmt.Sprintf(`
+SERDE 'org.apache.hadoop.hive.serde2.OpenCSVSerde'
+WITH SERDEPROPERTIES (
+    "separatorChar" = "%s",
+    "quoteChar"     = "%s",
+    "escapeChar"    = "%s"
+)
+`, escaper.Replace(separator), escaper.Replace(quote), escaper.Replace(escape))
+}
+
+// fileDropHiveTableParameters returns the parameters of the raw table of
+// fileDrop, whose files are at location. The columns of CSV files are
+// strings, since the OpenCSVSerde only reads strings.
+func fileDropHiveTableParameters(fileDrop *metering.FileDropDataSource, template *fileDropPathTemplate, location string) hive.TableParameters {
+	params := hive.TableParameters{
+		PartitionedBy: template.partitionColumns(),
+		Location:      location,
+		External:      true,
+	}
+	switch fileDrop.Format {
+	case metering.FileDropFormatCSV:
+		params.Columns = make([]hive.Column, len(fileDrop.Columns))
+		for i, col := range fileDrop.Columns {
+			params.Columns[i] = hive.Column{Name: col.Name, Type: "string"}
+		}
+		params.FileFormat = "textfile"
+		params.RowFormat = fileDropCSVRowFormat(fileDrop.CSV)
+		if fileDrop.CSV == nil || fileDrop.CSV.Header == nil || *fileDrop.CSV.Header {
+			params.TableProperties = map[string]string{
+				"skip.header.line.count": "1",
+			}
+		}
+	case metering.FileDropFormatJSON:
+		params.Columns = fileDrop.Columns
+		params.FileFormat = "textfile"
+		params.RowFormat = FileDropJSONHiveRowFormat
+	default:
+		params.Columns = fileDrop.Columns
+		params.FileFormat = "parquet"
+	}
+	return params
+}
+
+// fileDropConvert returns the expression converting the string expr into
+// prestoType.
+func fileDropConvert(expr, prestoType string) string {
+	switch strings.ToLower(prestoType) {
+	case "varchar":
+		return expr
+	case "timestamp":
+		return fmt.Sprintf("coalesce(try_cast(nullif(trim(%[1]s), '') AS timestamp), try(CAST(from_iso8601_timestamp(trim(%[1]s)) AS timestamp)))", expr)
+	case "date":
+		return fmt.Sprintf("coalesce(try_cast(nullif(trim(%[1]s), '') AS date), try(from_iso8601_date(trim(%[1]s))))", expr)
+	default:
+		return fmt.Sprintf("try_cast(nullif(trim(%s), '') AS %s)", expr, strings.ToLower(prestoType))
+	}
+}
+
+// fileDropView returns the query of a view of rawTableName with the columns
+// of fileDrop, converting the strings of CSV files to the types of the
+// columns, and the columns of the view.
+func fileDropView(fileDrop *metering.FileDropDataSource, template *fileDropPathTemplate, rawTableName string) (string, []presto.Column) {
+	var (
+		selects []string
+		columns []presto.Column
+	)
+	for _, hiveCol := range fileDrop.Columns {
+		// the columns were validated by validateFileDropDataSource
+		col, _ := reportingutil.HiveColumnToPrestoColumn(hiveCol)
+		expr := presto.QuoteIdentifier(col.Name)
+		if fileDrop.Format == metering.FileDropFormatCSV {
+			expr = fileDropConvert(expr, col.Type)
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, presto.QuoteIdentifier(col.Name)))
+		columns = append(columns, col)
+	}
+	for _, partitionCol := range template.partitionColumns() {
+		selects = append(selects, presto.QuoteIdentifier(partitionCol.Name))
+		columns = append(columns, presto.Column{Name: partitionCol.Name, Type: "varchar"})
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), rawTableName)
+	return query, columns
+}
+
+// fileDropLocation returns the location of the S3 prefix or HDFS directory
+// of fileDrop, ending with a slash.
+func fileDropLocation(fileDrop *metering.FileDropDataSource) (string, error) {
+	if fileDrop.S3 != nil {
+		return hive.S3Location(fileDrop.S3.Bucket, fileDrop.S3.Prefix)
+	}
+	u, err := url.Parse(fileDrop.HDFS.Path)
+	if err != nil {
+		return "", err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/"
+	return u.String(), nil
+}
+
+// fileDropFile is a file in the S3 prefix or HDFS directory of a FileDrop
+// ReportDataSource.
+type fileDropFile struct {
+	// Path is relative to the S3 prefix or HDFS directory.
+	Path         string
+	Size         int64
+	LastModified time.Time
+}
+
+type fileDropDiscovery struct {
+	Partitions      []metering.HiveTablePartition
+	DiscoveredFiles []metering.FileDropFileStatus
+	FailedFiles     []metering.FileDropFailedFileStatus
+}
+
+// fileDropHidden returns true if Hive ignores the file at p, which it does
+// for files and directories starting with a dot or an underscore, such as
+// _SUCCESS markers.
+func fileDropHidden(p string) bool {
+	for _, name := range strings.Split(p, "/") {
+		if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			return true
+		}
+	}
+	return false
+}
+
+// fileDropExtensionReason returns why a file named name can't be read as
+// format, or an empty string if it can.
+func fileDropExtensionReason(name string, format metering.FileDropFormat) string {
+	extensions := fileDropFormatExtensions[format]
+	if format != metering.FileDropFormatParquet {
+		name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".bz2")
+	}
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range extensions {
+		if ext == e {
+			return ""
+		}
+	}
+	return fmt.Sprintf("the extension of the file isn't one of %s for %s files", strings.Join(extensions, ", "), format)
+}
+
+// discoverFileDropFiles returns the partitions of the directories of files
+// matching template, and the files which can't be read by the table. Since
+// Hive reads every file in the directory of a partition, the partition of a
+// directory containing a file that can't be read isn't added. Without a
+// template, the table reads the files directly in location.
+func discoverFileDropFiles(files []fileDropFile, template *fileDropPathTemplate, format metering.FileDropFormat, location string) fileDropDiscovery {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	var (
+		discovery fileDropDiscovery
+		dirs      []string
+		dirSpecs  = make(map[string]hive.PartitionSpec)
+		dirFiles  = make(map[string][]fileDropFile)
+		// failedDirs are the directories containing files which can't be
+		// read, and reasons is why each of those files can't be read
+		failedDirs = make(map[string]bool)
+		reasons    = make(map[string]string)
+	)
+	fail := func(file fileDropFile, reason string) {
+		discovery.FailedFiles = append(discovery.FailedFiles, metering.FileDropFailedFileStatus{Path: file.Path, Reason: reason})
+	}
+	for _, file := range files {
+		// directory markers of S3
+		if file.Path == "" || strings.HasSuffix(file.Path, "/") || fileDropHidden(file.Path) {
+			continue
+		}
+		dir := path.Dir(file.Path)
+		if dir == "." {
+			dir = ""
+		}
+
+		var spec hive.PartitionSpec
+		if template == nil && dir != "" {
+			fail(file, "the file is in a subdirectory, which isn't read without a pathTemplate")
+			continue
+		}
+		if template != nil {
+			var ok bool
+			if spec, ok = template.partitionSpec(dir); !ok {
+				fail(file, fmt.Sprintf("the directory of the file doesn't match the pathTemplate %s", template.template))
+				continue
+			}
+		}
+		if _, ok := dirFiles[dir]; !ok {
+			dirs = append(dirs, dir)
+			dirSpecs[dir] = spec
+		}
+		dirFiles[dir] = append(dirFiles[dir], file)
+
+		reason := fileDropExtensionReason(file.Path, format)
+		if reason == "" && format == metering.FileDropFormatParquet && file.Size == 0 {
+			reason = "the file is empty, which isn't a valid Parquet file"
+		}
+		if reason != "" {
+			fail(file, reason)
+			failedDirs[dir] = true
+			reasons[file.Path] = reason
+		}
+	}
+
+	for _, dir := range dirs {
+		// without a template, the table reads the files even if some of
+		// them can't be read
+		skipDir := failedDirs[dir] && template != nil
+		for _, file := range dirFiles[dir] {
+			switch {
+			case reasons[file.Path] != "":
+			case skipDir:
+				fail(file, fmt.Sprintf("the partition of directory %s isn't added, since it contains files which can't be read", dir))
+			default:
+				discovery.DiscoveredFiles = append(discovery.DiscoveredFiles, metering.FileDropFileStatus{
+					Path:         file.Path,
+					Size:         file.Size,
+					LastModified: metav1.NewTime(file.LastModified.UTC()),
+				})
+			}
+		}
+		if template != nil && !skipDir {
+			discovery.Partitions = append(discovery.Partitions, metering.HiveTablePartition{
+				Location:      location + dir + "/",
+				PartitionSpec: dirSpecs[dir],
+			})
+		}
+	}
+
+	sort.Slice(discovery.FailedFiles, func(i, j int) bool {
+		return discovery.FailedFiles[i].Path < discovery.FailedFiles[j].Path
+	})
+	discovery.DiscoveredFiles = limitFileDropFileStatuses(discovery.DiscoveredFiles)
+	if len(discovery.FailedFiles) > maxFileDropFileStatuses {
+		discovery.FailedFiles = discovery.FailedFiles[:maxFileDropFileStatuses]
+	}
+	return discovery
+}
+
+// limitFileDropFileStatuses returns the maxFileDropFileStatuses most recently
+// modified files, sorted by path.
+func limitFileDropFileStatuses(files []metering.FileDropFileStatus) []metering.FileDropFileStatus {
+	if len(files) <= maxFileDropFileStatuses {
+		return files
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].LastModified.After(files[j].LastModified.Time)
+	})
+	files = files[:maxFileDropFileStatuses]
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// listFileDropFiles returns the files in the S3 prefix or HDFS directory of
+// dataSource.
+func (op *defaultReportingOperator) listFileDropFiles(logger log.FieldLogger, dataSource *metering.ReportDataSource) ([]fileDropFile, error) {
+	fileDrop := dataSource.Spec.FileDrop
+	var files []fileDropFile
+	if fileDrop.S3 != nil {
+		s3Options, err := op.getS3Options(dataSource.Namespace, fileDrop.S3, fileDrop.Credentials, "spec.fileDrop.credentials")
+		if err != nil {
+			return nil, err
+		}
+		lister, err := aws.NewObjectLister(logger, fileDrop.S3.Region, fileDrop.S3.Bucket, op.cfg.ProxyTrustedCABundle, s3Options)
+		if err != nil {
+			return nil, err
+		}
+		prefix := strings.Trim(fileDrop.S3.Prefix, "/")
+		if prefix != "" {
+			prefix += "/"
+		}
+		objects, err := lister.ListObjects(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			files = append(files, fileDropFile{
+				Path:         strings.TrimPrefix(obj.Key, prefix),
+				Size:         obj.Size,
+				LastModified: obj.LastModified,
+			})
+		}
+		return files, nil
+	}
+
+	endpoint := fileDrop.HDFS.WebHDFSEndpoint
+	if endpoint == "" {
+		var err error
+		endpoint, err = hdfs.WebHDFSEndpoint(fileDrop.HDFS.Path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := hdfs.NewClient(endpoint, fileDrop.HDFS.User, op.cfg.ProxyTrustedCABundle)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(fileDrop.HDFS.Path)
+	if err != nil {
+		return nil, err
+	}
+	hdfsFiles, err := client.ListFiles(u.Path)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range hdfsFiles {
+		files = append(files, fileDropFile{
+			Path:         f.Path,
+			Size:         f.Length,
+			LastModified: f.ModificationTime,
+		})
+	}
+	return files, nil
+}
+
+// createFileDropTables creates the external HiveTable of the files of
+// dataSource, partitioned by the placeholders of template, and a PrestoTable
+// view of it with the declared columns.
+func (op *defaultReportingOperator) createFileDropTables(logger log.FieldLogger, dataSource *metering.ReportDataSource, template *fileDropPathTemplate) (*metering.HiveTable, *metering.PrestoTable, error) {
+	fileDrop := dataSource.Spec.FileDrop
+	dbName, err := op.getDataSourceDatabaseName(dataSource, fileDrop.DatabaseName)
+	if err != nil {
+		return nil, nil, err
+	}
+	location, err := fileDropLocation(fileDrop)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := fileDropHiveTableParameters(fileDrop, template, location)
+	params.Database = dbName
+	params.Name = reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name) + dataSourceRawTableSuffix
+	return op.createDataSourceRawTableAndView(logger, dataSource, params, func(rawTableName string, _ []hive.Column) (string, []presto.Column) {
+		return fileDropView(fileDrop, template, rawTableName)
+	})
+}
@@ -0,0 +1,191 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reporting"
+)
+
+func TestParseFileDropPathTemplate(t *testing.T) {
+	template, err := parseFileDropPathTemplate("/year={year}/month={month}/")
+	require.NoError(t, err)
+	assert.Equal(t, []hive.Column{{Name: "year", Type: "string"}, {Name: "month", Type: "string"}}, template.partitionColumns())
+
+	spec, ok := template.partitionSpec("year=2024/month=01")
+	require.True(t, ok)
+	assert.Equal(t, hive.PartitionSpec{"year": "2024", "month": "01"}, spec)
+	for _, dir := range []string{"", "year=2024", "year=2024/month=01/extra", "2024/01", "year=2024/month="} {
+		_, ok := template.partitionSpec(dir)
+		assert.False(t, ok, "directory %q shouldn't match", dir)
+	}
+
+	template, err = parseFileDropPathTemplate("{vendor}/{start}-{end}")
+	require.NoError(t, err)
+	spec, ok = template.partitionSpec("acme/202401-202402")
+	require.True(t, ok)
+	assert.Equal(t, hive.PartitionSpec{"vendor": "acme", "start": "202401", "end": "202402"}, spec)
+
+	template, err = parseFileDropPathTemplate("")
+	require.NoError(t, err)
+	assert.Nil(t, template)
+	assert.Nil(t, template.partitionColumns())
+
+	for _, invalid := range []string{"licenses", "{year}{month}", "{Year}", "{year}/{year}", "{year", "a//{b}"} {
+		_, err := parseFileDropPathTemplate(invalid)
+		assert.Error(t, err, "pathTemplate %q should be invalid", invalid)
+	}
+}
+
+func TestValidateFileDropDataSource(t *testing.T) {
+	newFileDrop := func() *metering.FileDropDataSource {
+		return &metering.FileDropDataSource{
+			S3:           &metering.S3Bucket{Bucket: "costs", Prefix: "licenses", Region: "us-east-1"},
+			Format:       metering.FileDropFormatCSV,
+			Columns:      []hive.Column{{Name: "product", Type: "string"}, {Name: "cost", Type: "double"}},
+			PathTemplate: "{month}",
+		}
+	}
+	template, err := validateFileDropDataSource(newFileDrop())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"month"}, template.columns)
+
+	for name, modify := range map[string]func(*metering.FileDropDataSource){
+		"no source": func(f *metering.FileDropDataSource) { f.S3 = nil },
+		"two sources": func(f *metering.FileDropDataSource) {
+			f.HDFS = &metering.HDFSDirectory{Path: "hdfs://namenode:9820/costs"}
+		},
+		"invalid format": func(f *metering.FileDropDataSource) { f.Format = "Avro" },
+		"no columns":     func(f *metering.FileDropDataSource) { f.Columns = nil },
+		"duplicate column": func(f *metering.FileDropDataSource) {
+			f.Columns = append(f.Columns, hive.Column{Name: "cost", Type: "double"})
+		},
+		"uppercase column":    func(f *metering.FileDropDataSource) { f.Columns[0].Name = "Product" },
+		"nested CSV column":   func(f *metering.FileDropDataSource) { f.Columns[0].Type = "array<string>" },
+		"partition collision": func(f *metering.FileDropDataSource) { f.PathTemplate = "{cost}" },
+		"long separator":      func(f *metering.FileDropDataSource) { f.CSV = &metering.FileDropCSVOptions{Separator: "||"} },
+		"CSV options on JSON": func(f *metering.FileDropDataSource) {
+			f.Format = metering.FileDropFormatJSON
+			f.CSV = &metering.FileDropCSVOptions{Separator: ";"}
+		},
+		"HDFS path without namenode": func(f *metering.FileDropDataSource) {
+			f.S3 = nil
+			f.HDFS = &metering.HDFSDirectory{Path: "/costs"}
+		},
+	} {
+		fileDrop := newFileDrop()
+		modify(fileDrop)
+		_, err := validateFileDropDataSource(fileDrop)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFileDropHiveTableParametersAndView(t *testing.T) {
+	header := false
+	fileDrop := &metering.FileDropDataSource{
+		HDFS:    &metering.HDFSDirectory{Path: "hdfs://hdfs-namenode-0.hdfs-namenode:9820/filedrop/licenses"},
+		Format:  metering.FileDropFormatCSV,
+		CSV:     &metering.FileDropCSVOptions{Header: &header, Separator: "\t", Quote: "'"},
+		Columns: []hive.Column{{Name: "product", Type: "string"}, {Name: "cost", Type: "double"}, {Name: "day", Type: "date"}},
+	}
+	template, err := validateFileDropDataSource(fileDrop)
+	require.NoError(t, err)
+	location, err := fileDropLocation(fileDrop)
+	require.NoError(t, err)
+	assert.Equal(t, "hdfs://hdfs-namenode-0.hdfs-namenode:9820/filedrop/licenses/", location)
+
+	params := fileDropHiveTableParameters(fileDrop, template, location)
+	assert.True(t, params.External)
+	assert.Equal(t, []hive.Column{{Name: "product", Type: "string"}, {Name: "cost", Type: "string"}, {Name: "day", Type: "string"}}, params.Columns)
+	assert.Nil(t, params.PartitionedBy)
+	assert.Nil(t, params.TableProperties, "the first line shouldn't be skipped without a header")
+	assert.Contains(t, params.RowFormat, "\"separatorChar\" = \"\t\"")
+	assert.Contains(t, params.RowFormat, `"quoteChar"     = "'"`)
+	assert.Contains(t, params.RowFormat, `"escapeChar"    = "\\"`)
+
+	query, columns := fileDropView(fileDrop, template, "hive.metering.datasource_metering_licenses_raw")
+	assert.Equal(t, `SELECT "product" AS "product", try_cast(nullif(trim("cost"), '') AS double) AS "cost", coalesce(try_cast(nullif(trim("day"), '') AS date), try(from_iso8601_date(trim("day")))) AS "day" FROM hive.metering.datasource_metering_licenses_raw`, query)
+	assert.Len(t, columns, 3)
+
+	fileDrop.Format = metering.FileDropFormatParquet
+	fileDrop.CSV = nil
+	fileDrop.PathTemplate = "{month}"
+	template, err = validateFileDropDataSource(fileDrop)
+	require.NoError(t, err)
+	params = fileDropHiveTableParameters(fileDrop, template, location)
+	assert.Equal(t, fileDrop.Columns, params.Columns)
+	assert.Equal(t, "parquet", params.FileFormat)
+	assert.Equal(t, []hive.Column{{Name: "month", Type: "string"}}, params.PartitionedBy)
+	query, columns = fileDropView(fileDrop, template, "hive.metering.datasource_metering_licenses_raw")
+	assert.Equal(t, `SELECT "product" AS "product", "cost" AS "cost", "day" AS "day", "month" FROM hive.metering.datasource_metering_licenses_raw`, query)
+	assert.Equal(t, "month", columns[len(columns)-1].Name)
+}
+
+func TestDiscoverFileDropFiles(t *testing.T) {
+	modified := time.Date(2024, time.February, 1, 8, 0, 0, 0, time.UTC)
+	files := []fileDropFile{
+		{Path: "2024-02/licenses.csv.gz", Size: 20, LastModified: modified},
+		{Path: "2024-01/licenses.csv", Size: 10, LastModified: modified},
+		{Path: "2024-01/_SUCCESS", LastModified: modified},
+		{Path: "2024-03/licenses.csv", Size: 30, LastModified: modified},
+		{Path: "2024-03/licenses.xlsx", Size: 30, LastModified: modified},
+		{Path: "2024-03/", LastModified: modified},
+		{Path: "README.md", Size: 5, LastModified: modified},
+	}
+	template, err := parseFileDropPathTemplate("{month}")
+	require.NoError(t, err)
+	discovery := discoverFileDropFiles(files, template, metering.FileDropFormatCSV, "s3a://costs/licenses/")
+
+	assert.Equal(t, []metering.HiveTablePartition{
+		{Location: "s3a://costs/licenses/2024-01/", PartitionSpec: hive.PartitionSpec{"month": "2024-01"}},
+		{Location: "s3a://costs/licenses/2024-02/", PartitionSpec: hive.PartitionSpec{"month": "2024-02"}},
+	}, discovery.Partitions)
+	assert.Equal(t, []metering.FileDropFileStatus{
+		{Path: "2024-01/licenses.csv", Size: 10, LastModified: metav1.NewTime(modified)},
+		{Path: "2024-02/licenses.csv.gz", Size: 20, LastModified: metav1.NewTime(modified)},
+	}, discovery.DiscoveredFiles)
+	require.Len(t, discovery.FailedFiles, 3)
+	assert.Equal(t, "2024-03/licenses.csv", discovery.FailedFiles[0].Path)
+	assert.Contains(t, discovery.FailedFiles[0].Reason, "partition of directory 2024-03 isn't added")
+	assert.Equal(t, "2024-03/licenses.xlsx", discovery.FailedFiles[1].Path)
+	assert.Contains(t, discovery.FailedFiles[1].Reason, "extension")
+	assert.Equal(t, "README.md", discovery.FailedFiles[2].Path)
+	assert.Contains(t, discovery.FailedFiles[2].Reason, "pathTemplate")
+
+	// without a template, the files directly in the location are read
+	discovery = discoverFileDropFiles([]fileDropFile{
+		{Path: "licenses-2024-01.parquet", Size: 10, LastModified: modified},
+		{Path: "licenses-2024-02.parquet", LastModified: modified},
+		{Path: "2024/licenses.parquet", Size: 10, LastModified: modified},
+	}, nil, metering.FileDropFormatParquet, "s3a://costs/licenses/")
+	assert.Empty(t, discovery.Partitions)
+	assert.Equal(t, []metering.FileDropFileStatus{
+		{Path: "licenses-2024-01.parquet", Size: 10, LastModified: metav1.NewTime(modified)},
+	}, discovery.DiscoveredFiles)
+	require.Len(t, discovery.FailedFiles, 2)
+	assert.Contains(t, discovery.FailedFiles[0].Reason, "subdirectory")
+	assert.Contains(t, discovery.FailedFiles[1].Reason, "empty")
+}
+
+func TestDiscoverFileDropFilesQuotedKey(t *testing.T) {
+	// anyone able to write to the bucket controls the values of the
+	// partition columns and the locations of the partitions
+	template, err := parseFileDropPathTemplate("{month}")
+	require.NoError(t, err)
+	discovery := discoverFileDropFiles([]fileDropFile{
+		{Path: "2024-01') LOCATION 'file:/licenses.csv", Size: 10},
+	}, template, metering.FileDropFormatCSV, "s3a://costs/licenses/")
+	require.Len(t, discovery.Partitions, 1)
+	partition := discovery.Partitions[0]
+	assert.Equal(t, hive.PartitionSpec{"month": "2024-01') LOCATION 'file:"}, partition.PartitionSpec)
+
+	columns := template.partitionColumns()
+	assert.Equal(t, "`month`='2024-01\\') LOCATION \\'file:'", reporting.FmtPartitionSpec(columns, partition.PartitionSpec))
+	assert.Equal(t, "'s3a://costs/licenses/2024-01\\') LOCATION \\'file:/'", hive.QuoteString(partition.Location))
+}
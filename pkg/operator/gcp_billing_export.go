@@ -0,0 +1,328 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/gcp"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const (
+	// GCPBillingExportJSONHiveRowFormat is the Hadoop
+	// serialization/deserialization implementation used with newline
+	// delimited JSON billing exports.
+	GCPBillingExportJSONHiveRowFormat = `SERDE 'org.apache.hive.hcatalog.data.JsonSerDe'`
+
+	gcpServiceAccountSecretKey = "gcs-service-account.json"
+
+	invoiceMonthPartitionColumnName = "invoice_month"
+)
+
+// GCPBillingExportHivePartitions are the partition columns of the raw table
+// of a GCPBillingExport ReportDataSource.
+var GCPBillingExportHivePartitions = []hive.Column{
+	{Name: invoiceMonthPartitionColumnName, Type: "string"},
+}
+
+// gcpBillingExportHiveColumns returns the columns of the raw table of JSON
+// or Parquet billing exports, which have the nested fields of the standard
+// usage cost export table. Timestamps are strings in JSON exports.
+func gcpBillingExportHiveColumns(format metering.GCPBillingExportFormat) []hive.Column {
+	timestampType := "timestamp"
+	if format == metering.GCPBillingExportFormatJSON {
+		timestampType = "string"
+	}
+	const labelsType = "array<struct<key:string,value:string>>"
+	return []hive.Column{
+		{Name: "billing_account_id", Type: "string"},
+		{Name: "service", Type: "struct<id:string,description:string>"},
+		{Name: "sku", Type: "struct<id:string,description:string>"},
+		{Name: "usage_start_time", Type: timestampType},
+		{Name: "usage_end_time", Type: timestampType},
+		{Name: "project", Type: "struct<id:string,number:string,name:string,labels:" + labelsType + ",ancestry_numbers:string>"},
+		{Name: "labels", Type: labelsType},
+		{Name: "system_labels", Type: labelsType},
+		{Name: "location", Type: "struct<location:string,country:string,region:string,zone:string>"},
+		{Name: "resource", Type: "struct<name:string,global_name:string>"},
+		{Name: "export_time", Type: timestampType},
+		{Name: "cost", Type: "double"},
+		{Name: "currency", Type: "string"},
+		{Name: "currency_conversion_rate", Type: "double"},
+		{Name: "usage", Type: "struct<amount:double,unit:string,amount_in_pricing_units:double,pricing_unit:string>"},
+		{Name: "credits", Type: "array<struct<name:string,amount:double,full_name:string,id:string,type:string>>"},
+		{Name: "cost_type", Type: "string"},
+	}
+}
+
+// gcpBillingExportCSVHiveColumns returns the columns of the raw table of CSV
+// billing exports, which are all strings as the OpenCSVSerde only supports
+// string columns.
+func gcpBillingExportCSVHiveColumns(columns []string) []hive.Column {
+	hiveColumns := make([]hive.Column, len(columns))
+	for i, col := range columns {
+		// the nested fields are flattened into columns such as
+		// service.description, which becomes service_description
+		hiveColumns[i] = hive.Column{Name: csvHeaderColumnName(col), Type: "string"}
+	}
+	return hiveColumns
+}
+
+// gcpBillingExportViewColumn is a column of the view of the raw table of a
+// GCPBillingExport ReportDataSource.
+type gcpBillingExportViewColumn struct {
+	Name string
+	Type string
+	// Nested is the expression of the column in the raw table of JSON and
+	// Parquet exports.
+	Nested string
+	// CSV are the names of the columns of CSV exports which may hold the
+	// column, in order of preference.
+	CSV []string
+}
+
+var gcpBillingExportViewColumns = []gcpBillingExportViewColumn{
+	{Name: "billing_account_id", Type: "varchar", Nested: "billing_account_id", CSV: []string{"billing_account_id"}},
+	{Name: "service_id", Type: "varchar", Nested: "service.id", CSV: []string{"service_id"}},
+	{Name: "service_description", Type: "varchar", Nested: "service.description", CSV: []string{"service_description"}},
+	{Name: "sku_id", Type: "varchar", Nested: "sku.id", CSV: []string{"sku_id"}},
+	{Name: "sku_description", Type: "varchar", Nested: "sku.description", CSV: []string{"sku_description"}},
+	{Name: "usage_start_time", Type: "timestamp", Nested: "usage_start_time", CSV: []string{"usage_start_time"}},
+	{Name: "usage_end_time", Type: "timestamp", Nested: "usage_end_time", CSV: []string{"usage_end_time"}},
+	{Name: "project_id", Type: "varchar", Nested: "project.id", CSV: []string{"project_id"}},
+	{Name: "project_number", Type: "varchar", Nested: "project.number", CSV: []string{"project_number"}},
+	{Name: "project_name", Type: "varchar", Nested: "project.name", CSV: []string{"project_name"}},
+	{Name: "project_labels", Type: "map(varchar,varchar)", Nested: "map_from_entries(project.labels)", CSV: []string{"project_labels"}},
+	{Name: "labels", Type: "map(varchar,varchar)", Nested: "map_from_entries(labels)", CSV: []string{"labels"}},
+	{Name: "system_labels", Type: "map(varchar,varchar)", Nested: "map_from_entries(system_labels)", CSV: []string{"system_labels"}},
+	{Name: "location", Type: "varchar", Nested: "location.location", CSV: []string{"location_location", "location"}},
+	{Name: "location_country", Type: "varchar", Nested: "location.country", CSV: []string{"location_country"}},
+	{Name: "location_region", Type: "varchar", Nested: "location.region", CSV: []string{"location_region"}},
+	{Name: "location_zone", Type: "varchar", Nested: "location.zone", CSV: []string{"location_zone"}},
+	{Name: "resource_name", Type: "varchar", Nested: "resource.name", CSV: []string{"resource_name"}},
+	{Name: "resource_global_name", Type: "varchar", Nested: "resource.global_name", CSV: []string{"resource_global_name"}},
+	{Name: "export_time", Type: "timestamp", Nested: "export_time", CSV: []string{"export_time"}},
+	{Name: "cost", Type: "double", Nested: "cost", CSV: []string{"cost"}},
+	{Name: "currency", Type: "varchar", Nested: "currency", CSV: []string{"currency"}},
+	{Name: "currency_conversion_rate", Type: "double", Nested: "currency_conversion_rate", CSV: []string{"currency_conversion_rate"}},
+	{Name: "usage_amount", Type: "double", Nested: "usage.amount", CSV: []string{"usage_amount"}},
+	{Name: "usage_unit", Type: "varchar", Nested: "usage.unit", CSV: []string{"usage_unit"}},
+	{Name: "usage_amount_in_pricing_units", Type: "double", Nested: "usage.amount_in_pricing_units", CSV: []string{"usage_amount_in_pricing_units"}},
+	{Name: "usage_pricing_unit", Type: "varchar", Nested: "usage.pricing_unit", CSV: []string{"usage_pricing_unit"}},
+	{Name: "credits_amount", Type: "double", Nested: "coalesce(reduce(credits, 0.0, (s, c) -> s + coalesce(c.amount, 0.0), s -> s), 0.0)", CSV: []string{"credits_amount"}},
+	{Name: "cost_type", Type: "varchar", Nested: "cost_type", CSV: []string{"cost_type"}},
+}
+
+// gcpBillingExportConvert returns the expression converting the string expr
+// into colType.
+func gcpBillingExportConvert(expr, colType string) string {
+	switch colType {
+	case "double":
+		return fmt.Sprintf("try_cast(nullif(trim(%s), '') AS double)", expr)
+	case "timestamp":
+		// BigQuery extracts timestamps as 2024-01-01 00:00:00 UTC, with
+		// fractional seconds if they're non-zero
+		return fmt.Sprintf("coalesce(try(date_parse(%[1]s, '%%Y-%%m-%%d %%H:%%i:%%s UTC')), try(date_parse(%[1]s, '%%Y-%%m-%%d %%H:%%i:%%s.%%f UTC')), try(CAST(from_iso8601_timestamp(%[1]s) AS timestamp)))", expr)
+	case "map(varchar,varchar)":
+		// labels are either a JSON object or an array of key/value objects
+		return fmt.Sprintf("coalesce(try(CAST(json_parse(%[1]s) AS map(varchar,varchar))), try(map_from_entries(CAST(json_parse(%[1]s) AS array(row(key varchar, value varchar))))))", expr)
+	default:
+		return expr
+	}
+}
+
+// gcpBillingExportView returns the query of a view of rawTableName with the
+// nested fields of the billing export flattened into typed columns, and the
+// columns of the view. CSV columns missing from hiveColumns are null.
+func gcpBillingExportView(format metering.GCPBillingExportFormat, rawTableName string, hiveColumns []hive.Column) (string, []presto.Column) {
+	csvColumns := make(map[string]bool)
+	for _, col := range hiveColumns {
+		csvColumns[col.Name] = true
+	}
+	var (
+		selects []string
+		columns []presto.Column
+	)
+	for _, col := range gcpBillingExportViewColumns {
+		var expr string
+		switch format {
+		case metering.GCPBillingExportFormatCSV:
+			expr = fmt.Sprintf("CAST(NULL AS %s)", col.Type)
+			for _, name := range col.CSV {
+				if csvColumns[name] {
+					expr = gcpBillingExportConvert(presto.QuoteIdentifier(name), col.Type)
+					break
+				}
+			}
+		case metering.GCPBillingExportFormatJSON:
+			expr = col.Nested
+			if col.Type == "timestamp" {
+				expr = gcpBillingExportConvert(expr, col.Type)
+			}
+		default:
+			expr = col.Nested
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %s", expr, presto.QuoteIdentifier(col.Name)))
+		columns = append(columns, presto.Column{Name: col.Name, Type: col.Type})
+	}
+	for _, partitionCol := range GCPBillingExportHivePartitions {
+		selects = append(selects, presto.QuoteIdentifier(partitionCol.Name))
+		columns = append(columns, presto.Column{Name: partitionCol.Name, Type: "varchar"})
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), rawTableName)
+	return query, columns
+}
+
+// gcsLocation returns the gs URI of dir in bucket, which is how Hive and
+// Presto address Cloud Storage.
+func gcsLocation(bucket, dir string) string {
+	location := fmt.Sprintf("gs://%s/", bucket)
+	dir = strings.Trim(dir, "/")
+	if dir != "" {
+		location += dir + "/"
+	}
+	return location
+}
+
+// getGCPBillingExportFormat returns the format of the billing exports of
+// dataSource. The format of an existing table can't change, since its
+// columns depend on it. New tables use spec.gcpBillingExport.format, or the
+// format of the latest invoice month if it's unset.
+func getGCPBillingExportFormat(dataSource *metering.ReportDataSource, months []*gcp.InvoiceMonth) (metering.GCPBillingExportFormat, error) {
+	specFormat := dataSource.Spec.GCPBillingExport.Format
+	switch specFormat {
+	case "", metering.GCPBillingExportFormatJSON, metering.GCPBillingExportFormatCSV, metering.GCPBillingExportFormatParquet:
+	default:
+		return "", fmt.Errorf("invalid spec.gcpBillingExport.format %q, must be one of %s, %s or %s", specFormat, metering.GCPBillingExportFormatJSON, metering.GCPBillingExportFormatCSV, metering.GCPBillingExportFormatParquet)
+	}
+	if status := dataSource.Status.GCPBillingExport; status != nil && status.Format != "" {
+		if specFormat != "" && specFormat != status.Format {
+			return "", fmt.Errorf("the table of ReportDataSource %s was created for %s exports, and must be deleted to use %s exports", dataSource.Name, status.Format, specFormat)
+		}
+		return status.Format, nil
+	}
+	if specFormat != "" {
+		return specFormat, nil
+	}
+	return metering.GCPBillingExportFormat(months[len(months)-1].Format), nil
+}
+
+// filterGCPInvoiceMonths returns the invoice months with the format of the
+// table, and for CSV tables the same columns as the table, since the columns
+// of CSV files are read by position.
+func filterGCPInvoiceMonths(logger log.FieldLogger, months []*gcp.InvoiceMonth, format metering.GCPBillingExportFormat, hiveColumns []hive.Column) []*gcp.InvoiceMonth {
+	var filtered []*gcp.InvoiceMonth
+	for _, month := range months {
+		if metering.GCPBillingExportFormat(month.Format) != format {
+			logger.Warnf("ignoring invoice month %s with %s files, the table is for %s files", month.Directory, month.Format, format)
+			continue
+		}
+		if format == metering.GCPBillingExportFormatCSV && !reflect.DeepEqual(gcpBillingExportCSVHiveColumns(month.Columns), hiveColumns) {
+			logger.Warnf("ignoring invoice month %s with columns which differ from the table, recreate the ReportDataSource to use the columns of the latest exports", month.Directory)
+			continue
+		}
+		filtered = append(filtered, month)
+	}
+	return filtered
+}
+
+func getGCPBillingExportPartitions(bucket string, months []*gcp.InvoiceMonth) []metering.HiveTablePartition {
+	partitions := make([]metering.HiveTablePartition, 0, len(months))
+	for _, month := range months {
+		partitions = append(partitions, metering.HiveTablePartition{
+			Location: gcsLocation(bucket, month.Directory),
+			PartitionSpec: hive.PartitionSpec{
+				invoiceMonthPartitionColumnName: month.Month,
+			},
+		})
+	}
+	return partitions
+}
+
+func getGCPBillingExportInvoiceMonthStatuses(months []*gcp.InvoiceMonth) []metering.GCPBillingExportInvoiceMonthStatus {
+	statuses := make([]metering.GCPBillingExportInvoiceMonthStatus, 0, len(months))
+	for _, month := range months {
+		statuses = append(statuses, metering.GCPBillingExportInvoiceMonthStatus{
+			Month:       month.Month,
+			LastUpdated: metav1.NewTime(month.LastUpdated.UTC()),
+		})
+	}
+	return statuses
+}
+
+func gcpBillingExportInvoiceMonthsEqual(a, b []metering.GCPBillingExportInvoiceMonthStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Month != b[i].Month || !a[i].LastUpdated.Equal(&b[i].LastUpdated) {
+			return false
+		}
+	}
+	return true
+}
+
+// getGCSStorageClient returns a client for the bucket of dataSource, using
+// the service account key in spec.gcpBillingExport.source.secretRef if it's
+// set.
+func (op *defaultReportingOperator) getGCSStorageClient(dataSource *metering.ReportDataSource) (*gcp.StorageClient, error) {
+	source := dataSource.Spec.GCPBillingExport.Source
+	var serviceAccountKey []byte
+	if source.SecretRef != nil {
+		var err error
+		serviceAccountKey, err = op.getSecretKey(dataSource.Namespace, &v1.SecretKeySelector{
+			LocalObjectReference: *source.SecretRef,
+			Key:                  gcpServiceAccountSecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return gcp.NewStorageClient(context.TODO(), source.Bucket, source.Endpoint, serviceAccountKey, op.cfg.ProxyTrustedCABundle)
+}
+
+// createGCPBillingExportTables creates the HiveTable of the exported files in
+// format, with the columns of month for CSV files, and a PrestoTable view of
+// it with the nested fields flattened into typed columns.
+func (op *defaultReportingOperator) createGCPBillingExportTables(logger log.FieldLogger, dataSource *metering.ReportDataSource, format metering.GCPBillingExportFormat, month *gcp.InvoiceMonth) (*metering.HiveTable, *metering.PrestoTable, error) {
+	dbName, err := op.getDataSourceDatabaseName(dataSource, dataSource.Spec.GCPBillingExport.DatabaseName)
+	if err != nil {
+		return nil, nil, err
+	}
+	source := dataSource.Spec.GCPBillingExport.Source
+	params := hive.TableParameters{
+		Database:      dbName,
+		Name:          reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name) + dataSourceRawTableSuffix,
+		PartitionedBy: GCPBillingExportHivePartitions,
+		Location:      gcsLocation(source.Bucket, source.Prefix),
+		External:      true,
+	}
+	switch format {
+	case metering.GCPBillingExportFormatCSV:
+		params.Columns = gcpBillingExportCSVHiveColumns(month.Columns)
+		params.FileFormat = "textfile"
+		params.RowFormat = csvHiveRowFormat
+		params.TableProperties = map[string]string{
+			"skip.header.line.count": "1",
+		}
+	case metering.GCPBillingExportFormatJSON:
+		params.Columns = gcpBillingExportHiveColumns(format)
+		params.FileFormat = "textfile"
+		params.RowFormat = GCPBillingExportJSONHiveRowFormat
+	default:
+		params.Columns = gcpBillingExportHiveColumns(format)
+		params.FileFormat = "parquet"
+	}
+	return op.createDataSourceRawTableAndView(logger, dataSource, params, func(rawTableName string, hiveColumns []hive.Column) (string, []presto.Column) {
+		return gcpBillingExportView(format, rawTableName, hiveColumns)
+	})
+}
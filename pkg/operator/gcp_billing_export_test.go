@@ -0,0 +1,111 @@
+package operator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/gcp"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+)
+
+func TestGCPBillingExportView(t *testing.T) {
+	for _, format := range []metering.GCPBillingExportFormat{metering.GCPBillingExportFormatJSON, metering.GCPBillingExportFormatParquet} {
+		hiveColumns := gcpBillingExportHiveColumns(format)
+		// every column of the raw table must be usable by Presto
+		for _, col := range hiveColumns {
+			_, err := reportingutil.HiveColumnToPrestoColumn(col)
+			assert.NoError(t, err, "column %s", col.Name)
+		}
+
+		query, columns := gcpBillingExportView(format, "hive.metering.datasource_metering_gcp_raw", hiveColumns)
+		require.Len(t, columns, len(gcpBillingExportViewColumns)+1)
+		assert.Equal(t, "invoice_month", columns[len(columns)-1].Name)
+		assert.Contains(t, query, `service.description AS "service_description"`)
+		assert.Contains(t, query, `map_from_entries(labels) AS "labels"`)
+		assert.True(t, strings.HasSuffix(query, `"invoice_month" FROM hive.metering.datasource_metering_gcp_raw`), "unexpected query: %s", query)
+		if format == metering.GCPBillingExportFormatJSON {
+			assert.Contains(t, query, `try(date_parse(usage_start_time, '%Y-%m-%d %H:%i:%s UTC'))`)
+		} else {
+			assert.Contains(t, query, `usage_start_time AS "usage_start_time"`)
+		}
+	}
+
+	hiveColumns := gcpBillingExportCSVHiveColumns([]string{"billing_account_id", "service.description", "Cost", "labels"})
+	assert.Equal(t, []hive.Column{
+		{Name: "billing_account_id", Type: "string"},
+		{Name: "service_description", Type: "string"},
+		{Name: "cost", Type: "string"},
+		{Name: "labels", Type: "string"},
+	}, hiveColumns)
+	query, _ := gcpBillingExportView(metering.GCPBillingExportFormatCSV, "hive.metering.datasource_metering_gcp_raw", hiveColumns)
+	assert.Contains(t, query, `"service_description" AS "service_description"`)
+	assert.Contains(t, query, `CAST(NULL AS varchar) AS "service_id"`)
+	assert.Contains(t, query, `try_cast(nullif(trim("cost"), '') AS double) AS "cost"`)
+	assert.Contains(t, query, `try(CAST(json_parse("labels") AS map(varchar,varchar)))`)
+	assert.Contains(t, query, `CAST(NULL AS map(varchar,varchar)) AS "system_labels"`)
+}
+
+func TestGetGCPBillingExportFormat(t *testing.T) {
+	months := []*gcp.InvoiceMonth{
+		{Month: "202401", Format: gcp.ExportFormatCSV},
+		{Month: "202402", Format: gcp.ExportFormatJSON},
+	}
+	newDataSource := func(specFormat, statusFormat metering.GCPBillingExportFormat) *metering.ReportDataSource {
+		ds := &metering.ReportDataSource{}
+		ds.Spec.GCPBillingExport = &metering.GCPBillingExportDataSource{Format: specFormat}
+		if statusFormat != "" {
+			ds.Status.TableRef = v1.LocalObjectReference{Name: "table"}
+			ds.Status.GCPBillingExport = &metering.GCPBillingExportDataSourceStatus{Format: statusFormat}
+		}
+		return ds
+	}
+
+	format, err := getGCPBillingExportFormat(newDataSource("", ""), months)
+	require.NoError(t, err)
+	assert.Equal(t, metering.GCPBillingExportFormatJSON, format, "the format of the latest month should be used")
+
+	format, err = getGCPBillingExportFormat(newDataSource(metering.GCPBillingExportFormatParquet, ""), months)
+	require.NoError(t, err)
+	assert.Equal(t, metering.GCPBillingExportFormatParquet, format)
+
+	format, err = getGCPBillingExportFormat(newDataSource("", metering.GCPBillingExportFormatCSV), months)
+	require.NoError(t, err)
+	assert.Equal(t, metering.GCPBillingExportFormatCSV, format, "the format of an existing table should be kept")
+
+	_, err = getGCPBillingExportFormat(newDataSource(metering.GCPBillingExportFormatJSON, metering.GCPBillingExportFormatCSV), months)
+	assert.Error(t, err)
+	_, err = getGCPBillingExportFormat(newDataSource("Avro", ""), months)
+	assert.Error(t, err)
+}
+
+func TestGetGCPBillingExportPartitions(t *testing.T) {
+	updated := time.Date(2024, time.February, 2, 5, 0, 0, 0, time.UTC)
+	months := []*gcp.InvoiceMonth{
+		{Month: "202401", Directory: "billing/invoice_month=202401", Format: gcp.ExportFormatCSV, LastUpdated: updated, Columns: []string{"cost"}},
+		{Month: "202402", Directory: "billing/invoice_month=202402", Format: gcp.ExportFormatCSV, LastUpdated: updated, Columns: []string{"cost", "labels"}},
+		{Month: "202403", Directory: "billing/202403", Format: gcp.ExportFormatJSON, LastUpdated: updated},
+	}
+	months = filterGCPInvoiceMonths(logrus.New(), months, metering.GCPBillingExportFormatCSV, gcpBillingExportCSVHiveColumns([]string{"cost"}))
+	require.Len(t, months, 1)
+
+	assert.Equal(t, []metering.HiveTablePartition{
+		{
+			Location:      "gs://exports/billing/invoice_month=202401/",
+			PartitionSpec: hive.PartitionSpec{"invoice_month": "202401"},
+		},
+	}, getGCPBillingExportPartitions("exports", months))
+	assert.Equal(t, "gs://exports/", gcsLocation("exports", "/"))
+
+	statuses := getGCPBillingExportInvoiceMonthStatuses(months)
+	assert.True(t, gcpBillingExportInvoiceMonthsEqual(statuses, getGCPBillingExportInvoiceMonthStatuses(months)))
+	months[0].LastUpdated = updated.Add(time.Hour)
+	assert.False(t, gcpBillingExportInvoiceMonthsEqual(statuses, getGCPBillingExportInvoiceMonthStatuses(months)))
+}
@@ -119,6 +119,7 @@ func TestGetPartitionChanges(t *testing.T) {
 			changes := getPartitionChanges(partitionColumns, test.current, test.desired)
 			assert.Equal(t, test.expectedToAdd, changes.toAddPartitions, "to add should match expected to add")
 			assert.Equal(t, test.expectedToRemove, changes.toRemovePartitions, "to remove should match expected to remove")
+			assert.Equal(t, test.expectedToUpdate, changes.toUpdatePartitions, "to update should match expected to update")
 		})
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -107,6 +108,11 @@ func (op *defaultReportingOperator) handleHiveTable(logger log.FieldLogger, hive
 
 	if hiveTable.Status.TableName != "" {
 		logger.Infof("HiveTable %s already created", hiveTable.Name)
+		var err error
+		hiveTable, err = op.addHiveTableColumns(logger, hiveTable)
+		if err != nil {
+			return err
+		}
 	} else {
 		logger.Infof("creating table %s in Hive", hiveTable.Spec.TableName)
 
@@ -228,13 +234,33 @@ func (op *defaultReportingOperator) handleHiveTable(logger log.FieldLogger, hive
 		logger.Debugf("partitions to add: [%s]", strings.Join(toAddPartitionsList, ", "))
 		logger.Debugf("partitions to update: [%s]", strings.Join(toUpdatePartitionsList, ", "))
 
-		// We append toUpdatePartitions to both slices because we process an
-		// update by removing it first, then adding it back with the new
-		// values.
-		toRemove := append(changes.toRemovePartitions, changes.toUpdatePartitions...)
-		toAdd := append(changes.toAddPartitions, changes.toUpdatePartitions...)
+		// Partitions whose location changed are pointed at their new
+		// location in place, so queries never see the partition missing.
+		// A partition can't be set to an empty location, so those are
+		// updated by removing the partition first, then adding it back.
+		toRemove := changes.toRemovePartitions
+		toAdd := changes.toAddPartitions
+		var toSetLocation []metering.HiveTablePartition
+		for _, p := range changes.toUpdatePartitions {
+			if p.Location == "" {
+				toRemove = append(toRemove, p)
+				toAdd = append(toAdd, p)
+			} else {
+				toSetLocation = append(toSetLocation, p)
+			}
+		}
 
 		tableName := hiveTable.Status.TableName
+		for _, p := range toSetLocation {
+			partSpecStr := reporting.FmtPartitionSpec(hivePartitionColumns, p.PartitionSpec)
+			logger.Debugf("setting location of partition %s of Hive table %q to %s", partSpecStr, tableName, p.Location)
+			err := op.hivePartitionManager.SetPartitionLocation(hiveTable.Status.DatabaseName, tableName, hivePartitionColumns, hive.TablePartition(p))
+			if err != nil {
+				return fmt.Errorf("failed to set location of partition %s of Hive table %q to %s: %s", partSpecStr, tableName, p.Location, err)
+			}
+			logger.Debugf("partition successfully set location of partition %s of Hive table %q to %s", partSpecStr, tableName, p.Location)
+		}
+
 		for _, p := range toRemove {
 			partSpecStr := reporting.FmtPartitionSpec(hivePartitionColumns, p.PartitionSpec)
 			locStr := ""
@@ -275,6 +301,56 @@ func (op *defaultReportingOperator) handleHiveTable(logger log.FieldLogger, hive
 	return nil
 }
 
+// addHiveTableColumns adds any columns appended to spec.columns since the
+// table was created to the table in Hive, and to the PrestoTable for the
+// HiveTable.
+func (op *defaultReportingOperator) addHiveTableColumns(logger log.FieldLogger, hiveTable *metering.HiveTable) (*metering.HiveTable, error) {
+	if len(hiveTable.Status.Columns) == 0 {
+		return hiveTable, nil
+	}
+	added, compatible := reportingutil.DiffHiveColumns(hiveTable.Status.Columns, hiveTable.Spec.Columns)
+	if !compatible {
+		return nil, fmt.Errorf("spec.columns of HiveTable %s can only be changed by appending new columns", hiveTable.Name)
+	}
+	if len(added) == 0 {
+		return hiveTable, nil
+	}
+
+	logger.Infof("adding %d columns to table %s in Hive", len(added), hiveTable.Status.TableName)
+	err := op.hiveTableManager.AddColumns(hiveTable.Status.DatabaseName, hiveTable.Status.TableName, added)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't add columns to table %s in Hive: %v", hiveTable.Status.TableName, err)
+	}
+
+	prestoColumns, err := reportingutil.HiveColumnsToPrestoColumns(append(hiveTable.Spec.Columns, hiveTable.Spec.PartitionedBy...))
+	if err != nil {
+		return nil, fmt.Errorf("unable to update PrestoTable %s, error converting Hive columns to Presto columns: %s", hiveTable.Name, err)
+	}
+	prestoTable, err := op.prestoTableLister.PrestoTables(hiveTable.Namespace).Get(hiveTable.Name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get PrestoTable %s: %v", hiveTable.Name, err)
+	}
+	prestoTable = prestoTable.DeepCopy()
+	prestoTable.Spec.Columns = prestoColumns
+	prestoTable.Status.Columns = prestoColumns
+	prestoTable, err = op.meteringClient.MeteringV1().PrestoTables(prestoTable.Namespace).Update(context.TODO(), prestoTable, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to update PrestoTable %s columns: %v", prestoTable.Name, err)
+	}
+
+	hiveTable.Status.Columns = hiveTable.Spec.Columns
+	hiveTable, err = op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("successfully added %d columns to table %s in Hive", len(added), hiveTable.Status.TableName)
+
+	if err := op.queueDependentsOfPrestoTable(prestoTable); err != nil {
+		logger.WithError(err).Errorf("error queuing dependents of PrestoTable %s", prestoTable.Name)
+	}
+	return hiveTable, nil
+}
+
 func (op *defaultReportingOperator) addHiveTableFinalizer(hiveTable *metering.HiveTable) (*metering.HiveTable, error) {
 	hiveTable.Finalizers = append(hiveTable.Finalizers, hiveTableFinalizer)
 	newHiveTable, err := op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
@@ -307,8 +383,12 @@ func hiveTableNeedsFinalizer(hiveTable *metering.HiveTable) bool {
 }
 
 func (op *defaultReportingOperator) createHiveTableCR(obj metav1.Object, gvk schema.GroupVersionKind, params hive.TableParameters, managePartitions bool, partitions []hive.TablePartition) (*metering.HiveTable, error) {
+	resourceName := reportingutil.TableResourceNameFromKind(gvk.Kind, obj.GetNamespace(), obj.GetName())
+	return op.createNamedHiveTableCR(resourceName, obj, gvk, params, managePartitions, partitions)
+}
+
+func (op *defaultReportingOperator) createNamedHiveTableCR(resourceName string, obj metav1.Object, gvk schema.GroupVersionKind, params hive.TableParameters, managePartitions bool, partitions []hive.TablePartition) (*metering.HiveTable, error) {
 	apiVersion := gvk.GroupVersion().String()
-	kind := gvk.Kind
 	name := obj.GetName()
 	namespace := obj.GetNamespace()
 	objLabels := obj.GetLabels()
@@ -331,7 +411,6 @@ func (op *defaultReportingOperator) createHiveTableCR(obj metav1.Object, gvk sch
 		})
 	}
 
-	resourceName := reportingutil.TableResourceNameFromKind(kind, namespace, name)
 	newHiveTable := &metering.HiveTable{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "HiveTable",
@@ -403,6 +482,27 @@ func (op *defaultReportingOperator) waitForHiveTable(namespace, name string, pol
 	return hiveTable, nil
 }
 
+// waitForHiveTableColumns waits until the columns of the HiveTable in Hive
+// match the given columns.
+func (op *defaultReportingOperator) waitForHiveTableColumns(namespace, name string, columns []hive.Column, pollInterval, timeout time.Duration) (*metering.HiveTable, error) {
+	var hiveTable *metering.HiveTable
+	err := wait.Poll(pollInterval, timeout, func() (bool, error) {
+		var err error
+		hiveTable, err = op.meteringClient.MeteringV1().HiveTables(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return reflect.DeepEqual(hiveTable.Status.Columns, columns), nil
+	})
+	if err != nil {
+		if err == wait.ErrWaitTimeout {
+			return nil, errors.New("timed out waiting for Hive table columns to be added")
+		}
+		return nil, err
+	}
+	return hiveTable, nil
+}
+
 func (op *defaultReportingOperator) dropHiveTable(hiveTable *metering.HiveTable) error {
 	tableName := hiveTable.Status.TableName
 	databaseName := hiveTable.Status.DatabaseName
@@ -431,6 +531,9 @@ func getPartitionChanges(partitionColumns []hive.Column, current []metering.Hive
 	desiredSet := sets.NewString()
 	// lookup a map used to go back from setID to Partition
 	lookup := make(map[string]metering.HiveTablePartition)
+	// currentLocations is the location of each current partition, used to
+	// only update partitions whose location changed
+	currentLocations := make(map[string]string)
 
 	for _, p := range current {
 		var vals []string
@@ -439,6 +542,7 @@ func getPartitionChanges(partitionColumns []hive.Column, current []metering.Hive
 		}
 		setID := strings.Join(vals, "-")
 		lookup[setID] = p
+		currentLocations[setID] = p.Location
 		currSet.Insert(setID)
 	}
 	for _, p := range desired {
@@ -466,6 +570,9 @@ func getPartitionChanges(partitionColumns []hive.Column, current []metering.Hive
 	}
 	for _, setID := range toUpdate.UnsortedList() {
 		p := lookup[setID]
+		if p.Location == currentLocations[setID] {
+			continue
+		}
 		changes.toUpdatePartitions = append(changes.toUpdatePartitions, p)
 	}
 
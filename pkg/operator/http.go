@@ -22,6 +22,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
 	meteringUtil "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1/util"
@@ -41,17 +43,31 @@ const (
 	APIV1ReportGetEndpoint         = "/api/v1/reports/get"
 	APIV2ReportEndpointPrefix      = "/api/v2/reports"
 	APIV2ReportQueryEndpointPrefix = "/api/v2/reportqueries"
+	APIV2DependencyGraphEndpoint   = "/api/v2/dependencygraph"
+
+	APIV1PrometheusRemoteWriteEndpointPrefix = "/api/v1/datasources/prometheus/write"
+	APIV1PushTableEndpointPrefix             = "/api/v1/datasources/push"
 )
 
 type server struct {
 	logger log.FieldLogger
 
 	rand          *rand.Rand
+	clock         clock.Clock
 	collectorFunc prometheusImporterFunc
+	// defaultStepSize is the time precision recorded for metrics received
+	// by remote_write when their ReportDataSource doesn't configure one.
+	defaultStepSize time.Duration
+	// remoteWriteTracker records the metrics received by remote_write for
+	// the operator to update the status of their ReportDataSources.
+	remoteWriteTracker *remoteWriteTracker
 
 	prometheusMetricsRepo prestostore.PrometheusMetricsRepo
+	pushTableRepo         prestostore.PushTableStorer
 	reportResultsGetter   prestostore.ReportResultsGetter
 	dependencyResolver    DependencyResolver
+	// secretsGetter gets the token Secrets of PushTable ReportDataSources.
+	secretsGetter corev1.SecretsGetter
 
 	reportLister           listers.ReportLister
 	reportDataSourceLister listers.ReportDataSourceLister
@@ -70,10 +86,15 @@ func (l *requestLogger) Print(v ...interface{}) {
 func newRouter(
 	logger log.FieldLogger,
 	rand *rand.Rand,
+	clock clock.Clock,
 	prometheusMetricsRepo prestostore.PrometheusMetricsRepo,
+	pushTableRepo prestostore.PushTableStorer,
 	reportResultsGetter prestostore.ReportResultsGetter,
 	depResolver DependencyResolver,
+	secretsGetter corev1.SecretsGetter,
 	collectorFunc prometheusImporterFunc,
+	defaultStepSize time.Duration,
+	remoteWriteTracker *remoteWriteTracker,
 	reportLister listers.ReportLister,
 	reportDataSourceLister listers.ReportDataSourceLister,
 	reportQueryLister listers.ReportQueryLister,
@@ -88,10 +109,15 @@ func newRouter(
 	srv := &server{
 		logger:                 logger,
 		rand:                   rand,
+		clock:                  clock,
 		collectorFunc:          collectorFunc,
+		defaultStepSize:        defaultStepSize,
+		remoteWriteTracker:     remoteWriteTracker,
 		prometheusMetricsRepo:  prometheusMetricsRepo,
+		pushTableRepo:          pushTableRepo,
 		reportResultsGetter:    reportResultsGetter,
 		dependencyResolver:     depResolver,
+		secretsGetter:          secretsGetter,
 		reportLister:           reportLister,
 		reportDataSourceLister: reportDataSourceLister,
 		reportQueryLister:      reportQueryLister,
@@ -100,12 +126,16 @@ func newRouter(
 
 	router.HandleFunc(APIV2ReportEndpointPrefix+"/{namespace}/{name}/full", srv.getReportV2FullHandler)
 	router.HandleFunc(APIV2ReportEndpointPrefix+"/{namespace}/{name}/table", srv.getReportV2TableHandler)
+	router.HandleFunc(APIV2ReportEndpointPrefix+"/{namespace}/{name}/dependencygraph", srv.getReportDependencyGraphV2Handler)
 	router.HandleFunc(APIV2ReportQueryEndpointPrefix+"/{namespace}/{name}/render", srv.renderReportQueryV2Handler)
+	router.HandleFunc(APIV2DependencyGraphEndpoint+"/{namespace}", srv.getNamespaceDependencyGraphV2Handler)
 	router.HandleFunc(APIV1ReportGetEndpoint, srv.getReportV1Handler)
 	router.HandleFunc("/api/v1/datasources/prometheus/collect/{namespace}", srv.collectPrometheusMetricsDataHandler)
 	router.HandleFunc("/api/v1/datasources/prometheus/collect/{namespace}/{datasourceName}", srv.collectPrometheusMetricsDataHandler)
 	router.HandleFunc("/api/v1/datasources/prometheus/store/{namespace}/{datasourceName}", srv.storePrometheusMetricsDataHandler)
 	router.HandleFunc("/api/v1/datasources/prometheus/fetch/{namespace}/{datasourceName}", srv.fetchPrometheusMetricsDataHandler)
+	router.HandleFunc(APIV1PrometheusRemoteWriteEndpointPrefix+"/{namespace}", srv.prometheusRemoteWriteHandler)
+	router.HandleFunc(APIV1PushTableEndpointPrefix+"/{namespace}/{name}", srv.pushTableHandler)
 
 	return router
 }
@@ -782,3 +812,93 @@ func (srv *server) renderReportQueryV2Handler(w http.ResponseWriter, r *http.Req
 		logger.WithError(err).Error("failed writing HTTP response")
 	}
 }
+
+func (srv *server) validateGetDependencyGraphReq(logger log.FieldLogger, w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != "GET" {
+		writeErrorResponse(logger, w, r, http.StatusNotFound, "Not found")
+		return "", false
+	}
+	err := r.ParseForm()
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusBadRequest, "couldn't parse URL query params: %v", err)
+		return "", false
+	}
+	format := r.Form.Get("format")
+	switch format {
+	case "":
+		return "json", true
+	case "json", "dot":
+		return format, true
+	}
+	writeErrorResponse(logger, w, r, http.StatusBadRequest, "format must be one of: json or dot")
+	return "", false
+}
+
+func (srv *server) getReportDependencyGraphV2Handler(w http.ResponseWriter, r *http.Request) {
+	logger := newRequestLogger(srv.logger, r, srv.rand)
+	name := chi.URLParam(r, "name")
+	namespace := chi.URLParam(r, "namespace")
+	format, ok := srv.validateGetDependencyGraphReq(logger, w, r)
+	if !ok {
+		return
+	}
+
+	report, err := srv.reportLister.Reports(namespace).Get(name)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if k8serrors.IsNotFound(err) {
+			code = http.StatusNotFound
+		}
+		logger.WithError(err).Errorf("error getting report: %v", err)
+		writeErrorResponse(logger, w, r, code, "error getting report: %v", err)
+		return
+	}
+
+	graph := srv.dependencyResolver.ResolveReportDependencyGraph(report)
+	writeDependencyGraphResponse(logger, format, report.Name, graph, w, r)
+}
+
+func (srv *server) getNamespaceDependencyGraphV2Handler(w http.ResponseWriter, r *http.Request) {
+	logger := newRequestLogger(srv.logger, r, srv.rand)
+	namespace := chi.URLParam(r, "namespace")
+	format, ok := srv.validateGetDependencyGraphReq(logger, w, r)
+	if !ok {
+		return
+	}
+
+	reports, err := srv.reportLister.Reports(namespace).List(labels.Everything())
+	if err != nil {
+		logger.WithError(err).Errorf("error listing reports: %v", err)
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "error listing reports: %v", err)
+		return
+	}
+	queries, err := srv.reportQueryLister.ReportQueries(namespace).List(labels.Everything())
+	if err != nil {
+		logger.WithError(err).Errorf("error listing reportQueries: %v", err)
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "error listing reportQueries: %v", err)
+		return
+	}
+	datasources, err := srv.reportDataSourceLister.ReportDataSources(namespace).List(labels.Everything())
+	if err != nil {
+		logger.WithError(err).Errorf("error listing reportDataSources: %v", err)
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "error listing reportDataSources: %v", err)
+		return
+	}
+
+	graph := srv.dependencyResolver.ResolveNamespaceDependencyGraph(namespace, reports, queries, datasources)
+	writeDependencyGraphResponse(logger, format, namespace, graph, w, r)
+}
+
+func writeDependencyGraphResponse(logger log.FieldLogger, format, name string, graph *reporting.DependencyGraph, w http.ResponseWriter, r *http.Request) {
+	switch format {
+	case "json":
+		writeResponseAsJSON(logger, w, http.StatusOK, graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%s.dot", name))
+		w.WriteHeader(http.StatusOK)
+		if err := graph.WriteDOT(w); err != nil {
+			logger.WithError(err).Error("failed writing HTTP response")
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/cache"
 
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
@@ -49,12 +50,17 @@ func apiReportV2URLTable(namespace, reportName string) string {
 type fakePrometheusMetricsRepo struct {
 	metrics map[string][]*prestostore.PrometheusMetric
 	err     error
+	// storeErrs are returned when storing metrics in their table
+	storeErrs map[string]error
 }
 
 func (f *fakePrometheusMetricsRepo) StorePrometheusMetrics(ctx context.Context, tableName string, metrics []*prestostore.PrometheusMetric) error {
 	if f.err != nil {
 		return f.err
 	}
+	if err := f.storeErrs[tableName]; err != nil {
+		return err
+	}
 	f.metrics[tableName] = append(f.metrics[tableName], metrics...)
 
 	// sort metrics we store by timestamp
@@ -81,6 +87,48 @@ func (f *fakePrometheusMetricsRepo) GetLastTimestampForTable(tableName string) (
 	return nil, fmt.Errorf("table %s not found", tableName)
 }
 
+func (f *fakePrometheusMetricsRepo) GetMetricCountsByBucket(tableName string, window prestostore.TimeInterval) (map[time.Time]int64, error) {
+	metrics, ok := f.metrics[tableName]
+	if !ok {
+		return nil, fmt.Errorf("table %s not found", tableName)
+	}
+	counts := make(map[time.Time]int64)
+	for _, metric := range metrics {
+		if !metric.Timestamp.Before(window.Start) && metric.Timestamp.Before(window.End) {
+			counts[metric.Timestamp.Truncate(prestostore.CoverageBucketSize).UTC()]++
+		}
+	}
+	return counts, nil
+}
+
+func (f *fakePrometheusMetricsRepo) DeletePrometheusMetricsPartition(tableName, dt string) error {
+	if f.err != nil {
+		return f.err
+	}
+	var kept []*prestostore.PrometheusMetric
+	for _, metric := range f.metrics[tableName] {
+		if prestostore.PrometheusMetricTimestampPartition(metric.Timestamp) != dt {
+			kept = append(kept, metric)
+		}
+	}
+	f.metrics[tableName] = kept
+	return nil
+}
+
+func (f *fakePrometheusMetricsRepo) GetPrometheusMetricsPartitions(tableName string) ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var partitions []string
+	for _, metric := range f.metrics[tableName] {
+		dt := prestostore.PrometheusMetricTimestampPartition(metric.Timestamp)
+		if len(partitions) == 0 || partitions[len(partitions)-1] != dt {
+			partitions = append(partitions, dt)
+		}
+	}
+	return partitions, nil
+}
+
 type fakeReportResultsGetter struct {
 	results []presto.Row
 	err     error
@@ -306,8 +354,8 @@ func TestAPIV1ReportsGet(t *testing.T) {
 			}
 
 			// setup a test server suitable for making API calls against
-			router := newRouter(testLogger, testRand, tt.prometheusMetricsRepo, tt.reportResultsGetter, nil, noopPrometheusImporterFunc,
-				reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
+			router := newRouter(testLogger, testRand, clock.RealClock{}, tt.prometheusMetricsRepo, nil, tt.reportResultsGetter, nil, nil, noopPrometheusImporterFunc,
+				time.Minute, nil, reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
 			)
 			server := httptest.NewServer(router)
 			defer server.Close()
@@ -612,8 +660,8 @@ func TestAPIV2ReportsFull(t *testing.T) {
 			}
 
 			// setup a test server suitable for making API calls against
-			router := newRouter(testLogger, testRand, tt.prometheusMetricsRepo, tt.reportResultsGetter, nil, noopPrometheusImporterFunc,
-				reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
+			router := newRouter(testLogger, testRand, clock.RealClock{}, tt.prometheusMetricsRepo, nil, tt.reportResultsGetter, nil, nil, noopPrometheusImporterFunc,
+				time.Minute, nil, reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
 			)
 			server := httptest.NewServer(router)
 			defer server.Close()
@@ -902,8 +950,8 @@ func TestAPIV2ReportsTable(t *testing.T) {
 			}
 
 			// setup a test server suitable for making API calls against
-			router := newRouter(testLogger, testRand, tt.prometheusMetricsRepo, tt.reportResultsGetter, nil, noopPrometheusImporterFunc,
-				reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
+			router := newRouter(testLogger, testRand, clock.RealClock{}, tt.prometheusMetricsRepo, nil, tt.reportResultsGetter, nil, nil, noopPrometheusImporterFunc,
+				time.Minute, nil, reportLister, reportDataSourceLister, reportQueryLister, prestoTableLister,
 			)
 			server := httptest.NewServer(router)
 			defer server.Close()
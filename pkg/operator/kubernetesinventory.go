@@ -0,0 +1,455 @@
+package operator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/jsonpath"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+)
+
+const (
+	defaultKubernetesInventorySnapshotInterval = 15 * time.Minute
+	minKubernetesInventorySnapshotInterval     = time.Minute
+	// kubernetesInventorySyncRetryInterval is how soon a KubernetesInventory
+	// ReportDataSource is processed again while the cache of its objects
+	// is syncing.
+	kubernetesInventorySyncRetryInterval = 5 * time.Second
+)
+
+var kubernetesInventoryColumnNameRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// kubernetesInventoryResource is the API resource of a kind of object
+// snapshotted by KubernetesInventory ReportDataSources.
+type kubernetesInventoryResource struct {
+	// resource is the plural name of the resource in the core API group.
+	resource   string
+	object     runtime.Object
+	namespaced bool
+}
+
+var kubernetesInventoryResources = map[metering.KubernetesInventoryResource]kubernetesInventoryResource{
+	metering.KubernetesInventoryPod:                   {resource: "pods", object: &v1.Pod{}, namespaced: true},
+	metering.KubernetesInventoryNode:                  {resource: "nodes", object: &v1.Node{}},
+	metering.KubernetesInventoryPersistentVolumeClaim: {resource: "persistentvolumeclaims", object: &v1.PersistentVolumeClaim{}, namespaced: true},
+}
+
+type kubernetesInventoryColumn struct {
+	name  string
+	field *jsonpath.JSONPath
+	label string
+}
+
+// kubernetesInventoryConfig is a validated spec.kubernetesInventory.
+type kubernetesInventoryConfig struct {
+	resource kubernetesInventoryResource
+	// informerNamespace is the namespace cached by the informer of the
+	// objects, or metav1.NamespaceAll.
+	informerNamespace string
+	// namespaces is nil if objects in every namespace are snapshotted.
+	namespaces sets.String
+	selector   labels.Selector
+	interval   time.Duration
+	columns    []kubernetesInventoryColumn
+}
+
+// newKubernetesInventoryConfig validates the spec.kubernetesInventory of a
+// ReportDataSource in namespace. Unless allNamespaces is set, only the
+// objects in namespace can be snapshotted.
+func newKubernetesInventoryConfig(inventory *metering.KubernetesInventoryDataSource, namespace string, allNamespaces bool) (*kubernetesInventoryConfig, error) {
+	resource, ok := kubernetesInventoryResources[inventory.Resource]
+	if !ok {
+		return nil, fmt.Errorf("invalid resource %q, must be one of Pod, Node or PersistentVolumeClaim", inventory.Resource)
+	}
+	cfg := &kubernetesInventoryConfig{
+		resource:          resource,
+		informerNamespace: metav1.NamespaceAll,
+		selector:          labels.Everything(),
+		interval:          defaultKubernetesInventorySnapshotInterval,
+	}
+	if len(inventory.Namespaces) != 0 {
+		if !resource.namespaced {
+			return nil, fmt.Errorf("namespaces can't be set for the cluster scoped resource %s", inventory.Resource)
+		}
+		cfg.namespaces = sets.NewString(inventory.Namespaces...)
+	}
+	if !allNamespaces {
+		if !resource.namespaced {
+			return nil, fmt.Errorf("the cluster scoped resource %s can't be snapshotted, the reporting-operator only allows snapshotting the objects in the namespace of the ReportDataSource", inventory.Resource)
+		}
+		if other := cfg.namespaces.Difference(sets.NewString(namespace)); other.Len() != 0 {
+			return nil, fmt.Errorf("namespaces %s can't be snapshotted, the reporting-operator only allows snapshotting the objects in the namespace of the ReportDataSource", strings.Join(other.List(), ", "))
+		}
+		cfg.informerNamespace = namespace
+		cfg.namespaces = sets.NewString(namespace)
+	}
+	if inventory.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(inventory.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector: %v", err)
+		}
+		cfg.selector = selector
+	}
+	if inventory.SnapshotInterval != nil {
+		if inventory.SnapshotInterval.Duration < minKubernetesInventorySnapshotInterval {
+			return nil, fmt.Errorf("snapshotInterval must be at least %s, got %s", minKubernetesInventorySnapshotInterval, inventory.SnapshotInterval.Duration)
+		}
+		cfg.interval = inventory.SnapshotInterval.Duration
+	}
+
+	names := sets.NewString()
+	for _, column := range prestostore.KubernetesInventoryHiveColumns(nil) {
+		names.Insert(column.Name)
+	}
+	for _, column := range prestostore.KubernetesInventoryHivePartitionColumns {
+		names.Insert(column.Name)
+	}
+	for _, column := range inventory.Columns {
+		if !kubernetesInventoryColumnNameRegex.MatchString(column.Name) {
+			return nil, fmt.Errorf("invalid column name %q, column names must be lowercase letters, digits and underscores", column.Name)
+		}
+		if names.Has(column.Name) {
+			return nil, fmt.Errorf("duplicate column %s", column.Name)
+		}
+		names.Insert(column.Name)
+
+		if (column.Field == "") == (column.Label == "") {
+			return nil, fmt.Errorf("exactly one of field and label must be set for column %s", column.Name)
+		}
+		invColumn := kubernetesInventoryColumn{name: column.Name, label: column.Label}
+		if column.Field != "" {
+			template := column.Field
+			if !strings.Contains(template, "{") {
+				template = "{" + template + "}"
+			}
+			parsed, err := jsonpath.Parse(column.Name, template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid field %q for column %s: %v", column.Field, column.Name, err)
+			}
+			if err := validateKubernetesInventoryField(parsed.Root); err != nil {
+				return nil, fmt.Errorf("invalid field %q for column %s: %v", column.Field, column.Name, err)
+			}
+			invColumn.field = jsonpath.New(column.Name).AllowMissingKeys(true)
+			if err := invColumn.field.Parse(template); err != nil {
+				return nil, fmt.Errorf("invalid field %q for column %s: %v", column.Field, column.Name, err)
+			}
+		}
+		cfg.columns = append(cfg.columns, invColumn)
+	}
+	return cfg, nil
+}
+
+// validateKubernetesInventoryField returns an error unless each path in the
+// parsed JSONPath template root is a plain path of fields and array indexes
+// into the metadata or status of an object, or into resources in its spec,
+// such as .spec.containers[*].resources. The rest of the spec can contain
+// credentials, such as the environment variables of Pods, and is stripped
+// from the cached objects by stripKubernetesInventoryObject.
+func validateKubernetesInventoryField(root *jsonpath.ListNode) error {
+	for _, node := range root.Nodes {
+		switch node := node.(type) {
+		case *jsonpath.TextNode:
+			continue
+		case *jsonpath.ListNode:
+			var path []string
+			for _, pathNode := range node.Nodes {
+				switch pathNode := pathNode.(type) {
+				case *jsonpath.FieldNode:
+					path = append(path, pathNode.Value)
+				case *jsonpath.ArrayNode:
+					if len(path) == 0 {
+						return fmt.Errorf("paths must start with a field")
+					}
+				default:
+					return fmt.Errorf("only fields and array indexes are supported in paths, got %s", pathNode.Type())
+				}
+			}
+			if len(path) == 0 {
+				return fmt.Errorf("paths must start with a field")
+			}
+			if !kubernetesInventoryFieldAllowed(path) {
+				return fmt.Errorf("only the metadata, status and resources of objects can be read, got .%s", strings.Join(path, "."))
+			}
+		default:
+			return fmt.Errorf("unsupported JSONPath %s", node.Type())
+		}
+	}
+	return nil
+}
+
+func kubernetesInventoryFieldAllowed(path []string) bool {
+	switch path[0] {
+	case "metadata", "status":
+		return true
+	case "spec":
+		for _, field := range path[1:] {
+			if field == "resources" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (cfg *kubernetesInventoryConfig) columnNames() []string {
+	names := make([]string, len(cfg.columns))
+	for i, column := range cfg.columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// snapshotKubernetesInventory returns the objects selected by cfg as of now,
+// sorted by namespace and name. Columns whose field has no value in an
+// object, or can't be evaluated for it, are null.
+func snapshotKubernetesInventory(cfg *kubernetesInventoryConfig, objects []interface{}, now time.Time) ([]*prestostore.KubernetesInventoryObject, error) {
+	var snapshot []*prestostore.KubernetesInventoryObject
+	var buf bytes.Buffer
+	for _, obj := range objects {
+		object, ok := obj.(metav1.Object)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %T in cache", obj)
+		}
+		if cfg.namespaces != nil && !cfg.namespaces.Has(object.GetNamespace()) {
+			continue
+		}
+		if !cfg.selector.Matches(labels.Set(object.GetLabels())) {
+			continue
+		}
+
+		invObject := &prestostore.KubernetesInventoryObject{
+			Timestamp: now,
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+			UID:       string(object.GetUID()),
+			Labels:    object.GetLabels(),
+			Values:    make(map[string]string),
+		}
+		if owner := metav1.GetControllerOfNoCopy(object); owner != nil {
+			invObject.OwnerKind = owner.Kind
+			invObject.OwnerName = owner.Name
+		}
+
+		// fields are evaluated against the JSON representation of the
+		// object, the same as kubectl does
+		var content map[string]interface{}
+		for _, column := range cfg.columns {
+			if column.field == nil {
+				if value, ok := object.GetLabels()[column.label]; ok {
+					invObject.Values[column.name] = value
+				}
+				continue
+			}
+			if content == nil {
+				var err error
+				content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+				if err != nil {
+					return nil, fmt.Errorf("unable to convert %s to unstructured: %v", object.GetName(), err)
+				}
+			}
+			buf.Reset()
+			if err := column.field.Execute(&buf, content); err != nil || buf.Len() == 0 {
+				continue
+			}
+			invObject.Values[column.name] = buf.String()
+		}
+		snapshot = append(snapshot, invObject)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Namespace != snapshot[j].Namespace {
+			return snapshot[i].Namespace < snapshot[j].Namespace
+		}
+		return snapshot[i].Name < snapshot[j].Name
+	})
+	return snapshot, nil
+}
+
+// stripKubernetesInventoryObject returns obj with only the fields which can
+// be read by the columns of KubernetesInventory ReportDataSources: its
+// metadata without managedFields and the last applied configuration, its
+// status, and the resources in its spec. Other objects, such as the Status
+// of watch errors, are returned unchanged.
+func stripKubernetesInventoryObject(obj runtime.Object) runtime.Object {
+	stripMeta := func(objectMeta metav1.ObjectMeta) metav1.ObjectMeta {
+		objectMeta.ManagedFields = nil
+		if _, exists := objectMeta.Annotations[v1.LastAppliedConfigAnnotation]; exists {
+			annotations := make(map[string]string, len(objectMeta.Annotations))
+			for k, v := range objectMeta.Annotations {
+				if k != v1.LastAppliedConfigAnnotation {
+					annotations[k] = v
+				}
+			}
+			objectMeta.Annotations = annotations
+		}
+		return objectMeta
+	}
+	stripContainers := func(containers []v1.Container) []v1.Container {
+		if containers == nil {
+			return nil
+		}
+		stripped := make([]v1.Container, len(containers))
+		for i, container := range containers {
+			stripped[i] = v1.Container{Name: container.Name, Resources: container.Resources}
+		}
+		return stripped
+	}
+
+	switch obj := obj.(type) {
+	case *v1.Pod:
+		return &v1.Pod{
+			TypeMeta:   obj.TypeMeta,
+			ObjectMeta: stripMeta(obj.ObjectMeta),
+			Spec: v1.PodSpec{
+				InitContainers: stripContainers(obj.Spec.InitContainers),
+				Containers:     stripContainers(obj.Spec.Containers),
+			},
+			Status: obj.Status,
+		}
+	case *v1.Node:
+		return &v1.Node{
+			TypeMeta:   obj.TypeMeta,
+			ObjectMeta: stripMeta(obj.ObjectMeta),
+			Status:     obj.Status,
+		}
+	case *v1.PersistentVolumeClaim:
+		return &v1.PersistentVolumeClaim{
+			TypeMeta:   obj.TypeMeta,
+			ObjectMeta: stripMeta(obj.ObjectMeta),
+			Spec:       v1.PersistentVolumeClaimSpec{Resources: obj.Spec.Resources},
+			Status:     obj.Status,
+		}
+	default:
+		return obj
+	}
+}
+
+// newKubernetesInventoryListWatch returns a ListerWatcher of the objects of
+// resource in namespace, stripped by stripKubernetesInventoryObject so the
+// rest of their spec isn't cached.
+func newKubernetesInventoryListWatch(c cache.Getter, resource kubernetesInventoryResource, namespace string) cache.ListerWatcher {
+	listWatch := cache.NewListWatchFromClient(c, resource.resource, namespace, fields.Everything())
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := listWatch.List(options)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			for i, item := range items {
+				items[i] = stripKubernetesInventoryObject(item)
+			}
+			if err := meta.SetList(list, items); err != nil {
+				return nil, err
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := listWatch.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				event.Object = stripKubernetesInventoryObject(event.Object)
+				return event, true
+			}), nil
+		},
+	}
+}
+
+// getKubernetesInventoryInformer returns the informer caching the objects of
+// the resource of cfg in its informerNamespace, starting it if this is the
+// first KubernetesInventory ReportDataSource of them. The informers are
+// shared by the ReportDataSources of the same resource and namespace, and
+// run until the workers stop.
+func (op *defaultReportingOperator) getKubernetesInventoryInformer(cfg *kubernetesInventoryConfig) cache.SharedIndexInformer {
+	key := cfg.resource.resource
+	if cfg.informerNamespace != metav1.NamespaceAll {
+		key = cfg.informerNamespace + "/" + key
+	}
+	op.kubernetesInventoryInformersMu.Lock()
+	defer op.kubernetesInventoryInformersMu.Unlock()
+	if informer, exists := op.kubernetesInventoryInformers[key]; exists {
+		return informer
+	}
+
+	op.logger.Infof("starting informer for %s of KubernetesInventory ReportDataSources", key)
+	listWatch := newKubernetesInventoryListWatch(op.kubeClient.RESTClient(), cfg.resource, cfg.informerNamespace)
+	informer := cache.NewSharedIndexInformer(listWatch, cfg.resource.object, defaultResyncPeriod, cache.Indexers{})
+	go informer.Run(op.kubernetesInventoryStopCh)
+	op.kubernetesInventoryInformers[key] = informer
+	return informer
+}
+
+// createKubernetesInventoryTable creates the table of the snapshots of a
+// KubernetesInventory ReportDataSource in its StorageLocation.
+func (op *defaultReportingOperator) createKubernetesInventoryTable(dataSource *metering.ReportDataSource, cfg *kubernetesInventoryConfig) (*metering.HiveTable, error) {
+	tableName := reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name)
+	hiveStorage, err := op.getHiveStorage(dataSource.Spec.KubernetesInventory.Storage, dataSource.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("storage incorrectly configured for ReportDataSource %s, err: %v", dataSource.Name, err)
+	}
+	if hiveStorage.Status.Hive.DatabaseName == "" {
+		op.enqueueStorageLocation(hiveStorage)
+		return nil, fmt.Errorf("StorageLocation %s Hive database %s does not exist yet", hiveStorage.Name, hiveStorage.Spec.Hive.DatabaseName)
+	}
+	params := hive.TableParameters{
+		Database:      hiveStorage.Status.Hive.DatabaseName,
+		Name:          tableName,
+		Columns:       prestostore.KubernetesInventoryHiveColumns(cfg.columnNames()),
+		PartitionedBy: prestostore.KubernetesInventoryHivePartitionColumns,
+	}
+	if hiveStorage.Spec.Hive.DefaultTableProperties != nil {
+		params.RowFormat = hiveStorage.Spec.Hive.DefaultTableProperties.RowFormat
+		params.FileFormat = hiveStorage.Spec.Hive.DefaultTableProperties.FileFormat
+	}
+
+	hiveTable, err := op.createHiveTableCR(dataSource, metering.ReportDataSourceGVK, params, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	hiveTable, err = op.waitForHiveTable(hiveTable.Namespace, hiveTable.Name, time.Second, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error creating table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	if _, err := op.waitForPrestoTable(hiveTable.Namespace, hiveTable.Name, time.Second, 30*time.Second); err != nil {
+		return nil, fmt.Errorf("error creating table for ReportDataSource %s: %s", dataSource.Name, err)
+	}
+	return hiveTable, nil
+}
+
+// storeKubernetesInventorySnapshot snapshots the objects selected by cfg into
+// tableName, returning the number of objects stored.
+func (op *defaultReportingOperator) storeKubernetesInventorySnapshot(cfg *kubernetesInventoryConfig, informer cache.SharedIndexInformer, tableName string, now time.Time) (int, error) {
+	snapshot, err := snapshotKubernetesInventory(cfg, informer.GetStore().List(), now)
+	if err != nil {
+		return 0, err
+	}
+	if len(snapshot) == 0 {
+		return 0, nil
+	}
+	if err := op.kubernetesInventoryRepo.StoreKubernetesInventory(context.Background(), tableName, cfg.columnNames(), snapshot); err != nil {
+		return 0, fmt.Errorf("failed to store the snapshot of %s into table %s: %v", cfg.resource.resource, tableName, err)
+	}
+	return len(snapshot), nil
+}
@@ -0,0 +1,217 @@
+package operator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+func TestNewKubernetesInventoryConfig(t *testing.T) {
+	cfg, err := newKubernetesInventoryConfig(&metering.KubernetesInventoryDataSource{
+		Resource: metering.KubernetesInventoryPod,
+		Columns: []metering.KubernetesInventoryColumn{
+			{Name: "qos_class", Field: ".status.qosClass"},
+			{Name: "cpu_requests", Field: "{.spec.containers[*].resources.requests.cpu}"},
+			{Name: "created", Field: "created at {.metadata.creationTimestamp}"},
+			{Name: "app", Label: "app"},
+		},
+	}, "web", false)
+	require.NoError(t, err)
+	assert.Equal(t, "pods", cfg.resource.resource)
+	assert.Equal(t, "web", cfg.informerNamespace)
+	assert.Equal(t, sets.NewString("web"), cfg.namespaces)
+	assert.Equal(t, defaultKubernetesInventorySnapshotInterval, cfg.interval)
+	assert.Equal(t, []string{"qos_class", "cpu_requests", "created", "app"}, cfg.columnNames())
+
+	cfg, err = newKubernetesInventoryConfig(&metering.KubernetesInventoryDataSource{Resource: metering.KubernetesInventoryPod, Namespaces: []string{"web"}}, "web", false)
+	require.NoError(t, err)
+	assert.Equal(t, sets.NewString("web"), cfg.namespaces)
+
+	cfg, err = newKubernetesInventoryConfig(&metering.KubernetesInventoryDataSource{Resource: metering.KubernetesInventoryNode}, "web", true)
+	require.NoError(t, err)
+	assert.Equal(t, metav1.NamespaceAll, cfg.informerNamespace)
+	assert.Nil(t, cfg.namespaces)
+
+	for name, inventory := range map[string]metering.KubernetesInventoryDataSource{
+		"cluster scoped":   {Resource: metering.KubernetesInventoryNode},
+		"other namespaces": {Resource: metering.KubernetesInventoryPod, Namespaces: []string{"web", "kube-system"}},
+	} {
+		inventory := inventory
+		_, err := newKubernetesInventoryConfig(&inventory, "web", false)
+		assert.Error(t, err, name)
+	}
+
+	for name, inventory := range map[string]metering.KubernetesInventoryDataSource{
+		"unknown resource":   {Resource: "Deployment"},
+		"cluster namespaces": {Resource: metering.KubernetesInventoryNode, Namespaces: []string{"default"}},
+		"short interval":     {Resource: metering.KubernetesInventoryNode, SnapshotInterval: &metav1.Duration{Duration: time.Second}},
+		"invalid selector": {Resource: metering.KubernetesInventoryNode, Selector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "role", Operator: "Between"}},
+		}},
+		"uppercase column": {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "QoS", Label: "qos"}}},
+		"builtin column":   {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "owner_kind", Label: "owner"}}},
+		"partition column": {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "dt", Label: "dt"}}},
+		"duplicate column": {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "app", Label: "app"}, {Name: "app", Label: "name"}}},
+		"field and label":  {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "app", Label: "app", Field: ".metadata.name"}}},
+		"no value":         {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "app"}}},
+		"invalid field":    {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "node", Field: "{.status.hostIP"}}},
+		"spec field":       {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "node", Field: "{.spec.nodeName}"}}},
+		"env field":        {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "env", Field: "{.spec.containers[*].env[*].value}"}}},
+		"recursive field":  {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "values", Field: "{..value}"}}},
+		"wildcard field":   {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "values", Field: "{.*}"}}},
+		"filter field":     {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "limits", Field: `{.spec.containers[?(@.env[0].value=="x")].resources}`}}},
+		"range field":      {Resource: metering.KubernetesInventoryPod, Columns: []metering.KubernetesInventoryColumn{{Name: "names", Field: "{range .spec.containers[*]}{.name}{end}"}}},
+	} {
+		inventory := inventory
+		_, err := newKubernetesInventoryConfig(&inventory, "web", true)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSnapshotKubernetesInventory(t *testing.T) {
+	isController := true
+	newPod := func(namespace, name string, labels map[string]string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      name,
+				UID:       types.UID("uid-" + name),
+				Labels:    labels,
+			},
+			Spec:   v1.PodSpec{NodeName: "worker-0"},
+			Status: v1.PodStatus{QOSClass: v1.PodQOSBurstable},
+		}
+	}
+	frontend := newPod("web", "frontend-abcde", map[string]string{"app": "frontend", "tier": "web"})
+	frontend.OwnerReferences = []metav1.OwnerReference{
+		{Kind: "Node", Name: "worker-0"},
+		{Kind: "ReplicaSet", Name: "frontend-7d9c8", Controller: &isController},
+	}
+	frontend.Spec.Containers = []v1.Container{
+		{Name: "nginx", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")}}},
+		{Name: "proxy", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}}},
+	}
+	backend := newPod("api", "backend-0", map[string]string{"app": "backend"})
+	other := newPod("kube-system", "dns-0", map[string]string{"app": "dns"})
+	unlabeled := newPod("web", "debug", nil)
+
+	cfg, err := newKubernetesInventoryConfig(&metering.KubernetesInventoryDataSource{
+		Resource:   metering.KubernetesInventoryPod,
+		Namespaces: []string{"web", "api"},
+		Selector:   &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: metav1.LabelSelectorOpExists}}},
+		Columns: []metering.KubernetesInventoryColumn{
+			{Name: "qos_class", Field: ".status.qosClass"},
+			{Name: "cpu_requests", Field: "{.spec.containers[*].resources.requests.cpu}"},
+			{Name: "tier", Label: "tier"},
+		},
+	}, "web", true)
+	require.NoError(t, err)
+
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	snapshot, err := snapshotKubernetesInventory(cfg, []interface{}{frontend, other, unlabeled, backend}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []*prestostore.KubernetesInventoryObject{
+		{
+			Timestamp: now,
+			Namespace: "api",
+			Name:      "backend-0",
+			UID:       "uid-backend-0",
+			Labels:    map[string]string{"app": "backend"},
+			Values:    map[string]string{"qos_class": "Burstable"},
+		},
+		{
+			Timestamp: now,
+			Namespace: "web",
+			Name:      "frontend-abcde",
+			UID:       "uid-frontend-abcde",
+			OwnerKind: "ReplicaSet",
+			OwnerName: "frontend-7d9c8",
+			Labels:    map[string]string{"app": "frontend", "tier": "web"},
+			Values:    map[string]string{"qos_class": "Burstable", "cpu_requests": "250m 1", "tier": "web"},
+		},
+	}, snapshot)
+
+	_, err = snapshotKubernetesInventory(cfg, []interface{}{"not an object"}, now)
+	assert.Error(t, err)
+}
+
+func TestStripKubernetesInventoryObject(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "web",
+			Name:      "frontend-abcde",
+			Labels:    map[string]string{"app": "frontend"},
+			Annotations: map[string]string{
+				"example.com/team":             "payments",
+				v1.LastAppliedConfigAnnotation: `{"spec":{"containers":[{"env":[{"name":"PASSWORD","value":"hunter2"}]}]}}`,
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "worker-0",
+			Containers: []v1.Container{{
+				Name:      "nginx",
+				Image:     "nginx",
+				Env:       []v1.EnvVar{{Name: "PASSWORD", Value: "hunter2"}},
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")}},
+			}},
+		},
+		Status: v1.PodStatus{QOSClass: v1.PodQOSBurstable},
+	}
+	assert.Equal(t, &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "web",
+			Name:        "frontend-abcde",
+			Labels:      map[string]string{"app": "frontend"},
+			Annotations: map[string]string{"example.com/team": "payments"},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:      "nginx",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("250m")}},
+			}},
+		},
+		Status: v1.PodStatus{QOSClass: v1.PodQOSBurstable},
+	}, stripKubernetesInventoryObject(pod))
+	assert.Contains(t, pod.Annotations, v1.LastAppliedConfigAnnotation, "the object must not be modified")
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-east-1a/i-0123"},
+		Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{KubeletVersion: "v1.20.0"}},
+	}
+	assert.Equal(t, &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0"},
+		Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{KubeletVersion: "v1.20.0"}},
+	}, stripKubernetesInventoryObject(node))
+
+	storageClass := "gp2"
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "data"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClass,
+			Resources:        v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}
+	assert.Equal(t, &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "web", Name: "data"},
+		Spec: v1.PersistentVolumeClaimSpec{
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}, stripKubernetesInventoryObject(pvc))
+
+	status := &metav1.Status{Message: "too old resource version"}
+	assert.Equal(t, status, stripKubernetesInventoryObject(status))
+}
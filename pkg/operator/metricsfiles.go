@@ -0,0 +1,144 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/kube-reporting/metering-operator/pkg/aws"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+)
+
+// prometheusMetricsFileFormat is the Hive file format of tables Prometheus
+// metrics can be stored in as files.
+const prometheusMetricsFileFormat = "parquet"
+
+// metricsFileWriter writes the files of Prometheus metrics to S3 or to the
+// local filesystem, which is used when the Hive warehouse is a volume also
+// mounted by the reporting-operator.
+type metricsFileWriter struct {
+	s3 *aws.S3ObjectWriter
+}
+
+func newMetricsFileWriter() (*metricsFileWriter, error) {
+	s3Writer, err := aws.NewS3ObjectWriter()
+	if err != nil {
+		return nil, err
+	}
+	return &metricsFileWriter{s3: s3Writer}, nil
+}
+
+// Supports returns true if files can be written to location.
+func (w *metricsFileWriter) Supports(location string) bool {
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "file":
+		return true
+	case "s3", "s3a", "s3n":
+		return u.Host != ""
+	}
+	return false
+}
+
+func (w *metricsFileWriter) WriteFile(ctx context.Context, location string, data []byte) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "file":
+		return writeLocalFile(u.Path, data)
+	case "s3", "s3a", "s3n":
+		return w.s3.PutObject(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), data)
+	}
+	return fmt.Errorf("unsupported location %s", location)
+}
+
+// DeleteFiles deletes the directory at location and all the files in it.
+func (w *metricsFileWriter) DeleteFiles(ctx context.Context, location string) error {
+	u, err := url.Parse(location)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "file":
+		return os.RemoveAll(u.Path)
+	case "s3", "s3a", "s3n":
+		prefix := strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), "/")
+		if prefix == "" {
+			return fmt.Errorf("refusing to delete every file of bucket %s", u.Host)
+		}
+		// the trailing slash keeps the files of other directories starting
+		// with the same name
+		return w.s3.DeletePrefix(ctx, u.Host, prefix+"/")
+	}
+	return fmt.Errorf("unsupported location %s", location)
+}
+
+// writeLocalFile writes data to a temporary file which is renamed to path, so
+// Presto never reads a partially written file.
+func writeLocalFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// files starting with a dot are ignored by Hive and Presto
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// locatePrometheusMetricsTableFiles returns the Hive table of the Presto table
+// tableName if it's stored as Parquet in a location files can be written to.
+func (op *defaultReportingOperator) locatePrometheusMetricsTableFiles(tableName string) *prestostore.PrometheusMetricsTableFiles {
+	prestoTables, err := op.prestoTableLister.List(labels.Everything())
+	if err != nil {
+		op.logger.WithError(err).Errorf("unable to list PrestoTables to find the location of table %s", tableName)
+		return nil
+	}
+	for _, prestoTable := range prestoTables {
+		fullName, err := reportingutil.FullyQualifiedTableName(prestoTable)
+		if err != nil || fullName != tableName {
+			continue
+		}
+		// PrestoTables of HiveTables have the same name as the HiveTable
+		hiveTable, err := op.hiveTableLister.HiveTables(prestoTable.Namespace).Get(prestoTable.Name)
+		if err != nil {
+			return nil
+		}
+		if !strings.EqualFold(hiveTable.Status.FileFormat, prometheusMetricsFileFormat) || !op.metricsFileWriter.Supports(hiveTable.Status.Location) {
+			return nil
+		}
+		return &prestostore.PrometheusMetricsTableFiles{
+			DatabaseName: hiveTable.Status.DatabaseName,
+			TableName:    hiveTable.Status.TableName,
+			Location:     hiveTable.Status.Location,
+		}
+	}
+	return nil
+}
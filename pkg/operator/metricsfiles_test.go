@@ -0,0 +1,35 @@
+package operator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsFileWriter(t *testing.T) {
+	w := &metricsFileWriter{}
+	assert.True(t, w.Supports("s3a://bucket/metering"))
+	assert.True(t, w.Supports("file:///user/hive/warehouse"))
+	assert.False(t, w.Supports("hdfs://hdfs-namenode-0:9820/user/hive/warehouse"))
+	assert.False(t, w.Supports(""))
+
+	dir, err := ioutil.TempDir("", "metricsfiles")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "table", "dt=2019-01-01", "metrics.parquet")
+	require.NoError(t, w.WriteFile(context.Background(), "file://"+path, []byte("PAR1")))
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "PAR1", string(data))
+
+	// no temporary files are left behind
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
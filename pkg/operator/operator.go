@@ -77,10 +77,13 @@ type defaultReportingOperator struct {
 	hiveTableQueue        workqueue.RateLimitingInterface
 	storageLocationQueue  workqueue.RateLimitingInterface
 
-	reportResultsRepo     prestostore.ReportResultsRepo
-	prometheusMetricsRepo prestostore.PrometheusMetricsRepo
-	reportGenerator       reporting.ReportGenerator
-	dependencyResolver    DependencyResolver
+	reportResultsRepo       prestostore.ReportResultsRepo
+	prometheusMetricsRepo   prestostore.PrometheusMetricsRepo
+	kubernetesInventoryRepo prestostore.KubernetesInventoryStorer
+	pushTableRepo           prestostore.PushTableStorer
+	prometheusSnapshotRepo  prestostore.PrometheusSnapshotRepo
+	reportGenerator         reporting.ReportGenerator
+	dependencyResolver      DependencyResolver
 
 	prestoTableManager   reporting.PrestoTableManager
 	hiveDatabaseManager  reporting.HiveDatabaseManager
@@ -101,6 +104,42 @@ type defaultReportingOperator struct {
 
 	importersMu sync.Mutex
 	importers   map[string]*prestostore.PrometheusImporter
+
+	// prometheusSnapshotters run the snapshots of snapshot mode Prometheus
+	// ReportDataSources, by namespace/name.
+	prometheusSnapshottersMu sync.Mutex
+	prometheusSnapshotters   map[string]*prestostore.PrometheusSnapshotter
+
+	// remoteWriteTracker records the metrics received by remote_write
+	// ReportDataSources for updating their status.
+	remoteWriteTracker *remoteWriteTracker
+
+	prometheusEndpointSetsMu sync.Mutex
+	prometheusEndpointSets   map[string]*prometheusEndpointSet
+
+	// resumedBackfills are the ReportDataSource backfills which have been
+	// run since the operator started.
+	resumedBackfillsMu sync.Mutex
+	resumedBackfills   map[string]bool
+
+	// importScheduler limits the Prometheus queries run by the imports of
+	// all ReportDataSources.
+	importScheduler *prestostore.ImportScheduler
+
+	metricsFileWriter *metricsFileWriter
+
+	// kubernetesInventoryInformers cache the objects snapshotted by
+	// KubernetesInventory ReportDataSources by resource, and are stopped
+	// when kubernetesInventoryStopCh is closed.
+	kubernetesInventoryInformersMu sync.Mutex
+	kubernetesInventoryInformers   map[string]cache.SharedIndexInformer
+	kubernetesInventoryStopCh      <-chan struct{}
+
+	// pendingPrestoExternalCatalogs are the changes of the Presto catalogs
+	// of ExternalDatabase ReportDataSources waiting to be stored, by
+	// catalog.
+	prestoExternalCatalogsMu      sync.Mutex
+	pendingPrestoExternalCatalogs map[string]*prestoExternalCatalogChange
 }
 
 func New(logger log.FieldLogger, cfg Config) (ReportingOperator, error) {
@@ -249,6 +288,16 @@ func newReportingOperator(
 		rand:      rand,
 		clock:     clock,
 		importers: make(map[string]*prestostore.PrometheusImporter),
+
+		prometheusSnapshotters: make(map[string]*prestostore.PrometheusSnapshotter),
+
+		remoteWriteTracker:     newRemoteWriteTracker(clock),
+		prometheusEndpointSets: make(map[string]*prometheusEndpointSet),
+		resumedBackfills:       make(map[string]bool),
+
+		kubernetesInventoryInformers:  make(map[string]cache.SharedIndexInformer),
+		pendingPrestoExternalCatalogs: make(map[string]*prestoExternalCatalogChange),
+		importScheduler:               prestostore.NewImportScheduler(cfg.PrometheusDataSourceMaxConcurrentQueries, cfg.PrometheusDataSourceMaxConcurrentQueriesPerPrometheus),
 	}
 
 	op.logger.Info("setting the informers")
@@ -449,6 +498,9 @@ func (op *defaultReportingOperator) Run(ctx context.Context) error {
 	op.reportResultsRepo = prestostore.NewReportResultsRepo(loggingDMLPrestoQueryer)
 	op.reportGenerator = reporting.NewReportGenerator(op.logger, op.reportResultsRepo)
 	op.prometheusMetricsRepo = prestostore.NewPrometheusMetricsRepo(loggingDMLPrestoQueryer, prestoQueryBufferPool)
+	op.kubernetesInventoryRepo = prestostore.NewKubernetesInventoryRepo(loggingDMLPrestoQueryer, prestoQueryBufferPool)
+	op.pushTableRepo = prestostore.NewPushTableRepo(loggingDMLPrestoQueryer, prestoQueryBufferPool)
+	op.prometheusSnapshotRepo = prestostore.NewPrometheusSnapshotRepo(loggingDMLPrestoQueryer, prestoQueryBufferPool)
 
 	prestoTableManager := reporting.NewPrestoTableManager(loggingDDLPrestoQueryer)
 	hiveManager := reporting.NewHiveManager(loggingDDLHiveQueryer)
@@ -458,6 +510,14 @@ func (op *defaultReportingOperator) Run(ctx context.Context) error {
 	op.hiveDatabaseManager = hiveManager
 	op.hivePartitionManager = hiveManager
 
+	if op.cfg.PrometheusDataSourceWriteFiles {
+		op.metricsFileWriter, err = newMetricsFileWriter()
+		if err != nil {
+			return err
+		}
+		op.prometheusMetricsRepo = prestostore.NewPrometheusMetricsFileRepo(op.clock, op.prometheusMetricsRepo, op.locatePrometheusMetricsTableFiles, op.metricsFileWriter, hiveManager)
+	}
+
 	prestoHealthChecker := reporting.NewPrestoHealthChecker(op.logger, prestoQueryer, hiveManager, "", "operator_health_check")
 	op.testReadFromPrestoFunc = func() bool {
 		return prestoHealthChecker.TestReadFromPrestoSingleFlight()
@@ -465,8 +525,8 @@ func (op *defaultReportingOperator) Run(ctx context.Context) error {
 
 	op.logger.Infof("starting HTTP server")
 	apiRouter := newRouter(
-		op.logger, op.rand, op.prometheusMetricsRepo, op.reportResultsRepo, op.dependencyResolver, op.importPrometheusForTimeRange,
-		op.reportLister, op.reportDataSourceLister, op.reportQueryLister, op.prestoTableLister,
+		op.logger, op.rand, op.clock, op.prometheusMetricsRepo, op.pushTableRepo, op.reportResultsRepo, op.dependencyResolver, op.kubeClient, op.importPrometheusForTimeRange,
+		op.cfg.PrometheusQueryConfig.StepSize.Duration, op.remoteWriteTracker, op.reportLister, op.reportDataSourceLister, op.reportQueryLister, op.prestoTableLister,
 	)
 	apiRouter.HandleFunc("/ready", op.readinessHandler)
 	apiRouter.HandleFunc("/healthy", op.readinessHandler)
@@ -637,6 +697,20 @@ func (op *defaultReportingOperator) Run(ctx context.Context) error {
 }
 
 func (op *defaultReportingOperator) newPrometheusConnFromURL(url string) (prom.API, error) {
+	roundTripper, err := op.newPrometheusRoundTripper()
+	if err != nil {
+		return nil, err
+	}
+
+	return op.newPrometheusConn(promapi.Config{
+		Address:      url,
+		RoundTripper: roundTripper,
+	})
+}
+
+// newPrometheusRoundTripper returns a http.RoundTripper using the TLS and
+// authentication configuration for communicating with Prometheus.
+func (op *defaultReportingOperator) newPrometheusRoundTripper() (http.RoundTripper, error) {
 	transportConfig := &transport.Config{}
 	if op.cfg.PrometheusConfig.CAFile != "" {
 		// Use the configured CA for communicating to Prometheus
@@ -660,19 +734,14 @@ func (op *defaultReportingOperator) newPrometheusConnFromURL(url string) (prom.A
 		transportConfig.BearerTokenFile = op.cfg.PrometheusConfig.BearerTokenFile
 	}
 
-	roundTripper, err := transport.New(transportConfig)
-	if err != nil {
-		return nil, err
-	}
-
-	return op.newPrometheusConn(promapi.Config{
-		Address:      url,
-		RoundTripper: roundTripper,
-	})
+	return transport.New(transportConfig)
 }
 
 func (op *defaultReportingOperator) startWorkers(ctx context.Context, wg *sync.WaitGroup) {
 	stopCh := ctx.Done()
+	// the informers of KubernetesInventory ReportDataSources are started by
+	// the ReportDataSource workers
+	op.kubernetesInventoryStopCh = stopCh
 
 	startWorker := func(threads int, workerFunc func(id int)) {
 		for i := 0; i < threads; i++ {
@@ -721,6 +790,17 @@ func (op *defaultReportingOperator) startWorkers(ctx context.Context, wg *sync.W
 		wait.Until(op.runReportWorker, time.Second, stopCh)
 		op.logger.Infof("Report worker #%d stopped", i)
 	})
+
+	if op.cfg.ExternalDatabaseCatalogUpdatesEnabled {
+		startWorker(1, func(i int) {
+			op.logger.Infof("starting Presto external catalogs worker")
+			wait.Until(op.storePrestoExternalCatalogs, op.cfg.ExternalDatabaseCatalogUpdateInterval, stopCh)
+			// store the changes queued since the last run, such as the
+			// removals of deleted ReportDataSources
+			op.storePrestoExternalCatalogs()
+			op.logger.Infof("Presto external catalogs worker stopped")
+		})
+	}
 }
 
 func (op *defaultReportingOperator) setInitialized() {
@@ -0,0 +1,153 @@
+package prestostore
+
+import (
+	"fmt"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+// CoverageBucketSize is the size of the buckets the metrics of a table are
+// counted in when checking the coverage of imported data. A bucket without
+// any metrics which should have been imported is considered a gap.
+const CoverageBucketSize = time.Hour
+
+// TimeInterval is the half-open interval of time [Start, End).
+type TimeInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (i TimeInterval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// ProcessedTimeRangeInterval returns the interval of time whose data was
+// imported by querying timeRange, which covers one step past its End since
+// the next time range starts at End plus Step.
+func ProcessedTimeRangeInterval(timeRange prom.Range) TimeInterval {
+	return TimeInterval{
+		Start: timeRange.Start.UTC(),
+		End:   timeRange.End.Add(timeRange.Step).UTC(),
+	}
+}
+
+// AddTimeInterval returns intervals with interval added, merging overlapping
+// and adjacent intervals. intervals must be sorted and non-overlapping, as
+// returned by AddTimeInterval.
+func AddTimeInterval(intervals []TimeInterval, interval TimeInterval) []TimeInterval {
+	if !interval.Start.Before(interval.End) {
+		return intervals
+	}
+	var merged []TimeInterval
+	inserted := false
+	for _, existing := range intervals {
+		switch {
+		case existing.End.Before(interval.Start):
+			merged = append(merged, existing)
+		case interval.End.Before(existing.Start):
+			if !inserted {
+				merged = append(merged, interval)
+				inserted = true
+			}
+			merged = append(merged, existing)
+		default:
+			// overlapping or adjacent, so extend interval to include existing
+			if existing.Start.Before(interval.Start) {
+				interval.Start = existing.Start
+			}
+			if existing.End.After(interval.End) {
+				interval.End = existing.End
+			}
+		}
+	}
+	if !inserted {
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// FindGaps returns the parts of window which are missing data: parts
+// between intervals of coverage, and parts of each CoverageBucketSize bucket
+// within coverage that has no metrics according to counts, which are the
+// number of metrics per bucket keyed by the start of the bucket. Gaps before
+// the start of the first interval or after the end of the last interval
+// aren't returned, since that data hasn't been imported yet.
+func FindGaps(coverage []TimeInterval, counts map[time.Time]int64, window TimeInterval) []TimeInterval {
+	if len(coverage) == 0 {
+		return nil
+	}
+	start := coverage[0].Start
+	if window.Start.After(start) {
+		start = window.Start
+	}
+	end := coverage[len(coverage)-1].End
+	if window.End.Before(end) {
+		end = window.End
+	}
+
+	var gaps []TimeInterval
+	// holes between covered intervals
+	for i := 1; i < len(coverage); i++ {
+		gaps = AddTimeInterval(gaps, clampInterval(TimeInterval{Start: coverage[i-1].End, End: coverage[i].Start}, start, end))
+	}
+	// empty buckets within covered intervals
+	for _, interval := range coverage {
+		interval = clampInterval(interval, start, end)
+		for bucket := interval.Start.Truncate(CoverageBucketSize); bucket.Before(interval.End); bucket = bucket.Add(CoverageBucketSize) {
+			if counts[bucket.UTC()] != 0 {
+				continue
+			}
+			gaps = AddTimeInterval(gaps, clampInterval(TimeInterval{Start: bucket, End: bucket.Add(CoverageBucketSize)}, interval.Start, interval.End))
+		}
+	}
+	return gaps
+}
+
+func clampInterval(interval TimeInterval, start, end time.Time) TimeInterval {
+	if interval.Start.Before(start) {
+		interval.Start = start
+	}
+	if interval.End.After(end) {
+		interval.End = end
+	}
+	return interval
+}
+
+// GetMetricCountsByBucket returns the number of metrics in tableName with
+// timestamps in window, grouped by CoverageBucketSize buckets keyed by the
+// start of the bucket. Only the dt partitions of window are read.
+func GetMetricCountsByBucket(queryer db.Queryer, tableName string, window TimeInterval) (map[time.Time]int64, error) {
+	query := fmt.Sprintf(`
+				SELECT date_trunc('hour', "timestamp") AS bucket, count(*) AS metrics
+				FROM %s
+				WHERE "dt" >= %s AND "dt" <= %s
+				AND "timestamp" >= %s AND "timestamp" < %s
+				GROUP BY 1`,
+		tableName,
+		presto.QuoteString(PrometheusMetricTimestampPartition(window.Start)),
+		presto.QuoteString(PrometheusMetricTimestampPartition(window.End)),
+		presto.FormatTimestamp(window.Start),
+		presto.FormatTimestamp(window.End),
+	)
+	results, err := presto.ExecuteSelect(queryer, query)
+	if err != nil {
+		return nil, fmt.Errorf("error counting metrics for table %s: %v", tableName, err)
+	}
+	counts := make(map[time.Time]int64, len(results))
+	for _, row := range results {
+		bucket, ok := row["bucket"].(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("invalid bucket %v counting metrics for table %s", row["bucket"], tableName)
+		}
+		count, ok := row["metrics"].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid count %v counting metrics for table %s", row["metrics"], tableName)
+		}
+		counts[bucket.UTC()] = count
+	}
+	return counts, nil
+}
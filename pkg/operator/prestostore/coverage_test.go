@@ -0,0 +1,95 @@
+package prestostore
+
+import (
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddTimeInterval(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	hours := func(start, end int) TimeInterval {
+		return TimeInterval{Start: janOne.Add(time.Duration(start) * time.Hour), End: janOne.Add(time.Duration(end) * time.Hour)}
+	}
+
+	var intervals []TimeInterval
+	intervals = AddTimeInterval(intervals, hours(4, 5))
+	intervals = AddTimeInterval(intervals, hours(0, 1))
+	intervals = AddTimeInterval(intervals, hours(8, 9))
+	assert.Equal(t, []TimeInterval{hours(0, 1), hours(4, 5), hours(8, 9)}, intervals)
+
+	// adjacent intervals are merged
+	intervals = AddTimeInterval(intervals, hours(1, 2))
+	assert.Equal(t, []TimeInterval{hours(0, 2), hours(4, 5), hours(8, 9)}, intervals)
+
+	// an interval overlapping several intervals merges them all
+	intervals = AddTimeInterval(intervals, hours(3, 8))
+	assert.Equal(t, []TimeInterval{hours(0, 2), hours(3, 9)}, intervals)
+
+	// empty intervals are ignored
+	intervals = AddTimeInterval(intervals, hours(2, 2))
+	assert.Equal(t, []TimeInterval{hours(0, 2), hours(3, 9)}, intervals)
+}
+
+func TestProcessedTimeRangeInterval(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	timeRanges := getTimeRangesChunked(janOne, janOne.Add(time.Hour), 5*time.Minute, time.Minute, 0)
+
+	// consecutive processed time ranges have no holes between them
+	var intervals []TimeInterval
+	for _, timeRange := range timeRanges {
+		intervals = AddTimeInterval(intervals, ProcessedTimeRangeInterval(timeRange))
+	}
+	assert.Len(t, intervals, 1)
+	assert.Equal(t, ProcessedTimeRangeInterval(prom.Range{Start: janOne, End: janOne.Add(5 * time.Minute), Step: time.Minute}), TimeInterval{Start: janOne, End: janOne.Add(6 * time.Minute)})
+}
+
+func TestFindGaps(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours, minutes int) time.Time {
+		return janOne.Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+
+	coverage := []TimeInterval{
+		{Start: at(0, 30), End: at(3, 0)},
+		{Start: at(4, 0), End: at(6, 15)},
+	}
+	counts := map[time.Time]int64{
+		at(0, 0): 30,
+		// hour 1 is empty
+		at(2, 0): 60,
+		at(4, 0): 60,
+		at(5, 0): 60,
+		// hour 6 is empty
+	}
+
+	tests := map[string]struct {
+		window   TimeInterval
+		expected []TimeInterval
+	}{
+		"everything": {
+			window: TimeInterval{Start: janOne, End: at(12, 0)},
+			expected: []TimeInterval{
+				{Start: at(1, 0), End: at(2, 0)},
+				{Start: at(3, 0), End: at(4, 0)},
+				{Start: at(6, 0), End: at(6, 15)},
+			},
+		},
+		"within window": {
+			window: TimeInterval{Start: at(3, 30), End: at(5, 0)},
+			expected: []TimeInterval{
+				{Start: at(3, 30), End: at(4, 0)},
+			},
+		},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, FindGaps(coverage, counts, test.window))
+		})
+	}
+
+	assert.Empty(t, FindGaps(nil, counts, TimeInterval{Start: janOne, End: at(12, 0)}), "expected no gaps without coverage")
+}
@@ -0,0 +1,134 @@
+package prestostore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
+)
+
+// PrometheusMetricsTableFiles is the Hive table of a Presto table storing
+// Prometheus metrics, whose metrics can be stored by writing Parquet files
+// into the partition locations of the table.
+type PrometheusMetricsTableFiles struct {
+	DatabaseName string
+	TableName    string
+	// Location is the location of the table, such as s3a://bucket/path. The
+	// files of each dt partition are written to Location/dt=<dt>, which is
+	// where Presto writes them when inserting.
+	Location string
+}
+
+// PrometheusMetricsTableLocator returns the Hive table of the Presto table
+// tableName if its metrics can be stored as files, or nil if they must be
+// inserted using Presto.
+type PrometheusMetricsTableLocator func(tableName string) *PrometheusMetricsTableFiles
+
+type FileWriter interface {
+	// WriteFile writes data to the file at location, a URL such as
+	// s3a://bucket/key.
+	WriteFile(ctx context.Context, location string, data []byte) error
+}
+
+type HivePartitionAdder interface {
+	AddPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error
+}
+
+// prometheusMetricsFileRepo is a PrometheusMetricsRepo which stores metrics as
+// Parquet files when the table is stored as Parquet in a location files can
+// be written to, and otherwise inserts them using Presto.
+type prometheusMetricsFileRepo struct {
+	PrometheusMetricsRepo
+	clock      clock.Clock
+	locate     PrometheusMetricsTableLocator
+	files      FileWriter
+	partitions HivePartitionAdder
+}
+
+func NewPrometheusMetricsFileRepo(clock clock.Clock, repo PrometheusMetricsRepo, locate PrometheusMetricsTableLocator, files FileWriter, partitions HivePartitionAdder) *prometheusMetricsFileRepo {
+	return &prometheusMetricsFileRepo{
+		PrometheusMetricsRepo: repo,
+		clock:                 clock,
+		locate:                locate,
+		files:                 files,
+		partitions:            partitions,
+	}
+}
+
+func (r *prometheusMetricsFileRepo) StorePrometheusMetrics(ctx context.Context, tableName string, metrics []*PrometheusMetric) error {
+	table := r.locate(tableName)
+	if table == nil {
+		return r.PrometheusMetricsRepo.StorePrometheusMetrics(ctx, tableName, metrics)
+	}
+	return StorePrometheusMetricsFiles(ctx, r.clock, r.files, r.partitions, *table, metrics)
+}
+
+// StorePrometheusMetricsFiles writes metrics as a Parquet file into each dt
+// partition of table they belong in, adding the partitions to the Hive
+// metastore if they don't already exist. Files are named by the time they're
+// written and a digest of their contents, so they don't replace the files of
+// earlier stores.
+func StorePrometheusMetricsFiles(ctx context.Context, clock clock.Clock, files FileWriter, partitions HivePartitionAdder, table PrometheusMetricsTableFiles, metrics []*PrometheusMetric) error {
+	partitionMetrics := make(map[string][]*PrometheusMetric)
+	for _, metric := range metrics {
+		dt := PrometheusMetricTimestampPartition(metric.Timestamp)
+		partitionMetrics[dt] = append(partitionMetrics[dt], metric)
+	}
+	dts := make([]string, 0, len(partitionMetrics))
+	for dt := range partitionMetrics {
+		dts = append(dts, dt)
+	}
+	sort.Strings(dts)
+
+	for _, dt := range dts {
+		var buf bytes.Buffer
+		if err := parquet.Write(&buf, prometheusMetricsParquetColumns(partitionMetrics[dt])); err != nil {
+			return fmt.Errorf("error encoding metrics for table %s: %v", table.TableName, err)
+		}
+		partitionLocation := fmt.Sprintf("%s/%s=%s", strings.TrimSuffix(table.Location, "/"), dtColumnName, dt)
+		digest := sha256.Sum256(buf.Bytes())
+		fileLocation := fmt.Sprintf("%s/%d_%x.parquet", partitionLocation, clock.Now().UnixNano(), digest[:8])
+		if err := files.WriteFile(ctx, fileLocation, buf.Bytes()); err != nil {
+			return fmt.Errorf("error writing metrics for table %s to %s: %v", table.TableName, fileLocation, err)
+		}
+		err := partitions.AddPartition(table.DatabaseName, table.TableName, PrometheusMetricHivePartitionColumns, hive.TablePartition{
+			Location:      partitionLocation,
+			PartitionSpec: hive.PartitionSpec{dtColumnName: dt},
+		})
+		if err != nil {
+			return fmt.Errorf("error adding partition %s=%s to table %s: %v", dtColumnName, dt, table.TableName, err)
+		}
+	}
+	return nil
+}
+
+// prometheusMetricsParquetColumns returns the columns of metrics in the order
+// of PrometheusMetricHiveTableColumns, since Presto matches the columns of
+// Parquet files to the columns of Hive tables by index by default. Names are
+// lowercase, as Hive column names are.
+func prometheusMetricsParquetColumns(metrics []*PrometheusMetric) []parquet.Column {
+	amounts := make([]float64, len(metrics))
+	timestamps := make([]time.Time, len(metrics))
+	timePrecisions := make([]float64, len(metrics))
+	labels := make([]map[string]string, len(metrics))
+	for i, metric := range metrics {
+		amounts[i] = metric.Amount
+		timestamps[i] = metric.Timestamp
+		timePrecisions[i] = metric.StepSize.Seconds()
+		labels[i] = metric.Labels
+	}
+	return []parquet.Column{
+		{Name: amountColumnName, Type: parquet.Double, Doubles: amounts},
+		{Name: timestampColumnName, Type: parquet.Timestamp, Timestamps: timestamps},
+		{Name: strings.ToLower(timePrecisionColumnName), Type: parquet.Double, Doubles: timePrecisions},
+		{Name: labelsColumnName, Type: parquet.StringMap, StringMaps: labels},
+	}
+}
@@ -0,0 +1,92 @@
+package prestostore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/parquet"
+)
+
+type fakeFileWriter struct {
+	files map[string][]byte
+}
+
+func (w *fakeFileWriter) WriteFile(ctx context.Context, location string, data []byte) error {
+	w.files[location] = data
+	return nil
+}
+
+type fakeHivePartitionAdder struct {
+	partitions []hive.TablePartition
+}
+
+func (a *fakeHivePartitionAdder) AddPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error {
+	a.partitions = append(a.partitions, partition)
+	return nil
+}
+
+// insertingPrometheusMetricsRepo records the metrics stored using Presto.
+type insertingPrometheusMetricsRepo struct {
+	PrometheusMetricsRepo
+	inserted map[string][]*PrometheusMetric
+}
+
+func (r *insertingPrometheusMetricsRepo) StorePrometheusMetrics(ctx context.Context, tableName string, metrics []*PrometheusMetric) error {
+	r.inserted[tableName] = append(r.inserted[tableName], metrics...)
+	return nil
+}
+
+func TestPrometheusMetricsFileRepo(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 23, 58, 0, 0, time.UTC)
+	var metrics []*PrometheusMetric
+	for i := 0; i < 4; i++ {
+		metrics = append(metrics, &PrometheusMetric{
+			Labels:    map[string]string{"pod": "foo"},
+			Amount:    float64(i),
+			StepSize:  time.Minute,
+			Timestamp: janOne.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	inserting := &insertingPrometheusMetricsRepo{inserted: make(map[string][]*PrometheusMetric)}
+	files := &fakeFileWriter{files: make(map[string][]byte)}
+	partitions := &fakeHivePartitionAdder{}
+	now := time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC)
+	repo := NewPrometheusMetricsFileRepo(clock.NewFakeClock(now), inserting, func(tableName string) *PrometheusMetricsTableFiles {
+		if tableName != "hive.metering.parquet_table" {
+			return nil
+		}
+		return &PrometheusMetricsTableFiles{DatabaseName: "metering", TableName: "parquet_table", Location: "s3a://bucket/metering/parquet_table/"}
+	}, files, partitions)
+
+	require.NoError(t, repo.StorePrometheusMetrics(context.Background(), "hive.metering.parquet_table", metrics))
+	assert.Empty(t, inserting.inserted)
+	// the metrics span two days, so a file is written to each partition
+	assert.Equal(t, []hive.TablePartition{
+		{Location: "s3a://bucket/metering/parquet_table/dt=2019-01-01", PartitionSpec: hive.PartitionSpec{"dt": "2019-01-01"}},
+		{Location: "s3a://bucket/metering/parquet_table/dt=2019-01-02", PartitionSpec: hive.PartitionSpec{"dt": "2019-01-02"}},
+	}, partitions.partitions)
+	// files are named by the time they're written and a digest of their
+	// contents
+	expectedFiles := make(map[string][]byte)
+	for dt, dtMetrics := range map[string][]*PrometheusMetric{"2019-01-01": metrics[:2], "2019-01-02": metrics[2:]} {
+		var buf bytes.Buffer
+		require.NoError(t, parquet.Write(&buf, prometheusMetricsParquetColumns(dtMetrics)))
+		digest := sha256.Sum256(buf.Bytes())
+		expectedFiles[fmt.Sprintf("s3a://bucket/metering/parquet_table/dt=%s/1546387200000000000_%x.parquet", dt, digest[:8])] = buf.Bytes()
+	}
+	assert.Equal(t, expectedFiles, files.files)
+
+	// tables which can't be written to as files are inserted into
+	require.NoError(t, repo.StorePrometheusMetrics(context.Background(), "hive.metering.orc_table", metrics))
+	assert.Equal(t, metrics, inserting.inserted["hive.metering.orc_table"])
+}
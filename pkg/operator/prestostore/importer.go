@@ -10,6 +10,8 @@ import (
 	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
 )
 
 type ImporterMetricsCollectors struct {
@@ -29,6 +31,12 @@ type ImporterMetricsCollectors struct {
 	MetricsImportedCounter prometheus.Counter
 
 	ImportsRunningGauge prometheus.Gauge
+
+	// QueuedPrometheusQueriesGauge and InFlightPrometheusQueriesGauge track
+	// the queries waiting for the ImportScheduler, and the queries running.
+	QueuedPrometheusQueriesGauge          prometheus.Gauge
+	InFlightPrometheusQueriesGauge        prometheus.Gauge
+	PrometheusQueryQueueDurationHistogram prometheus.Observer
 }
 
 // PrometheusImporter imports Prometheus metrics into Presto tables
@@ -59,6 +67,20 @@ type Config struct {
 	MaxQueryRangeDuration     time.Duration
 	ImportFromTime            *time.Time
 	MaxBackfillImportDuration time.Duration
+	// RemoteRead, if set, makes the importer read raw samples using the
+	// Prometheus remote_read API instead of running PrometheusQuery.
+	RemoteRead *RemoteReadConfig
+	// RelabelConfigs are applied to the labels of each series before its
+	// metrics are stored, dropping series which are dropped by them.
+	RelabelConfigs []*relabel.Config
+	// ChunkConcurrency is the number of chunks fetched from Prometheus at
+	// once. Chunks are still stored in order. Values less than 2 fetch
+	// chunks one at a time.
+	ChunkConcurrency int
+	// Scheduler, if set, limits the Prometheus queries run by imports,
+	// SchedulerKey identifying the import when waiting for it.
+	Scheduler    *ImportScheduler
+	SchedulerKey ImportSchedulerKey
 }
 
 func NewPrometheusImporter(logger logrus.FieldLogger, promConn prom.API, prometheusMetricsRepo PrometheusMetricsRepo, clock clock.Clock, cfg Config, collectors ImporterMetricsCollectors) *PrometheusImporter {
@@ -89,6 +111,13 @@ func (importer *PrometheusImporter) UpdateConfig(cfg Config) {
 	importer.importLock.Unlock()
 }
 
+// UpdatePrometheusConn replaces the prom.API the importer queries.
+func (importer *PrometheusImporter) UpdatePrometheusConn(promConn prom.API) {
+	importer.importLock.Lock()
+	importer.promConn = promConn
+	importer.importLock.Unlock()
+}
+
 // ImportFromLastTimestamp executes a Presto query from the last time range it
 // queried and stores the results in a Presto table.
 // The importer will track the last time series it retrieved and will query
@@ -172,13 +201,24 @@ func (importer *PrometheusImporter) ImportFromLastTimestamp(ctx context.Context)
 	return &importResults, nil
 }
 
-func promMatrixToPrometheusMetrics(timeRange prom.Range, matrix model.Matrix) []*PrometheusMetric {
+// promMatrixToPrometheusMetrics returns the samples of matrix after
+// relabeling their series, or an error if relabeling gives distinct series
+// the same labels.
+func promMatrixToPrometheusMetrics(timeRange prom.Range, matrix model.Matrix, relabelConfigs []*relabel.Config) ([]*PrometheusMetric, error) {
 	var metrics []*PrometheusMetric
+	seriesSet := relabel.NewSeriesSet()
 	// iterate over segments of contiguous billing metrics
 	for _, sampleStream := range matrix {
-		labels := make(map[string]string, len(sampleStream.Metric))
+		original := make(map[string]string, len(sampleStream.Metric))
 		for k, v := range sampleStream.Metric {
-			labels[string(k)] = string(v)
+			original[string(k)] = string(v)
+		}
+		labels := relabel.Process(original, relabelConfigs)
+		if labels == nil {
+			continue
+		}
+		if err := seriesSet.Add(original, labels); err != nil {
+			return nil, err
 		}
 		for _, value := range sampleStream.Values {
 			metric := &PrometheusMetric{
@@ -190,5 +230,5 @@ func promMatrixToPrometheusMetrics(timeRange prom.Range, matrix model.Matrix) []
 			metrics = append(metrics, metric)
 		}
 	}
-	return metrics
+	return metrics, nil
 }
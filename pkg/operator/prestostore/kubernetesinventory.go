@@ -0,0 +1,123 @@
+package prestostore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+var (
+	// KubernetesInventoryHiveTableColumns are the columns of every
+	// KubernetesInventory table, which are followed by the configured
+	// columns of the ReportDataSource.
+	KubernetesInventoryHiveTableColumns = []hive.Column{
+		{Name: timestampColumnName, Type: "timestamp"},
+		{Name: "namespace", Type: "string"},
+		{Name: "name", Type: "string"},
+		{Name: "uid", Type: "string"},
+		{Name: "owner_kind", Type: "string"},
+		{Name: "owner_name", Type: "string"},
+		{Name: labelsColumnName, Type: "map<string, string>"},
+	}
+	// KubernetesInventoryHivePartitionColumns partition the snapshots by the
+	// day they were taken, the same as Prometheus metrics.
+	KubernetesInventoryHivePartitionColumns = PrometheusMetricHivePartitionColumns
+)
+
+// KubernetesInventoryHiveColumns returns the columns of a KubernetesInventory
+// table with the configured columns, which are varchar.
+func KubernetesInventoryHiveColumns(columns []string) []hive.Column {
+	hiveColumns := make([]hive.Column, 0, len(KubernetesInventoryHiveTableColumns)+len(columns))
+	hiveColumns = append(hiveColumns, KubernetesInventoryHiveTableColumns...)
+	for _, column := range columns {
+		hiveColumns = append(hiveColumns, hive.Column{Name: column, Type: "string"})
+	}
+	return hiveColumns
+}
+
+// KubernetesInventoryObject is the snapshot of a Kubernetes object at
+// Timestamp.
+type KubernetesInventoryObject struct {
+	Timestamp time.Time
+	// Namespace is empty for cluster scoped objects, such as Nodes.
+	Namespace string
+	Name      string
+	UID       string
+	// OwnerKind and OwnerName identify the controller of the object, and
+	// are empty if it doesn't have one.
+	OwnerKind string
+	OwnerName string
+	Labels    map[string]string
+	// Values are the values of the configured columns by column name.
+	// Columns without a value are null.
+	Values map[string]string
+}
+
+type KubernetesInventoryStorer interface {
+	// StoreKubernetesInventory inserts objects into tableName, which has the
+	// configured columns named by columns.
+	StoreKubernetesInventory(ctx context.Context, tableName string, columns []string, objects []*KubernetesInventoryObject) error
+}
+
+type kubernetesInventoryRepo struct {
+	queryer         db.Queryer
+	queryBufferPool *sync.Pool
+}
+
+func NewKubernetesInventoryRepo(queryer db.Queryer, queryBufferPool *sync.Pool) *kubernetesInventoryRepo {
+	if queryBufferPool == nil {
+		queryBufferPool = &defaultQueryBufferPool
+	}
+	return &kubernetesInventoryRepo{
+		queryer:         queryer,
+		queryBufferPool: queryBufferPool,
+	}
+}
+
+func (r *kubernetesInventoryRepo) StoreKubernetesInventory(ctx context.Context, tableName string, columns []string, objects []*KubernetesInventoryObject) error {
+	queryBuf := r.queryBufferPool.Get().(*bytes.Buffer)
+	queryBuf.Reset()
+	defer r.queryBufferPool.Put(queryBuf)
+	return insertValuesWithBuffer(queryBuf, ctx, r.queryer, tableName, "Kubernetes objects", len(objects), func(i int) string {
+		return generateKubernetesInventorySQLValues(objects[i], columns)
+	})
+}
+
+// generateKubernetesInventorySQLValues turns a KubernetesInventoryObject into
+// a SQL literal suited for INSERT statements, with the columns in the order
+// of KubernetesInventoryHiveColumns followed by the dt partition column.
+func generateKubernetesInventorySQLValues(object *KubernetesInventoryObject, columns []string) string {
+	values := []string{
+		presto.FormatTimestamp(object.Timestamp),
+		nullableString(object.Namespace),
+		presto.QuoteString(object.Name),
+		presto.QuoteString(object.UID),
+		nullableString(object.OwnerKind),
+		nullableString(object.OwnerName),
+		presto.FormatStringMap(object.Labels),
+	}
+	for _, column := range columns {
+		value, ok := object.Values[column]
+		if !ok {
+			values = append(values, "NULL")
+			continue
+		}
+		values = append(values, presto.QuoteString(value))
+	}
+	values = append(values, presto.QuoteString(PrometheusMetricTimestampPartition(object.Timestamp)))
+	return "(" + strings.Join(values, ",") + ")"
+}
+
+// nullableString returns s as a string literal, or NULL if s is empty.
+func nullableString(s string) string {
+	if s == "" {
+		return "NULL"
+	}
+	return presto.QuoteString(s)
+}
@@ -0,0 +1,37 @@
+package prestostore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateKubernetesInventorySQLValues(t *testing.T) {
+	object := &KubernetesInventoryObject{
+		Timestamp: time.Date(2024, time.March, 1, 23, 45, 0, 0, time.UTC),
+		Namespace: "web",
+		Name:      "frontend-7d9c8-abcde",
+		UID:       "6a1e6a9e-4f3b-4c5d-9d2e-0c1f2a3b4c5d",
+		OwnerKind: "ReplicaSet",
+		OwnerName: "frontend-7d9c8",
+		Labels:    map[string]string{"app": "frontend"},
+		Values:    map[string]string{"qos_class": "Burstable", "priority_class": ""},
+	}
+	assert.Equal(t,
+		`(timestamp '2024-03-01 23:45:00.000','web','frontend-7d9c8-abcde','6a1e6a9e-4f3b-4c5d-9d2e-0c1f2a3b4c5d','ReplicaSet','frontend-7d9c8',map(ARRAY['app'],ARRAY['frontend']),'Burstable','',NULL,'2024-03-01')`,
+		generateKubernetesInventorySQLValues(object, []string{"qos_class", "priority_class", "node"}),
+	)
+
+	// cluster scoped objects without a controller have null namespaces and
+	// owners
+	object = &KubernetesInventoryObject{
+		Timestamp: time.Date(2024, time.March, 1, 23, 45, 0, 0, time.UTC),
+		Name:      "worker-0",
+		UID:       "0c1f2a3b",
+	}
+	assert.Equal(t,
+		`(timestamp '2024-03-01 23:45:00.000',NULL,'worker-0','0c1f2a3b',NULL,NULL,map(ARRAY[],ARRAY[]),'2024-03-01')`,
+		generateKubernetesInventorySQLValues(object, nil),
+	)
+}
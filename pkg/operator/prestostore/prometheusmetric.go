@@ -78,10 +78,25 @@ type PrometheusMetricTimestampTracker interface {
 	GetLastTimestampForTable(tableName string) (*time.Time, error)
 }
 
+type PrometheusMetricCoverageChecker interface {
+	GetMetricCountsByBucket(tableName string, window TimeInterval) (map[time.Time]int64, error)
+}
+
+type PrometheusMetricPartitionDeleter interface {
+	DeletePrometheusMetricsPartition(tableName, dt string) error
+}
+
+type PrometheusMetricPartitionLister interface {
+	GetPrometheusMetricsPartitions(tableName string) ([]string, error)
+}
+
 type PrometheusMetricsRepo interface {
 	PrometheusMetricsGetter
 	PrometheusMetricsStorer
 	PrometheusMetricTimestampTracker
+	PrometheusMetricCoverageChecker
+	PrometheusMetricPartitionDeleter
+	PrometheusMetricPartitionLister
 }
 
 type prometheusMetricRepo struct {
@@ -130,6 +145,56 @@ func (r *prometheusMetricRepo) GetLastTimestampForTable(tableName string) (*time
 	return nil, nil
 }
 
+func (r *prometheusMetricRepo) GetMetricCountsByBucket(tableName string, window TimeInterval) (map[time.Time]int64, error) {
+	return GetMetricCountsByBucket(r.queryer, tableName, window)
+}
+
+// DeletePrometheusMetricsPartition deletes the metrics in the dt partition of
+// tableName, as returned by PrometheusMetricTimestampPartition.
+func (r *prometheusMetricRepo) DeletePrometheusMetricsPartition(tableName, dt string) error {
+	err := presto.DeleteFromWhere(r.queryer, tableName, fmt.Sprintf(`"dt" = %s`, presto.QuoteString(dt)))
+	if err != nil {
+		return fmt.Errorf("error deleting partition dt=%s of table %s: %v", dt, tableName, err)
+	}
+	return nil
+}
+
+// GetPrometheusMetricsPartitions returns the dt partitions of tableName in
+// ascending order. tableName must be fully qualified, and the partitions are
+// read from the Hive $partitions table so no data is scanned.
+func (r *prometheusMetricRepo) GetPrometheusMetricsPartitions(tableName string) ([]string, error) {
+	return getPrometheusPartitions(r.queryer, tableName)
+}
+
+// HivePartitionsTableName returns the name of the Hive $partitions table
+// listing the partitions of tableName.
+func HivePartitionsTableName(tableName string) string {
+	idx := strings.LastIndex(tableName, ".")
+	return tableName[:idx+1] + presto.QuoteIdentifier(tableName[idx+1:]+"$partitions")
+}
+
+// getPrometheusPartitions returns the dt partitions of tableName in
+// ascending order, which must be fully qualified.
+func getPrometheusPartitions(queryer db.Queryer, tableName string) ([]string, error) {
+	if !strings.Contains(tableName, ".") {
+		return nil, fmt.Errorf("table %s is not fully qualified", tableName)
+	}
+	query := fmt.Sprintf(`SELECT "dt" FROM %s ORDER BY 1`, HivePartitionsTableName(tableName))
+	results, err := presto.ExecuteSelect(queryer, query)
+	if err != nil {
+		return nil, fmt.Errorf("error listing partitions of table %s: %v", tableName, err)
+	}
+	partitions := make([]string, 0, len(results))
+	for _, row := range results {
+		dt, ok := row["dt"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid partition %v listing partitions of table %s", row["dt"], tableName)
+		}
+		partitions = append(partitions, dt)
+	}
+	return partitions, nil
+}
+
 // PrometheusMetric is a receipt of a usage determined by a query within a specific time range.
 type PrometheusMetric struct {
 	Labels    map[string]string `json:"labels"`
@@ -142,6 +207,15 @@ type PrometheusMetric struct {
 // storePrometheusMetricsWithBuffer handles storing Prometheus metrics into the
 // specified Presto table.
 func StorePrometheusMetricsWithBuffer(queryBuf *bytes.Buffer, ctx context.Context, queryer db.Queryer, tableName string, metrics []*PrometheusMetric) error {
+	return insertValuesWithBuffer(queryBuf, ctx, queryer, tableName, "metrics", len(metrics), func(i int) string {
+		return generatePrometheusMetricSQLValues(metrics[i])
+	})
+}
+
+// insertValuesWithBuffer inserts numRows rows into tableName using as few
+// INSERT statements as fit in the capacity of queryBuf. rowSQLValues returns
+// the SQL literal of the i-th row, and kind describes the rows in errors.
+func insertValuesWithBuffer(queryBuf *bytes.Buffer, ctx context.Context, queryer db.Queryer, tableName, kind string, numRows int, rowSQLValues func(i int) string) error {
 	bufferCapacity := queryBuf.Cap()
 
 	insertStatementLength := len(presto.FormatInsertQuery(tableName, ""))
@@ -152,10 +226,9 @@ func StorePrometheusMetricsWithBuffer(queryBuf *bytes.Buffer, ctx context.Contex
 	commaStr := ","
 	valuesStmtStr := "VALUES "
 
-	metricsInBuffer := false
-	numMetrics := len(metrics)
+	rowsInBuffer := false
 
-	for i, metric := range metrics {
+	for i := 0; i < numRows; i++ {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -163,39 +236,39 @@ func StorePrometheusMetricsWithBuffer(queryBuf *bytes.Buffer, ctx context.Contex
 			// continue processing if context isn't cancelled.
 		}
 
-		metricSQLStr := generatePrometheusMetricSQLValues(metric)
+		rowSQLStr := rowSQLValues(i)
 
-		// lastMetric means we need to insert after writing the metric to the
+		// lastRow means we need to insert after writing the row to the
 		// buffer
-		lastMetric := i == (numMetrics - 1)
+		lastRow := i == (numRows - 1)
 
-		if metricsInBuffer {
-			// if writing the current metricSQLStr to the buffer would exceed the
+		if rowsInBuffer {
+			// if writing the current rowSQLStr to the buffer would exceed the
 			// bufferCapacity, perform the insert query, and reset the buffer
 			// to flush it
-			bytesToWrite := len(commaStr + metricSQLStr)
+			bytesToWrite := len(commaStr + rowSQLStr)
 			if (bytesToWrite + queryBuf.Len()) > queryCap {
 				err := presto.InsertInto(queryer, tableName, queryBuf.String())
 				if err != nil {
-					return fmt.Errorf("failed to store metrics into presto: %v", err)
+					return fmt.Errorf("failed to store %s into presto: %v", kind, err)
 				}
 				queryBuf.Reset()
 
 				// we just inserted the contents of the buffer, so reset
-				// metricsInBuffer and prepend VALUES
-				metricsInBuffer = false
+				// rowsInBuffer and prepend VALUES
+				rowsInBuffer = false
 			}
 		}
 
 		var toWrite string
-		if !metricsInBuffer {
-			// no metrics in buffer means we need to prepend "VALUES " before
-			// we write metricSQL
-			toWrite = valuesStmtStr + metricSQLStr
+		if !rowsInBuffer {
+			// no rows in buffer means we need to prepend "VALUES " before
+			// we write rowSQL
+			toWrite = valuesStmtStr + rowSQLStr
 		} else {
-			// existing metrics in buffer means we need to prepend "," before
-			// we write metricSQL since that separates each record
-			toWrite = commaStr + metricSQLStr
+			// existing rows in buffer means we need to prepend "," before
+			// we write rowSQL since that separates each record
+			toWrite = commaStr + rowSQLStr
 		}
 
 		bytesToWrite := len(toWrite)
@@ -207,14 +280,14 @@ func StorePrometheusMetricsWithBuffer(queryBuf *bytes.Buffer, ctx context.Contex
 		if err != nil {
 			return fmt.Errorf(`error writing %q string to buffer: %v`, toWrite, err)
 		}
-		metricsInBuffer = true
+		rowsInBuffer = true
 
-		// this is the last metric in the loop, insert the contents of the
+		// this is the last row in the loop, insert the contents of the
 		// buffer
-		if lastMetric {
+		if lastRow {
 			err := presto.InsertInto(queryer, tableName, queryBuf.String())
 			if err != nil {
-				return fmt.Errorf("failed to store metrics into presto: %v", err)
+				return fmt.Errorf("failed to store %s into presto: %v", kind, err)
 			}
 			queryBuf.Reset()
 		}
@@ -234,17 +307,13 @@ func StorePrometheusMetricsWithBuffer(queryBuf *bytes.Buffer, ctx context.Contex
 // the following columns are partition columns:
 // column "dt" type: "string"
 func generatePrometheusMetricSQLValues(metric *PrometheusMetric) string {
-	var keys []string
-	var vals []string
-	for k, v := range metric.Labels {
-		keys = append(keys, "'"+k+"'")
-		vals = append(vals, "'"+v+"'")
-	}
-	keyString := "ARRAY[" + strings.Join(keys, ",") + "]"
-	valString := "ARRAY[" + strings.Join(vals, ",") + "]"
 	dt := PrometheusMetricTimestampPartition(metric.Timestamp)
-	return fmt.Sprintf("(%f,timestamp '%s',%f,map(%s,%s),'%s')",
-		metric.Amount, metric.Timestamp.Format(presto.TimestampFormat), metric.StepSize.Seconds(), keyString, valString, dt,
+	return fmt.Sprintf("(%s,%s,%s,%s,%s)",
+		presto.FormatDouble(metric.Amount),
+		presto.FormatTimestamp(metric.Timestamp),
+		presto.FormatDouble(metric.StepSize.Seconds()),
+		presto.FormatStringMap(metric.Labels),
+		presto.QuoteString(dt),
 	)
 }
 
@@ -257,7 +326,7 @@ func PrometheusMetricTimestampPartition(t time.Time) string {
 func GetPrometheusMetrics(queryer db.Queryer, tableName string, start, end time.Time) ([]*PrometheusMetric, error) {
 	whereClause := ""
 	if !start.IsZero() {
-		whereClause += fmt.Sprintf(`WHERE "timestamp" >= timestamp '%s' `, start.Format(presto.TimestampFormat))
+		whereClause += fmt.Sprintf(`WHERE "timestamp" >= %s `, presto.FormatTimestamp(start))
 	}
 	if !end.IsZero() {
 		if !start.IsZero() {
@@ -265,7 +334,7 @@ func GetPrometheusMetrics(queryer db.Queryer, tableName string, start, end time.
 		} else {
 			whereClause += " WHERE "
 		}
-		whereClause += fmt.Sprintf(`"timestamp" <= timestamp '%s'`, end.Format(presto.TimestampFormat))
+		whereClause += fmt.Sprintf(`"timestamp" <= %s`, presto.FormatTimestamp(end))
 	}
 
 	rows, err := presto.GetRowsWhere(queryer, tableName, PrometheusMetricPrestoAllColumns, whereClause)
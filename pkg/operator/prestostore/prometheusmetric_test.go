@@ -0,0 +1,33 @@
+package prestostore
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePrometheusMetricSQLValues(t *testing.T) {
+	metric := &PrometheusMetric{
+		Labels: map[string]string{
+			"pod":         "foo",
+			"annotation":  "it's'); DROP TABLE metrics; --",
+			"description": "line one\nline two",
+		},
+		Amount:    1e-9,
+		StepSize:  time.Minute,
+		Timestamp: time.Date(2019, time.January, 1, 23, 59, 0, 0, time.UTC),
+	}
+	assert.Equal(t,
+		`(1E-09,timestamp '2019-01-01 23:59:00.000',6E+01,map(ARRAY['annotation','description','pod'],ARRAY['it''s''); DROP TABLE metrics; --',U&'line one\000Aline two','foo']),'2019-01-01')`,
+		generatePrometheusMetricSQLValues(metric),
+	)
+
+	metric.Amount = math.NaN()
+	metric.Labels = nil
+	assert.Equal(t,
+		`(nan(),timestamp '2019-01-01 23:59:00.000',6E+01,map(ARRAY[],ARRAY[]),'2019-01-01')`,
+		generatePrometheusMetricSQLValues(metric),
+	)
+}
@@ -0,0 +1,507 @@
+package prestostore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
+)
+
+const (
+	PrometheusSnapshotSeriesColumnName    = "series"
+	PrometheusSnapshotPresentColumnName   = "present"
+	PrometheusSnapshotValidFromColumnName = "valid_from"
+	PrometheusSnapshotValidToColumnName   = "valid_to"
+)
+
+var (
+	// PrometheusSnapshotHiveTableColumns are the columns of the raw table of
+	// a snapshot mode Prometheus ReportDataSource, which has a row for each
+	// series each time it's added, changed or removed.
+	PrometheusSnapshotHiveTableColumns = []hive.Column{
+		{Name: amountColumnName, Type: "double"},
+		{Name: timestampColumnName, Type: "timestamp"},
+		{Name: labelsColumnName, Type: "map<string, string>"},
+		{Name: PrometheusSnapshotSeriesColumnName, Type: "string"},
+		{Name: PrometheusSnapshotPresentColumnName, Type: "boolean"},
+	}
+	// PrometheusSnapshotHivePartitionColumns partition the rows by the day
+	// of the snapshot, the same as Prometheus metrics. Each partition starts
+	// with a row for every series present at midnight, so the series of a
+	// day can be read without the partitions of earlier days.
+	PrometheusSnapshotHivePartitionColumns = PrometheusMetricHivePartitionColumns
+)
+
+// PrometheusSnapshotRow is the value of a series from the snapshot taken at
+// Timestamp, which is valid until the next row of the series.
+type PrometheusSnapshotRow struct {
+	// Series identifies the series, and is the same for every row with the
+	// same labels.
+	Series    string
+	Labels    map[string]string
+	Amount    float64
+	Timestamp time.Time
+	// Present is false for the rows recording that a series was removed,
+	// which have the last Amount of the series.
+	Present bool
+}
+
+// PrometheusSnapshot is the series present after a snapshot.
+type PrometheusSnapshot struct {
+	// Time is when the snapshot was taken, and is zero if no snapshot has
+	// been stored.
+	Time time.Time
+	// Series are the series in the snapshot by their Series key.
+	Series map[string]*PrometheusSnapshotRow
+}
+
+type PrometheusSnapshotRepo interface {
+	// StorePrometheusSnapshotRows inserts rows into tableName.
+	StorePrometheusSnapshotRows(ctx context.Context, tableName string, rows []*PrometheusSnapshotRow) error
+	// GetLatestPrometheusSnapshot returns the series present after the
+	// last snapshot stored in tableName, read from the partitions from the
+	// one of since onwards, or from the newest partition if since is zero.
+	GetLatestPrometheusSnapshot(tableName string, since time.Time) (*PrometheusSnapshot, error)
+}
+
+type prometheusSnapshotRepo struct {
+	queryer         db.Queryer
+	queryBufferPool *sync.Pool
+}
+
+func NewPrometheusSnapshotRepo(queryer db.Queryer, queryBufferPool *sync.Pool) *prometheusSnapshotRepo {
+	if queryBufferPool == nil {
+		queryBufferPool = &defaultQueryBufferPool
+	}
+	return &prometheusSnapshotRepo{
+		queryer:         queryer,
+		queryBufferPool: queryBufferPool,
+	}
+}
+
+func (r *prometheusSnapshotRepo) StorePrometheusSnapshotRows(ctx context.Context, tableName string, rows []*PrometheusSnapshotRow) error {
+	queryBuf := r.queryBufferPool.Get().(*bytes.Buffer)
+	queryBuf.Reset()
+	defer r.queryBufferPool.Put(queryBuf)
+	return insertValuesWithBuffer(queryBuf, ctx, r.queryer, tableName, "snapshot rows", len(rows), func(i int) string {
+		return generatePrometheusSnapshotSQLValues(rows[i])
+	})
+}
+
+// GetLatestPrometheusSnapshot reads the newest row of each series, which is
+// how a snapshotter which was restarted knows which series changed. Every
+// series present is stored in the newest partition, so only it is read,
+// unless since is set by a snapshotter whose last store failed part way.
+func (r *prometheusSnapshotRepo) GetLatestPrometheusSnapshot(tableName string, since time.Time) (*PrometheusSnapshot, error) {
+	snapshot := &PrometheusSnapshot{Series: make(map[string]*PrometheusSnapshotRow)}
+	partitions, err := getPrometheusPartitions(r.queryer, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the latest snapshot of table %s: %v", tableName, err)
+	}
+	if len(partitions) == 0 {
+		return snapshot, nil
+	}
+	fromPartition := partitions[len(partitions)-1]
+	if !since.IsZero() && PrometheusMetricTimestampPartition(since) < fromPartition {
+		fromPartition = PrometheusMetricTimestampPartition(since)
+	}
+
+	query := fmt.Sprintf(`
+				SELECT "series", "labels", "amount", "timestamp", "present"
+				FROM (
+					SELECT *, row_number() OVER (PARTITION BY "series" ORDER BY "timestamp" DESC) AS snapshot_row_number
+					FROM %s
+					WHERE "dt" >= %s
+				)
+				WHERE snapshot_row_number = 1`, tableName, presto.QuoteString(fromPartition))
+	results, err := presto.ExecuteSelect(r.queryer, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the latest snapshot of table %s: %v", tableName, err)
+	}
+
+	for _, result := range results {
+		row, err := prometheusSnapshotRowFromResult(result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid row getting the latest snapshot of table %s: %v", tableName, err)
+		}
+		if row.Timestamp.After(snapshot.Time) {
+			snapshot.Time = row.Timestamp
+		}
+		if row.Present {
+			snapshot.Series[row.Series] = row
+		}
+	}
+	return snapshot, nil
+}
+
+func prometheusSnapshotRowFromResult(result map[string]interface{}) (*PrometheusSnapshotRow, error) {
+	series, ok := result[PrometheusSnapshotSeriesColumnName].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid series %v", result[PrometheusSnapshotSeriesColumnName])
+	}
+	rowLabels, ok := result[labelsColumnName].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid labels %v of series %s", result[labelsColumnName], series)
+	}
+	labels := make(map[string]string, len(rowLabels))
+	for key, value := range rowLabels {
+		labels[key], ok = value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid label %s of series %s, valueType: %T, value: %+v", key, series, value, value)
+		}
+	}
+	var amount float64
+	switch v := result[amountColumnName].(type) {
+	case float64:
+		amount = v
+	case string:
+		// NaN and the infinities are returned as strings
+		var err error
+		amount, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q of series %s", v, series)
+		}
+	default:
+		return nil, fmt.Errorf("invalid amount %v of series %s", v, series)
+	}
+	timestamp, ok := result[timestampColumnName].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("invalid timestamp %v of series %s", result[timestampColumnName], series)
+	}
+	present, ok := result[PrometheusSnapshotPresentColumnName].(bool)
+	if !ok {
+		return nil, fmt.Errorf("invalid present %v of series %s", result[PrometheusSnapshotPresentColumnName], series)
+	}
+	return &PrometheusSnapshotRow{
+		Series:    series,
+		Labels:    labels,
+		Amount:    amount,
+		Timestamp: timestamp.UTC(),
+		Present:   present,
+	}, nil
+}
+
+// generatePrometheusSnapshotSQLValues turns a PrometheusSnapshotRow into a
+// SQL literal suited for INSERT statements, with the columns in the order of
+// PrometheusSnapshotHiveTableColumns followed by the dt partition column.
+func generatePrometheusSnapshotSQLValues(row *PrometheusSnapshotRow) string {
+	return fmt.Sprintf("(%s,%s,%s,%s,%t,%s)",
+		presto.FormatDouble(row.Amount),
+		presto.FormatTimestamp(row.Timestamp),
+		presto.FormatStringMap(row.Labels),
+		presto.QuoteString(row.Series),
+		row.Present,
+		presto.QuoteString(PrometheusMetricTimestampPartition(row.Timestamp)),
+	)
+}
+
+// PrometheusSeriesKey returns the key identifying the series with labels,
+// which is the same as the series selector matching only it.
+func PrometheusSeriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// DiffPrometheusSnapshot returns the rows to store for the series of the
+// snapshot cur, given the series of the previous snapshot prev. A row is
+// returned for each series which was added, whose amount changed, or which
+// was removed at ts. Each series in prev whose row is in an earlier
+// partition than ts is also carried over to the partition of ts with a row
+// at its midnight, so the rows of a partition don't depend on older
+// partitions. Rows are sorted by series and timestamp.
+func DiffPrometheusSnapshot(prev, cur map[string]*PrometheusSnapshotRow, ts time.Time) []*PrometheusSnapshotRow {
+	partition := PrometheusMetricTimestampPartition(ts)
+	midnight := ts.UTC().Truncate(24 * time.Hour)
+	var rows []*PrometheusSnapshotRow
+	for series, row := range cur {
+		prevRow, exists := prev[series]
+		unchanged := exists && prometheusSnapshotAmountsEqual(prevRow.Amount, row.Amount)
+		if exists && PrometheusMetricTimestampPartition(prevRow.Timestamp) != partition && (unchanged || midnight.Before(ts)) {
+			rows = append(rows, prometheusSnapshotCarryOverRow(prevRow, midnight))
+		}
+		if !unchanged {
+			rows = append(rows, row)
+		}
+	}
+	for series, prevRow := range prev {
+		if _, exists := cur[series]; !exists {
+			if PrometheusMetricTimestampPartition(prevRow.Timestamp) != partition && midnight.Before(ts) {
+				rows = append(rows, prometheusSnapshotCarryOverRow(prevRow, midnight))
+			}
+			rows = append(rows, &PrometheusSnapshotRow{
+				Series:    series,
+				Labels:    prevRow.Labels,
+				Amount:    prevRow.Amount,
+				Timestamp: ts,
+				Present:   false,
+			})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Series != rows[j].Series {
+			return rows[i].Series < rows[j].Series
+		}
+		return rows[i].Timestamp.Before(rows[j].Timestamp)
+	})
+	return rows
+}
+
+// prometheusSnapshotCarryOverRow returns the row carrying the value of the
+// series of row over to the partition starting at midnight.
+func prometheusSnapshotCarryOverRow(row *PrometheusSnapshotRow, midnight time.Time) *PrometheusSnapshotRow {
+	return &PrometheusSnapshotRow{
+		Series:    row.Series,
+		Labels:    row.Labels,
+		Amount:    row.Amount,
+		Timestamp: midnight,
+		Present:   true,
+	}
+}
+
+// prometheusSnapshotAmountsEqual is true if a and b are the same value,
+// which unlike ==, is true for two NaNs.
+func prometheusSnapshotAmountsEqual(a, b float64) bool {
+	return a == b || (math.IsNaN(a) && math.IsNaN(b))
+}
+
+// promVectorToPrometheusSnapshotRows returns the series of vector by their
+// key after relabeling them, with the timestamp ts, or an error if
+// relabeling gives distinct series the same labels.
+func promVectorToPrometheusSnapshotRows(ts time.Time, vector model.Vector, relabelConfigs []*relabel.Config) (map[string]*PrometheusSnapshotRow, error) {
+	rows := make(map[string]*PrometheusSnapshotRow, len(vector))
+	seriesSet := relabel.NewSeriesSet()
+	for _, sample := range vector {
+		original := make(map[string]string, len(sample.Metric))
+		for k, v := range sample.Metric {
+			original[string(k)] = string(v)
+		}
+		labels := relabel.Process(original, relabelConfigs)
+		if labels == nil {
+			continue
+		}
+		if err := seriesSet.Add(original, labels); err != nil {
+			return nil, err
+		}
+		series := PrometheusSeriesKey(labels)
+		rows[series] = &PrometheusSnapshotRow{
+			Series:    series,
+			Labels:    labels,
+			Amount:    float64(sample.Value),
+			Timestamp: ts,
+			Present:   true,
+		}
+	}
+	return rows, nil
+}
+
+type PrometheusSnapshotConfig struct {
+	PrometheusQuery string
+	PrestoTableName string
+	// RelabelConfigs are applied to the labels of each series before it's
+	// compared to the previous snapshot, dropping series which are dropped
+	// by them.
+	RelabelConfigs []*relabel.Config
+	// Scheduler, if set, limits the Prometheus queries run by snapshots,
+	// SchedulerKey identifying the snapshotter when waiting for it.
+	Scheduler    *ImportScheduler
+	SchedulerKey ImportSchedulerKey
+}
+
+type PrometheusSnapshotResults struct {
+	// Time is when the snapshot was taken.
+	Time time.Time
+	// Series is the number of series in the snapshot.
+	Series int
+	// StoredRows is the number of rows stored for the series which were
+	// added, changed or removed.
+	StoredRows int
+}
+
+// PrometheusSnapshotter stores the changes to the series returned by an
+// instant query each time it's run.
+type PrometheusSnapshotter struct {
+	logger            logrus.FieldLogger
+	promConn          prom.API
+	repo              PrometheusSnapshotRepo
+	clock             clock.Clock
+	cfg               PrometheusSnapshotConfig
+	metricsCollectors ImporterMetricsCollectors
+
+	// snapshotLock ensures only one snapshot is running at a time,
+	// protecting the last field
+	snapshotLock sync.Mutex
+
+	// last is the last snapshot stored, or nil if it isn't known and must
+	// be read from the table.
+	last *PrometheusSnapshot
+	// rebuildSince is the time of the last snapshot stored before a store
+	// failed, from whose partition last is read from the table again, since
+	// the failed store may have stored some of its rows.
+	rebuildSince time.Time
+}
+
+func NewPrometheusSnapshotter(logger logrus.FieldLogger, promConn prom.API, repo PrometheusSnapshotRepo, clock clock.Clock, cfg PrometheusSnapshotConfig, collectors ImporterMetricsCollectors) *PrometheusSnapshotter {
+	logger = logger.WithFields(logrus.Fields{
+		"component": "PrometheusSnapshotter",
+		"tableName": cfg.PrestoTableName,
+	})
+	return &PrometheusSnapshotter{
+		logger:            logger,
+		promConn:          promConn,
+		repo:              repo,
+		clock:             clock,
+		cfg:               cfg,
+		metricsCollectors: collectors,
+	}
+}
+
+func (s *PrometheusSnapshotter) UpdateConfig(cfg PrometheusSnapshotConfig) {
+	s.snapshotLock.Lock()
+	// the last snapshot is the state of the table, so it only needs to be
+	// read again if the table changed
+	if cfg.PrestoTableName != s.cfg.PrestoTableName {
+		s.last = nil
+		s.rebuildSince = time.Time{}
+	}
+	s.cfg = cfg
+	s.logger = s.logger.WithField("tableName", cfg.PrestoTableName)
+	s.snapshotLock.Unlock()
+}
+
+// UpdatePrometheusConn replaces the prom.API the snapshotter queries.
+func (s *PrometheusSnapshotter) UpdatePrometheusConn(promConn prom.API) {
+	s.snapshotLock.Lock()
+	s.promConn = promConn
+	s.snapshotLock.Unlock()
+}
+
+// Snapshot runs the PrometheusQuery as an instant query at the current time,
+// and stores a row for each series which was added, changed or removed since
+// the last snapshot. The first snapshot of each day also stores every series
+// of the last snapshot at midnight, so the series of a day can be read
+// without the partitions of earlier days.
+func (s *PrometheusSnapshotter) Snapshot(ctx context.Context) (*PrometheusSnapshotResults, error) {
+	s.snapshotLock.Lock()
+	s.logger.Debugf("PrometheusSnapshotter Snapshot started")
+	defer s.logger.Debugf("PrometheusSnapshotter Snapshot finished")
+	defer s.snapshotLock.Unlock()
+
+	cfg := s.cfg
+	collectors := s.metricsCollectors
+
+	// if the last snapshot isn't known, we haven't run before, were
+	// restarted or failed to store the last snapshot, so the newest row of
+	// each series is read from the table
+	if s.last == nil {
+		s.logger.Debugf("last snapshot for table %s isn't known, querying for it", cfg.PrestoTableName)
+		last, err := s.repo.GetLatestPrometheusSnapshot(cfg.PrestoTableName, s.rebuildSince)
+		if err != nil {
+			return nil, err
+		}
+		s.last = last
+		s.rebuildSince = time.Time{}
+	}
+
+	collectors.ImportsRunningGauge.Inc()
+	snapshotStart := s.clock.Now()
+	collectors.TotalImportsCounter.Inc()
+	defer func() {
+		collectors.ImportsRunningGauge.Dec()
+		snapshotDuration := s.clock.Since(snapshotStart)
+		collectors.ImportDurationHistogram.Observe(snapshotDuration.Seconds())
+		s.logger.Debugf("took %s to run snapshot", snapshotDuration)
+	}()
+
+	ts := snapshotStart.UTC().Truncate(time.Second)
+	cur, err := s.query(ctx, cfg, ts)
+	if err != nil {
+		collectors.FailedImportsCounter.Inc()
+		collectors.FailedPrometheusQueriesCounter.Inc()
+		return nil, fmt.Errorf("failed to perform Prometheus query: %v", err)
+	}
+	collectors.MetricsScrapedCounter.Add(float64(len(cur)))
+
+	rows := DiffPrometheusSnapshot(s.last.Series, cur, ts)
+	if len(rows) != 0 {
+		prestoStoreBegin := s.clock.Now()
+		collectors.TotalPrestoStoresCounter.Inc()
+		err := s.repo.StorePrometheusSnapshotRows(ctx, cfg.PrestoTableName, rows)
+		collectors.PrestoStoreDurationHistogram.Observe(s.clock.Since(prestoStoreBegin).Seconds())
+		if err != nil {
+			collectors.FailedImportsCounter.Inc()
+			collectors.FailedPrestoStoresCounter.Inc()
+			// at this point we cannot be sure which rows are in Presto,
+			// so the last snapshot is read from the table again, from
+			// the partition of the last snapshot which was stored. The
+			// series missing from the partition of the next snapshot are
+			// then carried over to it again.
+			s.rebuildSince = s.last.Time
+			s.last = nil
+			return nil, fmt.Errorf("failed to store the snapshot at %s into table %s: %v", ts, cfg.PrestoTableName, err)
+		}
+		collectors.MetricsImportedCounter.Add(float64(len(rows)))
+	}
+	s.last = &PrometheusSnapshot{Time: ts, Series: cur}
+
+	s.logger.Infof("stored %d of the %d series of the snapshot at %s into %s", len(rows), len(cur), ts, cfg.PrestoTableName)
+	return &PrometheusSnapshotResults{
+		Time:       ts,
+		Series:     len(cur),
+		StoredRows: len(rows),
+	}, nil
+}
+
+// query waits for cfg.Scheduler to allow a query, and then runs the
+// PrometheusQuery at ts.
+func (s *PrometheusSnapshotter) query(ctx context.Context, cfg PrometheusSnapshotConfig, ts time.Time) (map[string]*PrometheusSnapshotRow, error) {
+	collectors := s.metricsCollectors
+	queuedAt := s.clock.Now()
+	collectors.QueuedPrometheusQueriesGauge.Inc()
+	release, err := cfg.Scheduler.Acquire(ctx, cfg.SchedulerKey)
+	collectors.QueuedPrometheusQueriesGauge.Dec()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	collectors.PrometheusQueryQueueDurationHistogram.Observe(s.clock.Since(queuedAt).Seconds())
+
+	collectors.InFlightPrometheusQueriesGauge.Inc()
+	defer collectors.InFlightPrometheusQueriesGauge.Dec()
+
+	s.logger.Debugf("querying Prometheus at %s, the Prometheus query is: %s", ts, cfg.PrometheusQuery)
+	queryStart := s.clock.Now()
+	pVal, err := s.promConn.Query(ctx, cfg.PrometheusQuery, ts)
+	collectors.PrometheusQueryDurationHistogram.Observe(s.clock.Since(queryStart).Seconds())
+	collectors.TotalPrometheusQueriesCounter.Inc()
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := pVal.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected a vector in response to query, got a %v", pVal.Type())
+	}
+	return promVectorToPrometheusSnapshotRows(ts, vector, cfg.RelabelConfigs)
+}
@@ -0,0 +1,302 @@
+package prestostore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestPrometheusSeriesKey(t *testing.T) {
+	assert.Equal(t, `{}`, PrometheusSeriesKey(nil))
+	assert.Equal(t,
+		`{label_zone="us-east-1a \"b\"",node="worker-1"}`,
+		PrometheusSeriesKey(map[string]string{"node": "worker-1", "label_zone": `us-east-1a "b"`}),
+	)
+	// a label value containing the separators can't produce another key
+	assert.NotEqual(t,
+		PrometheusSeriesKey(map[string]string{"a": `1",b="2`}),
+		PrometheusSeriesKey(map[string]string{"a": "1", "b": "2"}),
+	)
+}
+
+func newTestPrometheusSnapshotRow(ts time.Time, amount float64, labels map[string]string) *PrometheusSnapshotRow {
+	return &PrometheusSnapshotRow{
+		Series:    PrometheusSeriesKey(labels),
+		Labels:    labels,
+		Amount:    amount,
+		Timestamp: ts,
+		Present:   true,
+	}
+}
+
+func TestDiffPrometheusSnapshot(t *testing.T) {
+	t1 := time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(5 * time.Minute)
+	rows := func(rows ...*PrometheusSnapshotRow) map[string]*PrometheusSnapshotRow {
+		m := make(map[string]*PrometheusSnapshotRow)
+		for _, row := range rows {
+			m[row.Series] = row
+		}
+		return m
+	}
+	a1 := newTestPrometheusSnapshotRow(t1, 1, map[string]string{"node": "a"})
+	b1 := newTestPrometheusSnapshotRow(t1, 1, map[string]string{"node": "b"})
+	c1 := newTestPrometheusSnapshotRow(t1, math.NaN(), map[string]string{"node": "c"})
+	a2 := newTestPrometheusSnapshotRow(t2, 1, map[string]string{"node": "a"})
+	b2 := newTestPrometheusSnapshotRow(t2, 2, map[string]string{"node": "b"})
+	c2 := newTestPrometheusSnapshotRow(t2, math.NaN(), map[string]string{"node": "c"})
+	d2 := newTestPrometheusSnapshotRow(t2, 1, map[string]string{"node": "d"})
+
+	t.Run("first snapshot", func(t *testing.T) {
+		assert.Equal(t, []*PrometheusSnapshotRow{a1, b1}, DiffPrometheusSnapshot(nil, rows(b1, a1), t1))
+	})
+	t.Run("unchanged", func(t *testing.T) {
+		assert.Empty(t, DiffPrometheusSnapshot(rows(a1, b1, c1), rows(a2, b1, c2), t2))
+	})
+	t.Run("added, changed and removed", func(t *testing.T) {
+		removedA := &PrometheusSnapshotRow{Series: a1.Series, Labels: a1.Labels, Amount: 1, Timestamp: t2, Present: false}
+		assert.Equal(t,
+			[]*PrometheusSnapshotRow{removedA, b2, d2},
+			DiffPrometheusSnapshot(rows(a1, b1, c1), rows(b2, c2, d2), t2),
+		)
+	})
+	t.Run("new day", func(t *testing.T) {
+		midnight := time.Date(2024, time.March, 2, 0, 0, 0, 0, time.UTC)
+		t3 := midnight.Add(5 * time.Minute)
+		a3 := newTestPrometheusSnapshotRow(t3, 1, map[string]string{"node": "a"})
+		b3 := newTestPrometheusSnapshotRow(t3, 3, map[string]string{"node": "b"})
+		d3 := newTestPrometheusSnapshotRow(t3, 1, map[string]string{"node": "d"})
+		carriedA := newTestPrometheusSnapshotRow(midnight, 1, a2.Labels)
+		carriedB := newTestPrometheusSnapshotRow(midnight, 2, b2.Labels)
+		// NaNs aren't equal when comparing the rows
+		c2 := newTestPrometheusSnapshotRow(t2, 5, c2.Labels)
+		carriedC := newTestPrometheusSnapshotRow(midnight, 5, c2.Labels)
+		removedC := &PrometheusSnapshotRow{Series: c2.Series, Labels: c2.Labels, Amount: 5, Timestamp: t3, Present: false}
+		// every series of the previous day is carried over to midnight,
+		// and the series already in the new day aren't
+		assert.Equal(t,
+			[]*PrometheusSnapshotRow{carriedA, carriedB, b3, carriedC, removedC},
+			DiffPrometheusSnapshot(rows(a2, b2, c2, d3), rows(a3, b3, d3), t3),
+		)
+		// a snapshot at midnight only carries over the unchanged series
+		removedC.Timestamp = midnight
+		assert.Equal(t,
+			[]*PrometheusSnapshotRow{carriedA, carriedB, removedC},
+			DiffPrometheusSnapshot(rows(a2, b2, c2), rows(a2, carriedB), midnight),
+		)
+	})
+}
+
+func TestGeneratePrometheusSnapshotSQLValues(t *testing.T) {
+	row := newTestPrometheusSnapshotRow(time.Date(2024, time.March, 1, 23, 45, 0, 0, time.UTC), 3, map[string]string{"node": "worker-1"})
+	assert.Equal(t,
+		`(3E+00,timestamp '2024-03-01 23:45:00.000',map(ARRAY['node'],ARRAY['worker-1']),'{node="worker-1"}',true,'2024-03-01')`,
+		generatePrometheusSnapshotSQLValues(row),
+	)
+	row.Present = false
+	row.Amount = math.NaN()
+	assert.Equal(t,
+		`(nan(),timestamp '2024-03-01 23:45:00.000',map(ARRAY['node'],ARRAY['worker-1']),'{node="worker-1"}',false,'2024-03-01')`,
+		generatePrometheusSnapshotSQLValues(row),
+	)
+}
+
+// fakeInstantPrometheusAPI returns vector from instant queries.
+type fakeInstantPrometheusAPI struct {
+	prom.API
+	vector model.Vector
+}
+
+func (api *fakeInstantPrometheusAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	return api.vector, nil
+}
+
+type fakePrometheusSnapshotRepo struct {
+	stored []*PrometheusSnapshotRow
+	gets   int
+	// failAfter, if positive, makes the next store fail after storing
+	// failAfter-1 rows.
+	failAfter int
+}
+
+func (r *fakePrometheusSnapshotRepo) StorePrometheusSnapshotRows(ctx context.Context, tableName string, rows []*PrometheusSnapshotRow) error {
+	if r.failAfter > 0 {
+		r.stored = append(r.stored, rows[:r.failAfter-1]...)
+		r.failAfter = 0
+		return errors.New("insert failed")
+	}
+	r.stored = append(r.stored, rows...)
+	return nil
+}
+
+func (r *fakePrometheusSnapshotRepo) GetLatestPrometheusSnapshot(tableName string, since time.Time) (*PrometheusSnapshot, error) {
+	r.gets++
+	snapshot := &PrometheusSnapshot{Series: make(map[string]*PrometheusSnapshotRow)}
+	if len(r.stored) == 0 {
+		return snapshot, nil
+	}
+	fromPartition := ""
+	for _, row := range r.stored {
+		if partition := PrometheusMetricTimestampPartition(row.Timestamp); partition > fromPartition {
+			fromPartition = partition
+		}
+	}
+	if !since.IsZero() && PrometheusMetricTimestampPartition(since) < fromPartition {
+		fromPartition = PrometheusMetricTimestampPartition(since)
+	}
+	for _, row := range r.stored {
+		if PrometheusMetricTimestampPartition(row.Timestamp) < fromPartition {
+			continue
+		}
+		if row.Timestamp.After(snapshot.Time) {
+			snapshot.Time = row.Timestamp
+		}
+		if row.Present {
+			snapshot.Series[row.Series] = row
+		} else {
+			delete(snapshot.Series, row.Series)
+		}
+	}
+	return snapshot, nil
+}
+
+func TestPrometheusSnapshotter(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 23, 50, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	promConn := &fakeInstantPrometheusAPI{}
+	repo := &fakePrometheusSnapshotRepo{}
+	cfg := PrometheusSnapshotConfig{
+		PrometheusQuery: "kube_node_labels",
+		PrestoTableName: "test",
+		Scheduler:       NewImportScheduler(1, 0),
+	}
+	snapshotter := NewPrometheusSnapshotter(logger, promConn, repo, fakeClock, cfg, newTestImporterMetricsCollectors())
+
+	sample := func(node string, value float64) *model.Sample {
+		return &model.Sample{Metric: model.Metric{"node": model.LabelValue(node)}, Value: model.SampleValue(value)}
+	}
+	snapshot := func(expectedSeries, expectedStored int) {
+		t.Helper()
+		results, err := snapshotter.Snapshot(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, fakeClock.Now(), results.Time)
+		assert.Equal(t, expectedSeries, results.Series)
+		assert.Equal(t, expectedStored, results.StoredRows)
+	}
+
+	promConn.vector = model.Vector{sample("a", 1), sample("b", 1)}
+	snapshot(2, 2)
+	assert.Equal(t, 1, repo.gets, "expected the last snapshot to be read from the table")
+
+	fakeClock.Step(time.Minute)
+	snapshot(2, 0)
+
+	fakeClock.Step(time.Minute)
+	promConn.vector = model.Vector{sample("a", 2)}
+	snapshot(1, 2)
+	assert.Equal(t, 1, repo.gets)
+
+	// a restarted snapshotter continues from the rows in the table
+	snapshotter = NewPrometheusSnapshotter(logger, promConn, repo, fakeClock, cfg, newTestImporterMetricsCollectors())
+	fakeClock.Step(time.Minute)
+	snapshot(1, 0)
+	assert.Equal(t, 2, repo.gets)
+
+	// the first snapshot of a day carries every series over to midnight
+	fakeClock.Step(10 * time.Minute)
+	snapshot(1, 1)
+
+	var stored []string
+	for _, row := range repo.stored {
+		value := "removed"
+		if row.Present {
+			value = strconv.FormatFloat(row.Amount, 'f', -1, 64)
+		}
+		stored = append(stored, row.Timestamp.Format("15:04")+" "+row.Series+" "+value)
+	}
+	assert.Equal(t, []string{
+		`23:50 {node="a"} 1`,
+		`23:50 {node="b"} 1`,
+		`23:52 {node="a"} 2`,
+		`23:52 {node="b"} removed`,
+		`00:00 {node="a"} 2`,
+	}, stored)
+	assert.Equal(t, 0, cfg.Scheduler.Running())
+}
+
+func TestPrometheusSnapshotterStoreFailure(t *testing.T) {
+	start := time.Date(2024, time.March, 1, 23, 50, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(start)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	promConn := &fakeInstantPrometheusAPI{}
+	repo := &fakePrometheusSnapshotRepo{}
+	cfg := PrometheusSnapshotConfig{
+		PrometheusQuery: "kube_node_labels",
+		PrestoTableName: "test",
+		Scheduler:       NewImportScheduler(1, 0),
+	}
+	snapshotter := NewPrometheusSnapshotter(logger, promConn, repo, fakeClock, cfg, newTestImporterMetricsCollectors())
+	sample := func(node string, value float64) *model.Sample {
+		return &model.Sample{Metric: model.Metric{"node": model.LabelValue(node)}, Value: model.SampleValue(value)}
+	}
+
+	promConn.vector = model.Vector{sample("a", 1), sample("b", 1), sample("c", 1)}
+	_, err := snapshotter.Snapshot(context.Background())
+	require.NoError(t, err)
+
+	// the first snapshot of the next day only stores the carried over
+	// row of a before failing
+	fakeClock.Step(15 * time.Minute)
+	promConn.vector = model.Vector{sample("a", 1), sample("b", 2)}
+	repo.failAfter = 2
+	_, err = snapshotter.Snapshot(context.Background())
+	require.Error(t, err)
+
+	// the last snapshot is read again from the partition of the last
+	// snapshot stored, and the series missing from the new day are carried
+	// over to it
+	fakeClock.Step(15 * time.Minute)
+	results, err := snapshotter.Snapshot(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, repo.gets)
+	assert.Equal(t, 4, results.StoredRows)
+
+	var stored []string
+	for _, row := range repo.stored {
+		value := "removed"
+		if row.Present {
+			value = strconv.FormatFloat(row.Amount, 'f', -1, 64)
+		}
+		stored = append(stored, row.Timestamp.Format("15:04")+" "+row.Series+" "+value)
+	}
+	assert.Equal(t, []string{
+		`23:50 {node="a"} 1`,
+		`23:50 {node="b"} 1`,
+		`23:50 {node="c"} 1`,
+		`00:00 {node="a"} 1`,
+		`00:00 {node="b"} 1`,
+		`00:20 {node="b"} 2`,
+		`00:00 {node="c"} 1`,
+		`00:20 {node="c"} removed`,
+	}, stored)
+
+	// the newest partition has every series, so a restart only reads it
+	latest, err := repo.GetLatestPrometheusSnapshot("test", time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, latest.Series, 2)
+	assert.Equal(t, 2.0, latest.Series[`{node="b"}`].Amount)
+}
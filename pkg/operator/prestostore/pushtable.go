@@ -0,0 +1,91 @@
+package prestostore
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const (
+	PushTableIdempotencyKeyColumnName = "idempotency_key"
+	PushTableIngestionTimeColumnName  = "ingestion_time"
+)
+
+var (
+	// PushTableHiveSystemColumns follow the declared columns of every
+	// PushTable table.
+	PushTableHiveSystemColumns = []hive.Column{
+		{Name: PushTableIdempotencyKeyColumnName, Type: "string"},
+		{Name: PushTableIngestionTimeColumnName, Type: "timestamp"},
+	}
+	// PushTableHivePartitionColumns partition the rows by the day they
+	// were pushed, the same as Prometheus metrics.
+	PushTableHivePartitionColumns = PrometheusMetricHivePartitionColumns
+)
+
+// PushTableHiveColumns returns the columns of a PushTable table with the
+// declared columns.
+func PushTableHiveColumns(columns []hive.Column) []hive.Column {
+	hiveColumns := make([]hive.Column, 0, len(columns)+len(PushTableHiveSystemColumns))
+	hiveColumns = append(hiveColumns, columns...)
+	hiveColumns = append(hiveColumns, PushTableHiveSystemColumns...)
+	return hiveColumns
+}
+
+// PushTableRow is a row pushed to a PushTable ReportDataSource.
+type PushTableRow struct {
+	IdempotencyKey string
+	IngestionTime  time.Time
+	// Values are the SQL literals of the declared columns, in the order
+	// they are declared.
+	Values []string
+}
+
+type PushTableStorer interface {
+	// StorePushTableRows inserts rows into tableName.
+	StorePushTableRows(ctx context.Context, tableName string, rows []*PushTableRow) error
+}
+
+type pushTableRepo struct {
+	queryer         db.Queryer
+	queryBufferPool *sync.Pool
+}
+
+func NewPushTableRepo(queryer db.Queryer, queryBufferPool *sync.Pool) *pushTableRepo {
+	if queryBufferPool == nil {
+		queryBufferPool = &defaultQueryBufferPool
+	}
+	return &pushTableRepo{
+		queryer:         queryer,
+		queryBufferPool: queryBufferPool,
+	}
+}
+
+func (r *pushTableRepo) StorePushTableRows(ctx context.Context, tableName string, rows []*PushTableRow) error {
+	queryBuf := r.queryBufferPool.Get().(*bytes.Buffer)
+	queryBuf.Reset()
+	defer r.queryBufferPool.Put(queryBuf)
+	return insertValuesWithBuffer(queryBuf, ctx, r.queryer, tableName, "pushed rows", len(rows), func(i int) string {
+		return generatePushTableSQLValues(rows[i])
+	})
+}
+
+// generatePushTableSQLValues turns a PushTableRow into a SQL literal suited
+// for INSERT statements, with the columns in the order of
+// PushTableHiveColumns followed by the dt partition column.
+func generatePushTableSQLValues(row *PushTableRow) string {
+	values := make([]string, 0, len(row.Values)+3)
+	values = append(values, row.Values...)
+	values = append(values,
+		presto.QuoteString(row.IdempotencyKey),
+		presto.FormatTimestamp(row.IngestionTime),
+		presto.QuoteString(PrometheusMetricTimestampPartition(row.IngestionTime)),
+	)
+	return "(" + strings.Join(values, ",") + ")"
+}
@@ -0,0 +1,20 @@
+package prestostore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePushTableSQLValues(t *testing.T) {
+	row := &PushTableRow{
+		IdempotencyKey: "build-42:1",
+		IngestionTime:  time.Date(2024, time.March, 1, 23, 45, 0, 0, time.UTC),
+		Values:         []string{"'ci-runner-1'", "CAST(120 AS bigint)", "NULL"},
+	}
+	assert.Equal(t,
+		`('ci-runner-1',CAST(120 AS bigint),NULL,'build-42:1',timestamp '2024-03-01 23:45:00.000','2024-03-01')`,
+		generatePushTableSQLValues(row),
+	)
+}
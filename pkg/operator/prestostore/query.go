@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync"
 	"time"
 
 	prom "github.com/prometheus/client_golang/api/prometheus/v1"
@@ -61,15 +62,19 @@ func ImportFromTimeRange(logger logrus.FieldLogger, clock clock.Clock, promConn
 	})
 	logger.Debugf("querying for data between %s and %s (chunks: %d)", startTime, endTime, len(timeRanges))
 
-	for _, timeRange := range timeRanges {
-		// check for cancellation
-		select {
-		case <-ctx.Done():
-			return importResults, ctx.Err()
-		default:
-			// continue processing if context isn't cancelled.
-		}
+	// Chunks are fetched from Prometheus concurrently, but stored in order,
+	// so the processed time ranges are always contiguous, and an import
+	// resuming from the last processed time range doesn't skip chunks
+	// fetched after one which failed.
+	fetchCtx, cancelFetches := context.WithCancel(ctx)
+	fetches := startTimeRangeFetches(fetchCtx, logger, clock, promConn, metricsCollectors, cfg, timeRanges)
+	// stop any fetches still running if the import stops early
+	defer func() {
+		cancelFetches()
+		fetches.wg.Wait()
+	}()
 
+	for i, timeRange := range timeRanges {
 		promQueryBegin := timeRange.Start.UTC()
 		promQueryEnd := timeRange.End.UTC()
 		promLogger := logger.WithFields(logrus.Fields{
@@ -77,26 +82,22 @@ func ImportFromTimeRange(logger logrus.FieldLogger, clock clock.Clock, promConn
 			"promQueryEnd":   promQueryEnd,
 		})
 
-		promLogger.Debugf("querying Prometheus using range %s to %s", timeRange.Start, timeRange.End)
-		promLogger.Debugf("the Prometheus query is: %s", cfg.PrometheusQuery)
-
-		queryStart := clock.Now()
-		pVal, err := promConn.QueryRange(ctx, cfg.PrometheusQuery, timeRange)
-		queryDuration := clock.Since(queryStart)
-		metricsCollectors.PrometheusQueryDurationHistogram.Observe(float64(queryDuration.Seconds()))
-		metricsCollectors.TotalPrometheusQueriesCounter.Inc()
-		if err != nil {
+		var fetch timeRangeFetch
+		select {
+		case <-ctx.Done():
+			return importResults, ctx.Err()
+		case fetch = <-fetches.results[i]:
+		}
+		if fetch.err != nil {
+			if ctx.Err() != nil {
+				return importResults, ctx.Err()
+			}
 			metricsCollectors.FailedImportsCounter.Inc()
 			metricsCollectors.FailedPrometheusQueriesCounter.Inc()
-			return importResults, fmt.Errorf("failed to perform Prometheus query: %v", err)
+			return importResults, fmt.Errorf("failed to perform Prometheus query: %v", fetch.err)
 		}
+		metrics := fetch.metrics
 
-		matrix, ok := pVal.(model.Matrix)
-		if !ok {
-			return importResults, fmt.Errorf("expected a matrix in response to query, got a %v", pVal.Type())
-		}
-
-		metrics := promMatrixToPrometheusMetrics(timeRange, matrix)
 		numMetrics := len(metrics)
 		metricsCollectors.MetricsScrapedCounter.Add(float64(numMetrics))
 
@@ -139,6 +140,8 @@ func ImportFromTimeRange(logger logrus.FieldLogger, clock clock.Clock, promConn
 		}
 
 		importResults.ProcessedTimeRanges = append(importResults.ProcessedTimeRanges, timeRange)
+		// let the next chunk be fetched now this one is stored
+		fetches.done()
 	}
 
 	if len(importResults.ProcessedTimeRanges) != 0 {
@@ -152,6 +155,116 @@ func ImportFromTimeRange(logger logrus.FieldLogger, clock clock.Clock, promConn
 	}
 }
 
+type timeRangeFetch struct {
+	metrics []*PrometheusMetric
+	err     error
+}
+
+type timeRangeFetches struct {
+	// results receives the result of fetching each time range
+	results []chan timeRangeFetch
+	// window limits the time ranges fetched but not yet stored
+	window chan struct{}
+	wg     sync.WaitGroup
+}
+
+// done must be called once the result of a time range has been stored,
+// allowing another time range to be fetched.
+func (f *timeRangeFetches) done() {
+	<-f.window
+}
+
+// startTimeRangeFetches fetches timeRanges in the background, fetching up to
+// cfg.ChunkConcurrency time ranges which haven't been stored yet at once.
+// Each query waits for cfg.Scheduler to allow it to run.
+func startTimeRangeFetches(ctx context.Context, logger logrus.FieldLogger, clock clock.Clock, promConn prom.API, metricsCollectors ImporterMetricsCollectors, cfg Config, timeRanges []prom.Range) *timeRangeFetches {
+	concurrency := cfg.ChunkConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	fetches := &timeRangeFetches{
+		results: make([]chan timeRangeFetch, len(timeRanges)),
+		window:  make(chan struct{}, concurrency),
+	}
+	for i := range fetches.results {
+		// buffered so fetches never block if the import stops early
+		fetches.results[i] = make(chan timeRangeFetch, 1)
+	}
+
+	fetches.wg.Add(1)
+	go func() {
+		defer fetches.wg.Done()
+		for i, timeRange := range timeRanges {
+			select {
+			case fetches.window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			fetches.wg.Add(1)
+			go func(result chan<- timeRangeFetch, timeRange prom.Range) {
+				defer fetches.wg.Done()
+				promLogger := logger.WithFields(logrus.Fields{
+					"promQueryBegin": timeRange.Start.UTC(),
+					"promQueryEnd":   timeRange.End.UTC(),
+				})
+				metrics, err := scheduleFetchTimeRange(ctx, promLogger, clock, promConn, metricsCollectors, cfg, timeRange)
+				result <- timeRangeFetch{metrics: metrics, err: err}
+			}(fetches.results[i], timeRange)
+		}
+	}()
+	return fetches
+}
+
+// scheduleFetchTimeRange waits for cfg.Scheduler to allow a query, and then
+// fetches the metrics of timeRange.
+func scheduleFetchTimeRange(ctx context.Context, logger logrus.FieldLogger, clock clock.Clock, promConn prom.API, metricsCollectors ImporterMetricsCollectors, cfg Config, timeRange prom.Range) ([]*PrometheusMetric, error) {
+	queuedAt := clock.Now()
+	metricsCollectors.QueuedPrometheusQueriesGauge.Inc()
+	release, err := cfg.Scheduler.Acquire(ctx, cfg.SchedulerKey)
+	metricsCollectors.QueuedPrometheusQueriesGauge.Dec()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	metricsCollectors.PrometheusQueryQueueDurationHistogram.Observe(clock.Since(queuedAt).Seconds())
+
+	metricsCollectors.InFlightPrometheusQueriesGauge.Inc()
+	defer metricsCollectors.InFlightPrometheusQueriesGauge.Dec()
+
+	logger.Debugf("querying Prometheus using range %s to %s", timeRange.Start, timeRange.End)
+	if cfg.RemoteRead != nil {
+		logger.Debugf("reading samples using Prometheus remote_read with matchers: %v", cfg.RemoteRead.Matchers)
+	} else {
+		logger.Debugf("the Prometheus query is: %s", cfg.PrometheusQuery)
+	}
+
+	queryStart := clock.Now()
+	metrics, err := fetchTimeRange(ctx, promConn, cfg, timeRange)
+	queryDuration := clock.Since(queryStart)
+	metricsCollectors.PrometheusQueryDurationHistogram.Observe(float64(queryDuration.Seconds()))
+	metricsCollectors.TotalPrometheusQueriesCounter.Inc()
+	return metrics, err
+}
+
+// fetchTimeRange returns the metrics for timeRange, either by running the
+// PrometheusQuery using query_range, or reading the raw samples using
+// remote_read if cfg.RemoteRead is set.
+func fetchTimeRange(ctx context.Context, promConn prom.API, cfg Config, timeRange prom.Range) ([]*PrometheusMetric, error) {
+	if cfg.RemoteRead != nil {
+		return remoteReadTimeRange(ctx, *cfg.RemoteRead, timeRange, cfg.RelabelConfigs)
+	}
+
+	pVal, err := promConn.QueryRange(ctx, cfg.PrometheusQuery, timeRange)
+	if err != nil {
+		return nil, err
+	}
+	matrix, ok := pVal.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected a matrix in response to query, got a %v", pVal.Type())
+	}
+	return promMatrixToPrometheusMetrics(timeRange, matrix, cfg.RelabelConfigs)
+}
+
 func getTimeRangesChunked(beginTime, endTime time.Time, chunkSize, stepSize time.Duration, maxTimeRanges int64) []prom.Range {
 	chunkStart := truncateToSecond(beginTime)
 	chunkEnd := truncateToSecond(chunkStart.Add(chunkSize))
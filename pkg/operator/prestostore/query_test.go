@@ -1,11 +1,22 @@
 package prestostore
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"sync"
 	"testing"
 	"time"
 
 	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
 )
 
 func TestGetTimeRanges(t *testing.T) {
@@ -82,3 +93,158 @@ func TestGetTimeRanges(t *testing.T) {
 	}
 
 }
+
+// fakeChunkPrometheusAPI returns a sample at the start of each query_range
+// query, taking longer for earlier chunks so they complete out of order, and
+// failing the query starting at failAt.
+type fakeChunkPrometheusAPI struct {
+	prom.API
+	start  time.Time
+	failAt time.Time
+
+	mu      sync.Mutex
+	running int
+	maxRun  int
+}
+
+func (api *fakeChunkPrometheusAPI) QueryRange(ctx context.Context, query string, r prom.Range) (model.Value, error) {
+	api.mu.Lock()
+	api.running++
+	if api.running > api.maxRun {
+		api.maxRun = api.running
+	}
+	api.mu.Unlock()
+	defer func() {
+		api.mu.Lock()
+		api.running--
+		api.mu.Unlock()
+	}()
+
+	time.Sleep(time.Duration(20-r.Start.Sub(api.start)/time.Hour) * time.Millisecond)
+	if r.Start.Equal(api.failAt) {
+		return nil, errors.New("query failed")
+	}
+	return model.Matrix{{
+		Metric: model.Metric{"pod": "foo"},
+		Values: []model.SamplePair{{Timestamp: model.TimeFromUnixNano(r.Start.UnixNano()), Value: 1}},
+	}}, nil
+}
+
+type fakePrometheusMetricsStorer struct {
+	stored []time.Time
+}
+
+func (s *fakePrometheusMetricsStorer) StorePrometheusMetrics(ctx context.Context, tableName string, metrics []*PrometheusMetric) error {
+	for _, metric := range metrics {
+		s.stored = append(s.stored, metric.Timestamp)
+	}
+	return nil
+}
+
+func newTestImporterMetricsCollectors() ImporterMetricsCollectors {
+	counter := func() prometheus.Counter { return prometheus.NewCounter(prometheus.CounterOpts{Name: "test"}) }
+	gauge := func() prometheus.Gauge { return prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}) }
+	histogram := func() prometheus.Observer { return prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test"}) }
+	return ImporterMetricsCollectors{
+		TotalImportsCounter:                   counter(),
+		FailedImportsCounter:                  counter(),
+		ImportDurationHistogram:               histogram(),
+		TotalPrometheusQueriesCounter:         counter(),
+		FailedPrometheusQueriesCounter:        counter(),
+		PrometheusQueryDurationHistogram:      histogram(),
+		TotalPrestoStoresCounter:              counter(),
+		FailedPrestoStoresCounter:             counter(),
+		PrestoStoreDurationHistogram:          histogram(),
+		MetricsScrapedCounter:                 counter(),
+		MetricsImportedCounter:                counter(),
+		ImportsRunningGauge:                   gauge(),
+		QueuedPrometheusQueriesGauge:          gauge(),
+		InFlightPrometheusQueriesGauge:        gauge(),
+		PrometheusQueryQueueDurationHistogram: histogram(),
+	}
+}
+
+func TestPromMatrixToPrometheusMetrics(t *testing.T) {
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	timeRange := prom.Range{Start: start, End: start.Add(time.Minute), Step: time.Minute}
+	matrix := model.Matrix{
+		{
+			Metric: model.Metric{"namespace": "default", "pod": "foo"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnixNano(start.UnixNano()), Value: 1}},
+		},
+		{
+			Metric: model.Metric{"namespace": "default", "pod": "bar"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnixNano(start.UnixNano()), Value: 2}},
+		},
+	}
+
+	podRegex, err := relabel.NewRegexp("pod")
+	require.NoError(t, err)
+	dropPod := []*relabel.Config{{Action: relabel.LabelDrop, Regex: podRegex}}
+	namespaceRegex, err := relabel.NewRegexp("namespace")
+	require.NoError(t, err)
+	dropNamespace := []*relabel.Config{{Action: relabel.LabelDrop, Regex: namespaceRegex}}
+
+	metrics, err := promMatrixToPrometheusMetrics(timeRange, matrix, dropNamespace)
+	require.NoError(t, err)
+	assert.Equal(t, []*PrometheusMetric{
+		{Labels: map[string]string{"pod": "foo"}, Amount: 1, StepSize: time.Minute, Timestamp: start},
+		{Labels: map[string]string{"pod": "bar"}, Amount: 2, StepSize: time.Minute, Timestamp: start},
+	}, metrics)
+
+	// without the pod label the series can't be told apart
+	_, err = promMatrixToPrometheusMetrics(timeRange, matrix, dropPod)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicate series after relabeling")
+}
+
+func TestImportFromTimeRangeChunkConcurrency(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return janOne.Add(time.Duration(h) * time.Hour) }
+	cfg := Config{
+		PrometheusQuery:  "up",
+		PrestoTableName:  "test",
+		ChunkSize:        time.Hour - time.Minute,
+		StepSize:         time.Minute,
+		ChunkConcurrency: 4,
+		Scheduler:        NewImportScheduler(3, 0),
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	tests := map[string]struct {
+		failAt         time.Time
+		expectedStored []time.Time
+	}{
+		"all chunks succeed": {
+			expectedStored: []time.Time{hour(0), hour(1), hour(2), hour(3), hour(4), hour(5), hour(6), hour(7)},
+		},
+		// chunks after the failed chunk may have been fetched, but aren't
+		// stored, so the processed time ranges stay contiguous
+		"a chunk fails": {
+			failAt:         hour(3),
+			expectedStored: []time.Time{hour(0), hour(1), hour(2)},
+		},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			promConn := &fakeChunkPrometheusAPI{start: janOne, failAt: test.failAt}
+			storer := &fakePrometheusMetricsStorer{}
+			results, err := ImportFromTimeRange(logger, clock.RealClock{}, promConn, storer, newTestImporterMetricsCollectors(), context.Background(), janOne, hour(8), cfg)
+			if test.failAt.IsZero() {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+			assert.Equal(t, test.expectedStored, storer.stored)
+			require.Len(t, results.ProcessedTimeRanges, len(test.expectedStored))
+			for i, timeRange := range results.ProcessedTimeRanges {
+				assert.Equal(t, test.expectedStored[i], timeRange.Start)
+			}
+			assert.True(t, promConn.maxRun > 1, "expected chunks to be fetched concurrently")
+			assert.True(t, promConn.maxRun <= 3, "expected the scheduler to limit concurrent queries, got %d", promConn.maxRun)
+			assert.Equal(t, 0, cfg.Scheduler.Running())
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package prestostore
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+// RemoteReadLookbackDelta is how far back from each step a resampled series
+// uses samples from, the same as the default lookback delta of Prometheus.
+// It's also the longest time a raw sample is considered to cover.
+const RemoteReadLookbackDelta = 5 * time.Minute
+
+// RemoteReader reads raw samples using the Prometheus remote_read API.
+type RemoteReader interface {
+	Read(ctx context.Context, query remote.Query) ([]remote.TimeSeries, error)
+}
+
+// RemoteReadConfig configures importing the raw samples of the series
+// selected by Matchers using the Prometheus remote_read API.
+type RemoteReadConfig struct {
+	Reader   RemoteReader
+	Matchers []remote.LabelMatcher
+	// Resample, if true, resamples each series to the StepSize of the
+	// import, producing the same metrics as a query_range query of a
+	// selector would. Otherwise every raw sample is imported.
+	Resample bool
+}
+
+// remoteReadTimeRange reads the samples of the series selected by cfg for
+// timeRange, applying relabelConfigs to the labels of each series.
+//
+// Raw samples with timestamps from the start of timeRange until the start of
+// the next time range (timeRange.End plus timeRange.Step) are returned, so
+// consecutive time ranges don't skip or duplicate samples. The StepSize of
+// each raw sample is the time until the next sample of the series, so
+// multiplying amounts by their StepSize works the same as for resampled
+// metrics.
+func remoteReadTimeRange(ctx context.Context, cfg RemoteReadConfig, timeRange prom.Range, relabelConfigs []*relabel.Config) ([]*PrometheusMetric, error) {
+	query := remote.Query{
+		StartTimestampMs: timestampMs(timeRange.Start),
+		EndTimestampMs:   timestampMs(timeRange.End.Add(timeRange.Step)) - 1,
+		Matchers:         cfg.Matchers,
+	}
+	if cfg.Resample {
+		query.StartTimestampMs = timestampMs(timeRange.Start.Add(-RemoteReadLookbackDelta))
+		query.EndTimestampMs = timestampMs(timeRange.End)
+	}
+
+	series, err := cfg.Reader.Read(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []*PrometheusMetric
+	seriesSet := relabel.NewSeriesSet()
+	for _, ts := range series {
+		labels := relabel.Process(ts.Labels, relabelConfigs)
+		if labels == nil {
+			continue
+		}
+		if err := seriesSet.Add(ts.Labels, labels); err != nil {
+			return nil, err
+		}
+		samples := ts.Samples
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i].Timestamp < samples[j].Timestamp
+		})
+		if cfg.Resample {
+			metrics = append(metrics, resampleSeries(labels, samples, timeRange)...)
+		} else {
+			metrics = append(metrics, rawSeries(labels, samples, timeRange.Step)...)
+		}
+	}
+	return metrics, nil
+}
+
+// resampleSeries returns the value of the series at each step of timeRange,
+// using the newest sample within RemoteReadLookbackDelta of the step.
+func resampleSeries(labels map[string]string, samples []remote.Sample, timeRange prom.Range) []*PrometheusMetric {
+	lookbackMs := int64(RemoteReadLookbackDelta / time.Millisecond)
+	var metrics []*PrometheusMetric
+	i := 0
+	for t := timeRange.Start; !t.After(timeRange.End); t = t.Add(timeRange.Step) {
+		stepMs := timestampMs(t)
+		for i < len(samples) && samples[i].Timestamp <= stepMs {
+			i++
+		}
+		// samples[i-1] is the newest sample at or before the step
+		if i == 0 {
+			continue
+		}
+		sample := samples[i-1]
+		// NaN is used by Prometheus to mark series as stale
+		if sample.Timestamp <= stepMs-lookbackMs || math.IsNaN(sample.Value) {
+			continue
+		}
+		metrics = append(metrics, &PrometheusMetric{
+			Labels:    labels,
+			Amount:    sample.Value,
+			StepSize:  timeRange.Step,
+			Timestamp: t.UTC(),
+		})
+	}
+	return metrics
+}
+
+// rawSeries returns a metric for each sample, with the StepSize of each
+// being the time until the next sample of the series, limited to
+// RemoteReadLookbackDelta. The last sample uses the interval of the
+// previous samples, or defaultStepSize if it's the only sample.
+func rawSeries(labels map[string]string, samples []remote.Sample, defaultStepSize time.Duration) []*PrometheusMetric {
+	var metrics []*PrometheusMetric
+	stepSize := defaultStepSize
+	for i, sample := range samples {
+		if i+1 < len(samples) {
+			stepSize = time.Duration(samples[i+1].Timestamp-sample.Timestamp) * time.Millisecond
+			if stepSize > RemoteReadLookbackDelta {
+				stepSize = RemoteReadLookbackDelta
+			}
+		}
+		// NaN is used by Prometheus to mark series as stale, and the
+		// staleness marker ends the interval of the previous sample.
+		if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+			continue
+		}
+		metrics = append(metrics, &PrometheusMetric{
+			Labels:    labels,
+			Amount:    sample.Value,
+			StepSize:  stepSize,
+			Timestamp: time.Unix(0, sample.Timestamp*int64(time.Millisecond)).UTC(),
+		})
+	}
+	return metrics
+}
+
+func timestampMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
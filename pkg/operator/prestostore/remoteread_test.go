@@ -0,0 +1,129 @@
+package prestostore
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+type fakeRemoteReader struct {
+	series []remote.TimeSeries
+	query  remote.Query
+}
+
+func (r *fakeRemoteReader) Read(ctx context.Context, query remote.Query) ([]remote.TimeSeries, error) {
+	r.query = query
+	return r.series, nil
+}
+
+func TestRemoteReadTimeRange(t *testing.T) {
+	janOne := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) int64 {
+		return timestampMs(janOne.Add(d))
+	}
+	labels := map[string]string{"__name__": "kube_pod_container_resource_requests", "pod": "foo"}
+	timeRange := prom.Range{
+		Start: janOne,
+		End:   janOne.Add(4 * time.Minute),
+		Step:  time.Minute,
+	}
+	series := []remote.TimeSeries{
+		{
+			Labels: labels,
+			// samples are scraped every 30 seconds, out of order, and the
+			// series goes stale after 2m30s
+			Samples: []remote.Sample{
+				{Value: 2, Timestamp: ms(30 * time.Second)},
+				{Value: 1, Timestamp: ms(0)},
+				{Value: 3, Timestamp: ms(time.Minute)},
+				{Value: 4, Timestamp: ms(90 * time.Second)},
+				{Value: 5, Timestamp: ms(2 * time.Minute)},
+				{Value: math.NaN(), Timestamp: ms(150 * time.Second)},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		resample        bool
+		expectedQuery   remote.Query
+		expectedMetrics []*PrometheusMetric
+	}{
+		"raw": {
+			expectedQuery: remote.Query{
+				StartTimestampMs: ms(0),
+				EndTimestampMs:   ms(5*time.Minute) - 1,
+			},
+			expectedMetrics: []*PrometheusMetric{
+				{Labels: labels, Amount: 1, StepSize: 30 * time.Second, Timestamp: janOne},
+				{Labels: labels, Amount: 2, StepSize: 30 * time.Second, Timestamp: janOne.Add(30 * time.Second)},
+				{Labels: labels, Amount: 3, StepSize: 30 * time.Second, Timestamp: janOne.Add(time.Minute)},
+				{Labels: labels, Amount: 4, StepSize: 30 * time.Second, Timestamp: janOne.Add(90 * time.Second)},
+				{Labels: labels, Amount: 5, StepSize: 30 * time.Second, Timestamp: janOne.Add(2 * time.Minute)},
+			},
+		},
+		"resampled": {
+			resample: true,
+			expectedQuery: remote.Query{
+				StartTimestampMs: ms(-RemoteReadLookbackDelta),
+				EndTimestampMs:   ms(4 * time.Minute),
+			},
+			expectedMetrics: []*PrometheusMetric{
+				{Labels: labels, Amount: 1, StepSize: time.Minute, Timestamp: janOne},
+				{Labels: labels, Amount: 3, StepSize: time.Minute, Timestamp: janOne.Add(time.Minute)},
+				{Labels: labels, Amount: 5, StepSize: time.Minute, Timestamp: janOne.Add(2 * time.Minute)},
+				// the series is stale from 2m30s on
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			matchers := []remote.LabelMatcher{{Type: remote.MatchEqual, Name: "__name__", Value: "kube_pod_container_resource_requests"}}
+			reader := &fakeRemoteReader{series: copySeries(series)}
+			metrics, err := remoteReadTimeRange(context.Background(), RemoteReadConfig{
+				Reader:   reader,
+				Matchers: matchers,
+				Resample: test.resample,
+			}, timeRange, nil)
+			require.NoError(t, err)
+
+			test.expectedQuery.Matchers = matchers
+			assert.Equal(t, test.expectedQuery, reader.query)
+			assert.Equal(t, test.expectedMetrics, metrics)
+		})
+	}
+}
+
+func TestRemoteReadResampleLookback(t *testing.T) {
+	janOne := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	labels := map[string]string{"__name__": "up"}
+	// a single sample is used for the steps within the lookback delta
+	samples := []remote.Sample{{Value: 1, Timestamp: timestampMs(janOne.Add(-time.Minute))}}
+	metrics := resampleSeries(labels, samples, prom.Range{
+		Start: janOne,
+		End:   janOne.Add(10 * time.Minute),
+		Step:  2 * time.Minute,
+	})
+	require.Len(t, metrics, 2)
+	assert.Equal(t, janOne, metrics[0].Timestamp)
+	assert.Equal(t, janOne.Add(2*time.Minute), metrics[1].Timestamp)
+}
+
+func copySeries(series []remote.TimeSeries) []remote.TimeSeries {
+	copied := make([]remote.TimeSeries, len(series))
+	for i, ts := range series {
+		copied[i] = remote.TimeSeries{
+			Labels:  ts.Labels,
+			Samples: append([]remote.Sample(nil), ts.Samples...),
+		}
+	}
+	return copied
+}
@@ -0,0 +1,200 @@
+package prestostore
+
+import (
+	"context"
+	"sync"
+)
+
+// ImportSchedulerKey identifies who an import is for when waiting for the
+// ImportScheduler. Queue is typically the ReportDataSource, and Prometheus
+// identifies the Prometheus being queried so it can be limited separately.
+type ImportSchedulerKey struct {
+	Queue      string
+	Prometheus string
+}
+
+// ImportScheduler bounds the number of Prometheus queries run at once by
+// imports, both in total and for each Prometheus. Waiting queries are
+// granted slots round-robin between queues, so an import with many chunks to
+// query, such as a backfill, doesn't starve the other queues.
+//
+// A nil *ImportScheduler doesn't limit queries.
+type ImportScheduler struct {
+	maxConcurrent              int
+	maxConcurrentPerPrometheus int
+
+	mu                   sync.Mutex
+	running              int
+	runningPerPrometheus map[string]int
+	// queues holds the waiters of each queue, and order is the round-robin
+	// order of the queues with waiters, next being the index of the queue
+	// to be considered first.
+	queues map[string][]*importWaiter
+	order  []string
+	next   int
+}
+
+type importWaiter struct {
+	prometheus string
+	ready      chan struct{}
+	granted    bool
+}
+
+// NewImportScheduler returns an ImportScheduler running at most
+// maxConcurrent queries, and at most maxConcurrentPerPrometheus queries
+// against the same Prometheus. Limits less than or equal to zero are
+// unlimited.
+func NewImportScheduler(maxConcurrent, maxConcurrentPerPrometheus int) *ImportScheduler {
+	return &ImportScheduler{
+		maxConcurrent:              maxConcurrent,
+		maxConcurrentPerPrometheus: maxConcurrentPerPrometheus,
+		runningPerPrometheus:       make(map[string]int),
+		queues:                     make(map[string][]*importWaiter),
+	}
+}
+
+// Acquire waits until a query for key can be run, returning a function
+// which must be called once the query has finished. If ctx is cancelled
+// first, ctx.Err() is returned.
+func (s *ImportScheduler) Acquire(ctx context.Context, key ImportSchedulerKey) (release func(), err error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	waiter := &importWaiter{
+		prometheus: key.Prometheus,
+		ready:      make(chan struct{}),
+	}
+	s.mu.Lock()
+	if _, exists := s.queues[key.Queue]; !exists {
+		s.order = append(s.order, key.Queue)
+	}
+	s.queues[key.Queue] = append(s.queues[key.Queue], waiter)
+	s.dispatch()
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return s.releaseFunc(key.Prometheus), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if waiter.granted {
+			// granted while being cancelled, so give the slot back
+			s.release(key.Prometheus)
+		} else {
+			s.removeWaiter(key.Queue, waiter)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Running returns the number of queries currently running.
+func (s *ImportScheduler) Running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Waiting returns the number of queries waiting to be run.
+func (s *ImportScheduler) Waiting() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	waiting := 0
+	for _, waiters := range s.queues {
+		waiting += len(waiters)
+	}
+	return waiting
+}
+
+func (s *ImportScheduler) releaseFunc(prometheus string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.release(prometheus)
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *ImportScheduler) release(prometheus string) {
+	s.running--
+	s.runningPerPrometheus[prometheus]--
+	if s.runningPerPrometheus[prometheus] <= 0 {
+		delete(s.runningPerPrometheus, prometheus)
+	}
+	s.dispatch()
+}
+
+// dispatch grants slots to waiters while there are slots available, taking
+// the first waiter of each queue in turn, skipping queues whose first waiter
+// is for a Prometheus which is already running its maximum.
+func (s *ImportScheduler) dispatch() {
+	for len(s.order) != 0 && (s.maxConcurrent <= 0 || s.running < s.maxConcurrent) {
+		granted := false
+		for i := 0; i < len(s.order); i++ {
+			idx := (s.next + i) % len(s.order)
+			queue := s.order[idx]
+			waiter := s.queues[queue][0]
+			if s.maxConcurrentPerPrometheus > 0 && s.runningPerPrometheus[waiter.prometheus] >= s.maxConcurrentPerPrometheus {
+				continue
+			}
+
+			s.running++
+			s.runningPerPrometheus[waiter.prometheus]++
+			waiter.granted = true
+			close(waiter.ready)
+
+			s.queues[queue] = s.queues[queue][1:]
+			if len(s.queues[queue]) == 0 {
+				s.removeQueue(idx)
+				s.next = idx
+			} else {
+				s.next = idx + 1
+			}
+			if len(s.order) != 0 {
+				s.next %= len(s.order)
+			} else {
+				s.next = 0
+			}
+			granted = true
+			break
+		}
+		if !granted {
+			return
+		}
+	}
+}
+
+func (s *ImportScheduler) removeWaiter(queue string, waiter *importWaiter) {
+	waiters := s.queues[queue]
+	for i, w := range waiters {
+		if w == waiter {
+			s.queues[queue] = append(waiters[:i:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(s.queues[queue]) != 0 {
+		return
+	}
+	for idx, q := range s.order {
+		if q == queue {
+			s.removeQueue(idx)
+			if idx < s.next {
+				s.next--
+			}
+			if len(s.order) == 0 || s.next >= len(s.order) {
+				s.next = 0
+			}
+			return
+		}
+	}
+}
+
+// removeQueue removes the queue at idx of the round-robin order, which must
+// have no waiters.
+func (s *ImportScheduler) removeQueue(idx int) {
+	delete(s.queues, s.order[idx])
+	s.order = append(s.order[:idx:idx], s.order[idx+1:]...)
+}
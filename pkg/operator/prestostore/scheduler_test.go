@@ -0,0 +1,127 @@
+package prestostore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// acquireAsync starts acquiring key, returning a channel receiving the
+// release function once granted.
+func acquireAsync(ctx context.Context, s *ImportScheduler, key ImportSchedulerKey) <-chan func() {
+	granted := make(chan func(), 1)
+	go func() {
+		release, err := s.Acquire(ctx, key)
+		if err == nil {
+			granted <- release
+		}
+	}()
+	return granted
+}
+
+func waitForWaiting(t *testing.T, s *ImportScheduler, waiting int) {
+	require.Eventually(t, func() bool { return s.Waiting() == waiting }, time.Second, time.Millisecond)
+}
+
+func TestImportSchedulerRoundRobin(t *testing.T) {
+	s := NewImportScheduler(1, 0)
+	ctx := context.Background()
+	key := func(queue string) ImportSchedulerKey {
+		return ImportSchedulerKey{Queue: queue, Prometheus: "prometheus"}
+	}
+
+	release, err := s.Acquire(ctx, key("blocker"))
+	require.NoError(t, err)
+
+	// a backfill queues many queries before another ReportDataSource
+	var grants []<-chan func()
+	var queues []string
+	for _, queue := range []string{"backfill", "backfill", "backfill", "other", "another"} {
+		grants = append(grants, acquireAsync(ctx, s, key(queue)))
+		queues = append(queues, queue)
+		waitForWaiting(t, s, len(grants))
+	}
+
+	// each release grants exactly one waiter, since only 1 query can run
+	var order []string
+	for len(grants) != 0 {
+		release()
+		require.Eventually(t, func() bool {
+			for i, granted := range grants {
+				select {
+				case release = <-granted:
+					order = append(order, queues[i])
+					grants = append(grants[:i:i], grants[i+1:]...)
+					queues = append(queues[:i:i], queues[i+1:]...)
+					return true
+				default:
+				}
+			}
+			return false
+		}, time.Second, time.Millisecond)
+	}
+	release()
+
+	assert.Equal(t, []string{"backfill", "other", "another", "backfill", "backfill"}, order)
+	assert.Equal(t, 0, s.Running())
+	assert.Equal(t, 0, s.Waiting())
+}
+
+func TestImportSchedulerPerPrometheusLimit(t *testing.T) {
+	s := NewImportScheduler(2, 1)
+	ctx := context.Background()
+
+	releaseA, err := s.Acquire(ctx, ImportSchedulerKey{Queue: "a", Prometheus: "prometheus-a"})
+	require.NoError(t, err)
+
+	// the second query against prometheus-a waits, but doesn't block a query
+	// against prometheus-b
+	waitingA := acquireAsync(ctx, s, ImportSchedulerKey{Queue: "b", Prometheus: "prometheus-a"})
+	waitForWaiting(t, s, 1)
+	releaseB, err := s.Acquire(ctx, ImportSchedulerKey{Queue: "c", Prometheus: "prometheus-b"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Running())
+	assert.Equal(t, 1, s.Waiting())
+
+	releaseA()
+	// releasing twice has no effect
+	releaseA()
+	select {
+	case release := <-waitingA:
+		release()
+	case <-time.After(time.Second):
+		t.Fatal("expected the waiting query to be granted after the first was released")
+	}
+	releaseB()
+	assert.Equal(t, 0, s.Running())
+}
+
+func TestImportSchedulerCancel(t *testing.T) {
+	s := NewImportScheduler(1, 0)
+	release, err := s.Acquire(context.Background(), ImportSchedulerKey{Queue: "a"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.Acquire(ctx, ImportSchedulerKey{Queue: "b"})
+		errCh <- err
+	}()
+	waitForWaiting(t, s, 1)
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh)
+	assert.Equal(t, 0, s.Waiting())
+
+	release()
+	assert.Equal(t, 0, s.Running())
+}
+
+func TestNilImportScheduler(t *testing.T) {
+	var s *ImportScheduler
+	release, err := s.Acquire(context.Background(), ImportSchedulerKey{})
+	require.NoError(t, err)
+	release()
+}
@@ -0,0 +1,602 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	promapi "github.com/prometheus/client_golang/api"
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/transport"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+// prometheusEndpoint is one of the Prometheus or Thanos Query endpoints of a
+// ReportDataSource, along with its health as of its last request.
+type prometheusEndpoint struct {
+	name   string
+	url    string
+	api    prom.API
+	reader prestostore.RemoteReader
+
+	healthy            bool
+	lastError          string
+	lastTransitionTime *metav1.Time
+}
+
+// prometheusEndpointSet queries the endpoints of a ReportDataSource as a
+// single prom.API and prestostore.RemoteReader.
+//
+// Without replicaLabels, requests fail over between endpoints: healthy
+// endpoints are tried before unhealthy ones, in the order they're configured,
+// and the first successful response is used. With replicaLabels, every
+// endpoint is queried, and the series of all endpoints that succeed are
+// merged after removing the replica labels, preferring the samples of
+// endpoints configured earlier.
+type prometheusEndpointSet struct {
+	clock         clock.Clock
+	replicaLabels []string
+	// config is the configuration the set was created from, and is used to
+	// detect when the set must be recreated.
+	config metering.PrometheusConnectionConfig
+
+	mu        sync.Mutex
+	endpoints []*prometheusEndpoint
+}
+
+func newPrometheusEndpointSet(clock clock.Clock, config metering.PrometheusConnectionConfig, endpoints []*prometheusEndpoint) *prometheusEndpointSet {
+	for _, endpoint := range endpoints {
+		endpoint.healthy = true
+	}
+	return &prometheusEndpointSet{
+		clock:         clock,
+		replicaLabels: config.ReplicaLabels,
+		config:        config,
+		endpoints:     endpoints,
+	}
+}
+
+// statuses returns the health of each endpoint for the status of the
+// ReportDataSource.
+func (s *prometheusEndpointSet) statuses() []metering.PrometheusEndpointStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]metering.PrometheusEndpointStatus, len(s.endpoints))
+	for i, endpoint := range s.endpoints {
+		statuses[i] = metering.PrometheusEndpointStatus{
+			Name:               endpoint.name,
+			URL:                endpoint.url,
+			Healthy:            endpoint.healthy,
+			LastError:          endpoint.lastError,
+			LastTransitionTime: endpoint.lastTransitionTime.DeepCopy(),
+		}
+	}
+	return statuses
+}
+
+func (s *prometheusEndpointSet) record(endpoint *prometheusEndpoint, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	healthy := err == nil
+	if healthy != endpoint.healthy || endpoint.lastTransitionTime == nil {
+		// truncated since the status only stores seconds
+		now := metav1.NewTime(s.clock.Now().UTC().Truncate(time.Second))
+		endpoint.lastTransitionTime = &now
+	}
+	endpoint.healthy = healthy
+	if err != nil {
+		endpoint.lastError = err.Error()
+	} else {
+		endpoint.lastError = ""
+	}
+}
+
+// failoverOrder returns the healthy endpoints followed by the unhealthy
+// endpoints, each in the order they're configured.
+func (s *prometheusEndpointSet) failoverOrder() []*prometheusEndpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var healthy, unhealthy []*prometheusEndpoint
+	for _, endpoint := range s.endpoints {
+		if endpoint.healthy {
+			healthy = append(healthy, endpoint)
+		} else {
+			unhealthy = append(unhealthy, endpoint)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// failover calls fn with each endpoint in failoverOrder until it succeeds.
+func (s *prometheusEndpointSet) failover(fn func(endpoint *prometheusEndpoint) error) error {
+	var errs []string
+	for _, endpoint := range s.failoverOrder() {
+		err := fn(endpoint)
+		s.record(endpoint, err)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", endpoint.name, err))
+	}
+	return fmt.Errorf("all Prometheus endpoints failed: %s", strings.Join(errs, "; "))
+}
+
+// all calls fn with the index of each endpoint concurrently, returning an
+// error only if every call fails. Callers use the index to store the result
+// of each endpoint, so results can be merged in the configured order.
+func (s *prometheusEndpointSet) all(fn func(i int, endpoint *prometheusEndpoint) error) ([]bool, error) {
+	succeeded := make([]bool, len(s.endpoints))
+	errs := make([]error, len(s.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range s.endpoints {
+		i, endpoint := i, endpoint
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = fn(i, endpoint)
+			s.record(endpoint, errs[i])
+			succeeded[i] = errs[i] == nil
+		}()
+	}
+	wg.Wait()
+
+	var errMsgs []string
+	for i, err := range errs {
+		if err == nil {
+			return succeeded, nil
+		}
+		errMsgs = append(errMsgs, fmt.Sprintf("%s: %v", s.endpoints[i].name, err))
+	}
+	return nil, fmt.Errorf("all Prometheus endpoints failed: %s", strings.Join(errMsgs, "; "))
+}
+
+func (s *prometheusEndpointSet) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	if len(s.replicaLabels) == 0 {
+		var value model.Value
+		err := s.failover(func(endpoint *prometheusEndpoint) (err error) {
+			value, err = endpoint.api.Query(ctx, query, ts)
+			return err
+		})
+		return value, err
+	}
+
+	values := make([]model.Value, len(s.endpoints))
+	succeeded, err := s.all(func(i int, endpoint *prometheusEndpoint) (err error) {
+		values[i], err = endpoint.api.Query(ctx, query, ts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var vectors []model.Vector
+	for i, value := range values {
+		if !succeeded[i] {
+			continue
+		}
+		vector, ok := value.(model.Vector)
+		if !ok {
+			// scalars and strings have no labels to deduplicate
+			return value, nil
+		}
+		vectors = append(vectors, vector)
+	}
+	return dedupVectors(vectors, s.replicaLabels), nil
+}
+
+func (s *prometheusEndpointSet) QueryRange(ctx context.Context, query string, r prom.Range) (model.Value, error) {
+	if len(s.replicaLabels) == 0 {
+		var value model.Value
+		err := s.failover(func(endpoint *prometheusEndpoint) (err error) {
+			value, err = endpoint.api.QueryRange(ctx, query, r)
+			return err
+		})
+		return value, err
+	}
+
+	values := make([]model.Value, len(s.endpoints))
+	succeeded, err := s.all(func(i int, endpoint *prometheusEndpoint) (err error) {
+		values[i], err = endpoint.api.QueryRange(ctx, query, r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var matrices []model.Matrix
+	for i, value := range values {
+		if !succeeded[i] {
+			continue
+		}
+		matrix, ok := value.(model.Matrix)
+		if !ok {
+			return nil, fmt.Errorf("expected a matrix in response to query, got a %v", value.Type())
+		}
+		matrices = append(matrices, matrix)
+	}
+	return dedupMatrices(matrices, s.replicaLabels, r.Step), nil
+}
+
+func (s *prometheusEndpointSet) LabelValues(ctx context.Context, label string) (model.LabelValues, error) {
+	if len(s.replicaLabels) == 0 {
+		var values model.LabelValues
+		err := s.failover(func(endpoint *prometheusEndpoint) (err error) {
+			values, err = endpoint.api.LabelValues(ctx, label)
+			return err
+		})
+		return values, err
+	}
+
+	results := make([]model.LabelValues, len(s.endpoints))
+	_, err := s.all(func(i int, endpoint *prometheusEndpoint) (err error) {
+		results[i], err = endpoint.api.LabelValues(ctx, label)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[model.LabelValue]struct{})
+	var values model.LabelValues
+	for _, result := range results {
+		for _, value := range result {
+			if _, exists := seen[value]; !exists {
+				seen[value] = struct{}{}
+				values = append(values, value)
+			}
+		}
+	}
+	sort.Sort(values)
+	return values, nil
+}
+
+func (s *prometheusEndpointSet) Read(ctx context.Context, query remote.Query) ([]remote.TimeSeries, error) {
+	if len(s.replicaLabels) == 0 {
+		var series []remote.TimeSeries
+		err := s.failover(func(endpoint *prometheusEndpoint) (err error) {
+			series, err = endpoint.reader.Read(ctx, query)
+			return err
+		})
+		return series, err
+	}
+
+	results := make([][]remote.TimeSeries, len(s.endpoints))
+	succeeded, err := s.all(func(i int, endpoint *prometheusEndpoint) (err error) {
+		results[i], err = endpoint.reader.Read(ctx, query)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var matrices []model.Matrix
+	for i, series := range results {
+		if succeeded[i] {
+			matrices = append(matrices, remoteSeriesToMatrix(series))
+		}
+	}
+	// raw samples have no step, so each series is deduplicated using the
+	// interval between its samples
+	return matrixToRemoteSeries(dedupMatrices(matrices, s.replicaLabels, 0)), nil
+}
+
+// withoutLabels returns a copy of metric without the labels in names.
+func withoutLabels(metric model.Metric, names []string) model.Metric {
+	metric = metric.Clone()
+	for _, name := range names {
+		delete(metric, model.LabelName(name))
+	}
+	return metric
+}
+
+// dedupMatrices merges the series of matrices which are equal after removing
+// replicaLabels. Replicas scrape the same targets at different times, so the
+// samples of a series are only used where the series of earlier matrices
+// have no sample within step of them, filling the gaps in the earlier
+// series without adding a second sample for each step. If step is 0, the
+// smallest interval between the samples of a series is used.
+func dedupMatrices(matrices []model.Matrix, replicaLabels []string, step time.Duration) model.Matrix {
+	var merged model.Matrix
+	byFingerprint := make(map[model.Fingerprint]*model.SampleStream)
+	for _, matrix := range matrices {
+		for _, stream := range matrix {
+			metric := withoutLabels(stream.Metric, replicaLabels)
+			fingerprint := metric.Fingerprint()
+			existing, exists := byFingerprint[fingerprint]
+			if !exists {
+				existing = &model.SampleStream{Metric: metric}
+				byFingerprint[fingerprint] = existing
+				merged = append(merged, existing)
+			}
+			existing.Values = mergeSamplePairs(existing.Values, stream.Values, step)
+		}
+	}
+	return merged
+}
+
+// mergeSamplePairs returns the samples of a and the samples of b without a
+// sample of a within step of them, sorted by timestamp. If step is 0, the
+// smallest interval between the samples of a, or of b if a has less than
+// two samples, is used.
+func mergeSamplePairs(a, b []model.SamplePair, step time.Duration) []model.SamplePair {
+	sortSamplePairs(a)
+	sortSamplePairs(b)
+	if step <= 0 {
+		step = minSampleInterval(a)
+		if step <= 0 {
+			step = minSampleInterval(b)
+		}
+	}
+	// samples are only equal to samples with the same timestamp when the
+	// interval is unknown
+	window := model.Time(step / time.Millisecond)
+	if window < 1 {
+		window = 1
+	}
+
+	merged := a
+	for _, sample := range b {
+		// i is the first sample of a at or after sample
+		i := sort.Search(len(a), func(i int) bool {
+			return a[i].Timestamp >= sample.Timestamp
+		})
+		if i < len(a) && a[i].Timestamp-sample.Timestamp < window {
+			continue
+		}
+		if i > 0 && sample.Timestamp-a[i-1].Timestamp < window {
+			continue
+		}
+		merged = append(merged, sample)
+	}
+	sortSamplePairs(merged)
+	return merged
+}
+
+func sortSamplePairs(samples []model.SamplePair) {
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Timestamp < samples[j].Timestamp
+	})
+}
+
+// minSampleInterval returns the smallest interval between the sorted samples,
+// or 0 if there are less than two samples.
+func minSampleInterval(samples []model.SamplePair) time.Duration {
+	var interval model.Time
+	for i := 1; i < len(samples); i++ {
+		if d := samples[i].Timestamp - samples[i-1].Timestamp; d > 0 && (interval == 0 || d < interval) {
+			interval = d
+		}
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// dedupVectors merges the samples of vectors which are equal after removing
+// replicaLabels, using the sample from the earliest vector.
+func dedupVectors(vectors []model.Vector, replicaLabels []string) model.Vector {
+	var merged model.Vector
+	seen := make(map[model.Fingerprint]struct{})
+	for _, vector := range vectors {
+		for _, sample := range vector {
+			metric := withoutLabels(sample.Metric, replicaLabels)
+			fingerprint := metric.Fingerprint()
+			if _, exists := seen[fingerprint]; exists {
+				continue
+			}
+			seen[fingerprint] = struct{}{}
+			merged = append(merged, &model.Sample{
+				Metric:    metric,
+				Value:     sample.Value,
+				Timestamp: sample.Timestamp,
+			})
+		}
+	}
+	return merged
+}
+
+func remoteSeriesToMatrix(series []remote.TimeSeries) model.Matrix {
+	matrix := make(model.Matrix, len(series))
+	for i, ts := range series {
+		metric := make(model.Metric, len(ts.Labels))
+		for name, value := range ts.Labels {
+			metric[model.LabelName(name)] = model.LabelValue(value)
+		}
+		values := make([]model.SamplePair, len(ts.Samples))
+		for j, sample := range ts.Samples {
+			values[j] = model.SamplePair{
+				Timestamp: model.Time(sample.Timestamp),
+				Value:     model.SampleValue(sample.Value),
+			}
+		}
+		matrix[i] = &model.SampleStream{Metric: metric, Values: values}
+	}
+	return matrix
+}
+
+func matrixToRemoteSeries(matrix model.Matrix) []remote.TimeSeries {
+	series := make([]remote.TimeSeries, len(matrix))
+	for i, stream := range matrix {
+		labels := make(map[string]string, len(stream.Metric))
+		for name, value := range stream.Metric {
+			labels[string(name)] = string(value)
+		}
+		samples := make([]remote.Sample, len(stream.Values))
+		for j, pair := range stream.Values {
+			samples[j] = remote.Sample{
+				Timestamp: int64(pair.Timestamp),
+				Value:     float64(pair.Value),
+			}
+		}
+		series[i] = remote.TimeSeries{Labels: labels, Samples: samples}
+	}
+	return series
+}
+
+// prometheusEndpointStatusesEqual compares statuses by value, since the
+// lastTransitionTimes of statuses read from the API have a different
+// location than those of an in-memory prometheusEndpointSet.
+func prometheusEndpointStatusesEqual(a, b []metering.PrometheusEndpointStatus) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].URL != b[i].URL || a[i].Healthy != b[i].Healthy || a[i].LastError != b[i].LastError {
+			return false
+		}
+		if (a[i].LastTransitionTime == nil) != (b[i].LastTransitionTime == nil) {
+			return false
+		}
+		if a[i].LastTransitionTime != nil && !a[i].LastTransitionTime.Equal(b[i].LastTransitionTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// getPrometheusConnForReportDataSource returns the prom.API to import
+// metrics for reportDataSource from, which is either the set of its
+// endpoints, a connection to its URL, or the default connection.
+func (op *defaultReportingOperator) getPrometheusConnForReportDataSource(reportDataSource *metering.ReportDataSource) (prom.API, error) {
+	promConfig := reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig
+	switch {
+	case promConfig != nil && len(promConfig.Endpoints) != 0:
+		return op.getPrometheusEndpointSet(reportDataSource)
+	case promConfig != nil && promConfig.URL != "":
+		return op.newPrometheusConnFromURL(promConfig.URL)
+	default:
+		return op.promConn, nil
+	}
+}
+
+// prometheusAddressForReportDataSource returns the address of the Prometheus
+// queried by the imports of reportDataSource, which identifies the Prometheus
+// when limiting the queries run against it. ReportDataSources with multiple
+// endpoints are identified by all of their endpoints.
+func (op *defaultReportingOperator) prometheusAddressForReportDataSource(reportDataSource *metering.ReportDataSource) string {
+	promConfig := reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig
+	switch {
+	case promConfig != nil && len(promConfig.Endpoints) != 0:
+		urls := make([]string, len(promConfig.Endpoints))
+		for i, endpoint := range promConfig.Endpoints {
+			urls[i] = endpoint.URL
+		}
+		sort.Strings(urls)
+		return strings.Join(urls, ",")
+	case promConfig != nil && promConfig.URL != "":
+		return promConfig.URL
+	default:
+		return op.cfg.PrometheusConfig.Address
+	}
+}
+
+// getPrometheusEndpointSet returns the prometheusEndpointSet for the
+// endpoints of reportDataSource. Sets are kept between imports so the health
+// of endpoints is tracked over time, and are recreated when the
+// configuration of the endpoints changes.
+func (op *defaultReportingOperator) getPrometheusEndpointSet(reportDataSource *metering.ReportDataSource) (*prometheusEndpointSet, error) {
+	promConfig := reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig
+	key := reportDataSource.Namespace + "/" + reportDataSource.Name
+
+	op.prometheusEndpointSetsMu.Lock()
+	defer op.prometheusEndpointSetsMu.Unlock()
+	if set, exists := op.prometheusEndpointSets[key]; exists && reflect.DeepEqual(set.config, *promConfig) {
+		return set, nil
+	}
+
+	endpoints := make([]*prometheusEndpoint, len(promConfig.Endpoints))
+	for i, endpointConfig := range promConfig.Endpoints {
+		roundTripper, err := op.newPrometheusEndpointRoundTripper(reportDataSource.Namespace, endpointConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure Prometheus endpoint %s of ReportDataSource %s: %v", endpointConfig.Name, reportDataSource.Name, err)
+		}
+		api, err := op.newPrometheusConn(promapi.Config{
+			Address:      endpointConfig.URL,
+			RoundTripper: roundTripper,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure Prometheus endpoint %s of ReportDataSource %s: %v", endpointConfig.Name, reportDataSource.Name, err)
+		}
+		reader, err := remote.NewClient(endpointConfig.URL, roundTripper)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure Prometheus endpoint %s of ReportDataSource %s: %v", endpointConfig.Name, reportDataSource.Name, err)
+		}
+		endpoints[i] = &prometheusEndpoint{
+			name:   endpointConfig.Name,
+			url:    endpointConfig.URL,
+			api:    api,
+			reader: reader,
+		}
+	}
+
+	set := newPrometheusEndpointSet(op.clock, *promConfig.DeepCopy(), endpoints)
+	op.prometheusEndpointSets[key] = set
+	return set, nil
+}
+
+// forgetPrometheusEndpointSet drops the prometheusEndpointSet of a
+// ReportDataSource which was deleted or changed, so the set is created
+// again, with the credentials it references read again, if it's still used.
+func (op *defaultReportingOperator) forgetPrometheusEndpointSet(namespace, name string) {
+	op.prometheusEndpointSetsMu.Lock()
+	defer op.prometheusEndpointSetsMu.Unlock()
+	delete(op.prometheusEndpointSets, namespace+"/"+name)
+}
+
+// newPrometheusEndpointRoundTripper returns a http.RoundTripper for
+// endpoint. Endpoints without their own credentials or CA use the
+// reporting-operator's Prometheus configuration.
+func (op *defaultReportingOperator) newPrometheusEndpointRoundTripper(namespace string, endpoint metering.PrometheusEndpoint) (http.RoundTripper, error) {
+	if endpoint.BearerToken == nil && endpoint.CertificateAuthority == nil && !endpoint.InsecureSkipVerify {
+		return op.newPrometheusRoundTripper()
+	}
+
+	transportConfig := &transport.Config{}
+	if endpoint.BearerToken != nil {
+		token, err := op.getSecretKey(namespace, endpoint.BearerToken)
+		if err != nil {
+			return nil, err
+		}
+		transportConfig.BearerToken = strings.TrimSpace(string(token))
+	}
+	if endpoint.CertificateAuthority != nil {
+		ca, err := op.getSecretKey(namespace, endpoint.CertificateAuthority)
+		if err != nil {
+			return nil, err
+		}
+		transportConfig.TLS.CAData = ca
+	}
+	transportConfig.TLS.Insecure = endpoint.InsecureSkipVerify
+	return transport.New(transportConfig)
+}
+
+func (op *defaultReportingOperator) getSecretKey(namespace string, selector *v1.SecretKeySelector) ([]byte, error) {
+	secret, err := op.kubeClient.Secrets(namespace).Get(context.TODO(), selector.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get Secret %s: %v", selector.Name, err)
+	}
+	value, exists := secret.Data[selector.Key]
+	if !exists {
+		return nil, fmt.Errorf("Secret %s has no key %s", selector.Name, selector.Key)
+	}
+	return value, nil
+}
+
+// updatePrometheusEndpointStatuses records the health of the endpoints of
+// reportDataSource in its status, if it changed.
+func (op *defaultReportingOperator) updatePrometheusEndpointStatuses(reportDataSource *metering.ReportDataSource, set *prometheusEndpointSet) (*metering.ReportDataSource, error) {
+	statuses := set.statuses()
+	if prometheusEndpointStatusesEqual(reportDataSource.Status.PrometheusEndpoints, statuses) {
+		return reportDataSource, nil
+	}
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(reportDataSource.Namespace)
+	return updateReportDataSource(dsClient, reportDataSource.Name, func(newDS *metering.ReportDataSource) {
+		newDS.Status.PrometheusEndpoints = statuses
+	})
+}
@@ -0,0 +1,220 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+type fakePrometheusEndpointAPI struct {
+	matrix model.Matrix
+	series []remote.TimeSeries
+	err    error
+	calls  int
+}
+
+func (api *fakePrometheusEndpointAPI) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	api.calls++
+	return nil, api.err
+}
+
+func (api *fakePrometheusEndpointAPI) QueryRange(ctx context.Context, query string, r prom.Range) (model.Value, error) {
+	api.calls++
+	if api.err != nil {
+		return nil, api.err
+	}
+	return api.matrix, nil
+}
+
+func (api *fakePrometheusEndpointAPI) LabelValues(ctx context.Context, label string) (model.LabelValues, error) {
+	api.calls++
+	return nil, api.err
+}
+
+func (api *fakePrometheusEndpointAPI) Read(ctx context.Context, query remote.Query) ([]remote.TimeSeries, error) {
+	api.calls++
+	if api.err != nil {
+		return nil, api.err
+	}
+	return api.series, nil
+}
+
+func newTestPrometheusEndpointSet(clock clock.Clock, replicaLabels []string, apis ...*fakePrometheusEndpointAPI) *prometheusEndpointSet {
+	names := []string{"a", "b", "c"}
+	endpoints := make([]*prometheusEndpoint, len(apis))
+	for i, api := range apis {
+		endpoints[i] = &prometheusEndpoint{
+			name:   names[i],
+			url:    "http://" + names[i],
+			api:    api,
+			reader: api,
+		}
+	}
+	return newPrometheusEndpointSet(clock, metering.PrometheusConnectionConfig{ReplicaLabels: replicaLabels}, endpoints)
+}
+
+func TestPrometheusEndpointSetFailover(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFakeClock(now)
+	matrix := model.Matrix{{
+		Metric: model.Metric{"pod": "foo"},
+		Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(now.Unix()), Value: 1}},
+	}}
+	first := &fakePrometheusEndpointAPI{err: errors.New("connection refused")}
+	second := &fakePrometheusEndpointAPI{matrix: matrix}
+	set := newTestPrometheusEndpointSet(fakeClock, nil, first, second)
+
+	value, err := set.QueryRange(context.Background(), "up", prom.Range{})
+	require.NoError(t, err)
+	assert.Equal(t, matrix, value)
+
+	statuses := set.statuses()
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Healthy)
+	assert.Equal(t, "connection refused", statuses[0].LastError)
+	assert.True(t, statuses[0].LastTransitionTime.Time.Equal(now))
+	assert.True(t, statuses[1].Healthy)
+	assert.Empty(t, statuses[1].LastError)
+
+	// the unhealthy endpoint is tried last, so it isn't queried again while
+	// the healthy endpoint succeeds
+	_, err = set.QueryRange(context.Background(), "up", prom.Range{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 2, second.calls)
+
+	// the transition time only changes when the health changes
+	fakeClock.Step(time.Minute)
+	first.err = nil
+	second.err = errors.New("timeout")
+	_, err = set.QueryRange(context.Background(), "up", prom.Range{})
+	require.NoError(t, err)
+	statuses = set.statuses()
+	assert.True(t, statuses[0].Healthy)
+	assert.True(t, statuses[0].LastTransitionTime.Time.Equal(now.Add(time.Minute)))
+	assert.False(t, statuses[1].Healthy)
+
+	first.err = errors.New("timeout")
+	_, err = set.QueryRange(context.Background(), "up", prom.Range{})
+	assert.Error(t, err, "expected an error when every endpoint fails")
+}
+
+func TestPrometheusEndpointSetDedup(t *testing.T) {
+	ts := func(minutes int64) model.Time {
+		return model.TimeFromUnix(1546300800 + minutes*60)
+	}
+	replicaA := &fakePrometheusEndpointAPI{matrix: model.Matrix{
+		{
+			Metric: model.Metric{"pod": "foo", "replica": "a"},
+			Values: []model.SamplePair{{Timestamp: ts(0), Value: 1}, {Timestamp: ts(2), Value: 3}},
+		},
+	}}
+	replicaB := &fakePrometheusEndpointAPI{matrix: model.Matrix{
+		{
+			Metric: model.Metric{"pod": "foo", "replica": "b"},
+			Values: []model.SamplePair{{Timestamp: ts(0), Value: 10}, {Timestamp: ts(1), Value: 2}},
+		},
+		{
+			Metric: model.Metric{"pod": "bar", "replica": "b"},
+			Values: []model.SamplePair{{Timestamp: ts(0), Value: 5}},
+		},
+	}}
+	failing := &fakePrometheusEndpointAPI{err: errors.New("connection refused")}
+	set := newTestPrometheusEndpointSet(clock.NewFakeClock(time.Now()), []string{"replica"}, replicaA, replicaB, failing)
+
+	value, err := set.QueryRange(context.Background(), "up", prom.Range{Step: time.Minute})
+	require.NoError(t, err)
+	// the gap in the samples of replica a is filled by replica b, and the
+	// samples of replica a are used where both have samples
+	assert.Equal(t, model.Matrix{
+		{
+			Metric: model.Metric{"pod": "foo"},
+			Values: []model.SamplePair{{Timestamp: ts(0), Value: 1}, {Timestamp: ts(1), Value: 2}, {Timestamp: ts(2), Value: 3}},
+		},
+		{
+			Metric: model.Metric{"pod": "bar"},
+			Values: []model.SamplePair{{Timestamp: ts(0), Value: 5}},
+		},
+	}, value)
+
+	statuses := set.statuses()
+	assert.True(t, statuses[0].Healthy)
+	assert.True(t, statuses[1].Healthy)
+	assert.False(t, statuses[2].Healthy)
+
+	replicaA.series = []remote.TimeSeries{{
+		Labels:  map[string]string{"pod": "foo", "replica": "a"},
+		Samples: []remote.Sample{{Timestamp: 0, Value: 1}},
+	}}
+	replicaB.series = []remote.TimeSeries{{
+		Labels:  map[string]string{"pod": "foo", "replica": "b"},
+		Samples: []remote.Sample{{Timestamp: 0, Value: 10}, {Timestamp: 30000, Value: 2}},
+	}}
+	series, err := set.Read(context.Background(), remote.Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []remote.TimeSeries{{
+		Labels:  map[string]string{"pod": "foo"},
+		Samples: []remote.Sample{{Timestamp: 0, Value: 1}, {Timestamp: 30000, Value: 2}},
+	}}, series)
+
+	// replicas scrape at different times, so their raw samples are only
+	// used to fill the gaps of earlier replicas longer than their interval
+	replicaA.series = []remote.TimeSeries{{
+		Labels:  map[string]string{"pod": "foo", "replica": "a"},
+		Samples: []remote.Sample{{Timestamp: 0, Value: 1}, {Timestamp: 30000, Value: 2}, {Timestamp: 120000, Value: 5}},
+	}}
+	replicaB.series = []remote.TimeSeries{{
+		Labels: map[string]string{"pod": "foo", "replica": "b"},
+		Samples: []remote.Sample{
+			{Timestamp: 12000, Value: 10}, {Timestamp: 42000, Value: 20}, {Timestamp: 72000, Value: 3},
+			{Timestamp: 102000, Value: 4}, {Timestamp: 132000, Value: 50},
+		},
+	}}
+	series, err = set.Read(context.Background(), remote.Query{})
+	require.NoError(t, err)
+	assert.Equal(t, []remote.TimeSeries{{
+		Labels: map[string]string{"pod": "foo"},
+		Samples: []remote.Sample{
+			{Timestamp: 0, Value: 1}, {Timestamp: 30000, Value: 2}, {Timestamp: 72000, Value: 3}, {Timestamp: 120000, Value: 5},
+		},
+	}}, series)
+
+	// samples of query_range responses which aren't aligned to the same
+	// steps are deduplicated by step
+	replicaA.matrix = model.Matrix{{
+		Metric: model.Metric{"pod": "foo", "replica": "a"},
+		Values: []model.SamplePair{{Timestamp: ts(0), Value: 1}, {Timestamp: ts(1), Value: 2}},
+	}}
+	replicaB.matrix = model.Matrix{{
+		Metric: model.Metric{"pod": "foo", "replica": "b"},
+		Values: []model.SamplePair{{Timestamp: ts(0) + 500, Value: 10}, {Timestamp: ts(1) + 500, Value: 20}, {Timestamp: ts(2) + 500, Value: 3}},
+	}}
+	value, err = set.QueryRange(context.Background(), "up", prom.Range{Step: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, model.Matrix{{
+		Metric: model.Metric{"pod": "foo"},
+		Values: []model.SamplePair{{Timestamp: ts(0), Value: 1}, {Timestamp: ts(1), Value: 2}, {Timestamp: ts(2) + 500, Value: 3}},
+	}}, value)
+}
+
+func TestPrometheusEndpointStatusesEqual(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	status := func(healthy bool, transition time.Time) []metering.PrometheusEndpointStatus {
+		return []metering.PrometheusEndpointStatus{{Name: "a", URL: "http://a", Healthy: healthy, LastTransitionTime: &metav1.Time{Time: transition}}}
+	}
+	assert.True(t, prometheusEndpointStatusesEqual(status(true, now), status(true, now.Local())))
+	assert.False(t, prometheusEndpointStatusesEqual(status(true, now), status(false, now)))
+	assert.False(t, prometheusEndpointStatusesEqual(status(true, now), status(true, now.Add(time.Second))))
+	assert.False(t, prometheusEndpointStatusesEqual(nil, status(true, now)))
+}
@@ -0,0 +1,266 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+// validatePrometheusSnapshotDataSource returns an error if a snapshot mode
+// Prometheus ReportDataSource is configured with options only range mode
+// supports.
+func validatePrometheusSnapshotDataSource(dataSource *metering.ReportDataSource) error {
+	importer := dataSource.Spec.PrometheusMetricsImporter
+	switch {
+	case importer.Query == "":
+		return fmt.Errorf("spec.prometheusMetricsImporter.query must be set in snapshot mode")
+	case importer.RemoteWrite != nil:
+		return fmt.Errorf("spec.prometheusMetricsImporter.remoteWrite can't be used in snapshot mode")
+	case importer.RemoteRead != nil:
+		return fmt.Errorf("spec.prometheusMetricsImporter.remoteRead can't be used in snapshot mode")
+	case len(dataSource.Spec.Backfill) != 0:
+		return fmt.Errorf("spec.backfill can't be used in snapshot mode, instant queries can only be run at the current time")
+	}
+	return nil
+}
+
+// prometheusSnapshotView returns the query of the view of the raw table of
+// a snapshot mode Prometheus ReportDataSource, which has a row for each
+// value of a series in each dt partition, with the interval it's valid.
+// valid_to is the time of the next row of the series in the partition, the
+// midnight ending the partition if it isn't the newest, or null while the
+// value is current. Every partition starts with the series present at its
+// midnight, so the window doesn't span partitions, and filtering the view
+// by dt only reads those partitions.
+func prometheusSnapshotView(rawTableName string) (string, []presto.Column) {
+	query := fmt.Sprintf(
+		`SELECT "labels", "amount", "valid_from", "valid_to", "dt" FROM (SELECT "labels", "amount", "present", "dt", "timestamp" AS "valid_from", coalesce(lead("timestamp") OVER (PARTITION BY "dt", "series" ORDER BY "timestamp"), IF("dt" < (SELECT max("dt") FROM %s), date_add('day', 1, date_parse("dt", '%%Y-%%m-%%d')))) AS "valid_to" FROM %s) WHERE "present"`,
+		prestostore.HivePartitionsTableName(rawTableName),
+		rawTableName,
+	)
+	columns := []presto.Column{
+		{Name: "labels", Type: "map(varchar,varchar)"},
+		{Name: "amount", Type: "double"},
+		{Name: prestostore.PrometheusSnapshotValidFromColumnName, Type: "timestamp"},
+		{Name: prestostore.PrometheusSnapshotValidToColumnName, Type: "timestamp"},
+		{Name: "dt", Type: "varchar"},
+	}
+	return query, columns
+}
+
+// createPrometheusSnapshotTables creates the HiveTable the changes to the
+// series of a snapshot mode Prometheus ReportDataSource are stored in, and
+// a PrestoTable view of it with the interval each value is valid.
+func (op *defaultReportingOperator) createPrometheusSnapshotTables(logger log.FieldLogger, dataSource *metering.ReportDataSource) (*metering.HiveTable, *metering.PrestoTable, error) {
+	hiveStorage, err := op.getHiveStorage(dataSource.Spec.PrometheusMetricsImporter.Storage, dataSource.Namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage incorrectly configured for ReportDataSource %s, err: %v", dataSource.Name, err)
+	}
+	if hiveStorage.Status.Hive.DatabaseName == "" {
+		op.enqueueStorageLocation(hiveStorage)
+		return nil, nil, fmt.Errorf("StorageLocation %s Hive database %s does not exist yet", hiveStorage.Name, hiveStorage.Spec.Hive.DatabaseName)
+	}
+	params := hive.TableParameters{
+		Database:      hiveStorage.Status.Hive.DatabaseName,
+		Name:          reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name) + dataSourceRawTableSuffix,
+		Columns:       prestostore.PrometheusSnapshotHiveTableColumns,
+		PartitionedBy: prestostore.PrometheusSnapshotHivePartitionColumns,
+	}
+	if hiveStorage.Spec.Hive.DefaultTableProperties != nil {
+		params.RowFormat = hiveStorage.Spec.Hive.DefaultTableProperties.RowFormat
+		params.FileFormat = hiveStorage.Spec.Hive.DefaultTableProperties.FileFormat
+	}
+	return op.createDataSourceRawTableAndView(logger, dataSource, params, func(rawTableName string, _ []hive.Column) (string, []presto.Column) {
+		return prometheusSnapshotView(rawTableName)
+	})
+}
+
+// handlePrometheusSnapshotDataSource snapshots the series returned by the
+// query of a snapshot mode Prometheus ReportDataSource every queryInterval,
+// storing the series which were added, changed or removed.
+func (op *defaultReportingOperator) handlePrometheusSnapshotDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	if err := validatePrometheusSnapshotDataSource(dataSource); err != nil {
+		return fmt.Errorf("ReportDataSource %q: improperly configured datasource, %v", dataSource.Name, err)
+	}
+
+	if dataSource.Status.TableRef.Name == "" {
+		logger.Infof("new snapshot mode Prometheus ReportDataSource %s discovered", dataSource.Name)
+		hiveTable, prestoTable, err := op.createPrometheusSnapshotTables(logger, dataSource)
+		if err != nil {
+			return err
+		}
+
+		dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+		dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+			newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+			newDS.Status.PrometheusSnapshot = &metering.PrometheusSnapshotDataSourceStatus{
+				RawTableRef: v1.LocalObjectReference{Name: hiveTable.Name},
+			}
+		})
+		if err != nil {
+			logger.WithError(err).Errorf("failed to update ReportDataSource tableRef to %s", prestoTable.Name)
+			return err
+		}
+
+		if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+			logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+		}
+		if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+			logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+		}
+
+		// like range mode, return early after creating the tables to
+		// allow the tables of other ReportDataSources to be created
+		op.enqueueReportDataSourceAfter(dataSource, wait.Jitter(2*time.Second, 2.5))
+		return nil
+	}
+
+	// the table of a range mode ReportDataSource has every sample, so it
+	// can't be used to store snapshots
+	if dataSource.Status.PrometheusSnapshot == nil {
+		return fmt.Errorf("ReportDataSource %s was created in range mode, the ReportDataSource must be recreated to change its mode", dataSource.Name)
+	}
+	rawTableRef := dataSource.Status.PrometheusSnapshot.RawTableRef
+	rawPrestoTable, err := op.prestoTableLister.PrestoTables(dataSource.Namespace).Get(rawTableRef.Name)
+	if err != nil {
+		return fmt.Errorf("unable to get PrestoTable %s for ReportDataSource %s, %s", rawTableRef.Name, dataSource.Name, err)
+	}
+	rawTableName, err := reportingutil.FullyQualifiedTableName(rawPrestoTable)
+	if err != nil {
+		return err
+	}
+	logger.Infof("existing snapshot mode Prometheus ReportDataSource discovered, tableName: %s", rawTableName)
+
+	// each partition starts with every series present at its midnight, so
+	// dropping the partitions of earlier days keeps the values of the
+	// retained days
+	if updatedDS, err := op.enforceReportDataSourceRetention(logger, dataSource, rawPrestoTable); err != nil {
+		logger.WithError(err).Errorf("error dropping expired partitions of ReportDataSource %s", dataSource.Name)
+	} else {
+		dataSource = updatedDS
+	}
+
+	if op.cfg.DisablePrometheusMetricsImporter {
+		logger.Infof("Periodic Prometheus ReportDataSource importing disabled")
+		return nil
+	}
+
+	snapshotterCfg, err := op.newPromSnapshotterCfg(dataSource, rawTableName)
+	if err != nil {
+		return err
+	}
+	promConn, err := op.getPrometheusConnForReportDataSource(dataSource)
+	if err != nil {
+		return err
+	}
+
+	dataSourceLogger := logger.WithFields(log.Fields{
+		"reportDataSource": dataSource.Name,
+		"tableName":        rawTableName,
+	})
+	key := dataSource.Namespace + "/" + dataSource.Name
+	// wrap in a closure to handle lock and unlock of the mutex
+	snapshotter := func() *prestostore.PrometheusSnapshotter {
+		op.prometheusSnapshottersMu.Lock()
+		defer op.prometheusSnapshottersMu.Unlock()
+		snapshotter, exists := op.prometheusSnapshotters[key]
+		if exists {
+			snapshotter.UpdateConfig(snapshotterCfg)
+			snapshotter.UpdatePrometheusConn(promConn)
+			return snapshotter
+		}
+		metricsCollectors := op.newPromImporterMetricsCollectors(dataSource, rawPrestoTable, prestostore.Config{PrestoTableName: rawTableName})
+		snapshotter = prestostore.NewPrometheusSnapshotter(dataSourceLogger, promConn, op.prometheusSnapshotRepo, op.clock, snapshotterCfg, metricsCollectors)
+		op.prometheusSnapshotters[key] = snapshotter
+		return snapshotter
+	}()
+
+	results, err := snapshotter.Snapshot(context.Background())
+	if endpointSet, ok := promConn.(*prometheusEndpointSet); ok {
+		updatedDS, updateErr := op.updatePrometheusEndpointStatuses(dataSource, endpointSet)
+		if updateErr != nil {
+			logger.WithError(updateErr).Errorf("unable to update ReportDataSource %s Prometheus endpoint statuses", dataSource.Name)
+		} else {
+			dataSource = updatedDS
+		}
+	}
+	if err != nil {
+		op.eventRecorder.Event(dataSource, v1.EventTypeWarning, "FailedPrometheusQuery", "Unable to store snapshot after Prometheus query failure. Check the reporting-operator container logs for more information.")
+		return fmt.Errorf("Snapshot errored: %v", err)
+	}
+
+	// the value of each series is known until the time of the snapshot, so
+	// Reports can run once a snapshot after their reporting period is stored
+	snapshotTime := &metav1.Time{Time: results.Time}
+	importStatus := dataSource.Status.PrometheusMetricsImportStatus.DeepCopy()
+	if importStatus == nil {
+		importStatus = &metering.PrometheusMetricsImportStatus{}
+	}
+	importStatus.LastImportTime = &metav1.Time{Time: op.clock.Now().UTC()}
+	if importStatus.ImportDataStartTime == nil {
+		importStatus.ImportDataStartTime = snapshotTime
+	}
+	importStatus.ImportDataEndTime = snapshotTime
+	if results.StoredRows != 0 {
+		if importStatus.EarliestImportedMetricTime == nil {
+			importStatus.EarliestImportedMetricTime = snapshotTime
+		}
+		importStatus.NewestImportedMetricTime = snapshotTime
+	}
+
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	dataSource, err = updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		newDS.Status.PrometheusMetricsImportStatus = importStatus
+		newDS.Status.PrometheusSnapshot = &metering.PrometheusSnapshotDataSourceStatus{
+			RawTableRef:         rawTableRef,
+			LastSnapshotTime:    snapshotTime,
+			LastSnapshotSeries:  results.Series,
+			LastSnapshotChanges: results.StoredRows,
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update ReportDataSource %s PrometheusMetricsImportStatus: %v", dataSource.Name, err)
+	}
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+
+	snapshotInterval := op.getQueryIntervalForReportDataSource(dataSource)
+	logger.Infof("stored %d changes to the %d series of ReportDataSource %s, queuing to snapshot again in %s", results.StoredRows, results.Series, dataSource.Name, snapshotInterval)
+	op.enqueueReportDataSourceAfter(dataSource, snapshotInterval)
+	return nil
+}
+
+// newPromSnapshotterCfg returns the configuration of the snapshotter of a
+// snapshot mode Prometheus ReportDataSource storing into rawTableName.
+func (op *defaultReportingOperator) newPromSnapshotterCfg(dataSource *metering.ReportDataSource, rawTableName string) (prestostore.PrometheusSnapshotConfig, error) {
+	relabelConfigs, err := newPrometheusRelabelConfigs(dataSource.Spec.PrometheusMetricsImporter.RelabelConfigs)
+	if err != nil {
+		return prestostore.PrometheusSnapshotConfig{}, fmt.Errorf("invalid relabelConfigs for ReportDataSource %s: %v", dataSource.Name, err)
+	}
+	return prestostore.PrometheusSnapshotConfig{
+		PrometheusQuery: dataSource.Spec.PrometheusMetricsImporter.Query,
+		PrestoTableName: rawTableName,
+		RelabelConfigs:  relabelConfigs,
+		Scheduler:       op.importScheduler,
+		SchedulerKey: prestostore.ImportSchedulerKey{
+			Queue:      dataSource.Namespace + "/" + dataSource.Name,
+			Prometheus: op.prometheusAddressForReportDataSource(dataSource),
+		},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+func TestValidatePrometheusSnapshotDataSource(t *testing.T) {
+	tests := map[string]struct {
+		modify      func(*metering.ReportDataSource)
+		expectedErr string
+	}{
+		"valid": {
+			modify: func(*metering.ReportDataSource) {},
+		},
+		"missing query": {
+			modify: func(ds *metering.ReportDataSource) {
+				ds.Spec.PrometheusMetricsImporter.Query = ""
+			},
+			expectedErr: "spec.prometheusMetricsImporter.query must be set in snapshot mode",
+		},
+		"remoteWrite": {
+			modify: func(ds *metering.ReportDataSource) {
+				ds.Spec.PrometheusMetricsImporter.RemoteWrite = &metering.PrometheusRemoteWriteConfig{}
+			},
+			expectedErr: "spec.prometheusMetricsImporter.remoteWrite can't be used in snapshot mode",
+		},
+		"backfill": {
+			modify: func(ds *metering.ReportDataSource) {
+				ds.Spec.Backfill = []metering.ReportDataSourceBackfill{{Name: "last-month"}}
+			},
+			expectedErr: "spec.backfill can't be used in snapshot mode, instant queries can only be run at the current time",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ds := testhelpers.NewReportDataSource("node-labels", "default")
+			ds.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{
+				Mode:  metering.PrometheusMetricsImporterModeSnapshot,
+				Query: "kube_node_labels",
+			}
+			test.modify(ds)
+			err := validatePrometheusSnapshotDataSource(ds)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPrometheusSnapshotView(t *testing.T) {
+	query, columns := prometheusSnapshotView("hive.metering.datasource_node_labels_raw")
+	assert.Equal(t, `SELECT "labels", "amount", "valid_from", "valid_to", "dt" FROM (SELECT "labels", "amount", "present", "dt", "timestamp" AS "valid_from", coalesce(lead("timestamp") OVER (PARTITION BY "dt", "series" ORDER BY "timestamp"), IF("dt" < (SELECT max("dt") FROM hive.metering."datasource_node_labels_raw$partitions"), date_add('day', 1, date_parse("dt", '%Y-%m-%d')))) AS "valid_to" FROM hive.metering.datasource_node_labels_raw) WHERE "present"`, query)
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	assert.Equal(t, []string{"labels", "amount", "valid_from", "valid_to", "dt"}, names)
+}
@@ -136,6 +136,34 @@ var (
 			Help:      "Number of Prometheus ReportDatasource imports currently running.",
 		},
 	)
+
+	prometheusReportDatasourceQueuedPrometheusQueriesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_queued_prometheus_queries",
+			Help:      "Number of Prometheus ReportDatasource Prometheus queries waiting for the import scheduler.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	prometheusReportDatasourceInFlightPrometheusQueriesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_in_flight_prometheus_queries",
+			Help:      "Number of Prometheus ReportDatasource Prometheus queries currently running.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	prometheusReportDatasourcePrometheusQueryQueueDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "prometheus_reportdatasource_prometheus_query_queue_duration_seconds",
+			Help:      "Duration Prometheus ReportDatasource Prometheus queries waited for the import scheduler.",
+			Buckets:   []float64{1.0, 10.0, 60.0, 300.0},
+		},
+		prometheusReportDatasourceLabels,
+	)
 )
 
 func init() {
@@ -151,6 +179,9 @@ func init() {
 	prometheus.MustRegister(prometheusReportDatasourcePrometheusQueryDurationHistogram)
 	prometheus.MustRegister(prometheusReportDatasourcePrestoreStoreDurationHistogram)
 	prometheus.MustRegister(prometheusReportDatasourceRunningImportsGauge)
+	prometheus.MustRegister(prometheusReportDatasourceQueuedPrometheusQueriesGauge)
+	prometheus.MustRegister(prometheusReportDatasourceInFlightPrometheusQueriesGauge)
+	prometheus.MustRegister(prometheusReportDatasourcePrometheusQueryQueueDurationHistogram)
 }
 
 type prometheusImporterFunc func(ctx context.Context, namespace, dsName string, start, end time.Time) ([]*prometheusImportResults, error)
@@ -179,7 +210,9 @@ func (op *defaultReportingOperator) importPrometheusForTimeRange(ctx context.Con
 	var reportDataSourcesToImport []*metering.ReportDataSource
 
 	for _, reportDataSource := range reportDataSources.Items {
-		if reportDataSource.Spec.PrometheusMetricsImporter == nil {
+		// remote_write ReportDataSources have nothing to import, and
+		// instant queries can't be run for a past time range
+		if reportDataSource.Spec.PrometheusMetricsImporter == nil || reportDataSource.Spec.PrometheusMetricsImporter.RemoteWrite != nil || reportDataSource.Spec.PrometheusMetricsImporter.Mode == metering.PrometheusMetricsImporterModeSnapshot {
 			continue
 		}
 
@@ -227,14 +260,9 @@ func (op *defaultReportingOperator) importPrometheusForTimeRange(ctx context.Con
 				<-semaphore
 			}()
 
-			var promConn prom.API
-			if (reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig != nil) && (reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig.URL != "") {
-				promConn, err = op.newPrometheusConnFromURL(reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig.URL)
-				if err != nil {
-					return err
-				}
-			} else {
-				promConn = op.promConn
+			promConn, err := op.getPrometheusConnForReportDataSource(reportDataSource)
+			if err != nil {
+				return err
 			}
 
 			importResults, err := prestostore.ImportFromTimeRange(dataSourceLogger, op.clock, promConn, op.prometheusMetricsRepo, metricsCollectors, ctx, start, end, importCfg)
@@ -310,6 +338,19 @@ func (op *defaultReportingOperator) newPromImporterCfg(reportDataSource *meterin
 		return prestostore.Config{}, err
 	}
 
+	var remoteReadCfg *prestostore.RemoteReadConfig
+	if reportDataSource.Spec.PrometheusMetricsImporter.RemoteRead != nil {
+		remoteReadCfg, err = op.newPromRemoteReadCfg(reportDataSource)
+		if err != nil {
+			return prestostore.Config{}, err
+		}
+	}
+
+	relabelConfigs, err := newPrometheusRelabelConfigs(reportDataSource.Spec.PrometheusMetricsImporter.RelabelConfigs)
+	if err != nil {
+		return prestostore.Config{}, fmt.Errorf("invalid relabelConfigs for ReportDataSource %s: %v", reportDataSource.Name, err)
+	}
+
 	return prestostore.Config{
 		PrometheusQuery:           query,
 		PrestoTableName:           tableName,
@@ -319,23 +360,20 @@ func (op *defaultReportingOperator) newPromImporterCfg(reportDataSource *meterin
 		MaxQueryRangeDuration:     op.cfg.PrometheusDataSourceMaxQueryRangeDuration,
 		MaxBackfillImportDuration: op.cfg.PrometheusDataSourceMaxBackfillImportDuration,
 		ImportFromTime:            op.cfg.PrometheusDataSourceGlobalImportFromTime,
+		RemoteRead:                remoteReadCfg,
+		RelabelConfigs:            relabelConfigs,
+		ChunkConcurrency:          op.cfg.PrometheusDataSourceChunkConcurrency,
+		Scheduler:                 op.importScheduler,
+		SchedulerKey: prestostore.ImportSchedulerKey{
+			Queue:      reportDataSource.Namespace + "/" + reportDataSource.Name,
+			Prometheus: op.prometheusAddressForReportDataSource(reportDataSource),
+		},
 	}, nil
 }
 
-func (op *defaultReportingOperator) newPromImporter(logger logrus.FieldLogger, reportDataSource *metering.ReportDataSource, prestoTable *metering.PrestoTable, cfg prestostore.Config) (*prestostore.PrometheusImporter, error) {
+func (op *defaultReportingOperator) newPromImporter(logger logrus.FieldLogger, reportDataSource *metering.ReportDataSource, prestoTable *metering.PrestoTable, promConn prom.API, cfg prestostore.Config) *prestostore.PrometheusImporter {
 	metricsCollectors := op.newPromImporterMetricsCollectors(reportDataSource, prestoTable, cfg)
-	var promConn prom.API
-	var err error
-	if (reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig != nil) && (reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig.URL != "") {
-		promConn, err = op.newPrometheusConnFromURL(reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig.URL)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		promConn = op.promConn
-	}
-
-	return prestostore.NewPrometheusImporter(logger, promConn, op.prometheusMetricsRepo, op.clock, cfg, metricsCollectors), nil
+	return prestostore.NewPrometheusImporter(logger, promConn, op.prometheusMetricsRepo, op.clock, cfg, metricsCollectors)
 }
 
 func (op *defaultReportingOperator) newPromImporterMetricsCollectors(reportDataSource *metering.ReportDataSource, prestoTable *metering.PrestoTable, cfg prestostore.Config) prestostore.ImporterMetricsCollectors {
@@ -362,6 +400,10 @@ func (op *defaultReportingOperator) newPromImporterMetricsCollectors(reportDataS
 
 	prestoStoreDurationHistogram := prometheusReportDatasourcePrestoreStoreDurationHistogram.With(promLabels)
 
+	queuedPrometheusQueriesGauge := prometheusReportDatasourceQueuedPrometheusQueriesGauge.With(promLabels)
+	inFlightPrometheusQueriesGauge := prometheusReportDatasourceInFlightPrometheusQueriesGauge.With(promLabels)
+	promQueryQueueDurationHistogram := prometheusReportDatasourcePrometheusQueryQueueDurationHistogram.With(promLabels)
+
 	return prestostore.ImporterMetricsCollectors{
 		TotalImportsCounter:     totalImportsCounter,
 		FailedImportsCounter:    failedImportsCounter,
@@ -378,5 +420,9 @@ func (op *defaultReportingOperator) newPromImporterMetricsCollectors(reportDataS
 
 		MetricsScrapedCounter:  promQueryMetricsScrapedCounter,
 		MetricsImportedCounter: metricsImportedCounter,
+
+		QueuedPrometheusQueriesGauge:          queuedPrometheusQueriesGauge,
+		InFlightPrometheusQueriesGauge:        inFlightPrometheusQueriesGauge,
+		PrometheusQueryQueueDurationHistogram: promQueryQueueDurationHistogram,
 	}
 }
@@ -0,0 +1,615 @@
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const (
+	// PushTableTokenHeader is the header pushes send the token of the
+	// PushTable ReportDataSource in. The Authorization header isn't used,
+	// since it's used to authenticate to the auth proxy of the API.
+	PushTableTokenHeader = "X-Metering-Push-Token"
+	// PushTableIdempotencyKeyHeader identifies the rows of a push to a
+	// PushTable ReportDataSource without keyColumns, so the push can be
+	// retried without storing its rows twice. Responses to pushes have the
+	// key of the push in this header, which is generated if the push didn't
+	// have one.
+	PushTableIdempotencyKeyHeader = "Idempotency-Key"
+
+	pushTableTokenSecretKey = "token"
+	// maxPushTableRequestBytes is the largest body accepted by a push.
+	maxPushTableRequestBytes = 16 << 20
+	// maxPushTableRowErrors is the most rejected rows whose errors are
+	// returned by a push.
+	maxPushTableRowErrors = 100
+)
+
+var (
+	// pushTableColumnTypes are the Presto types of the Hive types of the
+	// columns of PushTable ReportDataSources.
+	pushTableColumnTypes = map[string]string{
+		"string":    "varchar",
+		"boolean":   "boolean",
+		"tinyint":   "tinyint",
+		"smallint":  "smallint",
+		"int":       "integer",
+		"bigint":    "bigint",
+		"double":    "double",
+		"date":      "date",
+		"timestamp": "timestamp",
+	}
+	pushTableIntegerBits = map[string]int{
+		"tinyint":  8,
+		"smallint": 16,
+		"integer":  32,
+		"bigint":   64,
+	}
+	// pushTableTimestampLayouts are the accepted formats of timestamps.
+	// Timestamps without a time zone are UTC.
+	pushTableTimestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999",
+	}
+
+	pushTableRowsAcceptedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "pushtable_reportdatasource_rows_accepted_total",
+			Help:      "Number of rows pushed to a PushTable ReportDataSource which were stored.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	pushTableRowsRejectedCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "pushtable_reportdatasource_rows_rejected_total",
+			Help:      "Number of rows pushed to a PushTable ReportDataSource which were rejected by validation.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+
+	pushTableFailedStoresCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: prometheusMetricNamespace,
+			Name:      "pushtable_reportdatasource_failed_presto_stores_total",
+			Help:      "Number of pushes to a PushTable ReportDataSource whose rows failed to be stored.",
+		},
+		prometheusReportDatasourceLabels,
+	)
+)
+
+func init() {
+	prometheus.MustRegister(pushTableRowsAcceptedCounter)
+	prometheus.MustRegister(pushTableRowsRejectedCounter)
+	prometheus.MustRegister(pushTableFailedStoresCounter)
+}
+
+type pushTableColumn struct {
+	name       string
+	prestoType string
+}
+
+// pushTableSchema is a validated spec.pushTable.
+type pushTableSchema struct {
+	columns []pushTableColumn
+	// columnIndexes are the positions of the columns by name.
+	columnIndexes map[string]int
+	// keyColumns are the positions of the keyColumns.
+	keyColumns []int
+}
+
+func validatePushTableDataSource(pushTable *metering.PushTableDataSource) (*pushTableSchema, error) {
+	if pushTable.TokenSecretRef.Name == "" {
+		return nil, fmt.Errorf("spec.pushTable.tokenSecretRef.name must be set")
+	}
+	if len(pushTable.Columns) == 0 {
+		return nil, fmt.Errorf("spec.pushTable.columns must have at least one column")
+	}
+	reserved := make(map[string]bool)
+	for _, col := range prestostore.PushTableHiveSystemColumns {
+		reserved[col.Name] = true
+	}
+	for _, col := range prestostore.PushTableHivePartitionColumns {
+		reserved[col.Name] = true
+	}
+
+	schema := &pushTableSchema{columnIndexes: make(map[string]int)}
+	for i, col := range pushTable.Columns {
+		if !fileDropColumnNameRegexp.MatchString(col.Name) {
+			return nil, fmt.Errorf("invalid column name %q, names must be lowercase letters, digits and underscores", col.Name)
+		}
+		if reserved[col.Name] {
+			return nil, fmt.Errorf("column name %s is reserved", col.Name)
+		}
+		if _, exists := schema.columnIndexes[col.Name]; exists {
+			return nil, fmt.Errorf("column %s is declared more than once", col.Name)
+		}
+		prestoType, ok := pushTableColumnTypes[strings.ToLower(col.Type)]
+		if !ok {
+			return nil, fmt.Errorf("invalid type %q of column %s, must be one of string, boolean, tinyint, smallint, int, bigint, double, date or timestamp", col.Type, col.Name)
+		}
+		schema.columns = append(schema.columns, pushTableColumn{name: col.Name, prestoType: prestoType})
+		schema.columnIndexes[col.Name] = i
+	}
+
+	keyColumns := make(map[string]bool)
+	for _, name := range pushTable.KeyColumns {
+		i, ok := schema.columnIndexes[name]
+		if !ok {
+			return nil, fmt.Errorf("key column %s isn't a column", name)
+		}
+		if keyColumns[name] {
+			return nil, fmt.Errorf("key column %s is listed more than once", name)
+		}
+		keyColumns[name] = true
+		schema.keyColumns = append(schema.keyColumns, i)
+	}
+	return schema, nil
+}
+
+// hiveColumns returns the declared columns, with lowercase types.
+func (s *pushTableSchema) hiveColumns(pushTable *metering.PushTableDataSource) []hive.Column {
+	columns := make([]hive.Column, len(pushTable.Columns))
+	for i, col := range pushTable.Columns {
+		columns[i] = hive.Column{Name: col.Name, Type: strings.ToLower(col.Type)}
+	}
+	return columns
+}
+
+// pushTableView returns the query of a view of rawTableName with the
+// declared columns and the ingestion time of each row, keeping only the
+// first row pushed with each idempotency key.
+func pushTableView(schema *pushTableSchema, rawTableName string) (string, []presto.Column) {
+	var (
+		selects []string
+		columns []presto.Column
+	)
+	for _, col := range schema.columns {
+		selects = append(selects, presto.QuoteIdentifier(col.name))
+		columns = append(columns, presto.Column{Name: col.name, Type: col.prestoType})
+	}
+	selects = append(selects, presto.QuoteIdentifier(prestostore.PushTableIngestionTimeColumnName))
+	columns = append(columns, presto.Column{Name: prestostore.PushTableIngestionTimeColumnName, Type: "timestamp"})
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM (SELECT *, row_number() OVER (PARTITION BY %s ORDER BY %s) AS push_row_number FROM %s) WHERE push_row_number = 1",
+		strings.Join(selects, ", "),
+		presto.QuoteIdentifier(prestostore.PushTableIdempotencyKeyColumnName),
+		presto.QuoteIdentifier(prestostore.PushTableIngestionTimeColumnName),
+		rawTableName,
+	)
+	return query, columns
+}
+
+// formatValue returns value as a SQL literal of the type of col, or NULL if
+// value is nil.
+func (col pushTableColumn) formatValue(value *string) (string, error) {
+	if value == nil {
+		return "NULL", nil
+	}
+	v := *value
+	switch col.prestoType {
+	case "varchar":
+		return presto.QuoteString(v), nil
+	case "boolean":
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("invalid boolean %q", v)
+		}
+		return strconv.FormatBool(b), nil
+	case "tinyint", "smallint", "integer", "bigint":
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, pushTableIntegerBits[col.prestoType])
+		if err != nil {
+			return "", fmt.Errorf("invalid %s %q", col.prestoType, v)
+		}
+		return fmt.Sprintf("CAST(%d AS %s)", i, col.prestoType), nil
+	case "double":
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("invalid double %q", v)
+		}
+		return presto.FormatDouble(f), nil
+	case "date":
+		d, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+		if err != nil {
+			return "", fmt.Errorf("invalid date %q, must be in the format YYYY-MM-DD", v)
+		}
+		return presto.FormatDate(d), nil
+	case "timestamp":
+		for _, layout := range pushTableTimestampLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return presto.FormatTimestamp(t), nil
+			}
+		}
+		return "", fmt.Errorf("invalid timestamp %q, must be in RFC 3339 format", v)
+	default:
+		return "", fmt.Errorf("unsupported type %s", col.prestoType)
+	}
+}
+
+// pushTableRowError is the reason a pushed row was rejected. Row is the
+// position of the row in the push, starting at 1, not counting the header of
+// CSV pushes or empty lines of JSON Lines pushes.
+type pushTableRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type pushTableResponse struct {
+	Accepted int `json:"accepted"`
+	Rejected int `json:"rejected"`
+	// Errors are the errors of the first rejected rows.
+	Errors []pushTableRowError `json:"errors,omitempty"`
+}
+
+// pushTableBatch is the rows of a push converted to rows of the table of a
+// PushTable ReportDataSource.
+type pushTableBatch struct {
+	schema         *pushTableSchema
+	idempotencyKey string
+	ingestionTime  time.Time
+
+	rows     []*prestostore.PushTableRow
+	rejected int
+	errors   []pushTableRowError
+}
+
+func newPushTableBatch(schema *pushTableSchema, idempotencyKey string, ingestionTime time.Time) *pushTableBatch {
+	return &pushTableBatch{
+		schema:         schema,
+		idempotencyKey: idempotencyKey,
+		ingestionTime:  ingestionTime,
+	}
+}
+
+func (b *pushTableBatch) reject(row int, err error) {
+	b.rejected++
+	if len(b.errors) < maxPushTableRowErrors {
+		b.errors = append(b.errors, pushTableRowError{Row: row, Error: err.Error()})
+	}
+}
+
+// add validates the values of row against the schema, which are nil for
+// null values, and adds it to the batch, or records why it's rejected.
+func (b *pushTableBatch) add(row int, values []*string) {
+	literals := make([]string, len(values))
+	for i, col := range b.schema.columns {
+		literal, err := col.formatValue(values[i])
+		if err != nil {
+			b.reject(row, fmt.Errorf("column %s: %v", col.name, err))
+			return
+		}
+		literals[i] = literal
+	}
+	b.rows = append(b.rows, &prestostore.PushTableRow{
+		IdempotencyKey: b.rowIdempotencyKey(row, values),
+		IngestionTime:  b.ingestionTime,
+		Values:         literals,
+	})
+}
+
+// rowIdempotencyKey returns a hash of the values of the keyColumns of row,
+// or the idempotency key of the push followed by the position of the row if
+// there aren't any keyColumns.
+func (b *pushTableBatch) rowIdempotencyKey(row int, values []*string) string {
+	if len(b.schema.keyColumns) == 0 {
+		return b.idempotencyKey + ":" + strconv.Itoa(row)
+	}
+	keyValues := make([]*string, len(b.schema.keyColumns))
+	for i, col := range b.schema.keyColumns {
+		keyValues[i] = values[col]
+	}
+	// JSON distinguishes null from empty values, and can't be ambiguous
+	// about where each value ends
+	encoded, _ := json.Marshal(keyValues)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *pushTableBatch) response() pushTableResponse {
+	return pushTableResponse{
+		Accepted: len(b.rows),
+		Rejected: b.rejected,
+		Errors:   b.errors,
+	}
+}
+
+// readPushTableCSV adds the rows of a CSV push to batch. The first record is
+// the header naming the column of each field, and columns without a field
+// are null, the same as empty fields. An error is returned if the push can't
+// be read at all.
+func readPushTableCSV(batch *pushTableBatch, body io.Reader) error {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read CSV header: %v", err)
+	}
+	fieldColumns := make([]int, len(header))
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		col, ok := batch.schema.columnIndexes[name]
+		if !ok {
+			return fmt.Errorf("CSV header field %q isn't a column", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("CSV header field %q is repeated", name)
+		}
+		seen[name] = true
+		fieldColumns[i] = col
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				batch.reject(row, fmt.Errorf("has %d fields, the header has %d", len(record), len(header)))
+				continue
+			}
+			return fmt.Errorf("unable to read CSV row %d: %v", row, err)
+		}
+		values := make([]*string, len(batch.schema.columns))
+		for i, field := range record {
+			if field == "" {
+				continue
+			}
+			field := field
+			values[fieldColumns[i]] = &field
+		}
+		batch.add(row, values)
+	}
+}
+
+// readPushTableJSONLines adds the rows of a JSON Lines push to batch. Each
+// line is a JSON object whose fields are columns, and columns without a
+// field are null. An error is returned if the push can't be read at all.
+func readPushTableJSONLines(batch *pushTableBatch, body io.Reader) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxPushTableRequestBytes)
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+		values, err := parsePushTableJSONRow(batch.schema, line)
+		if err != nil {
+			batch.reject(row, err)
+			continue
+		}
+		batch.add(row, values)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read JSON Lines: %v", err)
+	}
+	return nil
+}
+
+func parsePushTableJSONRow(schema *pushTableSchema, line []byte) ([]*string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %v", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON object: unexpected data after the object")
+	}
+	values := make([]*string, len(schema.columns))
+	for name, field := range fields {
+		col, ok := schema.columnIndexes[name]
+		if !ok {
+			return nil, fmt.Errorf("field %q isn't a column", name)
+		}
+		var value string
+		switch v := field.(type) {
+		case nil:
+			continue
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("field %q must be a string, number, boolean or null", name)
+		}
+		values[col] = &value
+	}
+	return values, nil
+}
+
+// pushTableHandler stores the rows pushed to a PushTable ReportDataSource in
+// JSON Lines or CSV format, and responds with the number of rows accepted
+// and rejected by validation. The rows are stored by several INSERTs, so if
+// storing them fails some of them may already be stored. The push should
+// then be retried with the idempotency key in the response, since the view
+// of the table only has the first row stored with each key.
+func (srv *server) pushTableHandler(w http.ResponseWriter, r *http.Request) {
+	logger := newRequestLogger(srv.logger, r, srv.rand)
+	namespace := chi.URLParam(r, "namespace")
+	name := chi.URLParam(r, "name")
+
+	if r.Method != http.MethodPost {
+		writeErrorResponse(logger, w, r, http.StatusMethodNotAllowed, "pushes must use POST")
+		return
+	}
+
+	dataSource, err := srv.reportDataSourceLister.ReportDataSources(namespace).Get(name)
+	if k8serrors.IsNotFound(err) || (err == nil && dataSource.Spec.PushTable == nil) {
+		writeErrorResponse(logger, w, r, http.StatusNotFound, "PushTable ReportDataSource %s not found", name)
+		return
+	}
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "unable to get ReportDataSource %s: %v", name, err)
+		return
+	}
+	pushTable := dataSource.Spec.PushTable
+	logger = logger.WithField("reportDataSource", dataSource.Name)
+
+	if !srv.authenticatePush(logger, w, r, dataSource) {
+		return
+	}
+
+	schema, err := validatePushTableDataSource(pushTable)
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "ReportDataSource %s is improperly configured: %v", dataSource.Name, err)
+		return
+	}
+	if dataSource.Status.PushTable == nil {
+		writeErrorResponse(logger, w, r, http.StatusServiceUnavailable, "ReportDataSource %s table not created yet", dataSource.Name)
+		return
+	}
+	tableName, err := srv.getPrestoTableName(dataSource.Namespace, dataSource.Status.PushTable.RawTableRef.Name)
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusServiceUnavailable, "%v", err)
+		return
+	}
+
+	idempotencyKey := r.Header.Get(PushTableIdempotencyKeyHeader)
+	if idempotencyKey == "" {
+		// the generated key is returned, so the push can still be
+		// retried without storing its rows twice
+		idempotencyKey, err = newPushTableIdempotencyKey()
+		if err != nil {
+			writeErrorResponse(logger, w, r, http.StatusInternalServerError, "unable to generate idempotency key: %v", err)
+			return
+		}
+	}
+	w.Header().Set(PushTableIdempotencyKeyHeader, idempotencyKey)
+	batch := newPushTableBatch(schema, idempotencyKey, srv.clock.Now().UTC())
+
+	body := http.MaxBytesReader(w, r.Body, maxPushTableRequestBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		err = readPushTableCSV(batch, body)
+	case "", "application/x-ndjson", "application/jsonl", "application/json":
+		err = readPushTableJSONLines(batch, body)
+	default:
+		writeErrorResponse(logger, w, r, http.StatusUnsupportedMediaType, "unsupported Content-Type %q, must be text/csv or application/x-ndjson", mediaType)
+		return
+	}
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	promLabels := prometheus.Labels{
+		"reportdatasource": dataSource.Name,
+		"namespace":        dataSource.Namespace,
+		"table_name":       tableName,
+	}
+	if len(batch.rows) != 0 {
+		err = srv.pushTableRepo.StorePushTableRows(context.Background(), tableName, batch.rows)
+		if err != nil {
+			pushTableFailedStoresCounter.With(promLabels).Inc()
+			writeErrorResponse(logger, w, r, http.StatusInternalServerError, "unable to store rows for ReportDataSource %s, some of them may have been stored and the push should be retried with the %s %s: %v", dataSource.Name, PushTableIdempotencyKeyHeader, idempotencyKey, err)
+			return
+		}
+	}
+	pushTableRowsAcceptedCounter.With(promLabels).Add(float64(len(batch.rows)))
+	pushTableRowsRejectedCounter.With(promLabels).Add(float64(batch.rejected))
+	logger.Debugf("stored %d pushed rows into %s, rejected %d rows", len(batch.rows), tableName, batch.rejected)
+
+	writeResponseAsJSON(logger, w, http.StatusOK, batch.response())
+}
+
+// authenticatePush checks the token of a push to dataSource, writing an error
+// response and returning false if it's not the token in the tokenSecretRef
+// of dataSource.
+func (srv *server) authenticatePush(logger log.FieldLogger, w http.ResponseWriter, r *http.Request, dataSource *metering.ReportDataSource) bool {
+	token := r.Header.Get(PushTableTokenHeader)
+	if token == "" {
+		writeErrorResponse(logger, w, r, http.StatusUnauthorized, "the %s header must be set", PushTableTokenHeader)
+		return false
+	}
+	secretName := dataSource.Spec.PushTable.TokenSecretRef.Name
+	if secretName == "" {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "ReportDataSource %s is improperly configured: spec.pushTable.tokenSecretRef.name must be set", dataSource.Name)
+		return false
+	}
+	secret, err := srv.secretsGetter.Secrets(dataSource.Namespace).Get(r.Context(), secretName, metav1.GetOptions{})
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "unable to get the token Secret %s of ReportDataSource %s: %v", secretName, dataSource.Name, err)
+		return false
+	}
+	expected, ok := secret.Data[pushTableTokenSecretKey]
+	if !ok || len(expected) == 0 {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "the token Secret %s of ReportDataSource %s has no %s key", secretName, dataSource.Name, pushTableTokenSecretKey)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), bytes.TrimSpace(expected)) != 1 {
+		writeErrorResponse(logger, w, r, http.StatusUnauthorized, "invalid token for ReportDataSource %s", dataSource.Name)
+		return false
+	}
+	return true
+}
+
+func newPushTableIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// createPushTableTables creates the HiveTable the rows pushed to dataSource
+// are stored in, and a PrestoTable view of it with a single row for each
+// idempotency key.
+func (op *defaultReportingOperator) createPushTableTables(logger log.FieldLogger, dataSource *metering.ReportDataSource, schema *pushTableSchema) (*metering.HiveTable, *metering.PrestoTable, error) {
+	pushTable := dataSource.Spec.PushTable
+	dbName, err := op.getDataSourceDatabaseName(dataSource, pushTable.DatabaseName)
+	if err != nil {
+		return nil, nil, err
+	}
+	params := hive.TableParameters{
+		Database:      dbName,
+		Name:          reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name) + dataSourceRawTableSuffix,
+		Columns:       prestostore.PushTableHiveColumns(schema.hiveColumns(pushTable)),
+		PartitionedBy: prestostore.PushTableHivePartitionColumns,
+	}
+	return op.createDataSourceRawTableAndView(logger, dataSource, params, func(rawTableName string, _ []hive.Column) (string, []presto.Column) {
+		return pushTableView(schema, rawTableName)
+	})
+}
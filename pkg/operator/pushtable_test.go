@@ -0,0 +1,406 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	listers "github.com/kube-reporting/metering-operator/pkg/generated/listers/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+type fakePushTableRepo struct {
+	rows map[string][]*prestostore.PushTableRow
+	err  error
+}
+
+func (f *fakePushTableRepo) StorePushTableRows(ctx context.Context, tableName string, rows []*prestostore.PushTableRow) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.rows[tableName] = append(f.rows[tableName], rows...)
+	return nil
+}
+
+// fakeSecrets is a SecretsGetter only implementing Get.
+type fakeSecrets struct {
+	corev1.SecretInterface
+	secrets map[string]*v1.Secret
+}
+
+func (f *fakeSecrets) Secrets(namespace string) corev1.SecretInterface {
+	return f
+}
+
+func (f *fakeSecrets) Get(ctx context.Context, name string, opts metav1.GetOptions) (*v1.Secret, error) {
+	secret, ok := f.secrets[name]
+	if !ok {
+		return nil, k8serrors.NewNotFound(v1.Resource("secrets"), name)
+	}
+	return secret, nil
+}
+
+func newTestPushTable() *metering.PushTableDataSource {
+	return &metering.PushTableDataSource{
+		Columns: []hive.Column{
+			{Name: "runner", Type: "string"},
+			{Name: "build", Type: "bigint"},
+			{Name: "minutes", Type: "double"},
+			{Name: "cached", Type: "boolean"},
+			{Name: "started", Type: "timestamp"},
+		},
+		KeyColumns:     []string{"runner", "build"},
+		TokenSecretRef: v1.LocalObjectReference{Name: "ci-push-token"},
+	}
+}
+
+func TestValidatePushTableDataSource(t *testing.T) {
+	schema, err := validatePushTableDataSource(newTestPushTable())
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, schema.keyColumns)
+	assert.Equal(t, "integer", mustValidatePushTable(t, []hive.Column{{Name: "count", Type: "INT"}}).columns[0].prestoType)
+
+	for name, modify := range map[string]func(*metering.PushTableDataSource){
+		"no token":         func(p *metering.PushTableDataSource) { p.TokenSecretRef.Name = "" },
+		"no columns":       func(p *metering.PushTableDataSource) { p.Columns = nil },
+		"uppercase column": func(p *metering.PushTableDataSource) { p.Columns[0].Name = "Runner" },
+		"duplicate column": func(p *metering.PushTableDataSource) { p.Columns[1].Name = "runner" },
+		"reserved column":  func(p *metering.PushTableDataSource) { p.Columns[0].Name = "idempotency_key" },
+		"partition column": func(p *metering.PushTableDataSource) { p.Columns[0].Name = "dt" },
+		"nested type":      func(p *metering.PushTableDataSource) { p.Columns[0].Type = "array<string>" },
+		"unknown key":      func(p *metering.PushTableDataSource) { p.KeyColumns = []string{"job"} },
+		"duplicate key":    func(p *metering.PushTableDataSource) { p.KeyColumns = []string{"build", "build"} },
+	} {
+		pushTable := newTestPushTable()
+		modify(pushTable)
+		_, err := validatePushTableDataSource(pushTable)
+		assert.Error(t, err, name)
+	}
+}
+
+func mustValidatePushTable(t *testing.T, columns []hive.Column) *pushTableSchema {
+	schema, err := validatePushTableDataSource(&metering.PushTableDataSource{
+		Columns:        columns,
+		TokenSecretRef: v1.LocalObjectReference{Name: "token"},
+	})
+	require.NoError(t, err)
+	return schema
+}
+
+func TestPushTableColumnFormatValue(t *testing.T) {
+	tests := []struct {
+		prestoType string
+		value      string
+		expected   string
+		expectErr  bool
+	}{
+		{prestoType: "varchar", value: "it's", expected: "'it''s'"},
+		{prestoType: "boolean", value: "true", expected: "true"},
+		{prestoType: "boolean", value: "yes", expectErr: true},
+		{prestoType: "tinyint", value: "127", expected: "CAST(127 AS tinyint)"},
+		{prestoType: "tinyint", value: "128", expectErr: true},
+		{prestoType: "bigint", value: " 42 ", expected: "CAST(42 AS bigint)"},
+		{prestoType: "integer", value: "1.5", expectErr: true},
+		{prestoType: "double", value: "1.5", expected: "1.5E+00"},
+		{prestoType: "double", value: "NaN", expectErr: true},
+		{prestoType: "date", value: "2024-03-01", expected: "date '2024-03-01'"},
+		{prestoType: "date", value: "03/01/2024", expectErr: true},
+		{prestoType: "timestamp", value: "2024-03-01T10:00:00+02:00", expected: "timestamp '2024-03-01 08:00:00.000'"},
+		{prestoType: "timestamp", value: "2024-03-01 10:00:00.5", expected: "timestamp '2024-03-01 10:00:00.500'"},
+		{prestoType: "timestamp", value: "yesterday", expectErr: true},
+	}
+	for _, tt := range tests {
+		value := tt.value
+		literal, err := pushTableColumn{name: "col", prestoType: tt.prestoType}.formatValue(&value)
+		if tt.expectErr {
+			assert.Error(t, err, "%s %q", tt.prestoType, tt.value)
+			continue
+		}
+		require.NoError(t, err, "%s %q", tt.prestoType, tt.value)
+		assert.Equal(t, tt.expected, literal)
+	}
+
+	literal, err := pushTableColumn{name: "col", prestoType: "bigint"}.formatValue(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "NULL", literal)
+}
+
+func TestReadPushTableRows(t *testing.T) {
+	schema, err := validatePushTableDataSource(newTestPushTable())
+	require.NoError(t, err)
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	batch := newPushTableBatch(schema, "push-1", now)
+	err = readPushTableJSONLines(batch, strings.NewReader(`{"runner":"ci-1","build":42,"minutes":3.5,"cached":true,"started":"2024-03-01T10:00:00Z"}
+
+{"runner":"ci-1","build":"43","minutes":null}
+{"runner":"ci-2","build":44,"extra":1}
+{"runner":"ci-2","build":[44]}
+{"runner":"ci-2","build":45,"minutes":"slow"}
+not json
+`))
+	require.NoError(t, err)
+	require.Len(t, batch.rows, 2)
+	assert.Equal(t, []string{"'ci-1'", "CAST(42 AS bigint)", "3.5E+00", "true", "timestamp '2024-03-01 10:00:00.000'"}, batch.rows[0].Values)
+	assert.Equal(t, []string{"'ci-1'", "CAST(43 AS bigint)", "NULL", "NULL", "NULL"}, batch.rows[1].Values)
+	assert.Equal(t, now, batch.rows[0].IngestionTime)
+	assert.Len(t, batch.rows[0].IdempotencyKey, 64)
+	assert.NotEqual(t, batch.rows[0].IdempotencyKey, batch.rows[1].IdempotencyKey)
+
+	resp := batch.response()
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, 4, resp.Rejected)
+	require.Len(t, resp.Errors, 4)
+	assert.Equal(t, 3, resp.Errors[0].Row)
+	assert.Contains(t, resp.Errors[0].Error, `field "extra" isn't a column`)
+	assert.Contains(t, resp.Errors[2].Error, "column minutes")
+	assert.Equal(t, 6, resp.Errors[3].Row)
+
+	// rows of CSV pushes have the same keys as the same JSON rows, and
+	// columns without a field are null
+	csvBatch := newPushTableBatch(schema, "push-2", now)
+	err = readPushTableCSV(csvBatch, strings.NewReader("build,runner,minutes\n42,ci-1,3.5\n43,ci-1,\n44,ci-2\n"))
+	require.NoError(t, err)
+	require.Len(t, csvBatch.rows, 2)
+	assert.Equal(t, batch.rows[0].IdempotencyKey, csvBatch.rows[0].IdempotencyKey)
+	assert.Equal(t, []string{"'ci-1'", "CAST(43 AS bigint)", "NULL", "NULL", "NULL"}, csvBatch.rows[1].Values)
+	require.Len(t, csvBatch.errors, 1)
+	assert.Equal(t, 3, csvBatch.errors[0].Row)
+
+	for name, body := range map[string]string{
+		"unknown header field":  "runner,job\nci-1,build\n",
+		"repeated header field": "runner,runner\nci-1,ci-2\n",
+		"bare quote":            "runner\nci\"1\n",
+	} {
+		err := readPushTableCSV(newPushTableBatch(schema, "push-3", now), strings.NewReader(body))
+		assert.Error(t, err, name)
+	}
+
+	// without keyColumns, rows are identified by their position in the push
+	pushTable := newTestPushTable()
+	pushTable.KeyColumns = nil
+	schema, err = validatePushTableDataSource(pushTable)
+	require.NoError(t, err)
+	batch = newPushTableBatch(schema, "push-4", now)
+	require.NoError(t, readPushTableCSV(batch, strings.NewReader("runner\nci-1\nci-1\n")))
+	require.Len(t, batch.rows, 2)
+	assert.Equal(t, "push-4:1", batch.rows[0].IdempotencyKey)
+	assert.Equal(t, "push-4:2", batch.rows[1].IdempotencyKey)
+}
+
+func TestPushTableView(t *testing.T) {
+	schema := mustValidatePushTable(t, []hive.Column{{Name: "runner", Type: "string"}, {Name: "minutes", Type: "double"}})
+	query, columns := pushTableView(schema, "hive.metering.datasource_ci_minutes_raw")
+	assert.Equal(t, `SELECT "runner", "minutes", "ingestion_time" FROM (SELECT *, row_number() OVER (PARTITION BY "idempotency_key" ORDER BY "ingestion_time") AS push_row_number FROM hive.metering.datasource_ci_minutes_raw) WHERE push_row_number = 1`, query)
+	assert.Equal(t, "varchar", columns[0].Type)
+	assert.Equal(t, "ingestion_time", columns[2].Name)
+}
+
+func TestPushTableHandler(t *testing.T) {
+	const (
+		namespace      = "ci"
+		testCatalog    = "hive"
+		testSchema     = "metering"
+		rawTableName   = "datasource_ci_build_minutes_raw"
+		rawFQTableName = testCatalog + "." + testSchema + "." + rawTableName
+	)
+
+	rawTable := testhelpers.NewPrestoTable(rawTableName, namespace, testCatalog, testSchema, nil)
+	dataSource := testhelpers.NewReportDataSource("build-minutes", namespace)
+	dataSource.Spec.PushTable = newTestPushTable()
+	dataSource.Status.PushTable = &metering.PushTableDataSourceStatus{
+		RawTableRef: v1.LocalObjectReference{Name: rawTable.Name},
+	}
+	noTableDataSource := testhelpers.NewReportDataSource("test-minutes", namespace)
+	noTableDataSource.Spec.PushTable = newTestPushTable()
+	prometheusDataSource := testhelpers.NewReportDataSource("pod-request-cpu", namespace)
+	prometheusDataSource.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{Query: "up"}
+
+	secrets := &fakeSecrets{secrets: map[string]*v1.Secret{
+		"ci-push-token": {Data: map[string][]byte{"token": []byte("s3cret\n")}},
+	}}
+	now := time.Date(2024, time.March, 1, 10, 15, 0, 0, time.UTC)
+
+	jsonBody := `{"runner":"ci-1","build":42,"minutes":3.5}
+{"runner":"ci-1","build":43,"minutes":"slow"}
+`
+	tests := map[string]struct {
+		method             string
+		dataSource         string
+		token              string
+		contentType        string
+		body               string
+		noIdempotencyKey   bool
+		storeErr           error
+		expectedStatusCode int
+		expectedResponse   *pushTableResponse
+		expectedRows       int
+	}{
+		"stores valid rows": {
+			body:               jsonBody,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: &pushTableResponse{
+				Accepted: 1,
+				Rejected: 1,
+				Errors:   []pushTableRowError{{Row: 2, Error: `column minutes: invalid double "slow"`}},
+			},
+			expectedRows: 1,
+		},
+		"generates idempotency key": {
+			body:               jsonBody,
+			noIdempotencyKey:   true,
+			expectedStatusCode: http.StatusOK,
+			expectedResponse: &pushTableResponse{
+				Accepted: 1,
+				Rejected: 1,
+				Errors:   []pushTableRowError{{Row: 2, Error: `column minutes: invalid double "slow"`}},
+			},
+			expectedRows: 1,
+		},
+		"stores CSV rows": {
+			contentType:        "text/csv; charset=utf-8",
+			body:               "runner,build\nci-1,42\nci-2,43\n",
+			expectedStatusCode: http.StatusOK,
+			expectedResponse:   &pushTableResponse{Accepted: 2},
+			expectedRows:       2,
+		},
+		"missing token": {
+			token:              "-",
+			body:               jsonBody,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"wrong token": {
+			token:              "guess",
+			body:               jsonBody,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		"not a PushTable ReportDataSource": {
+			dataSource:         prometheusDataSource.Name,
+			body:               jsonBody,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"unknown ReportDataSource": {
+			dataSource:         "missing",
+			body:               jsonBody,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		"no table yet": {
+			dataSource:         noTableDataSource.Name,
+			body:               jsonBody,
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+		"invalid CSV header": {
+			contentType:        "text/csv",
+			body:               "runner,job\nci-1,build\n",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"unsupported content type": {
+			contentType:        "application/x-protobuf",
+			body:               jsonBody,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+		"store error": {
+			body:               jsonBody,
+			storeErr:           errors.New("mock database had an error"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		"wrong method": {
+			method:             http.MethodGet,
+			expectedStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for testName, tt := range tests {
+		tt := tt
+		t.Run(testName, func(t *testing.T) {
+			reportDataSourceIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+			for _, ds := range []*metering.ReportDataSource{dataSource, noTableDataSource, prometheusDataSource} {
+				reportDataSourceIndexer.Add(ds)
+			}
+			prestoTableIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+			prestoTableIndexer.Add(rawTable)
+
+			repo := &fakePushTableRepo{
+				rows: make(map[string][]*prestostore.PushTableRow),
+				err:  tt.storeErr,
+			}
+			router := newRouter(testLogger, testRand, clock.NewFakeClock(now), nil, repo, &fakeReportResultsGetter{}, nil, secrets, noopPrometheusImporterFunc,
+				time.Minute, nil,
+				listers.NewReportLister(cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})),
+				listers.NewReportDataSourceLister(reportDataSourceIndexer),
+				listers.NewReportQueryLister(cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})),
+				listers.NewPrestoTableLister(prestoTableIndexer),
+			)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			dataSourceName := tt.dataSource
+			if dataSourceName == "" {
+				dataSourceName = dataSource.Name
+			}
+			req, err := http.NewRequest(method, server.URL+APIV1PushTableEndpointPrefix+"/"+namespace+"/"+dataSourceName, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			switch tt.token {
+			case "":
+				req.Header.Set(PushTableTokenHeader, "s3cret")
+			case "-":
+			default:
+				req.Header.Set(PushTableTokenHeader, tt.token)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if !tt.noIdempotencyKey {
+				req.Header.Set(PushTableIdempotencyKeyHeader, "build-42")
+			}
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			require.Equal(t, tt.expectedStatusCode, resp.StatusCode)
+			idempotencyKey := resp.Header.Get(PushTableIdempotencyKeyHeader)
+			if tt.expectedResponse != nil || tt.storeErr != nil {
+				// the key is returned so pushes without one can be retried
+				if tt.noIdempotencyKey {
+					assert.Len(t, idempotencyKey, 32)
+				} else {
+					assert.Equal(t, "build-42", idempotencyKey)
+				}
+			}
+			if tt.expectedResponse != nil {
+				var pushResp pushTableResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&pushResp))
+				assert.Equal(t, *tt.expectedResponse, pushResp)
+			} else {
+				var errResp errorResponse
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+				assert.NotEmpty(t, errResp.Error)
+			}
+			require.Len(t, repo.rows[rawFQTableName], tt.expectedRows)
+			for _, row := range repo.rows[rawFQTableName] {
+				assert.Equal(t, now, row.IngestionTime)
+			}
+		})
+	}
+}
@@ -115,6 +115,10 @@ func (op *defaultReportingOperator) updateReportDataSource(prev, cur interface{}
 		return
 	}
 
+	if !reflect.DeepEqual(curReportDataSource.Spec, prevReportDataSource.Spec) {
+		op.forgetPrometheusEndpointSet(curReportDataSource.Namespace, curReportDataSource.Name)
+	}
+
 	// we allow periodic resyncs to trigger ReportDataSources even
 	// if they're not changed to ensure failed ones eventually get re-tried.
 	// however, if we know that it's a Prometheus ReportDataSource where the
@@ -129,6 +133,24 @@ func (op *defaultReportingOperator) updateReportDataSource(prev, cur interface{}
 			return
 		}
 	}
+	// likewise, KubernetesInventory ReportDataSources are queued for their
+	// next snapshot when the snapshot status is updated
+	if curReportDataSource.Spec.KubernetesInventory != nil {
+		sameSpec := reflect.DeepEqual(curReportDataSource.Spec, prevReportDataSource.Spec)
+		snapshotStatusChanged := !reflect.DeepEqual(curReportDataSource.Status.KubernetesInventory, prevReportDataSource.Status.KubernetesInventory)
+		if sameSpec && snapshotStatusChanged {
+			return
+		}
+	}
+	// and ExternalDatabase ReportDataSources are queued for their next
+	// check when the check status is updated
+	if curReportDataSource.Spec.ExternalDatabase != nil {
+		sameSpec := reflect.DeepEqual(curReportDataSource.Spec, prevReportDataSource.Spec)
+		checkStatusChanged := !reflect.DeepEqual(curReportDataSource.Status.ExternalDatabase, prevReportDataSource.Status.ExternalDatabase)
+		if sameSpec && checkStatusChanged {
+			return
+		}
+	}
 
 	op.logger.Infof("updating ReportDataSource %s/%s", curReportDataSource.Namespace, curReportDataSource.Name)
 	op.enqueueReportDataSource(curReportDataSource)
@@ -0,0 +1,47 @@
+package operator
+
+import (
+	"fmt"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
+)
+
+// newPrometheusRelabelConfigs compiles and validates the relabelConfigs of a
+// ReportDataSource, applying the same defaults as Prometheus.
+func newPrometheusRelabelConfigs(cfgs []metering.PrometheusRelabelConfig) ([]*relabel.Config, error) {
+	compiled := make([]*relabel.Config, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		regex := relabel.DefaultRegex
+		if cfg.Regex != nil {
+			regex = *cfg.Regex
+		}
+		re, err := relabel.NewRegexp(regex)
+		if err != nil {
+			return nil, fmt.Errorf("relabelConfigs[%d]: invalid regex %q: %v", i, regex, err)
+		}
+		c := &relabel.Config{
+			SourceLabels: cfg.SourceLabels,
+			Separator:    relabel.DefaultSeparator,
+			Regex:        re,
+			Modulus:      cfg.Modulus,
+			TargetLabel:  cfg.TargetLabel,
+			Replacement:  relabel.DefaultReplacement,
+			Action:       relabel.Action(cfg.Action),
+		}
+		if cfg.Separator != nil {
+			c.Separator = *cfg.Separator
+		}
+		if cfg.Replacement != nil {
+			c.Replacement = *cfg.Replacement
+		}
+		if c.Action == "" {
+			c.Action = relabel.Replace
+		}
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("relabelConfigs[%d]: %v", i, err)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,45 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
+)
+
+func TestNewPrometheusRelabelConfigs(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	cfgs, err := newPrometheusRelabelConfigs([]metering.PrometheusRelabelConfig{
+		{Action: metering.PrometheusRelabelLabelKeep, Regex: strPtr("__name__|namespace|pod|label_app")},
+		// action defaults to replace, and regex, separator and replacement
+		// default to the values Prometheus uses
+		{SourceLabels: []string{"namespace", "pod"}, TargetLabel: "namespaced_pod"},
+		{Action: metering.PrometheusRelabelReplace, SourceLabels: []string{"label_app"}, TargetLabel: "app", Separator: strPtr(""), Replacement: strPtr("app-$1")},
+		{Action: metering.PrometheusRelabelDrop, SourceLabels: []string{"namespace"}, Regex: strPtr("openshift-.*")},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"__name__":       "kube_pod_labels",
+		"namespace":      "default",
+		"pod":            "foo",
+		"label_app":      "web",
+		"namespaced_pod": "default;foo",
+		"app":            "app-web",
+	}, relabel.Process(map[string]string{
+		"__name__":       "kube_pod_labels",
+		"namespace":      "default",
+		"pod":            "foo",
+		"label_app":      "web",
+		"label_pod_hash": "abc123",
+	}, cfgs))
+	assert.Nil(t, relabel.Process(map[string]string{"namespace": "openshift-metering"}, cfgs))
+
+	_, err = newPrometheusRelabelConfigs([]metering.PrometheusRelabelConfig{{Action: metering.PrometheusRelabelKeep, Regex: strPtr("(")}})
+	assert.Error(t, err, "expected an invalid regex to error")
+	_, err = newPrometheusRelabelConfigs([]metering.PrometheusRelabelConfig{{Action: metering.PrometheusRelabelHashMod, TargetLabel: "shard"}})
+	assert.Error(t, err, "expected hashmod without a modulus to error")
+}
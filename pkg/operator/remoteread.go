@@ -0,0 +1,73 @@
+package operator
+
+import (
+	"fmt"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+var remoteMatchTypes = map[metering.PrometheusLabelMatchType]remote.MatchType{
+	"":                                     remote.MatchEqual,
+	metering.PrometheusLabelMatchEqual:     remote.MatchEqual,
+	metering.PrometheusLabelMatchNotEqual:  remote.MatchNotEqual,
+	metering.PrometheusLabelMatchRegexp:    remote.MatchRegexp,
+	metering.PrometheusLabelMatchNotRegexp: remote.MatchNotRegexp,
+}
+
+// remoteReadLabelMatchers converts matchers to the matchers of a remote_read
+// query, validating them the same as remote_write matchers.
+func remoteReadLabelMatchers(matchers []metering.PrometheusLabelMatcher) ([]remote.LabelMatcher, error) {
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("at least one label matcher is required")
+	}
+	if _, err := compilePrometheusLabelMatchers(matchers); err != nil {
+		return nil, err
+	}
+	remoteMatchers := make([]remote.LabelMatcher, len(matchers))
+	for i, matcher := range matchers {
+		remoteMatchers[i] = remote.LabelMatcher{
+			Type:  remoteMatchTypes[matcher.Type],
+			Name:  matcher.Name,
+			Value: matcher.Value,
+		}
+	}
+	return remoteMatchers, nil
+}
+
+func (op *defaultReportingOperator) newPromRemoteReadCfg(reportDataSource *metering.ReportDataSource) (*prestostore.RemoteReadConfig, error) {
+	remoteRead := reportDataSource.Spec.PrometheusMetricsImporter.RemoteRead
+	matchers, err := remoteReadLabelMatchers(remoteRead.Matchers)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remoteRead configuration for ReportDataSource %s: %v", reportDataSource.Name, err)
+	}
+
+	var reader prestostore.RemoteReader
+	promConfig := reportDataSource.Spec.PrometheusMetricsImporter.PrometheusConfig
+	if promConfig != nil && len(promConfig.Endpoints) != 0 {
+		reader, err = op.getPrometheusEndpointSet(reportDataSource)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		address := op.cfg.PrometheusConfig.Address
+		if promConfig != nil && promConfig.URL != "" {
+			address = promConfig.URL
+		}
+		roundTripper, err := op.newPrometheusRoundTripper()
+		if err != nil {
+			return nil, err
+		}
+		reader, err = remote.NewClient(address, roundTripper)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &prestostore.RemoteReadConfig{
+		Reader:   reader,
+		Matchers: matchers,
+		Resample: remoteRead.Resample,
+	}, nil
+}
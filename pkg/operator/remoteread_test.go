@@ -0,0 +1,32 @@
+package operator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+func TestRemoteReadLabelMatchers(t *testing.T) {
+	matchers, err := remoteReadLabelMatchers([]metering.PrometheusLabelMatcher{
+		{Name: "__name__", Value: "kube_pod_container_resource_requests"},
+		{Name: "resource", Type: metering.PrometheusLabelMatchNotEqual, Value: "memory"},
+		{Name: "namespace", Type: metering.PrometheusLabelMatchRegexp, Value: "team-.*"},
+		{Name: "node", Type: metering.PrometheusLabelMatchNotRegexp, Value: "master-.*"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []remote.LabelMatcher{
+		{Type: remote.MatchEqual, Name: "__name__", Value: "kube_pod_container_resource_requests"},
+		{Type: remote.MatchNotEqual, Name: "resource", Value: "memory"},
+		{Type: remote.MatchRegexp, Name: "namespace", Value: "team-.*"},
+		{Type: remote.MatchNotRegexp, Name: "node", Value: "master-.*"},
+	}, matchers)
+
+	_, err = remoteReadLabelMatchers(nil)
+	assert.Error(t, err, "expected at least one matcher to be required")
+	_, err = remoteReadLabelMatchers([]metering.PrometheusLabelMatcher{{Name: "namespace", Type: metering.PrometheusLabelMatchRegexp, Value: "("}})
+	assert.Error(t, err, "expected an invalid regular expression to error")
+}
@@ -0,0 +1,530 @@
+package operator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/relabel"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+)
+
+// prometheusLabelMatcher is a compiled metering.PrometheusLabelMatcher.
+type prometheusLabelMatcher struct {
+	name     string
+	negate   bool
+	value    string
+	valueRes *regexp.Regexp
+}
+
+func (m prometheusLabelMatcher) matches(lbls map[string]string) bool {
+	// like Prometheus, a missing label matches the same as an empty value
+	value := lbls[m.name]
+	var matched bool
+	if m.valueRes != nil {
+		matched = m.valueRes.MatchString(value)
+	} else {
+		matched = value == m.value
+	}
+	return matched != m.negate
+}
+
+type prometheusLabelMatchers []prometheusLabelMatcher
+
+func (ms prometheusLabelMatchers) matches(lbls map[string]string) bool {
+	for _, m := range ms {
+		if !m.matches(lbls) {
+			return false
+		}
+	}
+	return true
+}
+
+func compilePrometheusLabelMatchers(matchers []metering.PrometheusLabelMatcher) (prometheusLabelMatchers, error) {
+	compiled := make(prometheusLabelMatchers, 0, len(matchers))
+	for _, matcher := range matchers {
+		if matcher.Name == "" {
+			return nil, fmt.Errorf("label matcher name cannot be empty")
+		}
+		m := prometheusLabelMatcher{name: matcher.Name, value: matcher.Value}
+		switch matcher.Type {
+		case "", metering.PrometheusLabelMatchEqual:
+		case metering.PrometheusLabelMatchNotEqual:
+			m.negate = true
+		case metering.PrometheusLabelMatchRegexp, metering.PrometheusLabelMatchNotRegexp:
+			// regular expressions are fully anchored, the same as in PromQL
+			re, err := regexp.Compile("^(?:" + matcher.Value + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression for label matcher %s: %v", matcher.Name, err)
+			}
+			m.valueRes = re
+			m.negate = matcher.Type == metering.PrometheusLabelMatchNotRegexp
+		default:
+			return nil, fmt.Errorf("invalid type %q for label matcher %s, must be one of =, !=, =~ or !~", matcher.Type, matcher.Name)
+		}
+		compiled = append(compiled, m)
+	}
+	return compiled, nil
+}
+
+// getRemoteWriteStepSize returns the time precision recorded for the samples
+// written to a remote_write ReportDataSource.
+func getRemoteWriteStepSize(dataSource *metering.ReportDataSource, defaultStepSize time.Duration) time.Duration {
+	importer := dataSource.Spec.PrometheusMetricsImporter
+	switch {
+	case importer.RemoteWrite.StepSize != nil:
+		return importer.RemoteWrite.StepSize.Duration
+	case importer.QueryConfig != nil && importer.QueryConfig.StepSize != nil:
+		return importer.QueryConfig.StepSize.Duration
+	default:
+		return defaultStepSize
+	}
+}
+
+type remoteWriteTarget struct {
+	dataSource     *metering.ReportDataSource
+	matchers       prometheusLabelMatchers
+	relabelConfigs []*relabel.Config
+	stepSize       time.Duration
+	metrics        []*prestostore.PrometheusMetric
+	// seriesSet and rejected find series of the request with the same labels
+	// after relabeling, which are rejected since retrying won't change them.
+	seriesSet *relabel.SeriesSet
+	rejected  error
+}
+
+// remoteWriteRetryPeriod is how long the sender of a remote_write request is
+// assumed to retry it for when it fails. Prometheus retries requests until
+// their samples are truncated from its WAL, which is kept for about 2 hours.
+const remoteWriteRetryPeriod = 2 * time.Hour
+
+// remoteWriteActivity is what happened to the metrics received by
+// remote_write for a ReportDataSource since its status was last updated.
+type remoteWriteActivity struct {
+	// first and last are the earliest and newest timestamps of the metrics
+	// stored, and are zero if none were stored.
+	first, last time.Time
+	// intervals are the time ranges of the requests stored, each covering
+	// the stepSize following its newest metric.
+	intervals []prestostore.TimeInterval
+
+	lastErr     string
+	lastErrTime time.Time
+}
+
+func (a *remoteWriteActivity) merge(other *remoteWriteActivity) {
+	if !other.first.IsZero() && (a.first.IsZero() || other.first.Before(a.first)) {
+		a.first = other.first
+	}
+	if other.last.After(a.last) {
+		a.last = other.last
+	}
+	for _, interval := range other.intervals {
+		a.intervals = prestostore.AddTimeInterval(a.intervals, interval)
+	}
+	if other.lastErrTime.After(a.lastErrTime) {
+		a.lastErr = other.lastErr
+		a.lastErrTime = other.lastErrTime
+	}
+}
+
+// remoteWriteTracker records the remoteWriteActivity of each remote_write
+// ReportDataSource, by namespace/name, so the operator can update their
+// status from the metrics received, rather than by scanning their tables.
+type remoteWriteTracker struct {
+	clock clock.Clock
+
+	mu       sync.Mutex
+	activity map[string]*remoteWriteActivity
+	// stored is when the requests which failed for other ReportDataSources
+	// were stored for a ReportDataSource, by namespace/name and the digest of
+	// the request, so they aren't stored twice when the sender retries them.
+	stored map[string]time.Time
+}
+
+func newRemoteWriteTracker(clock clock.Clock) *remoteWriteTracker {
+	return &remoteWriteTracker{
+		clock:    clock,
+		activity: make(map[string]*remoteWriteActivity),
+		stored:   make(map[string]time.Time),
+	}
+}
+
+func (t *remoteWriteTracker) add(dataSource *metering.ReportDataSource, activity *remoteWriteActivity) {
+	key := dataSource.Namespace + "/" + dataSource.Name
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing, ok := t.activity[key]; ok {
+		existing.merge(activity)
+		return
+	}
+	t.activity[key] = activity
+}
+
+// recordStored records that metrics were stored in the table of dataSource.
+func (t *remoteWriteTracker) recordStored(dataSource *metering.ReportDataSource, metrics []*prestostore.PrometheusMetric, stepSize time.Duration) {
+	activity := &remoteWriteActivity{}
+	for _, metric := range metrics {
+		if activity.first.IsZero() || metric.Timestamp.Before(activity.first) {
+			activity.first = metric.Timestamp
+		}
+		if metric.Timestamp.After(activity.last) {
+			activity.last = metric.Timestamp
+		}
+	}
+	if !activity.first.IsZero() {
+		activity.intervals = []prestostore.TimeInterval{{Start: activity.first, End: activity.last.Add(stepSize)}}
+	}
+	t.add(dataSource, activity)
+}
+
+// recordFailed records that the metrics of a request couldn't be stored in
+// the table of dataSource.
+func (t *remoteWriteTracker) recordFailed(dataSource *metering.ReportDataSource, err error) {
+	t.add(dataSource, &remoteWriteActivity{
+		lastErr:     err.Error(),
+		lastErrTime: t.clock.Now().UTC(),
+	})
+}
+
+// recordRequestStored records that the request with digest was stored in the
+// table of dataSource while it failed for another ReportDataSource, so it
+// will be retried.
+func (t *remoteWriteTracker) recordRequestStored(dataSource *metering.ReportDataSource, digest string) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, storedTime := range t.stored {
+		if now.Sub(storedTime) > remoteWriteRetryPeriod {
+			delete(t.stored, key)
+		}
+	}
+	t.stored[dataSource.Namespace+"/"+dataSource.Name+"/"+digest] = now
+}
+
+// requestStored returns true if the request with digest was already stored in
+// the table of dataSource by a previous attempt.
+func (t *remoteWriteTracker) requestStored(dataSource *metering.ReportDataSource, digest string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, stored := t.stored[dataSource.Namespace+"/"+dataSource.Name+"/"+digest]
+	return stored
+}
+
+// take returns and forgets the activity of the ReportDataSource, which is
+// nil if nothing happened.
+func (t *remoteWriteTracker) take(namespace, name string) *remoteWriteActivity {
+	key := namespace + "/" + name
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	activity := t.activity[key]
+	delete(t.activity, key)
+	return activity
+}
+
+// forget drops the activity of a deleted ReportDataSource.
+func (t *remoteWriteTracker) forget(namespace, name string) {
+	t.take(namespace, name)
+}
+
+type remoteWriteFailure struct {
+	target *remoteWriteTarget
+	code   int
+	err    error
+}
+
+// prometheusRemoteWriteHandler receives metrics pushed using the Prometheus
+// remote_write protocol, and stores each series in the tables of every
+// remote_write ReportDataSource in the namespace with matchers selecting it.
+//
+// Each ReportDataSource is stored independently. If any of them can't be
+// stored, the request fails so the sender retries it, and the failure is
+// recorded in the status of the ReportDataSources which failed. The
+// ReportDataSources which were stored remember the digest of the request, so
+// they skip it when it's retried rather than storing it twice.
+func (srv *server) prometheusRemoteWriteHandler(w http.ResponseWriter, r *http.Request) {
+	logger := newRequestLogger(srv.logger, r, srv.rand)
+	namespace := chi.URLParam(r, "namespace")
+
+	if r.Method != http.MethodPost {
+		writeErrorResponse(logger, w, r, http.StatusMethodNotAllowed, "remote_write requests must use POST")
+		return
+	}
+
+	digester := sha256.New()
+	series, err := remote.DecodeWriteRequest(io.TeeReader(r.Body, digester))
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusBadRequest, "unable to decode remote_write request: %v", err)
+		return
+	}
+	digest := hex.EncodeToString(digester.Sum(nil))
+
+	dataSources, err := srv.reportDataSourceLister.ReportDataSources(namespace).List(labels.Everything())
+	if err != nil {
+		writeErrorResponse(logger, w, r, http.StatusInternalServerError, "unable to list ReportDataSources: %v", err)
+		return
+	}
+
+	var targets []*remoteWriteTarget
+	for _, dataSource := range dataSources {
+		if dataSource.Spec.PrometheusMetricsImporter == nil || dataSource.Spec.PrometheusMetricsImporter.RemoteWrite == nil {
+			continue
+		}
+		matchers, err := compilePrometheusLabelMatchers(dataSource.Spec.PrometheusMetricsImporter.RemoteWrite.Matchers)
+		if err != nil {
+			// a misconfigured ReportDataSource shouldn't prevent other
+			// ReportDataSources from receiving metrics
+			logger.WithError(err).Errorf("ignoring ReportDataSource %s with invalid remoteWrite configuration", dataSource.Name)
+			continue
+		}
+		relabelConfigs, err := newPrometheusRelabelConfigs(dataSource.Spec.PrometheusMetricsImporter.RelabelConfigs)
+		if err != nil {
+			logger.WithError(err).Errorf("ignoring ReportDataSource %s with invalid relabelConfigs", dataSource.Name)
+			continue
+		}
+		targets = append(targets, &remoteWriteTarget{
+			dataSource:     dataSource,
+			matchers:       matchers,
+			relabelConfigs: relabelConfigs,
+			stepSize:       getRemoteWriteStepSize(dataSource, srv.defaultStepSize),
+			seriesSet:      relabel.NewSeriesSet(),
+		})
+	}
+
+	for _, ts := range series {
+		for _, target := range targets {
+			if target.rejected != nil || !target.matchers.matches(ts.Labels) {
+				continue
+			}
+			// relabeling happens after matching, so matchers select series
+			// by the labels they were written with
+			seriesLabels := relabel.Process(ts.Labels, target.relabelConfigs)
+			if seriesLabels == nil {
+				continue
+			}
+			if err := target.seriesSet.Add(ts.Labels, seriesLabels); err != nil {
+				target.rejected = fmt.Errorf("rejected the remote_write request for ReportDataSource %s: %v", target.dataSource.Name, err)
+				target.metrics = nil
+				continue
+			}
+			for _, sample := range ts.Samples {
+				// NaN is used by Prometheus to mark stale series, and neither
+				// NaN nor infinities can be used when aggregating usage.
+				if math.IsNaN(sample.Value) || math.IsInf(sample.Value, 0) {
+					continue
+				}
+				target.metrics = append(target.metrics, &prestostore.PrometheusMetric{
+					Labels:    seriesLabels,
+					Amount:    sample.Value,
+					StepSize:  target.stepSize,
+					Timestamp: time.Unix(0, sample.Timestamp*int64(time.Millisecond)).UTC(),
+				})
+			}
+		}
+	}
+
+	var stored []*remoteWriteTarget
+	var failures []remoteWriteFailure
+	for _, target := range targets {
+		if target.rejected != nil {
+			// the request fails the same way each time it's retried, so
+			// it's only recorded in the status of the ReportDataSource
+			srv.remoteWriteTracker.recordFailed(target.dataSource, target.rejected)
+			logger.WithError(target.rejected).Errorf("invalid relabelConfigs for ReportDataSource %s", target.dataSource.Name)
+			continue
+		}
+		if len(target.metrics) == 0 {
+			continue
+		}
+		if srv.remoteWriteTracker.requestStored(target.dataSource, digest) {
+			logger.Debugf("skipping ReportDataSource %s which stored the retried remote_write request already", target.dataSource.Name)
+			continue
+		}
+		tableName, err := srv.getPrometheusReportDataSourceTableName(target.dataSource)
+		if err != nil {
+			failures = append(failures, remoteWriteFailure{target: target, code: http.StatusServiceUnavailable, err: err})
+			continue
+		}
+		promLabels := prometheus.Labels{
+			"reportdatasource": target.dataSource.Name,
+			"namespace":        target.dataSource.Namespace,
+			"table_name":       tableName,
+		}
+		prometheusReportDatasourceTotalPrestoStoresCounter.With(promLabels).Inc()
+		err = srv.prometheusMetricsRepo.StorePrometheusMetrics(context.Background(), tableName, target.metrics)
+		if err != nil {
+			prometheusReportDatasourceFailedPrestoStoresCounter.With(promLabels).Inc()
+			err = fmt.Errorf("unable to store prometheus metrics for ReportDataSource %s: %v", target.dataSource.Name, err)
+			failures = append(failures, remoteWriteFailure{target: target, code: http.StatusInternalServerError, err: err})
+			continue
+		}
+		stored = append(stored, target)
+		srv.remoteWriteTracker.recordStored(target.dataSource, target.metrics, target.stepSize)
+		prometheusReportDatasourceMetricsImportedCounter.With(promLabels).Add(float64(len(target.metrics)))
+		logger.WithFields(log.Fields{
+			"reportDataSource": target.dataSource.Name,
+			"tableName":        tableName,
+		}).Debugf("stored %d metrics received by remote_write", len(target.metrics))
+	}
+
+	if len(failures) != 0 {
+		// the sender retries the request, which only needs to be stored for
+		// the ReportDataSources which failed
+		for _, target := range stored {
+			srv.remoteWriteTracker.recordRequestStored(target.dataSource, digest)
+		}
+		for _, failure := range failures {
+			srv.remoteWriteTracker.recordFailed(failure.target.dataSource, failure.err)
+			logger.WithError(failure.err).Errorf("unable to store %d metrics received by remote_write for ReportDataSource %s", len(failure.target.metrics), failure.target.dataSource.Name)
+		}
+		failure := failures[len(failures)-1]
+		writeErrorResponse(logger, w, r, failure.code, "%v", failure.err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (srv *server) getPrometheusReportDataSourceTableName(dataSource *metering.ReportDataSource) (string, error) {
+	if dataSource.Status.TableRef.Name == "" {
+		return "", fmt.Errorf("ReportDataSource %s table not created yet", dataSource.Name)
+	}
+	return srv.getPrestoTableName(dataSource.Namespace, dataSource.Status.TableRef.Name)
+}
+
+// getPrestoTableName returns the fully qualified name of the table of the
+// PrestoTable name once it's created.
+func (srv *server) getPrestoTableName(namespace, name string) (string, error) {
+	prestoTable, err := srv.prestoTableLister.PrestoTables(namespace).Get(name)
+	if err != nil {
+		return "", fmt.Errorf("unable to get PrestoTable %s: %v", name, err)
+	}
+	if prestoTable.Status.TableName == "" {
+		return "", fmt.Errorf("PrestoTable %s table %s not created yet", prestoTable.Name, prestoTable.Spec.TableName)
+	}
+	tableName, err := reportingutil.FullyQualifiedTableName(prestoTable)
+	if err != nil {
+		return "", fmt.Errorf("invalid PrestoTable %s: %v", prestoTable.Name, err)
+	}
+	return tableName, nil
+}
+
+// updateRemoteWriteImportStatus adds the time ranges stored by activity to
+// the ImportedRanges of status. ImportDataEndTime is the end of the data
+// received without gaps since ImportDataStartTime, so Reports wait for gaps
+// to be filled by the sender retrying. Once a gap is older than
+// remoteWriteRetryPeriod it won't be filled anymore, so it's skipped and
+// recorded in the Gaps of status instead.
+func updateRemoteWriteImportStatus(status *metering.PrometheusMetricsImportStatus, activity *remoteWriteActivity, stepSize time.Duration, now time.Time) {
+	intervals := importedRangesToIntervals(status, stepSize)
+	for _, interval := range activity.intervals {
+		intervals = prestostore.AddTimeInterval(intervals, interval)
+	}
+	if len(intervals) == 0 {
+		return
+	}
+	status.ImportedRanges = intervalsToImportedRanges(intervals)
+
+	if !activity.first.IsZero() {
+		first, last := activity.first.UTC(), activity.last.UTC()
+		if status.EarliestImportedMetricTime == nil || first.Before(status.EarliestImportedMetricTime.Time) {
+			status.EarliestImportedMetricTime = &metav1.Time{Time: first}
+		}
+		if status.NewestImportedMetricTime == nil || last.After(status.NewestImportedMetricTime.Time) {
+			status.NewestImportedMetricTime = &metav1.Time{Time: last}
+		}
+	}
+
+	end := intervals[0].End
+	var gaps []metering.PrometheusImportGap
+	for _, interval := range intervals[1:] {
+		if now.Sub(interval.Start) < remoteWriteRetryPeriod {
+			break
+		}
+		gaps = append(gaps, metering.PrometheusImportGap{
+			Start: metav1.NewTime(end),
+			End:   metav1.NewTime(interval.Start),
+		})
+		end = interval.End
+	}
+	if len(gaps) > maxExpiredGaps {
+		gaps = gaps[len(gaps)-maxExpiredGaps:]
+	}
+	status.Gaps = gaps
+	status.ImportDataStartTime = &metav1.Time{Time: intervals[0].Start}
+	status.ImportDataEndTime = &metav1.Time{Time: end}
+}
+
+// handlePrometheusRemoteWriteDataSource updates the import status of a
+// ReportDataSource receiving metrics by remote_write using the time ranges of
+// the requests stored since it was last updated, allowing Reports depending
+// on it to run once metrics covering their reporting period have been
+// received.
+func (op *defaultReportingOperator) handlePrometheusRemoteWriteDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource) error {
+	activity := op.remoteWriteTracker.take(dataSource.Namespace, dataSource.Name)
+	if activity == nil {
+		activity = &remoteWriteActivity{}
+	}
+
+	importStatus := dataSource.Status.PrometheusMetricsImportStatus.DeepCopy()
+	if importStatus == nil {
+		importStatus = &metering.PrometheusMetricsImportStatus{}
+	}
+	now := op.clock.Now().UTC()
+	importStatus.LastImportTime = &metav1.Time{Time: now}
+	stepSize := getRemoteWriteStepSize(dataSource, op.cfg.PrometheusQueryConfig.StepSize.Duration)
+	updateRemoteWriteImportStatus(importStatus, activity, stepSize, now)
+	if importStatus.ImportDataEndTime == nil {
+		logger.Infof("no metrics received by remote_write for ReportDataSource %s yet", dataSource.Name)
+	}
+
+	remoteWriteStatus := dataSource.Status.PrometheusRemoteWrite.DeepCopy()
+	if activity.lastErr != "" {
+		if remoteWriteStatus == nil {
+			remoteWriteStatus = &metering.PrometheusRemoteWriteDataSourceStatus{}
+		}
+		remoteWriteStatus.LastStoreError = activity.lastErr
+		remoteWriteStatus.LastStoreErrorTime = &metav1.Time{Time: activity.lastErrTime}
+	}
+
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	updatedDataSource, err := updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		newDS.Status.PrometheusMetricsImportStatus = importStatus
+		newDS.Status.PrometheusRemoteWrite = remoteWriteStatus
+	})
+	if err != nil {
+		// keep the activity to merge it into the status next time
+		op.remoteWriteTracker.add(dataSource, activity)
+		return fmt.Errorf("unable to update ReportDataSource %s PrometheusMetricsImportStatus: %v", dataSource.Name, err)
+	}
+	dataSource = updatedDataSource
+
+	if err := op.queueDependentReportsForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+	if err := op.queueDependentReportDataSourcesForDataSource(dataSource); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of ReportDataSource %s", dataSource.Name)
+	}
+
+	checkDelay := op.getQueryIntervalForReportDataSource(dataSource)
+	logger.Infof("queuing remote_write Prometheus ReportDataSource %s to check for received metrics again in %s", dataSource.Name, checkDelay)
+	op.enqueueReportDataSourceAfter(dataSource, checkDelay)
+	return nil
+}
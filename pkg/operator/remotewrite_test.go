@@ -0,0 +1,465 @@
+package operator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	listers "github.com/kube-reporting/metering-operator/pkg/generated/listers/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/prometheus/remote"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+func TestPrometheusLabelMatchers(t *testing.T) {
+	lbls := map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "cpu", "namespace": "default"}
+
+	tests := map[string]struct {
+		matchers    []metering.PrometheusLabelMatcher
+		expectMatch bool
+		expectErr   bool
+	}{
+		"no matchers": {
+			expectMatch: true,
+		},
+		"equal": {
+			matchers:    []metering.PrometheusLabelMatcher{{Name: "resource", Value: "cpu"}},
+			expectMatch: true,
+		},
+		"equal mismatch": {
+			matchers: []metering.PrometheusLabelMatcher{{Name: "resource", Type: metering.PrometheusLabelMatchEqual, Value: "memory"}},
+		},
+		"not equal": {
+			matchers:    []metering.PrometheusLabelMatcher{{Name: "resource", Type: metering.PrometheusLabelMatchNotEqual, Value: "memory"}},
+			expectMatch: true,
+		},
+		"missing label matches empty value": {
+			matchers:    []metering.PrometheusLabelMatcher{{Name: "node", Value: ""}},
+			expectMatch: true,
+		},
+		"regexp is anchored": {
+			matchers: []metering.PrometheusLabelMatcher{{Name: "__name__", Type: metering.PrometheusLabelMatchRegexp, Value: "kube_pod"}},
+		},
+		"regexp": {
+			matchers:    []metering.PrometheusLabelMatcher{{Name: "__name__", Type: metering.PrometheusLabelMatchRegexp, Value: "kube_pod_.*"}},
+			expectMatch: true,
+		},
+		"not regexp": {
+			matchers:    []metering.PrometheusLabelMatcher{{Name: "namespace", Type: metering.PrometheusLabelMatchNotRegexp, Value: "openshift-.*"}},
+			expectMatch: true,
+		},
+		"all matchers must match": {
+			matchers: []metering.PrometheusLabelMatcher{
+				{Name: "resource", Value: "cpu"},
+				{Name: "namespace", Value: "other"},
+			},
+		},
+		"invalid regexp": {
+			matchers:  []metering.PrometheusLabelMatcher{{Name: "namespace", Type: metering.PrometheusLabelMatchRegexp, Value: "("}},
+			expectErr: true,
+		},
+		"invalid type": {
+			matchers:  []metering.PrometheusLabelMatcher{{Name: "namespace", Type: "==", Value: "default"}},
+			expectErr: true,
+		},
+		"empty name": {
+			matchers:  []metering.PrometheusLabelMatcher{{Value: "default"}},
+			expectErr: true,
+		},
+	}
+
+	for testName, tt := range tests {
+		tt := tt
+		t.Run(testName, func(t *testing.T) {
+			matchers, err := compilePrometheusLabelMatchers(tt.matchers)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectMatch, matchers.matches(lbls))
+		})
+	}
+}
+
+func TestPrometheusRemoteWriteHandler(t *testing.T) {
+	const (
+		namespace      = "default"
+		testCatalog    = "hive"
+		testSchema     = "metering"
+		cpuTableName   = "datasource_default_pod_request_cpu"
+		memTableName   = "datasource_default_pod_request_memory"
+		cpuFQTableName = testCatalog + "." + testSchema + "." + cpuTableName
+		memFQTableName = testCatalog + "." + testSchema + "." + memTableName
+	)
+
+	newRemoteWriteDataSource := func(name, tableName, resource string, stepSize *time.Duration) (*metering.ReportDataSource, *metering.PrestoTable) {
+		ds := testhelpers.NewReportDataSource(name, namespace)
+		ds.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{
+			RemoteWrite: &metering.PrometheusRemoteWriteConfig{
+				Matchers: []metering.PrometheusLabelMatcher{
+					{Name: "__name__", Value: "kube_pod_container_resource_requests"},
+					{Name: "resource", Value: resource},
+				},
+			},
+		}
+		if stepSize != nil {
+			ds.Spec.PrometheusMetricsImporter.RemoteWrite.StepSize = &metav1.Duration{Duration: *stepSize}
+		}
+		table := testhelpers.NewPrestoTable(tableName, namespace, testCatalog, testSchema, prestostore.PrometheusMetricPrestoAllColumns)
+		ds.Status.TableRef.Name = table.Name
+		return ds, table
+	}
+
+	cpuStepSize := 30 * time.Second
+	cpuDataSource, cpuTable := newRemoteWriteDataSource("pod-request-cpu", cpuTableName, "cpu", &cpuStepSize)
+	memDataSource, memTable := newRemoteWriteDataSource("pod-request-memory", memTableName, "memory", nil)
+	noTableDataSource, _ := newRemoteWriteDataSource("pod-request-gpu", "", "gpu", nil)
+	noTableDataSource.Status.TableRef.Name = ""
+	pullDataSource := testhelpers.NewReportDataSource("pod-request-cpu-pull", namespace)
+	pullDataSource.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{Query: "up"}
+
+	timestamp := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC)
+	series := []remote.TimeSeries{
+		{
+			Labels: map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "cpu", "pod": "foo"},
+			Samples: []remote.Sample{
+				{Value: 0.5, Timestamp: timestamp.UnixNano() / int64(time.Millisecond)},
+				{Value: math.NaN(), Timestamp: timestamp.Add(time.Minute).UnixNano() / int64(time.Millisecond)},
+			},
+		},
+		{
+			Labels:  map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "memory", "pod": "foo"},
+			Samples: []remote.Sample{{Value: 1024, Timestamp: timestamp.UnixNano() / int64(time.Millisecond)}},
+		},
+		{
+			Labels:  map[string]string{"__name__": "up", "job": "kubelet"},
+			Samples: []remote.Sample{{Value: 1, Timestamp: timestamp.UnixNano() / int64(time.Millisecond)}},
+		},
+	}
+
+	// labelDropDataSource stores the cpu series without their pod label,
+	// which is the only label telling them apart
+	labelDropDataSource := cpuDataSource.DeepCopy()
+	podLabel := "pod"
+	labelDropDataSource.Spec.PrometheusMetricsImporter.RelabelConfigs = []metering.PrometheusRelabelConfig{
+		{Action: metering.PrometheusRelabelLabelDrop, Regex: &podLabel},
+	}
+	otherPodSeries := remote.TimeSeries{
+		Labels:  map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "cpu", "pod": "bar"},
+		Samples: []remote.Sample{{Value: 0.25, Timestamp: timestamp.UnixNano() / int64(time.Millisecond)}},
+	}
+
+	cpuIntervals := []prestostore.TimeInterval{{Start: timestamp, End: timestamp.Add(cpuStepSize)}}
+	memIntervals := []prestostore.TimeInterval{{Start: timestamp, End: timestamp.Add(time.Minute)}}
+
+	tests := map[string]struct {
+		method             string
+		body               []byte
+		dataSources        []*metering.ReportDataSource
+		storeErr           error
+		storeErrs          map[string]error
+		expectedStatusCode int
+		// retry sends the request again once the store errors are gone,
+		// which must succeed.
+		retry           bool
+		expectedMetrics map[string][]*prestostore.PrometheusMetric
+		// expectedActivity is the activity recorded for each ReportDataSource
+		// by name, with the error messages of failures replaced by "error".
+		expectedActivity map[string]*remoteWriteActivity
+	}{
+		"stores matching series": {
+			body:               remote.EncodeWriteRequest(series),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, memDataSource, pullDataSource},
+			expectedStatusCode: http.StatusNoContent,
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				cpuFQTableName: {
+					{Labels: series[0].Labels, Amount: 0.5, StepSize: cpuStepSize, Timestamp: timestamp},
+				},
+				memFQTableName: {
+					{Labels: series[1].Labels, Amount: 1024, StepSize: time.Minute, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name: {first: timestamp, last: timestamp, intervals: cpuIntervals},
+				memDataSource.Name: {first: timestamp, last: timestamp, intervals: memIntervals},
+			},
+		},
+		"no table yet for matching series": {
+			body: remote.EncodeWriteRequest(append(series, remote.TimeSeries{
+				Labels:  map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "gpu"},
+				Samples: []remote.Sample{{Value: 1, Timestamp: 0}},
+			})),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, noTableDataSource},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				cpuFQTableName: {
+					{Labels: series[0].Labels, Amount: 0.5, StepSize: cpuStepSize, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name:     {first: timestamp, last: timestamp, intervals: cpuIntervals},
+				noTableDataSource.Name: {lastErr: "error", lastErrTime: now},
+			},
+		},
+		"no table yet for all matching series": {
+			body: remote.EncodeWriteRequest([]remote.TimeSeries{{
+				Labels:  map[string]string{"__name__": "kube_pod_container_resource_requests", "resource": "gpu"},
+				Samples: []remote.Sample{{Value: 1, Timestamp: 0}},
+			}}),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, noTableDataSource},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedMetrics:    map[string][]*prestostore.PrometheusMetric{},
+			expectedActivity: map[string]*remoteWriteActivity{
+				noTableDataSource.Name: {lastErr: "error", lastErrTime: now},
+			},
+		},
+		"no table yet for unmatched series": {
+			body:               remote.EncodeWriteRequest(series[:1]),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, noTableDataSource},
+			expectedStatusCode: http.StatusNoContent,
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				cpuFQTableName: {
+					{Labels: series[0].Labels, Amount: 0.5, StepSize: cpuStepSize, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name: {first: timestamp, last: timestamp, intervals: cpuIntervals},
+			},
+		},
+		"invalid body": {
+			body:               []byte("not a remote write request"),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedMetrics:    map[string][]*prestostore.PrometheusMetric{},
+		},
+		"store error": {
+			body:               remote.EncodeWriteRequest(series),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource},
+			storeErr:           errors.New("mock database had an error"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMetrics:    map[string][]*prestostore.PrometheusMetric{},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name: {lastErr: "error", lastErrTime: now},
+			},
+		},
+		"store error for one table": {
+			body:               remote.EncodeWriteRequest(series),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, memDataSource},
+			storeErrs:          map[string]error{cpuFQTableName: errors.New("mock database had an error")},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				memFQTableName: {
+					{Labels: series[1].Labels, Amount: 1024, StepSize: time.Minute, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name: {lastErr: "error", lastErrTime: now},
+				memDataSource.Name: {first: timestamp, last: timestamp, intervals: memIntervals},
+			},
+		},
+		"retried store error for one table": {
+			body:               remote.EncodeWriteRequest(series),
+			dataSources:        []*metering.ReportDataSource{cpuDataSource, memDataSource},
+			storeErrs:          map[string]error{cpuFQTableName: errors.New("mock database had an error")},
+			expectedStatusCode: http.StatusInternalServerError,
+			retry:              true,
+			// the retry only stores the metrics of the table which failed
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				cpuFQTableName: {
+					{Labels: series[0].Labels, Amount: 0.5, StepSize: cpuStepSize, Timestamp: timestamp},
+				},
+				memFQTableName: {
+					{Labels: series[1].Labels, Amount: 1024, StepSize: time.Minute, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				cpuDataSource.Name: {first: timestamp, last: timestamp, intervals: cpuIntervals, lastErr: "error", lastErrTime: now},
+				memDataSource.Name: {first: timestamp, last: timestamp, intervals: memIntervals},
+			},
+		},
+		"duplicate series after relabeling": {
+			body:               remote.EncodeWriteRequest(append(series, otherPodSeries)),
+			dataSources:        []*metering.ReportDataSource{labelDropDataSource, memDataSource},
+			expectedStatusCode: http.StatusNoContent,
+			// retrying the request would be rejected again, so only the
+			// other ReportDataSources store it
+			expectedMetrics: map[string][]*prestostore.PrometheusMetric{
+				memFQTableName: {
+					{Labels: series[1].Labels, Amount: 1024, StepSize: time.Minute, Timestamp: timestamp},
+				},
+			},
+			expectedActivity: map[string]*remoteWriteActivity{
+				labelDropDataSource.Name: {lastErr: "error", lastErrTime: now},
+				memDataSource.Name:       {first: timestamp, last: timestamp, intervals: memIntervals},
+			},
+		},
+		"wrong method": {
+			method:             http.MethodGet,
+			dataSources:        []*metering.ReportDataSource{cpuDataSource},
+			expectedStatusCode: http.StatusMethodNotAllowed,
+			expectedMetrics:    map[string][]*prestostore.PrometheusMetric{},
+		},
+	}
+
+	for testName, tt := range tests {
+		tt := tt
+		t.Run(testName, func(t *testing.T) {
+			reportDataSourceIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+			prestoTableIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+			for _, ds := range tt.dataSources {
+				reportDataSourceIndexer.Add(ds)
+			}
+			prestoTableIndexer.Add(cpuTable)
+			prestoTableIndexer.Add(memTable)
+
+			repo := &fakePrometheusMetricsRepo{
+				metrics:   make(map[string][]*prestostore.PrometheusMetric),
+				err:       tt.storeErr,
+				storeErrs: tt.storeErrs,
+			}
+			tracker := newRemoteWriteTracker(clock.NewFakeClock(now))
+			router := newRouter(testLogger, testRand, clock.NewFakeClock(now), repo, nil, &fakeReportResultsGetter{}, nil, nil, noopPrometheusImporterFunc,
+				time.Minute, tracker,
+				listers.NewReportLister(cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})),
+				listers.NewReportDataSourceLister(reportDataSourceIndexer),
+				listers.NewReportQueryLister(cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})),
+				listers.NewPrestoTableLister(prestoTableIndexer),
+			)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			send := func(expectedStatusCode int) {
+				req, err := http.NewRequest(method, server.URL+path.Join(APIV1PrometheusRemoteWriteEndpointPrefix, namespace), bytes.NewReader(tt.body))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", remote.ContentType)
+				req.Header.Set("Content-Encoding", remote.ContentEncoding)
+				resp, err := server.Client().Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				require.Equal(t, expectedStatusCode, resp.StatusCode)
+				if resp.StatusCode != http.StatusNoContent {
+					var errResp errorResponse
+					assert.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+					assert.NotEmpty(t, errResp.Error)
+				}
+			}
+			send(tt.expectedStatusCode)
+			if tt.retry {
+				repo.err = nil
+				repo.storeErrs = nil
+				send(http.StatusNoContent)
+			}
+			assert.Equal(t, tt.expectedMetrics, repo.metrics)
+
+			activity := make(map[string]*remoteWriteActivity)
+			for _, ds := range tt.dataSources {
+				if a := tracker.take(ds.Namespace, ds.Name); a != nil {
+					if a.lastErr != "" {
+						a.lastErr = "error"
+					}
+					activity[ds.Name] = a
+				}
+			}
+			if tt.expectedActivity == nil {
+				tt.expectedActivity = map[string]*remoteWriteActivity{}
+			}
+			assert.Equal(t, tt.expectedActivity, activity)
+		})
+	}
+}
+
+func TestUpdateRemoteWriteImportStatus(t *testing.T) {
+	stepSize := time.Minute
+	start := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(6 * time.Hour)
+	// interval returns the interval of a request with samples from
+	// start+from to start+to
+	interval := func(from, to time.Duration) prestostore.TimeInterval {
+		return prestostore.TimeInterval{Start: start.Add(from), End: start.Add(to).Add(stepSize)}
+	}
+	importedRange := func(from, to time.Duration) metering.PrometheusImportTimeRange {
+		return metering.PrometheusImportTimeRange{Start: metav1.NewTime(start.Add(from)), End: metav1.NewTime(start.Add(to))}
+	}
+	timePtr := func(d time.Duration) *metav1.Time {
+		return &metav1.Time{Time: start.Add(d)}
+	}
+
+	tests := map[string]struct {
+		status    metering.PrometheusMetricsImportStatus
+		intervals []prestostore.TimeInterval
+		expected  metering.PrometheusMetricsImportStatus
+	}{
+		"nothing received": {},
+		"contiguous requests": {
+			intervals: []prestostore.TimeInterval{interval(0, 59*time.Minute), interval(time.Hour, 119*time.Minute)},
+			expected: metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime: timePtr(0),
+				ImportDataEndTime:   timePtr(2 * time.Hour),
+				ImportedRanges:      []metering.PrometheusImportTimeRange{importedRange(0, 2*time.Hour)},
+			},
+		},
+		"gap the sender can still fill": {
+			intervals: []prestostore.TimeInterval{interval(3*time.Hour, 4*time.Hour), interval(5*time.Hour, 5*time.Hour)},
+			expected: metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime: timePtr(3 * time.Hour),
+				ImportDataEndTime:   timePtr(4*time.Hour + stepSize),
+				ImportedRanges:      []metering.PrometheusImportTimeRange{importedRange(3*time.Hour, 4*time.Hour+stepSize), importedRange(5*time.Hour, 5*time.Hour+stepSize)},
+			},
+		},
+		"gap older than the retry period": {
+			intervals: []prestostore.TimeInterval{interval(0, time.Hour), interval(2*time.Hour, 3*time.Hour), interval(5*time.Hour, 5*time.Hour)},
+			expected: metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime: timePtr(0),
+				ImportDataEndTime:   timePtr(3*time.Hour + stepSize),
+				ImportedRanges:      []metering.PrometheusImportTimeRange{importedRange(0, time.Hour+stepSize), importedRange(2*time.Hour, 3*time.Hour+stepSize), importedRange(5*time.Hour, 5*time.Hour+stepSize)},
+				Gaps:                []metering.PrometheusImportGap{{Start: *timePtr(time.Hour + stepSize), End: *timePtr(2 * time.Hour)}},
+			},
+		},
+		"gap filled by a retried request": {
+			status: metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime: timePtr(3 * time.Hour),
+				ImportDataEndTime:   timePtr(4 * time.Hour),
+				ImportedRanges:      []metering.PrometheusImportTimeRange{importedRange(3*time.Hour, 4*time.Hour), importedRange(5*time.Hour, 5*time.Hour+stepSize)},
+			},
+			intervals: []prestostore.TimeInterval{{Start: start.Add(4 * time.Hour), End: start.Add(5 * time.Hour)}},
+			expected: metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime: timePtr(3 * time.Hour),
+				ImportDataEndTime:   timePtr(5*time.Hour + stepSize),
+				ImportedRanges:      []metering.PrometheusImportTimeRange{importedRange(3*time.Hour, 5*time.Hour+stepSize)},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			activity := &remoteWriteActivity{}
+			for _, interval := range tt.intervals {
+				activity.merge(&remoteWriteActivity{intervals: []prestostore.TimeInterval{interval}})
+			}
+			status := tt.status.DeepCopy()
+			updateRemoteWriteImportStatus(status, activity, stepSize, now)
+			assert.Equal(t, &tt.expected, status)
+		})
+	}
+}
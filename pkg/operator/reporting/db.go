@@ -2,6 +2,7 @@ package reporting
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/kube-reporting/metering-operator/pkg/db"
@@ -12,6 +13,7 @@ import (
 type HiveTableManager interface {
 	CreateTable(params hive.TableParameters, ignoreExists bool) error
 	DropTable(dbName, tableName string, ignoreNotExists bool) error
+	AddColumns(dbName, tableName string, columns []hive.Column) error
 }
 
 type HiveDatabaseManager interface {
@@ -22,6 +24,9 @@ type HiveDatabaseManager interface {
 type HivePartitionManager interface {
 	AddPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error
 	DropPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error
+	// SetPartitionLocation points an existing partition at a new location,
+	// replacing the data of the partition in a single metastore operation.
+	SetPartitionLocation(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error
 }
 
 type HiveManager struct {
@@ -40,6 +45,10 @@ func (m *HiveManager) DropTable(dbName, tableName string, ignoreNotExists bool)
 	return hive.ExecuteDropTable(m.execer, dbName, tableName, ignoreNotExists)
 }
 
+func (m *HiveManager) AddColumns(dbName, tableName string, columns []hive.Column) error {
+	return hive.ExecuteAddColumns(m.execer, dbName, tableName, columns)
+}
+
 func (m *HiveManager) CreateDatabase(params hive.DatabaseParameters) error {
 	return hive.ExecuteCreateDatabase(m.execer, params)
 }
@@ -52,7 +61,7 @@ func (m *HiveManager) AddPartition(dbName, tableName string, partitionColumns []
 	partitionSpecStr := FmtPartitionSpec(partitionColumns, partition.PartitionSpec)
 	locationStr := ""
 	if partition.Location != "" {
-		locationStr = fmt.Sprintf("LOCATION '%s'", partition.Location)
+		locationStr = fmt.Sprintf("LOCATION %s", hive.QuoteString(partition.Location))
 	}
 	_, err := m.execer.Exec(fmt.Sprintf("ALTER TABLE %s.%s ADD IF NOT EXISTS PARTITION (%s) %s", dbName, tableName, partitionSpecStr, locationStr))
 	return err
@@ -64,13 +73,30 @@ func (m *HiveManager) DropPartition(dbName, tableName string, partitionColumns [
 	return err
 }
 
+func (m *HiveManager) SetPartitionLocation(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error {
+	if partition.Location == "" {
+		return fmt.Errorf("cannot set the location of partition (%s) of table %s.%s to an empty location", FmtPartitionSpec(partitionColumns, partition.PartitionSpec), dbName, tableName)
+	}
+	partitionSpecStr := FmtPartitionSpec(partitionColumns, partition.PartitionSpec)
+	_, err := m.execer.Exec(fmt.Sprintf("ALTER TABLE %s.%s PARTITION (%s) SET LOCATION %s", dbName, tableName, partitionSpecStr, hive.QuoteString(partition.Location)))
+	return err
+}
+
+// numericPartitionValueRegexp matches the values of partition columns which
+// are written without quotes.
+var numericPartitionValueRegexp = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// FmtPartitionSpec returns the partition spec of a PARTITION clause. The
+// values of string columns, and any values of other columns which aren't
+// numbers, are quoted and escaped, since they may come from paths or other
+// input which isn't trusted.
 func FmtPartitionSpec(partitionColumns []hive.Column, partSpec hive.PartitionSpec) string {
 	var partitionVals []string
 	for _, col := range partitionColumns {
 		val := partSpec[col.Name]
 		// Quote strings
-		if strings.ToLower(col.Type) == "string" {
-			val = fmt.Sprintf("'%s'", val)
+		if strings.ToLower(col.Type) == "string" || !numericPartitionValueRegexp.MatchString(val) {
+			val = hive.QuoteString(val)
 		}
 		partitionVals = append(partitionVals, fmt.Sprintf("`%s`=%s", col.Name, val))
 	}
@@ -82,6 +108,7 @@ type PrestoTableManager interface {
 	CreateTableAs(catalog, schema, tableName string, columns []presto.Column, comment string, properties map[string]string, ignoreExists bool, query string) error
 	DropTable(catalog, schema, tableName string, ignoreNotExists bool) error
 	QueryMetadata(catalog, schema, tableName string) ([]presto.Column, error)
+	ListCatalogs() ([]string, error)
 
 	CreateView(catalog, schema, viewName, query string) error
 	DropView(catalog, schema, viewName string, ignoreNotExists bool) error
@@ -118,3 +145,7 @@ func (c *PrestoTableManagerImpl) DropView(catalog, schema, viewName string, igno
 func (c *PrestoTableManagerImpl) QueryMetadata(catalog, schema, tableName string) ([]presto.Column, error) {
 	return presto.QueryMetadata(c.queryer, catalog, schema, tableName)
 }
+
+func (c *PrestoTableManagerImpl) ListCatalogs() ([]string, error) {
+	return presto.ListCatalogs(c.queryer)
+}
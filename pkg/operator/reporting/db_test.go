@@ -0,0 +1,59 @@
+package reporting
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+)
+
+// recordingExecer records the queries executed instead of running them.
+type recordingExecer struct {
+	queries []string
+}
+
+func (e *recordingExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	return nil, nil
+}
+
+func (e *recordingExecer) Close() error {
+	return nil
+}
+
+func TestHiveManagerAddPartition(t *testing.T) {
+	execer := &recordingExecer{}
+	m := NewHiveManager(execer)
+	columns := []hive.Column{{Name: "month", Type: "string"}, {Name: "day", Type: "int"}}
+	require.NoError(t, m.AddPartition("metering", "datasource_files", columns, hive.TablePartition{
+		PartitionSpec: hive.PartitionSpec{"month": "2024-01'), PARTITION (`month`='x", "day": "1 OR 1=1"},
+		Location:      "s3a://costs/it's/",
+	}))
+	require.NoError(t, m.AddPartition("metering", "datasource_files", columns, hive.TablePartition{
+		PartitionSpec: hive.PartitionSpec{"month": "2024-01", "day": "1"},
+	}))
+	assert.Equal(t, []string{
+		"ALTER TABLE metering.datasource_files ADD IF NOT EXISTS PARTITION (`month`='2024-01\\'), PARTITION (`month`=\\'x', `day`='1 OR 1=1') LOCATION 's3a://costs/it\\'s/'",
+		"ALTER TABLE metering.datasource_files ADD IF NOT EXISTS PARTITION (`month`='2024-01', `day`=1) ",
+	}, execer.queries)
+}
+
+func TestHiveManagerSetPartitionLocation(t *testing.T) {
+	execer := &recordingExecer{}
+	m := NewHiveManager(execer)
+	columns := []hive.Column{{Name: "billing_period_start", Type: "string"}}
+	partition := hive.TablePartition{
+		PartitionSpec: hive.PartitionSpec{"billing_period_start": "20231101"},
+		Location:      "s3a://billing/cur/20231101-20231201/it's-new/",
+	}
+	require.NoError(t, m.SetPartitionLocation("metering", "datasource_aws", columns, partition))
+	assert.Equal(t, []string{
+		`ALTER TABLE metering.datasource_aws PARTITION (` + "`billing_period_start`" + `='20231101') SET LOCATION 's3a://billing/cur/20231101-20231201/it\'s-new/'`,
+	}, execer.queries)
+
+	partition.Location = ""
+	assert.Error(t, m.SetPartitionLocation("metering", "datasource_aws", columns, partition))
+}
@@ -42,17 +42,15 @@ func NewDependencyResolver(
 }
 
 func (resolver *DependencyResolver) ResolveDependencies(namespace string, inputDefs []metering.ReportQueryInputDefinition, inputVals []metering.ReportQueryInputValue) (*DependencyResolutionResult, error) {
-	resolverCtx := &resolverContext{
-		reportAccumulator:     make(map[string]*metering.Report),
-		queryAccumulator:      make(map[string]*metering.ReportQuery),
-		datasourceAccumulator: make(map[string]*metering.ReportDataSource),
-		inputValues:           make(map[string]interface{}),
-	}
-	err := resolver.resolveDependencies(namespace, resolverCtx, inputDefs, inputVals, 0, maxDepth)
+	resolverCtx := newResolverContext()
+	err := resolver.resolveDependencies(namespace, resolverCtx, "", inputDefs, inputVals, 0, maxDepth)
 	if err != nil {
 		return nil, err
 	}
+	return resolverCtx.result(), nil
+}
 
+func (resolverCtx *resolverContext) result() *DependencyResolutionResult {
 	deps := &ReportQueryDependencies{
 		ReportQueries:     make([]*metering.ReportQuery, 0, len(resolverCtx.queryAccumulator)),
 		ReportDataSources: make([]*metering.ReportDataSource, 0, len(resolverCtx.datasourceAccumulator)),
@@ -82,7 +80,7 @@ func (resolver *DependencyResolver) ResolveDependencies(namespace string, inputD
 	return &DependencyResolutionResult{
 		Dependencies: deps,
 		InputValues:  resolverCtx.inputValues,
-	}, nil
+	}
 }
 
 type resolverContext struct {
@@ -90,11 +88,60 @@ type resolverContext struct {
 	queryAccumulator      map[string]*metering.ReportQuery
 	datasourceAccumulator map[string]*metering.ReportDataSource
 	inputValues           map[string]interface{}
+	// edges records which resource depends on which, keyed by the ID of
+	// the dependent resource. See DependencyNodeID.
+	edges map[string][]string
+	// errors is only set when resolving a DependencyGraph, and records why
+	// each resource which couldn't be resolved failed, keyed by it's ID,
+	// so that resolution continues past the broken dependency.
+	errors map[string]error
+}
+
+func newResolverContext() *resolverContext {
+	return &resolverContext{
+		reportAccumulator:     make(map[string]*metering.Report),
+		queryAccumulator:      make(map[string]*metering.ReportQuery),
+		datasourceAccumulator: make(map[string]*metering.ReportDataSource),
+		inputValues:           make(map[string]interface{}),
+		edges:                 make(map[string][]string),
+	}
+}
+
+// newGraphResolverContext returns a resolverContext which records the
+// resources that can't be resolved instead of returning an error.
+func newGraphResolverContext() *resolverContext {
+	resolverCtx := newResolverContext()
+	resolverCtx.errors = make(map[string]error)
+	return resolverCtx
+}
+
+// fail records that the resource with the given ID couldn't be resolved if
+// resolverCtx is resolving a DependencyGraph, otherwise it returns err.
+func (resolverCtx *resolverContext) fail(id string, err error) error {
+	if resolverCtx.errors == nil || id == "" {
+		return err
+	}
+	if _, exists := resolverCtx.errors[id]; !exists {
+		resolverCtx.errors[id] = err
+	}
+	return nil
 }
 
-func (resolver *DependencyResolver) resolveDependencies(namespace string, resolverCtx *resolverContext, inputDefs []metering.ReportQueryInputDefinition, inputVals []metering.ReportQueryInputValue, depth, maxDepth int) error {
+func (resolverCtx *resolverContext) addEdge(from, to string) {
+	if from == "" {
+		return
+	}
+	for _, existing := range resolverCtx.edges[from] {
+		if existing == to {
+			return
+		}
+	}
+	resolverCtx.edges[from] = append(resolverCtx.edges[from], to)
+}
+
+func (resolver *DependencyResolver) resolveDependencies(namespace string, resolverCtx *resolverContext, parent string, inputDefs []metering.ReportQueryInputDefinition, inputVals []metering.ReportQueryInputValue, depth, maxDepth int) error {
 	if depth >= maxDepth {
-		return fmt.Errorf("detected a cycle at depth %d", depth)
+		return resolverCtx.fail(parent, fmt.Errorf("detected a cycle at depth %d", depth))
 	}
 	depth += 1
 
@@ -113,7 +160,7 @@ func (resolver *DependencyResolver) resolveDependencies(namespace string, resolv
 			}
 		}
 		if !seen {
-			return fmt.Errorf("invalid input %q, supported inputs: %s", val.Name, strings.Join(supportedInputs, " ,"))
+			return resolverCtx.fail(parent, fmt.Errorf("invalid input %q, supported inputs: %s", val.Name, strings.Join(supportedInputs, " ,")))
 		}
 		givenInputs[val.Name] = val
 	}
@@ -158,7 +205,7 @@ func (resolver *DependencyResolver) resolveDependencies(namespace string, resolv
 			if err == nil {
 				name := dst.(*string)
 				if name != nil {
-					err = resolver.resolveDataSource(namespace, resolverCtx, inputVals, *name, depth, maxDepth)
+					err = resolver.resolveDataSource(namespace, resolverCtx, parent, inputVals, *name, depth, maxDepth)
 					if err != nil {
 						return err
 					}
@@ -170,7 +217,7 @@ func (resolver *DependencyResolver) resolveDependencies(namespace string, resolv
 			if err == nil {
 				name := dst.(*string)
 				if name != nil {
-					err = resolver.resolveQuery(namespace, resolverCtx, inputVals, *name, depth, maxDepth)
+					err = resolver.resolveQuery(namespace, resolverCtx, parent, inputVals, *name, depth, maxDepth)
 					if err != nil {
 						return err
 					}
@@ -182,35 +229,44 @@ func (resolver *DependencyResolver) resolveDependencies(namespace string, resolv
 			if err == nil {
 				name := dst.(*string)
 				if name != nil {
-					err = resolver.resolveReport(namespace, resolverCtx, inputVals, *name, depth, maxDepth)
+					err = resolver.resolveReport(namespace, resolverCtx, parent, inputVals, *name, depth, maxDepth)
 					if err != nil {
 						return err
 					}
 				}
 			}
 		default:
-			return fmt.Errorf("unsupported input type %s", inputType)
+			if err := resolverCtx.fail(parent, fmt.Errorf("unsupported input type %s", inputType)); err != nil {
+				return err
+			}
+			continue
 		}
 		if err != nil {
-			return fmt.Errorf("inputs Name: %s is not valid a '%s': value: '%s', err: %s", def.Name, inputType, string(*inputVal), err)
+			err = fmt.Errorf("inputs Name: %s is not valid a '%s': value: '%s', err: %s", def.Name, inputType, string(*inputVal), err)
+			if err := resolverCtx.fail(parent, err); err != nil {
+				return err
+			}
+			continue
 		}
 		resolverCtx.inputValues[def.Name] = dst
 	}
 	return nil
 }
 
-func (resolver *DependencyResolver) resolveQuery(namespace string, resolverCtx *resolverContext, inputVals []metering.ReportQueryInputValue, queryName string, depth, maxDepth int) error {
+func (resolver *DependencyResolver) resolveQuery(namespace string, resolverCtx *resolverContext, parent string, inputVals []metering.ReportQueryInputValue, queryName string, depth, maxDepth int) error {
+	queryID := DependencyNodeID(DependencyNodeReportQuery, queryName)
+	resolverCtx.addEdge(parent, queryID)
 	if _, exists := resolverCtx.queryAccumulator[queryName]; exists {
 		return nil
 	}
 	// fetch the query
 	query, err := resolver.queryGetter.GetReportQuery(namespace, queryName)
 	if err != nil {
-		return err
+		return resolverCtx.fail(queryID, err)
 	}
 	// Resolve the dependencies of the reportQuery.
 	// We pass nil for the inputValues to resolverDependencies to avoid cycles.
-	err = resolver.resolveDependencies(namespace, resolverCtx, query.Spec.Inputs, nil, depth, maxDepth)
+	err = resolver.resolveDependencies(namespace, resolverCtx, DependencyNodeID(DependencyNodeReportQuery, query.Name), query.Spec.Inputs, nil, depth, maxDepth)
 	if err != nil {
 		return err
 	}
@@ -218,19 +274,21 @@ func (resolver *DependencyResolver) resolveQuery(namespace string, resolverCtx *
 	return nil
 }
 
-func (resolver *DependencyResolver) resolveDataSource(namespace string, resolverCtx *resolverContext, inputVals []metering.ReportQueryInputValue, dsName string, depth, maxDepth int) error {
+func (resolver *DependencyResolver) resolveDataSource(namespace string, resolverCtx *resolverContext, parent string, inputVals []metering.ReportQueryInputValue, dsName string, depth, maxDepth int) error {
+	dsID := DependencyNodeID(DependencyNodeReportDataSource, dsName)
+	resolverCtx.addEdge(parent, dsID)
 	if _, exists := resolverCtx.datasourceAccumulator[dsName]; exists {
 		return nil
 	}
 	// fetch the datasource
 	datasource, err := resolver.dataSourceGetter.GetReportDataSource(namespace, dsName)
 	if err != nil {
-		return err
+		return resolverCtx.fail(dsID, err)
 	}
 	// if the datasource is a Query datasource, lookup the query it
 	// depends on and resolve it's dependencies
 	if datasource.Spec.ReportQueryView != nil {
-		err = resolver.resolveQuery(namespace, resolverCtx, inputVals, datasource.Spec.ReportQueryView.QueryName, depth, maxDepth)
+		err = resolver.resolveQuery(namespace, resolverCtx, DependencyNodeID(DependencyNodeReportDataSource, datasource.Name), inputVals, datasource.Spec.ReportQueryView.QueryName, depth, maxDepth)
 		if err != nil {
 			return err
 		}
@@ -239,16 +297,18 @@ func (resolver *DependencyResolver) resolveDataSource(namespace string, resolver
 	return nil
 }
 
-func (resolver *DependencyResolver) resolveReport(namespace string, resolverCtx *resolverContext, inputVals []metering.ReportQueryInputValue, reportName string, depth, maxDepth int) error {
+func (resolver *DependencyResolver) resolveReport(namespace string, resolverCtx *resolverContext, parent string, inputVals []metering.ReportQueryInputValue, reportName string, depth, maxDepth int) error {
+	reportID := DependencyNodeID(DependencyNodeReport, reportName)
+	resolverCtx.addEdge(parent, reportID)
 	if _, exists := resolverCtx.reportAccumulator[reportName]; exists {
 		return nil
 	}
 	// this input refers to a report, so fetch the report
 	report, err := resolver.reportGetter.GetReport(namespace, reportName)
 	if err != nil {
-		return err
+		return resolverCtx.fail(reportID, err)
 	}
-	err = resolver.resolveQuery(namespace, resolverCtx, inputVals, report.Spec.QueryName, depth, maxDepth)
+	err = resolver.resolveQuery(namespace, resolverCtx, DependencyNodeID(DependencyNodeReport, report.Name), inputVals, report.Spec.QueryName, depth, maxDepth)
 	if err != nil {
 		return err
 	}
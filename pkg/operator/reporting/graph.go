@@ -0,0 +1,352 @@
+package reporting
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	meteringUtil "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1/util"
+)
+
+type DependencyNodeKind string
+
+const (
+	DependencyNodeReport           DependencyNodeKind = "Report"
+	DependencyNodeReportQuery      DependencyNodeKind = "ReportQuery"
+	DependencyNodeReportDataSource DependencyNodeKind = "ReportDataSource"
+)
+
+// DependencyNodeID returns the identifier used for a resource within a
+// DependencyGraph.
+func DependencyNodeID(kind DependencyNodeKind, name string) string {
+	return string(kind) + "/" + name
+}
+
+func splitDependencyNodeID(id string) (DependencyNodeKind, string) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 {
+		return "", id
+	}
+	return DependencyNodeKind(parts[0]), parts[1]
+}
+
+// DependencyGraph is the Report -> ReportQuery -> ReportDataSource graph for
+// a set of resources within a single namespace. Edges point from a resource
+// to the resource it depends on.
+type DependencyGraph struct {
+	Namespace string                 `json:"namespace"`
+	Nodes     []*DependencyGraphNode `json:"nodes"`
+	Edges     []DependencyGraphEdge  `json:"edges"`
+}
+
+type DependencyGraphNode struct {
+	ID   string             `json:"id"`
+	Kind DependencyNodeKind `json:"kind"`
+	Name string             `json:"name"`
+	// Ready is true if the resource has a table, and for ReportQueries, if
+	// every resource it depends on is ready.
+	Ready     bool   `json:"ready"`
+	TableName string `json:"tableName,omitempty"`
+
+	// ImportDataStartTime and ImportDataEndTime are only set for
+	// ReportDataSources importing Prometheus metrics.
+	ImportDataStartTime *meta.Time `json:"importDataStartTime,omitempty"`
+	ImportDataEndTime   *meta.Time `json:"importDataEndTime,omitempty"`
+	// LastReportTime is only set for Reports.
+	LastReportTime *meta.Time `json:"lastReportTime,omitempty"`
+
+	// Reason and Message are the Running condition reason and message of a
+	// Report.
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Error is set if this resource or it's dependencies could not be
+	// resolved.
+	Error string `json:"error,omitempty"`
+	// Missing is true if a resource depends on this resource, but it
+	// doesn't exist.
+	Missing bool `json:"missing,omitempty"`
+}
+
+type DependencyGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ResolveReportDependencyGraph returns the dependency graph rooted at the
+// given report. Dependencies which cannot be resolved are included with
+// their Error field set, and with Missing set if they don't exist, so the
+// graph shows where the report's dependencies are broken.
+func (resolver *DependencyResolver) ResolveReportDependencyGraph(report *metering.Report) *DependencyGraph {
+	builder := newDependencyGraphBuilder(report.Namespace)
+	builder.merge(resolver.resolveReportGraph(report))
+	builder.addReport(report)
+	return builder.graph()
+}
+
+// ResolveNamespaceDependencyGraph returns the dependency graph containing
+// every given resource. Resources whose dependencies cannot be resolved are
+// still included, with their Error field set, so that a single broken
+// resource doesn't hide the rest of the graph.
+func (resolver *DependencyResolver) ResolveNamespaceDependencyGraph(namespace string, reports []*metering.Report, queries []*metering.ReportQuery, dataSources []*metering.ReportDataSource) *DependencyGraph {
+	builder := newDependencyGraphBuilder(namespace)
+	for _, report := range reports {
+		builder.merge(resolver.resolveReportGraph(report))
+		builder.addReport(report)
+	}
+	for _, query := range queries {
+		resolverCtx := newGraphResolverContext()
+		id := DependencyNodeID(DependencyNodeReportQuery, query.Name)
+		// resolverCtx records errors instead of returning them
+		_ = resolver.resolveDependencies(namespace, resolverCtx, id, query.Spec.Inputs, nil, 0, maxDepth)
+		builder.merge(resolverCtx)
+		builder.addReportQuery(query)
+	}
+	for _, dataSource := range dataSources {
+		resolverCtx := newGraphResolverContext()
+		id := DependencyNodeID(DependencyNodeReportDataSource, dataSource.Name)
+		if dataSource.Spec.ReportQueryView != nil {
+			_ = resolver.resolveQuery(namespace, resolverCtx, id, dataSource.Spec.ReportQueryView.Inputs, dataSource.Spec.ReportQueryView.QueryName, 0, maxDepth)
+			builder.merge(resolverCtx)
+		}
+		builder.addReportDataSource(dataSource)
+	}
+	return builder.graph()
+}
+
+func (resolver *DependencyResolver) resolveReportGraph(report *metering.Report) *resolverContext {
+	resolverCtx := newGraphResolverContext()
+	reportID := DependencyNodeID(DependencyNodeReport, report.Name)
+	queryID := DependencyNodeID(DependencyNodeReportQuery, report.Spec.QueryName)
+	resolverCtx.addEdge(reportID, queryID)
+
+	query, err := resolver.queryGetter.GetReportQuery(report.Namespace, report.Spec.QueryName)
+	if err != nil {
+		_ = resolverCtx.fail(queryID, err)
+		return resolverCtx
+	}
+	// the Report's inputs are passed to it's ReportQuery, so resolve them
+	// the same way the Report does when it runs
+	_ = resolver.resolveDependencies(report.Namespace, resolverCtx, queryID, query.Spec.Inputs, report.Spec.Inputs, 1, maxDepth)
+	resolverCtx.queryAccumulator[query.Name] = query
+	return resolverCtx
+}
+
+type dependencyGraphBuilder struct {
+	namespace string
+	nodes     map[string]*DependencyGraphNode
+	edges     map[string][]string
+	errors    map[string]error
+}
+
+func newDependencyGraphBuilder(namespace string) *dependencyGraphBuilder {
+	return &dependencyGraphBuilder{
+		namespace: namespace,
+		nodes:     make(map[string]*DependencyGraphNode),
+		edges:     make(map[string][]string),
+		errors:    make(map[string]error),
+	}
+}
+
+func (builder *dependencyGraphBuilder) merge(resolverCtx *resolverContext) {
+	for _, report := range resolverCtx.reportAccumulator {
+		builder.addReport(report)
+	}
+	for _, query := range resolverCtx.queryAccumulator {
+		builder.addReportQuery(query)
+	}
+	for _, dataSource := range resolverCtx.datasourceAccumulator {
+		builder.addReportDataSource(dataSource)
+	}
+	for from, tos := range resolverCtx.edges {
+		for _, to := range tos {
+			builder.addEdge(from, to)
+		}
+	}
+	for id, err := range resolverCtx.errors {
+		builder.errors[id] = err
+	}
+}
+
+func (builder *dependencyGraphBuilder) addEdge(from, to string) {
+	for _, existing := range builder.edges[from] {
+		if existing == to {
+			return
+		}
+	}
+	builder.edges[from] = append(builder.edges[from], to)
+}
+
+func (builder *dependencyGraphBuilder) addReport(report *metering.Report) {
+	node := &DependencyGraphNode{
+		ID:             DependencyNodeID(DependencyNodeReport, report.Name),
+		Kind:           DependencyNodeReport,
+		Name:           report.Name,
+		Ready:          report.Status.TableRef.Name != "",
+		TableName:      report.Status.TableRef.Name,
+		LastReportTime: report.Status.LastReportTime,
+	}
+	if cond := meteringUtil.GetReportCondition(report.Status, metering.ReportRunning); cond != nil {
+		node.Reason = cond.Reason
+		node.Message = cond.Message
+		if cond.Status == v1.ConditionFalse && cond.Reason == meteringUtil.GenerateReportFailedReason {
+			node.Ready = false
+		}
+	}
+	builder.nodes[node.ID] = node
+}
+
+func (builder *dependencyGraphBuilder) addReportQuery(query *metering.ReportQuery) {
+	node := &DependencyGraphNode{
+		ID:   DependencyNodeID(DependencyNodeReportQuery, query.Name),
+		Kind: DependencyNodeReportQuery,
+		Name: query.Name,
+	}
+	builder.nodes[node.ID] = node
+}
+
+func (builder *dependencyGraphBuilder) addReportDataSource(dataSource *metering.ReportDataSource) {
+	node := &DependencyGraphNode{
+		ID:        DependencyNodeID(DependencyNodeReportDataSource, dataSource.Name),
+		Kind:      DependencyNodeReportDataSource,
+		Name:      dataSource.Name,
+		Ready:     dataSource.Status.TableRef.Name != "",
+		TableName: dataSource.Status.TableRef.Name,
+	}
+	if status := dataSource.Status.PrometheusMetricsImportStatus; status != nil {
+		node.ImportDataStartTime = status.ImportDataStartTime
+		node.ImportDataEndTime = status.ImportDataEndTime
+	} else if dataSource.Spec.PrometheusMetricsImporter != nil {
+		// no data has been imported yet
+		node.Ready = false
+	}
+	builder.nodes[node.ID] = node
+}
+
+func (builder *dependencyGraphBuilder) graph() *DependencyGraph {
+	g := &DependencyGraph{
+		Namespace: builder.namespace,
+		Nodes:     make([]*DependencyGraphNode, 0, len(builder.nodes)),
+		Edges:     make([]DependencyGraphEdge, 0, len(builder.edges)),
+	}
+	for id, err := range builder.errors {
+		node, exists := builder.nodes[id]
+		if !exists {
+			// the resource couldn't be fetched, so add a node for it
+			// which only has what's known from it's ID
+			kind, name := splitDependencyNodeID(id)
+			node = &DependencyGraphNode{
+				ID:      id,
+				Kind:    kind,
+				Name:    name,
+				Missing: k8serrors.IsNotFound(err),
+			}
+			builder.nodes[id] = node
+		}
+		node.Error = err.Error()
+		node.Ready = false
+	}
+	for _, node := range builder.nodes {
+		g.Nodes = append(g.Nodes, node)
+	}
+	for from, tos := range builder.edges {
+		for _, to := range tos {
+			g.Edges = append(g.Edges, DependencyGraphEdge{From: from, To: to})
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From == g.Edges[j].From {
+			return g.Edges[i].To < g.Edges[j].To
+		}
+		return g.Edges[i].From < g.Edges[j].From
+	})
+
+	// A ReportQuery has no table of it's own, so it's ready when
+	// everything it depends on is ready.
+	visited := make(map[string]bool)
+	var queryReady func(id string) bool
+	queryReady = func(id string) bool {
+		node, exists := builder.nodes[id]
+		if !exists {
+			return false
+		}
+		if node.Kind != DependencyNodeReportQuery {
+			return node.Ready
+		}
+		if visited[id] {
+			return node.Ready
+		}
+		visited[id] = true
+		node.Ready = node.Error == ""
+		for _, to := range builder.edges[id] {
+			if !queryReady(to) {
+				node.Ready = false
+			}
+		}
+		return node.Ready
+	}
+	for _, node := range g.Nodes {
+		queryReady(node.ID)
+	}
+	return g
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language.
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", g.Namespace)
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, style=filled];\n")
+	for _, node := range g.Nodes {
+		color, style := "palegreen", "filled"
+		if node.Missing {
+			color, style = "lightgrey", "filled,dashed"
+		} else if !node.Ready {
+			color = "lightpink"
+		}
+		fmt.Fprintf(&b, "\t%q [label=%q, fillcolor=%q, style=%q];\n", node.ID, node.dotLabel(), color, style)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "\t%q -> %q;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (node *DependencyGraphNode) dotLabel() string {
+	lines := []string{
+		fmt.Sprintf("%s %s", node.Kind, node.Name),
+		fmt.Sprintf("ready: %t", node.Ready),
+	}
+	if node.Missing {
+		lines = append(lines, "missing: true")
+	}
+	if node.TableName != "" {
+		lines = append(lines, fmt.Sprintf("table: %s", node.TableName))
+	}
+	if node.ImportDataStartTime != nil {
+		lines = append(lines, fmt.Sprintf("importDataStartTime: %s", node.ImportDataStartTime.UTC()))
+	}
+	if node.ImportDataEndTime != nil {
+		lines = append(lines, fmt.Sprintf("importDataEndTime: %s", node.ImportDataEndTime.UTC()))
+	}
+	if node.LastReportTime != nil {
+		lines = append(lines, fmt.Sprintf("lastReportTime: %s", node.LastReportTime.UTC()))
+	}
+	if node.Reason != "" {
+		lines = append(lines, fmt.Sprintf("reason: %s", node.Reason))
+	}
+	if node.Error != "" {
+		lines = append(lines, fmt.Sprintf("error: %s", node.Error))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,146 @@
+package reporting
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	testNs := "test-ns"
+
+	ds1 := testhelpers.NewReportDataSource("datasource1", testNs)
+	ds1.Status.TableRef = v1.LocalObjectReference{Name: "datasource-table1"}
+	ds2 := testhelpers.NewReportDataSource("datasource2", testNs)
+
+	query1 := &metering.ReportQuery{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "query1",
+			Namespace: testNs,
+		},
+		Spec: metering.ReportQuerySpec{
+			Inputs: []metering.ReportQueryInputDefinition{
+				{
+					Name:    "ds1",
+					Type:    "ReportDataSource",
+					Default: newDefault(`"datasource1"`),
+				},
+				{
+					Name:    "q2",
+					Type:    "ReportQuery",
+					Default: newDefault(`"query2"`),
+				},
+			},
+		},
+	}
+	query2 := &metering.ReportQuery{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "query2",
+			Namespace: testNs,
+		},
+		Spec: metering.ReportQuerySpec{
+			Inputs: []metering.ReportQueryInputDefinition{
+				{
+					Name:    "ds2",
+					Type:    "ReportDataSource",
+					Default: newDefault(`"datasource2"`),
+				},
+			},
+		},
+	}
+	report1 := testhelpers.NewReport("report1", testNs, "query1", nil, nil, nil, metering.ReportStatus{
+		TableRef: v1.LocalObjectReference{Name: "report-table1"},
+	}, nil, false, nil)
+
+	resolver := NewDependencyResolver(
+		testhelpers.NewReportQueryStore([]*metering.ReportQuery{query1, query2}),
+		testhelpers.NewReportDataSourceStore([]*metering.ReportDataSource{ds1, ds2}),
+		testhelpers.NewReportStore([]*metering.Report{report1}),
+	)
+
+	graph := resolver.ResolveReportDependencyGraph(report1)
+
+	expectedEdges := []DependencyGraphEdge{
+		{From: "Report/report1", To: "ReportQuery/query1"},
+		{From: "ReportQuery/query1", To: "ReportDataSource/datasource1"},
+		{From: "ReportQuery/query1", To: "ReportQuery/query2"},
+		{From: "ReportQuery/query2", To: "ReportDataSource/datasource2"},
+	}
+	assert.Equal(t, expectedEdges, graph.Edges)
+
+	ready := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		ready[node.ID] = node.Ready
+	}
+	expectedReady := map[string]bool{
+		"Report/report1":               true,
+		"ReportQuery/query1":           false,
+		"ReportQuery/query2":           false,
+		"ReportDataSource/datasource1": true,
+		"ReportDataSource/datasource2": false,
+	}
+	assert.Equal(t, expectedReady, ready)
+
+	var buf bytes.Buffer
+	require.NoError(t, graph.WriteDOT(&buf))
+	assert.Contains(t, buf.String(), `"ReportQuery/query1" -> "ReportQuery/query2";`)
+	assert.Contains(t, buf.String(), `table: datasource-table1`)
+
+	// a ReportQuery referencing a missing ReportDataSource shouldn't prevent
+	// the rest of the namespace from being returned
+	brokenQuery := &metering.ReportQuery{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "broken",
+			Namespace: testNs,
+		},
+		Spec: metering.ReportQuerySpec{
+			Inputs: []metering.ReportQueryInputDefinition{
+				{
+					Name:    "ds",
+					Type:    "ReportDataSource",
+					Default: newDefault(`"does-not-exist"`),
+				},
+			},
+		},
+	}
+	resolver.queryGetter = testhelpers.NewReportQueryStore([]*metering.ReportQuery{query1, query2, brokenQuery})
+	nsGraph := resolver.ResolveNamespaceDependencyGraph(testNs,
+		[]*metering.Report{report1},
+		[]*metering.ReportQuery{brokenQuery, query1, query2},
+		[]*metering.ReportDataSource{ds1, ds2},
+	)
+	nodes := make(map[string]*DependencyGraphNode)
+	for _, node := range nsGraph.Nodes {
+		nodes[node.ID] = node
+	}
+	require.Len(t, nodes, 7)
+	assert.False(t, nodes["ReportQuery/broken"].Ready)
+	missing := nodes["ReportDataSource/does-not-exist"]
+	require.NotNil(t, missing)
+	assert.True(t, missing.Missing)
+	assert.NotEmpty(t, missing.Error)
+	assert.Equal(t, DependencyNodeReportDataSource, missing.Kind)
+	assert.Equal(t, "does-not-exist", missing.Name)
+	assert.Contains(t, nsGraph.Edges, DependencyGraphEdge{From: "ReportQuery/broken", To: "ReportDataSource/does-not-exist"})
+	assert.True(t, nodes["Report/report1"].Ready)
+
+	// a Report whose ReportQuery is missing returns the partial graph
+	report2 := testhelpers.NewReport("report2", testNs, "missing-query", nil, nil, nil, metering.ReportStatus{}, nil, false, nil)
+	graph = resolver.ResolveReportDependencyGraph(report2)
+	require.Len(t, graph.Nodes, 2)
+	assert.Equal(t, "Report/report2", graph.Nodes[0].ID)
+	assert.Equal(t, "ReportQuery/missing-query", graph.Nodes[1].ID)
+	assert.True(t, graph.Nodes[1].Missing)
+	assert.Equal(t, []DependencyGraphEdge{{From: "Report/report2", To: "ReportQuery/missing-query"}}, graph.Edges)
+
+	buf.Reset()
+	require.NoError(t, graph.WriteDOT(&buf))
+	assert.Contains(t, buf.String(), `fillcolor="lightgrey", style="filled,dashed"`)
+}
@@ -0,0 +1,107 @@
+package querytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// resultsComparisonEpsilon is the relative error allowed between numeric
+// values when comparing results, due to floating point precision.
+const resultsComparisonEpsilon = 0.0001
+
+// Check runs testCase and compares the rendered query and results against
+// the golden files of testCase. If update is true, the golden files are
+// overwritten instead. Results are only compared if the Harness has a
+// database to run queries against, see ChecksResults.
+func (h *Harness) Check(testCase TestCase, update bool) error {
+	result, err := h.Run(testCase)
+	if err != nil {
+		return err
+	}
+
+	if testCase.ExpectedQuery != "" {
+		if update {
+			if err := ioutil.WriteFile(testCase.ExpectedQuery, []byte(result.Query), 0644); err != nil {
+				return err
+			}
+		} else {
+			expected, err := ioutil.ReadFile(testCase.ExpectedQuery)
+			if err != nil {
+				return err
+			}
+			if string(expected) != result.Query {
+				return fmt.Errorf("rendered query doesn't match %s, got:\n%s", testCase.ExpectedQuery, result.Query)
+			}
+		}
+	}
+
+	if testCase.ExpectedResults != "" && result.Rows != nil {
+		if update {
+			data, err := json.MarshalIndent(result.Rows, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(testCase.ExpectedResults, append(data, '\n'), 0644); err != nil {
+				return err
+			}
+		} else {
+			data, err := ioutil.ReadFile(testCase.ExpectedResults)
+			if err != nil {
+				return err
+			}
+			var expected []map[string]interface{}
+			if err := json.Unmarshal(data, &expected); err != nil {
+				return fmt.Errorf("error decoding expected results from %s: %v", testCase.ExpectedResults, err)
+			}
+			if err := CompareResults(expected, result.Rows); err != nil {
+				return fmt.Errorf("results don't match %s: %v", testCase.ExpectedResults, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CompareResults returns an error describing the first difference between the
+// expected and actual rows. Numeric values are compared with a small relative
+// error allowed.
+func CompareResults(expected, actual []map[string]interface{}) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d rows, got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		expectedColumns := sortedKeys(expected[i])
+		actualColumns := sortedKeys(actual[i])
+		if !reflect.DeepEqual(expectedColumns, actualColumns) {
+			return fmt.Errorf("row %d: expected columns [%s], got [%s]", i, strings.Join(expectedColumns, ", "), strings.Join(actualColumns, ", "))
+		}
+		for _, column := range expectedColumns {
+			if !valuesEqual(expected[i][column], actual[i][column]) {
+				return fmt.Errorf("row %d: expected column %q to be %v, got %v", i, column, expected[i][column], actual[i][column])
+			}
+		}
+	}
+	return nil
+}
+
+func valuesEqual(expected, actual interface{}) bool {
+	expectedFloat, expectedOk := expected.(float64)
+	actualFloat, actualOk := actual.(float64)
+	if !expectedOk || !actualOk || expectedFloat == 0 {
+		return reflect.DeepEqual(expected, actual)
+	}
+	return math.Abs(expectedFloat-actualFloat)/math.Abs(expectedFloat) <= resultsComparisonEpsilon
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,385 @@
+// Package querytest implements a harness for testing ReportQueries without a
+// running reporting-operator. Without a database, it's a golden SQL harness:
+// ReportQueries are rendered the same way the reporting-operator renders
+// them, and only the rendered SQL is compared with golden files. The results
+// of queries are only checked if a Presto compatible database is configured,
+// in which case they're run against fixture data stored in the tables of the
+// ReportDataSources they depend on. hack/querytest.sh runs the test cases of
+// the chart's ReportQueries against a Presto container.
+package querytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/db"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reporting"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+	"github.com/kube-reporting/metering-operator/pkg/presto"
+)
+
+const (
+	DefaultNamespace = "metering-querytest"
+	DefaultCatalog   = "memory"
+	DefaultSchema    = "default"
+)
+
+// Config controls where a Harness creates the tables for ReportDataSources.
+type Config struct {
+	Namespace string
+	Catalog   string
+	Schema    string
+}
+
+// TestCase describes a single ReportQuery to test, the fixtures to store in
+// the ReportDataSources it depends on, and the golden files containing the
+// expected rendered query and results.
+type TestCase struct {
+	Name   string                           `json:"name"`
+	Query  string                           `json:"query"`
+	Inputs []metering.ReportQueryInputValue `json:"inputs,omitempty"`
+	// ReportingStart and ReportingEnd default to the earliest and latest
+	// timestamps of the metrics in the fixtures.
+	ReportingStart *meta.Time `json:"reportingStart,omitempty"`
+	ReportingEnd   *meta.Time `json:"reportingEnd,omitempty"`
+	// DataSources maps the name of a ReportDataSource to a file containing a
+	// JSON array of Prometheus metrics to store in its table.
+	DataSources map[string]string `json:"dataSources,omitempty"`
+	// ExpectedQuery is a file containing the rendered query.
+	ExpectedQuery string `json:"expectedQuery,omitempty"`
+	// ExpectedResults is a file containing a JSON array of the rows returned
+	// by the query.
+	ExpectedResults string `json:"expectedResults,omitempty"`
+}
+
+// LoadTestCases reads a YAML list of TestCases from path. Relative file paths
+// within each TestCase are resolved relative to the directory containing path.
+func LoadTestCases(path string) ([]TestCase, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var testCases []TestCase
+	if err := yaml.Unmarshal(data, &testCases); err != nil {
+		return nil, fmt.Errorf("error decoding test cases from %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+	for i := range testCases {
+		for name, file := range testCases[i].DataSources {
+			testCases[i].DataSources[name] = resolve(file)
+		}
+		testCases[i].ExpectedQuery = resolve(testCases[i].ExpectedQuery)
+		testCases[i].ExpectedResults = resolve(testCases[i].ExpectedResults)
+	}
+	return testCases, nil
+}
+
+// Result is the outcome of running a TestCase.
+type Result struct {
+	Query string
+	// Rows is nil if the Harness has no database to run the query against.
+	Rows []map[string]interface{}
+}
+
+// Harness renders ReportQueries, and runs them against a Presto compatible
+// database, such as a local single node Presto using the memory connector, if
+// one is configured.
+type Harness struct {
+	cfg     Config
+	queryer db.Queryer
+
+	queries     map[string]*metering.ReportQuery
+	dataSources map[string]*metering.ReportDataSource
+	resolver    *reporting.DependencyResolver
+}
+
+// New returns a Harness for the ReportQueries and ReportDataSources in
+// resources. If queryer is nil, queries are only rendered.
+//
+// ReportDataSources that queries depend on but aren't in resources are
+// created using the convention of the openshift-metering chart: if a
+// ReportQuery with the same name exists, the ReportDataSource is a view of
+// that query, otherwise it contains Prometheus metrics.
+func New(queryer db.Queryer, cfg Config, resources *Resources) *Harness {
+	if cfg.Namespace == "" {
+		cfg.Namespace = DefaultNamespace
+	}
+	if cfg.Catalog == "" {
+		cfg.Catalog = DefaultCatalog
+	}
+	if cfg.Schema == "" {
+		cfg.Schema = DefaultSchema
+	}
+
+	h := &Harness{
+		cfg:         cfg,
+		queryer:     queryer,
+		queries:     make(map[string]*metering.ReportQuery),
+		dataSources: make(map[string]*metering.ReportDataSource),
+	}
+	for _, query := range resources.ReportQueries {
+		query = query.DeepCopy()
+		query.Namespace = cfg.Namespace
+		h.queries[query.Name] = query
+	}
+	for _, dataSource := range resources.ReportDataSources {
+		dataSource = dataSource.DeepCopy()
+		dataSource.Namespace = cfg.Namespace
+		h.dataSources[dataSource.Name] = dataSource
+	}
+
+	h.resolver = reporting.NewDependencyResolver(
+		reporting.ReportQueryGetterFunc(h.getReportQuery),
+		reporting.ReportDataSourceGetterFunc(h.getReportDataSource),
+		reporting.ReportGetterFunc(func(_, name string) (*metering.Report, error) {
+			return nil, apierrors.NewNotFound(metering.Resource("Report"), name)
+		}),
+	)
+	return h
+}
+
+// ChecksResults returns true if the Harness has a database to run queries
+// against, and false if it only compares the rendered SQL of test cases.
+func (h *Harness) ChecksResults() bool {
+	return h.queryer != nil
+}
+
+func (h *Harness) getReportQuery(_, name string) (*metering.ReportQuery, error) {
+	query, ok := h.queries[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(metering.Resource("ReportQuery"), name)
+	}
+	return query, nil
+}
+
+func (h *Harness) getReportDataSource(_, name string) (*metering.ReportDataSource, error) {
+	if dataSource, ok := h.dataSources[name]; ok {
+		return dataSource, nil
+	}
+	dataSource := &metering.ReportDataSource{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      name,
+			Namespace: h.cfg.Namespace,
+		},
+	}
+	if _, ok := h.queries[name]; ok {
+		dataSource.Spec.ReportQueryView = &metering.ReportQueryViewDataSource{QueryName: name}
+	} else {
+		dataSource.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{}
+	}
+	h.dataSources[name] = dataSource
+	return dataSource, nil
+}
+
+// Run renders the ReportQuery of testCase, and if the Harness has a database,
+// stores the fixtures of testCase and runs the rendered query. The tables of
+// every ReportDataSource the query depends on are recreated, so fixtures from
+// previous runs don't affect the results.
+func (h *Harness) Run(testCase TestCase) (*Result, error) {
+	query, err := h.getReportQuery(h.cfg.Namespace, testCase.Query)
+	if err != nil {
+		return nil, err
+	}
+	deps, err := h.resolver.ResolveDependencies(h.cfg.Namespace, query.Spec.Inputs, testCase.Inputs)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve dependencies of ReportQuery %s: %v", query.Name, err)
+	}
+
+	fixtures := make(map[string][]*prestostore.PrometheusMetric)
+	var earliest, latest time.Time
+	for dataSourceName, file := range testCase.DataSources {
+		metrics, err := LoadPrometheusMetrics(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, metric := range metrics {
+			if earliest.IsZero() || metric.Timestamp.Before(earliest) {
+				earliest = metric.Timestamp
+			}
+			if metric.Timestamp.After(latest) {
+				latest = metric.Timestamp
+			}
+		}
+		fixtures[dataSourceName] = metrics
+	}
+
+	reportingStart, reportingEnd := earliest.UTC(), latest.UTC()
+	if testCase.ReportingStart != nil {
+		reportingStart = testCase.ReportingStart.UTC()
+	}
+	if testCase.ReportingEnd != nil {
+		reportingEnd = testCase.ReportingEnd.UTC()
+	}
+
+	var prestoTables []*metering.PrestoTable
+	for _, dataSource := range deps.Dependencies.ReportDataSources {
+		prestoTable := h.newPrestoTable(dataSource)
+		dataSource.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+		prestoTables = append(prestoTables, prestoTable)
+	}
+
+	if h.queryer != nil {
+		created := make(map[string]bool)
+		for _, dataSource := range deps.Dependencies.ReportDataSources {
+			if err := h.createDataSourceTable(dataSource, fixtures, prestoTables, created); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	queryCtx := &reporting.ReportQueryTemplateContext{
+		Namespace:         h.cfg.Namespace,
+		Query:             query.Spec.Query,
+		RequiredInputs:    reportingutil.ConvertInputDefinitionsIntoInputList(query.Spec.Inputs),
+		ReportQueries:     deps.Dependencies.ReportQueries,
+		ReportDataSources: deps.Dependencies.ReportDataSources,
+		PrestoTables:      prestoTables,
+	}
+	tmplCtx := reporting.TemplateContext{
+		Report: reporting.ReportTemplateInfo{
+			ReportingStart: &reportingStart,
+			ReportingEnd:   &reportingEnd,
+			Inputs:         deps.InputValues,
+		},
+	}
+	renderedQuery, err := reporting.RenderQuery(queryCtx, tmplCtx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render ReportQuery %s: %v", query.Name, err)
+	}
+
+	result := &Result{Query: renderedQuery}
+	if h.queryer == nil {
+		return result, nil
+	}
+	// Report tables are populated using INSERT INTO, which maps the
+	// results to the columns of the ReportQuery by position, so name the
+	// results the same way.
+	selectQuery := renderedQuery
+	if len(query.Spec.Columns) != 0 {
+		columns := presto.GenerateQuotedColumnsListSQL(reportingutil.GeneratePrestoColumns(query))
+		selectQuery = fmt.Sprintf("SELECT * FROM (\n%s\n) AS results(%s)", renderedQuery, columns)
+	}
+	rows, err := presto.ExecuteSelect(h.queryer, selectQuery)
+	if err != nil {
+		return nil, fmt.Errorf("error running ReportQuery %s: %v", query.Name, err)
+	}
+	result.Rows, err = normalizeRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (h *Harness) newPrestoTable(dataSource *metering.ReportDataSource) *metering.PrestoTable {
+	return &metering.PrestoTable{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      reportingutil.TableResourceNameFromKind(metering.ReportDataSourceGVK.Kind, dataSource.Namespace, dataSource.Name),
+			Namespace: dataSource.Namespace,
+		},
+		Status: metering.PrestoTableStatus{
+			Catalog:   h.cfg.Catalog,
+			Schema:    h.cfg.Schema,
+			TableName: reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name),
+		},
+	}
+}
+
+// createDataSourceTable creates the table of a Prometheus ReportDataSource
+// containing its fixture, or the view of a ReportQuery ReportDataSource after
+// creating the tables of the ReportDataSources the view depends on.
+func (h *Harness) createDataSourceTable(dataSource *metering.ReportDataSource, fixtures map[string][]*prestostore.PrometheusMetric, prestoTables []*metering.PrestoTable, created map[string]bool) error {
+	if created[dataSource.Name] {
+		return nil
+	}
+	created[dataSource.Name] = true
+	tableName := reportingutil.DataSourceTableName(dataSource.Namespace, dataSource.Name)
+
+	switch {
+	case dataSource.Spec.ReportQueryView != nil:
+		query, err := h.getReportQuery(h.cfg.Namespace, dataSource.Spec.ReportQueryView.QueryName)
+		if err != nil {
+			return err
+		}
+		deps, err := h.resolver.ResolveDependencies(h.cfg.Namespace, query.Spec.Inputs, dataSource.Spec.ReportQueryView.Inputs)
+		if err != nil {
+			return fmt.Errorf("unable to resolve dependencies of ReportQuery %s: %v", query.Name, err)
+		}
+		for _, dep := range deps.Dependencies.ReportDataSources {
+			if err := h.createDataSourceTable(h.dataSources[dep.Name], fixtures, prestoTables, created); err != nil {
+				return err
+			}
+		}
+		queryCtx := &reporting.ReportQueryTemplateContext{
+			Namespace:         h.cfg.Namespace,
+			Query:             query.Spec.Query,
+			RequiredInputs:    reportingutil.ConvertInputDefinitionsIntoInputList(query.Spec.Inputs),
+			ReportQueries:     deps.Dependencies.ReportQueries,
+			ReportDataSources: deps.Dependencies.ReportDataSources,
+			PrestoTables:      prestoTables,
+		}
+		renderedQuery, err := reporting.RenderQuery(queryCtx, reporting.TemplateContext{
+			Report: reporting.ReportTemplateInfo{
+				Inputs: deps.InputValues,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("unable to render ReportQuery %s: %v", query.Name, err)
+		}
+		if err := presto.CreateView(h.queryer, h.cfg.Catalog, h.cfg.Schema, tableName, renderedQuery, true); err != nil {
+			return fmt.Errorf("error creating view for ReportDataSource %s: %v", dataSource.Name, err)
+		}
+	case dataSource.Spec.PrometheusMetricsImporter != nil:
+		if err := presto.DropTable(h.queryer, h.cfg.Catalog, h.cfg.Schema, tableName, true); err != nil {
+			return fmt.Errorf("error dropping table for ReportDataSource %s: %v", dataSource.Name, err)
+		}
+		if err := presto.CreateTable(h.queryer, h.cfg.Catalog, h.cfg.Schema, tableName, prestostore.PrometheusMetricPrestoAllColumns, "", nil, false); err != nil {
+			return fmt.Errorf("error creating table for ReportDataSource %s: %v", dataSource.Name, err)
+		}
+		metrics := fixtures[dataSource.Name]
+		if len(metrics) == 0 {
+			return nil
+		}
+		var buf bytes.Buffer
+		buf.Grow(1000000)
+		fullTableName := presto.FullyQualifiedTableName(h.cfg.Catalog, h.cfg.Schema, tableName)
+		if err := prestostore.StorePrometheusMetricsWithBuffer(&buf, context.Background(), h.queryer, fullTableName, metrics); err != nil {
+			return fmt.Errorf("error storing fixture for ReportDataSource %s: %v", dataSource.Name, err)
+		}
+	default:
+		return fmt.Errorf("ReportDataSource %s: only Prometheus and ReportQuery view ReportDataSources are supported", dataSource.Name)
+	}
+	return nil
+}
+
+// normalizeRows converts rows into the same representation as rows decoded
+// from JSON, which is how expected results are stored.
+func normalizeRows(rows []presto.Row) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	normalized := []map[string]interface{}{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
@@ -0,0 +1,90 @@
+package querytest
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/prestodb/presto-go-client/presto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChartReportQueryResults only runs if a Presto with a memory catalog is
+// available, eg: using hack/querytest.sh, which `make querytest` runs, or by
+// running the prestosql/presto image locally and setting
+// METERING_QUERYTEST_PRESTO_HOST=127.0.0.1:8080
+const prestoHostEnvVar = "METERING_QUERYTEST_PRESTO_HOST"
+
+var update = flag.Bool("update", false, "update the golden files of the ReportQuery test cases")
+
+const chartReportQueriesDir = "../../../../charts/openshift-metering/templates/openshift-reporting/report-queries"
+
+func loadChartTestCases(t *testing.T) (*Resources, []TestCase) {
+	resources, err := LoadResources(chartReportQueriesDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, resources.ReportQueries)
+
+	testCases, err := LoadTestCases("testdata/testcases.yaml")
+	require.NoError(t, err)
+	return resources, testCases
+}
+
+// TestChartReportQueries compares the rendered queries with their golden SQL
+// files, which doesn't need a database.
+func TestChartReportQueries(t *testing.T) {
+	resources, testCases := loadChartTestCases(t)
+	harness := New(nil, Config{}, resources)
+	for _, testCase := range testCases {
+		testCase := testCase
+		testCase.ExpectedResults = ""
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert.NoError(t, harness.Check(testCase, *update))
+		})
+	}
+}
+
+// TestChartReportQueryResults runs the queries against their fixtures and
+// compares the results with the expected results.
+func TestChartReportQueryResults(t *testing.T) {
+	host := os.Getenv(prestoHostEnvVar)
+	if host == "" {
+		t.Skipf("%s isn't set, run hack/querytest.sh to check the results of the ReportQueries", prestoHostEnvVar)
+	}
+	resources, testCases := loadChartTestCases(t)
+
+	prestoDB, err := sql.Open("presto", fmt.Sprintf("http://querytest@%s?catalog=%s&schema=%s", host, DefaultCatalog, DefaultSchema))
+	require.NoError(t, err)
+	defer prestoDB.Close()
+	harness := New(prestoDB, Config{}, resources)
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		require.NotEmpty(t, testCase.ExpectedResults, "test case %s has no expected results", testCase.Name)
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert.NoError(t, harness.Check(testCase, *update))
+		})
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	expected := []map[string]interface{}{
+		{"namespace": "default", "pod_request_cpu_core_seconds": 60.0},
+	}
+
+	assert.NoError(t, CompareResults(expected, []map[string]interface{}{
+		{"namespace": "default", "pod_request_cpu_core_seconds": 60.000001},
+	}))
+	assert.Error(t, CompareResults(expected, []map[string]interface{}{
+		{"namespace": "default", "pod_request_cpu_core_seconds": 61.0},
+	}))
+	assert.Error(t, CompareResults(expected, []map[string]interface{}{
+		{"namespace": "other", "pod_request_cpu_core_seconds": 60.0},
+	}))
+	assert.Error(t, CompareResults(expected, []map[string]interface{}{
+		{"pod_request_cpu_core_seconds": 60.0},
+	}))
+	assert.Error(t, CompareResults(expected, nil))
+}
@@ -0,0 +1,153 @@
+package querytest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+)
+
+// Resources contains the ReportQueries and ReportDataSources a Harness
+// renders and runs queries against.
+type Resources struct {
+	ReportQueries     []*metering.ReportQuery
+	ReportDataSources []*metering.ReportDataSource
+}
+
+// LoadResources reads every ReportQuery and ReportDataSource from the YAML
+// files in paths. Directories are expanded to the *.yaml and *.yml files
+// they contain, and any other kinds of resources are ignored.
+func LoadResources(paths ...string) (*Resources, error) {
+	files, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := &Resources{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		err = resources.decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", file, err)
+		}
+	}
+	return resources, nil
+}
+
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+	}
+	return files, nil
+}
+
+// decode reads a stream of YAML documents into resources.
+func (resources *Resources) decode(r io.Reader) error {
+	data, err := stripHelmActions(r)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+
+		var typeMeta struct {
+			Kind string `json:"kind"`
+		}
+		if err := json.Unmarshal(raw, &typeMeta); err != nil {
+			return err
+		}
+		switch typeMeta.Kind {
+		case "ReportQuery":
+			var query metering.ReportQuery
+			if err := json.Unmarshal(raw, &query); err != nil {
+				return err
+			}
+			resources.ReportQueries = append(resources.ReportQueries, &query)
+		case "ReportDataSource":
+			var dataSource metering.ReportDataSource
+			if err := json.Unmarshal(raw, &dataSource); err != nil {
+				return err
+			}
+			resources.ReportDataSources = append(resources.ReportDataSources, &dataSource)
+		}
+	}
+}
+
+// stripHelmActions removes lines consisting of a single Helm template action,
+// such as the variable assignments and if/end blocks wrapping the ReportQueries
+// in the openshift-metering chart, so chart templates can be loaded as-is.
+// Template actions embedded in other YAML aren't supported.
+func stripHelmActions(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "{{") && strings.HasSuffix(trimmed, "}}") {
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadPrometheusMetrics reads a fixture file containing a JSON array of
+// Prometheus metrics, using the same format as the metrics accepted by the
+// /api/v1/datasources/prometheus/store endpoint.
+func LoadPrometheusMetrics(path string) ([]*prestostore.PrometheusMetric, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var metrics []*prestostore.PrometheusMetric
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("error decoding Prometheus metrics from %s: %v", path, err)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,11 @@
+SELECT
+  timestamp '2019-03-18 16:00:00.000' AS period_start,
+  timestamp '2019-03-18 16:05:00.000' AS period_end,
+  namespace,
+  sum(pod_request_cpu_core_seconds) as pod_request_cpu_core_seconds
+FROM (SELECT * FROM memory.default.datasource_metering_querytest_pod_cpu_request_raw WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')
+WHERE "timestamp" >= timestamp '2019-03-18 16:00:00.000'
+AND "timestamp" < timestamp '2019-03-18 16:05:00.000'
+AND dt >= '2019-03-18'
+AND dt <= '2019-03-18'
+GROUP BY namespace
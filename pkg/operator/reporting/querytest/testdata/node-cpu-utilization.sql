@@ -0,0 +1,24 @@
+WITH node_cpu_allocatable AS (
+  SELECT sum(node_allocatable_cpu_core_seconds) as node_allocatable_cpu_core_seconds
+  FROM (SELECT * FROM memory.default.datasource_metering_querytest_node_cpu_allocatable_raw WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')
+    WHERE "timestamp" >= timestamp '2019-03-18 16:00:00.000'
+    AND "timestamp" < timestamp '2019-03-18 16:05:00.000'
+    AND dt >= '2019-03-18'
+    AND dt <= '2019-03-18'
+), pod_cpu_consumption AS (
+  SELECT sum(pod_request_cpu_core_seconds) as pod_request_cpu_core_seconds
+  FROM (SELECT * FROM memory.default.datasource_metering_querytest_pod_cpu_request_raw WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')
+  WHERE "timestamp" >= timestamp '2019-03-18 16:00:00.000'
+  AND "timestamp" < timestamp '2019-03-18 16:05:00.000'
+  AND dt >= '2019-03-18'
+  AND dt <= '2019-03-18'
+)
+SELECT
+  timestamp '2019-03-18 16:00:00.000' AS period_start,
+  timestamp '2019-03-18 16:05:00.000' AS period_end,
+  node_cpu_allocatable.*,
+  pod_cpu_consumption.*,
+  pod_cpu_consumption.pod_request_cpu_core_seconds / node_cpu_allocatable.node_allocatable_cpu_core_seconds,
+  1 - (pod_cpu_consumption.pod_request_cpu_core_seconds / node_cpu_allocatable.node_allocatable_cpu_core_seconds)
+FROM node_cpu_allocatable
+CROSS JOIN pod_cpu_consumption
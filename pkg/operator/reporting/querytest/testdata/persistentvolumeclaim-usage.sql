@@ -0,0 +1,12 @@
+SELECT
+  timestamp '2019-03-18 16:00:00.000' AS period_start,
+  timestamp '2019-03-18 16:05:00.000' AS period_end,
+  namespace,
+  persistentvolumeclaim,
+  sum(persistentvolumeclaim_usage_bytes)
+FROM (SELECT * FROM memory.default.datasource_metering_querytest_persistentvolumeclaim_usage_with_phase_raw WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')
+WHERE "timestamp" >= timestamp '2019-03-18 16:00:00.000'
+AND "timestamp" < timestamp '2019-03-18 16:05:00.000'
+AND dt >= '2019-03-18'
+AND dt <= '2019-03-18'
+GROUP BY namespace, persistentvolumeclaim
@@ -10,6 +10,8 @@ import (
 
 	"github.com/Masterminds/sprig"
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
@@ -28,6 +30,11 @@ type ReportQueryTemplateContext struct {
 	ReportQueries     []*metering.ReportQuery
 	ReportDataSources []*metering.ReportDataSource
 	PrestoTables      []*metering.PrestoTable
+
+	// partitionStart and partitionEnd are the reporting period of the
+	// TemplateContext being rendered, and are used to prune the partitions
+	// read from ReportDataSource tables.
+	partitionStart, partitionEnd *time.Time
 }
 
 // TemplateContext is the context passed to each template and contains variables related to the Report
@@ -48,20 +55,74 @@ type ReportTemplateInfo struct {
 // that the DataSource references (DataSource.Status.TableRef) and nil, or an empty string and an error
 // if the TableRef.Name is unset, or unable to be found in the ctx.PrestoTables.
 func (ctx *ReportQueryTemplateContext) dataSourceTableName(name string) (string, error) {
+	_, tableName, err := ctx.lookupDataSourceTable(name)
+	return tableName, err
+}
+
+// prunedDataSourceTableName is like dataSourceTableName, but when rendering
+// a query for a reporting period, the table of a ReportDataSource partitioned
+// by dt is returned as a subquery selecting only the partitions containing data
+// within the reporting period, so Presto doesn't read every partition. Queries
+// opt in to pruning by using it instead of dataSourceTableName, since it
+// changes which rows the query reads.
+func (ctx *ReportQueryTemplateContext) prunedDataSourceTableName(name string) (string, error) {
+	ds, tableName, err := ctx.lookupDataSourceTable(name)
+	if err != nil {
+		return "", err
+	}
+	if ctx.partitionStart == nil || ctx.partitionEnd == nil || !ctx.isDtPartitioned(ds) {
+		return tableName, nil
+	}
+	predicate, err := DtPartitionPredicate(*ctx.partitionStart, *ctx.partitionEnd)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(SELECT * FROM %s WHERE %s)", tableName, predicate), nil
+}
+
+// lookupDataSourceTable returns the ReportDataSource named name, and the fully
+// qualified name of its table.
+func (ctx *ReportQueryTemplateContext) lookupDataSourceTable(name string) (*metering.ReportDataSource, string, error) {
 	for _, ds := range ctx.ReportDataSources {
 		if ds.Name == name {
 			if ds.Status.TableRef.Name == "" {
-				return "", fmt.Errorf("%s tableRef is empty", ds.Name)
+				return nil, "", fmt.Errorf("%s tableRef is empty", ds.Name)
 			}
 			for _, prestoTable := range ctx.PrestoTables {
 				if prestoTable.Name == ds.Status.TableRef.Name {
-					return reportingutil.FullyQualifiedTableName(prestoTable)
+					tableName, err := reportingutil.FullyQualifiedTableName(prestoTable)
+					return ds, tableName, err
+				}
+			}
+			return nil, "", fmt.Errorf("tableRef PrestoTable %s not found", ds.Status.TableRef.Name)
+		}
+	}
+	return nil, "", fmt.Errorf("ReportDataSource %s dependency not found", name)
+}
+
+// isDtPartitioned returns true if the ReportDataSource is a Prometheus metrics
+// table, which are partitioned by dt, or a view of a ReportQuery with a dt
+// column, which by convention is the dt partition of the Prometheus metrics
+// the view is selecting from. The view of a snapshot mode Prometheus
+// ReportDataSource has values valid from before the reporting period, so it
+// has no dt column.
+func (ctx *ReportQueryTemplateContext) isDtPartitioned(ds *metering.ReportDataSource) bool {
+	switch {
+	case ds.Spec.PrometheusMetricsImporter != nil:
+		return ds.Spec.PrometheusMetricsImporter.Mode != metering.PrometheusMetricsImporterModeSnapshot
+	case ds.Spec.ReportQueryView != nil:
+		for _, query := range ctx.ReportQueries {
+			if query.Name != ds.Spec.ReportQueryView.QueryName {
+				continue
+			}
+			for _, col := range query.Spec.Columns {
+				if col.Name == dtPartitionColumn {
+					return true
 				}
 			}
-			return "", fmt.Errorf("tableRef PrestoTable %s not found", ds.Status.TableRef.Name)
 		}
 	}
-	return "", fmt.Errorf("ReportDataSource %s dependency not found", name)
+	return false
 }
 
 // reportTableName is a receiver method for ReportQueryTemplateContext, which validates that
@@ -120,7 +181,15 @@ func (ctx *ReportQueryTemplateContext) newQueryTemplate() (*template.Template, e
 		"prometheusMetricPartitionFormat": PrometheusMetricPartitionFormat,
 		"reportTableName":                 ctx.reportTableName,
 		"dataSourceTableName":             ctx.dataSourceTableName,
+		"prunedDataSourceTableName":       ctx.prunedDataSourceTableName,
 		"renderReportQuery":               ctx.renderReportQuery,
+		"dtPartitionPredicate":            DtPartitionPredicate,
+		"timeBucket":                      TimeBucket,
+		"labelSelectorPredicate":          LabelSelectorPredicate,
+		"quoteString":                     QuoteString,
+		"quoteIdentifier":                 QuoteIdentifier,
+		"azureVMName":                     AzureVMName,
+		"azureTagValue":                   AzureTagValue,
 	}
 
 	tmpl, err := template.New("reportQueryTemplate").Delims("{|", "|}").Funcs(templateFuncMap).Funcs(sprig.TxtFuncMap()).Parse(ctx.Query)
@@ -146,7 +215,11 @@ func RenderQuery(ctx *ReportQueryTemplateContext, tmplCtx TemplateContext) (stri
 		return "", fmt.Errorf("missing inputs: %s", strings.Join(missingInputs.List(), ", "))
 	}
 
-	tmpl, err := ctx.newQueryTemplate()
+	// copy the context so the reporting period doesn't leak into the caller's
+	// context
+	renderCtx := *ctx
+	renderCtx.partitionStart, renderCtx.partitionEnd = reportingPeriod(tmplCtx)
+	tmpl, err := renderCtx.newQueryTemplate()
 	if err != nil {
 		return "", err
 	}
@@ -154,6 +227,20 @@ func RenderQuery(ctx *ReportQueryTemplateContext, tmplCtx TemplateContext) (stri
 	return renderTemplate(tmpl, tmplCtx)
 }
 
+// reportingPeriod returns the reporting period of tmplCtx. Like the built-in
+// ReportQueries, the ReportingStart and ReportingEnd inputs take precedence
+// over the period of the Report.
+func reportingPeriod(tmplCtx TemplateContext) (*time.Time, *time.Time) {
+	start, end := tmplCtx.Report.ReportingStart, tmplCtx.Report.ReportingEnd
+	if input, err := toTime(tmplCtx.Report.Inputs["ReportingStart"]); err == nil {
+		start = &input
+	}
+	if input, err := toTime(tmplCtx.Report.Inputs["ReportingEnd"]); err == nil {
+		end = &input
+	}
+	return start, end
+}
+
 func renderTemplate(tmpl *template.Template, tmplCtx TemplateContext) (string, error) {
 	var buf bytes.Buffer
 	err := tmpl.Execute(&buf, tmplCtx)
@@ -166,22 +253,27 @@ func renderTemplate(tmpl *template.Template, tmplCtx TemplateContext) (string, e
 // TimestampFormat checks the type of the input interface parameter and returns that parameter in
 // the form specified by the format string parameter, or an error if it's not able to be converted.
 func TimestampFormat(input interface{}, format string) (string, error) {
-	var err error
-	var d time.Time
+	d, err := toTime(input)
+	if err != nil {
+		return "", err
+	}
+	return d.Format(format), nil
+}
+
+func toTime(input interface{}) (time.Time, error) {
 	switch v := input.(type) {
 	case time.Time:
-		d = v
+		return v, nil
 	case *time.Time:
 		if v == nil {
-			return "", errors.New("got nil timestamp")
+			return time.Time{}, errors.New("got nil timestamp")
 		}
-		d = *v
+		return *v, nil
 	case string:
-		d, err = time.Parse(time.RFC3339, v)
+		return time.Parse(time.RFC3339, v)
 	default:
-		return "", fmt.Errorf("couldn't convert %#v to a Presto timestamp", input)
+		return time.Time{}, fmt.Errorf("couldn't convert %#v to a Presto timestamp", input)
 	}
-	return d.Format(format), err
 }
 
 // PrometheusMetricPartitionFormat is a helper function that returns the Prometheus timestamp partition format
@@ -193,3 +285,146 @@ func PrometheusMetricPartitionFormat(input interface{}) (string, error) {
 func PrestoTimestamp(input interface{}) (string, error) {
 	return TimestampFormat(input, presto.TimestampFormat)
 }
+
+const (
+	dtPartitionColumn = "dt"
+	labelsColumn      = "labels"
+)
+
+// DtPartitionPredicate is a helper function that returns a SQL predicate
+// selecting the dt partitions containing data between start (inclusive) and
+// end (exclusive), which allows Presto to skip reading the other partitions of
+// Prometheus ReportDataSource tables. The partition column defaults to dt, and
+// can be overridden by an optional third argument, eg: a table alias.
+func DtPartitionPredicate(start, end interface{}, column ...string) (string, error) {
+	if len(column) > 1 {
+		return "", fmt.Errorf("expected at most 1 partition column, got %d", len(column))
+	}
+	col := QuoteIdentifier(dtPartitionColumn)
+	if len(column) == 1 {
+		col = column[0]
+	}
+	startTime, err := toTime(start)
+	if err != nil {
+		return "", err
+	}
+	endTime, err := toTime(end)
+	if err != nil {
+		return "", err
+	}
+	if endTime.Before(startTime) {
+		return "", fmt.Errorf("partition end %s is before start %s", endTime, startTime)
+	}
+	// end is exclusive, so if it's exactly at the start of a day, the
+	// partition for that day can't contain any matching rows
+	lastPartition := endTime
+	if endTime.After(startTime) {
+		lastPartition = endTime.Add(-time.Nanosecond)
+	}
+	return fmt.Sprintf("%s >= %s AND %s <= %s",
+		col, QuoteString(prestostore.PrometheusMetricTimestampPartition(startTime)),
+		col, QuoteString(prestostore.PrometheusMetricTimestampPartition(lastPartition)),
+	), nil
+}
+
+var timeBucketGranularities = sets.NewString("hour", "day", "week")
+
+// TimeBucket is a helper function that returns a SQL expression truncating
+// the timestamp expression expr to the start of its hour, day or week.
+func TimeBucket(granularity, expr string) (string, error) {
+	if !timeBucketGranularities.Has(granularity) {
+		return "", fmt.Errorf("invalid time bucket granularity %q, must be one of: %s", granularity, strings.Join(timeBucketGranularities.List(), ", "))
+	}
+	return fmt.Sprintf("date_trunc(%s, %s)", QuoteString(granularity), expr), nil
+}
+
+// LabelSelectorPredicate is a helper function that converts a Kubernetes label
+// selector, eg: "app=foo,tier in (web, api),!canary", into a SQL predicate
+// over the labels map column of Prometheus ReportDataSource tables. The
+// column defaults to labels, and can be overridden by an optional second
+// argument, eg: a table alias. An empty selector matches every row.
+func LabelSelectorPredicate(selector string, column ...string) (string, error) {
+	if len(column) > 1 {
+		return "", fmt.Errorf("expected at most 1 labels column, got %d", len(column))
+	}
+	col := QuoteIdentifier(labelsColumn)
+	if len(column) == 1 {
+		col = column[0]
+	}
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return "", fmt.Errorf("invalid label selector %q: %v", selector, err)
+	}
+	requirements, _ := parsed.Requirements()
+	if len(requirements) == 0 {
+		return "true", nil
+	}
+
+	var predicates []string
+	for _, req := range requirements {
+		value := fmt.Sprintf("element_at(%s, %s)", col, QuoteString(req.Key()))
+		var values []string
+		for _, v := range req.Values().List() {
+			values = append(values, QuoteString(v))
+		}
+
+		var predicate string
+		switch req.Operator() {
+		case selection.Equals, selection.DoubleEquals:
+			predicate = fmt.Sprintf("%s = %s", value, values[0])
+		case selection.NotEquals:
+			predicate = fmt.Sprintf("(%s IS NULL OR %s <> %s)", value, value, values[0])
+		case selection.In:
+			predicate = fmt.Sprintf("%s IN (%s)", value, strings.Join(values, ", "))
+		case selection.NotIn:
+			predicate = fmt.Sprintf("(%s IS NULL OR %s NOT IN (%s))", value, value, strings.Join(values, ", "))
+		case selection.Exists:
+			predicate = fmt.Sprintf("%s IS NOT NULL", value)
+		case selection.DoesNotExist:
+			predicate = fmt.Sprintf("%s IS NULL", value)
+		case selection.GreaterThan:
+			predicate = fmt.Sprintf("try_cast(%s AS bigint) > %s", value, req.Values().List()[0])
+		case selection.LessThan:
+			predicate = fmt.Sprintf("try_cast(%s AS bigint) < %s", value, req.Values().List()[0])
+		default:
+			return "", fmt.Errorf("unsupported label selector operator %q", req.Operator())
+		}
+		predicates = append(predicates, predicate)
+	}
+	return strings.Join(predicates, " AND "), nil
+}
+
+// QuoteString is a helper function that returns s as a SQL string literal.
+func QuoteString(s string) string {
+	return presto.QuoteString(s)
+}
+
+// QuoteIdentifier is a helper function that returns s as a quoted SQL
+// identifier, such as a column name.
+func QuoteIdentifier(s string) string {
+	return presto.QuoteIdentifier(s)
+}
+
+// azureVMResourceRegexp matches the name of a virtual machine or virtual
+// machine scale set in an Azure resource ID or node provider ID.
+const azureVMResourceRegexp = `(?i)/providers/Microsoft\.Compute/(?:virtualMachines|virtualMachineScaleSets)/([^/]+)`
+
+// AzureVMName is a helper function that returns a SQL expression extracting
+// the lowercased name of the virtual machine or scale set from expr, which
+// can be either the ResourceId column of an Azure cost export, or the
+// provider_id of a node, eg:
+// azure:///subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachineScaleSets/<vmss>/virtualMachines/0.
+// This allows Azure VM costs to be joined to nodes. The expression is NULL
+// for other resources.
+func AzureVMName(expr string) string {
+	return fmt.Sprintf("lower(regexp_extract(%s, %s, 1))", expr, QuoteString(azureVMResourceRegexp))
+}
+
+// AzureTagValue is a helper function that returns a SQL expression
+// extracting the value of the tag key from expr, the Tags column of an Azure
+// cost export. Depending on the type of subscription, tags are exported as
+// a JSON object with or without the surrounding braces.
+func AzureTagValue(expr, key string) string {
+	path := fmt.Sprintf(`$["%s"]`, strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key))
+	return fmt.Sprintf("json_extract_scalar(CASE WHEN starts_with(trim(%[1]s), '{') THEN %[1]s ELSE concat('{', %[1]s, '}') END, %[2]s)", expr, QuoteString(path))
+}
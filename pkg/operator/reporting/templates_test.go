@@ -612,3 +612,218 @@ func newTestReportQuery(testReportName, testNamespace, query string, inputs []me
 		},
 	}
 }
+
+func TestQueryHelperTemplateFunctions(t *testing.T) {
+	reportStart := time.Date(2019, time.March, 18, 16, 0, 0, 0, time.UTC)
+	reportEnd := time.Date(2019, time.March, 20, 0, 0, 0, 0, time.UTC)
+
+	testTable := []struct {
+		name      string
+		query     string
+		expectErr bool
+		// expectErrMsg is a substring of the error, as the template
+		// position in error messages varies between Go versions
+		expectErrMsg string
+		expectOutput string
+	}{
+		{
+			name:         "dtPartitionPredicate excludes the partition of an end at midnight",
+			query:        "WHERE {| dtPartitionPredicate .Report.ReportingStart .Report.ReportingEnd |}",
+			expectOutput: `WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-19'`,
+		},
+		{
+			name:         "dtPartitionPredicate with a partition column",
+			query:        "WHERE {| dtPartitionPredicate .Report.ReportingStart \"2019-03-20T00:00:01Z\" \"t.dt\" |}",
+			expectOutput: `WHERE t.dt >= '2019-03-18' AND t.dt <= '2019-03-20'`,
+		},
+		{
+			name:         "dtPartitionPredicate with an end before the start returns error",
+			query:        "WHERE {| dtPartitionPredicate .Report.ReportingEnd .Report.ReportingStart |}",
+			expectErr:    true,
+			expectErrMsg: "error calling dtPartitionPredicate: partition end 2019-03-18 16:00:00 +0000 UTC is before start 2019-03-20 00:00:00 +0000 UTC",
+		},
+		{
+			name:         "timeBucket truncates to the granularity",
+			query:        `SELECT {| timeBucket "hour" "\"timestamp\"" |} AS hour`,
+			expectOutput: `SELECT date_trunc('hour', "timestamp") AS hour`,
+		},
+		{
+			name:         "timeBucket with an invalid granularity returns error",
+			query:        `SELECT {| timeBucket "year" "\"timestamp\"" |}`,
+			expectErr:    true,
+			expectErrMsg: "error calling timeBucket: invalid time bucket granularity \"year\", must be one of: day, hour, week",
+		},
+		{
+			name:         "labelSelectorPredicate converts every operator",
+			query:        `WHERE {| labelSelectorPredicate "app=foo,tier in (web,api),env!=dev,!canary,release,replicas>2" |}`,
+			expectOutput: `WHERE element_at("labels", 'app') = 'foo' AND element_at("labels", 'canary') IS NULL AND (element_at("labels", 'env') IS NULL OR element_at("labels", 'env') <> 'dev') AND element_at("labels", 'release') IS NOT NULL AND try_cast(element_at("labels", 'replicas') AS bigint) > 2 AND element_at("labels", 'tier') IN ('api', 'web')`,
+		},
+		{
+			name:         "labelSelectorPredicate with a labels column and notin",
+			query:        `WHERE {| labelSelectorPredicate "namespace notin (kube-system)" "p.labels" |}`,
+			expectOutput: `WHERE (element_at(p.labels, 'namespace') IS NULL OR element_at(p.labels, 'namespace') NOT IN ('kube-system'))`,
+		},
+		{
+			name:         "labelSelectorPredicate with an empty selector matches everything",
+			query:        `WHERE {| labelSelectorPredicate "" |}`,
+			expectOutput: `WHERE true`,
+		},
+		{
+			name:      "labelSelectorPredicate with an invalid selector returns error",
+			query:     `WHERE {| labelSelectorPredicate "app in" |}`,
+			expectErr: true,
+		},
+		{
+			name:         "quoteString and quoteIdentifier escape quotes",
+			query:        `SELECT {| quoteIdentifier "my\"column" |} FROM t WHERE name = {| quoteString "o'brien" |}`,
+			expectOutput: `SELECT "my""column" FROM t WHERE name = 'o''brien'`,
+		},
+		{
+			name:         "azureVMName extracts virtual machine names",
+			query:        `SELECT {| azureVMName "c.resourceid" |}`,
+			expectOutput: `SELECT lower(regexp_extract(c.resourceid, '(?i)/providers/Microsoft\.Compute/(?:virtualMachines|virtualMachineScaleSets)/([^/]+)', 1))`,
+		},
+		{
+			name:         "azureTagValue extracts a tag",
+			query:        `SELECT {| azureTagValue "tags" "kubernetes.io-created-for-pvc-name" |}`,
+			expectOutput: `SELECT json_extract_scalar(CASE WHEN starts_with(trim(tags), '{') THEN tags ELSE concat('{', tags, '}') END, '$["kubernetes.io-created-for-pvc-name"]')`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			output, err := RenderQuery(&ReportQueryTemplateContext{Query: testCase.query}, TemplateContext{
+				Report: ReportTemplateInfo{
+					ReportingStart: &reportStart,
+					ReportingEnd:   &reportEnd,
+				},
+			})
+
+			if testCase.expectErr {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), testCase.expectErrMsg)
+				}
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, testCase.expectOutput, output)
+			}
+		})
+	}
+}
+
+func TestDataSourceTableNamePartitionPruning(t *testing.T) {
+	const testNamespace = "default"
+	reportStart := time.Date(2019, time.March, 18, 0, 0, 0, 0, time.UTC)
+	reportEnd := time.Date(2019, time.March, 19, 0, 0, 0, 0, time.UTC)
+	inputStart := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	promDS := testhelpers.NewReportDataSource("prom", testNamespace)
+	promDS.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{}
+	promDS.Status.TableRef = v1.LocalObjectReference{Name: "prom_table"}
+
+	snapshotDS := testhelpers.NewReportDataSource("snapshot", testNamespace)
+	snapshotDS.Spec.PrometheusMetricsImporter = &metering.PrometheusMetricsImporterDataSource{Mode: metering.PrometheusMetricsImporterModeSnapshot}
+	snapshotDS.Status.TableRef = v1.LocalObjectReference{Name: "snapshot_table"}
+
+	viewDS := testhelpers.NewReportDataSource("view", testNamespace)
+	viewDS.Spec.ReportQueryView = &metering.ReportQueryViewDataSource{QueryName: "view-query"}
+	viewDS.Status.TableRef = v1.LocalObjectReference{Name: "view_table"}
+
+	noDtViewDS := testhelpers.NewReportDataSource("no-dt-view", testNamespace)
+	noDtViewDS.Spec.ReportQueryView = &metering.ReportQueryViewDataSource{QueryName: "no-dt-query"}
+	noDtViewDS.Status.TableRef = v1.LocalObjectReference{Name: "no_dt_view_table"}
+
+	viewQuery := newTestReportQuery("view-query", testNamespace, "", nil)
+	viewQuery.Spec.Columns = []metering.ReportQueryColumn{{Name: "amount", Type: "double"}, {Name: "dt", Type: "varchar"}}
+	noDtQuery := newTestReportQuery("no-dt-query", testNamespace, "", nil)
+	noDtQuery.Spec.Columns = []metering.ReportQueryColumn{{Name: "amount", Type: "double"}}
+
+	newCtx := func(query string) *ReportQueryTemplateContext {
+		return &ReportQueryTemplateContext{
+			Namespace:         testNamespace,
+			Query:             query,
+			ReportQueries:     []*metering.ReportQuery{viewQuery, noDtQuery},
+			ReportDataSources: []*metering.ReportDataSource{promDS, snapshotDS, viewDS, noDtViewDS},
+			PrestoTables: []*metering.PrestoTable{
+				newTestPrestoTable("prom_table", testNamespace, "default", "hive", nil),
+				newTestPrestoTable("snapshot_table", testNamespace, "default", "hive", nil),
+				newTestPrestoTable("view_table", testNamespace, "default", "hive", nil),
+				newTestPrestoTable("no_dt_view_table", testNamespace, "default", "hive", nil),
+			},
+		}
+	}
+	period := TemplateContext{
+		Report: ReportTemplateInfo{
+			ReportingStart: &reportStart,
+			ReportingEnd:   &reportEnd,
+		},
+	}
+
+	testTable := []struct {
+		name            string
+		query           string
+		templateContext TemplateContext
+		expectOutput    string
+	}{
+		{
+			name:            "Prometheus ReportDataSource tables only read the partitions of the reporting period",
+			query:           `SELECT * FROM {| prunedDataSourceTableName "prom" |}`,
+			templateContext: period,
+			expectOutput:    `SELECT * FROM (SELECT * FROM hive.default.prom_table WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')`,
+		},
+		{
+			name:            "snapshot mode Prometheus ReportDataSource views aren't pruned",
+			query:           `SELECT * FROM {| prunedDataSourceTableName "snapshot" |}`,
+			templateContext: period,
+			expectOutput:    `SELECT * FROM hive.default.snapshot_table`,
+		},
+		{
+			name:            "views with a dt column only read the partitions of the reporting period",
+			query:           `SELECT * FROM {| prunedDataSourceTableName "view" |}`,
+			templateContext: period,
+			expectOutput:    `SELECT * FROM (SELECT * FROM hive.default.view_table WHERE "dt" >= '2019-03-18' AND "dt" <= '2019-03-18')`,
+		},
+		{
+			name:            "views without a dt column aren't pruned",
+			query:           `SELECT * FROM {| prunedDataSourceTableName "no-dt-view" |}`,
+			templateContext: period,
+			expectOutput:    `SELECT * FROM hive.default.no_dt_view_table`,
+		},
+		{
+			name:            "tables aren't pruned without a reporting period",
+			query:           `SELECT * FROM {| prunedDataSourceTableName "prom" |}`,
+			templateContext: TemplateContext{},
+			expectOutput:    `SELECT * FROM hive.default.prom_table`,
+		},
+		{
+			name:            "dataSourceTableName reads every partition",
+			query:           `SELECT * FROM {| dataSourceTableName "prom" |}`,
+			templateContext: period,
+			expectOutput:    `SELECT * FROM hive.default.prom_table`,
+		},
+		{
+			name:  "the ReportingStart input takes precedence over the reporting period",
+			query: `SELECT * FROM {| prunedDataSourceTableName "prom" |}`,
+			templateContext: TemplateContext{
+				Report: ReportTemplateInfo{
+					ReportingStart: &reportStart,
+					ReportingEnd:   &reportEnd,
+					Inputs:         map[string]interface{}{"ReportingStart": &inputStart},
+				},
+			},
+			expectOutput: `SELECT * FROM (SELECT * FROM hive.default.prom_table WHERE "dt" >= '2019-03-01' AND "dt" <= '2019-03-18')`,
+		},
+	}
+
+	for _, testCase := range testTable {
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			output, err := RenderQuery(newCtx(testCase.query), testCase.templateContext)
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectOutput, output)
+		})
+	}
+}
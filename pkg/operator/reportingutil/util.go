@@ -1,6 +1,8 @@
 package reportingutil
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -23,6 +25,24 @@ func DataSourceTableName(namespace, dataSourceName string) string {
 	return fmt.Sprintf("datasource_%s_%s", resourceNameReplacer.Replace(namespace), resourceNameReplacer.Replace(dataSourceName))
 }
 
+// externalDatabaseCatalogNameMaxLength limits the readable part of the
+// names of ExternalDatabase catalogs, whose properties are stored in Secret
+// keys of at most 253 characters.
+const externalDatabaseCatalogNameMaxLength = 200
+
+// ExternalDatabaseCatalogName is the name of the Presto catalog of an
+// ExternalDatabase ReportDataSource. The namespace and name are replaced
+// like in table names, so the catalog name ends with a hash of them to
+// keep ReportDataSources like a-b and a.b from sharing a catalog.
+func ExternalDatabaseCatalogName(namespace, dataSourceName string) string {
+	name := fmt.Sprintf("external_%s_%s", resourceNameReplacer.Replace(namespace), resourceNameReplacer.Replace(dataSourceName))
+	if len(name) > externalDatabaseCatalogNameMaxLength {
+		name = name[:externalDatabaseCatalogNameMaxLength]
+	}
+	sum := sha256.Sum256([]byte(namespace + "/" + dataSourceName))
+	return name + "_" + hex.EncodeToString(sum[:8])
+}
+
 func ReportTableName(namespace, reportName string) string {
 	return fmt.Sprintf("report_%s_%s", resourceNameReplacer.Replace(namespace), resourceNameReplacer.Replace(reportName))
 }
@@ -87,6 +107,24 @@ func GenerateHiveColumns(query *metering.ReportQuery) []hive.Column {
 	return columns
 }
 
+// DiffHiveColumns compares the columns of an existing table against the
+// desired columns. If the desired columns only append new columns to the
+// existing columns, compatible is true and added contains the new columns.
+// Any other difference, such as a removed, renamed, re-ordered or re-typed
+// column is incompatible, because rows are inserted into a table by
+// position.
+func DiffHiveColumns(current, desired []hive.Column) (added []hive.Column, compatible bool) {
+	if len(desired) < len(current) {
+		return nil, false
+	}
+	for i, col := range current {
+		if col.Name != desired[i].Name || !strings.EqualFold(col.Type, desired[i].Type) {
+			return nil, false
+		}
+	}
+	return desired[len(current):], true
+}
+
 func GeneratePrestoColumns(query *metering.ReportQuery) []presto.Column {
 	var columns []presto.Column
 	for _, col := range query.Spec.Columns {
@@ -142,6 +180,15 @@ func HiveColumnToPrestoColumn(column hive.Column) (presto.Column, error) {
 	} else {
 		colType = strings.ToUpper(column.Type)
 		switch {
+		case isNestedHiveColumnType(colType):
+			prestoType, err := hiveColumnTypeToPrestoColumnType(column.Type)
+			if err != nil {
+				return presto.Column{}, fmt.Errorf("unsupported hive type for column %q: %v", column.Name, err)
+			}
+			return presto.Column{
+				Name: column.Name,
+				Type: prestoType,
+			}, nil
 		case strings.Contains(colType, "MAP"):
 			// does not support maps with arrays inside them
 			if strings.Contains(colType, "ARRAY") {
@@ -185,6 +232,129 @@ func HiveColumnToPrestoColumn(column hive.Column) (presto.Column, error) {
 	return presto.Column{}, fmt.Errorf("unsupported hive type: %q", column.Type)
 }
 
+// isNestedHiveColumnType returns true if colType is a parameterized type
+// other than a map of simple types, such as a struct, an array, a decimal, or
+// a map of nested types.
+func isNestedHiveColumnType(colType string) bool {
+	colType = strings.ToUpper(strings.TrimSpace(colType))
+	switch {
+	case strings.HasPrefix(colType, "STRUCT"), strings.HasPrefix(colType, "ARRAY"), strings.HasPrefix(colType, "DECIMAL"):
+		return true
+	case strings.HasPrefix(colType, "MAP"):
+		return strings.Count(colType, "<") > 1
+	}
+	return false
+}
+
+// hiveColumnTypeToPrestoColumnType converts colType into the Presto type,
+// recursively converting the types of structs, arrays and maps. Structs are
+// converted into rows.
+func hiveColumnTypeToPrestoColumnType(colType string) (string, error) {
+	colType = strings.TrimSpace(colType)
+	upper := strings.ToUpper(colType)
+	if prestoType := SimpleHiveColumnTypeToPrestoColumnType(upper); prestoType != "" {
+		return prestoType, nil
+	}
+
+	if strings.HasPrefix(upper, "DECIMAL") {
+		args := strings.Replace(strings.TrimSpace(upper[len("DECIMAL"):]), " ", "", -1)
+		if args == "" {
+			// the Hive default
+			return "DECIMAL(10,0)", nil
+		}
+		var precision, scale int
+		if _, err := fmt.Sscanf(args, "(%d,%d)", &precision, &scale); err != nil {
+			return "", fmt.Errorf("invalid decimal type %q", colType)
+		}
+		return fmt.Sprintf("DECIMAL(%d,%d)", precision, scale), nil
+	}
+
+	open := strings.Index(colType, "<")
+	if open == -1 || !strings.HasSuffix(colType, ">") {
+		return "", fmt.Errorf("unsupported type %q", colType)
+	}
+	kind := strings.ToUpper(strings.TrimSpace(colType[:open]))
+	args, err := splitHiveTypeArguments(colType[open+1 : len(colType)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid type %q: %v", colType, err)
+	}
+
+	switch kind {
+	case "ARRAY":
+		if len(args) != 1 {
+			return "", fmt.Errorf("invalid array type %q", colType)
+		}
+		elemType, err := hiveColumnTypeToPrestoColumnType(args[0])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("array(%s)", elemType), nil
+	case "MAP":
+		if len(args) != 2 {
+			return "", fmt.Errorf("invalid map type %q", colType)
+		}
+		keyType, err := hiveColumnTypeToPrestoColumnType(args[0])
+		if err != nil {
+			return "", err
+		}
+		valueType, err := hiveColumnTypeToPrestoColumnType(args[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("map(%s,%s)", keyType, valueType), nil
+	case "STRUCT":
+		fields := make([]string, len(args))
+		for i, arg := range args {
+			colon := strings.Index(arg, ":")
+			if colon == -1 {
+				return "", fmt.Errorf("invalid struct field %q of type %q", arg, colType)
+			}
+			name := strings.ToLower(strings.TrimSpace(arg[:colon]))
+			fieldType, err := hiveColumnTypeToPrestoColumnType(arg[colon+1:])
+			if err != nil {
+				return "", err
+			}
+			fields[i] = fmt.Sprintf("%s %s", name, fieldType)
+		}
+		return fmt.Sprintf("row(%s)", strings.Join(fields, ",")), nil
+	}
+	return "", fmt.Errorf("unsupported type %q", colType)
+}
+
+// splitHiveTypeArguments splits the comma separated arguments of a
+// parameterized type, such as the fields of a struct, ignoring commas within
+// nested types.
+func splitHiveTypeArguments(s string) ([]string, error) {
+	var args []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '<', '(':
+			depth++
+		case '>', ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced %q", c)
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced type arguments %q", s)
+	}
+	args = append(args, strings.TrimSpace(s[start:]))
+	for _, arg := range args {
+		if arg == "" {
+			return nil, fmt.Errorf("empty type argument in %q", s)
+		}
+	}
+	return args, nil
+}
+
 func SimplePrestoColumnTypeToHiveColumnType(colType string) string {
 	colType = strings.ToUpper(colType)
 	switch colType {
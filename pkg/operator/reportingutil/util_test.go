@@ -2,6 +2,7 @@ package reportingutil
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/kube-reporting/metering-operator/pkg/hive"
@@ -111,6 +112,72 @@ func TestHiveColumnToPrestoColumn(t *testing.T) {
 			errAssertion:     assert.Error,
 			expectedErr:      fmt.Errorf(`invalid presto map key type: ""`),
 		},
+		"decimal(38, 9) to DECIMAL(38,9)": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "decimal(38, 9)",
+			},
+			expectedPrestoColumn: presto.Column{
+				Name: "foo",
+				Type: "DECIMAL(38,9)",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.NoError,
+		},
+		"array<string> to array(VARCHAR)": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "array<string>",
+			},
+			expectedPrestoColumn: presto.Column{
+				Name: "foo",
+				Type: "array(VARCHAR)",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.NoError,
+		},
+		"nested struct to row": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "struct<Name:string, tags:map<string,array<string>>, cost:struct<amount:double,unit:string>>",
+			},
+			expectedPrestoColumn: presto.Column{
+				Name: "foo",
+				Type: "row(name VARCHAR,tags map(VARCHAR,array(VARCHAR)),cost row(amount DOUBLE,unit VARCHAR))",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.NoError,
+		},
+		"map<string, struct<...>> to map(VARCHAR,row(...))": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "map<string, struct<a:double>>",
+			},
+			expectedPrestoColumn: presto.Column{
+				Name: "foo",
+				Type: "map(VARCHAR,row(a DOUBLE))",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.NoError,
+		},
+		"broken struct struct<a> error": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "struct<a>",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.Error,
+			expectedErr:      fmt.Errorf(`unsupported hive type for column "foo": invalid struct field "a" of type "struct<a>"`),
+		},
+		"broken array array<string error": {
+			hiveColumn: hive.Column{
+				Name: "foo",
+				Type: "array<string",
+			},
+			compareAssertion: assert.Equal,
+			errAssertion:     assert.Error,
+			expectedErr:      fmt.Errorf(`unsupported hive type for column "foo": unsupported type "array<string"`),
+		},
 	}
 
 	for testName, tt := range tests {
@@ -127,3 +194,82 @@ func TestHiveColumnToPrestoColumn(t *testing.T) {
 		})
 	}
 }
+
+func TestDiffHiveColumns(t *testing.T) {
+	current := []hive.Column{
+		{Name: "namespace", Type: "string"},
+		{Name: "pod_request_cpu_core_seconds", Type: "double"},
+	}
+	tests := map[string]struct {
+		desired            []hive.Column
+		expectedAdded      []hive.Column
+		expectedCompatible bool
+	}{
+		"unchanged": {
+			desired:            current,
+			expectedAdded:      []hive.Column{},
+			expectedCompatible: true,
+		},
+		"type case differs": {
+			desired: []hive.Column{
+				{Name: "namespace", Type: "STRING"},
+				{Name: "pod_request_cpu_core_seconds", Type: "DOUBLE"},
+			},
+			expectedAdded:      []hive.Column{},
+			expectedCompatible: true,
+		},
+		"appended column": {
+			desired: []hive.Column{
+				{Name: "namespace", Type: "string"},
+				{Name: "pod_request_cpu_core_seconds", Type: "double"},
+				{Name: "node", Type: "string"},
+			},
+			expectedAdded:      []hive.Column{{Name: "node", Type: "string"}},
+			expectedCompatible: true,
+		},
+		"inserted column": {
+			desired: []hive.Column{
+				{Name: "node", Type: "string"},
+				{Name: "namespace", Type: "string"},
+				{Name: "pod_request_cpu_core_seconds", Type: "double"},
+			},
+		},
+		"removed column": {
+			desired: []hive.Column{
+				{Name: "namespace", Type: "string"},
+			},
+		},
+		"changed type": {
+			desired: []hive.Column{
+				{Name: "namespace", Type: "string"},
+				{Name: "pod_request_cpu_core_seconds", Type: "bigint"},
+			},
+		},
+	}
+
+	for testName, tt := range tests {
+		testName := testName
+		tt := tt
+		t.Run(testName, func(t *testing.T) {
+			added, compatible := DiffHiveColumns(current, tt.desired)
+			assert.Equal(t, tt.expectedCompatible, compatible)
+			if tt.expectedCompatible {
+				assert.Equal(t, tt.expectedAdded, added)
+			}
+		})
+	}
+}
+
+func TestExternalDatabaseCatalogName(t *testing.T) {
+	name := ExternalDatabaseCatalogName("metering", "cmdb-cost-centers")
+	assert.Regexp(t, `^external_metering_cmdb_cost_centers_[0-9a-f]{16}$`, name)
+	assert.Equal(t, name, ExternalDatabaseCatalogName("metering", "cmdb-cost-centers"))
+
+	// the replaced namespaces and names are the same, the hashes aren't
+	assert.NotEqual(t, ExternalDatabaseCatalogName("metering", "a-b"), ExternalDatabaseCatalogName("metering", "a.b"))
+	assert.NotEqual(t, ExternalDatabaseCatalogName("a_b", "c"), ExternalDatabaseCatalogName("a", "b_c"))
+
+	long := ExternalDatabaseCatalogName("metering", strings.Repeat("a", 253))
+	assert.Len(t, long, externalDatabaseCatalogNameMaxLength+17)
+	assert.NotEqual(t, long, ExternalDatabaseCatalogName("metering", strings.Repeat("a", 252)))
+}
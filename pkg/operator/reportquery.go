@@ -6,6 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
 
 	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
@@ -47,7 +48,12 @@ func (op *defaultReportingOperator) syncReportQuery(logger log.FieldLogger, key
 
 func (op *defaultReportingOperator) handleReportQuery(logger log.FieldLogger, query *metering.ReportQuery) error {
 	// queue any reportDataSources using this query to create views
-	return op.queueDependentReportDataSourcesForQuery(query)
+	if err := op.queueDependentReportDataSourcesForQuery(query); err != nil {
+		return err
+	}
+	// queue any reports using this query so changes to the query's columns
+	// are applied to their tables
+	return op.queueDependentReportsForQuery(query)
 }
 
 func (op *defaultReportingOperator) uninitialiedDependendenciesHandler() *reporting.UninitialiedDependendenciesHandler {
@@ -70,3 +76,17 @@ func (op *defaultReportingOperator) queueDependentReportDataSourcesForQuery(quer
 	}
 	return nil
 }
+
+func (op *defaultReportingOperator) queueDependentReportsForQuery(query *metering.ReportQuery) error {
+	reports, err := op.reportLister.Reports(query.Namespace).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if report.Spec.QueryName == query.Name {
+			op.enqueueReport(report)
+		}
+	}
+	return nil
+}
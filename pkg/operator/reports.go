@@ -461,101 +461,20 @@ func (op *defaultReportingOperator) runReport(logger log.FieldLogger, report *me
 			return err
 		}
 		logger.Infof("Report %s table already exists, tableName: %s", report.Name, tableName)
-	} else {
-		tableName := reportingutil.ReportTableName(report.Namespace, report.Name)
-		hiveStorage, err := op.getHiveStorage(report.Spec.Output, report.Namespace)
-		if err != nil {
-			return fmt.Errorf("storage incorrectly configured for Report %s, err: %v", report.Name, err)
-		}
-		if hiveStorage.Status.Hive.DatabaseName == "" {
-			op.enqueueStorageLocation(hiveStorage)
-			return fmt.Errorf("StorageLocation %s Hive database %s does not exist yet", hiveStorage.Name, hiveStorage.Spec.Hive.DatabaseName)
-		}
-
-		cols, err := reportingutil.PrestoColumnsToHiveColumns(reportingutil.GeneratePrestoColumns(reportQuery))
-		if err != nil {
-			return fmt.Errorf("unable to convert Presto columns to Hive columns: %s", err)
-		}
-
-		params := hive.TableParameters{
-			Database: hiveStorage.Status.Hive.DatabaseName,
-			Name:     tableName,
-			Columns:  cols,
-		}
-		if hiveStorage.Spec.Hive.DefaultTableProperties != nil {
-			params.RowFormat = hiveStorage.Spec.Hive.DefaultTableProperties.RowFormat
-			params.FileFormat = hiveStorage.Spec.Hive.DefaultTableProperties.FileFormat
-		}
-
-		logger.Infof("creating Hive table %s in database %s", tableName, hiveStorage.Status.Hive.DatabaseName)
-		hiveTable, err := op.createHiveTableCR(report, metering.ReportGVK, params, false, nil)
-		if err != nil {
-			return fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
-		}
-		hiveTable, err = op.waitForHiveTable(hiveTable.Namespace, hiveTable.Name, time.Second, 20*time.Second)
-		if err != nil {
-			return fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
-		}
-		prestoTable, err = op.waitForPrestoTable(hiveTable.Namespace, hiveTable.Name, time.Second, 20*time.Second)
-		if err != nil {
-			return fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
-		}
 
-		logger.Infof("created Hive table %s in database %s", tableName, hiveStorage.Status.Hive.DatabaseName)
-
-		tableName, err = reportingutil.FullyQualifiedTableName(prestoTable)
+		var schemaIncompatible bool
+		report, prestoTable, schemaIncompatible, err = op.reconcileReportTableSchema(logger, report, reportQuery, prestoTable)
 		if err != nil {
 			return err
 		}
-		dataSourceName := fmt.Sprintf("report-%s", report.Name)
-
-		logger.Infof("creating PrestoTable ReportDataSource %s pointing at report table %s", dataSourceName, tableName)
-		ownerRef := metav1.NewControllerRef(prestoTable, metering.PrestoTableGVK)
-		newReportDataSource := &metering.ReportDataSource{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "ReportDataSource",
-				APIVersion: metering.ReportDataSourceGVK.GroupVersion().String(),
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      dataSourceName,
-				Namespace: prestoTable.Namespace,
-				Labels:    prestoTable.ObjectMeta.Labels,
-				OwnerReferences: []metav1.OwnerReference{
-					*ownerRef,
-				},
-			},
-			Spec: metering.ReportDataSourceSpec{
-				PrestoTable: &metering.PrestoTableDataSource{
-					TableRef: v1.LocalObjectReference{
-						Name: prestoTable.Name,
-					},
-				},
-			},
-		}
-		_, err = op.meteringClient.MeteringV1().ReportDataSources(report.Namespace).Create(context.TODO(), newReportDataSource, metav1.CreateOptions{})
-		if err != nil {
-			if apierrors.IsAlreadyExists(err) {
-				logger.Infof("ReportDataSource %s already exists", dataSourceName)
-			} else {
-				return fmt.Errorf("error creating PrestoTable ReportDataSource %s: %s", dataSourceName, err)
-			}
+		if schemaIncompatible {
+			return nil
 		}
-		logger.Infof("created PrestoTable ReportDataSource %s", dataSourceName)
-
-		report.Status.TableRef = v1.LocalObjectReference{Name: hiveTable.Name}
-		report, err = op.meteringClient.MeteringV1().Reports(report.Namespace).Update(context.TODO(), report, metav1.UpdateOptions{})
+	} else {
+		report, prestoTable, err = op.createReportTable(logger, report, reportQuery)
 		if err != nil {
-			logger.WithError(err).Errorf("unable to update Report status with tableName")
 			return err
 		}
-
-		// queue dependents so that they're aware the table now exists
-		if err := op.queueDependentReportQueriesForReport(report); err != nil {
-			logger.WithError(err).Errorf("error queuing ReportQuery dependents of Report %s", report.Name)
-		}
-		if err := op.queueDependentReportsForReport(report); err != nil {
-			logger.WithError(err).Errorf("error queuing Report dependents of Report %s", report.Name)
-		}
 	}
 
 	runningCond := meteringUtil.GetReportCondition(report.Status, metering.ReportRunning)
@@ -763,6 +682,14 @@ func (op *defaultReportingOperator) runReport(logger log.FieldLogger, report *me
 
 	// Update the LastReportTime on the report status
 	report.Status.LastReportTime = &metav1.Time{Time: reportPeriod.periodEnd}
+	recordReportQueryGeneration(&report.Status, reportQuery.Generation, reportPeriod)
+	// the results of earlier periods were deleted, including any generated
+	// from data which has since changed
+	if report.Spec.OverwriteExistingData {
+		if condErr := meteringUtil.RemoveReportCondition(&report.Status, metering.ReportStale); condErr != nil {
+			return condErr
+		}
+	}
 
 	// check if we've reached the configured ReportingEnd, and if so, update
 	// the status to indicate the report has finished
@@ -820,6 +747,218 @@ func (op *defaultReportingOperator) runReport(logger log.FieldLogger, report *me
 	return nil
 }
 
+// createReportTable creates the HiveTable, PrestoTable and PrestoTable
+// ReportDataSource storing the results of the Report, and updates the Report
+// status.tableRef to point at the new table.
+func (op *defaultReportingOperator) createReportTable(logger log.FieldLogger, report *metering.Report, reportQuery *metering.ReportQuery) (*metering.Report, *metering.PrestoTable, error) {
+	tableName := reportingutil.ReportTableName(report.Namespace, report.Name)
+	resourceName := reportingutil.TableResourceNameFromKind(metering.ReportGVK.Kind, report.Namespace, report.Name)
+	// tables created because of incompatible ReportQuery changes are
+	// versioned, the first table isn't so that existing Reports keep using
+	// their original table name
+	if report.Status.TableVersion != 0 {
+		tableName = fmt.Sprintf("%s_v%d", tableName, report.Status.TableVersion)
+		resourceName = fmt.Sprintf("%s-v%d", resourceName, report.Status.TableVersion)
+	}
+	hiveStorage, err := op.getHiveStorage(report.Spec.Output, report.Namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage incorrectly configured for Report %s, err: %v", report.Name, err)
+	}
+	if hiveStorage.Status.Hive.DatabaseName == "" {
+		op.enqueueStorageLocation(hiveStorage)
+		return nil, nil, fmt.Errorf("StorageLocation %s Hive database %s does not exist yet", hiveStorage.Name, hiveStorage.Spec.Hive.DatabaseName)
+	}
+
+	cols, err := reportingutil.PrestoColumnsToHiveColumns(reportingutil.GeneratePrestoColumns(reportQuery))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to convert Presto columns to Hive columns: %s", err)
+	}
+
+	params := hive.TableParameters{
+		Database: hiveStorage.Status.Hive.DatabaseName,
+		Name:     tableName,
+		Columns:  cols,
+	}
+	if hiveStorage.Spec.Hive.DefaultTableProperties != nil {
+		params.RowFormat = hiveStorage.Spec.Hive.DefaultTableProperties.RowFormat
+		params.FileFormat = hiveStorage.Spec.Hive.DefaultTableProperties.FileFormat
+	}
+
+	logger.Infof("creating Hive table %s in database %s", tableName, hiveStorage.Status.Hive.DatabaseName)
+	hiveTable, err := op.createNamedHiveTableCR(resourceName, report, metering.ReportGVK, params, false, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
+	}
+	hiveTable, err = op.waitForHiveTable(hiveTable.Namespace, hiveTable.Name, time.Second, 20*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
+	}
+	prestoTable, err := op.waitForPrestoTable(hiveTable.Namespace, hiveTable.Name, time.Second, 20*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating table for Report %s: %s", report.Name, err)
+	}
+
+	logger.Infof("created Hive table %s in database %s", tableName, hiveStorage.Status.Hive.DatabaseName)
+
+	tableName, err = reportingutil.FullyQualifiedTableName(prestoTable)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataSourceName := fmt.Sprintf("report-%s", report.Name)
+
+	logger.Infof("creating PrestoTable ReportDataSource %s pointing at report table %s", dataSourceName, tableName)
+	ownerRef := metav1.NewControllerRef(prestoTable, metering.PrestoTableGVK)
+	newReportDataSource := &metering.ReportDataSource{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "ReportDataSource",
+			APIVersion: metering.ReportDataSourceGVK.GroupVersion().String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dataSourceName,
+			Namespace: prestoTable.Namespace,
+			Labels:    prestoTable.ObjectMeta.Labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*ownerRef,
+			},
+		},
+		Spec: metering.ReportDataSourceSpec{
+			PrestoTable: &metering.PrestoTableDataSource{
+				TableRef: v1.LocalObjectReference{
+					Name: prestoTable.Name,
+				},
+			},
+		},
+	}
+	_, err = op.meteringClient.MeteringV1().ReportDataSources(report.Namespace).Create(context.TODO(), newReportDataSource, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			logger.Infof("ReportDataSource %s already exists", dataSourceName)
+			// point the existing ReportDataSource at the new table if the
+			// Report table was re-created
+			err = op.updateReportTableDataSource(dataSourceName, prestoTable)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error updating PrestoTable ReportDataSource %s: %s", dataSourceName, err)
+			}
+		} else {
+			return nil, nil, fmt.Errorf("error creating PrestoTable ReportDataSource %s: %s", dataSourceName, err)
+		}
+	}
+	logger.Infof("created PrestoTable ReportDataSource %s", dataSourceName)
+
+	report.Status.TableRef = v1.LocalObjectReference{Name: hiveTable.Name}
+	report, err = op.meteringClient.MeteringV1().Reports(report.Namespace).Update(context.TODO(), report, metav1.UpdateOptions{})
+	if err != nil {
+		logger.WithError(err).Errorf("unable to update Report status with tableName")
+		return nil, nil, err
+	}
+
+	// queue dependents so that they're aware the table now exists
+	if err := op.queueDependentReportQueriesForReport(report); err != nil {
+		logger.WithError(err).Errorf("error queuing ReportQuery dependents of Report %s", report.Name)
+	}
+	if err := op.queueDependentReportsForReport(report); err != nil {
+		logger.WithError(err).Errorf("error queuing Report dependents of Report %s", report.Name)
+	}
+	return report, prestoTable, nil
+}
+
+// reconcileReportTableSchema compares the columns of the existing Report
+// table against the columns of the ReportQuery. Appended columns are added to
+// the existing table. Any other change is handled according to the Report
+// spec.schemaChangePolicy: either a new table is created, or the Report is
+// marked SchemaIncompatible, in which case schemaIncompatible is true and the
+// Report should not run.
+func (op *defaultReportingOperator) reconcileReportTableSchema(logger log.FieldLogger, report *metering.Report, reportQuery *metering.ReportQuery, prestoTable *metering.PrestoTable) (_ *metering.Report, _ *metering.PrestoTable, schemaIncompatible bool, err error) {
+	hiveTable, err := op.hiveTableLister.HiveTables(report.Namespace).Get(report.Status.TableRef.Name)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("unable to get HiveTable %s for Report %s, %s", report.Status.TableRef.Name, report.Name, err)
+	}
+	cols, err := reportingutil.PrestoColumnsToHiveColumns(reportingutil.GeneratePrestoColumns(reportQuery))
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("unable to convert Presto columns to Hive columns: %s", err)
+	}
+
+	added, compatible := reportingutil.DiffHiveColumns(hiveTable.Spec.Columns, cols)
+	if compatible && len(added) == 0 {
+		return report, prestoTable, false, nil
+	}
+
+	if compatible {
+		var addedNames []string
+		for _, col := range added {
+			addedNames = append(addedNames, col.Name)
+		}
+		logger.Infof("ReportQuery %s added columns [%s], adding them to the Report %s table", reportQuery.Name, strings.Join(addedNames, ", "), report.Name)
+
+		hiveTable = hiveTable.DeepCopy()
+		hiveTable.Spec.Columns = cols
+		hiveTable, err = op.meteringClient.MeteringV1().HiveTables(hiveTable.Namespace).Update(context.TODO(), hiveTable, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("unable to update HiveTable %s columns: %s", hiveTable.Name, err)
+		}
+		_, err = op.waitForHiveTableColumns(hiveTable.Namespace, hiveTable.Name, cols, time.Second, 20*time.Second)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("error adding columns to table for Report %s: %s", report.Name, err)
+		}
+		prestoTable, err = op.meteringClient.MeteringV1().PrestoTables(prestoTable.Namespace).Get(context.TODO(), prestoTable.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return report, prestoTable, false, nil
+	}
+
+	msg := fmt.Sprintf("The columns of ReportQuery %s are incompatible with the columns of the Report table %s. Columns can only be appended to a ReportQuery used by an existing Report.", reportQuery.Name, hiveTable.Status.TableName)
+	if report.Spec.SchemaChangePolicy != metering.ReportSchemaChangePolicyNewTable {
+		// don't update unless the message changes
+		if runningCond := meteringUtil.GetReportCondition(report.Status, metering.ReportRunning); runningCond != nil && runningCond.Status == v1.ConditionFalse && runningCond.Reason == meteringUtil.SchemaIncompatibleReason && runningCond.Message == msg {
+			return report, prestoTable, true, nil
+		}
+		logger.Warnf(msg)
+		report, err = op.updateReportStatus(report, meteringUtil.NewReportCondition(metering.ReportRunning, v1.ConditionFalse, meteringUtil.SchemaIncompatibleReason, msg))
+		return report, prestoTable, true, err
+	}
+
+	logger.Infof("%s Creating a new table for Report %s because spec.schemaChangePolicy is %s.", msg, report.Name, metering.ReportSchemaChangePolicyNewTable)
+	report.Status.TableVersion++
+	report, prestoTable, err = op.createReportTable(logger, report, reportQuery)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return report, prestoTable, false, nil
+}
+
+// updateReportTableDataSource points the PrestoTable ReportDataSource of a
+// Report at the given PrestoTable.
+func (op *defaultReportingOperator) updateReportTableDataSource(dataSourceName string, prestoTable *metering.PrestoTable) error {
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(prestoTable.Namespace)
+	_, err := updateReportDataSource(dsClient, dataSourceName, func(newDS *metering.ReportDataSource) {
+		if newDS.Spec.PrestoTable != nil {
+			newDS.Spec.PrestoTable.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+		}
+		newDS.Status.TableRef = v1.LocalObjectReference{Name: prestoTable.Name}
+	})
+	return err
+}
+
+// recordReportQueryGeneration records the generation of the ReportQuery used
+// to generate the given reportPeriod. Consecutive periods using the same
+// ReportQuery generation and table are merged into a single entry.
+func recordReportQueryGeneration(status *metering.ReportStatus, generation int64, period *reportPeriod) {
+	if n := len(status.QueryGenerations); n != 0 {
+		last := &status.QueryGenerations[n-1]
+		if last.QueryGeneration == generation && last.TableRef == status.TableRef && last.PeriodEnd.Time.Equal(period.periodStart) {
+			last.PeriodEnd = metav1.Time{Time: period.periodEnd}
+			return
+		}
+	}
+	status.QueryGenerations = append(status.QueryGenerations, metering.ReportQueryGeneration{
+		QueryGeneration: generation,
+		PeriodStart:     metav1.Time{Time: period.periodStart},
+		PeriodEnd:       metav1.Time{Time: period.periodEnd},
+		TableRef:        status.TableRef,
+	})
+}
+
 // We check first for Reports depending on this Report, return if any, and then check ReportQueries depending on Report
 func isReportNotUsedAsInput(logger log.FieldLogger, report *metering.Report, op *defaultReportingOperator) bool {
 	// Consider Reports referencing this report in the namespace of this report
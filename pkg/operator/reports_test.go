@@ -510,3 +510,27 @@ func TestReportsExpireAsExpected(t *testing.T) {
 		})
 	}
 }
+
+func TestRecordReportQueryGeneration(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2020, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+	table1 := v1.LocalObjectReference{Name: "table1"}
+	table2 := v1.LocalObjectReference{Name: "table2"}
+
+	status := metering.ReportStatus{TableRef: table1}
+	recordReportQueryGeneration(&status, 1, &reportPeriod{periodStart: day(1), periodEnd: day(2)})
+	recordReportQueryGeneration(&status, 1, &reportPeriod{periodStart: day(2), periodEnd: day(3)})
+	// the query changed
+	recordReportQueryGeneration(&status, 2, &reportPeriod{periodStart: day(3), periodEnd: day(4)})
+	// a new table was created
+	status.TableRef = table2
+	recordReportQueryGeneration(&status, 2, &reportPeriod{periodStart: day(4), periodEnd: day(5)})
+
+	expected := []metering.ReportQueryGeneration{
+		{QueryGeneration: 1, PeriodStart: metav1.Time{Time: day(1)}, PeriodEnd: metav1.Time{Time: day(3)}, TableRef: table1},
+		{QueryGeneration: 2, PeriodStart: metav1.Time{Time: day(3)}, PeriodEnd: metav1.Time{Time: day(4)}, TableRef: table1},
+		{QueryGeneration: 2, PeriodStart: metav1.Time{Time: day(4)}, PeriodEnd: metav1.Time{Time: day(5)}, TableRef: table2},
+	}
+	assert.Equal(t, expected, status.QueryGenerations)
+}
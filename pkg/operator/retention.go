@@ -0,0 +1,215 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reportingutil"
+)
+
+// retentionEnforcementInterval is how often the partitions of a
+// ReportDataSource with a retention policy are checked for expired data.
+const retentionEnforcementInterval = time.Hour
+
+// retentionCutoff returns the time data older than is expired by retention.
+// Retaining a number of days keeps the current day and that many whole days
+// before it.
+func retentionCutoff(retention *metering.ReportDataSourceRetention, now time.Time) (time.Time, error) {
+	now = now.UTC()
+	switch {
+	case retention.Period != nil && retention.Days != nil:
+		return time.Time{}, fmt.Errorf("only one of retention.period and retention.days may be set")
+	case retention.Period != nil:
+		if retention.Period.Duration <= 0 {
+			return time.Time{}, fmt.Errorf("retention.period must be positive, got %s", retention.Period.Duration)
+		}
+		return now.Add(-retention.Period.Duration), nil
+	case retention.Days != nil:
+		if *retention.Days < 0 {
+			return time.Time{}, fmt.Errorf("retention.days must not be negative, got %d", *retention.Days)
+		}
+		startOfToday := now.Truncate(backfillPartitionDuration)
+		return startOfToday.Add(-time.Duration(*retention.Days) * backfillPartitionDuration), nil
+	}
+	return time.Time{}, fmt.Errorf("one of retention.period and retention.days must be set")
+}
+
+// getReportsRetainingDataSource returns the time each Report depending on
+// dataSource still needs data from, which is the start of its next reporting
+// period. Finished Reports don't need any data, and are omitted. If the
+// dependencies or reporting period of any Report in the namespace can't be
+// determined, it might need any of the data, so an error is returned and
+// nothing is dropped until it can be.
+func (op *defaultReportingOperator) getReportsRetainingDataSource(logger log.FieldLogger, dataSource *metering.ReportDataSource, now time.Time) (map[string]time.Time, error) {
+	reports, err := op.reportLister.Reports(dataSource.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	neededFrom := make(map[string]time.Time)
+	for _, report := range reports {
+		reportLogger := logger.WithField("report", report.Name)
+		deps, err := op.getReportDependencies(report)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the dependencies of Report %s to check if it needs the data of ReportDataSource %s: %v", report.Name, dataSource.Name, err)
+		}
+		dependent := false
+		for _, depDataSource := range deps.ReportDataSources {
+			if depDataSource.Name == dataSource.Name {
+				dependent = true
+				break
+			}
+		}
+		if !dependent || isReportFinished(reportLogger, report) {
+			continue
+		}
+		// getReportPeriod sets the status of Reports which haven't run yet
+		period, err := getReportPeriod(now, reportLogger, report.DeepCopy())
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the reporting period of Report %s, which depends on ReportDataSource %s: %v", report.Name, dataSource.Name, err)
+		}
+		neededFrom[report.Name] = period.periodStart
+	}
+	return neededFrom, nil
+}
+
+// enforceReportDataSourceRetention drops the dt partitions of the table of
+// dataSource which have expired according to spec.retention, deleting their
+// files if the operator writes the files of the table. Partitions still
+// needed by the unfinished reporting periods of dependent Reports are kept,
+// and so is the newest partition, which the importer resumes from.
+func (op *defaultReportingOperator) enforceReportDataSourceRetention(logger log.FieldLogger, dataSource *metering.ReportDataSource, prestoTable *metering.PrestoTable) (*metering.ReportDataSource, error) {
+	retention := dataSource.Spec.Retention
+	if retention == nil {
+		return dataSource, nil
+	}
+	now := op.clock.Now().UTC()
+	if status := dataSource.Status.Retention; status != nil && status.LastEnforcementTime != nil && now.Sub(status.LastEnforcementTime.Time) < retentionEnforcementInterval {
+		return dataSource, nil
+	}
+
+	cutoff, err := retentionCutoff(retention, now)
+	if err != nil {
+		return dataSource, fmt.Errorf("invalid retention for ReportDataSource %s: %v", dataSource.Name, err)
+	}
+	neededFrom, err := op.getReportsRetainingDataSource(logger, dataSource, now)
+	if err != nil {
+		return dataSource, err
+	}
+	var retainedFor []string
+	expiredBefore := cutoff
+	for reportName, start := range neededFrom {
+		if start.Before(expiredBefore) {
+			retainedFor = append(retainedFor, reportName)
+			if start.Before(cutoff) {
+				cutoff = start
+			}
+		}
+	}
+	sort.Strings(retainedFor)
+
+	tableName, err := reportingutil.FullyQualifiedTableName(prestoTable)
+	if err != nil {
+		return dataSource, err
+	}
+	// PrestoTables of HiveTables have the same name as the HiveTable
+	hiveTable, err := op.hiveTableLister.HiveTables(prestoTable.Namespace).Get(prestoTable.Name)
+	if err != nil {
+		return dataSource, fmt.Errorf("unable to get HiveTable %s for ReportDataSource %s: %v", prestoTable.Name, dataSource.Name, err)
+	}
+	partitions, err := op.prometheusMetricsRepo.GetPrometheusMetricsPartitions(tableName)
+	if err != nil {
+		return dataSource, err
+	}
+	deleteFiles := op.metricsFileWriter != nil && op.metricsFileWriter.Supports(hiveTable.Status.Location)
+
+	var droppedUntil, oldestRetained *metav1.Time
+	for i, dt := range partitions {
+		start, err := time.Parse(prestostore.PrometheusMetricTimestampPartitionFormat, dt)
+		if err != nil {
+			logger.Warnf("ignoring partition dt=%s of table %s, which isn't a date", dt, tableName)
+			continue
+		}
+		if i == len(partitions)-1 || start.Add(backfillPartitionDuration).After(cutoff) {
+			oldestRetained = &metav1.Time{Time: start}
+			break
+		}
+
+		partition := hive.TablePartition{PartitionSpec: hive.PartitionSpec{"dt": dt}}
+		err = op.hivePartitionManager.DropPartition(hiveTable.Status.DatabaseName, hiveTable.Status.TableName, prestostore.PrometheusMetricHivePartitionColumns, partition)
+		if err != nil {
+			return dataSource, fmt.Errorf("failed to drop expired partition dt=%s of table %s: %v", dt, tableName, err)
+		}
+		if deleteFiles {
+			location := fmt.Sprintf("%s/dt=%s", strings.TrimSuffix(hiveTable.Status.Location, "/"), dt)
+			if err := op.metricsFileWriter.DeleteFiles(context.Background(), location); err != nil {
+				return dataSource, fmt.Errorf("failed to delete the files of expired partition dt=%s of table %s: %v", dt, tableName, err)
+			}
+		}
+		logger.Infof("dropped expired partition dt=%s of table %s", dt, tableName)
+		droppedUntil = &metav1.Time{Time: start.Add(backfillPartitionDuration)}
+	}
+
+	dsClient := op.meteringClient.MeteringV1().ReportDataSources(dataSource.Namespace)
+	return updateReportDataSource(dsClient, dataSource.Name, func(newDS *metering.ReportDataSource) {
+		status := newDS.Status.Retention
+		if status == nil {
+			status = &metering.ReportDataSourceRetentionStatus{}
+		}
+		status.LastEnforcementTime = &metav1.Time{Time: now}
+		status.RetainedForReports = retainedFor
+		if oldestRetained != nil {
+			status.OldestRetainedTime = oldestRetained
+		}
+		newDS.Status.Retention = status
+		if droppedUntil != nil && newDS.Status.PrometheusMetricsImportStatus != nil {
+			trimPrometheusImportStatus(newDS.Status.PrometheusMetricsImportStatus, droppedUntil.Time)
+		}
+	})
+}
+
+// trimPrometheusImportStatus removes the time before start from the imported
+// ranges and gaps of status, so data dropped by retention isn't considered
+// missing and imported again.
+func trimPrometheusImportStatus(status *metering.PrometheusMetricsImportStatus, start time.Time) {
+	var ranges []metering.PrometheusImportTimeRange
+	for _, r := range status.ImportedRanges {
+		if !r.End.After(start) {
+			continue
+		}
+		if r.Start.Time.Before(start) {
+			r.Start = metav1.NewTime(start)
+		}
+		ranges = append(ranges, r)
+	}
+	status.ImportedRanges = ranges
+
+	var gaps []metering.PrometheusImportGap
+	for _, gap := range status.Gaps {
+		if !gap.End.After(start) {
+			continue
+		}
+		if gap.Start.Time.Before(start) {
+			gap.Start = metav1.NewTime(start)
+		}
+		gaps = append(gaps, gap)
+	}
+	status.Gaps = gaps
+
+	if status.ImportDataStartTime != nil && status.ImportDataStartTime.Time.Before(start) {
+		status.ImportDataStartTime = &metav1.Time{Time: start}
+	}
+	if status.EarliestImportedMetricTime != nil && status.EarliestImportedMetricTime.Time.Before(start) {
+		status.EarliestImportedMetricTime = &metav1.Time{Time: start}
+	}
+}
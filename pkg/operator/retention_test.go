@@ -0,0 +1,224 @@
+package operator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/cache"
+
+	metering "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1"
+	meteringUtil "github.com/kube-reporting/metering-operator/pkg/apis/metering/v1/util"
+	"github.com/kube-reporting/metering-operator/pkg/generated/clientset/versioned/fake"
+	listers "github.com/kube-reporting/metering-operator/pkg/generated/listers/metering/v1"
+	"github.com/kube-reporting/metering-operator/pkg/hive"
+	"github.com/kube-reporting/metering-operator/pkg/operator/prestostore"
+	"github.com/kube-reporting/metering-operator/pkg/operator/reporting"
+	"github.com/kube-reporting/metering-operator/test/testhelpers"
+)
+
+type fakeHivePartitionManager struct {
+	dropped []string
+}
+
+func (m *fakeHivePartitionManager) AddPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error {
+	return nil
+}
+
+func (m *fakeHivePartitionManager) DropPartition(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error {
+	m.dropped = append(m.dropped, partition.PartitionSpec["dt"])
+	return nil
+}
+
+func (m *fakeHivePartitionManager) SetPartitionLocation(dbName, tableName string, partitionColumns []hive.Column, partition hive.TablePartition) error {
+	return nil
+}
+
+func TestRetentionCutoff(t *testing.T) {
+	now := time.Date(2019, time.January, 20, 12, 0, 0, 0, time.UTC)
+	days := func(d int64) *int64 { return &d }
+
+	cutoff, err := retentionCutoff(&metering.ReportDataSourceRetention{Period: &metav1.Duration{Duration: 36 * time.Hour}}, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2019, time.January, 19, 0, 0, 0, 0, time.UTC), cutoff)
+
+	// the current day and the 7 days before it are kept
+	cutoff, err = retentionCutoff(&metering.ReportDataSourceRetention{Days: days(7)}, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2019, time.January, 13, 0, 0, 0, 0, time.UTC), cutoff)
+
+	cutoff, err = retentionCutoff(&metering.ReportDataSourceRetention{Days: days(0)}, now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2019, time.January, 20, 0, 0, 0, 0, time.UTC), cutoff)
+
+	for _, invalid := range []*metering.ReportDataSourceRetention{
+		{},
+		{Days: days(-1)},
+		{Period: &metav1.Duration{}},
+		{Period: &metav1.Duration{Duration: time.Hour}, Days: days(1)},
+	} {
+		_, err := retentionCutoff(invalid, now)
+		assert.Error(t, err)
+	}
+}
+
+func TestEnforceReportDataSourceRetention(t *testing.T) {
+	janOne := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	janTen := janOne.Add(9 * 24 * time.Hour)
+	now := time.Date(2019, time.January, 20, 12, 0, 0, 0, time.UTC)
+	const namespace = "default"
+	tableName := "hive.metering.datasource_test"
+
+	repo := &fakePrometheusMetricsRepo{metrics: map[string][]*prestostore.PrometheusMetric{}}
+	for ts := janOne; ts.Before(now); ts = ts.Add(time.Hour) {
+		repo.metrics[tableName] = append(repo.metrics[tableName], &prestostore.PrometheusMetric{Timestamp: ts})
+	}
+
+	warehouse, err := ioutil.TempDir("", "retention")
+	require.NoError(t, err)
+	defer os.RemoveAll(warehouse)
+	for _, dt := range []string{"2019-01-01", "2019-01-09", "2019-01-10"} {
+		path := filepath.Join(warehouse, "datasource_test", "dt="+dt, "metrics.parquet")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte("PAR1"), 0644))
+	}
+
+	days := int64(7)
+	dataSource := &metering.ReportDataSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: namespace},
+		Spec: metering.ReportDataSourceSpec{
+			PrometheusMetricsImporter: &metering.PrometheusMetricsImporterDataSource{Query: "up"},
+			Retention:                 &metering.ReportDataSourceRetention{Days: &days},
+		},
+		Status: metering.ReportDataSourceStatus{
+			TableRef: v1.LocalObjectReference{Name: "datasource-test"},
+			PrometheusMetricsImportStatus: &metering.PrometheusMetricsImportStatus{
+				ImportDataStartTime:        &metav1.Time{Time: janOne},
+				EarliestImportedMetricTime: &metav1.Time{Time: janOne},
+				ImportedRanges: []metering.PrometheusImportTimeRange{
+					{Start: metav1.NewTime(janOne), End: metav1.NewTime(janOne.Add(24 * time.Hour))},
+					{Start: metav1.NewTime(janOne.Add(2 * 24 * time.Hour)), End: metav1.NewTime(now)},
+				},
+				Gaps: []metering.PrometheusImportGap{
+					{Start: metav1.NewTime(janOne.Add(24 * time.Hour)), End: metav1.NewTime(janOne.Add(2 * 24 * time.Hour))},
+				},
+			},
+		},
+	}
+	prestoTable := &metering.PrestoTable{
+		ObjectMeta: metav1.ObjectMeta{Name: "datasource-test", Namespace: namespace},
+		Status:     metering.PrestoTableStatus{Catalog: "hive", Schema: "metering", TableName: "datasource_test"},
+	}
+	hiveTable := &metering.HiveTable{
+		ObjectMeta: metav1.ObjectMeta{Name: "datasource-test", Namespace: namespace},
+		Status: metering.HiveTableStatus{
+			DatabaseName: "metering",
+			TableName:    "datasource_test",
+			Location:     "file://" + filepath.Join(warehouse, "datasource_test"),
+		},
+	}
+
+	dsInput, err := json.Marshal(dataSource.Name)
+	require.NoError(t, err)
+	query := &metering.ReportQuery{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-query", Namespace: namespace},
+		Spec: metering.ReportQuerySpec{
+			Inputs: []metering.ReportQueryInputDefinition{{Name: "ds", Type: "ReportDataSource"}},
+		},
+	}
+	inputs := metering.ReportQueryInputValues{{Name: "ds", Value: (*json.RawMessage)(&dsInput)}}
+	janTwentyFirst := janOne.Add(20 * 24 * time.Hour)
+	decOne := janOne.AddDate(0, -1, 0)
+	reports := []*metering.Report{
+		// needs the data from January 10th onwards
+		testhelpers.NewReport("unfinished", namespace, query.Name, inputs, &janTen, &janTwentyFirst, metering.ReportStatus{}, nil, false, nil),
+		// finished reports don't need any data
+		testhelpers.NewReport("finished", namespace, query.Name, inputs, &decOne, &janTen, metering.ReportStatus{
+			Conditions: []metering.ReportCondition{
+				*meteringUtil.NewReportCondition(metering.ReportRunning, v1.ConditionFalse, meteringUtil.ReportFinishedReason, ""),
+			},
+		}, nil, false, nil),
+	}
+	// the dependencies of a broken Report can't be resolved, so it might
+	// need any of the data
+	brokenReport := testhelpers.NewReport("broken", namespace, "missing-query", inputs, &decOne, nil, metering.ReportStatus{}, nil, false, nil)
+
+	reportIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	reportQueryIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	hiveTableIndexer := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	for _, report := range reports {
+		require.NoError(t, reportIndexer.Add(report))
+	}
+	require.NoError(t, reportIndexer.Add(brokenReport))
+	require.NoError(t, reportQueryIndexer.Add(query))
+	require.NoError(t, hiveTableIndexer.Add(hiveTable))
+
+	partitionManager := &fakeHivePartitionManager{}
+	fakeClock := clock.NewFakeClock(now)
+	op := &defaultReportingOperator{
+		meteringClient:        fake.NewSimpleClientset(dataSource),
+		clock:                 fakeClock,
+		prometheusMetricsRepo: repo,
+		hivePartitionManager:  partitionManager,
+		metricsFileWriter:     &metricsFileWriter{},
+		reportLister:          listers.NewReportLister(reportIndexer),
+		reportQueryLister:     listers.NewReportQueryLister(reportQueryIndexer),
+		hiveTableLister:       listers.NewHiveTableLister(hiveTableIndexer),
+		dependencyResolver: reporting.NewDependencyResolver(
+			testhelpers.NewReportQueryStore([]*metering.ReportQuery{query}),
+			testhelpers.NewReportDataSourceStore([]*metering.ReportDataSource{dataSource}),
+			testhelpers.NewReportStore(reports),
+		),
+	}
+
+	// nothing is dropped while a Report can't be resolved
+	_, err = op.enforceReportDataSourceRetention(logrus.New(), dataSource, prestoTable)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Report broken")
+	assert.Empty(t, partitionManager.dropped)
+	require.NoError(t, reportIndexer.Delete(brokenReport))
+
+	// retaining 7 days would drop everything before January 13th, but the
+	// unfinished Report still needs the data from January 10th
+	dataSource, err = op.enforceReportDataSourceRetention(logrus.New(), dataSource, prestoTable)
+	require.NoError(t, err)
+	var expectedDropped []string
+	for d := janOne; d.Before(janTen); d = d.Add(24 * time.Hour) {
+		expectedDropped = append(expectedDropped, prestostore.PrometheusMetricTimestampPartition(d))
+	}
+	assert.Equal(t, expectedDropped, partitionManager.dropped)
+
+	for dt, exists := range map[string]bool{"2019-01-01": false, "2019-01-09": false, "2019-01-10": true} {
+		_, err := os.Stat(filepath.Join(warehouse, "datasource_test", "dt="+dt))
+		assert.Equal(t, exists, err == nil, "files of partition dt=%s", dt)
+	}
+
+	retention := dataSource.Status.Retention
+	require.NotNil(t, retention)
+	assert.Equal(t, janTen, retention.OldestRetainedTime.UTC())
+	assert.Equal(t, now, retention.LastEnforcementTime.UTC())
+	assert.Equal(t, []string{"unfinished"}, retention.RetainedForReports)
+
+	// the dropped data is no longer considered imported or missing
+	importStatus := dataSource.Status.PrometheusMetricsImportStatus
+	assert.Equal(t, []metering.PrometheusImportTimeRange{{Start: metav1.NewTime(janTen), End: metav1.NewTime(now)}}, importStatus.ImportedRanges)
+	assert.Empty(t, importStatus.Gaps)
+	assert.Equal(t, janTen, importStatus.ImportDataStartTime.UTC())
+	assert.Equal(t, janTen, importStatus.EarliestImportedMetricTime.UTC())
+
+	// partitions aren't checked again until retentionEnforcementInterval has
+	// passed
+	fakeClock.Step(time.Minute)
+	_, err = op.enforceReportDataSourceRetention(logrus.New(), dataSource, prestoTable)
+	require.NoError(t, err)
+	assert.Len(t, partitionManager.dropped, len(expectedDropped))
+}
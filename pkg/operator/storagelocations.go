@@ -198,7 +198,20 @@ func (op *defaultReportingOperator) queueDependentsOfStorageLocation(storageLoca
 			if storage.Name == storageLocation.Name {
 				op.enqueueReportDataSource(datasource)
 			}
-		case datasource.Spec.AWSBilling != nil && datasource.Spec.AWSBilling.DatabaseName == "":
+		case datasource.Spec.KubernetesInventory != nil:
+			storage, err := op.getStorage(datasource.Spec.KubernetesInventory.Storage, datasource.Namespace)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			if storage.Name == storageLocation.Name {
+				op.enqueueReportDataSource(datasource)
+			}
+		case datasource.Spec.AWSBilling != nil && datasource.Spec.AWSBilling.DatabaseName == "",
+			datasource.Spec.AzureCostExport != nil && datasource.Spec.AzureCostExport.DatabaseName == "",
+			datasource.Spec.GCPBillingExport != nil && datasource.Spec.GCPBillingExport.DatabaseName == "",
+			datasource.Spec.FileDrop != nil && datasource.Spec.FileDrop.DatabaseName == "",
+			datasource.Spec.PushTable != nil && datasource.Spec.PushTable.DatabaseName == "":
 			storage, err := op.getStorage(nil, datasource.Namespace)
 			if err != nil {
 				errs = append(errs, err.Error())
@@ -17,6 +17,10 @@ type DependencyResolver interface {
 	// ResolveDependencies determines, for the given namespace and report query inputs, any report, report
 	// query, and data source dependencies.
 	ResolveDependencies(namespace string, inputDefs []metering.ReportQueryInputDefinition, inputVals []metering.ReportQueryInputValue) (*reporting.DependencyResolutionResult, error)
+	// ResolveReportDependencyGraph returns the graph of every resource the given report depends on.
+	ResolveReportDependencyGraph(report *metering.Report) *reporting.DependencyGraph
+	// ResolveNamespaceDependencyGraph returns the graph of the given resources and everything they depend on.
+	ResolveNamespaceDependencyGraph(namespace string, reports []*metering.Report, queries []*metering.ReportQuery, dataSources []*metering.ReportDataSource) *reporting.DependencyGraph
 }
 
 // TLSConfig allows configuration of using TLS (on/off) as well as the cert and key.
@@ -136,6 +140,46 @@ type Config struct {
 	// PrometheusDataSourceGlobalImportFromTime, if non-empty, indicates when Prometheus ReportDataSource data should
 	// be back filled from.
 	PrometheusDataSourceGlobalImportFromTime *time.Time
+	// PrometheusDataSourceRetention is how long Prometheus retains data for. Gaps in the data imported by Prometheus
+	// ReportDataSources are only re-imported if they're within the retention.
+	PrometheusDataSourceRetention time.Duration
+	// PrometheusDataSourceCoverageCheckInterval controls how often the data imported by Prometheus ReportDataSources
+	// is checked for gaps. If zero, the data is never checked.
+	PrometheusDataSourceCoverageCheckInterval time.Duration
+	// PrometheusDataSourceWriteFiles, if true, creates the tables of Prometheus ReportDataSources stored as Parquet,
+	// and stores metrics by writing Parquet files into the table's partitions instead of inserting them using Presto,
+	// when the table's location is on S3 or the local filesystem.
+	PrometheusDataSourceWriteFiles bool
+	// PrometheusDataSourceMaxConcurrentQueries is the maximum number of Prometheus queries run at once by the imports
+	// of all Prometheus ReportDataSources. Waiting queries are run round-robin between ReportDataSources. If zero,
+	// queries are not limited.
+	PrometheusDataSourceMaxConcurrentQueries int
+	// PrometheusDataSourceMaxConcurrentQueriesPerPrometheus is the maximum number of Prometheus queries run at once
+	// against the same Prometheus. If zero, queries are only limited by PrometheusDataSourceMaxConcurrentQueries.
+	PrometheusDataSourceMaxConcurrentQueriesPerPrometheus int
+	// PrometheusDataSourceChunkConcurrency is the number of chunks of a single import fetched from Prometheus at once.
+	// Chunks are always stored in order.
+	PrometheusDataSourceChunkConcurrency int
+
+	// AWSWebIdentityTokenFile is the path of the web identity token used by ReportDataSources with AWS credentials
+	// using a webIdentity, such as the service account token projected for IAM Roles for Service Accounts. If empty,
+	// web identities can't be used.
+	AWSWebIdentityTokenFile string
+
+	// KubernetesInventoryAllNamespaces allows KubernetesInventory ReportDataSources to snapshot the objects in every
+	// namespace, and Nodes. If false, they can only snapshot the objects in their own namespace.
+	KubernetesInventoryAllNamespaces bool
+
+	// ExternalDatabaseAllowedHosts are the hosts ExternalDatabase ReportDataSources can connect to, as hostnames,
+	// wildcards of subdomains like *.example.com, IP addresses or CIDRs. If empty, ExternalDatabase ReportDataSources
+	// can't connect to any host.
+	ExternalDatabaseAllowedHosts []string
+	// ExternalDatabaseCatalogUpdatesEnabled allows storing the Presto catalogs of ExternalDatabase ReportDataSources.
+	// Each change of the catalogs restarts Presto, failing the queries running at the time, so it's disabled by default.
+	ExternalDatabaseCatalogUpdatesEnabled bool
+	// ExternalDatabaseCatalogUpdateInterval is how often the changes to the Presto catalogs of ExternalDatabase
+	// ReportDataSources are stored together, since each change of the catalogs restarts Presto.
+	ExternalDatabaseCatalogUpdateInterval time.Duration
 
 	// ProxyTrustedCABundle configures the path to the certificate authority bundle used to connect to the cluster-wide
 	// https proxy.
@@ -170,4 +214,18 @@ const (
 	DefaultPrometheusDataSourceMaxQueryRangeDuration = 10 * time.Minute
 	// DefaultPrometheusDataSourceMaxBackfillImportDuration how far we will query for backlogged data.
 	DefaultPrometheusDataSourceMaxBackfillImportDuration = 2 * time.Hour
+	// DefaultPrometheusDataSourceRetention is the default retention of Prometheus.
+	DefaultPrometheusDataSourceRetention = 15 * 24 * time.Hour
+	// DefaultPrometheusDataSourceCoverageCheckInterval is how often imported data is checked for gaps.
+	DefaultPrometheusDataSourceCoverageCheckInterval = time.Hour
+	// DefaultPrometheusDataSourceMaxConcurrentQueries is how many Prometheus queries imports run at once.
+	DefaultPrometheusDataSourceMaxConcurrentQueries = 8
+	// DefaultPrometheusDataSourceMaxConcurrentQueriesPerPrometheus is how many queries imports run at once against
+	// the same Prometheus.
+	DefaultPrometheusDataSourceMaxConcurrentQueriesPerPrometheus = 4
+	// DefaultPrometheusDataSourceChunkConcurrency is how many chunks of an import are fetched at once.
+	DefaultPrometheusDataSourceChunkConcurrency = 2
+	// DefaultExternalDatabaseCatalogUpdateInterval is how often the changes to the Presto catalogs of ExternalDatabase
+	// ReportDataSources are stored.
+	DefaultExternalDatabaseCatalogUpdateInterval = 5 * time.Minute
 )
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/util/errors"
 )
@@ -18,6 +19,7 @@ func IsValidConfig(cfg *Config) error {
 	errs = append(errs, IsValidHiveConfig(cfg))
 	errs = append(errs, IsValidKubeConfig(cfg.Kubeconfig))
 	errs = append(errs, IsValidPrometheusConfig(cfg))
+	errs = append(errs, IsValidExternalDatabaseConfig(cfg))
 
 	if err := isValidTLSConfig(&cfg.APITLSConfig); err != nil {
 		errs = append(errs, fmt.Errorf("error validating apiTLSConfig: %s", err.Error()))
@@ -146,6 +148,29 @@ func IsValidPrometheusConfig(cfg *Config) error {
 	return nil
 }
 
+// IsValidExternalDatabaseConfig ensures the ExternalDatabase ReportDataSource configuration is valid.
+func IsValidExternalDatabaseConfig(cfg *Config) error {
+	errs := []error{}
+	for _, host := range cfg.ExternalDatabaseAllowedHosts {
+		if strings.Contains(host, "/") {
+			if _, _, err := net.ParseCIDR(host); err != nil {
+				errs = append(errs, fmt.Errorf("invalid externalDatabaseAllowedHosts CIDR %q: %v", host, err))
+			}
+		} else if name := strings.TrimPrefix(host, "*."); name == "" || strings.ContainsAny(name, "*?#@ \t\r\n") {
+			errs = append(errs, fmt.Errorf("invalid externalDatabaseAllowedHosts host %q", host))
+		}
+	}
+
+	if cfg.ExternalDatabaseCatalogUpdateInterval <= 0 {
+		errs = append(errs, fmt.Errorf("externalDatabaseCatalogUpdateInterval must be positive"))
+	}
+
+	if len(errs) > 0 {
+		return errors.NewAggregate(errs)
+	}
+	return nil
+}
+
 // IsValidTLSConfig ensures the TLS config is valid.
 func isValidTLSConfig(cfg *TLSConfig) error {
 	if cfg.UseTLS {
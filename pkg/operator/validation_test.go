@@ -187,6 +187,37 @@ func TestIsValidConfig(t *testing.T) {
 			},
 			expectedErr: "prometheusDataSourceGlobalImportFromTime and prometheusDataSourceMaxBackfillImportDuration cannot both be set",
 		},
+		"external database config - valid allowed hosts": {
+			makeCfg: func() *Config {
+				cfg := validConfig()
+				cfg.ExternalDatabaseAllowedHosts = []string{"cmdb.example.com", "*.db.example.com", "10.0.0.0/8", "fd00::12"}
+				return cfg
+			},
+		},
+		"external database config - invalid allowed CIDR": {
+			makeCfg: func() *Config {
+				cfg := validConfig()
+				cfg.ExternalDatabaseAllowedHosts = []string{"10.0.0.0/33"}
+				return cfg
+			},
+			expectedErr: `invalid externalDatabaseAllowedHosts CIDR "10.0.0.0/33"`,
+		},
+		"external database config - invalid allowed wildcard": {
+			makeCfg: func() *Config {
+				cfg := validConfig()
+				cfg.ExternalDatabaseAllowedHosts = []string{"db.*.example.com"}
+				return cfg
+			},
+			expectedErr: `invalid externalDatabaseAllowedHosts host "db.*.example.com"`,
+		},
+		"external database config - invalid catalog update interval": {
+			makeCfg: func() *Config {
+				cfg := validConfig()
+				cfg.ExternalDatabaseCatalogUpdateInterval = 0
+				return cfg
+			},
+			expectedErr: "externalDatabaseCatalogUpdateInterval must be positive",
+		},
 		"api tls config - valid": {
 			makeCfg: func() *Config {
 				cfg := validConfig()
@@ -277,6 +308,7 @@ func TestIsValidConfig(t *testing.T) {
 
 func validConfig() *Config {
 	return &Config{
-		AllNamespaces: true,
+		AllNamespaces:                         true,
+		ExternalDatabaseCatalogUpdateInterval: DefaultExternalDatabaseCatalogUpdateInterval,
 	}
 }
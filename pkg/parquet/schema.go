@@ -0,0 +1,185 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Parquet physical and converted types from parquet.thrift, which aren't
+// written but are read from the schemas of other files.
+const (
+	typeBoolean int32 = 0
+	typeInt32   int32 = 1
+	typeInt96   int32 = 3
+	typeFloat   int32 = 4
+
+	convertedDecimal        int32 = 5
+	convertedDate           int32 = 6
+	convertedTimestampMicro int32 = 10
+)
+
+// FooterTailSize is the size of the end of a Parquet file which contains the
+// length of its footer, followed by the magic number.
+const FooterTailSize = 8
+
+// SchemaElement is an element of the schema of a Parquet file, which is
+// either a group of the elements following it, or a primitive column.
+type SchemaElement struct {
+	Name string
+	// Type is the physical type of a primitive column, and is nil for
+	// groups.
+	Type           *int32
+	RepetitionType int32
+	NumChildren    int
+	ConvertedType  *int32
+	Scale          int32
+	Precision      int32
+}
+
+// FooterLength returns the length of the footer of a Parquet file from the
+// FooterTailSize bytes at its end.
+func FooterLength(tail []byte) (int, error) {
+	if len(tail) != FooterTailSize || string(tail[4:]) != magic {
+		return 0, fmt.Errorf("parquet: not a Parquet file")
+	}
+	return int(binary.LittleEndian.Uint32(tail[:4])), nil
+}
+
+// ReadSchema returns the schema of a Parquet file from its footer, which is
+// the FileMetaData struct preceding the last FooterTailSize bytes of the file.
+func ReadSchema(footer []byte) (schema []SchemaElement, err error) {
+	r := &thriftReader{data: footer}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if readErr, ok := recovered.(thriftReadError); ok {
+				err = fmt.Errorf("parquet: invalid footer: %v", readErr.err)
+				return
+			}
+			panic(recovered)
+		}
+	}()
+	metadata := r.structValue()
+	elements, ok := metadata[2].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("parquet: footer has no schema")
+	}
+	for _, element := range elements {
+		fields, ok := element.(map[int16]interface{})
+		if !ok {
+			return nil, fmt.Errorf("parquet: invalid schema element")
+		}
+		var e SchemaElement
+		if typ, ok := fields[1].(int64); ok {
+			t := int32(typ)
+			e.Type = &t
+		}
+		if repetition, ok := fields[3].(int64); ok {
+			e.RepetitionType = int32(repetition)
+		}
+		e.Name, _ = fields[4].(string)
+		if numChildren, ok := fields[5].(int64); ok {
+			e.NumChildren = int(numChildren)
+		}
+		if converted, ok := fields[6].(int64); ok {
+			c := int32(converted)
+			e.ConvertedType = &c
+		}
+		if scale, ok := fields[7].(int64); ok {
+			e.Scale = int32(scale)
+		}
+		if precision, ok := fields[8].(int64); ok {
+			e.Precision = int32(precision)
+		}
+		schema = append(schema, e)
+	}
+	return schema, nil
+}
+
+// HiveColumnTypes returns the Hive type of each top level column of schema
+// by name, for the columns of primitive types, and of maps of primitive
+// types. Columns of other types, such as lists and structs, are omitted.
+func HiveColumnTypes(schema []SchemaElement) map[string]string {
+	types := make(map[string]string)
+	if len(schema) == 0 {
+		return types
+	}
+	i := 1
+	for column := 0; column < schema[0].NumChildren && i < len(schema); column++ {
+		element := schema[i]
+		if hiveType := elementHiveType(schema, i); hiveType != "" {
+			types[element.Name] = hiveType
+		}
+		i = skipSchemaElement(schema, i)
+	}
+	return types
+}
+
+// skipSchemaElement returns the index of the element following the element
+// at i and its children.
+func skipSchemaElement(schema []SchemaElement, i int) int {
+	numChildren := schema[i].NumChildren
+	i++
+	for child := 0; child < numChildren && i < len(schema); child++ {
+		i = skipSchemaElement(schema, i)
+	}
+	return i
+}
+
+// elementHiveType returns the Hive type of the element at i, or "" if it
+// isn't a primitive column or a map of primitive columns.
+func elementHiveType(schema []SchemaElement, i int) string {
+	element := schema[i]
+	if element.Type != nil {
+		return primitiveHiveType(element)
+	}
+	// maps are a group of a repeated group of a key and a value
+	if element.ConvertedType == nil || (*element.ConvertedType != convertedMap && *element.ConvertedType != convertedMapKeyValue) {
+		return ""
+	}
+	if element.NumChildren != 1 || i+3 >= len(schema) {
+		return ""
+	}
+	keyValue, key, value := schema[i+1], schema[i+2], schema[i+3]
+	if keyValue.RepetitionType != repetitionRepeated || keyValue.NumChildren != 2 || key.Type == nil || value.Type == nil {
+		return ""
+	}
+	keyType, valueType := primitiveHiveType(key), primitiveHiveType(value)
+	if keyType == "" || valueType == "" {
+		return ""
+	}
+	return fmt.Sprintf("map<%s,%s>", keyType, valueType)
+}
+
+func primitiveHiveType(element SchemaElement) string {
+	converted := int32(-1)
+	if element.ConvertedType != nil {
+		converted = *element.ConvertedType
+	}
+	if converted == convertedDecimal {
+		return fmt.Sprintf("decimal(%d,%d)", element.Precision, element.Scale)
+	}
+	switch *element.Type {
+	case typeBoolean:
+		return "boolean"
+	case typeInt32:
+		if converted == convertedDate {
+			return "date"
+		}
+		return "int"
+	case typeInt64:
+		if converted == convertedTimestamp || converted == convertedTimestampMicro {
+			return "timestamp"
+		}
+		return "bigint"
+	case typeInt96:
+		return "timestamp"
+	case typeFloat:
+		return "float"
+	case typeDouble:
+		return "double"
+	case typeByteArray:
+		return "string"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,65 @@
+package parquet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHiveColumnTypes(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []Column{
+		{Name: "amount", Type: Double, Doubles: []float64{1}},
+		{Name: "timestamp", Type: Timestamp, Timestamps: []time.Time{time.Unix(0, 0)}},
+		{Name: "labels", Type: StringMap, StringMaps: []map[string]string{{"a": "b"}}},
+	})
+	require.NoError(t, err)
+
+	file := buf.Bytes()
+	footerLength, err := FooterLength(file[len(file)-FooterTailSize:])
+	require.NoError(t, err)
+	footer := file[len(file)-FooterTailSize-footerLength : len(file)-FooterTailSize]
+
+	schema, err := ReadSchema(footer)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"amount":    "double",
+		"timestamp": "timestamp",
+		"labels":    "map<string,string>",
+	}, HiveColumnTypes(schema))
+
+	_, err = ReadSchema(footer[:len(footer)/2])
+	assert.Error(t, err, "truncated footer")
+	_, err = FooterLength([]byte("12345678"))
+	assert.Error(t, err, "not a Parquet file")
+}
+
+func TestHiveColumnTypesPrimitives(t *testing.T) {
+	typ := func(t int32) *int32 { return &t }
+	schema := []SchemaElement{
+		{Name: "schema", NumChildren: 9},
+		{Name: "bool", Type: typ(typeBoolean)},
+		{Name: "int", Type: typ(typeInt32)},
+		{Name: "date", Type: typ(typeInt32), ConvertedType: typ(convertedDate)},
+		{Name: "bigint", Type: typ(typeInt64)},
+		{Name: "legacy_timestamp", Type: typ(typeInt96)},
+		{Name: "cost", Type: typ(7), ConvertedType: typ(convertedDecimal), Precision: 18, Scale: 4},
+		{Name: "string", Type: typ(typeByteArray), ConvertedType: typ(convertedUTF8)},
+		{Name: "fixed", Type: typ(7)},
+		{Name: "list", NumChildren: 1, ConvertedType: typ(3)},
+		{Name: "list", RepetitionType: repetitionRepeated, NumChildren: 1},
+		{Name: "element", Type: typ(typeByteArray)},
+	}
+	assert.Equal(t, map[string]string{
+		"bool":             "boolean",
+		"int":              "int",
+		"date":             "date",
+		"bigint":           "bigint",
+		"legacy_timestamp": "timestamp",
+		"cost":             "decimal(18,4)",
+		"string":           "string",
+	}, HiveColumnTypes(schema))
+}
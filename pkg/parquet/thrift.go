@@ -0,0 +1,244 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Thrift compact protocol types used by the Parquet metadata.
+const (
+	thriftTrue   byte = 1
+	thriftFalse  byte = 2
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// compactWriter encodes Thrift structs using the compact protocol, which is
+// how Parquet page headers and file metadata are serialized. Only the types
+// used by the Parquet metadata are supported.
+type compactWriter struct {
+	buf bytes.Buffer
+	// lastFieldIDs is a stack of the last field ID written for each struct
+	// being written, since field IDs are delta encoded.
+	lastFieldIDs []int16
+}
+
+func (w *compactWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *compactWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func (w *compactWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compactWriter) fieldHeader(id int16, typ byte) {
+	last := &w.lastFieldIDs[len(w.lastFieldIDs)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta<<4) | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+// beginStruct starts writing a struct, either the top level struct or an
+// element of a list of structs.
+func (w *compactWriter) beginStruct() {
+	w.lastFieldIDs = append(w.lastFieldIDs, 0)
+}
+
+// endStruct writes the stop field of the struct being written.
+func (w *compactWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastFieldIDs = w.lastFieldIDs[:len(w.lastFieldIDs)-1]
+}
+
+// structField starts writing a struct field, which is ended by endStruct.
+func (w *compactWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+// boolField writes a bool field, whose value is encoded in its type.
+func (w *compactWriter) boolField(id int16, v bool) {
+	if v {
+		w.fieldHeader(id, thriftTrue)
+	} else {
+		w.fieldHeader(id, thriftFalse)
+	}
+}
+
+func (w *compactWriter) i32Field(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) i64Field(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.zigzag(v)
+}
+
+func (w *compactWriter) stringField(id int16, v string) {
+	w.fieldHeader(id, thriftBinary)
+	w.string(v)
+}
+
+// listField writes the header of a list field with size elements of
+// elemType, which must then be written.
+func (w *compactWriter) listField(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size<<4) | elemType)
+	} else {
+		w.buf.WriteByte(0xf0 | elemType)
+		w.varint(uint64(size))
+	}
+}
+
+func (w *compactWriter) i32(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *compactWriter) string(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+// Thrift compact protocol types which are only read.
+const (
+	thriftByte   byte = 3
+	thriftI16    byte = 4
+	thriftDouble byte = 7
+	thriftSet    byte = 10
+	thriftMap    byte = 11
+)
+
+// thriftReadError is the panic value of a thriftReader reading invalid
+// data, which is recovered by the caller of the thriftReader.
+type thriftReadError struct {
+	err error
+}
+
+// thriftReader decodes Thrift structs encoded using the compact protocol
+// into maps of field ID to value, with integers as int64, binary fields as
+// string, and lists and sets as []interface{}. Maps and doubles are skipped.
+type thriftReader struct {
+	data []byte
+}
+
+func (r *thriftReader) fail(format string, args ...interface{}) {
+	panic(thriftReadError{fmt.Errorf(format, args...)})
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.data) == 0 {
+		r.fail("unexpected end of data")
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *thriftReader) bytes(n uint64) []byte {
+	if uint64(len(r.data)) < n {
+		r.fail("unexpected end of data")
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail("invalid varint")
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		// bool fields have their value in their type, and bool elements of
+		// lists are a byte
+		return typ == thriftTrue
+	case thriftByte:
+		return int64(int8(r.byte()))
+	case thriftI16, thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftDouble:
+		r.bytes(8)
+		return nil
+	case thriftBinary:
+		return string(r.bytes(r.varint()))
+	case thriftList, thriftSet:
+		header := r.byte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.varint()
+		}
+		if size > uint64(len(r.data)) {
+			// every element is at least a byte
+			r.fail("invalid list size %d", size)
+		}
+		elemType := header & 0x0f
+		list := make([]interface{}, size)
+		for i := range list {
+			if elemType == thriftTrue || elemType == thriftFalse {
+				list[i] = r.byte() == thriftTrue
+				continue
+			}
+			list[i] = r.value(elemType)
+		}
+		return list
+	case thriftMap:
+		size := r.varint()
+		if size == 0 {
+			return nil
+		}
+		types := r.byte()
+		for i := uint64(0); i < size; i++ {
+			r.value(types >> 4)
+			r.value(types & 0x0f)
+		}
+		return nil
+	case thriftStruct:
+		return r.structValue()
+	}
+	r.fail("unknown thrift type %d", typ)
+	return nil
+}
+
+func (r *thriftReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
@@ -0,0 +1,377 @@
+// Package parquet writes Apache Parquet files readable by Hive and Presto.
+//
+// Only what's needed to store rows of Prometheus metrics is supported: each
+// file has a single row group with one Snappy compressed, PLAIN encoded data
+// page per column.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// ColumnType is the type of the values of a Column.
+type ColumnType int
+
+const (
+	// Double is a required column of float64 values, the Hive double type.
+	Double ColumnType = iota
+	// Timestamp is a required column of time.Time values, the Hive timestamp
+	// type. Timestamps are written as INT64 milliseconds since the Unix epoch
+	// in UTC, annotated as TIMESTAMP_MILLIS, rather than as the deprecated
+	// INT96 timestamps.
+	Timestamp
+	// StringMap is a column of map[string]string values, the Hive
+	// map<string,string> type. Nil maps are written as empty maps.
+	StringMap
+)
+
+const magic = "PAR1"
+
+// Parquet types, encodings and other enums from parquet.thrift.
+const (
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+
+	repetitionRequired int32 = 0
+	repetitionOptional int32 = 1
+	repetitionRepeated int32 = 2
+
+	convertedUTF8        int32 = 0
+	convertedMap         int32 = 1
+	convertedMapKeyValue int32 = 2
+	convertedTimestamp   int32 = 9
+
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	codecSnappy int32 = 1
+
+	pageTypeData int32 = 0
+)
+
+// Column is a column of a Parquet file along with its values, which must be
+// the slice matching its Type: Doubles, Timestamps or StringMaps.
+type Column struct {
+	Name string
+	Type ColumnType
+
+	Doubles    []float64
+	Timestamps []time.Time
+	StringMaps []map[string]string
+}
+
+func (c Column) numRows() int {
+	switch c.Type {
+	case Double:
+		return len(c.Doubles)
+	case Timestamp:
+		return len(c.Timestamps)
+	default:
+		return len(c.StringMaps)
+	}
+}
+
+// leafColumn is a column of values stored in a column chunk, which for maps
+// are the keys and values.
+type leafColumn struct {
+	path          []string
+	typ           int32
+	maxRepetition int
+	maxDefinition int
+
+	// numValues is the number of values including nulls, which is the
+	// number of levels for columns with levels.
+	numValues        int
+	repetitionLevels []int
+	definitionLevels []int
+	values           bytes.Buffer
+}
+
+// Write writes a Parquet file containing columns to w. Every column must have
+// the same number of rows.
+func Write(w io.Writer, columns []Column) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("parquet: no columns")
+	}
+	numRows := columns[0].numRows()
+	for _, column := range columns {
+		if column.numRows() != numRows {
+			return fmt.Errorf("parquet: column %s has %d rows, expected %d", column.Name, column.numRows(), numRows)
+		}
+	}
+
+	var leaves []*leafColumn
+	for _, column := range columns {
+		switch column.Type {
+		case Double:
+			leaf := &leafColumn{path: []string{column.Name}, typ: typeDouble, numValues: numRows}
+			for _, v := range column.Doubles {
+				binary.Write(&leaf.values, binary.LittleEndian, math.Float64bits(v))
+			}
+			leaves = append(leaves, leaf)
+		case Timestamp:
+			leaf := &leafColumn{path: []string{column.Name}, typ: typeInt64, numValues: numRows}
+			for _, v := range column.Timestamps {
+				binary.Write(&leaf.values, binary.LittleEndian, v.UnixNano()/int64(time.Millisecond))
+			}
+			leaves = append(leaves, leaf)
+		case StringMap:
+			leaves = append(leaves, stringMapLeaves(column)...)
+		default:
+			return fmt.Errorf("parquet: column %s has unknown type %d", column.Name, column.Type)
+		}
+	}
+
+	counter := &countingWriter{w: w}
+	if _, err := io.WriteString(counter, magic); err != nil {
+		return err
+	}
+
+	meta := &compactWriter{}
+	meta.beginStruct()
+	meta.i32Field(1, 1)
+	writeSchema(meta, columns)
+	meta.i64Field(3, int64(numRows))
+
+	// a single row group containing every column
+	meta.listField(4, thriftStruct, 1)
+	meta.beginStruct()
+	meta.listField(1, thriftStruct, len(leaves))
+	var totalByteSize int64
+	for _, leaf := range leaves {
+		offset := counter.n
+		uncompressedSize, compressedSize, err := writeDataPage(counter, leaf)
+		if err != nil {
+			return err
+		}
+		totalByteSize += uncompressedSize
+
+		meta.beginStruct()
+		meta.i64Field(2, offset)
+		meta.structField(3)
+		meta.i32Field(1, leaf.typ)
+		meta.listField(2, thriftI32, 2)
+		meta.i32(encodingPlain)
+		meta.i32(encodingRLE)
+		meta.listField(3, thriftBinary, len(leaf.path))
+		for _, name := range leaf.path {
+			meta.string(name)
+		}
+		meta.i32Field(4, codecSnappy)
+		meta.i64Field(5, int64(leaf.numValues))
+		meta.i64Field(6, uncompressedSize)
+		meta.i64Field(7, compressedSize)
+		meta.i64Field(9, offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64Field(2, totalByteSize)
+	meta.i64Field(3, int64(numRows))
+	meta.endStruct()
+
+	meta.stringField(6, "metering-operator")
+	meta.endStruct()
+
+	if _, err := counter.Write(meta.Bytes()); err != nil {
+		return err
+	}
+	var footerLength [4]byte
+	binary.LittleEndian.PutUint32(footerLength[:], uint32(len(meta.Bytes())))
+	if _, err := counter.Write(footerLength[:]); err != nil {
+		return err
+	}
+	_, err := io.WriteString(counter, magic)
+	return err
+}
+
+// stringMapLeaves returns the key and value leaf columns of a map column,
+// using the map schema Hive writes: an optional group containing a repeated
+// group of a required key and an optional value.
+func stringMapLeaves(column Column) []*leafColumn {
+	keys := &leafColumn{path: []string{column.Name, "map", "key"}, typ: typeByteArray, maxRepetition: 1, maxDefinition: 2}
+	values := &leafColumn{path: []string{column.Name, "map", "value"}, typ: typeByteArray, maxRepetition: 1, maxDefinition: 3}
+	for _, m := range column.StringMaps {
+		if len(m) == 0 {
+			// an empty map has a defined group with no key_value entries
+			keys.repetitionLevels = append(keys.repetitionLevels, 0)
+			keys.definitionLevels = append(keys.definitionLevels, 1)
+			values.repetitionLevels = append(values.repetitionLevels, 0)
+			values.definitionLevels = append(values.definitionLevels, 1)
+			continue
+		}
+		mapKeys := make([]string, 0, len(m))
+		for k := range m {
+			mapKeys = append(mapKeys, k)
+		}
+		sort.Strings(mapKeys)
+		for i, k := range mapKeys {
+			repetition := 1
+			if i == 0 {
+				repetition = 0
+			}
+			keys.repetitionLevels = append(keys.repetitionLevels, repetition)
+			keys.definitionLevels = append(keys.definitionLevels, 2)
+			writeByteArray(&keys.values, k)
+			values.repetitionLevels = append(values.repetitionLevels, repetition)
+			values.definitionLevels = append(values.definitionLevels, 3)
+			writeByteArray(&values.values, m[k])
+		}
+	}
+	keys.numValues = len(keys.definitionLevels)
+	values.numValues = len(values.definitionLevels)
+	return []*leafColumn{keys, values}
+}
+
+func writeSchema(meta *compactWriter, columns []Column) {
+	numElements := 1
+	for _, column := range columns {
+		if column.Type == StringMap {
+			numElements += 4
+		} else {
+			numElements++
+		}
+	}
+	meta.listField(2, thriftStruct, numElements)
+
+	meta.beginStruct()
+	meta.stringField(4, "hive_schema")
+	meta.i32Field(5, int32(len(columns)))
+	meta.endStruct()
+
+	element := func(typ *int32, repetition int32, name string, numChildren int32, converted *int32) {
+		meta.beginStruct()
+		if typ != nil {
+			meta.i32Field(1, *typ)
+		}
+		meta.i32Field(3, repetition)
+		meta.stringField(4, name)
+		if numChildren != 0 {
+			meta.i32Field(5, numChildren)
+		}
+		if converted != nil {
+			meta.i32Field(6, *converted)
+		}
+		if converted != nil && *converted == convertedTimestamp {
+			// the logicalType equivalent to TIMESTAMP_MILLIS:
+			// TIMESTAMP(isAdjustedToUTC=true, unit=MILLIS)
+			meta.structField(10)
+			meta.structField(8)
+			meta.boolField(1, true)
+			meta.structField(2)
+			meta.structField(1)
+			meta.endStruct()
+			meta.endStruct()
+			meta.endStruct()
+			meta.endStruct()
+		}
+		meta.endStruct()
+	}
+	int32Ptr := func(v int32) *int32 { return &v }
+	for _, column := range columns {
+		switch column.Type {
+		case Double:
+			element(int32Ptr(typeDouble), repetitionRequired, column.Name, 0, nil)
+		case Timestamp:
+			element(int32Ptr(typeInt64), repetitionRequired, column.Name, 0, int32Ptr(convertedTimestamp))
+		case StringMap:
+			element(nil, repetitionOptional, column.Name, 1, int32Ptr(convertedMap))
+			element(nil, repetitionRepeated, "map", 2, int32Ptr(convertedMapKeyValue))
+			element(int32Ptr(typeByteArray), repetitionRequired, "key", 0, int32Ptr(convertedUTF8))
+			element(int32Ptr(typeByteArray), repetitionOptional, "value", 0, int32Ptr(convertedUTF8))
+		}
+	}
+}
+
+// writeDataPage writes the page header and data page of leaf, returning the
+// uncompressed and compressed size of the column chunk including the header.
+func writeDataPage(w io.Writer, leaf *leafColumn) (int64, int64, error) {
+	var page bytes.Buffer
+	if leaf.maxRepetition > 0 {
+		writeLevels(&page, leaf.repetitionLevels, leaf.maxRepetition)
+	}
+	if leaf.maxDefinition > 0 {
+		writeLevels(&page, leaf.definitionLevels, leaf.maxDefinition)
+	}
+	page.Write(leaf.values.Bytes())
+
+	compressed := snappy.Encode(nil, page.Bytes())
+
+	header := &compactWriter{}
+	header.beginStruct()
+	header.i32Field(1, pageTypeData)
+	header.i32Field(2, int32(page.Len()))
+	header.i32Field(3, int32(len(compressed)))
+	header.structField(5)
+	header.i32Field(1, int32(leaf.numValues))
+	header.i32Field(2, encodingPlain)
+	header.i32Field(3, encodingRLE)
+	header.i32Field(4, encodingRLE)
+	header.endStruct()
+	header.endStruct()
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return 0, 0, err
+	}
+	if _, err := w.Write(compressed); err != nil {
+		return 0, 0, err
+	}
+	headerSize := int64(len(header.Bytes()))
+	return headerSize + int64(page.Len()), headerSize + int64(len(compressed)), nil
+}
+
+// writeLevels writes levels using the RLE/bit-packing hybrid encoding
+// prefixed by its length, using only RLE runs.
+func writeLevels(buf *bytes.Buffer, levels []int, maxLevel int) {
+	bitWidth := 0
+	for maxLevel>>uint(bitWidth) != 0 {
+		bitWidth++
+	}
+	byteWidth := (bitWidth + 7) / 8
+
+	var encoded bytes.Buffer
+	var varint [binary.MaxVarintLen64]byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		n := binary.PutUvarint(varint[:], uint64(j-i)<<1)
+		encoded.Write(varint[:n])
+		for b := 0; b < byteWidth; b++ {
+			encoded.WriteByte(byte(levels[i] >> uint(8*b)))
+		}
+		i = j
+	}
+
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(encoded.Len()))
+	buf.Write(length[:])
+	buf.Write(encoded.Bytes())
+}
+
+func writeByteArray(buf *bytes.Buffer, v string) {
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(v)))
+	buf.Write(length[:])
+	buf.WriteString(v)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
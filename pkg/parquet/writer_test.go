@@ -0,0 +1,235 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compactReader decodes Thrift compact protocol structs into maps of field
+// ID to value, so the files written can be checked without a Parquet reader.
+type compactReader struct {
+	t   *testing.T
+	buf *bytes.Reader
+}
+
+func (r *compactReader) varint() uint64 {
+	v, err := binary.ReadUvarint(r.buf)
+	require.NoError(r.t, err)
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) byte() byte {
+	b, err := r.buf.ReadByte()
+	require.NoError(r.t, err)
+	return b
+}
+
+func (r *compactReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftTrue, thriftFalse:
+		return typ == thriftTrue
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		b := make([]byte, r.varint())
+		_, err := r.buf.Read(b)
+		require.NoError(r.t, err)
+		return string(b)
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return r.structValue()
+	}
+	r.t.Fatalf("unexpected thrift type %d", typ)
+	return nil
+}
+
+func (r *compactReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+func readLevels(t *testing.T, buf *bytes.Reader) []int {
+	var length uint32
+	require.NoError(t, binary.Read(buf, binary.LittleEndian, &length))
+	encoded := make([]byte, length)
+	_, err := buf.Read(encoded)
+	require.NoError(t, err)
+	runs := bytes.NewReader(encoded)
+	var levels []int
+	for runs.Len() > 0 {
+		header, err := binary.ReadUvarint(runs)
+		require.NoError(t, err)
+		require.Zero(t, header&1, "expected only RLE runs")
+		value, err := runs.ReadByte()
+		require.NoError(t, err)
+		for i := uint64(0); i < header>>1; i++ {
+			levels = append(levels, int(value))
+		}
+	}
+	return levels
+}
+
+var update = flag.Bool("update", false, "update the golden Parquet file")
+
+// goldenFile is the file written from testColumns. It was checked to be
+// readable by the parquet-go reader, an independent implementation of the
+// format, so it must only be updated after checking the new file with a
+// Parquet reader, such as parquet-tools.
+const goldenFile = "testdata/columns.parquet"
+
+func testColumns() []Column {
+	janOne := time.Date(2019, time.January, 1, 1, 2, 3, 0, time.UTC)
+	return []Column{
+		{Name: "amount", Type: Double, Doubles: []float64{1.5, 2, 3}},
+		{Name: "timestamp", Type: Timestamp, Timestamps: []time.Time{janOne, janOne.Add(time.Minute), janOne.Add(24 * time.Hour)}},
+		{Name: "labels", Type: StringMap, StringMaps: []map[string]string{
+			{"pod": "foo", "namespace": "default"},
+			nil,
+			{"pod": "bar"},
+		}},
+	}
+}
+
+// TestWriteGolden compares the file written with goldenFile, so changes to
+// the encoding are noticed.
+func TestWriteGolden(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, testColumns()))
+	if *update {
+		require.NoError(t, ioutil.WriteFile(goldenFile, buf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(goldenFile)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestWrite(t *testing.T) {
+	columns := testColumns()
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, columns))
+
+	file := buf.Bytes()
+	require.Equal(t, magic, string(file[:4]))
+	require.Equal(t, magic, string(file[len(file)-4:]))
+	footerLength := binary.LittleEndian.Uint32(file[len(file)-8:])
+	footer := file[len(file)-8-int(footerLength) : len(file)-8]
+	meta := (&compactReader{t: t, buf: bytes.NewReader(footer)}).structValue()
+	assert.Equal(t, int64(3), meta[3], "expected num_rows to be 3")
+
+	var schemaNames []interface{}
+	for _, element := range meta[2].([]interface{}) {
+		schemaNames = append(schemaNames, element.(map[int16]interface{})[4])
+	}
+	assert.Equal(t, []interface{}{"hive_schema", "amount", "timestamp", "labels", "map", "key", "value"}, schemaNames)
+
+	rowGroups := meta[4].([]interface{})
+	require.Len(t, rowGroups, 1)
+	chunks := rowGroups[0].(map[int16]interface{})[1].([]interface{})
+	require.Len(t, chunks, 4)
+
+	// readPage returns the decompressed data page of a column chunk
+	readPage := func(chunk interface{}) (map[int16]interface{}, *bytes.Reader) {
+		columnMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		offset := columnMeta[9].(int64)
+		pageReader := &compactReader{t: t, buf: bytes.NewReader(file[offset:])}
+		header := pageReader.structValue()
+		compressed := make([]byte, header[3].(int64))
+		_, err := pageReader.buf.Read(compressed)
+		require.NoError(t, err)
+		page, err := snappy.Decode(nil, compressed)
+		require.NoError(t, err)
+		require.Len(t, page, int(header[2].(int64)))
+		return columnMeta, bytes.NewReader(page)
+	}
+
+	columnMeta, page := readPage(chunks[0])
+	assert.Equal(t, []interface{}{"amount"}, columnMeta[3])
+	var amounts []float64
+	for page.Len() > 0 {
+		var bits uint64
+		require.NoError(t, binary.Read(page, binary.LittleEndian, &bits))
+		amounts = append(amounts, math.Float64frombits(bits))
+	}
+	assert.Equal(t, columns[0].Doubles, amounts)
+
+	// timestamps are INT64 TIMESTAMP_MILLIS adjusted to UTC
+	timestampElement := meta[2].([]interface{})[2].(map[int16]interface{})
+	assert.Equal(t, int64(typeInt64), timestampElement[1])
+	assert.Equal(t, int64(convertedTimestamp), timestampElement[6])
+	assert.Equal(t, map[int16]interface{}{8: map[int16]interface{}{
+		1: true,
+		2: map[int16]interface{}{1: map[int16]interface{}{}},
+	}}, timestampElement[10])
+	_, page = readPage(chunks[1])
+	var millis []int64
+	for page.Len() > 0 {
+		var v int64
+		require.NoError(t, binary.Read(page, binary.LittleEndian, &v))
+		millis = append(millis, v)
+	}
+	assert.Equal(t, []int64{1546304523000, 1546304583000, 1546390923000}, millis)
+
+	columnMeta, page = readPage(chunks[2])
+	assert.Equal(t, []interface{}{"labels", "map", "key"}, columnMeta[3])
+	assert.Equal(t, int64(4), columnMeta[5], "expected a value per key and per empty map")
+	assert.Equal(t, []int{0, 1, 0, 0}, readLevels(t, page))
+	assert.Equal(t, []int{2, 2, 1, 2}, readLevels(t, page))
+	var keys []string
+	for page.Len() > 0 {
+		var length uint32
+		require.NoError(t, binary.Read(page, binary.LittleEndian, &length))
+		key := make([]byte, length)
+		_, err := page.Read(key)
+		require.NoError(t, err)
+		keys = append(keys, string(key))
+	}
+	assert.Equal(t, []string{"namespace", "pod", "pod"}, keys)
+
+	_, page = readPage(chunks[3])
+	readLevels(t, page)
+	assert.Equal(t, []int{3, 3, 1, 3}, readLevels(t, page))
+}
+
+func TestWriteMismatchedColumns(t *testing.T) {
+	err := Write(&bytes.Buffer{}, []Column{
+		{Name: "amount", Type: Double, Doubles: []float64{1}},
+		{Name: "timestamp", Type: Timestamp},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package presto
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuoteString returns s as a Presto string literal. Single quotes are
+// doubled, and invalid UTF-8 sequences are replaced by the Unicode
+// replacement character, since Presto rejects queries which aren't valid
+// UTF-8. Strings containing control characters, such as NUL or newlines, are
+// returned as Unicode escaped literals (U&'...') so they never appear raw in
+// the query text.
+func QuoteString(s string) string {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if strings.IndexFunc(s, unicode.IsControl) == -1 {
+		return "'" + strings.Replace(s, "'", "''", -1) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("U&'")
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			b.WriteString("''")
+		case r == '\\':
+			b.WriteString(`\\`)
+		case unicode.IsControl(r):
+			// all control characters are in the basic multilingual plane,
+			// so the 4 hex digit form is always enough
+			fmt.Fprintf(&b, `\%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// QuoteIdentifier returns s as a quoted Presto identifier, such as a column
+// name.
+func QuoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// FormatDouble returns f as a Presto double literal. Doubles are formatted
+// in exponent notation, which Presto always parses as a double rather than a
+// decimal, with as many digits as needed to represent f exactly. NaN and the
+// infinities don't have literals, so the functions returning them are used.
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan()"
+	case math.IsInf(f, 1):
+		return "infinity()"
+	case math.IsInf(f, -1):
+		return "-infinity()"
+	}
+	return strconv.FormatFloat(f, 'E', -1, 64)
+}
+
+// FormatTimestamp returns t in UTC as a Presto timestamp literal with
+// millisecond precision.
+func FormatTimestamp(t time.Time) string {
+	return "timestamp '" + t.UTC().Format(TimestampFormat) + "'"
+}
+
+// FormatDate returns the day of t, in the location of t, as a Presto date
+// literal.
+func FormatDate(t time.Time) string {
+	return "date '" + t.Format("2006-01-02") + "'"
+}
+
+// FormatStringArray returns values as a Presto array(varchar) literal.
+func FormatStringArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = QuoteString(v)
+	}
+	return "ARRAY[" + strings.Join(quoted, ",") + "]"
+}
+
+// FormatStringMap returns m as a Presto map(varchar, varchar) literal. Keys
+// are sorted so the same map always produces the same literal.
+func FormatStringMap(m map[string]string) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return "map(" + FormatStringArray(keys) + "," + FormatStringArray(values) + ")"
+}
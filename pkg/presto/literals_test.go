@@ -0,0 +1,215 @@
+package presto
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseStringLiteral parses the Presto string literal at the start of query
+// the way Presto's lexer does, returning its value and the rest of query.
+func parseStringLiteral(t *testing.T, query string) (string, string) {
+	unicodeEscaped := strings.HasPrefix(query, "U&'")
+	if unicodeEscaped {
+		query = query[2:]
+	}
+	require.True(t, strings.HasPrefix(query, "'"), "expected a string literal, got %q", query)
+	query = query[1:]
+
+	var value strings.Builder
+	for {
+		require.NotEmpty(t, query, "unterminated string literal")
+		switch {
+		case strings.HasPrefix(query, "''"):
+			value.WriteByte('\'')
+			query = query[2:]
+		case query[0] == '\'':
+			return value.String(), query[1:]
+		case unicodeEscaped && strings.HasPrefix(query, `\\`):
+			value.WriteByte('\\')
+			query = query[2:]
+		case unicodeEscaped && strings.HasPrefix(query, `\+`):
+			r, err := strconv.ParseUint(query[2:8], 16, 32)
+			require.NoError(t, err)
+			value.WriteRune(rune(r))
+			query = query[8:]
+		case unicodeEscaped && query[0] == '\\':
+			r, err := strconv.ParseUint(query[1:5], 16, 32)
+			require.NoError(t, err)
+			value.WriteRune(rune(r))
+			query = query[5:]
+		default:
+			r, size := utf8.DecodeRuneInString(query)
+			value.WriteRune(r)
+			query = query[size:]
+		}
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	tests := map[string]struct {
+		value    string
+		expected string
+	}{
+		"empty": {
+			value:    "",
+			expected: "''",
+		},
+		"plain": {
+			value:    "openshift-metering",
+			expected: "'openshift-metering'",
+		},
+		"single quotes": {
+			value:    "it's a 'pod'",
+			expected: "'it''s a ''pod'''",
+		},
+		"statement injection": {
+			value:    "x'); DROP TABLE foo; --",
+			expected: "'x''); DROP TABLE foo; --'",
+		},
+		"backslashes are literal": {
+			value:    `C:\pods\`,
+			expected: `'C:\pods\'`,
+		},
+		"unicode": {
+			value:    "pöd-日本-😀",
+			expected: "'pöd-日本-😀'",
+		},
+		"control characters": {
+			value:    "a\nb\x00c\\'",
+			expected: `U&'a\000Ab\0000c\\'''`,
+		},
+		"invalid utf-8": {
+			value:    "a\xffb",
+			expected: "'a\uFFFDb'",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, QuoteString(test.value))
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, `"amount"`, QuoteIdentifier("amount"))
+	assert.Equal(t, `"a""b"`, QuoteIdentifier(`a"b`))
+}
+
+func TestFormatDouble(t *testing.T) {
+	tests := map[float64]string{
+		0:               "0E+00",
+		1.5:             "1.5E+00",
+		-2:              "-2E+00",
+		1e-9:            "1E-09",
+		123456789.125:   "1.23456789125E+08",
+		math.MaxFloat64: "1.7976931348623157E+308",
+		math.Inf(1):     "infinity()",
+		math.Inf(-1):    "-infinity()",
+	}
+	for value, expected := range tests {
+		assert.Equal(t, expected, FormatDouble(value))
+	}
+	assert.Equal(t, "nan()", FormatDouble(math.NaN()))
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	ts := time.Date(2019, time.January, 1, 19, 2, 3, 456789000, est)
+	assert.Equal(t, "timestamp '2019-01-02 00:02:03.456'", FormatTimestamp(ts))
+}
+
+func TestFormatDate(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	assert.Equal(t, "date '2019-01-01'", FormatDate(time.Date(2019, time.January, 1, 23, 2, 3, 0, est)))
+}
+
+func TestFormatStringMap(t *testing.T) {
+	assert.Equal(t, "map(ARRAY[],ARRAY[])", FormatStringMap(nil))
+	assert.Equal(t,
+		"map(ARRAY['namespace','pod'],ARRAY['default','it''s'])",
+		FormatStringMap(map[string]string{"pod": "it's", "namespace": "default"}),
+	)
+}
+
+// fuzzSeedEnvVar sets the seed of the fuzz tests, to reproduce a failure
+// using the seed logged by the failed test.
+const fuzzSeedEnvVar = "METERING_FUZZ_SEED"
+
+// newFuzzRand returns the source of random values of a fuzz test, seeded from
+// fuzzSeedEnvVar if it's set, or else the current time. The seed is logged so
+// failures can be reproduced.
+func newFuzzRand(t *testing.T) *rand.Rand {
+	t.Helper()
+	seed := time.Now().UnixNano()
+	if env := os.Getenv(fuzzSeedEnvVar); env != "" {
+		var err error
+		seed, err = strconv.ParseInt(env, 10, 64)
+		require.NoError(t, err, "invalid %s", fuzzSeedEnvVar)
+	}
+	t.Logf("using seed %d, set %s=%d to reproduce", seed, fuzzSeedEnvVar, seed)
+	return rand.New(rand.NewSource(seed))
+}
+
+// randomString returns a string of random bytes and runes, biased towards
+// the characters that need escaping.
+func randomString(rnd *rand.Rand) string {
+	special := []string{"'", "''", `\`, `"`, "\x00", "\n", "\r", "\t", "\x7f", "\u0085", "\u2028", "😀", "日", "\xff", "\xc3", "U&", "--", ";", ")"}
+	var b strings.Builder
+	for i, n := 0, rnd.Intn(20); i < n; i++ {
+		switch rnd.Intn(4) {
+		case 0:
+			b.WriteString(special[rnd.Intn(len(special))])
+		case 1:
+			b.WriteByte(byte(rnd.Intn(256)))
+		case 2:
+			b.WriteRune(rune(rnd.Intn(unicode.MaxRune + 1)))
+		default:
+			b.WriteByte(byte('a' + rnd.Intn(26)))
+		}
+	}
+	return b.String()
+}
+
+// TestQuoteStringFuzz checks that random strings always produce a literal
+// which is valid UTF-8, contains no raw control characters, and is parsed
+// back into the original value with nothing following it.
+func TestQuoteStringFuzz(t *testing.T) {
+	rnd := newFuzzRand(t)
+	for i := 0; i < 10000; i++ {
+		value := randomString(rnd)
+		quoted := QuoteString(value)
+		require.True(t, utf8.ValidString(quoted), "literal of %q is not valid UTF-8: %q", value, quoted)
+		require.Equal(t, -1, strings.IndexFunc(quoted, unicode.IsControl), "literal of %q contains control characters: %q", value, quoted)
+
+		parsed, rest := parseStringLiteral(t, quoted+",1")
+		require.Equal(t, strings.ToValidUTF8(value, "\uFFFD"), parsed, "literal %q", quoted)
+		require.Equal(t, ",1", rest, "literal %q", quoted)
+	}
+}
+
+// TestFormatDoubleFuzz checks that random doubles are formatted as double
+// literals which parse back to the same value.
+func TestFormatDoubleFuzz(t *testing.T) {
+	rnd := newFuzzRand(t)
+	for i := 0; i < 10000; i++ {
+		value := math.Float64frombits(rnd.Uint64())
+		formatted := FormatDouble(value)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		require.Contains(t, formatted, "E", "%v must be formatted as a double rather than a decimal", value)
+		parsed, err := strconv.ParseFloat(formatted, 64)
+		require.NoError(t, err)
+		require.Equal(t, value, parsed)
+	}
+}
@@ -19,6 +19,13 @@ func DeleteFrom(queryer db.Queryer, tableName string) error {
 	return err
 }
 
+// DeleteFromWhere deletes the rows of tableName matching whereClause. Hive
+// tables only support deleting entire partitions, so whereClause must only
+// reference partition columns for them.
+func DeleteFromWhere(queryer db.Queryer, tableName, whereClause string) error {
+	return execQuery(queryer, fmt.Sprintf("DELETE FROM %s WHERE %s", tableName, whereClause))
+}
+
 func InsertInto(queryer db.Queryer, tableName, query string) error {
 	return execQuery(queryer, FormatInsertQuery(tableName, query))
 }
@@ -107,6 +114,24 @@ func QueryMetadata(queryer db.Queryer, catalog, schema, tableName string) ([]Col
 	return cols, nil
 }
 
+// ListCatalogs returns the names of the catalogs loaded by Presto.
+func ListCatalogs(queryer db.Queryer) ([]string, error) {
+	rows, err := ExecuteSelect(queryer, "SHOW CATALOGS")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the Presto catalogs: %v", err)
+	}
+
+	var catalogs []string
+	for _, row := range rows {
+		catalog, ok := row["Catalog"].(string)
+		if !ok {
+			return nil, fmt.Errorf("failed to convert the Presto catalog name to a string")
+		}
+		catalogs = append(catalogs, catalog)
+	}
+	return catalogs, nil
+}
+
 func GenerateGetRowsSQL(tableName string, columns []Column) string {
 	columnsSQL := GenerateQuotedColumnsListSQL(columns)
 	orderBySQL := GenerateOrderBySQL(columns)
@@ -228,7 +253,7 @@ func FormatInsertQuery(target, query string) string {
 }
 
 func quoteColumn(col Column) string {
-	return `"` + col.Name + `"`
+	return QuoteIdentifier(col.Name)
 }
 
 type Row map[string]interface{}
@@ -0,0 +1,206 @@
+// Package relabel rewrites and filters the labels of series the same as the
+// relabel_configs of Prometheus.
+package relabel
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Action is the action a Config performs.
+type Action string
+
+const (
+	// Replace sets TargetLabel to Replacement, with references to groups of
+	// Regex expanded, if Regex matches the joined SourceLabels. If the
+	// expanded Replacement is empty, TargetLabel is removed.
+	Replace Action = "replace"
+	// Keep drops series for which Regex doesn't match the joined
+	// SourceLabels.
+	Keep Action = "keep"
+	// Drop drops series for which Regex matches the joined SourceLabels.
+	Drop Action = "drop"
+	// HashMod sets TargetLabel to the Modulus of a hash of the joined
+	// SourceLabels.
+	HashMod Action = "hashmod"
+	// LabelDrop removes every label with a name matching Regex.
+	LabelDrop Action = "labeldrop"
+	// LabelKeep removes every label with a name not matching Regex.
+	LabelKeep Action = "labelkeep"
+)
+
+const (
+	DefaultSeparator   = ";"
+	DefaultRegex       = "(.*)"
+	DefaultReplacement = "$1"
+)
+
+// Config is a single relabeling step.
+type Config struct {
+	SourceLabels []string
+	Separator    string
+	Regex        *regexp.Regexp
+	Modulus      uint64
+	TargetLabel  string
+	Replacement  string
+	Action       Action
+}
+
+// NewRegexp returns expr compiled as a fully anchored regular expression,
+// which is how Prometheus treats relabeling regular expressions.
+func NewRegexp(expr string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expr + ")$")
+}
+
+// Validate returns an error if c can't be applied.
+func (c *Config) Validate() error {
+	if c.Regex == nil {
+		return fmt.Errorf("regex is required")
+	}
+	for _, name := range c.SourceLabels {
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid source label %q", name)
+		}
+	}
+	switch c.Action {
+	case Replace:
+		if c.TargetLabel == "" {
+			return fmt.Errorf("targetLabel is required for action %s", c.Action)
+		}
+	case HashMod:
+		if !model.LabelName(c.TargetLabel).IsValid() {
+			return fmt.Errorf("invalid targetLabel %q for action %s", c.TargetLabel, c.Action)
+		}
+		if c.Modulus == 0 {
+			return fmt.Errorf("modulus is required for action %s", c.Action)
+		}
+	case Keep, Drop, LabelDrop, LabelKeep:
+	default:
+		return fmt.Errorf("unknown relabel action %q", c.Action)
+	}
+	return nil
+}
+
+// Process applies cfgs to labels in order, returning the resulting labels,
+// or nil if the series was dropped. labels is not modified.
+func Process(labels map[string]string, cfgs []*Config) map[string]string {
+	if len(cfgs) == 0 {
+		return labels
+	}
+	result := make(map[string]string, len(labels))
+	for name, value := range labels {
+		result[name] = value
+	}
+	for _, cfg := range cfgs {
+		if !cfg.apply(result) {
+			return nil
+		}
+	}
+	return result
+}
+
+// SeriesSet finds distinct series which have the same labels after
+// relabeling, such as when a labeldrop removes the only label telling them
+// apart. Their samples can't be told apart once stored, so like Prometheus,
+// they're rejected rather than stored as a single series.
+type SeriesSet struct {
+	// originals are the labels of each series before relabeling, keyed by
+	// its labels after relabeling.
+	originals map[string]string
+}
+
+func NewSeriesSet() *SeriesSet {
+	return &SeriesSet{originals: make(map[string]string)}
+}
+
+// Add records that the series with the labels original has the labels
+// relabeled after relabeling. It returns an error if a different series
+// already has the same labels after relabeling. Adding the same series more
+// than once isn't an error.
+func (s *SeriesSet) Add(original, relabeled map[string]string) error {
+	key := labelsString(relabeled)
+	originalKey := labelsString(original)
+	if existing, ok := s.originals[key]; ok && existing != originalKey {
+		return fmt.Errorf("duplicate series after relabeling: %s and %s both have the labels %s", existing, originalKey, key)
+	}
+	s.originals[key] = originalKey
+	return nil
+}
+
+// labelsString returns labels sorted by name, in the same format as
+// Prometheus.
+func labelsString(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// apply applies c to labels in place, returning false if the series is
+// dropped.
+func (c *Config) apply(labels map[string]string) bool {
+	values := make([]string, len(c.SourceLabels))
+	for i, name := range c.SourceLabels {
+		values[i] = labels[name]
+	}
+	value := strings.Join(values, c.Separator)
+
+	switch c.Action {
+	case Drop:
+		return !c.Regex.MatchString(value)
+	case Keep:
+		return c.Regex.MatchString(value)
+	case Replace:
+		indexes := c.Regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(c.Regex.ExpandString(nil, c.TargetLabel, value, indexes))
+		if !model.LabelName(target).IsValid() {
+			return true
+		}
+		replacement := string(c.Regex.ExpandString(nil, c.Replacement, value, indexes))
+		if replacement == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = replacement
+		}
+	case HashMod:
+		labels[c.TargetLabel] = fmt.Sprintf("%d", sum64(md5.Sum([]byte(value)))%c.Modulus)
+	case LabelDrop:
+		for name := range labels {
+			if c.Regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	case LabelKeep:
+		for name := range labels {
+			if !c.Regex.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+	}
+	return true
+}
+
+// sum64 returns the last 8 bytes of hash as a big endian integer, which is
+// how Prometheus hashes for the hashmod action.
+func sum64(hash [md5.Size]byte) uint64 {
+	var s uint64
+	for _, b := range hash[md5.Size-8:] {
+		s = s<<8 | uint64(b)
+	}
+	return s
+}
@@ -0,0 +1,165 @@
+package relabel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConfig(t *testing.T, cfg Config, regex string) *Config {
+	if cfg.Separator == "" {
+		cfg.Separator = DefaultSeparator
+	}
+	if cfg.Replacement == "" {
+		cfg.Replacement = DefaultReplacement
+	}
+	if regex == "" {
+		regex = DefaultRegex
+	}
+	var err error
+	cfg.Regex, err = NewRegexp(regex)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	return &cfg
+}
+
+func TestProcess(t *testing.T) {
+	labels := map[string]string{
+		"__name__":        "kube_pod_labels",
+		"namespace":       "default",
+		"pod":             "foo-1",
+		"label_app":       "foo",
+		"label_team":      "metering",
+		"label_pod_hash":  "abc123",
+		"label_component": "",
+	}
+
+	tests := map[string]struct {
+		cfgs     []Config
+		regexes  []string
+		expected map[string]string
+	}{
+		"keep matching": {
+			cfgs:     []Config{{Action: Keep, SourceLabels: []string{"namespace"}}},
+			regexes:  []string{"default|kube-system"},
+			expected: labels,
+		},
+		"keep not matching": {
+			cfgs:    []Config{{Action: Keep, SourceLabels: []string{"namespace"}}},
+			regexes: []string{"kube-system"},
+		},
+		"drop joined source labels": {
+			cfgs:    []Config{{Action: Drop, SourceLabels: []string{"namespace", "pod"}}},
+			regexes: []string{"default;foo-.*"},
+		},
+		"regex is anchored": {
+			cfgs:     []Config{{Action: Drop, SourceLabels: []string{"pod"}}},
+			regexes:  []string{"foo"},
+			expected: labels,
+		},
+		"labelkeep and labeldrop": {
+			cfgs: []Config{
+				{Action: LabelKeep},
+				{Action: LabelDrop},
+			},
+			regexes: []string{"__name__|namespace|pod|label_.*", "label_pod_hash"},
+			expected: map[string]string{
+				"__name__":        "kube_pod_labels",
+				"namespace":       "default",
+				"pod":             "foo-1",
+				"label_app":       "foo",
+				"label_team":      "metering",
+				"label_component": "",
+			},
+		},
+		"replace": {
+			cfgs: []Config{
+				{Action: Replace, SourceLabels: []string{"label_team", "label_app"}, TargetLabel: "owner", Replacement: "$2@$1"},
+				// the target label is removed if the replacement is empty
+				{Action: Replace, SourceLabels: []string{"label_component"}, TargetLabel: "label_pod_hash"},
+				// nothing is done if the regex doesn't match
+				{Action: Replace, SourceLabels: []string{"pod"}, TargetLabel: "pod_index"},
+			},
+			regexes: []string{"(.*);(.*)", "", "bar-(\\d+)"},
+			expected: map[string]string{
+				"__name__":        "kube_pod_labels",
+				"namespace":       "default",
+				"pod":             "foo-1",
+				"label_app":       "foo",
+				"label_team":      "metering",
+				"label_component": "",
+				"owner":           "foo@metering",
+			},
+		},
+		"hashmod": {
+			cfgs: []Config{
+				{Action: HashMod, SourceLabels: []string{"pod"}, TargetLabel: "shard", Modulus: 1},
+				{Action: LabelKeep},
+			},
+			regexes:  []string{"", "pod|shard"},
+			expected: map[string]string{"pod": "foo-1", "shard": "0"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			var cfgs []*Config
+			for i, cfg := range test.cfgs {
+				cfgs = append(cfgs, newConfig(t, cfg, test.regexes[i]))
+			}
+			original := make(map[string]string, len(labels))
+			for k, v := range labels {
+				original[k] = v
+			}
+			assert.Equal(t, test.expected, Process(labels, cfgs))
+			assert.Equal(t, original, labels, "expected the input labels to be unmodified")
+		})
+	}
+}
+
+func TestHashMod(t *testing.T) {
+	regex, err := NewRegexp(DefaultRegex)
+	require.NoError(t, err)
+	cfg := &Config{Action: HashMod, SourceLabels: []string{"pod"}, Separator: DefaultSeparator, Regex: regex, TargetLabel: "shard", Modulus: 1000}
+	first := Process(map[string]string{"pod": "foo-1"}, []*Config{cfg})
+	second := Process(map[string]string{"pod": "foo-1"}, []*Config{cfg})
+	assert.Equal(t, first["shard"], second["shard"], "expected hashmod to be deterministic")
+	assert.NotEmpty(t, first["shard"])
+}
+
+func TestSeriesSet(t *testing.T) {
+	cfgs := []*Config{newConfig(t, Config{Action: LabelDrop}, "pod")}
+	foo := map[string]string{"namespace": "default", "pod": "foo"}
+	bar := map[string]string{"namespace": "default", "pod": "bar"}
+	other := map[string]string{"namespace": "other", "pod": "foo"}
+
+	set := NewSeriesSet()
+	require.NoError(t, set.Add(foo, Process(foo, cfgs)))
+	// the same series again, such as when its samples are split between
+	// several parts of a response
+	require.NoError(t, set.Add(foo, Process(foo, cfgs)))
+	require.NoError(t, set.Add(other, Process(other, cfgs)))
+
+	err := set.Add(bar, Process(bar, cfgs))
+	require.Error(t, err)
+	assert.Equal(t, `duplicate series after relabeling: {namespace="default", pod="foo"} and {namespace="default", pod="bar"} both have the labels {namespace="default"}`, err.Error())
+}
+
+func TestValidate(t *testing.T) {
+	regex, err := NewRegexp(DefaultRegex)
+	require.NoError(t, err)
+	invalid := map[string]Config{
+		"unknown action":           {Action: "labelmap", Regex: regex},
+		"replace without target":   {Action: Replace, Regex: regex},
+		"hashmod without modulus":  {Action: HashMod, Regex: regex, TargetLabel: "shard"},
+		"hashmod invalid target":   {Action: HashMod, Regex: regex, TargetLabel: "$1", Modulus: 2},
+		"invalid source label":     {Action: Keep, Regex: regex, SourceLabels: []string{"not-valid"}},
+		"missing compiled regexes": {Action: Keep},
+	}
+	for name, cfg := range invalid {
+		cfg := cfg
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
@@ -0,0 +1,292 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// ReadVersionHeader is the header remote_read clients send the version
+	// of the protocol in.
+	ReadVersionHeader = "X-Prometheus-Remote-Read-Version"
+	readVersion       = "0.1.0"
+
+	// ReadPath is the path of the remote_read API of Prometheus.
+	ReadPath = "/api/v1/read"
+)
+
+// field numbers of the ReadRequest and ReadResponse messages in
+// prompb/remote.proto and the LabelMatcher message in prompb/types.proto
+const (
+	readRequestQueriesField protowire.Number = 1
+
+	queryStartTimestampField protowire.Number = 1
+	queryEndTimestampField   protowire.Number = 2
+	queryMatchersField       protowire.Number = 3
+
+	labelMatcherTypeField  protowire.Number = 1
+	labelMatcherNameField  protowire.Number = 2
+	labelMatcherValueField protowire.Number = 3
+
+	readResponseResultsField   protowire.Number = 1
+	queryResultTimeseriesField protowire.Number = 1
+)
+
+// MatchType is the type of a LabelMatcher, using the values of the
+// LabelMatcher.Type enum in prompb.
+type MatchType int
+
+const (
+	MatchEqual MatchType = iota
+	MatchNotEqual
+	MatchRegexp
+	MatchNotRegexp
+)
+
+// LabelMatcher selects series by the value of a label, the same as the
+// matchers of a PromQL selector.
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// Query selects the samples of the series matching all Matchers with
+// timestamps between StartTimestampMs and EndTimestampMs inclusive, both in
+// milliseconds since the Unix epoch.
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// EncodeReadRequest returns queries as a snappy compressed ReadRequest.
+func EncodeReadRequest(queries []Query) []byte {
+	var data []byte
+	for _, query := range queries {
+		data = protowire.AppendTag(data, readRequestQueriesField, protowire.BytesType)
+		data = protowire.AppendBytes(data, marshalQuery(query))
+	}
+	return snappy.Encode(nil, data)
+}
+
+func marshalQuery(query Query) []byte {
+	var data []byte
+	data = protowire.AppendTag(data, queryStartTimestampField, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(query.StartTimestampMs))
+	data = protowire.AppendTag(data, queryEndTimestampField, protowire.VarintType)
+	data = protowire.AppendVarint(data, uint64(query.EndTimestampMs))
+	for _, matcher := range query.Matchers {
+		var m []byte
+		m = protowire.AppendTag(m, labelMatcherTypeField, protowire.VarintType)
+		m = protowire.AppendVarint(m, uint64(matcher.Type))
+		m = protowire.AppendTag(m, labelMatcherNameField, protowire.BytesType)
+		m = protowire.AppendString(m, matcher.Name)
+		m = protowire.AppendTag(m, labelMatcherValueField, protowire.BytesType)
+		m = protowire.AppendString(m, matcher.Value)
+
+		data = protowire.AppendTag(data, queryMatchersField, protowire.BytesType)
+		data = protowire.AppendBytes(data, m)
+	}
+	return data
+}
+
+// DecodeReadRequest reads a snappy compressed ReadRequest from r, and
+// returns the queries it contains.
+func DecodeReadRequest(r io.Reader) ([]Query, error) {
+	data, err := readCompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	var queries []Query
+	err = forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != readRequestQueriesField {
+			return nil
+		}
+		if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+			return err
+		}
+		query, err := unmarshalQuery(value)
+		if err != nil {
+			return fmt.Errorf("invalid query: %v", err)
+		}
+		queries = append(queries, query)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+func unmarshalQuery(data []byte) (Query, error) {
+	var query Query
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case queryStartTimestampField, queryEndTimestampField:
+			if err := checkWireType(num, typ, protowire.VarintType); err != nil {
+				return err
+			}
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if num == queryStartTimestampField {
+				query.StartTimestampMs = int64(v)
+			} else {
+				query.EndTimestampMs = int64(v)
+			}
+		case queryMatchersField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			matcher, err := unmarshalLabelMatcher(value)
+			if err != nil {
+				return err
+			}
+			query.Matchers = append(query.Matchers, matcher)
+		}
+		return nil
+	})
+	return query, err
+}
+
+func unmarshalLabelMatcher(data []byte) (LabelMatcher, error) {
+	var matcher LabelMatcher
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case labelMatcherTypeField:
+			if err := checkWireType(num, typ, protowire.VarintType); err != nil {
+				return err
+			}
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if v > uint64(MatchNotRegexp) {
+				return fmt.Errorf("unknown match type %d", v)
+			}
+			matcher.Type = MatchType(v)
+		case labelMatcherNameField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			matcher.Name = string(value)
+		case labelMatcherValueField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			matcher.Value = string(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return LabelMatcher{}, fmt.Errorf("invalid label matcher: %v", err)
+	}
+	return matcher, nil
+}
+
+// EncodeReadResponse returns the series selected by each query of a
+// ReadRequest as a snappy compressed ReadResponse.
+func EncodeReadResponse(results [][]TimeSeries) []byte {
+	var data []byte
+	for _, series := range results {
+		data = protowire.AppendTag(data, readResponseResultsField, protowire.BytesType)
+		data = protowire.AppendBytes(data, appendTimeSeries(nil, queryResultTimeseriesField, series))
+	}
+	return snappy.Encode(nil, data)
+}
+
+// DecodeReadResponse reads a snappy compressed ReadResponse from r, and
+// returns the series selected by each query of the ReadRequest.
+func DecodeReadResponse(r io.Reader) ([][]TimeSeries, error) {
+	data, err := readCompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	var results [][]TimeSeries
+	err = forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != readResponseResultsField {
+			return nil
+		}
+		if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+			return err
+		}
+		series, err := unmarshalRepeatedTimeSeries(value, queryResultTimeseriesField)
+		if err != nil {
+			return fmt.Errorf("invalid query result: %v", err)
+		}
+		results = append(results, series)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Client reads samples using the remote_read API of Prometheus. Only the
+// SAMPLES response type is supported, since it is supported by every
+// version of Prometheus and Thanos.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient returns a Client for the Prometheus at address. If roundTripper
+// is nil, http.DefaultTransport is used.
+func NewClient(address string, roundTripper http.RoundTripper) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Prometheus address %s: %v", address, err)
+	}
+	u.Path = path.Join(u.Path, ReadPath)
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	return &Client{
+		url:    u.String(),
+		client: &http.Client{Transport: roundTripper},
+	}, nil
+}
+
+// Read returns the series selected by query.
+func (c *Client) Read(ctx context.Context, query Query) ([]TimeSeries, error) {
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(EncodeReadRequest([]Query{query})))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Content-Encoding", ContentEncoding)
+	req.Header.Set(ReadVersionHeader, readVersion)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("remote_read request to %s failed with status %s: %s", c.url, resp.Status, bytes.TrimSpace(body))
+	}
+
+	results, err := DecodeReadResponse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode remote_read response: %v", err)
+	}
+	if len(results) != 1 {
+		return nil, fmt.Errorf("expected 1 result in remote_read response, got %d", len(results))
+	}
+	return results[0], nil
+}
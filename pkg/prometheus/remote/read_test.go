@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRead(t *testing.T) {
+	query := Query{
+		StartTimestampMs: 1546300800000,
+		EndTimestampMs:   1546304399999,
+		Matchers: []LabelMatcher{
+			{Type: MatchEqual, Name: "__name__", Value: "kube_pod_container_resource_requests"},
+			{Type: MatchNotRegexp, Name: "namespace", Value: "openshift-.*"},
+		},
+	}
+	series := []TimeSeries{
+		{
+			Labels: map[string]string{"__name__": "kube_pod_container_resource_requests", "namespace": "default"},
+			Samples: []Sample{
+				{Value: 0.5, Timestamp: 1546300800000},
+				{Value: 0.25, Timestamp: 1546300830000},
+			},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ReadPath, r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, ContentEncoding, r.Header.Get("Content-Encoding"))
+		assert.NotEmpty(t, r.Header.Get(ReadVersionHeader))
+
+		queries, err := DecodeReadRequest(r.Body)
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, []Query{query}, queries)
+
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Content-Encoding", ContentEncoding)
+		w.Write(EncodeReadResponse([][]TimeSeries{series}))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, nil)
+	require.NoError(t, err)
+	result, err := client.Read(context.Background(), query)
+	require.NoError(t, err)
+	assert.Equal(t, series, result)
+}
+
+func TestClientReadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "remote read is disabled", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Read(context.Background(), Query{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "remote read is disabled")
+}
+
+func TestDecodeReadRequestErrors(t *testing.T) {
+	tests := map[string][]byte{
+		// a query with the start timestamp as a string
+		"wrong start timestamp type": {0x0a, 0x03, 0x0a, 0x01, 0x31},
+		// a query with the matcher as a varint
+		"wrong matcher type": {0x0a, 0x02, 0x18, 0x01},
+		// a query with a matcher of type 4
+		"unknown match type": {0x0a, 0x04, 0x1a, 0x02, 0x08, 0x04},
+	}
+	for name, data := range tests {
+		data := data
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeReadRequest(bytes.NewReader(snappy.Encode(nil, data)))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,282 @@
+// Package remote implements the Prometheus remote_write and remote_read
+// protocols, which send snappy compressed protobuf messages, as defined in
+// prompb/remote.proto and prompb/types.proto of the Prometheus repository.
+// Only the fields of the messages used by metering are supported.
+//
+// The messages are encoded with protowire instead of the generated prompb
+// package, since prompb is part of the github.com/prometheus/prometheus
+// module, which would pull in the Prometheus server and its own versions of
+// the Kubernetes client libraries. Unknown fields are skipped, like the
+// generated code does, but known fields with the wrong wire type are
+// rejected.
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// ContentType is the Content-Type of remote_write and remote_read
+	// requests and responses.
+	ContentType = "application/x-protobuf"
+	// ContentEncoding is the Content-Encoding of remote_write and
+	// remote_read requests and responses.
+	ContentEncoding = "snappy"
+
+	// MaxMessageSize is the maximum size of a compressed remote_write
+	// request or remote_read response which will be read.
+	MaxMessageSize = 32 * 1024 * 1024
+	// MaxDecodedMessageSize is the maximum size of a remote_write request
+	// or remote_read response once it's decompressed.
+	MaxDecodedMessageSize = 128 * 1024 * 1024
+)
+
+// field numbers of the messages in prompb/types.proto
+const (
+	timeSeriesLabelsField  protowire.Number = 1
+	timeSeriesSamplesField protowire.Number = 2
+
+	labelNameField  protowire.Number = 1
+	labelValueField protowire.Number = 2
+
+	sampleValueField     protowire.Number = 1
+	sampleTimestampField protowire.Number = 2
+)
+
+// TimeSeries is a series of samples and the labels identifying it.
+type TimeSeries struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Sample is a single value of a TimeSeries. Timestamp is in milliseconds
+// since the Unix epoch.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// readCompressed reads a snappy compressed message of at most MaxMessageSize
+// bytes from r, and returns it decompressed. The decompressed size is read
+// from the header of the message, and messages which would decompress to more
+// than MaxDecodedMessageSize bytes are rejected before allocating it.
+func readCompressed(r io.Reader) ([]byte, error) {
+	compressed, err := ioutil.ReadAll(io.LimitReader(r, MaxMessageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) > MaxMessageSize {
+		return nil, fmt.Errorf("message is larger than %d bytes", MaxMessageSize)
+	}
+	decodedLen, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress message: %v", err)
+	}
+	if decodedLen > MaxDecodedMessageSize {
+		return nil, fmt.Errorf("decompressed message is larger than %d bytes", MaxDecodedMessageSize)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress message: %v", err)
+	}
+	return data, nil
+}
+
+// unmarshalRepeatedTimeSeries decodes the repeated TimeSeries field num of
+// the message in data.
+func unmarshalRepeatedTimeSeries(data []byte, num protowire.Number) ([]TimeSeries, error) {
+	var series []TimeSeries
+	err := forEachField(data, func(fieldNum protowire.Number, typ protowire.Type, value []byte) error {
+		if fieldNum != num {
+			return nil
+		}
+		if err := checkWireType(fieldNum, typ, protowire.BytesType); err != nil {
+			return err
+		}
+		ts, err := unmarshalTimeSeries(value)
+		if err != nil {
+			return fmt.Errorf("invalid timeseries: %v", err)
+		}
+		series = append(series, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+func unmarshalTimeSeries(data []byte) (TimeSeries, error) {
+	ts := TimeSeries{Labels: make(map[string]string)}
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case timeSeriesLabelsField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			name, labelValue, err := unmarshalLabel(value)
+			if err != nil {
+				return err
+			}
+			if _, exists := ts.Labels[name]; exists {
+				return fmt.Errorf("duplicate label %s", name)
+			}
+			ts.Labels[name] = labelValue
+		case timeSeriesSamplesField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			sample, err := unmarshalSample(value)
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, sample)
+		}
+		return nil
+	})
+	return ts, err
+}
+
+func unmarshalLabel(data []byte) (name, value string, err error) {
+	err = forEachField(data, func(num protowire.Number, typ protowire.Type, fieldValue []byte) error {
+		switch num {
+		case labelNameField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			name = string(fieldValue)
+		case labelValueField:
+			if err := checkWireType(num, typ, protowire.BytesType); err != nil {
+				return err
+			}
+			value = string(fieldValue)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("invalid label: %v", err)
+	}
+	if name == "" {
+		return "", "", fmt.Errorf("invalid label: name is empty")
+	}
+	return name, value, nil
+}
+
+func unmarshalSample(data []byte) (Sample, error) {
+	var sample Sample
+	err := forEachField(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		switch num {
+		case sampleValueField:
+			if err := checkWireType(num, typ, protowire.Fixed64Type); err != nil {
+				return err
+			}
+			v, n := protowire.ConsumeFixed64(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			sample.Value = math.Float64frombits(v)
+		case sampleTimestampField:
+			if err := checkWireType(num, typ, protowire.VarintType); err != nil {
+				return err
+			}
+			v, n := protowire.ConsumeVarint(value)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			sample.Timestamp = int64(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid sample: %v", err)
+	}
+	return sample, nil
+}
+
+// appendTimeSeries appends each of series to data as the field num.
+func appendTimeSeries(data []byte, num protowire.Number, series []TimeSeries) []byte {
+	for _, ts := range series {
+		data = protowire.AppendTag(data, num, protowire.BytesType)
+		data = protowire.AppendBytes(data, marshalTimeSeries(ts))
+	}
+	return data
+}
+
+func marshalTimeSeries(ts TimeSeries) []byte {
+	names := make([]string, 0, len(ts.Labels))
+	for name := range ts.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, labelNameField, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, labelValueField, protowire.BytesType)
+		label = protowire.AppendString(label, ts.Labels[name])
+
+		data = protowire.AppendTag(data, timeSeriesLabelsField, protowire.BytesType)
+		data = protowire.AppendBytes(data, label)
+	}
+	for _, sample := range ts.Samples {
+		var s []byte
+		s = protowire.AppendTag(s, sampleValueField, protowire.Fixed64Type)
+		s = protowire.AppendFixed64(s, math.Float64bits(sample.Value))
+		s = protowire.AppendTag(s, sampleTimestampField, protowire.VarintType)
+		s = protowire.AppendVarint(s, uint64(sample.Timestamp))
+
+		data = protowire.AppendTag(data, timeSeriesSamplesField, protowire.BytesType)
+		data = protowire.AppendBytes(data, s)
+	}
+	return data
+}
+
+// checkWireType returns an error if the field num has the wire type typ
+// instead of want.
+func checkWireType(num protowire.Number, typ, want protowire.Type) error {
+	if typ != want {
+		return fmt.Errorf("field %d has wire type %d, expected %d", num, typ, want)
+	}
+	return nil
+}
+
+// forEachField calls fn with each field of the protobuf message in data. For
+// fields of type BytesType value is the content of the field, and for other
+// types it is the encoded value.
+func forEachField(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		value := data[:n]
+		if typ == protowire.BytesType {
+			var m int
+			value, m = protowire.ConsumeBytes(value)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package remote
+
+import (
+	"io"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WriteVersionHeader is the header remote_write clients send the version of
+// the protocol in.
+const WriteVersionHeader = "X-Prometheus-Remote-Write-Version"
+
+// field numbers of the WriteRequest message in prompb/remote.proto
+const writeRequestTimeseriesField protowire.Number = 1
+
+// DecodeWriteRequest reads a snappy compressed WriteRequest from r, and
+// returns the series it contains.
+func DecodeWriteRequest(r io.Reader) ([]TimeSeries, error) {
+	data, err := readCompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalWriteRequest(data)
+}
+
+// UnmarshalWriteRequest decodes the protobuf encoded WriteRequest in data.
+// Fields other than the timeseries, such as metadata, are ignored.
+func UnmarshalWriteRequest(data []byte) ([]TimeSeries, error) {
+	return unmarshalRepeatedTimeSeries(data, writeRequestTimeseriesField)
+}
+
+// EncodeWriteRequest returns series as a snappy compressed WriteRequest,
+// suitable for sending to a remote_write receiver.
+func EncodeWriteRequest(series []TimeSeries) []byte {
+	return snappy.Encode(nil, MarshalWriteRequest(series))
+}
+
+// MarshalWriteRequest returns series as a protobuf encoded WriteRequest.
+// Labels are encoded sorted by name, as Prometheus requires.
+func MarshalWriteRequest(series []TimeSeries) []byte {
+	return appendTimeSeries(nil, writeRequestTimeseriesField, series)
+}
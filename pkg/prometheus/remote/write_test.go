@@ -0,0 +1,126 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeWriteRequest(t *testing.T) {
+	series := []TimeSeries{
+		{
+			Labels: map[string]string{"__name__": "kube_pod_container_resource_requests", "namespace": "default", "pod": "foo"},
+			Samples: []Sample{
+				{Value: 0.5, Timestamp: 1546300800000},
+				{Value: 1.25, Timestamp: 1546300860000},
+			},
+		},
+		{
+			Labels:  map[string]string{"__name__": "up", "job": "kubelet"},
+			Samples: []Sample{{Value: 1, Timestamp: 1546300800000}},
+		},
+	}
+
+	decoded, err := DecodeWriteRequest(bytes.NewReader(EncodeWriteRequest(series)))
+	require.NoError(t, err)
+	assert.Equal(t, series, decoded)
+}
+
+// prometheusWriteRequest is a WriteRequest laid out the way the generated
+// prompb code of Prometheus marshals it: fields in order of their number,
+// fields with the zero value left out, samples with the staleness marker
+// Prometheus writes when a series disappears, and metadata, which Prometheus
+// sends in the same field of the WriteRequest since v2.23.
+var prometheusWriteRequest = strings.Join([]string{
+	"0a50", // timeseries
+	"0a0e" + "0a085f5f6e616d655f5f" + "12027570",       // labels {name: "__name__", value: "up"}
+	"0a11" + "0a036a6f62" + "120a70726f6d657468657573", // labels {name: "job", value: "prometheus"}
+	"1210" + "09000000000000f03f" + "1080f8d6b5802d",   // samples {value: 1, timestamp: 1546300800000}
+	"1207" + "1098edd7b5802d",                          // samples {timestamp: 1546300815000}, the value 0 is left out
+	"1210" + "09020000000000f07f" + "10b0e2d8b5802d",   // samples {value: stale NaN, timestamp: 1546300830000}
+	"0a26", // timeseries
+	"0a0e" + "0a085f5f6e616d655f5f" + "12027570",                                      // labels {name: "__name__", value: "up"}
+	"1214" + "09000000000000e0bf" + "1098f8ffffffffffffff01",                          // samples {value: -0.5, timestamp: -1000}
+	"1a1e" + "0802" + "12027570" + "22163120696620746865207461726765742069732075702e", // metadata {type: GAUGE, metric_family_name: "up", help: "1 if the target is up."}
+}, "")
+
+func TestUnmarshalPrometheusWriteRequest(t *testing.T) {
+	data, err := hex.DecodeString(prometheusWriteRequest)
+	require.NoError(t, err)
+
+	decoded, err := UnmarshalWriteRequest(data)
+	require.NoError(t, err)
+	require.Len(t, decoded, 2)
+
+	assert.Equal(t, map[string]string{"__name__": "up", "job": "prometheus"}, decoded[0].Labels)
+	require.Len(t, decoded[0].Samples, 3)
+	assert.Equal(t, Sample{Value: 1, Timestamp: 1546300800000}, decoded[0].Samples[0])
+	assert.Equal(t, Sample{Value: 0, Timestamp: 1546300815000}, decoded[0].Samples[1])
+	assert.Equal(t, uint64(0x7ff0000000000002), math.Float64bits(decoded[0].Samples[2].Value))
+	assert.Equal(t, int64(1546300830000), decoded[0].Samples[2].Timestamp)
+
+	assert.Equal(t, []TimeSeries{{
+		Labels:  map[string]string{"__name__": "up"},
+		Samples: []Sample{{Value: -0.5, Timestamp: -1000}},
+	}}, decoded[1:])
+
+	// the second series has no zero values, so it's marshaled the same as
+	// Prometheus does
+	assert.Equal(t, prometheusWriteRequest[strings.Index(prometheusWriteRequest, "0a26"):strings.Index(prometheusWriteRequest, "1a1e")], hex.EncodeToString(MarshalWriteRequest(decoded[1:])))
+}
+
+func TestUnmarshalWriteRequestIgnoresUnknownFields(t *testing.T) {
+	data := MarshalWriteRequest([]TimeSeries{
+		{
+			Labels:  map[string]string{"__name__": "up"},
+			Samples: []Sample{{Value: math.Inf(1), Timestamp: 1000}},
+		},
+	})
+	// WriteRequest field 3 is metadata, which is ignored
+	data = protowire.AppendTag(data, 3, protowire.BytesType)
+	data = protowire.AppendString(data, "metadata")
+
+	decoded, err := UnmarshalWriteRequest(data)
+	require.NoError(t, err)
+	require.Len(t, decoded, 1)
+	assert.Equal(t, map[string]string{"__name__": "up"}, decoded[0].Labels)
+	assert.True(t, math.IsInf(decoded[0].Samples[0].Value, 1))
+}
+
+func TestDecodeWriteRequestErrors(t *testing.T) {
+	// a snappy block starts with its decompressed length as a varint
+	tooLarge := make([]byte, binary.MaxVarintLen64)
+	tooLarge = tooLarge[:binary.PutUvarint(tooLarge, MaxDecodedMessageSize+1)]
+
+	tests := map[string][]byte{
+		"decompressed too large": tooLarge,
+		"not snappy":             []byte("\xff\xff\xff\xff\xff"),
+		"truncated":              snappy.Encode(nil, MarshalWriteRequest([]TimeSeries{{Labels: map[string]string{"a": "b"}}}))[:6],
+		"invalid proto":          snappy.Encode(nil, []byte{0x0a, 0x05, 0x01}),
+		// timeseries as a varint
+		"wrong timeseries type": snappy.Encode(nil, []byte{0x08, 0x01}),
+		// a label {name: "a", value: "b"} as a fixed32
+		"wrong label type": snappy.Encode(nil, []byte{0x0a, 0x05, 0x0d, 0x61, 0x61, 0x61, 0x61}),
+		// a sample with the value as a varint
+		"wrong sample value type": snappy.Encode(nil, []byte{0x0a, 0x04, 0x12, 0x02, 0x08, 0x01}),
+		// a sample with the timestamp as a fixed64
+		"wrong sample timestamp type": snappy.Encode(nil, []byte{0x0a, 0x0b, 0x12, 0x09, 0x11, 0, 0, 0, 0, 0, 0, 0, 0}),
+		// the label "a" twice
+		"duplicate label": snappy.Encode(nil, []byte{0x0a, 0x0a, 0x0a, 0x03, 0x0a, 0x01, 0x61, 0x0a, 0x03, 0x0a, 0x01, 0x61}),
+	}
+	for name, body := range tests {
+		body := body
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeWriteRequest(bytes.NewReader(body))
+			assert.Error(t, err)
+		})
+	}
+}